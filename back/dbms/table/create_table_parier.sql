@@ -789,3 +789,367 @@ COMMENT ON COLUMN t_referral_earning.ct_modify IS 'Дата модификаци
 COMMENT ON COLUMN t_referral_earning.ct_delete IS 'Дата логического удаления';
 
 CREATE UNIQUE INDEX uk_t_referral_earning_ck_referral ON t_referral_earning(ck_referral);
+
+--changeset artemov_i:init_parier_referral_commission dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- РЕФЕРАЛЬНЫЕ ВОЗНАГРАЖДЕНИЯ
+-- =====================================================
+
+ALTER TABLE t_referral_code
+    ADD COLUMN IF NOT EXISTS ck_create VARCHAR(255) NOT NULL DEFAULT 'system',
+    ADD COLUMN IF NOT EXISTS ct_create TIMESTAMP NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS ck_modify VARCHAR(255) NOT NULL DEFAULT 'system',
+    ADD COLUMN IF NOT EXISTS ct_modify TIMESTAMP NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS ct_delete TIMESTAMP NULL;
+
+COMMENT ON COLUMN t_referral_code.ck_create IS 'Идентификатор создателя';
+COMMENT ON COLUMN t_referral_code.ct_create IS 'Дата создания';
+COMMENT ON COLUMN t_referral_code.ck_modify IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_referral_code.ct_modify IS 'Дата модификации';
+COMMENT ON COLUMN t_referral_code.ct_delete IS 'Дата логического удаления';
+
+CREATE UNIQUE INDEX IF NOT EXISTS uk_t_referral_code_cv_code ON t_referral_code(cv_code);
+
+ALTER TABLE t_referral
+    ADD COLUMN IF NOT EXISTS ck_create VARCHAR(255) NOT NULL DEFAULT 'system',
+    ADD COLUMN IF NOT EXISTS ct_create TIMESTAMP NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS ck_modify VARCHAR(255) NOT NULL DEFAULT 'system',
+    ADD COLUMN IF NOT EXISTS ct_modify TIMESTAMP NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS ct_delete TIMESTAMP NULL;
+
+COMMENT ON COLUMN t_referral.ck_create IS 'Идентификатор создателя';
+COMMENT ON COLUMN t_referral.ct_create IS 'Дата создания (начало срока начисления вознаграждений)';
+COMMENT ON COLUMN t_referral.ck_modify IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_referral.ct_modify IS 'Дата модификации';
+COMMENT ON COLUMN t_referral.ct_delete IS 'Дата логического удаления';
+
+-- У пользователя может быть только один пригласивший
+CREATE UNIQUE INDEX IF NOT EXISTS uk_t_referral_ck_referred ON t_referral(ck_referred);
+
+-- С одного реферала начисляется много вознаграждений
+DROP INDEX IF EXISTS uk_t_referral_earning_ck_referral;
+
+ALTER TABLE t_referral_earning
+    ADD COLUMN cr_type VARCHAR(20) NOT NULL DEFAULT 'SIGNUP' CHECK (cr_type IN ('SIGNUP', 'STAKE', 'WINNINGS')),
+    ADD COLUMN ck_source UUID NULL,
+    ADD COLUMN cn_base DECIMAL NULL,
+    ADD COLUMN ck_transaction UUID NULL,
+    ADD CONSTRAINT fk_t_referral_earning_ck_transaction FOREIGN KEY (ck_transaction) REFERENCES t_user_transaction(ck_id);
+
+ALTER TABLE t_referral_earning ALTER COLUMN cr_type DROP DEFAULT;
+
+COMMENT ON COLUMN t_referral_earning.cr_type IS 'Правило начисления: SIGNUP - за регистрацию, STAKE - от ставок, WINNINGS - от выигрышей приглашенного';
+COMMENT ON COLUMN t_referral_earning.ck_source IS 'Идентификатор операции, за которую начислено вознаграждение (транзакция приглашенного; для SIGNUP - реферал)';
+COMMENT ON COLUMN t_referral_earning.cn_base IS 'Сумма операции, от которой считался процент';
+COMMENT ON COLUMN t_referral_earning.ck_transaction IS 'Идентификатор транзакции зачисления в кошелек пригласившего';
+
+CREATE INDEX idx_t_referral_earning_ck_referral ON t_referral_earning(ck_referral);
+-- За одну операцию вознаграждение начисляется один раз
+CREATE UNIQUE INDEX uk_t_referral_earning_cr_type_and_ck_source ON t_referral_earning(cr_type, ck_source);
+
+--changeset artemov_i:init_parier_referral_review dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- ВЫПЛАТА И ПРОВЕРКА РЕФЕРАЛЬНЫХ ВОЗНАГРАЖДЕНИЙ
+-- =====================================================
+
+-- Уже начисленные вознаграждения выплачены сразу
+ALTER TABLE t_referral_earning
+    ADD COLUMN cr_status VARCHAR(20) NOT NULL DEFAULT 'PAID' CHECK (cr_status IN ('PENDING', 'PAID', 'FROZEN')),
+    ADD COLUMN cn_level SMALLINT NOT NULL DEFAULT 1 CHECK (cn_level IN (1, 2)),
+    ADD COLUMN ct_paid TIMESTAMP NULL,
+    ADD COLUMN cv_freeze_reason VARCHAR(1000) NULL;
+
+ALTER TABLE t_referral_earning ALTER COLUMN cr_status DROP DEFAULT;
+
+UPDATE t_referral_earning SET ct_paid = ct_create WHERE ck_transaction IS NOT NULL;
+
+COMMENT ON COLUMN t_referral_earning.cr_status IS 'Статус: PENDING - ожидает выплаты, PAID - выплачено, FROZEN - заморожено администратором';
+COMMENT ON COLUMN t_referral_earning.cn_level IS 'Уровень: 1 - от приглашенного, 2 - от приглашенного приглашенным';
+COMMENT ON COLUMN t_referral_earning.ct_paid IS 'Дата выплаты';
+COMMENT ON COLUMN t_referral_earning.cv_freeze_reason IS 'Причина заморозки';
+
+-- За одну операцию вознаграждение каждого уровня начисляется один раз
+DROP INDEX IF EXISTS uk_t_referral_earning_cr_type_and_ck_source;
+CREATE UNIQUE INDEX uk_t_referral_earning_cr_type_and_ck_source_and_cn_level ON t_referral_earning(cr_type, ck_source, cn_level);
+CREATE INDEX idx_t_referral_earning_cr_status_and_ct_create ON t_referral_earning(cr_status, ct_create);
+
+--changeset artemov_i:init_parier_comment_tree dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- ДЕРЕВО КОММЕНТАРИЕВ
+-- =====================================================
+
+-- Комментарии верхнего уровня ставки и ответы на комментарий выбираются по времени создания
+CREATE INDEX idx_t_bet_comment_ck_bet_and_ct_create ON t_bet_comment(ck_bet, ct_create, ck_id) WHERE ck_parent IS NULL AND ct_delete IS NULL;
+CREATE INDEX idx_t_bet_comment_ck_parent_and_ct_create ON t_bet_comment(ck_parent, ct_create, ck_id) WHERE ct_delete IS NULL;
+CREATE INDEX idx_t_bet_comment_like_ck_comment ON t_bet_comment_like(ck_comment) WHERE ct_delete IS NULL;
+
+--changeset artemov_i:init_parier_comment_revision dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- РЕДАКТИРОВАНИЕ И УДАЛЕНИЕ КОММЕНТАРИЕВ
+-- =====================================================
+
+ALTER TABLE t_bet_comment
+    ADD COLUMN ct_edit TIMESTAMP NULL,
+    ADD COLUMN cr_remove VARCHAR(20) NULL CHECK (cr_remove IN ('AUTHOR', 'MODERATOR'));
+
+COMMENT ON COLUMN t_bet_comment.ct_edit IS 'Дата последнего изменения текста';
+COMMENT ON COLUMN t_bet_comment.cr_remove IS 'Кто удалил комментарий: AUTHOR - автор, MODERATOR - модератор';
+
+--Таблица: t_bet_comment_revision - Предыдущие версии комментариев
+CREATE TABLE IF NOT EXISTS t_bet_comment_revision (
+    ck_id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ck_comment uuid NOT NULL,
+    cv_content text NOT NULL,
+    cl_moderator BOOLEAN NOT NULL DEFAULT false,
+    ck_create VARCHAR(255) NOT NULL,
+    ct_create TIMESTAMP NOT NULL DEFAULT now(),
+    ck_modify VARCHAR(255) NOT NULL,
+    ct_modify TIMESTAMP NOT NULL DEFAULT now(),
+    ct_delete TIMESTAMP NULL,
+    CONSTRAINT fk_t_bet_comment_revision_ck_comment FOREIGN KEY (ck_comment) REFERENCES t_bet_comment(ck_id)
+);
+
+COMMENT ON TABLE t_bet_comment_revision IS 'Предыдущие версии комментариев';
+COMMENT ON COLUMN t_bet_comment_revision.ck_id IS 'Идентификатор';
+COMMENT ON COLUMN t_bet_comment_revision.ck_comment IS 'Идентификатор комментария';
+COMMENT ON COLUMN t_bet_comment_revision.cv_content IS 'Текст комментария до изменения';
+COMMENT ON COLUMN t_bet_comment_revision.cl_moderator IS 'Изменение выполнил модератор';
+COMMENT ON COLUMN t_bet_comment_revision.ck_create IS 'Идентификатор изменившего';
+COMMENT ON COLUMN t_bet_comment_revision.ct_create IS 'Дата изменения';
+COMMENT ON COLUMN t_bet_comment_revision.ck_modify IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_bet_comment_revision.ct_modify IS 'Дата модификации';
+COMMENT ON COLUMN t_bet_comment_revision.ct_delete IS 'Дата логического удаления';
+
+CREATE INDEX idx_t_bet_comment_revision_ck_comment ON t_bet_comment_revision(ck_comment, ct_create);
+
+-- Удалённый комментарий с ответами остаётся в дереве как "комментарий удалён"
+DROP INDEX IF EXISTS idx_t_bet_comment_ck_parent_and_ct_create;
+CREATE INDEX idx_t_bet_comment_ck_parent_and_ct_create ON t_bet_comment(ck_parent, ct_create, ck_id);
+
+--changeset artemov_i:init_parier_comment_mention dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- УПОМИНАНИЯ ПОЛЬЗОВАТЕЛЕЙ В КОММЕНТАРИЯХ
+-- =====================================================
+
+--Таблица: t_bet_comment_mention - Упоминания пользователей в комментариях
+CREATE TABLE IF NOT EXISTS t_bet_comment_mention (
+    ck_id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ck_comment uuid NOT NULL,
+    ck_user uuid NOT NULL,
+    cv_username VARCHAR(255) NOT NULL,
+    cn_offset INTEGER NOT NULL,
+    cn_length INTEGER NOT NULL,
+    ct_read TIMESTAMP NULL,
+    ck_create VARCHAR(255) NOT NULL,
+    ct_create TIMESTAMP NOT NULL DEFAULT now(),
+    ck_modify VARCHAR(255) NOT NULL,
+    ct_modify TIMESTAMP NOT NULL DEFAULT now(),
+    ct_delete TIMESTAMP NULL,
+    CONSTRAINT fk_t_bet_comment_mention_ck_comment FOREIGN KEY (ck_comment) REFERENCES t_bet_comment(ck_id),
+    CONSTRAINT fk_t_bet_comment_mention_ck_user FOREIGN KEY (ck_user) REFERENCES t_user(ck_id)
+);
+
+COMMENT ON TABLE t_bet_comment_mention IS 'Упоминания пользователей в комментариях';
+COMMENT ON COLUMN t_bet_comment_mention.ck_id IS 'Идентификатор';
+COMMENT ON COLUMN t_bet_comment_mention.ck_comment IS 'Идентификатор комментария';
+COMMENT ON COLUMN t_bet_comment_mention.ck_user IS 'Идентификатор упомянутого пользователя';
+COMMENT ON COLUMN t_bet_comment_mention.cv_username IS 'Имя пользователя, как оно написано в тексте';
+COMMENT ON COLUMN t_bet_comment_mention.cn_offset IS 'Позиция упоминания в тексте, в символах';
+COMMENT ON COLUMN t_bet_comment_mention.cn_length IS 'Длина упоминания вместе с @, в символах';
+COMMENT ON COLUMN t_bet_comment_mention.ct_read IS 'Дата прочтения упомянутым пользователем';
+COMMENT ON COLUMN t_bet_comment_mention.ck_create IS 'Идентификатор создателя';
+COMMENT ON COLUMN t_bet_comment_mention.ct_create IS 'Дата создания';
+COMMENT ON COLUMN t_bet_comment_mention.ck_modify IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_bet_comment_mention.ct_modify IS 'Дата модификации';
+COMMENT ON COLUMN t_bet_comment_mention.ct_delete IS 'Дата логического удаления';
+
+CREATE INDEX idx_t_bet_comment_mention_ck_comment ON t_bet_comment_mention(ck_comment);
+CREATE INDEX idx_t_bet_comment_mention_ck_user_unread ON t_bet_comment_mention(ck_user, ct_create) WHERE ct_read IS NULL AND ct_delete IS NULL;
+-- Упоминания разрешаются по имени пользователя без учёта регистра
+CREATE INDEX idx_t_user_properties_username ON t_user_properties(lower(cv_text)) WHERE ck_type = 'USER_USERNAME';
+
+--changeset artemov_i:init_parier_notification dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- УВЕДОМЛЕНИЯ ПОЛЬЗОВАТЕЛЕЙ
+-- =====================================================
+
+-- Таблица: t_d_notification_type - Типы уведомлений
+CREATE TABLE IF NOT EXISTS t_d_notification_type (
+    ck_id VARCHAR(100) PRIMARY KEY,
+    ck_name VARCHAR(255) NOT NULL,
+    ck_description VARCHAR(255) NOT NULL,
+    ck_create VARCHAR(255) NOT NULL,
+    ct_create TIMESTAMP NOT NULL DEFAULT now(),
+    ck_modify VARCHAR(255) NOT NULL,
+    ct_modify TIMESTAMP NOT NULL DEFAULT now(),
+    ct_delete TIMESTAMP NULL,
+    CONSTRAINT fk_t_d_notification_type_ck_name FOREIGN KEY (ck_name) REFERENCES t_localization(ck_id),
+    CONSTRAINT fk_t_d_notification_type_ck_description FOREIGN KEY (ck_description) REFERENCES t_localization(ck_id)
+);
+
+COMMENT ON TABLE t_d_notification_type IS 'Типы уведомлений';
+COMMENT ON COLUMN t_d_notification_type.ck_id IS 'Идентификатор';
+COMMENT ON COLUMN t_d_notification_type.ck_name IS 'Шаблон заголовка уведомления';
+COMMENT ON COLUMN t_d_notification_type.ck_description IS 'Шаблон текста уведомления, {ключ} заменяется значением из cj_payload';
+COMMENT ON COLUMN t_d_notification_type.ck_create IS 'Идентификатор создателя';
+COMMENT ON COLUMN t_d_notification_type.ct_create IS 'Дата создания';
+COMMENT ON COLUMN t_d_notification_type.ck_modify IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_d_notification_type.ct_modify IS 'Дата модификации';
+COMMENT ON COLUMN t_d_notification_type.ct_delete IS 'Дата логического удаления';
+
+-- Таблица: t_notification - Уведомления пользователей
+CREATE TABLE IF NOT EXISTS t_notification (
+    ck_id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ck_user uuid NOT NULL,
+    ck_type VARCHAR(100) NOT NULL,
+    cj_payload JSON NOT NULL,
+    ct_read TIMESTAMP NULL,
+    ct_deliver TIMESTAMP NULL,
+    cv_deliver_error TEXT NULL,
+    ck_create VARCHAR(255) NOT NULL,
+    ct_create TIMESTAMP NOT NULL DEFAULT now(),
+    ck_modify VARCHAR(255) NOT NULL,
+    ct_modify TIMESTAMP NOT NULL DEFAULT now(),
+    ct_delete TIMESTAMP NULL,
+    CONSTRAINT fk_t_notification_ck_user FOREIGN KEY (ck_user) REFERENCES t_user(ck_id),
+    CONSTRAINT fk_t_notification_ck_type FOREIGN KEY (ck_type) REFERENCES t_d_notification_type(ck_id)
+);
+
+COMMENT ON TABLE t_notification IS 'Уведомления пользователей';
+COMMENT ON COLUMN t_notification.ck_id IS 'Идентификатор';
+COMMENT ON COLUMN t_notification.ck_user IS 'Идентификатор получателя';
+COMMENT ON COLUMN t_notification.ck_type IS 'Тип уведомления';
+COMMENT ON COLUMN t_notification.cj_payload IS 'Данные события для шаблона и ссылок';
+COMMENT ON COLUMN t_notification.ct_read IS 'Дата прочтения';
+COMMENT ON COLUMN t_notification.ct_deliver IS 'Дата отправки по внешним каналам (почта, webhook)';
+COMMENT ON COLUMN t_notification.cv_deliver_error IS 'Ошибки отправки по внешним каналам';
+COMMENT ON COLUMN t_notification.ck_create IS 'Идентификатор создателя';
+COMMENT ON COLUMN t_notification.ct_create IS 'Дата создания';
+COMMENT ON COLUMN t_notification.ck_modify IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_notification.ct_modify IS 'Дата модификации';
+COMMENT ON COLUMN t_notification.ct_delete IS 'Дата логического удаления';
+
+CREATE INDEX idx_t_notification_ck_user ON t_notification(ck_user, ct_create) WHERE ct_delete IS NULL;
+CREATE INDEX idx_t_notification_ck_user_unread ON t_notification(ck_user) WHERE ct_read IS NULL AND ct_delete IS NULL;
+CREATE INDEX idx_t_notification_undelivered ON t_notification(ct_create) WHERE ct_deliver IS NULL;
+
+--changeset artemov_i:init_parier_chat dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- ЧАТЫ СТАВОК И ЛИЧНЫЕ СООБЩЕНИЯ
+-- =====================================================
+
+ALTER TABLE t_chat
+    ADD COLUMN ck_bet uuid NULL,
+    ADD COLUMN cl_direct BOOLEAN NOT NULL DEFAULT false,
+    ADD CONSTRAINT fk_t_chat_ck_bet FOREIGN KEY (ck_bet) REFERENCES t_bet(ck_id);
+
+COMMENT ON COLUMN t_chat.ck_bet IS 'Идентификатор ставки, чьей комнатой является чат';
+COMMENT ON COLUMN t_chat.cl_direct IS 'Личный чат двух пользователей';
+
+-- У ставки одна комната
+CREATE UNIQUE INDEX uk_t_chat_ck_bet ON t_chat(ck_bet) WHERE ct_delete IS NULL;
+CREATE INDEX idx_t_chat_user_ck_user ON t_chat_user(ck_user) WHERE ct_delete IS NULL;
+-- История сообщений читается от новых к старым по курсору
+CREATE INDEX idx_t_chat_message_ck_chat_and_ct_create ON t_chat_message(ck_chat, ct_create, ck_id) WHERE ct_delete IS NULL;
+CREATE INDEX idx_t_chat_message_like_ck_message ON t_chat_message_like(ck_message) WHERE ct_delete IS NULL;
+
+--changeset artemov_i:init_parier_chat_moderation dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- МОДЕРАЦИЯ ЧАТОВ: БАНЫ И ПРИГЛАШЕНИЯ
+-- =====================================================
+
+COMMENT ON COLUMN t_chat_credentials.cv_credentials IS 'SHA-256 токена (TOKEN, API_KEY) или bcrypt пароля (PASSWORD)';
+COMMENT ON COLUMN t_chat_credentials.ct_delete IS 'Дата отзыва или использования одноразового приглашения';
+
+-- Приглашения проверяются среди неотозванных приглашений чата
+CREATE INDEX idx_t_chat_credentials_ck_chat ON t_chat_credentials(ck_chat) WHERE ct_delete IS NULL;
+
+--changeset artemov_i:init_user_reputation dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- РЕПУТАЦИЯ ПОЛЬЗОВАТЕЛЕЙ
+-- =====================================================
+
+-- Оценки ставятся по шкале от 1 до 5; NOT VALID не проверяет уже сохранённые оценки
+ALTER TABLE t_user_rating ADD CONSTRAINT cc_t_user_rating_cn_rating CHECK (cn_rating BETWEEN 1 AND 5) NOT VALID;
+ALTER TABLE t_bet_rating ADD CONSTRAINT cc_t_bet_rating_cn_rating CHECK (cn_rating BETWEEN 1 AND 5) NOT VALID;
+
+--Таблица: t_user_reputation - Репутация пользователей
+CREATE TABLE IF NOT EXISTS t_user_reputation (
+    ck_user UUID PRIMARY KEY,
+    cn_rating_sum BIGINT NOT NULL DEFAULT 0,
+    cn_rating_count INT NOT NULL DEFAULT 0,
+    cn_bets INT NOT NULL DEFAULT 0,
+    cn_wins INT NOT NULL DEFAULT 0,
+    cn_score NUMERIC(5,2) NOT NULL DEFAULT 0,
+    ck_create VARCHAR(255) NOT NULL,
+    ct_create TIMESTAMP NOT NULL DEFAULT now(),
+    ck_modify VARCHAR(255) NOT NULL,
+    ct_modify TIMESTAMP NOT NULL DEFAULT now(),
+    ct_delete TIMESTAMP NULL,
+    CONSTRAINT fk_t_user_reputation_ck_user FOREIGN KEY (ck_user) REFERENCES t_user(ck_id)
+);
+COMMENT ON TABLE t_user_reputation IS 'Репутация пользователей: счётчики обновляются вместе с оценками и результатами ставок';
+COMMENT ON COLUMN t_user_reputation.ck_user IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_user_reputation.cn_rating_sum IS 'Сумма полученных оценок пользователя и его ставок';
+COMMENT ON COLUMN t_user_reputation.cn_rating_count IS 'Количество полученных оценок пользователя и его ставок';
+COMMENT ON COLUMN t_user_reputation.cn_bets IS 'Количество рассчитанных ставок';
+COMMENT ON COLUMN t_user_reputation.cn_wins IS 'Количество выигранных ставок';
+COMMENT ON COLUMN t_user_reputation.cn_score IS 'Репутация от 0 до 100';
+COMMENT ON COLUMN t_user_reputation.ck_create IS 'Идентификатор создателя';
+COMMENT ON COLUMN t_user_reputation.ct_create IS 'Дата создания';
+COMMENT ON COLUMN t_user_reputation.ck_modify IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_user_reputation.ct_modify IS 'Дата модификации';
+COMMENT ON COLUMN t_user_reputation.ct_delete IS 'Дата логического удаления';
+
+-- Счётчики по уже сохранённым оценкам и истории ставок; формула репутации та же,
+-- что в reputationScore (internal/service/rating.go)
+INSERT INTO t_user_reputation (ck_user, cn_rating_sum, cn_rating_count, cn_bets, cn_wins, cn_score, ck_create, ck_modify)
+SELECT u.ck_id, r.cn_sum, r.cn_count, h.cn_bets, h.cn_wins,
+       round((50 * ((r.cn_sum + 15.0) / (r.cn_count + 5) - 1) / 4
+            + 20 * r.cn_count / (r.cn_count + 20.0)
+            + 30 * CASE WHEN h.cn_bets = 0 THEN 0 ELSE floor(h.cn_wins * 100.0 / h.cn_bets) / 100 END)::numeric, 2),
+       'system', 'system'
+  FROM t_user u
+ CROSS JOIN LATERAL (
+       SELECT coalesce(sum(cn_rating), 0) AS cn_sum, count(*) AS cn_count
+         FROM (SELECT cn_rating FROM t_user_rating WHERE ck_user = u.ck_id AND ct_delete IS NULL
+               UNION ALL
+               SELECT br.cn_rating FROM t_bet_rating br
+                 JOIN t_bet b ON b.ck_id = br.ck_bet
+                WHERE b.ck_author = u.ck_id AND br.ct_delete IS NULL) received
+       ) r
+ CROSS JOIN LATERAL (
+       SELECT count(*) AS cn_bets, count(*) FILTER (WHERE cl_win) AS cn_wins
+         FROM t_user_bet_history WHERE ck_user = u.ck_id AND ct_delete IS NULL
+       ) h;
+
+--changeset artemov_i:init_parier_referral_freeze dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- ЗАМОРОЗКА ВОЗНАГРАЖДЕНИЙ ПРИГЛАСИВШЕГО
+-- =====================================================
+
+-- Таблица: t_referral_freeze - Заморозка вознаграждений пригласившего
+CREATE TABLE IF NOT EXISTS t_referral_freeze (
+    ck_id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ck_user uuid NOT NULL,
+    cv_reason VARCHAR(1000) NOT NULL,
+    ck_create VARCHAR(255) NOT NULL,
+    ct_create TIMESTAMP NOT NULL DEFAULT now(),
+    ck_modify VARCHAR(255) NOT NULL,
+    ct_modify TIMESTAMP NOT NULL DEFAULT now(),
+    ct_delete TIMESTAMP NULL,
+    CONSTRAINT fk_t_referral_freeze_ck_user FOREIGN KEY (ck_user) REFERENCES t_user(ck_id)
+);
+
+COMMENT ON TABLE t_referral_freeze IS 'Заморозка вознаграждений пригласившего: пока она действует, новые вознаграждения начисляются замороженными и не выплачиваются';
+COMMENT ON COLUMN t_referral_freeze.ck_id IS 'Идентификатор';
+COMMENT ON COLUMN t_referral_freeze.ck_user IS 'Идентификатор пригласившего';
+COMMENT ON COLUMN t_referral_freeze.cv_reason IS 'Причина заморозки';
+COMMENT ON COLUMN t_referral_freeze.ck_create IS 'Идентификатор создателя';
+COMMENT ON COLUMN t_referral_freeze.ct_create IS 'Дата создания';
+COMMENT ON COLUMN t_referral_freeze.ck_modify IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_referral_freeze.ct_modify IS 'Дата модификации';
+COMMENT ON COLUMN t_referral_freeze.ct_delete IS 'Дата снятия заморозки';
+
+-- У пригласившего действует не больше одной заморозки
+CREATE UNIQUE INDEX uk_t_referral_freeze_ck_user ON t_referral_freeze(ck_user) WHERE ct_delete IS NULL;
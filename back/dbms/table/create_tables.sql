@@ -764,3 +764,243 @@ COMMENT ON COLUMN t_user_like.ct_delete IS 'Дата логического уд
 
 CREATE UNIQUE INDEX uk_t_user_like_ck_user_and_ck_author ON t_user_like(ck_user, ck_author);
 
+
+--changeset artemov_i:init_localization_machine_translation dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- МАШИННЫЙ ПЕРЕВОД
+-- =====================================================
+
+ALTER TABLE t_localization_word ADD COLUMN cl_machine BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE t_localization_word ADD COLUMN ck_review VARCHAR(255) NULL;
+ALTER TABLE t_localization_word ADD COLUMN ct_review TIMESTAMP NULL;
+
+COMMENT ON COLUMN t_localization_word.cl_machine IS 'Признак машинного перевода';
+COMMENT ON COLUMN t_localization_word.ck_review IS 'Идентификатор проверившего перевод';
+COMMENT ON COLUMN t_localization_word.ct_review IS 'Дата проверки перевода';
+
+CREATE INDEX idx_t_localization_word_review ON t_localization_word(ck_lang) WHERE cl_machine AND ct_review IS NULL AND ct_delete IS NULL;
+
+--changeset artemov_i:init_session_activity dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- АКТИВНОСТЬ СЕССИЙ
+-- =====================================================
+
+ALTER TABLE t_session ADD COLUMN ct_activity TIMESTAMP NULL;
+
+COMMENT ON COLUMN t_session.ct_activity IS 'Дата последней активности';
+
+CREATE INDEX idx_t_session_ck_user_active ON t_session(ck_user) WHERE ct_delete IS NULL;
+
+--changeset artemov_i:init_user_first_login dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- ПЕРВЫЙ ВХОД ПОЛЬЗОВАТЕЛЯ
+-- =====================================================
+
+ALTER TABLE t_user ADD COLUMN ct_first_login TIMESTAMP NULL;
+ALTER TABLE t_user ADD COLUMN ck_first_iss VARCHAR(255) NULL;
+ALTER TABLE t_user ADD COLUMN ck_first_client VARCHAR(255) NULL;
+
+COMMENT ON COLUMN t_user.ct_first_login IS 'Дата первого входа';
+COMMENT ON COLUMN t_user.ck_first_iss IS 'Issuer Keycloak при первом входе';
+COMMENT ON COLUMN t_user.ck_first_client IS 'Клиент Keycloak при первом входе';
+
+--changeset artemov_i:init_anonymous_activity dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- АКТИВНОСТЬ АНОНИМНЫХ ПОСЕТИТЕЛЕЙ
+-- =====================================================
+
+CREATE TABLE t_anonymous_state (
+    ck_id UUID PRIMARY KEY,
+    cv_data TEXT NOT NULL,
+    ct_expire TIMESTAMP NOT NULL,
+    ck_create VARCHAR(255) NOT NULL,
+    ct_create TIMESTAMP NOT NULL DEFAULT now(),
+    ck_modify VARCHAR(255) NOT NULL,
+    ct_modify TIMESTAMP NOT NULL DEFAULT now(),
+    ct_delete TIMESTAMP NULL
+);
+
+COMMENT ON TABLE t_anonymous_state IS 'Активность анонимных посетителей до входа';
+COMMENT ON COLUMN t_anonymous_state.ck_id IS 'Идентификатор анонимного посетителя';
+COMMENT ON COLUMN t_anonymous_state.cv_data IS 'Просмотры, черновики, реферальный код и язык в JSON';
+COMMENT ON COLUMN t_anonymous_state.ct_expire IS 'Дата истечения';
+COMMENT ON COLUMN t_anonymous_state.ck_create IS 'Идентификатор создателя';
+COMMENT ON COLUMN t_anonymous_state.ct_create IS 'Дата создания';
+COMMENT ON COLUMN t_anonymous_state.ck_modify IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_anonymous_state.ct_modify IS 'Дата модификации';
+COMMENT ON COLUMN t_anonymous_state.ct_delete IS 'Дата логического удаления';
+
+CREATE TABLE t_user_bet_view (
+    ck_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ck_user UUID NOT NULL,
+    ck_bet UUID NOT NULL,
+    ct_view TIMESTAMP NOT NULL,
+    ck_create VARCHAR(255) NOT NULL,
+    ct_create TIMESTAMP NOT NULL DEFAULT now(),
+    ck_modify VARCHAR(255) NOT NULL,
+    ct_modify TIMESTAMP NOT NULL DEFAULT now(),
+    ct_delete TIMESTAMP NULL,
+    CONSTRAINT fk_t_user_bet_view_ck_user FOREIGN KEY (ck_user) REFERENCES t_user(ck_id),
+    CONSTRAINT fk_t_user_bet_view_ck_bet FOREIGN KEY (ck_bet) REFERENCES t_bet(ck_id)
+);
+
+COMMENT ON TABLE t_user_bet_view IS 'Просмотренные пользователем ставки';
+COMMENT ON COLUMN t_user_bet_view.ck_id IS 'Идентификатор';
+COMMENT ON COLUMN t_user_bet_view.ck_user IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_user_bet_view.ck_bet IS 'Идентификатор ставки';
+COMMENT ON COLUMN t_user_bet_view.ct_view IS 'Дата последнего просмотра';
+COMMENT ON COLUMN t_user_bet_view.ck_create IS 'Идентификатор создателя';
+COMMENT ON COLUMN t_user_bet_view.ct_create IS 'Дата создания';
+COMMENT ON COLUMN t_user_bet_view.ck_modify IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_user_bet_view.ct_modify IS 'Дата модификации';
+COMMENT ON COLUMN t_user_bet_view.ct_delete IS 'Дата логического удаления';
+
+CREATE UNIQUE INDEX uk_t_user_bet_view_ck_user_and_ck_bet ON t_user_bet_view(ck_user, ck_bet);
+
+CREATE TABLE t_user_bet_draft (
+    ck_id UUID PRIMARY KEY,
+    ck_user UUID NOT NULL,
+    cv_data TEXT NOT NULL,
+    ct_draft TIMESTAMP NOT NULL,
+    ck_create VARCHAR(255) NOT NULL,
+    ct_create TIMESTAMP NOT NULL DEFAULT now(),
+    ck_modify VARCHAR(255) NOT NULL,
+    ct_modify TIMESTAMP NOT NULL DEFAULT now(),
+    ct_delete TIMESTAMP NULL,
+    CONSTRAINT fk_t_user_bet_draft_ck_user FOREIGN KEY (ck_user) REFERENCES t_user(ck_id)
+);
+
+COMMENT ON TABLE t_user_bet_draft IS 'Черновики ставок пользователей';
+COMMENT ON COLUMN t_user_bet_draft.ck_id IS 'Идентификатор черновика';
+COMMENT ON COLUMN t_user_bet_draft.ck_user IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_user_bet_draft.cv_data IS 'Состояние формы создания ставки в JSON';
+COMMENT ON COLUMN t_user_bet_draft.ct_draft IS 'Дата последнего изменения черновика';
+COMMENT ON COLUMN t_user_bet_draft.ck_create IS 'Идентификатор создателя';
+COMMENT ON COLUMN t_user_bet_draft.ct_create IS 'Дата создания';
+COMMENT ON COLUMN t_user_bet_draft.ck_modify IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_user_bet_draft.ct_modify IS 'Дата модификации';
+COMMENT ON COLUMN t_user_bet_draft.ct_delete IS 'Дата логического удаления';
+
+CREATE INDEX idx_t_user_bet_draft_ck_user ON t_user_bet_draft(ck_user) WHERE ct_delete IS NULL;
+
+--changeset artemov_i:init_api_token dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- API ТОКЕНЫ И СЕРВИСНЫЕ АККАУНТЫ
+-- =====================================================
+
+ALTER TABLE t_user ADD COLUMN cl_service BOOLEAN NOT NULL DEFAULT false;
+
+COMMENT ON COLUMN t_user.cl_service IS 'Признак сервисного аккаунта';
+
+CREATE TABLE t_api_token (
+    ck_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ck_user UUID NOT NULL,
+    cv_name VARCHAR(255) NOT NULL,
+    cv_prefix VARCHAR(16) NOT NULL,
+    cv_hash VARCHAR(64) NOT NULL,
+    ct_expire TIMESTAMP NULL,
+    ct_last_used TIMESTAMP NULL,
+    ck_create VARCHAR(255) NOT NULL,
+    ct_create TIMESTAMP NOT NULL DEFAULT now(),
+    ck_modify VARCHAR(255) NOT NULL,
+    ct_modify TIMESTAMP NOT NULL DEFAULT now(),
+    ct_delete TIMESTAMP NULL,
+    CONSTRAINT fk_t_api_token_ck_user FOREIGN KEY (ck_user) REFERENCES t_user(ck_id)
+);
+
+COMMENT ON TABLE t_api_token IS 'API токены пользователей и сервисных аккаунтов';
+COMMENT ON COLUMN t_api_token.ck_id IS 'Идентификатор';
+COMMENT ON COLUMN t_api_token.ck_user IS 'Идентификатор владельца';
+COMMENT ON COLUMN t_api_token.cv_name IS 'Название токена';
+COMMENT ON COLUMN t_api_token.cv_prefix IS 'Начало токена для отображения';
+COMMENT ON COLUMN t_api_token.cv_hash IS 'SHA-256 токена';
+COMMENT ON COLUMN t_api_token.ct_expire IS 'Дата истечения';
+COMMENT ON COLUMN t_api_token.ct_last_used IS 'Дата последнего использования';
+COMMENT ON COLUMN t_api_token.ck_create IS 'Идентификатор создателя';
+COMMENT ON COLUMN t_api_token.ct_create IS 'Дата создания';
+COMMENT ON COLUMN t_api_token.ck_modify IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_api_token.ct_modify IS 'Дата модификации';
+COMMENT ON COLUMN t_api_token.ct_delete IS 'Дата отзыва';
+
+CREATE UNIQUE INDEX uk_t_api_token_cv_hash ON t_api_token(cv_hash);
+CREATE INDEX idx_t_api_token_ck_user ON t_api_token(ck_user) WHERE ct_delete IS NULL;
+
+CREATE TABLE t_api_token_scope (
+    ck_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ck_token UUID NOT NULL,
+    cr_scope VARCHAR(10) NOT NULL CHECK (cr_scope IN ('TABLE', 'PROPERTY')),
+    ck_object VARCHAR(255) NOT NULL,
+    cr_action VARCHAR(10) NOT NULL CHECK (cr_action IN ('INSERT', 'UPDATE', 'DELETE', 'ALL', 'VIEW')),
+    ck_create VARCHAR(255) NOT NULL,
+    ct_create TIMESTAMP NOT NULL DEFAULT now(),
+    ck_modify VARCHAR(255) NOT NULL,
+    ct_modify TIMESTAMP NOT NULL DEFAULT now(),
+    ct_delete TIMESTAMP NULL,
+    CONSTRAINT fk_t_api_token_scope_ck_token FOREIGN KEY (ck_token) REFERENCES t_api_token(ck_id)
+);
+
+COMMENT ON TABLE t_api_token_scope IS 'Области действия API токенов';
+COMMENT ON COLUMN t_api_token_scope.ck_id IS 'Идентификатор';
+COMMENT ON COLUMN t_api_token_scope.ck_token IS 'Идентификатор токена';
+COMMENT ON COLUMN t_api_token_scope.cr_scope IS 'Тип объекта: TABLE (как t_d_table_role) или PROPERTY (как t_properties_role)';
+COMMENT ON COLUMN t_api_token_scope.ck_object IS 'Таблица или тип свойства';
+COMMENT ON COLUMN t_api_token_scope.cr_action IS 'Тип доступа';
+COMMENT ON COLUMN t_api_token_scope.ck_create IS 'Идентификатор создателя';
+COMMENT ON COLUMN t_api_token_scope.ct_create IS 'Дата создания';
+COMMENT ON COLUMN t_api_token_scope.ck_modify IS 'Идентификатор пользователя';
+COMMENT ON COLUMN t_api_token_scope.ct_modify IS 'Дата модификации';
+COMMENT ON COLUMN t_api_token_scope.ct_delete IS 'Дата логического удаления';
+
+--changeset artemov_i:init_audit_log dbms:postgresql splitStatements:false stripComments:false
+-- =====================================================
+-- ЖУРНАЛ АУДИТА
+-- =====================================================
+
+CREATE TABLE t_audit_log (
+    ck_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    cn_seq BIGINT NOT NULL,
+    ck_actor VARCHAR(255) NOT NULL,
+    ck_session UUID NULL,
+    cv_ip VARCHAR(64) NULL,
+    cv_action VARCHAR(100) NOT NULL,
+    cv_entity_type VARCHAR(100) NOT NULL,
+    ck_entity VARCHAR(255) NOT NULL,
+    cj_before JSON NULL,
+    cj_after JSON NULL,
+    cv_prev_hash VARCHAR(64) NOT NULL,
+    cv_hash VARCHAR(64) NOT NULL,
+    ct_create TIMESTAMP NOT NULL
+);
+
+COMMENT ON TABLE t_audit_log IS 'Журнал аудита изменений; записи только добавляются и связаны цепочкой хешей';
+COMMENT ON COLUMN t_audit_log.ck_id IS 'Идентификатор';
+COMMENT ON COLUMN t_audit_log.cn_seq IS 'Порядковый номер записи в цепочке';
+COMMENT ON COLUMN t_audit_log.ck_actor IS 'Идентификатор пользователя, выполнившего действие';
+COMMENT ON COLUMN t_audit_log.ck_session IS 'Идентификатор сессии';
+COMMENT ON COLUMN t_audit_log.cv_ip IS 'IP адрес клиента';
+COMMENT ON COLUMN t_audit_log.cv_action IS 'Действие, например wallet.deposit';
+COMMENT ON COLUMN t_audit_log.cv_entity_type IS 'Тип изменённого объекта';
+COMMENT ON COLUMN t_audit_log.ck_entity IS 'Идентификатор изменённого объекта';
+COMMENT ON COLUMN t_audit_log.cj_before IS 'Изменённые поля до операции (JSON хранится как есть для проверки хеша)';
+COMMENT ON COLUMN t_audit_log.cj_after IS 'Изменённые поля после операции';
+COMMENT ON COLUMN t_audit_log.cv_prev_hash IS 'Хеш предыдущей записи';
+COMMENT ON COLUMN t_audit_log.cv_hash IS 'SHA-256 записи вместе с хешем предыдущей';
+COMMENT ON COLUMN t_audit_log.ct_create IS 'Дата записи';
+
+CREATE UNIQUE INDEX uk_t_audit_log_cn_seq ON t_audit_log(cn_seq);
+CREATE INDEX idx_t_audit_log_entity ON t_audit_log(cv_entity_type, ck_entity);
+CREATE INDEX idx_t_audit_log_ck_actor ON t_audit_log(ck_actor);
+CREATE INDEX idx_t_audit_log_ct_create ON t_audit_log(ct_create);
+
+-- Журнал только дополняется: изменение и удаление записей запрещены
+CREATE OR REPLACE FUNCTION t_audit_log_append_only()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 't_audit_log is append-only';
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER t_audit_log_no_update BEFORE UPDATE OR DELETE ON t_audit_log
+    FOR EACH ROW EXECUTE FUNCTION t_audit_log_append_only();
+CREATE TRIGGER t_audit_log_no_truncate BEFORE TRUNCATE ON t_audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION t_audit_log_append_only();
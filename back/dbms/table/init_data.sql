@@ -85,6 +85,7 @@ INSERT INTO t_localization (ck_id, cr_type, ck_create, ck_modify) VALUES
     ('transaction-status.pending', 'STATIC', 'system', 'system'),
     ('transaction-status.confirmed', 'STATIC', 'system', 'system'),
     ('transaction-status.rejected', 'STATIC', 'system', 'system'),
+    ('transaction-status.completed', 'STATIC', 'system', 'system'),
     ('transaction-type.deposit', 'STATIC', 'system', 'system'),
     ('transaction-type.withdrawal', 'STATIC', 'system', 'system'),
     ('transaction-type.bet', 'STATIC', 'system', 'system'),
@@ -94,6 +95,7 @@ INSERT INTO t_localization (ck_id, cr_type, ck_create, ck_modify) VALUES
     ('transaction-type.refund', 'STATIC', 'system', 'system'),
     ('transaction-type.bonus', 'STATIC', 'system', 'system'),
     ('transaction-type.promo', 'STATIC', 'system', 'system'),
+    ('transaction-type.referral', 'STATIC', 'system', 'system'),
     ('user.avatar', 'STATIC', 'system', 'system'),
     ('user.background', 'STATIC', 'system', 'system'),
     ('user.verified', 'STATIC', 'system', 'system'),
@@ -101,7 +103,11 @@ INSERT INTO t_localization (ck_id, cr_type, ck_create, ck_modify) VALUES
     ('user.email', 'STATIC', 'system', 'system'),
     ('user.phone', 'STATIC', 'system', 'system'),
     ('user.interests', 'STATIC', 'system', 'system'),
-    ('user.location', 'STATIC', 'system', 'system')
+    ('user.location', 'STATIC', 'system', 'system'),
+    ('user.lang', 'STATIC', 'system', 'system'),
+    ('user.notify-email', 'STATIC', 'system', 'system'),
+    ('user.notify-webhook', 'STATIC', 'system', 'system'),
+    ('user.notify-muted', 'STATIC', 'system', 'system')
     ON CONFLICT (ck_id) DO NOTHING;
 
 INSERT INTO t_localization_word (ck_localization, ck_lang, ck_text, ck_create, ck_modify) VALUES 
@@ -177,6 +183,8 @@ INSERT INTO t_localization_word (ck_localization, ck_lang, ck_text, ck_create, c
     ('transaction-status.confirmed', 'RU', f_create_or_select_word('Подтверждено'), 'system', 'system'),
     ('transaction-status.rejected', 'EN', f_create_or_select_word('Rejected'), 'system', 'system'),
     ('transaction-status.rejected', 'RU', f_create_or_select_word('Отклонено'), 'system', 'system'),
+    ('transaction-status.completed', 'EN', f_create_or_select_word('Completed'), 'system', 'system'),
+    ('transaction-status.completed', 'RU', f_create_or_select_word('Выполнено'), 'system', 'system'),
     ('transaction-type.deposit', 'EN', f_create_or_select_word('Deposit'), 'system', 'system'),
     ('transaction-type.deposit', 'RU', f_create_or_select_word('Пополнение'), 'system', 'system'),
     ('transaction-type.withdrawal', 'EN', f_create_or_select_word('Withdrawal'), 'system', 'system'),
@@ -195,6 +203,8 @@ INSERT INTO t_localization_word (ck_localization, ck_lang, ck_text, ck_create, c
     ('transaction-type.bonus', 'RU', f_create_or_select_word('Бонус'), 'system', 'system'),
     ('transaction-type.promo', 'EN', f_create_or_select_word('Promo'), 'system', 'system'),
     ('transaction-type.promo', 'RU', f_create_or_select_word('Промо'), 'system', 'system'),
+    ('transaction-type.referral', 'EN', f_create_or_select_word('Referral commission'), 'system', 'system'),
+    ('transaction-type.referral', 'RU', f_create_or_select_word('Реферальное вознаграждение'), 'system', 'system'),
     ('user.avatar', 'EN', f_create_or_select_word('Avatar'), 'system', 'system'),
     ('user.avatar', 'RU', f_create_or_select_word('Аватар'), 'system', 'system'),
     ('user.background', 'EN', f_create_or_select_word('Background'), 'system', 'system'),
@@ -210,7 +220,15 @@ INSERT INTO t_localization_word (ck_localization, ck_lang, ck_text, ck_create, c
     ('user.interests', 'EN', f_create_or_select_word('Interests'), 'system', 'system'),
     ('user.interests', 'RU', f_create_or_select_word('Интересы'), 'system', 'system'),
     ('user.location', 'EN', f_create_or_select_word('Location'), 'system', 'system'),
-    ('user.location', 'RU', f_create_or_select_word('Местоположение'), 'system', 'system')
+    ('user.location', 'RU', f_create_or_select_word('Местоположение'), 'system', 'system'),
+    ('user.lang', 'EN', f_create_or_select_word('Language'), 'system', 'system'),
+    ('user.lang', 'RU', f_create_or_select_word('Язык'), 'system', 'system'),
+    ('user.notify-email', 'EN', f_create_or_select_word('Email notifications'), 'system', 'system'),
+    ('user.notify-email', 'RU', f_create_or_select_word('Уведомления на почту'), 'system', 'system'),
+    ('user.notify-webhook', 'EN', f_create_or_select_word('Notification webhook'), 'system', 'system'),
+    ('user.notify-webhook', 'RU', f_create_or_select_word('Webhook для уведомлений'), 'system', 'system'),
+    ('user.notify-muted', 'EN', f_create_or_select_word('Muted notifications'), 'system', 'system'),
+    ('user.notify-muted', 'RU', f_create_or_select_word('Отключённые уведомления'), 'system', 'system')
     ON CONFLICT (ck_localization, ck_lang) DO NOTHING;
 
 --changeset artemov_i:init_categories_data runOnChange:true dbms:postgresql splitStatements:false stripComments:false
@@ -309,7 +327,8 @@ select 'Создание статусов транзакций';
 INSERT INTO t_d_transaction_status (ck_id, ck_name, ck_description, ck_create, ck_modify) VALUES 
     ('PENDING', 'transaction-status.pending', null, 'system', 'system'),
     ('CONFIRMED', 'transaction-status.confirmed', null, 'system', 'system'),
-    ('REJECTED', 'transaction-status.rejected', null, 'system', 'system')
+    ('REJECTED', 'transaction-status.rejected', null, 'system', 'system'),
+    ('COMPLETED', 'transaction-status.completed', null, 'system', 'system')
     ON CONFLICT (ck_id) DO NOTHING;
 
 --rollback DROP TABLE t_d_transaction_status;
@@ -329,6 +348,7 @@ INSERT INTO t_d_transaction_type (ck_id, ck_name, ck_description, ck_create, ck_
     ('REFUND', 'transaction-type.refund', null, 'system', 'system'),
     ('BONUS', 'transaction-type.bonus', null, 'system', 'system'),
     ('PROMO', 'transaction-type.promo', null, 'system', 'system'),
+    ('REFERRAL', 'transaction-type.referral', null, 'system', 'system'),
     ('WITHDRAWAL', 'transaction-type.withdrawal', null, 'system', 'system')
     ON CONFLICT (ck_id) DO NOTHING;
 
@@ -348,7 +368,251 @@ INSERT INTO t_d_properties_type (ck_id, cr_type, cr_place, ck_name, ck_descripti
     ('USER_EMAIL', 'TEXT', 'USER', 'user.email', null, 'system', 'system'),
     ('USER_PHONE', 'TEXT', 'USER', 'user.phone', null, 'system', 'system'),
     ('USER_INTERESTS', 'JSONARRAY', 'USER', 'user.interests', null, 'system', 'system'),
-    ('USER_LOCATION', 'TEXT', 'USER', 'user.location', null, 'system', 'system')
+    ('USER_LOCATION', 'TEXT', 'USER', 'user.location', null, 'system', 'system'),
+    ('USER_LANG', 'TEXT', 'USER', 'user.lang', null, 'system', 'system'),
+    ('USER_NOTIFY_EMAIL', 'BOOLEAN', 'USER', 'user.notify-email', null, 'system', 'system'),
+    ('USER_NOTIFY_WEBHOOK', 'TEXT', 'USER', 'user.notify-webhook', null, 'system', 'system'),
+    ('USER_NOTIFY_MUTED', 'JSONARRAY', 'USER', 'user.notify-muted', null, 'system', 'system')
     ON CONFLICT (ck_id) DO NOTHING;
 
 --rollback DROP TABLE t_d_properties_type;
+
+--changeset artemov_i:init_notification_types_data runOnChange:true dbms:postgresql splitStatements:false stripComments:false
+
+-- =====================================================
+-- 11.1. ТИПЫ УВЕДОМЛЕНИЙ
+-- =====================================================
+-- В шаблонах {ключ} заменяется значением из данных уведомления
+select 'Создание типов уведомлений';
+INSERT INTO t_localization (ck_id, cr_type, ck_create, ck_modify) VALUES 
+    ('notification.comment-reply', 'STATIC', 'system', 'system'),
+    ('notification.comment-reply.body', 'STATIC', 'system', 'system'),
+    ('notification.comment-mention', 'STATIC', 'system', 'system'),
+    ('notification.comment-mention.body', 'STATIC', 'system', 'system'),
+    ('notification.comment-like', 'STATIC', 'system', 'system'),
+    ('notification.comment-like.body', 'STATIC', 'system', 'system'),
+    ('notification.bet-like', 'STATIC', 'system', 'system'),
+    ('notification.bet-like.body', 'STATIC', 'system', 'system'),
+    ('notification.wallet-deposit', 'STATIC', 'system', 'system'),
+    ('notification.wallet-deposit.body', 'STATIC', 'system', 'system'),
+    ('notification.wallet-withdrawal', 'STATIC', 'system', 'system'),
+    ('notification.wallet-withdrawal.body', 'STATIC', 'system', 'system'),
+    ('notification.admin-credit', 'STATIC', 'system', 'system'),
+    ('notification.admin-credit.body', 'STATIC', 'system', 'system')
+    ON CONFLICT (ck_id) DO NOTHING;
+
+INSERT INTO t_localization_word (ck_localization, ck_lang, ck_text, ck_create, ck_modify) VALUES 
+    ('notification.comment-reply', 'EN', f_create_or_select_word('New reply'), 'system', 'system'),
+    ('notification.comment-reply', 'RU', f_create_or_select_word('Новый ответ'), 'system', 'system'),
+    ('notification.comment-reply.body', 'EN', f_create_or_select_word('{user} replied to your comment: {text}'), 'system', 'system'),
+    ('notification.comment-reply.body', 'RU', f_create_or_select_word('{user} ответил на ваш комментарий: {text}'), 'system', 'system'),
+    ('notification.comment-mention', 'EN', f_create_or_select_word('You were mentioned'), 'system', 'system'),
+    ('notification.comment-mention', 'RU', f_create_or_select_word('Вас упомянули'), 'system', 'system'),
+    ('notification.comment-mention.body', 'EN', f_create_or_select_word('{user} mentioned you: {text}'), 'system', 'system'),
+    ('notification.comment-mention.body', 'RU', f_create_or_select_word('{user} упомянул вас: {text}'), 'system', 'system'),
+    ('notification.comment-like', 'EN', f_create_or_select_word('New like'), 'system', 'system'),
+    ('notification.comment-like', 'RU', f_create_or_select_word('Новый лайк'), 'system', 'system'),
+    ('notification.comment-like.body', 'EN', f_create_or_select_word('{user} liked your comment: {text}'), 'system', 'system'),
+    ('notification.comment-like.body', 'RU', f_create_or_select_word('{user} оценил ваш комментарий: {text}'), 'system', 'system'),
+    ('notification.bet-like', 'EN', f_create_or_select_word('New like'), 'system', 'system'),
+    ('notification.bet-like', 'RU', f_create_or_select_word('Новый лайк'), 'system', 'system'),
+    ('notification.bet-like.body', 'EN', f_create_or_select_word('{user} liked your bet'), 'system', 'system'),
+    ('notification.bet-like.body', 'RU', f_create_or_select_word('{user} оценил вашу ставку'), 'system', 'system'),
+    ('notification.wallet-deposit', 'EN', f_create_or_select_word('Deposit'), 'system', 'system'),
+    ('notification.wallet-deposit', 'RU', f_create_or_select_word('Пополнение'), 'system', 'system'),
+    ('notification.wallet-deposit.body', 'EN', f_create_or_select_word('{amount} was deposited, balance {balance}'), 'system', 'system'),
+    ('notification.wallet-deposit.body', 'RU', f_create_or_select_word('Зачислено {amount}, баланс {balance}'), 'system', 'system'),
+    ('notification.wallet-withdrawal', 'EN', f_create_or_select_word('Withdrawal'), 'system', 'system'),
+    ('notification.wallet-withdrawal', 'RU', f_create_or_select_word('Вывод средств'), 'system', 'system'),
+    ('notification.wallet-withdrawal.body', 'EN', f_create_or_select_word('{amount} was withdrawn, balance {balance}'), 'system', 'system'),
+    ('notification.wallet-withdrawal.body', 'RU', f_create_or_select_word('Выведено {amount}, баланс {balance}'), 'system', 'system'),
+    ('notification.admin-credit', 'EN', f_create_or_select_word('Balance credited'), 'system', 'system'),
+    ('notification.admin-credit', 'RU', f_create_or_select_word('Начисление на баланс'), 'system', 'system'),
+    ('notification.admin-credit.body', 'EN', f_create_or_select_word('{amount} was credited: {description}'), 'system', 'system'),
+    ('notification.admin-credit.body', 'RU', f_create_or_select_word('Начислено {amount}: {description}'), 'system', 'system')
+    ON CONFLICT (ck_localization, ck_lang) DO NOTHING;
+
+INSERT INTO t_d_notification_type (ck_id, ck_name, ck_description, ck_create, ck_modify) VALUES 
+    ('COMMENT_REPLY', 'notification.comment-reply', 'notification.comment-reply.body', 'system', 'system'),
+    ('COMMENT_MENTION', 'notification.comment-mention', 'notification.comment-mention.body', 'system', 'system'),
+    ('COMMENT_LIKE', 'notification.comment-like', 'notification.comment-like.body', 'system', 'system'),
+    ('BET_LIKE', 'notification.bet-like', 'notification.bet-like.body', 'system', 'system'),
+    ('WALLET_DEPOSIT', 'notification.wallet-deposit', 'notification.wallet-deposit.body', 'system', 'system'),
+    ('WALLET_WITHDRAWAL', 'notification.wallet-withdrawal', 'notification.wallet-withdrawal.body', 'system', 'system'),
+    ('ADMIN_CREDIT', 'notification.admin-credit', 'notification.admin-credit.body', 'system', 'system')
+    ON CONFLICT (ck_id) DO NOTHING;
+
+--rollback DELETE FROM t_d_notification_type;
+
+--changeset artemov_i:init_roles_data runOnChange:true dbms:postgresql splitStatements:false stripComments:false
+
+-- =====================================================
+-- 12. РОЛИ И ДОСТУПЫ К ТАБЛИЦАМ
+-- =====================================================
+select 'Создание ролей';
+INSERT INTO t_localization (ck_id, cr_type, ck_create, ck_modify) VALUES 
+    ('role.anonymous', 'STATIC', 'system', 'system'),
+    ('role.viewer', 'STATIC', 'system', 'system'),
+    ('role.manager', 'STATIC', 'system', 'system'),
+    ('role.admin', 'STATIC', 'system', 'system')
+    ON CONFLICT (ck_id) DO NOTHING;
+
+INSERT INTO t_localization_word (ck_localization, ck_lang, ck_text, ck_create, ck_modify) VALUES 
+    ('role.anonymous', 'EN', f_create_or_select_word('Guest'), 'system', 'system'),
+    ('role.anonymous', 'RU', f_create_or_select_word('Гость'), 'system', 'system'),
+    ('role.viewer', 'EN', f_create_or_select_word('User'), 'system', 'system'),
+    ('role.viewer', 'RU', f_create_or_select_word('Пользователь'), 'system', 'system'),
+    ('role.manager', 'EN', f_create_or_select_word('Manager'), 'system', 'system'),
+    ('role.manager', 'RU', f_create_or_select_word('Менеджер'), 'system', 'system'),
+    ('role.admin', 'EN', f_create_or_select_word('Administrator'), 'system', 'system'),
+    ('role.admin', 'RU', f_create_or_select_word('Администратор'), 'system', 'system')
+    ON CONFLICT (ck_localization, ck_lang) DO NOTHING;
+
+INSERT INTO t_d_role (ck_id, ck_name, ck_description, cl_default, cr_place, ck_create, ck_modify) VALUES 
+    ('ANONYMOUS', 'role.anonymous', null, false, 'GLOBAL', 'system', 'system'),
+    ('VIEWER', 'role.viewer', null, true, 'GLOBAL', 'system', 'system'),
+    ('MANAGER', 'role.manager', null, false, 'GLOBAL', 'system', 'system'),
+    ('ADMIN', 'role.admin', null, false, 'GLOBAL', 'system', 'system')
+    ON CONFLICT (ck_id) DO NOTHING;
+
+-- Доступы, объявленные маршрутами API; отозванные вручную не восстанавливаются
+select 'Создание доступов ролей к таблицам';
+INSERT INTO t_d_table_role (ck_role, ck_table, cr_action, ck_create, ck_modify)
+SELECT g.ck_role, g.ck_table, g.cr_action, 'system', 'system'
+FROM (VALUES
+    -- Просмотр справочников и ставок доступен всем
+    ('ANONYMOUS', 't_d_category', 'VIEW'),
+    ('ANONYMOUS', 't_d_verification_source', 'VIEW'),
+    ('ANONYMOUS', 't_d_bet_status', 'VIEW'),
+    ('ANONYMOUS', 't_d_bet_type', 'VIEW'),
+    ('ANONYMOUS', 't_d_like_type', 'VIEW'),
+    ('ANONYMOUS', 't_d_properties_type', 'VIEW'),
+    ('ANONYMOUS', 't_d_properties_enum', 'VIEW'),
+    ('ANONYMOUS', 't_localization_word', 'VIEW'),
+    ('ANONYMOUS', 't_bet', 'VIEW'),
+    ('ANONYMOUS', 't_bet_comment', 'VIEW'),
+    ('ANONYMOUS', 't_bet_comment_revision', 'VIEW'),
+    ('ANONYMOUS', 't_chat', 'VIEW'),
+    ('ANONYMOUS', 't_chat_message', 'VIEW'),
+    ('ANONYMOUS', 't_user_reputation', 'VIEW'),
+    ('VIEWER', 't_d_category', 'VIEW'),
+    ('VIEWER', 't_d_verification_source', 'VIEW'),
+    ('VIEWER', 't_d_bet_status', 'VIEW'),
+    ('VIEWER', 't_d_bet_type', 'VIEW'),
+    ('VIEWER', 't_d_like_type', 'VIEW'),
+    ('VIEWER', 't_d_properties_type', 'VIEW'),
+    ('VIEWER', 't_d_properties_enum', 'VIEW'),
+    ('VIEWER', 't_localization_word', 'VIEW'),
+    ('VIEWER', 't_bet', 'VIEW'),
+    ('VIEWER', 't_bet', 'INSERT'),
+    ('VIEWER', 't_bet_like', 'INSERT'),
+    ('VIEWER', 't_bet_like', 'DELETE'),
+    ('VIEWER', 't_bet_comment', 'VIEW'),
+    ('VIEWER', 't_bet_comment_revision', 'VIEW'),
+    ('VIEWER', 't_bet_comment', 'INSERT'),
+    ('VIEWER', 't_bet_comment_like', 'INSERT'),
+    ('VIEWER', 't_bet_comment_like', 'DELETE'),
+    ('VIEWER', 't_chat', 'VIEW'),
+    ('VIEWER', 't_chat', 'INSERT'),
+    ('VIEWER', 't_chat_user', 'INSERT'),
+    ('VIEWER', 't_chat_user', 'DELETE'),
+    ('VIEWER', 't_chat_message', 'VIEW'),
+    ('VIEWER', 't_chat_message', 'INSERT'),
+    ('VIEWER', 't_chat_message_like', 'INSERT'),
+    ('VIEWER', 't_chat_message_like', 'DELETE'),
+    ('VIEWER', 't_chat_user', 'UPDATE'),
+    ('VIEWER', 't_chat_user_ban', 'VIEW'),
+    ('VIEWER', 't_chat_user_ban', 'INSERT'),
+    ('VIEWER', 't_chat_user_ban', 'DELETE'),
+    ('VIEWER', 't_chat_credentials', 'VIEW'),
+    ('VIEWER', 't_chat_credentials', 'INSERT'),
+    ('VIEWER', 't_chat_credentials', 'DELETE'),
+    ('VIEWER', 't_user_rating', 'INSERT'),
+    ('VIEWER', 't_user_rating', 'DELETE'),
+    ('VIEWER', 't_bet_rating', 'INSERT'),
+    ('VIEWER', 't_bet_rating', 'DELETE'),
+    ('VIEWER', 't_user_reputation', 'VIEW'),
+    -- Менеджер ведёт переводы
+    ('MANAGER', 't_d_category', 'VIEW'),
+    ('MANAGER', 't_d_verification_source', 'VIEW'),
+    ('MANAGER', 't_d_bet_status', 'VIEW'),
+    ('MANAGER', 't_d_bet_type', 'VIEW'),
+    ('MANAGER', 't_d_like_type', 'VIEW'),
+    ('MANAGER', 't_d_properties_type', 'VIEW'),
+    ('MANAGER', 't_d_properties_enum', 'VIEW'),
+    ('MANAGER', 't_bet', 'VIEW'),
+    ('MANAGER', 't_bet', 'INSERT'),
+    ('MANAGER', 't_bet_like', 'INSERT'),
+    ('MANAGER', 't_bet_like', 'DELETE'),
+    ('MANAGER', 't_bet_comment', 'VIEW'),
+    ('MANAGER', 't_bet_comment_revision', 'VIEW'),
+    ('MANAGER', 't_bet_comment', 'INSERT'),
+    ('MANAGER', 't_bet_comment_like', 'INSERT'),
+    ('MANAGER', 't_bet_comment_like', 'DELETE'),
+    ('MANAGER', 't_chat', 'VIEW'),
+    ('MANAGER', 't_chat', 'INSERT'),
+    ('MANAGER', 't_chat_user', 'INSERT'),
+    ('MANAGER', 't_chat_user', 'DELETE'),
+    ('MANAGER', 't_chat_message', 'VIEW'),
+    ('MANAGER', 't_chat_message', 'INSERT'),
+    ('MANAGER', 't_chat_message_like', 'INSERT'),
+    ('MANAGER', 't_chat_message_like', 'DELETE'),
+    ('MANAGER', 't_chat_user', 'UPDATE'),
+    ('MANAGER', 't_chat_user_ban', 'VIEW'),
+    ('MANAGER', 't_chat_user_ban', 'INSERT'),
+    ('MANAGER', 't_chat_user_ban', 'DELETE'),
+    ('MANAGER', 't_chat_credentials', 'VIEW'),
+    ('MANAGER', 't_chat_credentials', 'INSERT'),
+    ('MANAGER', 't_chat_credentials', 'DELETE'),
+    ('MANAGER', 't_user_rating', 'INSERT'),
+    ('MANAGER', 't_user_rating', 'DELETE'),
+    ('MANAGER', 't_bet_rating', 'INSERT'),
+    ('MANAGER', 't_bet_rating', 'DELETE'),
+    ('MANAGER', 't_user_reputation', 'VIEW'),
+    ('MANAGER', 't_localization_word', 'ALL'),
+    ('MANAGER', 't_d_lang', 'ALL'),
+    -- Администратор управляет всем
+    ('ADMIN', 't_d_category', 'ALL'),
+    ('ADMIN', 't_d_verification_source', 'ALL'),
+    ('ADMIN', 't_d_bet_status', 'ALL'),
+    ('ADMIN', 't_d_bet_type', 'ALL'),
+    ('ADMIN', 't_d_like_type', 'ALL'),
+    ('ADMIN', 't_d_properties_type', 'ALL'),
+    ('ADMIN', 't_d_properties_enum', 'ALL'),
+    ('ADMIN', 't_bet', 'ALL'),
+    ('ADMIN', 't_bet_like', 'ALL'),
+    ('ADMIN', 't_bet_comment', 'ALL'),
+    ('ADMIN', 't_bet_comment_like', 'ALL'),
+    ('ADMIN', 't_bet_comment_revision', 'ALL'),
+    ('ADMIN', 't_bet_comment_mention', 'ALL'),
+    ('ADMIN', 't_notification', 'ALL'),
+    ('ADMIN', 't_chat', 'ALL'),
+    ('ADMIN', 't_chat_user', 'ALL'),
+    ('ADMIN', 't_chat_message', 'ALL'),
+    ('ADMIN', 't_chat_message_like', 'ALL'),
+    ('ADMIN', 't_chat_user_ban', 'ALL'),
+    ('ADMIN', 't_chat_credentials', 'ALL'),
+    ('ADMIN', 't_user_rating', 'ALL'),
+    ('ADMIN', 't_bet_rating', 'ALL'),
+    ('ADMIN', 't_user_reputation', 'ALL'),
+    ('ADMIN', 't_user_bet_history', 'ALL'),
+    ('ADMIN', 't_d_notification_type', 'ALL'),
+    ('ADMIN', 't_localization_word', 'ALL'),
+    ('ADMIN', 't_d_lang', 'ALL'),
+    ('ADMIN', 't_d_role', 'ALL'),
+    ('ADMIN', 't_d_table_role', 'ALL'),
+    ('ADMIN', 't_properties_role', 'ALL'),
+    ('ADMIN', 't_user_role', 'ALL'),
+    ('ADMIN', 't_session', 'ALL'),
+    ('ADMIN', 't_api_token', 'ALL'),
+    ('ADMIN', 't_user_wallet', 'ALL'),
+    ('ADMIN', 't_user_transaction', 'ALL'),
+    ('ADMIN', 't_referral_earning', 'ALL'),
+    ('ADMIN', 't_audit_log', 'VIEW')
+) AS g (ck_role, ck_table, cr_action)
+WHERE NOT EXISTS (
+    SELECT 1 FROM t_d_table_role tr
+    WHERE tr.ck_role = g.ck_role AND tr.ck_table = g.ck_table AND tr.cr_action = g.cr_action
+);
+
+--rollback DELETE FROM t_d_table_role WHERE ck_create = 'system';
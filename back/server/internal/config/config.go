@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -18,13 +19,20 @@ type Config struct {
 	Store     StoreConfig
 	Swagger   SwaggerConfig
 	S3        S3Config
+	Storage   StorageConfig
 	Keycloak  KeycloakConfig
 	Media     MediaConfig
 	Cache     CacheConfig
 	AI        AICofig
+	Translate TranslateConfig
+	Locales   LocalesConfig
 	MCP       MCPConfig
 	Frontend  FrontendConfig
 	Wallet    WalletConfig
+	Referral  ReferralConfig
+	Comments  CommentsConfig
+	Notify    NotifyConfig
+	Events    EventsConfig
 	RateLimit RateLimitConfig
 }
 
@@ -60,11 +68,26 @@ type AICofig struct {
 	APIKey *string `json:"api_key,omitempty"`
 }
 
-type CacheConfig struct {
+// TranslateConfig holds the machine-translation job configuration
+type TranslateConfig struct {
 	Enabled    bool
-	Expiration time.Duration
-	Dir        string
-	IsUseCache bool
+	Interval   time.Duration // pause between runs of the background job
+	BatchSize  int           // max translations requested per run
+	RetryAfter time.Duration // how long a failed key is skipped
+}
+
+// LocalesConfig holds the locale bundle import and export configuration
+type LocalesConfig struct {
+	MaxImportSize int64 // maximum size in bytes of an imported bundle
+}
+
+type CacheConfig struct {
+	Enabled         bool
+	Expiration      time.Duration
+	Dir             string
+	IsUseCache      bool
+	LocalizationTTL time.Duration // lifetime of resolved texts in the in-process localization cache, 0 disables it
+	PermissionTTL   time.Duration // lifetime of cached user roles and role grants, 0 disables caching between requests
 }
 
 type MCPConfig struct {
@@ -107,6 +130,11 @@ type StoreConfig struct {
 	CookieSecure    bool
 	CookieHttpOnly  bool
 	SessionDuration time.Duration
+	TokenSecret     string // key for refresh tokens stored in sessions
+	SessionNotify   bool   // invalidate cached sessions of other instances via LISTEN/NOTIFY
+
+	AnonymousCookieName string        // cookie with the stable anonymous visitor ID
+	AnonymousDuration   time.Duration // lifetime of the anonymous ID and its state
 }
 
 // SwaggerConfig holds swagger configuration
@@ -128,6 +156,24 @@ type S3Config struct {
 	ForcePathStyle bool // for MinIO compatibility
 }
 
+type StorageType string
+
+const (
+	StorageTypeS3     StorageType = "s3"
+	StorageTypeFS     StorageType = "fs"
+	StorageTypeMemory StorageType = "memory"
+)
+
+// StorageConfig holds blob storage backend configuration
+type StorageConfig struct {
+	Type              StorageType
+	Dir               string        // root directory for the fs backend
+	BaseURL           string        // public URL of the local blob endpoint (fs and memory backends)
+	Secret            string        // HMAC secret for local signed URLs, required by the fs and memory backends
+	PresignExpiration time.Duration // lifetime of presigned upload URLs
+	MaxUploadSize     int64         // maximum size in bytes of a blob uploaded by presigned URL
+}
+
 type TenantConfig struct {
 	Iss          string
 	Realm        string
@@ -139,13 +185,15 @@ type TenantConfig struct {
 
 // KeycloakConfig holds Keycloak configuration for multitenant authentication
 type KeycloakConfig struct {
-	ServerURL    string         // Keycloak server URL (e.g., http://localhost:8080)
-	DefaultRealm string         // Default realm name
-	ClientID     string         // Client ID for API access
-	ClientSecret string         // Client secret for API access
-	JWTIssuer    string         // JWT issuer URL template (with {realm} placeholder)
-	CertEndpoint string         // Certificate endpoint template (with {realm} placeholder)
-	TenantsIss   []TenantConfig // Tenants ISS list
+	ServerURL    string            // Keycloak server URL (e.g., http://localhost:8080)
+	DefaultRealm string            // Default realm name
+	ClientID     string            // Client ID for API access
+	ClientSecret string            // Client secret for API access
+	JWTIssuer    string            // JWT issuer URL template (with {realm} placeholder)
+	CertEndpoint string            // Certificate endpoint template (with {realm} placeholder)
+	TenantsIss   []TenantConfig    // Tenants ISS list
+	RoleMappings map[string]string // Keycloak role -> local role ID, client roles as "<client>/<role>"; "" ignores the role
+	MatchRoles   bool              // grant local roles named like unmapped Keycloak roles
 }
 
 type MediaConfig struct {
@@ -156,6 +204,52 @@ type WalletConfig struct {
 	DefaultBalance float64
 }
 
+// ReferralConfig holds the commission rules paid to the referrer
+type ReferralConfig struct {
+	SignupBonus        float64        // paid once when the referred user registers
+	StakePercent       float64        // percent of every stake of the referred user
+	WinningsPercent    float64        // percent of every win of the referred user
+	CommissionDays     int            // how many days after registration stakes and winnings earn commission
+	SecondLevelPercent float64        // percent of a commission paid to the referrer's own referrer
+	Tiers              []ReferralTier // commission multipliers by number of active referees
+	ActiveDays         int            // a referee is active if they staked within this many days
+	HoldDays           int            // earnings are paid out after this many days unless frozen, 0 pays at once
+	PayoutInterval     time.Duration  // pause between runs of the payout job
+}
+
+// ReferralTier multiplies commissions of referrers with at least ActiveReferees active referees
+type ReferralTier struct {
+	ActiveReferees int     `json:"active_referees"`
+	Multiplier     float64 `json:"multiplier"`
+}
+
+// CommentsConfig limits the comment tree returned in one response
+type CommentsConfig struct {
+	MaxDepth   int           // max levels of replies loaded under a comment
+	Replies    int           // default number of replies loaded per comment
+	EditWindow time.Duration // how long the author may edit a comment, 0 means no limit
+}
+
+// NotifyConfig configures delivery of notifications outside the application
+type NotifyConfig struct {
+	SMTPHost        string // email channel is disabled when empty
+	SMTPPort        int
+	SMTPUsername    string
+	SMTPPassword    string
+	SMTPFrom        string
+	WebhookSecret   string        // signs webhook bodies with HMAC-SHA256 when set
+	WebhookTimeout  time.Duration // timeout of one webhook request
+	DeliverInterval time.Duration // pause between runs of the delivery job, 0 disables it
+}
+
+// EventsConfig configures the real-time event stream (SSE and WebSocket)
+type EventsConfig struct {
+	Enabled   bool          // publish events and serve /events
+	Buffer    int           // events queued per connection before a slow client is dropped
+	KeepAlive time.Duration // interval of keep-alive messages on idle connections
+	MaxTopics int           // topics one connection may subscribe to
+}
+
 type RateLimitConfig struct {
 	RPS   float64
 	Burst int
@@ -202,6 +296,11 @@ func LoadConfig() *Config {
 			CookieDomain:    getEnv("STORE_COOKIE_DOMAIN", ""),
 			CookieSecure:    getEnvAsBool("STORE_COOKIE_SECURE", false),
 			CookieHttpOnly:  getEnvAsBool("STORE_COOKIE_HTTP_ONLY", true),
+			TokenSecret:     getEnv("STORE_TOKEN_SECRET", getEnv("STORE_SECRET", "your-secret-key")),
+			SessionNotify:   getEnvAsBool("STORE_SESSION_NOTIFY", true),
+
+			AnonymousCookieName: getEnv("STORE_ANONYMOUS_COOKIE_NAME", "parier-visitor"),
+			AnonymousDuration:   getEnvDuration("STORE_ANONYMOUS_DURATION", 90*24*time.Hour),
 		},
 		Swagger: SwaggerConfig{
 			Host:     getEnv("SWAGGER_HOST", "localhost:8080"),
@@ -218,6 +317,14 @@ func LoadConfig() *Config {
 			UseSSL:         getEnvAsBool("S3_USE_SSL", true),
 			ForcePathStyle: getEnvAsBool("S3_FORCE_PATH_STYLE", false),
 		},
+		Storage: StorageConfig{
+			Type:              StorageType(getEnv("STORAGE_TYPE", string(StorageTypeS3))),
+			Dir:               getEnv("STORAGE_DIR", filepath.Join(os.TempDir(), "parier-storage")),
+			BaseURL:           getEnv("STORAGE_BASE_URL", "http://localhost:8080/api/v1/media/blob"),
+			Secret:            getEnv("STORAGE_SECRET", ""),
+			PresignExpiration: getEnvDuration("STORAGE_PRESIGN_EXPIRATION", 15*time.Minute),
+			MaxUploadSize:     int64(getEnvAsInt("STORAGE_MAX_UPLOAD_SIZE", 50<<20)),
+		},
 		Keycloak: KeycloakConfig{
 			ServerURL:    getEnv("KEYCLOAK_SERVER_URL", "http://localhost:8080"),
 			DefaultRealm: getEnv("KEYCLOAK_DEFAULT_REALM", "parier"),
@@ -226,15 +333,19 @@ func LoadConfig() *Config {
 			JWTIssuer:    getEnv("KEYCLOAK_JWT_ISSUER", "http://localhost:8080/realms/{realm}"),
 			CertEndpoint: getEnv("KEYCLOAK_CERT_ENDPOINT", "http://localhost:8080/realms/{realm}/protocol/openid-connect/certs"),
 			TenantsIss:   getEnvTenantsIss("KEYCLOAK_TENANTS", ""),
+			RoleMappings: getEnvRoleMappings("KEYCLOAK_ROLE_MAPPINGS"),
+			MatchRoles:   getEnvAsBool("KEYCLOAK_MATCH_ROLES", false),
 		},
 		Media: MediaConfig{
 			Duration: duration,
 		},
 		Cache: CacheConfig{
-			Enabled:    getEnvAsBool("CACHE_ENABLED", false),
-			Expiration: cacheDuration,
-			Dir:        getEnv("CACHE_DIR", os.TempDir()),
-			IsUseCache: getEnvAsBool("CACHE_IS_USE_CACHE", true),
+			Enabled:         getEnvAsBool("CACHE_ENABLED", false),
+			Expiration:      cacheDuration,
+			Dir:             getEnv("CACHE_DIR", os.TempDir()),
+			IsUseCache:      getEnvAsBool("CACHE_IS_USE_CACHE", true),
+			LocalizationTTL: getEnvDuration("CACHE_LOCALIZATION_TTL", 10*time.Minute),
+			PermissionTTL:   getEnvDuration("CACHE_PERMISSION_TTL", 30*time.Second),
 		},
 		AI: AICofig{
 			Type:   AIType(getEnv("AI_TYPE", "n8n")),
@@ -243,6 +354,15 @@ func LoadConfig() *Config {
 			Model:  getEnvPtr("AI_MODEL", ""),
 			APIKey: getEnvPtr("AI_API_KEY", ""),
 		},
+		Translate: TranslateConfig{
+			Enabled:    getEnvAsBool("TRANSLATE_ENABLED", false),
+			Interval:   getEnvDuration("TRANSLATE_INTERVAL", 10*time.Minute),
+			BatchSize:  getEnvAsInt("TRANSLATE_BATCH_SIZE", 50),
+			RetryAfter: getEnvDuration("TRANSLATE_RETRY_AFTER", time.Hour),
+		},
+		Locales: LocalesConfig{
+			MaxImportSize: int64(getEnvAsInt("LOCALES_MAX_IMPORT_SIZE", 10<<20)),
+		},
 		MCP: MCPConfig{
 			Enabled: getEnvAsBool("MCP_ENABLED", true),
 			Port:    getEnvAsInt("MCP_PORT", 8081),
@@ -253,6 +373,38 @@ func LoadConfig() *Config {
 		Wallet: WalletConfig{
 			DefaultBalance: getEnvAsFloat("WALLET_DEFAULT_BALANCE", 0),
 		},
+		Referral: ReferralConfig{
+			SignupBonus:        getEnvAsFloat("REFERRAL_SIGNUP_BONUS", 0),
+			StakePercent:       getEnvAsFloat("REFERRAL_STAKE_PERCENT", 0),
+			WinningsPercent:    getEnvAsFloat("REFERRAL_WINNINGS_PERCENT", 0),
+			CommissionDays:     getEnvAsInt("REFERRAL_COMMISSION_DAYS", 30),
+			SecondLevelPercent: getEnvAsFloat("REFERRAL_SECOND_LEVEL_PERCENT", 0),
+			Tiers:              getEnvReferralTiers("REFERRAL_TIERS"),
+			ActiveDays:         getEnvAsInt("REFERRAL_ACTIVE_DAYS", 30),
+			HoldDays:           getEnvAsInt("REFERRAL_HOLD_DAYS", 7),
+			PayoutInterval:     getEnvDuration("REFERRAL_PAYOUT_INTERVAL", time.Hour),
+		},
+		Comments: CommentsConfig{
+			MaxDepth:   getEnvAsInt("COMMENTS_MAX_DEPTH", 3),
+			Replies:    getEnvAsInt("COMMENTS_REPLIES", 3),
+			EditWindow: getEnvDuration("COMMENTS_EDIT_WINDOW", 15*time.Minute),
+		},
+		Notify: NotifyConfig{
+			SMTPHost:        getEnv("NOTIFY_SMTP_HOST", ""),
+			SMTPPort:        getEnvAsInt("NOTIFY_SMTP_PORT", 587),
+			SMTPUsername:    getEnv("NOTIFY_SMTP_USERNAME", ""),
+			SMTPPassword:    getEnv("NOTIFY_SMTP_PASSWORD", ""),
+			SMTPFrom:        getEnv("NOTIFY_SMTP_FROM", ""),
+			WebhookSecret:   getEnv("NOTIFY_WEBHOOK_SECRET", ""),
+			WebhookTimeout:  getEnvDuration("NOTIFY_WEBHOOK_TIMEOUT", 5*time.Second),
+			DeliverInterval: getEnvDuration("NOTIFY_DELIVER_INTERVAL", 30*time.Second),
+		},
+		Events: EventsConfig{
+			Enabled:   getEnvAsBool("EVENTS_ENABLED", true),
+			Buffer:    getEnvAsInt("EVENTS_BUFFER", 64),
+			KeepAlive: getEnvDuration("EVENTS_KEEPALIVE", 25*time.Second),
+			MaxTopics: getEnvAsInt("EVENTS_MAX_TOPICS", 20),
+		},
 		RateLimit: RateLimitConfig{
 			RPS:   getEnvAsFloat("RATE_LIMIT_RPS", 10),
 			Burst: getEnvAsInt("RATE_LIMIT_BURST", 20),
@@ -291,6 +443,34 @@ func getEnvTenantsIss(key string, defaultVal string) []TenantConfig {
 	return tenants
 }
 
+// getEnvRoleMappings parses a JSON object of Keycloak role mappings
+func getEnvRoleMappings(key string) map[string]string {
+	mappings := make(map[string]string)
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return mappings
+	}
+	if err := json.Unmarshal([]byte(valueStr), &mappings); err != nil {
+		log.Printf("Invalid %s: %v", key, err)
+		return make(map[string]string)
+	}
+	return mappings
+}
+
+// getEnvReferralTiers parses a JSON array of referral tiers
+func getEnvReferralTiers(key string) []ReferralTier {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return nil
+	}
+	var tiers []ReferralTier
+	if err := json.Unmarshal([]byte(valueStr), &tiers); err != nil {
+		log.Printf("Invalid %s: %v", key, err)
+		return nil
+	}
+	return tiers
+}
+
 // getEnv gets an environment variable or returns a default value
 func getEnv(key, defaultVal string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -383,3 +563,8 @@ func (k *TenantConfig) GetCertEndpoint() string {
 func (k *TenantConfig) GetTokenEndpoint() string {
 	return k.GetJWTIssuer() + "/protocol/openid-connect/token"
 }
+
+// GetRevokeEndpoint returns the token revocation endpoint URL for specific realm
+func (k *TenantConfig) GetRevokeEndpoint() string {
+	return k.GetJWTIssuer() + "/protocol/openid-connect/revoke"
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"parier-server/internal/middleware"
+	"parier-server/internal/models"
+	"parier-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ActivityHandler serves visitor activity: viewed bets, bet drafts, language and pending referral code.
+// Anonymous visitors are served too; their activity is moved to the account on login.
+type ActivityHandler struct {
+	service *service.ActivityService
+}
+
+func NewActivityHandler(s *service.ActivityService) *ActivityHandler {
+	return &ActivityHandler{service: s}
+}
+
+// ActivityResponse represents the activity of the current visitor
+type ActivityResponse struct {
+	models.SuccessResponse
+	Data models.AnonymousState `json:"data"`
+}
+
+// BetDraftResponse represents a saved bet draft
+type BetDraftResponse struct {
+	models.SuccessResponse
+	Data models.BetDraft `json:"data"`
+}
+
+// SetLangRequest represents the interface language of the visitor
+type SetLangRequest struct {
+	Lang string `json:"lang" binding:"required"`
+}
+
+// SetReferralCodeRequest represents the referral code the visitor came with
+type SetReferralCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// SaveDraftRequest represents the state of the bet creation form; without ID a new draft is created
+type SaveDraftRequest struct {
+	ID   *uuid.UUID      `json:"id,omitempty"`
+	Data json.RawMessage `json:"data" binding:"required" swaggertype:"object"`
+}
+
+func getVisitor(c *gin.Context) (service.Visitor, bool) {
+	session, err := middleware.GetSession(c)
+	if err != nil {
+		SendError(c, http.StatusUnauthorized, "Session not found", err.Error())
+		return service.Visitor{}, false
+	}
+	return service.VisitorFromSession(session), true
+}
+
+// GetActivity godoc
+// @Summary Get visitor activity
+// @Description Get viewed bets, bet drafts, language and pending referral code of the current visitor
+// @Tags activity
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Success 200 {object} ActivityResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /activity [get]
+func (h *ActivityHandler) GetActivity(c *gin.Context) {
+	visitor, ok := getVisitor(c)
+	if !ok {
+		return
+	}
+	activity, err := h.service.GetActivity(visitor)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Activity retrieved successfully", activity)
+}
+
+// PostBetView godoc
+// @Summary Record bet view
+// @Description Record that the current visitor viewed the bet
+// @Tags activity
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param bet_id path string true "Bet ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /activity/views/{bet_id} [post]
+func (h *ActivityHandler) PostBetView(c *gin.Context) {
+	visitor, ok := getVisitor(c)
+	if !ok {
+		return
+	}
+	betID, err := GetUUIDParam(c, "bet_id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid bet ID", err.Error())
+		return
+	}
+	if err := h.service.RecordBetView(visitor, betID); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Bet view recorded successfully")
+}
+
+// PutLang godoc
+// @Summary Set interface language
+// @Description Save the interface language of the current visitor
+// @Tags activity
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param request body SetLangRequest true "Language"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /activity/lang [put]
+func (h *ActivityHandler) PutLang(c *gin.Context) {
+	visitor, ok := getVisitor(c)
+	if !ok {
+		return
+	}
+	var req SetLangRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if err := h.service.SetLang(visitor, req.Lang); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Language saved successfully")
+}
+
+// PutReferralCode godoc
+// @Summary Set pending referral code
+// @Description Remember the referral code of an anonymous visitor; it is applied when the visitor registers
+// @Tags activity
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param request body SetReferralCodeRequest true "Referral code"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /activity/referral [put]
+func (h *ActivityHandler) PutReferralCode(c *gin.Context) {
+	visitor, ok := getVisitor(c)
+	if !ok {
+		return
+	}
+	var req SetReferralCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if err := h.service.SetReferralCode(visitor, req.Code); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Referral code saved successfully")
+}
+
+// PutDraft godoc
+// @Summary Save bet draft
+// @Description Create a bet draft, or update it when the draft ID is given
+// @Tags activity
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param request body SaveDraftRequest true "Draft"
+// @Success 200 {object} BetDraftResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /activity/drafts [put]
+func (h *ActivityHandler) PutDraft(c *gin.Context) {
+	visitor, ok := getVisitor(c)
+	if !ok {
+		return
+	}
+	var req SaveDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	id := uuid.Nil
+	if req.ID != nil {
+		id = *req.ID
+	}
+	draft, err := h.service.SaveDraft(visitor, id, req.Data)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Draft saved successfully", draft)
+}
+
+// DeleteDraft godoc
+// @Summary Delete bet draft
+// @Description Delete a bet draft of the current visitor
+// @Tags activity
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "Draft ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /activity/drafts/{id} [delete]
+func (h *ActivityHandler) DeleteDraft(c *gin.Context) {
+	visitor, ok := getVisitor(c)
+	if !ok {
+		return
+	}
+	id, err := GetUUIDParam(c, "id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid draft ID", err.Error())
+		return
+	}
+	if err := h.service.DeleteDraft(visitor, id); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Draft deleted successfully")
+}
+
+func (h *ActivityHandler) RegisterRoutes(router *gin.RouterGroup) {
+	activity := middleware.Declare(router).Group("activity")
+	{
+		activity.GET("", middleware.SessionAccess(), h.GetActivity)
+		activity.POST("/views/:bet_id", middleware.SessionAccess(), h.PostBetView)
+		activity.PUT("/lang", middleware.SessionAccess(), h.PutLang)
+		activity.PUT("/referral", middleware.SessionAccess(), h.PutReferralCode)
+		activity.PUT("/drafts", middleware.SessionAccess(), h.PutDraft)
+		activity.DELETE("/drafts/:id", middleware.SessionAccess(), h.DeleteDraft)
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"parier-server/internal/middleware"
 	"parier-server/internal/models"
 	"parier-server/internal/service"
 	"strconv"
@@ -72,12 +73,6 @@ func (h *AdminHandler) PostAdminCreditTokens(c *gin.Context) {
 		return
 	}
 
-	// Check admin role
-	if !h.hasAdminRole(user) {
-		SendError(c, http.StatusForbidden, "Forbidden", "Admin role required")
-		return
-	}
-
 	targets, err := h.service.ResolveCreditTargets(req.Rule, req.RuleParams)
 	if err != nil {
 		SendError(c, http.StatusInternalServerError, "Internal server error", err.Error())
@@ -100,7 +95,7 @@ func (h *AdminHandler) PostAdminCreditTokens(c *gin.Context) {
 		desc = "Admin credit"
 	}
 
-	newBalances, err := h.service.CreditUsers(targets, amount, desc, user.ID.String())
+	newBalances, err := h.service.CreditUsers(targets, amount, desc, GetAuditActor(c))
 	if err != nil {
 		SendError(c, http.StatusInternalServerError, "Internal server error", err.Error())
 		return
@@ -127,6 +122,8 @@ func (h *AdminHandler) PostAdminCreditTokens(c *gin.Context) {
 // @Param minBets query string false "Min bets"
 // @Success 200 {object} AdminCreditPreviewResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /admin/credit-preview [get]
 func (h *AdminHandler) GetAdminCreditPreview(c *gin.Context) {
@@ -178,16 +175,10 @@ func (h *AdminHandler) GetAdminCreditPreview(c *gin.Context) {
 	c.JSON(http.StatusOK, AdminCreditPreviewResponse{Count: len(targets)})
 }
 
-func (h *AdminHandler) hasAdminRole(user *models.User) bool {
-	for _, r := range user.Roles {
-		if r == "admin" || r == "ADMIN" {
-			return true
-		}
-	}
-	return false
-}
-
 func (h *AdminHandler) RegisterRoutes(router *gin.RouterGroup) {
-	router.GET("/credit-preview", h.GetAdminCreditPreview)
-	router.POST("/credit-tokens", h.PostAdminCreditTokens)
+	admin := middleware.Declare(router).Group("admin")
+	{
+		admin.GET("/credit-preview", middleware.TableAccess("t_user_wallet", models.ActionTypeView), h.GetAdminCreditPreview)
+		admin.POST("/credit-tokens", middleware.TableAccess("t_user_transaction", models.ActionTypeInsert), h.PostAdminCreditTokens)
+	}
 }
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"parier-server/internal/middleware"
+	"parier-server/internal/models"
+	"parier-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APITokenHandler manages personal API tokens and service accounts
+type APITokenHandler struct {
+	service *service.APITokenService
+}
+
+func NewAPITokenHandler(s *service.APITokenService) *APITokenHandler {
+	return &APITokenHandler{service: s}
+}
+
+// CreateAPITokenRequest represents a request to issue an API token
+type CreateAPITokenRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required" example:"table:t_localization_word:VIEW"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateServiceAccountRequest represents a request to create a service account
+type CreateServiceAccountRequest struct {
+	Name  string   `json:"name" binding:"required"`
+	Roles []string `json:"roles"`
+}
+
+// APITokenListResponse represents a list of API tokens
+type APITokenListResponse struct {
+	models.SuccessResponse
+	Data []service.APITokenInfo `json:"data"`
+}
+
+// CreatedAPITokenResponse represents a new API token; the token itself is shown only once
+type CreatedAPITokenResponse struct {
+	models.SuccessResponse
+	Data service.CreatedAPIToken `json:"data"`
+}
+
+// ServiceAccountResponse represents a service account
+type ServiceAccountResponse struct {
+	models.SuccessResponse
+	Data service.ServiceAccountInfo `json:"data"`
+}
+
+// ServiceAccountListResponse represents a list of service accounts
+type ServiceAccountListResponse struct {
+	models.SuccessResponse
+	Data []service.ServiceAccountInfo `json:"data"`
+}
+
+// getRegisteredUser returns the logged in user; anonymous visitors get 401
+func getRegisteredUser(c *gin.Context) (*models.TSession, bool) {
+	session, err := middleware.GetSession(c)
+	if err != nil {
+		SendError(c, http.StatusUnauthorized, "Session not found", err.Error())
+		return nil, false
+	}
+	if session.CkUser == nil {
+		SendError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return nil, false
+	}
+	return session, true
+}
+
+// GetTokens godoc
+// @Summary List personal API tokens
+// @Description List active API tokens of the current user
+// @Tags tokens
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Success 200 {object} APITokenListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/tokens [get]
+func (h *APITokenHandler) GetTokens(c *gin.Context) {
+	session, ok := getRegisteredUser(c)
+	if !ok {
+		return
+	}
+	tokens, err := h.service.ListTokens(*session.CkUser)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Tokens retrieved successfully", tokens)
+}
+
+// CreateToken godoc
+// @Summary Create personal API token
+// @Description Issue an API token for the current user. Scopes look like table:<table>:<action> or property:<type>:<action> and must be allowed by the user's roles. The token is returned only once; send it as "Authorization: Bearer <token>"
+// @Tags tokens
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param request body CreateAPITokenRequest true "Token"
+// @Success 200 {object} CreatedAPITokenResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/tokens [post]
+func (h *APITokenHandler) CreateToken(c *gin.Context) {
+	session, ok := getRegisteredUser(c)
+	if !ok {
+		return
+	}
+	var req CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	token, err := h.service.CreateToken(*session.CkUser, req.Name, req.Scopes, req.ExpiresAt, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Token created successfully", token)
+}
+
+// DeleteToken godoc
+// @Summary Revoke personal API token
+// @Description Revoke an API token of the current user
+// @Tags tokens
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "Token ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/tokens/{id} [delete]
+func (h *APITokenHandler) DeleteToken(c *gin.Context) {
+	session, ok := getRegisteredUser(c)
+	if !ok {
+		return
+	}
+	id, err := GetUUIDParam(c, "id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid token ID", err.Error())
+		return
+	}
+	if err := h.service.RevokeToken(*session.CkUser, id, GetAuditActor(c)); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Token revoked successfully")
+}
+
+// GetServiceAccounts godoc
+// @Summary List service accounts
+// @Description List service accounts used by integrations
+// @Tags tokens
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Success 200 {object} ServiceAccountListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/service-accounts [get]
+func (h *APITokenHandler) GetServiceAccounts(c *gin.Context) {
+	accounts, err := h.service.ListServiceAccounts()
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Service accounts retrieved successfully", accounts)
+}
+
+// CreateServiceAccount godoc
+// @Summary Create service account
+// @Description Create a service account with the given roles; its tokens act within these roles
+// @Tags tokens
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param request body CreateServiceAccountRequest true "Service account"
+// @Success 200 {object} ServiceAccountResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/service-accounts [post]
+func (h *APITokenHandler) CreateServiceAccount(c *gin.Context) {
+	var req CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	account, err := h.service.CreateServiceAccount(req.Name, req.Roles, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Service account created successfully", account)
+}
+
+// DeleteServiceAccount godoc
+// @Summary Delete service account
+// @Description Delete a service account and revoke all of its tokens
+// @Tags tokens
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "Service account ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/service-accounts/{id} [delete]
+func (h *APITokenHandler) DeleteServiceAccount(c *gin.Context) {
+	id, err := GetUUIDParam(c, "id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid service account ID", err.Error())
+		return
+	}
+	if err := h.service.DeleteServiceAccount(id, GetAuditActor(c)); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Service account deleted successfully")
+}
+
+// GetServiceAccountTokens godoc
+// @Summary List service account tokens
+// @Description List active API tokens of a service account
+// @Tags tokens
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "Service account ID"
+// @Success 200 {object} APITokenListResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/service-accounts/{id}/tokens [get]
+func (h *APITokenHandler) GetServiceAccountTokens(c *gin.Context) {
+	account, ok := h.getServiceAccount(c)
+	if !ok {
+		return
+	}
+	tokens, err := h.service.ListTokens(account.ID)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Tokens retrieved successfully", tokens)
+}
+
+// CreateServiceAccountToken godoc
+// @Summary Create service account token
+// @Description Issue an API token for a service account. Scopes must be allowed by the account's roles. The token is returned only once
+// @Tags tokens
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "Service account ID"
+// @Param request body CreateAPITokenRequest true "Token"
+// @Success 200 {object} CreatedAPITokenResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/service-accounts/{id}/tokens [post]
+func (h *APITokenHandler) CreateServiceAccountToken(c *gin.Context) {
+	account, ok := h.getServiceAccount(c)
+	if !ok {
+		return
+	}
+	var req CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	token, err := h.service.CreateToken(account.ID, req.Name, req.Scopes, req.ExpiresAt, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Token created successfully", token)
+}
+
+// DeleteServiceAccountToken godoc
+// @Summary Revoke service account token
+// @Description Revoke an API token of a service account
+// @Tags tokens
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "Service account ID"
+// @Param token_id path string true "Token ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/service-accounts/{id}/tokens/{token_id} [delete]
+func (h *APITokenHandler) DeleteServiceAccountToken(c *gin.Context) {
+	account, ok := h.getServiceAccount(c)
+	if !ok {
+		return
+	}
+	tokenID, err := GetUUIDParam(c, "token_id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid token ID", err.Error())
+		return
+	}
+	if err := h.service.RevokeToken(account.ID, tokenID, GetAuditActor(c)); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Token revoked successfully")
+}
+
+func (h *APITokenHandler) getServiceAccount(c *gin.Context) (*service.ServiceAccountInfo, bool) {
+	id, err := GetUUIDParam(c, "id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid service account ID", err.Error())
+		return nil, false
+	}
+	account, err := h.service.GetServiceAccount(id)
+	if err != nil {
+		sendServiceError(c, err)
+		return nil, false
+	}
+	return account, true
+}
+
+func (h *APITokenHandler) RegisterRoutes(router *gin.RouterGroup) {
+	routes := middleware.Declare(router)
+	tokens := routes.Group("auth/tokens")
+	{
+		tokens.GET("", middleware.SessionAccess(), h.GetTokens)
+		tokens.POST("", middleware.SessionAccess(), h.CreateToken)
+		tokens.DELETE("/:id", middleware.SessionAccess(), h.DeleteToken)
+	}
+	accounts := routes.Group("admin/service-accounts")
+	{
+		accounts.GET("", middleware.TableAccess("t_api_token", models.ActionTypeView), h.GetServiceAccounts)
+		accounts.POST("", middleware.TableAccess("t_api_token", models.ActionTypeInsert), h.CreateServiceAccount)
+		accounts.DELETE("/:id", middleware.TableAccess("t_api_token", models.ActionTypeDelete), h.DeleteServiceAccount)
+		accounts.GET("/:id/tokens", middleware.TableAccess("t_api_token", models.ActionTypeView), h.GetServiceAccountTokens)
+		accounts.POST("/:id/tokens", middleware.TableAccess("t_api_token", models.ActionTypeInsert), h.CreateServiceAccountToken)
+		accounts.DELETE("/:id/tokens/:token_id", middleware.TableAccess("t_api_token", models.ActionTypeDelete), h.DeleteServiceAccountToken)
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"parier-server/internal/middleware"
+	"parier-server/internal/models"
+	"parier-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler exposes the audit log to administrators
+type AuditHandler struct {
+	service *service.AuditService
+}
+
+func NewAuditHandler(s *service.AuditService) *AuditHandler {
+	return &AuditHandler{service: s}
+}
+
+// AuditLogResponse represents a page of the audit log
+type AuditLogResponse struct {
+	models.PaginationResponse
+	Data []models.TAuditLog `json:"data"`
+}
+
+// AuditVerificationResponse represents the result of the hash chain check
+type AuditVerificationResponse struct {
+	models.SuccessResponse
+	Data service.AuditVerification `json:"data"`
+}
+
+// GetAuditLog godoc
+// @Summary Get audit log
+// @Description Get audit log entries, newest first. Before and after contain only the changed fields
+// @Tags audit
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param actor query string false "Actor user ID"
+// @Param action query string false "Action, e.g. wallet.deposit"
+// @Param entity_type query string false "Entity type (table name)"
+// @Param entity_id query string false "Entity ID"
+// @Param from query string false "From time (RFC 3339)"
+// @Param to query string false "To time, exclusive (RFC 3339)"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit"
+// @Success 200 {object} AuditLogResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/audit [get]
+func (h *AuditHandler) GetAuditLog(c *gin.Context) {
+	filter := models.AuditLogFilter{
+		Actor:      queryString(c, "actor"),
+		Action:     queryString(c, "action"),
+		EntityType: queryString(c, "entity_type"),
+		EntityID:   queryString(c, "entity_id"),
+	}
+	var err error
+	if filter.From, err = queryTime(c, "from"); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid from time", err.Error())
+		return
+	}
+	if filter.To, err = queryTime(c, "to"); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid to time", err.Error())
+		return
+	}
+	pagination := GetPaginationFromQuery(c)
+	entries, total, err := h.service.List(filter, pagination.Offset, pagination.Limit)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendPaginated(c, entries, len(entries), total)
+}
+
+// VerifyAuditLog godoc
+// @Summary Verify audit log
+// @Description Recompute the hash chain of the audit log and report the first tampered entry. Keep the returned head outside the database to detect removal of the latest entries
+// @Tags audit
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Success 200 {object} AuditVerificationResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/audit/verify [get]
+func (h *AuditHandler) VerifyAuditLog(c *gin.Context) {
+	result, err := h.service.Verify()
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Audit log verified", result)
+}
+
+func queryString(c *gin.Context, key string) *string {
+	value := c.Query(key)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func queryTime(c *gin.Context, key string) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	t = t.UTC()
+	return &t, nil
+}
+
+func (h *AuditHandler) RegisterRoutes(router *gin.RouterGroup) {
+	routes := middleware.Declare(router)
+	audit := routes.Group("admin/audit")
+	{
+		audit.GET("", middleware.TableAccess("t_audit_log", models.ActionTypeView), h.GetAuditLog)
+		audit.GET("/verify", middleware.TableAccess("t_audit_log", models.ActionTypeView), h.VerifyAuditLog)
+	}
+}
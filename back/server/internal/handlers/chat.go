@@ -0,0 +1,798 @@
+package handlers
+
+import (
+	"net/http"
+	"parier-server/internal/middleware"
+	"parier-server/internal/models"
+	"parier-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ChatResponse struct {
+	models.SuccessResponse
+	Data models.ChatResponse `json:"data"`
+}
+
+type ChatsResponse struct {
+	models.PaginationResponse
+	Data []models.ChatResponse `json:"data"`
+}
+
+type ChatMembersResponse struct {
+	models.SuccessResponse
+	Data []models.ChatMemberResponse `json:"data"`
+}
+
+type ChatMessagesResponse struct {
+	models.SuccessResponse
+	Data models.ChatMessagesResponse `json:"data"`
+}
+
+type ChatMessageResponse struct {
+	models.SuccessResponse
+	Data models.ChatMessageResponse `json:"data"`
+}
+
+type ChatBansResponse struct {
+	models.SuccessResponse
+	Data []models.ChatBanResponse `json:"data"`
+}
+
+type ChatBanResponse struct {
+	models.SuccessResponse
+	Data models.ChatBanResponse `json:"data"`
+}
+
+type ChatInvitesResponse struct {
+	models.SuccessResponse
+	Data []models.ChatInviteResponse `json:"data"`
+}
+
+type ChatInviteResponse struct {
+	models.SuccessResponse
+	Data models.ChatInviteResponse `json:"data"`
+}
+
+type ChatHandler struct {
+	service *service.ChatService
+}
+
+func NewChatHandler(s *service.ChatService) *ChatHandler {
+	return &ChatHandler{service: s}
+}
+
+// GetBetChat godoc
+// @Summary Get bet chat
+// @Description Get the public chat room of a bet, creating it on first request
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param bet_id path string true "Bet ID"
+// @Success 200 {object} ChatResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/bet/{bet_id}/chat [post]
+func (h *ChatHandler) PostBetChat(c *gin.Context) {
+	var req models.DefaultRequest
+	betID := GetUUID(c, "bet_id")
+	if betID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Bet ID is required", "Bet ID is required")
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	chat, err := h.service.GetBetChat(betID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Chat fetched successfully", chat)
+}
+
+// OpenDirectChat godoc
+// @Summary Open direct chat
+// @Description Get the private chat of the current user with another user, creating it on first request
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param request body models.ChatDirectRequest true "Request"
+// @Success 200 {object} ChatResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/direct [put]
+func (h *ChatHandler) PutDirectChat(c *gin.Context) {
+	var req models.ChatDirectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	chat, err := h.service.OpenDirectChat(req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Chat opened successfully", chat)
+}
+
+// CreateChat godoc
+// @Summary Create group chat
+// @Description Create a private group chat. The creator becomes its admin; others join with invitations
+// @Tags chat
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Success 200 {object} ChatResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat [put]
+func (h *ChatHandler) PutChat(c *gin.Context) {
+	var req models.DefaultRequest
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	chat, err := h.service.CreateChat(req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Chat created successfully", chat)
+}
+
+// GetChats godoc
+// @Summary Get my chats
+// @Description Get chats the current user is a member of, most recently active first
+// @Tags chat
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit"
+// @Success 200 {object} ChatsResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chats [get]
+func (h *ChatHandler) GetChats(c *gin.Context) {
+	user := GetUser(c)
+	if user == nil {
+		SendError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return
+	}
+	pagination := GetPaginationFromQuery(c)
+	chats, total, err := h.service.GetChats(user.ID, pagination.Offset, pagination.Limit)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendPaginated(c, chats, len(chats), total)
+}
+
+// GetChat godoc
+// @Summary Get chat
+// @Description Get a chat. Public chats are visible to everyone, private ones only to their members
+// @Tags chat
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param chat_id path string true "Chat ID"
+// @Success 200 {object} ChatResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/{chat_id} [get]
+func (h *ChatHandler) GetChat(c *gin.Context) {
+	var req models.DefaultRequest
+	chatID := GetUUID(c, "chat_id")
+	if chatID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Chat ID is required", "Chat ID is required")
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	chat, err := h.service.GetChat(chatID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Chat fetched successfully", chat)
+}
+
+// JoinChat godoc
+// @Summary Join chat
+// @Description Join a public chat, or a private group chat with the credentials of an invitation. Banned users cannot join. A user has 5 attempts to join a chat by invitation per 15 minutes
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param chat_id path string true "Chat ID"
+// @Param request body models.ChatJoinRequest false "Request"
+// @Success 200 {object} ChatResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/{chat_id}/join [post]
+func (h *ChatHandler) PostJoinChat(c *gin.Context) {
+	var req models.ChatJoinRequest
+	chatID := GetUUID(c, "chat_id")
+	if chatID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Chat ID is required", "Chat ID is required")
+		return
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+			return
+		}
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	chat, err := h.service.JoinChat(chatID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Chat joined successfully", chat)
+}
+
+// LeaveChat godoc
+// @Summary Leave chat
+// @Description Leave a chat. Direct chats cannot be left
+// @Tags chat
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param chat_id path string true "Chat ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/{chat_id}/leave [post]
+func (h *ChatHandler) PostLeaveChat(c *gin.Context) {
+	var req models.DefaultRequest
+	chatID := GetUUID(c, "chat_id")
+	if chatID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Chat ID is required", "Chat ID is required")
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	left, err := h.service.LeaveChat(chatID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Chat left successfully", left)
+}
+
+// GetChatMembers godoc
+// @Summary Get chat members
+// @Description Get members of a chat in the order they joined
+// @Tags chat
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param chat_id path string true "Chat ID"
+// @Success 200 {object} ChatMembersResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/{chat_id}/members [get]
+func (h *ChatHandler) GetChatMembers(c *gin.Context) {
+	var req models.DefaultRequest
+	chatID := GetUUID(c, "chat_id")
+	if chatID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Chat ID is required", "Chat ID is required")
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	members, err := h.service.GetChatMembers(chatID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Chat members fetched successfully", members)
+}
+
+// SetChatModerator godoc
+// @Summary Set chat moderator
+// @Description Appoint a chat member as moderator or remove the role. Allowed to chat admins and to users with UPDATE access to t_chat_user_ban
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param chat_id path string true "Chat ID"
+// @Param request body models.ChatModeratorRequest true "Request"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/{chat_id}/moderator [post]
+func (h *ChatHandler) PostChatModerator(c *gin.Context) {
+	var req models.ChatModeratorRequest
+	chatID := GetUUID(c, "chat_id")
+	if chatID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Chat ID is required", "Chat ID is required")
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	moderator, err := chatModerator(c)
+	if err != nil {
+		SendError(c, http.StatusInternalServerError, "Failed to load permissions", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	updated, err := h.service.SetChatModerator(chatID, req, moderator, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Chat moderator updated successfully", updated)
+}
+
+// BanChatUser godoc
+// @Summary Ban or mute chat user
+// @Description Ban (leave the chat, no joining, messages or likes) or mute (no messages) a user until the given time or permanently. A new restriction replaces the previous one. Chat moderators cannot restrict admins and other moderators; users with UPDATE access to t_chat_user_ban may restrict anyone
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param chat_id path string true "Chat ID"
+// @Param request body models.ChatBanRequest true "Request"
+// @Success 200 {object} ChatBanResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/{chat_id}/ban [post]
+func (h *ChatHandler) PostBanChatUser(c *gin.Context) {
+	var req models.ChatBanRequest
+	chatID := GetUUID(c, "chat_id")
+	if chatID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Chat ID is required", "Chat ID is required")
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	moderator, err := chatModerator(c)
+	if err != nil {
+		SendError(c, http.StatusInternalServerError, "Failed to load permissions", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	ban, err := h.service.BanChatUser(chatID, req, moderator, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Chat user restricted successfully", ban)
+}
+
+// UnbanChatUser godoc
+// @Summary Unban chat user
+// @Description Lift the ban or mute of a user
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param chat_id path string true "Chat ID"
+// @Param request body models.ChatUnbanRequest true "Request"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/{chat_id}/unban [post]
+func (h *ChatHandler) PostUnbanChatUser(c *gin.Context) {
+	var req models.ChatUnbanRequest
+	chatID := GetUUID(c, "chat_id")
+	if chatID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Chat ID is required", "Chat ID is required")
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	moderator, err := chatModerator(c)
+	if err != nil {
+		SendError(c, http.StatusInternalServerError, "Failed to load permissions", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	unbanned, err := h.service.UnbanChatUser(chatID, req, moderator, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Chat user unbanned successfully", unbanned)
+}
+
+// GetChatBans godoc
+// @Summary Get chat bans
+// @Description Get current and scheduled bans and mutes of a chat
+// @Tags chat
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param chat_id path string true "Chat ID"
+// @Success 200 {object} ChatBansResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/{chat_id}/bans [get]
+func (h *ChatHandler) GetChatBans(c *gin.Context) {
+	var req models.DefaultRequest
+	chatID := GetUUID(c, "chat_id")
+	if chatID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Chat ID is required", "Chat ID is required")
+		return
+	}
+	moderator, err := chatModerator(c)
+	if err != nil {
+		SendError(c, http.StatusInternalServerError, "Failed to load permissions", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	bans, err := h.service.GetChatBans(chatID, req, moderator)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Chat bans fetched successfully", bans)
+}
+
+// CreateChatInvite godoc
+// @Summary Create chat invitation
+// @Description Create an invitation to a private group chat. TOKEN and API_KEY credentials are generated and returned only in this response; PASSWORD takes the password from the request; a chat has at most 5 active PASSWORD invitations. An invitation is valid from start to until and, if one_time is set, admits a single user
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param chat_id path string true "Chat ID"
+// @Param request body models.ChatInviteCreateRequest true "Request"
+// @Success 200 {object} ChatInviteResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/{chat_id}/invite [put]
+func (h *ChatHandler) PutChatInvite(c *gin.Context) {
+	var req models.ChatInviteCreateRequest
+	chatID := GetUUID(c, "chat_id")
+	if chatID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Chat ID is required", "Chat ID is required")
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	moderator, err := chatModerator(c)
+	if err != nil {
+		SendError(c, http.StatusInternalServerError, "Failed to load permissions", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	invite, err := h.service.CreateChatInvite(chatID, req, moderator)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Chat invitation created successfully", invite)
+}
+
+// GetChatInvites godoc
+// @Summary Get chat invitations
+// @Description Get invitations of a chat that are not revoked or used, including expired ones. Credentials are not returned
+// @Tags chat
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param chat_id path string true "Chat ID"
+// @Success 200 {object} ChatInvitesResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/{chat_id}/invites [get]
+func (h *ChatHandler) GetChatInvites(c *gin.Context) {
+	var req models.DefaultRequest
+	chatID := GetUUID(c, "chat_id")
+	if chatID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Chat ID is required", "Chat ID is required")
+		return
+	}
+	moderator, err := chatModerator(c)
+	if err != nil {
+		SendError(c, http.StatusInternalServerError, "Failed to load permissions", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	invites, err := h.service.GetChatInvites(chatID, req, moderator)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Chat invitations fetched successfully", invites)
+}
+
+// RevokeChatInvite godoc
+// @Summary Revoke chat invitation
+// @Description Revoke an invitation so that it no longer admits users
+// @Tags chat
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param invite_id path string true "Invitation ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/invite/{invite_id}/revoke [post]
+func (h *ChatHandler) PostRevokeChatInvite(c *gin.Context) {
+	var req models.DefaultRequest
+	inviteID := GetUUID(c, "invite_id")
+	if inviteID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Invitation ID is required", "Invitation ID is required")
+		return
+	}
+	moderator, err := chatModerator(c)
+	if err != nil {
+		SendError(c, http.StatusInternalServerError, "Failed to load permissions", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	revoked, err := h.service.RevokeChatInvite(inviteID, req, moderator)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Chat invitation revoked successfully", revoked)
+}
+
+// chatModerator сообщает, модерирует ли посетитель все чаты сайта, а не только те, где он
+// администратор или модератор
+func chatModerator(c *gin.Context) (bool, error) {
+	permissions, err := middleware.RequestPermissions(c)
+	if err != nil {
+		return false, err
+	}
+	if token := middleware.GetAPIToken(c); token != nil && !token.Allows(models.TokenScopeTable, "t_chat_user_ban", models.ActionTypeUpdate) {
+		return false, nil
+	}
+	return permissions.CanTable("t_chat_user_ban", models.ActionTypeUpdate), nil
+}
+
+// GetChatMessages godoc
+// @Summary Get chat messages
+// @Description Get a page of chat history, newest first. If has_more is set, pass next_cursor as cursor to load older messages
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param chat_id path string true "Chat ID"
+// @Param request body models.ChatMessagesRequest true "Request"
+// @Success 200 {object} ChatMessagesResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/{chat_id}/messages [post]
+func (h *ChatHandler) PostChatMessages(c *gin.Context) {
+	var req models.ChatMessagesRequest
+	chatID := GetUUID(c, "chat_id")
+	if chatID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Chat ID is required", "Chat ID is required")
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	messages, err := h.service.GetChatMessages(chatID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Messages fetched successfully", messages)
+}
+
+// SendChatMessage godoc
+// @Summary Send chat message
+// @Description Send a message or a reply to a message of the same chat. Sending to a public chat joins it; private chats accept messages only from members. Subscribers of chat:{id} receive a chat.message event
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param chat_id path string true "Chat ID"
+// @Param request body models.ChatMessageCreateRequest true "Request"
+// @Success 200 {object} ChatMessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/{chat_id}/message [put]
+func (h *ChatHandler) PutChatMessage(c *gin.Context) {
+	var req models.ChatMessageCreateRequest
+	chatID := GetUUID(c, "chat_id")
+	if chatID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Chat ID is required", "Chat ID is required")
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	message, err := h.service.SendChatMessage(chatID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Message sent successfully", message)
+}
+
+// LikeChatMessage godoc
+// @Summary Like chat message
+// @Description Like a chat message or change the like type
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param message_id path string true "Message ID"
+// @Param request body models.ChatMessageLikeRequest false "Request"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/message/{message_id}/like [post]
+func (h *ChatHandler) PostLikeChatMessage(c *gin.Context) {
+	var req models.ChatMessageLikeRequest
+	messageID := GetUUID(c, "message_id")
+	if messageID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Message ID is required", "Message ID is required")
+		return
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+			return
+		}
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	liked, err := h.service.LikeChatMessage(messageID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Message liked successfully", liked)
+}
+
+// UnlikeChatMessage godoc
+// @Summary Unlike chat message
+// @Description Remove the like of the current user from a chat message
+// @Tags chat
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param message_id path string true "Message ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/chat/message/{message_id}/unlike [post]
+func (h *ChatHandler) PostUnlikeChatMessage(c *gin.Context) {
+	var req models.DefaultRequest
+	messageID := GetUUID(c, "message_id")
+	if messageID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Message ID is required", "Message ID is required")
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	unliked, err := h.service.UnlikeChatMessage(messageID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Message unliked successfully", unliked)
+}
+
+// RegisterRoutes registers bet chat and direct message routes
+func (h *ChatHandler) RegisterRoutes(router *gin.RouterGroup) {
+	chat := middleware.Declare(router).Group("/parier")
+	{
+		chat.POST("/bet/:bet_id/chat", middleware.TableAccess("t_chat", models.ActionTypeView), h.PostBetChat)
+		chat.PUT("/chat", middleware.TableAccess("t_chat", models.ActionTypeInsert), h.PutChat)
+		chat.PUT("/chat/direct", middleware.TableAccess("t_chat", models.ActionTypeInsert), h.PutDirectChat)
+		chat.GET("/chats", middleware.SessionAccess(), h.GetChats)
+		chat.GET("/chat/:chat_id", middleware.TableAccess("t_chat", models.ActionTypeView), h.GetChat)
+		chat.POST("/chat/:chat_id/join", middleware.TableAccess("t_chat_user", models.ActionTypeInsert), h.PostJoinChat)
+		chat.POST("/chat/:chat_id/leave", middleware.TableAccess("t_chat_user", models.ActionTypeDelete), h.PostLeaveChat)
+		chat.GET("/chat/:chat_id/members", middleware.TableAccess("t_chat", models.ActionTypeView), h.GetChatMembers)
+		// права в конкретном чате (администратор, модератор) проверяет сервис, см. chatModerator
+		chat.POST("/chat/:chat_id/moderator", middleware.TableAccess("t_chat_user", models.ActionTypeUpdate), h.PostChatModerator)
+		chat.POST("/chat/:chat_id/ban", middleware.TableAccess("t_chat_user_ban", models.ActionTypeInsert), h.PostBanChatUser)
+		chat.POST("/chat/:chat_id/unban", middleware.TableAccess("t_chat_user_ban", models.ActionTypeDelete), h.PostUnbanChatUser)
+		chat.GET("/chat/:chat_id/bans", middleware.TableAccess("t_chat_user_ban", models.ActionTypeView), h.GetChatBans)
+		chat.PUT("/chat/:chat_id/invite", middleware.TableAccess("t_chat_credentials", models.ActionTypeInsert), h.PutChatInvite)
+		chat.GET("/chat/:chat_id/invites", middleware.TableAccess("t_chat_credentials", models.ActionTypeView), h.GetChatInvites)
+		chat.POST("/chat/invite/:invite_id/revoke", middleware.TableAccess("t_chat_credentials", models.ActionTypeDelete), h.PostRevokeChatInvite)
+		chat.POST("/chat/:chat_id/messages", middleware.TableAccess("t_chat_message", models.ActionTypeView), h.PostChatMessages)
+		chat.PUT("/chat/:chat_id/message", middleware.TableAccess("t_chat_message", models.ActionTypeInsert), h.PutChatMessage)
+		chat.POST("/chat/message/:message_id/like", middleware.TableAccess("t_chat_message_like", models.ActionTypeInsert), h.PostLikeChatMessage)
+		chat.POST("/chat/message/:message_id/unlike", middleware.TableAccess("t_chat_message_like", models.ActionTypeDelete), h.PostUnlikeChatMessage)
+	}
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"parier-server/internal/config"
 	"parier-server/internal/middleware"
@@ -17,12 +19,13 @@ import (
 )
 
 type CoreHandler struct {
-	coreService *service.CoreService
-	config      *config.Config
+	coreService         *service.CoreService
+	localizationService *service.LocalizationService
+	config              *config.Config
 }
 
-func NewCoreHandler(coreService *service.CoreService, config *config.Config) *CoreHandler {
-	return &CoreHandler{coreService: coreService, config: config}
+func NewCoreHandler(coreService *service.CoreService, localizationService *service.LocalizationService, config *config.Config) *CoreHandler {
+	return &CoreHandler{coreService: coreService, localizationService: localizationService, config: config}
 }
 
 // === REQUEST AND RESPONSE STRUCTURES ===
@@ -55,6 +58,11 @@ type PropertiesEnumsResponse struct {
 	Total int64                    `json:"total"`
 }
 
+type LocaleImportResponse struct {
+	models.SuccessResponse
+	Data service.LocaleImportResult `json:"data"`
+}
+
 // === ENDPOINTS ===
 
 // GetPropertiesTypes godoc
@@ -85,12 +93,17 @@ func (h *CoreHandler) GetPropertiesTypes(c *gin.Context) {
 		SendError(c, http.StatusInternalServerError, "Internal server error", err.Error())
 		return
 	}
+	ids := make([]*string, 0, len(propertiesTypes)*2)
+	for i := range propertiesTypes {
+		ids = append(ids, &propertiesTypes[i].CkName, propertiesTypes[i].CkDescription)
+	}
+	texts := h.coreService.Resolver.Resolve(query.Language, ids...)
 	response := make([]PropertiesTypeResponse, len(propertiesTypes))
 	for i, propertiesType := range propertiesTypes {
 		response[i] = PropertiesTypeResponse{
 			ID:          strings.ToLower(propertiesType.CkId),
-			Name:        *h.coreService.LocRepo.GetWordOrDefault(&propertiesType.CkName, query.Language),
-			Description: h.coreService.LocRepo.GetWordOrDefault(propertiesType.CkDescription, query.Language),
+			Name:        texts.Text(propertiesType.CkName),
+			Description: texts.Get(propertiesType.CkDescription),
 			Type:        propertiesType.CrType,
 			Place:       propertiesType.CrPlace,
 		}
@@ -127,10 +140,11 @@ func (h *CoreHandler) GetPropertiesType(c *gin.Context) {
 		SendError(c, http.StatusInternalServerError, "Internal server error", err.Error())
 		return
 	}
+	texts := h.coreService.Resolver.Resolve(req.Language, &propertiesType.CkName, propertiesType.CkDescription)
 	response := PropertiesTypeResponse{
 		ID:          strings.ToLower(propertiesType.CkId),
-		Name:        *h.coreService.LocRepo.GetWordOrDefault(&propertiesType.CkName, req.Language),
-		Description: h.coreService.LocRepo.GetWordOrDefault(propertiesType.CkDescription, req.Language),
+		Name:        texts.Text(propertiesType.CkName),
+		Description: texts.Get(propertiesType.CkDescription),
 		Type:        propertiesType.CrType,
 		Place:       propertiesType.CrPlace,
 	}
@@ -164,13 +178,18 @@ func (h *CoreHandler) GetPropertiesEnums(c *gin.Context) {
 		SendError(c, http.StatusInternalServerError, "Internal server error", err.Error())
 		return
 	}
+	ids := make([]*string, 0, len(propertiesEnums)*3)
+	for i := range propertiesEnums {
+		ids = append(ids, &propertiesEnums[i].CkName, propertiesEnums[i].CkDescription, propertiesEnums[i].CkLocalization)
+	}
+	texts := h.coreService.Resolver.Resolve(req.Language, ids...)
 	response := make([]PropertiesEnumResponse, len(propertiesEnums))
 	for i, propertiesEnum := range propertiesEnums {
-		value, valueID := h.getPropertyValueEnum(propertiesEnum, req.Language)
+		value, valueID := h.getPropertyValueEnum(propertiesEnum, texts)
 		response[i] = PropertiesEnumResponse{
 			ID:           propertiesEnum.CkId,
-			Name:         *h.coreService.LocRepo.GetWordOrDefault(&propertiesEnum.CkName, req.Language),
-			Description:  h.coreService.LocRepo.GetWordOrDefault(propertiesEnum.CkDescription, req.Language),
+			Name:         texts.Text(propertiesEnum.CkName),
+			Description:  texts.Get(propertiesEnum.CkDescription),
 			Property:     propertiesEnum.CkPropertyType,
 			PropertyType: propertiesEnum.CrValueType,
 			Value:        value,
@@ -233,10 +252,105 @@ func (h *CoreHandler) GetLocales(c *gin.Context) {
 	c.JSON(http.StatusOK, locales)
 }
 
+// ExportLocales godoc
+// @Summary Export locales
+// @Description Export a namespace translation bundle as i18next JSON, gettext PO or XLIFF 1.2
+// @Tags core
+// @Produce json
+// @Produce plain
+// @Produce xml
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param lang path string true "Language"
+// @Param ns path string true "Namespace"
+// @Param format query string false "Format (json, po, xliff)"
+// @Success 200 {file} file
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /core/locales/{lang}/{ns}/export [get]
+func (h *CoreHandler) ExportLocales(c *gin.Context) {
+	lang := strings.ToUpper(c.Param("lang"))
+	ns := strings.ToUpper(c.Param("ns"))
+	format, err := service.ParseLocaleFormat(c.Query("format"))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	content, err := h.localizationService.ExportLocales(lang, ns, format)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	filename := fmt.Sprintf("%s.%s.%s", strings.ToLower(lang), strings.ToLower(ns), format.Extension())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, format.ContentType(), content)
+}
+
+// ImportLocales godoc
+// @Summary Import locales
+// @Description Import a translation bundle (raw body or multipart "file"). Reports added, changed and conflicting keys; with dry_run nothing is written, otherwise changes are applied atomically. Keys missing from the namespace are reported as UNKNOWN_KEY conflicts unless create_keys is set
+// @Tags core
+// @Accept json
+// @Accept plain
+// @Accept xml
+// @Accept mpfd
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param lang path string true "Language"
+// @Param ns path string true "Namespace"
+// @Param format query string false "Format (json, po, xliff)"
+// @Param dry_run query bool false "Only report the diff"
+// @Param create_keys query bool false "Create keys missing from the namespace"
+// @Param file formData file false "Bundle file"
+// @Success 200 {object} LocaleImportResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /core/locales/{lang}/{ns}/import [put]
+func (h *CoreHandler) ImportLocales(c *gin.Context) {
+	lang := strings.ToUpper(c.Param("lang"))
+	ns := strings.ToUpper(c.Param("ns"))
+	format, err := service.ParseLocaleFormat(c.Query("format"))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	user := GetUser(c)
+	if user == nil {
+		SendError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return
+	}
+	content, err := readLocaleBundle(c, h.config.Locales.MaxImportSize)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		SendError(c, http.StatusRequestEntityTooLarge, "Bundle too large", fmt.Sprintf("Bundle exceeds %d bytes", tooLarge.Limit))
+		return
+	}
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+	createKeys := c.Query("create_keys") == "true"
+	result, err := h.localizationService.ImportLocales(lang, ns, format, content, dryRun, createKeys, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Locales imported successfully", result)
+}
+
 // === HELPER METHODS ===
 
 // getPropertyValueEnum converts property enum model to response format
-func (h *CoreHandler) getPropertyValueEnum(prop models.TDPropertiesEnum, lang *string) (interface{}, *uuid.UUID) {
+func (h *CoreHandler) getPropertyValueEnum(prop models.TDPropertiesEnum, texts repository.LocalizedTexts) (interface{}, *uuid.UUID) {
 	var value interface{}
 	var valueID *uuid.UUID
 
@@ -254,7 +368,7 @@ func (h *CoreHandler) getPropertyValueEnum(prop models.TDPropertiesEnum, lang *s
 		value = *prop.CkMedia
 		valueID = prop.CkMedia
 	case models.PropertyTypeLocalization:
-		value = h.coreService.LocRepo.GetWordOrDefault(prop.CkLocalization, lang)
+		value = texts.Get(prop.CkLocalization)
 	case models.PropertyTypeText:
 		value = *prop.CvText
 	case models.PropertyTypeJSONArray:
@@ -278,13 +392,34 @@ func (h *CoreHandler) getPropertyValueEnum(prop models.TDPropertiesEnum, lang *s
 	return value, valueID
 }
 
+// readLocaleBundle reads the uploaded bundle from a multipart "file" field or the raw body.
+// The whole request body is limited to limit bytes
+func readLocaleBundle(c *gin.Context, limit int64) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return io.ReadAll(file)
+	}
+	return io.ReadAll(c.Request.Body)
+}
+
 // RegisterRoutes registers all core routes
 func (h *CoreHandler) RegisterRoutes(router *gin.RouterGroup) {
-	core := router.Group("/core")
+	core := middleware.Declare(router).Group("/core")
 	{
-		core.POST("/properties-types", middleware.RequireTableAccess("t_d_properties_type", models.ActionTypeView), h.GetPropertiesTypes)
-		core.POST("/properties-types/:id", middleware.RequireTableAccess("t_d_properties_type", models.ActionTypeView), h.GetPropertiesType)
-		core.POST("/properties-enums", middleware.RequireTableAccess("t_d_properties_enum", models.ActionTypeView), h.GetPropertiesEnums)
-		core.GET("/locales/:lang/:ns", middleware.RequireTableAccess("t_localization_word", models.ActionTypeView), h.GetLocales)
+		core.POST("/properties-types", middleware.TableAccess("t_d_properties_type", models.ActionTypeView), h.GetPropertiesTypes)
+		core.POST("/properties-types/:id", middleware.TableAccess("t_d_properties_type", models.ActionTypeView), h.GetPropertiesType)
+		core.POST("/properties-enums", middleware.TableAccess("t_d_properties_enum", models.ActionTypeView), h.GetPropertiesEnums)
+		core.GET("/locales/:lang/:ns", middleware.TableAccess("t_localization_word", models.ActionTypeView), h.GetLocales)
+		core.GET("/locales/:lang/:ns/export", middleware.TableAccess("t_localization_word", models.ActionTypeView), h.ExportLocales)
+		core.PUT("/locales/:lang/:ns/import", middleware.TableAccess("t_localization_word", models.ActionTypeUpdate), h.ImportLocales)
 	}
 }
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"parier-server/internal/config"
+	"parier-server/internal/middleware"
+	"parier-server/internal/models"
+	"parier-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
+)
+
+type EventHandler struct {
+	hub      *service.EventHub
+	cfg      config.EventsConfig
+	frontend string
+}
+
+func NewEventHandler(hub *service.EventHub, cfg *config.Config) *EventHandler {
+	events := cfg.Events
+	if events.KeepAlive <= 0 {
+		events.KeepAlive = 25 * time.Second
+	}
+	return &EventHandler{hub: hub, cfg: events, frontend: cfg.Frontend.BaseURL}
+}
+
+// GetEventStream godoc
+// @Summary Subscribe to events over SSE
+// @Description Server-sent events of the given topics. Topics: bets (new bets), bet:{id} (comments and likes of a bet), chat:{id} (messages of a chat the user can read), replies, wallet and notifications (of the current user). The first event is "subscribed"; "resync" means events may have been lost and data should be reloaded
+// @Tags events
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param topic query []string true "Topics, repeated or comma separated" collectionFormat(multi)
+// @Success 200 {object} models.Event
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /events/sse [get]
+func (h *EventHandler) GetEventStream(c *gin.Context) {
+	sub, ok := h.subscribe(c)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+	// поток живёт дольше WRITE_TIMEOUT сервера
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	keepAlive := time.NewTicker(h.cfg.KeepAlive)
+	defer keepAlive.Stop()
+	c.SSEvent(service.EventSubscribed, subscribedEvent(sub))
+	c.Writer.Flush()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-sub.Events():
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		}
+	})
+}
+
+// GetEventSocket godoc
+// @Summary Subscribe to events over WebSocket
+// @Description WebSocket with events of the given topics (see /events/sse). The client changes topics with {"action":"subscribe"|"unsubscribe","topics":[...]}; the server answers with a "subscribed" or "error" event
+// @Tags events
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param topic query []string false "Initial topics, repeated or comma separated" collectionFormat(multi)
+// @Success 101 {object} models.Event
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /events/ws [get]
+func (h *EventHandler) GetEventSocket(c *gin.Context) {
+	sub, ok := h.subscribe(c)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+	server := websocket.Server{
+		Handshake: func(_ *websocket.Config, r *http.Request) error {
+			return checkEventOrigin(r, h.frontend)
+		},
+		Handler: func(ws *websocket.Conn) {
+			h.serveSocket(ws, sub)
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+func (h *EventHandler) serveSocket(ws *websocket.Conn, sub *service.Subscription) {
+	defer ws.Close()
+	// у перехваченного соединения остаются таймауты HTTP сервера
+	_ = ws.SetDeadline(time.Time{})
+	if websocket.JSON.Send(ws, subscribedEvent(sub)) != nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			var message string
+			if err := websocket.Message.Receive(ws, &message); err != nil {
+				return
+			}
+			// отправка в websocket.Conn защищена его собственной блокировкой
+			if websocket.JSON.Send(ws, h.command(sub, message)) != nil {
+				return
+			}
+		}
+	}()
+	keepAlive := time.NewTicker(h.cfg.KeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-sub.Events():
+			if !ok || websocket.JSON.Send(ws, event) != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if websocket.JSON.Send(ws, models.Event{ID: uuid.New(), Type: service.EventPing, CreatedAt: time.Now()}) != nil {
+				return
+			}
+		}
+	}
+}
+
+// command выполняет команду клиента WebSocket и возвращает ответ на неё
+func (h *EventHandler) command(sub *service.Subscription, message string) models.Event {
+	var cmd models.EventCommand
+	err := json.Unmarshal([]byte(message), &cmd)
+	if err == nil {
+		switch cmd.Action {
+		case "subscribe":
+			err = sub.Add(cmd.Topics...)
+		case "unsubscribe":
+			err = sub.Remove(cmd.Topics...)
+		default:
+			err = fmt.Errorf("unknown action %q", cmd.Action)
+		}
+	}
+	if err != nil {
+		return models.Event{ID: uuid.New(), Type: service.EventError, Data: map[string]any{"message": err.Error()}, CreatedAt: time.Now()}
+	}
+	return subscribedEvent(sub)
+}
+
+// subscribe создаёт подписку на темы из запроса или отвечает ошибкой
+func (h *EventHandler) subscribe(c *gin.Context) (*service.Subscription, bool) {
+	if h.hub == nil {
+		SendError(c, http.StatusServiceUnavailable, "Event stream is disabled", "Event stream is disabled")
+		return nil, false
+	}
+	var userID *uuid.UUID
+	if user := GetUser(c); user != nil {
+		userID = &user.ID
+	}
+	var topics []string
+	for _, value := range c.QueryArray("topic") {
+		topics = append(topics, strings.Split(value, ",")...)
+	}
+	sub := h.hub.Subscribe(userID)
+	if err := sub.Add(topics...); err != nil {
+		sub.Close()
+		sendServiceError(c, err)
+		return nil, false
+	}
+	return sub, true
+}
+
+func subscribedEvent(sub *service.Subscription) models.Event {
+	return models.Event{
+		ID:        uuid.New(),
+		Type:      service.EventSubscribed,
+		Data:      map[string]any{"topics": sub.Topics()},
+		CreatedAt: time.Now(),
+	}
+}
+
+// checkEventOrigin не даёт чужим сайтам открыть WebSocket с cookie сессии пользователя.
+// Разрешены клиенты без Origin, тот же хост и адрес фронтенда
+func checkEventOrigin(r *http.Request, frontend string) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+	if f, err := url.Parse(frontend); err == nil && f.Host != "" && strings.EqualFold(u.Host, f.Host) {
+		return nil
+	}
+	return fmt.Errorf("origin %s is not allowed", origin)
+}
+
+// RegisterRoutes registers real-time event stream routes
+func (h *EventHandler) RegisterRoutes(router *gin.RouterGroup) {
+	events := middleware.Declare(router).Group("events")
+	{
+		events.GET("/sse", middleware.TableAccess("t_bet", models.ActionTypeView), h.GetEventStream)
+		events.GET("/ws", middleware.TableAccess("t_bet", models.ActionTypeView), h.GetEventSocket)
+	}
+}
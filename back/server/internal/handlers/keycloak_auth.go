@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"parier-server/internal/config"
 	"parier-server/internal/middleware"
@@ -16,12 +17,14 @@ import (
 
 type KeycloakAuthHandler struct {
 	keycloakService *service.KeycloakService
+	activityService *service.ActivityService
 	config          *config.Config
 }
 
-func NewKeycloakAuthHandler(keycloakService *service.KeycloakService, config *config.Config) *KeycloakAuthHandler {
+func NewKeycloakAuthHandler(keycloakService *service.KeycloakService, activityService *service.ActivityService, config *config.Config) *KeycloakAuthHandler {
 	return &KeycloakAuthHandler{
 		keycloakService: keycloakService,
+		activityService: activityService,
 		config:          config,
 	}
 }
@@ -73,9 +76,10 @@ type TenantCreateResponse struct {
 }
 
 type LoginCodeRequest struct {
-	Code        string `json:"code" binding:"required"`
-	Iss         string `json:"iss" binding:"required"`
-	RedirectUri string `json:"redirect_uri" binding:"required"`
+	Code         string `json:"code" binding:"required"`
+	Iss          string `json:"iss" binding:"required"`
+	RedirectUri  string `json:"redirect_uri" binding:"required"`
+	ReferralCode string `json:"referral_code,omitempty"` // applied only if the account is registered by this login
 }
 
 type ProfileResponse struct {
@@ -91,10 +95,24 @@ type ProfileResponse struct {
 	UpdatedAt time.Time  `json:"updated_at"`
 }
 
+// SessionListResponse represents the list of the current user's sessions
+type SessionListResponse struct {
+	models.SuccessResponse
+	Data []service.SessionInfo `json:"data"`
+}
+
+// RevokedSessionsResponse represents the number of sessions that were logged out
+type RevokedSessionsResponse struct {
+	models.SuccessResponse
+	Data struct {
+		Revoked int `json:"revoked"`
+	} `json:"data"`
+}
+
 // LoginCode godoc
 
 // @Summary Login via Keycloak
-// @Description Authenticate user through Keycloak and return tokens
+// @Description Authenticate user through Keycloak and return tokens. Activity of the anonymous visitor (viewed bets, drafts, language, referral code) is moved to the user. The referral code from the request or the visitor's state links a newly registered user to the referrer
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -116,14 +134,25 @@ func (h *KeycloakAuthHandler) LoginCode(c *gin.Context) {
 		return
 	}
 
+	// Активность анонимного посетителя переносится пользователю после входа
+	visitor := service.VisitorFromSession(session)
+	if visitor.Anonymous && req.ReferralCode != "" {
+		if err := h.activityService.SetReferralCode(visitor, req.ReferralCode); err != nil {
+			log.Printf("Failed to set referral code of visitor %s: %v", visitor.ID, err)
+		}
+	}
+
 	userNew, err := h.keycloakService.GetCode(c, req.Code, req.Iss, req.RedirectUri, session)
 	if err != nil {
 		SendError(c, http.StatusUnauthorized, "Internal server error", err.Error())
 		return
 	}
+	if visitor.Anonymous {
+		h.activityService.MergeAnonymous(visitor.ID, userNew.ID, GetAuditActor(c))
+	}
 	profileResponse := ProfileResponse{
 		Id:        session.CkId,
-		UserId:    session.CkUser,
+		UserId:    &userNew.ID,
 		Username:  userNew.Username,
 		Email:     userNew.Email,
 		Phone:     userNew.Phone,
@@ -214,7 +243,7 @@ func (h *KeycloakAuthHandler) Logout(c *gin.Context) {
 		return
 	}
 
-	err = h.keycloakService.Logout(session.CkId)
+	err = h.keycloakService.Logout(c, session)
 	if err != nil {
 		SendError(c, http.StatusInternalServerError, "Internal server error", err.Error())
 		return
@@ -234,3 +263,127 @@ func (h *KeycloakAuthHandler) Logout(c *gin.Context) {
 	// Для демонстрации просто возвращаем успешный ответ
 	SendSuccess(c, fmt.Sprintf("Successfully logged out from realm: %s", realm))
 }
+
+// GetSessions godoc
+// @Summary List user sessions
+// @Description List active sessions (devices) of the current user
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Success 200 {object} SessionListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/sessions [get]
+func (h *KeycloakAuthHandler) GetSessions(c *gin.Context) {
+	session, ok := h.getUserSession(c)
+	if !ok {
+		return
+	}
+	sessions, err := h.keycloakService.ListSessions(*session.CkUser, session.CkId)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Sessions retrieved successfully", sessions)
+}
+
+// DeleteSession godoc
+// @Summary Revoke a session
+// @Description Log out one session of the current user
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "Session ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/sessions/{id} [delete]
+func (h *KeycloakAuthHandler) DeleteSession(c *gin.Context) {
+	session, ok := h.getUserSession(c)
+	if !ok {
+		return
+	}
+	id, err := GetUUIDParam(c, "id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid session ID", err.Error())
+		return
+	}
+	if err := h.keycloakService.RevokeSession(c, *session.CkUser, id); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	if id == session.CkId {
+		c.SetCookie(h.config.Store.CookieName, "", 0, h.config.Store.CookiePath, h.config.Store.CookieDomain, h.config.Store.CookieSecure, h.config.Store.CookieHttpOnly)
+	}
+	SendSuccess(c, "Session revoked successfully")
+}
+
+// LogoutOthers godoc
+// @Summary Log out everywhere else
+// @Description Log out all sessions of the current user except the current one
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Success 200 {object} RevokedSessionsResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /auth/sessions/logout-others [post]
+func (h *KeycloakAuthHandler) LogoutOthers(c *gin.Context) {
+	session, ok := h.getUserSession(c)
+	if !ok {
+		return
+	}
+	revoked, err := h.keycloakService.RevokeOtherSessions(c, *session.CkUser, session.CkId)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Other sessions revoked successfully", gin.H{"revoked": revoked})
+}
+
+// ForceLogoutUser godoc
+// @Summary Force logout a user
+// @Description Log out all sessions of the user
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "User ID"
+// @Success 200 {object} RevokedSessionsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/users/{id}/sessions [delete]
+func (h *KeycloakAuthHandler) ForceLogoutUser(c *gin.Context) {
+	userID, err := GetUUIDParam(c, "id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid user ID", err.Error())
+		return
+	}
+	revoked, err := h.keycloakService.ForceLogout(c, userID)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "User sessions revoked successfully", gin.H{"revoked": revoked})
+}
+
+// getUserSession returns the current session of a signed-in user or sends 401
+func (h *KeycloakAuthHandler) getUserSession(c *gin.Context) (*models.TSession, bool) {
+	session, err := middleware.GetSession(c)
+	if err != nil {
+		SendError(c, http.StatusUnauthorized, "Session not found", err.Error())
+		return nil, false
+	}
+	if session.CkUser == nil {
+		SendError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return nil, false
+	}
+	return session, true
+}
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"parier-server/internal/middleware"
+	"parier-server/internal/models"
+	"parier-server/internal/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LocalizationHandler exposes translation management for the content team
+type LocalizationHandler struct {
+	service   *service.LocalizationService
+	translate *service.TranslateService
+}
+
+func NewLocalizationHandler(svc *service.LocalizationService, translate *service.TranslateService) *LocalizationHandler {
+	return &LocalizationHandler{service: svc, translate: translate}
+}
+
+// === REQUEST AND RESPONSE STRUCTURES ===
+
+type LanguageResponse struct {
+	ID          string                   `json:"id"`
+	Name        string                   `json:"name"`
+	IsDefault   bool                     `json:"is_default"`
+	AndroidCode string                   `json:"android_code"`
+	IOSCode     string                   `json:"ios_code"`
+	BrowserCode string                   `json:"browser_code"`
+	Direction   models.LanguageDirection `json:"direction"`
+}
+
+type LanguagesResponse struct {
+	models.SuccessResponse
+	Data []LanguageResponse `json:"data"`
+}
+
+type MissingTranslationsResponse struct {
+	models.PaginationResponse
+	Data []service.MissingTranslationResponse `json:"data"`
+}
+
+type TranslationCoverageListResponse struct {
+	models.SuccessResponse
+	Data []service.TranslationCoverageResponse `json:"data"`
+}
+
+type BulkTranslationResponse struct {
+	models.SuccessResponse
+	Data service.BulkTranslationResult `json:"data"`
+}
+
+type PendingReviewListResponse struct {
+	models.PaginationResponse
+	Data []service.PendingReviewResponse `json:"data"`
+}
+
+type ApproveTranslationsResponse struct {
+	models.SuccessResponse
+	Data struct {
+		Approved int64 `json:"approved"`
+	} `json:"data"`
+}
+
+// === ENDPOINTS ===
+
+// GetLanguages godoc
+// @Summary Get languages
+// @Description Get all active languages
+// @Tags localization
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Success 200 {object} LanguagesResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/localization/languages [get]
+func (h *LocalizationHandler) GetLanguages(c *gin.Context) {
+	languages, err := h.service.GetAllLanguages()
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	response := make([]LanguageResponse, len(languages))
+	for i, lang := range languages {
+		response[i] = toLanguageResponse(&lang)
+	}
+	SendSuccess(c, "Languages fetched successfully", response)
+}
+
+// CreateLanguage godoc
+// @Summary Add language
+// @Description Add a new language
+// @Tags localization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param request body service.CreateLanguageRequest true "Language"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/localization/languages [put]
+func (h *LocalizationHandler) CreateLanguage(c *gin.Context) {
+	var req service.CreateLanguageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	user := GetUser(c)
+	if user == nil {
+		SendError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return
+	}
+	req.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	if existing, err := h.service.GetLanguageByID(req.Code); err == nil && existing != nil {
+		SendError(c, http.StatusConflict, "VALIDATION_ERROR", "Language already exists: "+req.Code)
+		return
+	}
+	lang, err := h.service.CreateLanguage(&req, GetAuditActor(c), nil)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	lang.NameWord = &models.TLWord{CvText: req.Name}
+	SendSuccess(c, "Language created successfully", toLanguageResponse(lang))
+}
+
+// GetMissingTranslations godoc
+// @Summary Get missing translations
+// @Description Get localization keys that have no translation in the language
+// @Tags localization
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param lang path string true "Language"
+// @Param ns query string false "Namespace (STATIC or DYNAMIC)"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit"
+// @Success 200 {object} MissingTranslationsResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/localization/missing/{lang} [get]
+func (h *LocalizationHandler) GetMissingTranslations(c *gin.Context) {
+	lang := strings.ToUpper(c.Param("lang"))
+	pagination := GetPaginationFromQuery(c)
+	missing, total, err := h.service.GetMissingTranslations(lang, getNamespaceQuery(c), pagination.Offset, pagination.Limit)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendPaginated(c, missing, len(missing), total)
+}
+
+// GetTranslationCoverage godoc
+// @Summary Get translation coverage
+// @Description Get per-language translation coverage percentages
+// @Tags localization
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param ns query string false "Namespace (STATIC or DYNAMIC)"
+// @Success 200 {object} TranslationCoverageListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/localization/coverage [get]
+func (h *LocalizationHandler) GetTranslationCoverage(c *gin.Context) {
+	coverage, err := h.service.GetTranslationCoverage(getNamespaceQuery(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Translation coverage fetched successfully", coverage)
+}
+
+// PostBulkTranslations godoc
+// @Summary Bulk edit translations
+// @Description Create, update or remove (empty text) translations in one transaction
+// @Tags localization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param request body service.BulkTranslationRequest true "Translations"
+// @Success 200 {object} BulkTranslationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/localization/translations [post]
+func (h *LocalizationHandler) PostBulkTranslations(c *gin.Context) {
+	var req service.BulkTranslationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	user := GetUser(c)
+	if user == nil {
+		SendError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return
+	}
+	result, err := h.service.BulkUpdateTranslations(&req, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Translations updated successfully", result)
+}
+
+// PostMachineTranslate godoc
+// @Summary Run machine translation
+// @Description Start filling missing translations through the AI module in the background
+// @Tags localization
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Success 202 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /admin/localization/machine-translate [post]
+func (h *LocalizationHandler) PostMachineTranslate(c *gin.Context) {
+	if err := h.translate.Trigger(); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, models.SuccessResponse{Success: true, Message: "Machine translation started"})
+}
+
+// GetPendingReview godoc
+// @Summary Get machine translations pending review
+// @Description Get machine translations of the language that were not reviewed yet
+// @Tags localization
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param lang path string true "Language"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit"
+// @Success 200 {object} PendingReviewListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/localization/review/{lang} [get]
+func (h *LocalizationHandler) GetPendingReview(c *gin.Context) {
+	lang := strings.ToUpper(c.Param("lang"))
+	pagination := GetPaginationFromQuery(c)
+	pending, total, err := h.translate.GetPendingReview(lang, pagination.Offset, pagination.Limit)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendPaginated(c, pending, len(pending), total)
+}
+
+// PostApproveTranslations godoc
+// @Summary Approve machine translations
+// @Description Mark machine translations as reviewed. To correct a translation use the bulk edit endpoint
+// @Tags localization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param request body service.ApproveTranslationsRequest true "Keys to approve"
+// @Success 200 {object} ApproveTranslationsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/localization/review [post]
+func (h *LocalizationHandler) PostApproveTranslations(c *gin.Context) {
+	var req service.ApproveTranslationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	user := GetUser(c)
+	if user == nil {
+		SendError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return
+	}
+	approved, err := h.translate.ApproveTranslations(&req, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Translations approved successfully", gin.H{"approved": approved})
+}
+
+// === HELPER METHODS ===
+
+func toLanguageResponse(lang *models.TDLang) LanguageResponse {
+	response := LanguageResponse{
+		ID:          lang.CkId,
+		IsDefault:   lang.ClDefault,
+		AndroidCode: lang.CvCodeAndroid,
+		IOSCode:     lang.CvCodeIos,
+		BrowserCode: lang.CvCodeBrowser,
+		Direction:   lang.CrDirection,
+	}
+	if lang.NameWord != nil {
+		response.Name = lang.NameWord.CvText
+	}
+	return response
+}
+
+func getNamespaceQuery(c *gin.Context) *string {
+	ns := c.Query("ns")
+	if ns == "" {
+		return nil
+	}
+	ns = strings.ToUpper(ns)
+	return &ns
+}
+
+// sendServiceError writes err using the status code mapped from its ServiceError code.
+// Server errors are logged with their cause, and the client gets only a generic message
+func sendServiceError(c *gin.Context, err error) {
+	serviceErr := service.GetServiceError(err)
+	if serviceErr == nil {
+		log.Printf("%s %s: %v", c.Request.Method, c.FullPath(), err)
+		SendError(c, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	status := getStatusCodeFromServiceError(serviceErr)
+	if status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %s: %v", c.Request.Method, c.FullPath(), serviceErr.Message, serviceErr.Cause)
+	}
+	SendError(c, status, serviceErr.Code, serviceErr.Message)
+}
+
+// RegisterRoutes registers translation management routes
+func (h *LocalizationHandler) RegisterRoutes(router *gin.RouterGroup) {
+	localization := middleware.Declare(router).Group("/admin/localization")
+	{
+		localization.GET("/languages", middleware.TableAccess("t_d_lang", models.ActionTypeView), h.GetLanguages)
+		localization.PUT("/languages", middleware.TableAccess("t_d_lang", models.ActionTypeInsert), h.CreateLanguage)
+		localization.GET("/missing/:lang", middleware.TableAccess("t_localization_word", models.ActionTypeView), h.GetMissingTranslations)
+		localization.GET("/coverage", middleware.TableAccess("t_localization_word", models.ActionTypeView), h.GetTranslationCoverage)
+		localization.POST("/translations", middleware.TableAccess("t_localization_word", models.ActionTypeUpdate), h.PostBulkTranslations)
+		localization.POST("/machine-translate", middleware.TableAccess("t_localization_word", models.ActionTypeInsert), h.PostMachineTranslate)
+		localization.GET("/review/:lang", middleware.TableAccess("t_localization_word", models.ActionTypeView), h.GetPendingReview)
+		localization.POST("/review", middleware.TableAccess("t_localization_word", models.ActionTypeUpdate), h.PostApproveTranslations)
+	}
+}
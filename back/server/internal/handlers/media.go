@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"parier-server/internal/config"
+	"parier-server/internal/middleware"
 	"parier-server/internal/models"
 	"parier-server/internal/service"
 	"strconv"
@@ -231,21 +233,109 @@ func (h *MediaHandler) GetMediaInfo(c *gin.Context) {
 		Url:         media.CvUrl,
 		ContentType: media.MediaType.CvMimeType,
 		TypeID:      media.MediaType.CkId,
-		TypeName:    *h.mediaService.LocRepo.GetWordOrDefault(&media.MediaType.CkName, lang),
+		TypeName:    h.mediaService.Resolver.Resolve(lang, &media.MediaType.CkName).Text(media.MediaType.CkName),
 	})
 }
 
+// @Summary Get blob by signed URL
+// @Description Serve a blob from local storage (fs and memory backends) using an HMAC-signed URL
+// @Tags media
+// @Produce application/octet-stream
+// @Param key path string true "Blob key"
+// @Param expires query int true "Expiration unix time"
+// @Param signature query string true "Signature"
+// @Success 200 {file} binary
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /media/blob/{key} [get]
+func (h *MediaHandler) GetBlob(c *gin.Context) {
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid expires", err.Error())
+		return
+	}
+	object, err := h.mediaService.GetSignedBlob(c.Request.Context(), c.Param("key"), expires, c.Query("signature"))
+	if err != nil {
+		if serviceErr := service.GetServiceError(err); serviceErr != nil {
+			SendError(c, getStatusCodeFromServiceError(serviceErr), serviceErr.Code, serviceErr.Message)
+			return
+		}
+		SendError(c, http.StatusInternalServerError, "Internal server error", "Failed to get blob")
+		return
+	}
+	contentType := object.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Cache-Control", fmt.Sprintf("max-age=%d", int(h.config.Media.Duration.Seconds())))
+	c.Data(http.StatusOK, contentType, object.Content)
+}
+
+// @Summary Upload blob by presigned URL
+// @Description Upload a blob to local storage (fs and memory backends) using a presigned URL
+// @Tags media
+// @Accept application/octet-stream
+// @Produce json
+// @Param key path string true "Blob key"
+// @Param expires query int true "Expiration unix time"
+// @Param signature query string true "Signature"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /media/blob/{key} [put]
+func (h *MediaHandler) PutBlob(c *gin.Context) {
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid expires", err.Error())
+		return
+	}
+	// маршрут не требует сессии, поэтому размер тела ограничен до записи в хранилище
+	limit := h.config.Storage.MaxUploadSize
+	tooLarge := fmt.Sprintf("Blob exceeds %d bytes", limit)
+	if c.Request.ContentLength > limit {
+		SendError(c, http.StatusRequestEntityTooLarge, "Blob too large", tooLarge)
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	err = h.mediaService.PutSignedBlob(c.Request.Context(), c.Param("key"), c.ContentType(), expires, c.Query("signature"), c.Request.Body)
+	if err != nil {
+		if serviceErr := service.GetServiceError(err); serviceErr != nil {
+			var maxBytes *http.MaxBytesError
+			if errors.As(serviceErr.Cause, &maxBytes) {
+				SendError(c, http.StatusRequestEntityTooLarge, "Blob too large", tooLarge)
+				return
+			}
+			SendError(c, getStatusCodeFromServiceError(serviceErr), serviceErr.Code, serviceErr.Message)
+			return
+		}
+		SendError(c, http.StatusInternalServerError, "Internal server error", "Failed to upload blob")
+		return
+	}
+	SendSuccess(c, "Blob uploaded successfully")
+}
+
 // getStatusCodeFromServiceError maps service errors to HTTP status codes
 func getStatusCodeFromServiceError(err *service.ServiceError) int {
 	switch err.Code {
-	case "MEDIA_NOT_FOUND":
+	case "MEDIA_NOT_FOUND", "NOT_FOUND":
 		return http.StatusNotFound
 	case "VALIDATION_ERROR", "INVALID_REQUEST", "REQUIRED_FIELD_MISSING", "INVALID_FORMAT":
 		return http.StatusBadRequest
 	case "UNAUTHORIZED", "INVALID_TOKEN", "INVALID_CREDENTIALS":
 		return http.StatusUnauthorized
-	case "MEDIA_IN_USE":
+	case "INVALID_SIGNATURE", "FORBIDDEN":
+		return http.StatusForbidden
+	case "STORAGE_NOT_SUPPORTED":
+		return http.StatusNotFound
+	case "MEDIA_IN_USE", "TRANSLATE_IN_PROGRESS", "ALREADY_EXISTS":
 		return http.StatusConflict
+	case "TOO_MANY_REQUESTS":
+		return http.StatusTooManyRequests
+	case "TRANSLATE_NOT_CONFIGURED":
+		return http.StatusServiceUnavailable
 	case "S3_UPLOAD_ERROR", "S3_DOWNLOAD_ERROR", "S3_DELETE_ERROR", "DB_SAVE_ERROR", "DB_DELETE_ERROR":
 		return http.StatusInternalServerError
 	default:
@@ -255,11 +345,24 @@ func getStatusCodeFromServiceError(err *service.ServiceError) int {
 
 // RegisterRoutes registers all media routes
 func (h *MediaHandler) RegisterRoutes(router *gin.RouterGroup) {
-	mediaGroup := router.Group("/media")
+	mediaGroup := middleware.Declare(router).Group("/media")
 	{
 		// File operations
-		mediaGroup.GET("/:id/download", h.DownloadFile)
-		mediaGroup.GET("/:id/raw", h.RawFile)
+		mediaGroup.GET("/:id/download", middleware.SessionAccess(), h.DownloadFile)
+		mediaGroup.GET("/:id/raw", middleware.SessionAccess(), h.RawFile)
 
 	}
 }
+
+// RegisterBlobRoutes registers signed blob routes served by local storage backends.
+// Requests are authorized by the URL signature, so the group must not require a session.
+func (h *MediaHandler) RegisterBlobRoutes(router *gin.RouterGroup) {
+	if !h.mediaService.IsLocalStorage() {
+		return
+	}
+	blobGroup := middleware.Declare(router).Group("/media/blob")
+	{
+		blobGroup.GET("/*key", middleware.PublicAccess(), h.GetBlob)
+		blobGroup.PUT("/*key", middleware.PublicAccess(), h.PutBlob)
+	}
+}
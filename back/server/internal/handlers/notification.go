@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"net/http"
+	"parier-server/internal/middleware"
+	"parier-server/internal/models"
+	"parier-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationsResponse struct {
+	models.PaginationResponse
+	Data []models.NotificationResponse `json:"data"`
+}
+
+type NotificationCountResponse struct {
+	models.SuccessResponse
+	Data struct {
+		Count int64 `json:"count"`
+	} `json:"data"`
+}
+
+type NotificationsReadResponse struct {
+	models.SuccessResponse
+	Data struct {
+		Read int64 `json:"read"`
+	} `json:"data"`
+}
+
+type NotificationPreferencesResponse struct {
+	models.SuccessResponse
+	Data models.NotificationPreferences `json:"data"`
+}
+
+type NotificationHandler struct {
+	service *service.NotificationService
+}
+
+func NewNotificationHandler(s *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{service: s}
+}
+
+// GetNotifications godoc
+// @Summary Get notifications
+// @Description Get notifications of the current user, newest first. Title and body are rendered in the request language
+// @Tags notification
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param unread query bool false "Only unread notifications"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit"
+// @Success 200 {object} NotificationsResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /notifications [get]
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	user := GetUser(c)
+	if user == nil {
+		SendError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return
+	}
+	pagination := GetPaginationFromQuery(c)
+	unread := c.Query("unread") == "true"
+	notifications, total, err := h.service.List(user.ID, unread, GetLanguage(c, nil), pagination.Offset, pagination.Limit)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendPaginated(c, notifications, len(notifications), total)
+}
+
+// GetUnreadCount godoc
+// @Summary Get unread notification count
+// @Description Get the number of unread notifications of the current user
+// @Tags notification
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Success 200 {object} NotificationCountResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /notifications/unread-count [get]
+func (h *NotificationHandler) GetUnreadCount(c *gin.Context) {
+	user := GetUser(c)
+	if user == nil {
+		SendError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return
+	}
+	count, err := h.service.UnreadCount(user.ID)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Unread notifications counted", gin.H{"count": count})
+}
+
+// PostReadNotifications godoc
+// @Summary Mark notifications as read
+// @Description Mark the listed notifications of the current user as read; without ids all unread notifications are marked
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param request body models.NotificationReadRequest false "Request"
+// @Success 200 {object} NotificationsReadResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /notifications/read [post]
+func (h *NotificationHandler) PostReadNotifications(c *gin.Context) {
+	var req models.NotificationReadRequest
+	user := GetUser(c)
+	if user == nil {
+		SendError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+			return
+		}
+	}
+	count, err := h.service.MarkRead(user.ID, req.IDs)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Notifications marked as read", gin.H{"read": count})
+}
+
+// GetPreferences godoc
+// @Summary Get notification preferences
+// @Description Get delivery channels and muted notification types of the current user
+// @Tags notification
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Success 200 {object} NotificationPreferencesResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /notifications/preferences [get]
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	user := GetUser(c)
+	if user == nil {
+		SendError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return
+	}
+	prefs, err := h.service.GetPreferences(user.ID)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Notification preferences fetched successfully", prefs)
+}
+
+// PutPreferences godoc
+// @Summary Update notification preferences
+// @Description Change the passed preferences: email delivery, webhook URL (empty string disables it) and muted notification types
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param request body models.NotificationPreferencesRequest true "Request"
+// @Success 200 {object} NotificationPreferencesResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /notifications/preferences [put]
+func (h *NotificationHandler) PutPreferences(c *gin.Context) {
+	var req models.NotificationPreferencesRequest
+	user := GetUser(c)
+	if user == nil {
+		SendError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	prefs, err := h.service.SetPreferences(user.ID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Notification preferences updated successfully", prefs)
+}
+
+// RegisterRoutes registers notification routes of the current user
+func (h *NotificationHandler) RegisterRoutes(router *gin.RouterGroup) {
+	notifications := middleware.Declare(router).Group("notifications")
+	{
+		notifications.GET("", middleware.SessionAccess(), h.GetNotifications)
+		notifications.GET("/unread-count", middleware.SessionAccess(), h.GetUnreadCount)
+		notifications.POST("/read", middleware.SessionAccess(), h.PostReadNotifications)
+		notifications.GET("/preferences", middleware.SessionAccess(), h.GetPreferences)
+		notifications.PUT("/preferences", middleware.SessionAccess(), h.PutPreferences)
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"parier-server/internal/middleware"
 	"parier-server/internal/models"
 	"parier-server/internal/service"
 
@@ -33,11 +34,48 @@ type BetCommentResponse struct {
 	Data []models.BetCommentResponse `json:"data"`
 }
 
+type BetCommentTreeResponse struct {
+	models.PaginationResponse
+	Data []models.BetCommentThreadResponse `json:"data"`
+}
+
+type BetCommentRepliesResponse struct {
+	models.SuccessResponse
+	Data models.BetCommentRepliesResponse `json:"data"`
+}
+
+type BetCommentRevisionsResponse struct {
+	models.SuccessResponse
+	Data []models.BetCommentRevisionResponse `json:"data"`
+}
+
+type MentionsResponse struct {
+	models.PaginationResponse
+	Data []models.MentionResponse `json:"data"`
+}
+
+type MentionsReadResponse struct {
+	models.SuccessResponse
+	Data struct {
+		Read int64 `json:"read"`
+	} `json:"data"`
+}
+
 type CurrentUserResponse struct {
 	models.SuccessResponse
 	Data models.AuthorResponse `json:"data"`
 }
 
+type ReputationResponse struct {
+	models.SuccessResponse
+	Data models.ReputationResponse `json:"data"`
+}
+
+type BetRatingResponse struct {
+	models.SuccessResponse
+	Data models.BetRatingResponse `json:"data"`
+}
+
 func NewParierHandler(service *service.ParierService) *ParierHandler {
 	return &ParierHandler{service: service}
 }
@@ -246,7 +284,7 @@ func (h *ParierHandler) CreateBet(c *gin.Context) {
 	}
 	req.Language = GetLanguage(c, req.Language)
 	req.User = GetUser(c)
-	bet, err := h.service.CreateBet(req)
+	bet, err := h.service.CreateBet(req, GetAuditActor(c))
 	if err != nil {
 		SendError(c, http.StatusInternalServerError, "Internal server error", err.Error())
 		return
@@ -267,6 +305,7 @@ func (h *ParierHandler) CreateBet(c *gin.Context) {
 // @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /parier/bet/{bet_id}/like [post]
 func (h *ParierHandler) PostLikeBet(c *gin.Context) {
@@ -280,7 +319,7 @@ func (h *ParierHandler) PostLikeBet(c *gin.Context) {
 	req.User = GetUser(c)
 	liked, err := h.service.LikeBet(betID, req)
 	if err != nil {
-		SendError(c, http.StatusInternalServerError, "Internal server error", err.Error())
+		sendServiceError(c, err)
 		return
 	}
 	SendSuccess(c, "Bet liked successfully", liked)
@@ -355,6 +394,81 @@ func (h *ParierHandler) PostBetComments(c *gin.Context) {
 	SendPaginated(c, comments, len(comments), total)
 }
 
+// GetBetCommentTree godoc
+// @Summary Get bet comment tree
+// @Description Get a page of top-level bet comments with nested replies. Every comment carries its reply count; if not all replies are loaded, has_more_replies is set and next_cursor continues after the last loaded reply
+// @Tags parier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param bet_id path string true "Bet ID"
+// @Param request body models.BetCommentTreeRequest true "Request"
+// @Success 200 {object} BetCommentTreeResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/bet/{bet_id}/comments/tree [post]
+func (h *ParierHandler) PostBetCommentTree(c *gin.Context) {
+	var req models.BetCommentTreeRequest
+	betID := GetUUID(c, "bet_id")
+	if betID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Bet ID is required", "Bet ID is required")
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	comments, total, err := h.service.GetBetCommentTree(betID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendPaginated(c, comments, len(comments), total)
+}
+
+// GetBetCommentReplies godoc
+// @Summary Get comment replies
+// @Description Load more replies to a comment after the cursor, with their own nested replies
+// @Tags parier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param comment_id path string true "Comment ID"
+// @Param request body models.BetCommentRepliesRequest true "Request"
+// @Success 200 {object} BetCommentRepliesResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/comment/{comment_id}/replies [post]
+func (h *ParierHandler) PostBetCommentReplies(c *gin.Context) {
+	var req models.BetCommentRepliesRequest
+	commentID := GetUUID(c, "comment_id")
+	if commentID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Comment ID is required", "Comment ID is required")
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	replies, err := h.service.GetBetCommentReplies(commentID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Replies fetched successfully", replies)
+}
+
 // CreateBetComment godoc
 // @Summary Create bet comment
 // @Description Create bet comment
@@ -369,6 +483,7 @@ func (h *ParierHandler) PostBetComments(c *gin.Context) {
 // @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /parier/bet/{bet_id}/comment [put]
 func (h *ParierHandler) PutCreateBetComment(c *gin.Context) {
@@ -386,12 +501,141 @@ func (h *ParierHandler) PutCreateBetComment(c *gin.Context) {
 	req.User = GetUser(c)
 	comment, err := h.service.CreateBetComment(betID, req)
 	if err != nil {
-		SendError(c, http.StatusInternalServerError, "Internal server error", err.Error())
+		sendServiceError(c, err)
 		return
 	}
 	SendSuccess(c, "Comment created successfully", comment)
 }
 
+// EditBetComment godoc
+// @Summary Edit bet comment
+// @Description Replace the comment text. The author may edit within COMMENTS_EDIT_WINDOW after creation, users with UPDATE access to t_bet_comment may edit any comment. The previous text is kept in the revision history
+// @Tags parier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param comment_id path string true "Comment ID"
+// @Param request body models.BetCommentEditRequest true "Request"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/comment/{comment_id}/edit [post]
+func (h *ParierHandler) PostEditBetComment(c *gin.Context) {
+	var req models.BetCommentEditRequest
+	commentID := GetUUID(c, "comment_id")
+	if commentID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Comment ID is required", "Comment ID is required")
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	moderator, err := commentModerator(c, models.ActionTypeUpdate)
+	if err != nil {
+		SendError(c, http.StatusInternalServerError, "Failed to load permissions", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	edited, err := h.service.EditBetComment(commentID, req, moderator, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Comment edited successfully", edited)
+}
+
+// DeleteBetComment godoc
+// @Summary Delete bet comment
+// @Description Delete own comment; users with DELETE access to t_bet_comment may delete any comment. Replies stay in the tree under a removed comment
+// @Tags parier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param comment_id path string true "Comment ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/comment/{comment_id}/delete [post]
+func (h *ParierHandler) PostDeleteBetComment(c *gin.Context) {
+	var req models.DefaultRequest
+	commentID := GetUUID(c, "comment_id")
+	if commentID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Comment ID is required", "Comment ID is required")
+		return
+	}
+	moderator, err := commentModerator(c, models.ActionTypeDelete)
+	if err != nil {
+		SendError(c, http.StatusInternalServerError, "Failed to load permissions", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	deleted, err := h.service.DeleteBetComment(commentID, req, moderator, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Comment deleted successfully", deleted)
+}
+
+// GetBetCommentRevisions godoc
+// @Summary Get comment edit history
+// @Description Get previous versions of the comment, newest first. History of a removed comment is available to moderators only
+// @Tags parier
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param comment_id path string true "Comment ID"
+// @Success 200 {object} BetCommentRevisionsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/comment/{comment_id}/revisions [get]
+func (h *ParierHandler) GetBetCommentRevisions(c *gin.Context) {
+	commentID := GetUUID(c, "comment_id")
+	if commentID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Comment ID is required", "Comment ID is required")
+		return
+	}
+	moderator, err := commentModerator(c, models.ActionTypeUpdate)
+	if err != nil {
+		SendError(c, http.StatusInternalServerError, "Failed to load permissions", err.Error())
+		return
+	}
+	revisions, err := h.service.GetBetCommentRevisions(commentID, moderator)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Comment revisions fetched successfully", revisions)
+}
+
+// commentModerator сообщает, может ли посетитель выполнять action с чужими комментариями
+func commentModerator(c *gin.Context, action models.ActionType) (bool, error) {
+	permissions, err := middleware.RequestPermissions(c)
+	if err != nil {
+		return false, err
+	}
+	if token := middleware.GetAPIToken(c); token != nil && !token.Allows(models.TokenScopeTable, "t_bet_comment", action) {
+		return false, nil
+	}
+	return permissions.CanTable("t_bet_comment", action), nil
+}
+
 // LikeBetComment godoc
 // @Summary Like bet comment
 // @Description Like bet comment
@@ -405,6 +649,7 @@ func (h *ParierHandler) PutCreateBetComment(c *gin.Context) {
 // @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /parier/comment/{comment_id}/like [post]
 func (h *ParierHandler) PostLikeBetComment(c *gin.Context) {
@@ -422,7 +667,7 @@ func (h *ParierHandler) PostLikeBetComment(c *gin.Context) {
 	req.User = GetUser(c)
 	liked, err := h.service.LikeBetComment(commentID, req)
 	if err != nil {
-		SendError(c, http.StatusInternalServerError, "Internal server error", err.Error())
+		sendServiceError(c, err)
 		return
 	}
 	SendSuccess(c, "Comment liked successfully", liked)
@@ -441,6 +686,7 @@ func (h *ParierHandler) PostLikeBetComment(c *gin.Context) {
 // @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /parier/comment/{comment_id}/unlike [post]
 func (h *ParierHandler) PostUnlikeBetComment(c *gin.Context) {
@@ -454,12 +700,75 @@ func (h *ParierHandler) PostUnlikeBetComment(c *gin.Context) {
 	req.User = GetUser(c)
 	unliked, err := h.service.UnlikeBetComment(commentID, req)
 	if err != nil {
-		SendError(c, http.StatusInternalServerError, "Internal server error", err.Error())
+		sendServiceError(c, err)
 		return
 	}
 	SendSuccess(c, "Comment unliked successfully", unliked)
 }
 
+// GetMentions godoc
+// @Summary Get unread mentions
+// @Description Get comments that mention the current user with @username and are not read yet, newest first
+// @Tags parier
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit"
+// @Success 200 {object} MentionsResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/mentions [get]
+func (h *ParierHandler) GetMentions(c *gin.Context) {
+	user := GetUser(c)
+	if user == nil {
+		SendError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return
+	}
+	pagination := GetPaginationFromQuery(c)
+	mentions, total, err := h.service.GetUnreadMentions(user.ID, pagination.Offset, pagination.Limit)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendPaginated(c, mentions, len(mentions), total)
+}
+
+// PostReadMentions godoc
+// @Summary Mark mentions as read
+// @Description Mark the listed mentions of the current user as read; without ids all unread mentions are marked
+// @Tags parier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param request body models.MentionReadRequest false "Request"
+// @Success 200 {object} MentionsReadResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/mentions/read [post]
+func (h *ParierHandler) PostReadMentions(c *gin.Context) {
+	var req models.MentionReadRequest
+	user := GetUser(c)
+	if user == nil {
+		SendError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+			return
+		}
+	}
+	count, err := h.service.MarkMentionsRead(user.ID, req.IDs)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Mentions marked as read", gin.H{"read": count})
+}
+
 // GetCurrentUser godoc
 // @Summary Get current user
 // @Description Get current user
@@ -483,23 +792,251 @@ func (h *ParierHandler) GetCurrentUser(c *gin.Context) {
 	SendSuccess(c, "Current user fetched successfully", user)
 }
 
+// GetUserReputation godoc
+// @Summary Get user reputation
+// @Description Reputation score from 0 to 100 and what it is made of: received ratings of the user and their bets and the win rate of settled bets. my_rating is the rating given by the current user
+// @Tags parier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param user_id path string true "User ID"
+// @Success 200 {object} ReputationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/user/{user_id}/reputation [get]
+func (h *ParierHandler) GetUserReputation(c *gin.Context) {
+	var req models.DefaultRequest
+	userID := GetUUID(c, "user_id")
+	if userID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "User ID is required", "User ID is required")
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	reputation, err := h.service.GetUserReputation(userID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Reputation fetched successfully", reputation)
+}
+
+// PutRateUser godoc
+// @Summary Rate user
+// @Description Rate a user from 1 to 5 or change the given rating. Users cannot rate themselves
+// @Tags parier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param user_id path string true "User ID"
+// @Param request body models.RatingRequest true "Request"
+// @Success 200 {object} ReputationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/user/{user_id}/rating [put]
+func (h *ParierHandler) PutRateUser(c *gin.Context) {
+	var req models.RatingRequest
+	userID := GetUUID(c, "user_id")
+	if userID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "User ID is required", "User ID is required")
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	reputation, err := h.service.RateUser(userID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "User rated successfully", reputation)
+}
+
+// PostUnrateUser godoc
+// @Summary Remove user rating
+// @Description Remove the rating given to a user
+// @Tags parier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param user_id path string true "User ID"
+// @Success 200 {object} ReputationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/user/{user_id}/unrate [post]
+func (h *ParierHandler) PostUnrateUser(c *gin.Context) {
+	var req models.DefaultRequest
+	userID := GetUUID(c, "user_id")
+	if userID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "User ID is required", "User ID is required")
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	reputation, err := h.service.UnrateUser(userID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "User rating removed successfully", reputation)
+}
+
+// PutRateBet godoc
+// @Summary Rate bet
+// @Description Rate a bet from 1 to 5 or change the given rating. The rating counts towards the reputation of the bet author, who cannot rate their own bet
+// @Tags parier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param bet_id path string true "Bet ID"
+// @Param request body models.RatingRequest true "Request"
+// @Success 200 {object} BetRatingResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/bet/{bet_id}/rating [put]
+func (h *ParierHandler) PutRateBet(c *gin.Context) {
+	var req models.RatingRequest
+	betID := GetUUID(c, "bet_id")
+	if betID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Bet ID is required", "Bet ID is required")
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	rating, err := h.service.RateBet(betID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Bet rated successfully", rating)
+}
+
+// PostUnrateBet godoc
+// @Summary Remove bet rating
+// @Description Remove the rating given to a bet
+// @Tags parier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param bet_id path string true "Bet ID"
+// @Success 200 {object} BetRatingResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/bet/{bet_id}/unrate [post]
+func (h *ParierHandler) PostUnrateBet(c *gin.Context) {
+	var req models.DefaultRequest
+	betID := GetUUID(c, "bet_id")
+	if betID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Bet ID is required", "Bet ID is required")
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	rating, err := h.service.UnrateBet(betID, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Bet rating removed successfully", rating)
+}
+
+// PostSettleBet godoc
+// @Summary Settle bet
+// @Description Close an open bet with the result of its author. The result is added to the author's bet history and reputation; winnings are not paid to the wallet
+// @Tags parier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Security BasicAuth
+// @Param bet_id path string true "Bet ID"
+// @Param request body models.BetSettleRequest true "Request"
+// @Success 200 {object} ReputationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /parier/bet/{bet_id}/settle [post]
+func (h *ParierHandler) PostSettleBet(c *gin.Context) {
+	var req models.BetSettleRequest
+	betID := GetUUID(c, "bet_id")
+	if betID == uuid.Nil {
+		SendError(c, http.StatusBadRequest, "Bet ID is required", "Bet ID is required")
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	req.Language = GetLanguage(c, req.Language)
+	req.User = GetUser(c)
+	reputation, err := h.service.SettleBet(betID, req, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Bet settled successfully", reputation)
+}
+
 // RegisterRoutes registers all parier routes
 func (h *ParierHandler) RegisterRoutes(router *gin.RouterGroup) {
-	parier := router.Group("/parier")
+	parier := middleware.Declare(router).Group("/parier")
 	{
-		parier.POST("/categories", h.GetCategories)
-		parier.POST("/verification-sources", h.GetVerificationSources)
-		parier.POST("/bet-statuses", h.GetBetStatuses)
-		parier.POST("/bet-types", h.GetBetTypes)
-		parier.POST("/like-types", h.GetLikeTypes)
-		parier.POST("/bet", h.GetBets)
-		parier.PUT("/bet", h.CreateBet)
-		parier.POST("/bet/:bet_id/like", h.PostLikeBet)
-		parier.POST("/bet/:bet_id/unlike", h.PostUnlikeBet)
-		parier.POST("/bet/:bet_id/comments", h.PostBetComments)
-		parier.PUT("/bet/:bet_id/comment", h.PutCreateBetComment)
-		parier.POST("/comment/:comment_id/like", h.PostLikeBetComment)
-		parier.POST("/comment/:comment_id/unlike", h.PostUnlikeBetComment)
-		parier.GET("/user", h.GetCurrentUser)
+		parier.POST("/categories", middleware.TableAccess("t_d_category", models.ActionTypeView), h.GetCategories)
+		parier.POST("/verification-sources", middleware.TableAccess("t_d_verification_source", models.ActionTypeView), h.GetVerificationSources)
+		parier.POST("/bet-statuses", middleware.TableAccess("t_d_bet_status", models.ActionTypeView), h.GetBetStatuses)
+		parier.POST("/bet-types", middleware.TableAccess("t_d_bet_type", models.ActionTypeView), h.GetBetTypes)
+		parier.POST("/like-types", middleware.TableAccess("t_d_like_type", models.ActionTypeView), h.GetLikeTypes)
+		parier.POST("/bet", middleware.TableAccess("t_bet", models.ActionTypeView), h.GetBets)
+		parier.PUT("/bet", middleware.TableAccess("t_bet", models.ActionTypeInsert), h.CreateBet)
+		parier.POST("/bet/:bet_id/like", middleware.TableAccess("t_bet_like", models.ActionTypeInsert), h.PostLikeBet)
+		parier.POST("/bet/:bet_id/unlike", middleware.TableAccess("t_bet_like", models.ActionTypeDelete), h.PostUnlikeBet)
+		parier.PUT("/bet/:bet_id/rating", middleware.TableAccess("t_bet_rating", models.ActionTypeInsert), h.PutRateBet)
+		parier.POST("/bet/:bet_id/unrate", middleware.TableAccess("t_bet_rating", models.ActionTypeDelete), h.PostUnrateBet)
+		parier.POST("/bet/:bet_id/settle", middleware.TableAccess("t_user_bet_history", models.ActionTypeInsert), h.PostSettleBet)
+		parier.POST("/bet/:bet_id/comments", middleware.TableAccess("t_bet_comment", models.ActionTypeView), h.PostBetComments)
+		parier.POST("/bet/:bet_id/comments/tree", middleware.TableAccess("t_bet_comment", models.ActionTypeView), h.PostBetCommentTree)
+		parier.POST("/comment/:comment_id/replies", middleware.TableAccess("t_bet_comment", models.ActionTypeView), h.PostBetCommentReplies)
+		parier.PUT("/bet/:bet_id/comment", middleware.TableAccess("t_bet_comment", models.ActionTypeInsert), h.PutCreateBetComment)
+		// свои комментарии меняет и удаляет тот, кто может их писать; чужие - модератор, см. commentModerator
+		parier.POST("/comment/:comment_id/edit", middleware.TableAccess("t_bet_comment", models.ActionTypeInsert), h.PostEditBetComment)
+		parier.POST("/comment/:comment_id/delete", middleware.TableAccess("t_bet_comment", models.ActionTypeInsert), h.PostDeleteBetComment)
+		parier.GET("/comment/:comment_id/revisions", middleware.TableAccess("t_bet_comment_revision", models.ActionTypeView), h.GetBetCommentRevisions)
+		parier.POST("/comment/:comment_id/like", middleware.TableAccess("t_bet_comment_like", models.ActionTypeInsert), h.PostLikeBetComment)
+		parier.POST("/comment/:comment_id/unlike", middleware.TableAccess("t_bet_comment_like", models.ActionTypeDelete), h.PostUnlikeBetComment)
+		parier.GET("/mentions", middleware.SessionAccess(), h.GetMentions)
+		parier.POST("/mentions/read", middleware.SessionAccess(), h.PostReadMentions)
+		parier.GET("/user", middleware.SessionAccess(), h.GetCurrentUser)
+		parier.GET("/user/:user_id/reputation", middleware.TableAccess("t_user_reputation", models.ActionTypeView), h.GetUserReputation)
+		parier.PUT("/user/:user_id/rating", middleware.TableAccess("t_user_rating", models.ActionTypeInsert), h.PutRateUser)
+		parier.POST("/user/:user_id/unrate", middleware.TableAccess("t_user_rating", models.ActionTypeDelete), h.PostUnrateUser)
 	}
 }
@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"parier-server/internal/middleware"
+	"parier-server/internal/models"
+	"parier-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RBACHandler manages roles, their table and property grants and role assignments
+type RBACHandler struct {
+	service *service.RBACService
+}
+
+func NewRBACHandler(s *service.RBACService) *RBACHandler {
+	return &RBACHandler{service: s}
+}
+
+// CreateRoleRequest represents a new role; name and description are localization IDs
+type CreateRoleRequest struct {
+	ID          string           `json:"id" binding:"required" example:"MODERATOR"`
+	Name        string           `json:"name" binding:"required"`
+	Description *string          `json:"description,omitempty"`
+	Place       models.RolePlace `json:"place,omitempty" example:"GLOBAL"`
+}
+
+// GrantRequest represents access of a role to a table or property type
+type GrantRequest struct {
+	Object string            `json:"object" binding:"required" example:"t_localization_word"`
+	Action models.ActionType `json:"action" binding:"required" example:"VIEW"`
+}
+
+// AssignRoleRequest represents a time-bounded role assignment; without start the role is active immediately
+type AssignRoleRequest struct {
+	Role  string     `json:"role" binding:"required"`
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+// RoleListResponse represents roles with their grants
+type RoleListResponse struct {
+	models.SuccessResponse
+	Data []models.TDRole `json:"data"`
+}
+
+// RoleResponse represents a role
+type RoleResponse struct {
+	models.SuccessResponse
+	Data models.TDRole `json:"data"`
+}
+
+// TableGrantResponse represents a table grant
+type TableGrantResponse struct {
+	models.SuccessResponse
+	Data models.TDTableRole `json:"data"`
+}
+
+// PropertyGrantResponse represents a property grant
+type PropertyGrantResponse struct {
+	models.SuccessResponse
+	Data models.TPropertiesRole `json:"data"`
+}
+
+// RoleAssignmentResponse represents a role assignment
+type RoleAssignmentResponse struct {
+	models.SuccessResponse
+	Data service.RoleAssignment `json:"data"`
+}
+
+// RoleAssignmentListResponse represents role assignments of a user
+type RoleAssignmentListResponse struct {
+	models.SuccessResponse
+	Data []service.RoleAssignment `json:"data"`
+}
+
+// EffectivePermissionsResponse represents effective permissions of a user
+type EffectivePermissionsResponse struct {
+	models.SuccessResponse
+	Data service.EffectivePermissions `json:"data"`
+}
+
+// GetRoles godoc
+// @Summary List roles
+// @Description List roles with their table and property grants
+// @Tags rbac
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Success 200 {object} RoleListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/roles [get]
+func (h *RBACHandler) GetRoles(c *gin.Context) {
+	roles, err := h.service.ListRoles()
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Roles retrieved successfully", roles)
+}
+
+// CreateRole godoc
+// @Summary Create role
+// @Description Create a role; name and description are localization IDs
+// @Tags rbac
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param request body CreateRoleRequest true "Role"
+// @Success 200 {object} RoleResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/roles [post]
+func (h *RBACHandler) CreateRole(c *gin.Context) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	role, err := h.service.CreateRole(req.ID, req.Name, req.Description, req.Place, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Role created successfully", role)
+}
+
+// DeleteRole godoc
+// @Summary Delete role
+// @Description Delete a role with its grants and end its assignments
+// @Tags rbac
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "Role ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/roles/{id} [delete]
+func (h *RBACHandler) DeleteRole(c *gin.Context) {
+	if err := h.service.DeleteRole(c.Param("id"), GetAuditActor(c)); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Role deleted successfully")
+}
+
+// PostTableGrant godoc
+// @Summary Grant table access
+// @Description Grant a role an action (VIEW, INSERT, UPDATE, DELETE or ALL) on a table
+// @Tags rbac
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "Role ID"
+// @Param request body GrantRequest true "Grant"
+// @Success 200 {object} TableGrantResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/roles/{id}/tables [post]
+func (h *RBACHandler) PostTableGrant(c *gin.Context) {
+	var req GrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	grant, err := h.service.GrantTable(c.Param("id"), req.Object, req.Action, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Table access granted successfully", grant)
+}
+
+// DeleteTableGrant godoc
+// @Summary Revoke table access
+// @Description Revoke a table grant of a role
+// @Tags rbac
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "Role ID"
+// @Param grant_id path string true "Grant ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/roles/{id}/tables/{grant_id} [delete]
+func (h *RBACHandler) DeleteTableGrant(c *gin.Context) {
+	grantID, err := GetUUIDParam(c, "grant_id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid grant ID", err.Error())
+		return
+	}
+	if err := h.service.RevokeTable(c.Param("id"), grantID, GetAuditActor(c)); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Table access revoked successfully")
+}
+
+// PostPropertyGrant godoc
+// @Summary Grant property access
+// @Description Grant a role an action (VIEW, INSERT, UPDATE, DELETE or ALL) on a property type
+// @Tags rbac
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "Role ID"
+// @Param request body GrantRequest true "Grant"
+// @Success 200 {object} PropertyGrantResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/roles/{id}/properties [post]
+func (h *RBACHandler) PostPropertyGrant(c *gin.Context) {
+	var req GrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	grant, err := h.service.GrantProperty(c.Param("id"), req.Object, req.Action, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Property access granted successfully", grant)
+}
+
+// DeletePropertyGrant godoc
+// @Summary Revoke property access
+// @Description Revoke a property grant of a role
+// @Tags rbac
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "Role ID"
+// @Param grant_id path string true "Grant ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/roles/{id}/properties/{grant_id} [delete]
+func (h *RBACHandler) DeletePropertyGrant(c *gin.Context) {
+	grantID, err := GetUUIDParam(c, "grant_id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid grant ID", err.Error())
+		return
+	}
+	if err := h.service.RevokeProperty(c.Param("id"), grantID, GetAuditActor(c)); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Property access revoked successfully")
+}
+
+// GetUserRoles godoc
+// @Summary List user roles
+// @Description List role assignments of a user, including future and ended ones
+// @Tags rbac
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "User ID"
+// @Success 200 {object} RoleAssignmentListResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/users/{id}/roles [get]
+func (h *RBACHandler) GetUserRoles(c *gin.Context) {
+	userID, err := GetUUIDParam(c, "id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid user ID", err.Error())
+		return
+	}
+	roles, err := h.service.GetUserRoles(userID)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "User roles retrieved successfully", roles)
+}
+
+// PostUserRole godoc
+// @Summary Assign role
+// @Description Assign a role to a user for a period; roles are applied to sessions on their next refresh
+// @Tags rbac
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "User ID"
+// @Param request body AssignRoleRequest true "Assignment"
+// @Success 200 {object} RoleAssignmentResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/users/{id}/roles [post]
+func (h *RBACHandler) PostUserRole(c *gin.Context) {
+	userID, err := GetUUIDParam(c, "id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid user ID", err.Error())
+		return
+	}
+	var req AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	assignment, err := h.service.AssignRole(userID, req.Role, req.Start, req.End, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Role assigned successfully", assignment)
+}
+
+// DeleteUserRole godoc
+// @Summary Revoke role
+// @Description End a role assignment of a user
+// @Tags rbac
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "User ID"
+// @Param assignment_id path string true "Assignment ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/users/{id}/roles/{assignment_id} [delete]
+func (h *RBACHandler) DeleteUserRole(c *gin.Context) {
+	userID, err := GetUUIDParam(c, "id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid user ID", err.Error())
+		return
+	}
+	assignmentID, err := GetUUIDParam(c, "assignment_id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid assignment ID", err.Error())
+		return
+	}
+	if err := h.service.RevokeUserRole(userID, assignmentID, GetAuditActor(c)); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Role revoked successfully")
+}
+
+// GetUserPermissions godoc
+// @Summary Explain user permissions
+// @Description Show effective table and property permissions of a user and the role grants that give them
+// @Tags rbac
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "User ID"
+// @Success 200 {object} EffectivePermissionsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/users/{id}/permissions [get]
+func (h *RBACHandler) GetUserPermissions(c *gin.Context) {
+	userID, err := GetUUIDParam(c, "id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid user ID", err.Error())
+		return
+	}
+	permissions, err := h.service.ExplainPermissions(userID)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "User permissions retrieved successfully", permissions)
+}
+
+func (h *RBACHandler) RegisterRoutes(router *gin.RouterGroup) {
+	routes := middleware.Declare(router)
+	roles := routes.Group("admin/roles")
+	{
+		roles.GET("", middleware.TableAccess("t_d_role", models.ActionTypeView), h.GetRoles)
+		roles.POST("", middleware.TableAccess("t_d_role", models.ActionTypeInsert), h.CreateRole)
+		roles.DELETE("/:id", middleware.TableAccess("t_d_role", models.ActionTypeDelete), h.DeleteRole)
+		roles.POST("/:id/tables", middleware.TableAccess("t_d_table_role", models.ActionTypeInsert), h.PostTableGrant)
+		roles.DELETE("/:id/tables/:grant_id", middleware.TableAccess("t_d_table_role", models.ActionTypeDelete), h.DeleteTableGrant)
+		roles.POST("/:id/properties", middleware.TableAccess("t_properties_role", models.ActionTypeInsert), h.PostPropertyGrant)
+		roles.DELETE("/:id/properties/:grant_id", middleware.TableAccess("t_properties_role", models.ActionTypeDelete), h.DeletePropertyGrant)
+	}
+	users := routes.Group("admin/users/:id")
+	{
+		users.GET("/roles", middleware.TableAccess("t_user_role", models.ActionTypeView), h.GetUserRoles)
+		users.POST("/roles", middleware.TableAccess("t_user_role", models.ActionTypeInsert), h.PostUserRole)
+		users.DELETE("/roles/:assignment_id", middleware.TableAccess("t_user_role", models.ActionTypeDelete), h.DeleteUserRole)
+		users.GET("/permissions", middleware.TableAccess("t_user_role", models.ActionTypeView), h.GetUserPermissions)
+	}
+}
@@ -2,7 +2,8 @@ package handlers
 
 import (
 	"net/http"
-	_ "parier-server/internal/models"
+	"parier-server/internal/middleware"
+	"parier-server/internal/models"
 	"parier-server/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -51,7 +52,7 @@ func (h *ReferralHandler) GetReferralCode(c *gin.Context) {
 
 // GetReferralStats godoc
 // @Summary Get referral stats
-// @Description Get referral statistics (total referrals, earnings, list)
+// @Description Get referral statistics (total referrals, paid and pending earnings, list)
 // @Tags referral
 // @Produce json
 // @Security BearerAuth
@@ -74,10 +75,123 @@ func (h *ReferralHandler) GetReferralStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// ReferralReviewResponse represents a page of referrers with unpaid earnings
+type ReferralReviewResponse struct {
+	models.PaginationResponse
+	Data []service.ReferrerReview `json:"data"`
+}
+
+// FreezeReferralEarningsRequest represents the reason to hold the referrer's earnings
+type FreezeReferralEarningsRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
+
+// ReferralEarningsStatusResponse represents the number of earnings whose status changed
+type ReferralEarningsStatusResponse struct {
+	models.SuccessResponse
+	Data struct {
+		Changed int64 `json:"changed"`
+	} `json:"data"`
+}
+
+// GetReferralReview godoc
+// @Summary Review referral earnings
+// @Description Get referrers with pending or frozen earnings, the largest first, with fraud flags of their referees: SHARED_IP, SHARED_USER_AGENT (sessions of the referrer and the referee), CIRCULAR (the chain of referrers loops), NO_STAKES
+// @Tags referral
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit"
+// @Success 200 {object} ReferralReviewResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/referrals/review [get]
+func (h *ReferralHandler) GetReferralReview(c *gin.Context) {
+	pagination := GetPaginationFromQuery(c)
+	referrers, total, err := h.service.Review(pagination.Offset, pagination.Limit)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendPaginated(c, referrers, len(referrers), total)
+}
+
+// FreezeReferralEarnings godoc
+// @Summary Freeze referral earnings
+// @Description Freeze the referrer: pending earnings are frozen, new earnings are accrued frozen and nothing is paid until the referrer is unfrozen
+// @Tags referral
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "Referrer user ID"
+// @Param request body FreezeReferralEarningsRequest true "Freeze reason"
+// @Success 200 {object} ReferralEarningsStatusResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/referrals/{id}/freeze [post]
+func (h *ReferralHandler) FreezeReferralEarnings(c *gin.Context) {
+	referrerID, err := GetUUIDParam(c, "id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid user ID", err.Error())
+		return
+	}
+	var req FreezeReferralEarningsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	changed, err := h.service.Freeze(referrerID, req.Reason, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Referral earnings frozen", gin.H{"changed": changed})
+}
+
+// UnfreezeReferralEarnings godoc
+// @Summary Unfreeze referral earnings
+// @Description Lift the referrer freeze and return frozen earnings of the referrer to the payout queue
+// @Tags referral
+// @Produce json
+// @Security BearerAuth
+// @Security OAuth2Keycloak
+// @Param id path string true "Referrer user ID"
+// @Success 200 {object} ReferralEarningsStatusResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/referrals/{id}/unfreeze [post]
+func (h *ReferralHandler) UnfreezeReferralEarnings(c *gin.Context) {
+	referrerID, err := GetUUIDParam(c, "id")
+	if err != nil {
+		SendError(c, http.StatusBadRequest, "Invalid user ID", err.Error())
+		return
+	}
+	changed, err := h.service.Unfreeze(referrerID, GetAuditActor(c))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+	SendSuccess(c, "Referral earnings unfrozen", gin.H{"changed": changed})
+}
+
 func (h *ReferralHandler) RegisterRoutes(router *gin.RouterGroup) {
-	referral := router.Group("referral")
+	routes := middleware.Declare(router)
+	referral := routes.Group("referral")
+	{
+		referral.GET("/code", middleware.SessionAccess(), h.GetReferralCode)
+		referral.GET("/stats", middleware.SessionAccess(), h.GetReferralStats)
+	}
+	admin := routes.Group("admin/referrals")
 	{
-		referral.GET("/code", h.GetReferralCode)
-		referral.GET("/stats", h.GetReferralStats)
+		admin.GET("/review", middleware.TableAccess("t_referral_earning", models.ActionTypeView), h.GetReferralReview)
+		admin.POST("/:id/freeze", middleware.TableAccess("t_referral_earning", models.ActionTypeUpdate), h.FreezeReferralEarnings)
+		admin.POST("/:id/unfreeze", middleware.TableAccess("t_referral_earning", models.ActionTypeUpdate), h.UnfreezeReferralEarnings)
 	}
 }
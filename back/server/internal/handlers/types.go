@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"net/http"
+	"parier-server/internal/middleware"
 	"parier-server/internal/models"
+	"parier-server/internal/service"
 	"strconv"
 	"strings"
 
@@ -128,6 +130,18 @@ func GetUser(c *gin.Context) *models.User {
 	return user.(*models.User)
 }
 
+// GetAuditActor returns the author of a change for the audit log: user, session and client IP
+func GetAuditActor(c *gin.Context) service.AuditActor {
+	if session, err := middleware.GetSession(c); err == nil {
+		return service.AuditActorFromSession(session, c.ClientIP())
+	}
+	actor := service.AuditActor{IP: c.ClientIP()}
+	if user := GetUser(c); user != nil {
+		actor.User = user.ID.String()
+	}
+	return actor
+}
+
 // GetUUID extracts UUID from context
 func GetUUID(c *gin.Context, param string) uuid.UUID {
 	idStr := c.Param(param)
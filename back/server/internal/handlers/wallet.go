@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"parier-server/internal/middleware"
 	_ "parier-server/internal/models"
 	"parier-server/internal/service"
 	"strconv"
@@ -93,7 +94,7 @@ func (h *WalletHandler) Deposit(c *gin.Context) {
 		desc = "Deposit"
 	}
 
-	balance, err := h.service.Deposit(user.ID, req.Amount, desc)
+	balance, err := h.service.Deposit(user.ID, req.Amount, desc, GetAuditActor(c))
 	if err != nil {
 		if svcErr := service.GetServiceError(err); svcErr != nil {
 			SendError(c, http.StatusBadRequest, svcErr.Code, svcErr.Message)
@@ -145,7 +146,7 @@ func (h *WalletHandler) Withdraw(c *gin.Context) {
 		desc = "Withdrawal"
 	}
 
-	balance, err := h.service.Withdraw(user.ID, req.Amount, desc)
+	balance, err := h.service.Withdraw(user.ID, req.Amount, desc, GetAuditActor(c))
 	if err != nil {
 		if svcErr := service.GetServiceError(err); svcErr != nil {
 			SendError(c, http.StatusBadRequest, svcErr.Code, svcErr.Message)
@@ -203,11 +204,11 @@ func (h *WalletHandler) GetTransactions(c *gin.Context) {
 }
 
 func (h *WalletHandler) RegisterRoutes(router *gin.RouterGroup) {
-	wallet := router.Group("wallet")
+	wallet := middleware.Declare(router).Group("wallet")
 	{
-		wallet.GET("/balance", h.GetBalance)
-		wallet.POST("/deposit", h.Deposit)
-		wallet.POST("/withdraw", h.Withdraw)
-		wallet.GET("/transactions", h.GetTransactions)
+		wallet.GET("/balance", middleware.SessionAccess(), h.GetBalance)
+		wallet.POST("/deposit", middleware.SessionAccess(), h.Deposit)
+		wallet.POST("/withdraw", middleware.SessionAccess(), h.Withdraw)
+		wallet.GET("/transactions", middleware.SessionAccess(), h.GetTransactions)
 	}
 }
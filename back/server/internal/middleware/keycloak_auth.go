@@ -1,9 +1,12 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"parier-server/internal/config"
 	"parier-server/internal/models"
@@ -12,12 +15,32 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
+// visitorSecret отделяет подпись cookie посетителя от подписи cookie сессии
+func visitorSecret(cfg *config.Config) string {
+	return cfg.Store.Secret + ":visitor"
+}
+
+// VisitorID возвращает постоянный ID анонимного посетителя из cookie, при отсутствии выдаёт новый.
+// ID переживает сессии, поэтому активность посетителя сохраняется до входа.
+func VisitorID(c *gin.Context, cfg *config.Config) uuid.UUID {
+	id := uuid.Nil
+	if cookie, err := c.Cookie(cfg.Store.AnonymousCookieName); err == nil && cookie != "" {
+		if verified, ok := util.VerifySession(cookie, visitorSecret(cfg)); ok {
+			id = verified
+		}
+	}
+	if id == uuid.Nil {
+		id = uuid.New()
+	}
+	c.SetCookie(cfg.Store.AnonymousCookieName, util.SignSession(id, visitorSecret(cfg)), int(cfg.Store.AnonymousDuration.Seconds()), cfg.Store.CookiePath, cfg.Store.CookieDomain, cfg.Store.CookieSecure, cfg.Store.CookieHttpOnly)
+	return id
+}
+
 func AnonymousSession(c *gin.Context, cfg *config.Config, keycloakService *service.KeycloakService, session *models.TSession) {
 	var user *models.User = &models.User{
-		ID:         uuid.New(),
+		ID:         VisitorID(c, cfg),
 		SessionID:  uuid.Nil,
 		ExternalID: "",
 		Username:   "anonymous",
@@ -29,7 +52,7 @@ func AnonymousSession(c *gin.Context, cfg *config.Config, keycloakService *servi
 	var claims *service.KeycloakJWTClaims = &service.KeycloakJWTClaims{}
 	claims.PreferredUsername = "anonymous"
 	claims.Email = "anonymous@parier.com"
-	claims.Subject = uuid.New().String()
+	claims.Subject = user.ID.String()
 	claims.Sid = uuid.New().String()
 	claims.RealmAccess.Roles = []string{models.RoleAnonymous.String()}
 	claims.ResourceAccess = make(map[string]service.KeycloakRealmAccess)
@@ -71,8 +94,18 @@ func GetSessionFromCookie(c *gin.Context, cfg *config.Config, keycloakService *s
 	if err != nil {
 		return nil, err
 	}
+	// Обновляем токены Keycloak до истечения access токена
+	refreshed, err := keycloakService.RefreshSession(c, session)
+	if errors.Is(err, service.ErrSessionExpired) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("Failed to refresh session %s: %v", session.CkId, err)
+		refreshed = session
+	}
+	keycloakService.TouchSession(refreshed)
 
-	return session, nil
+	return refreshed, nil
 }
 
 func GetInitialSession(c *gin.Context, cfg *config.Config, session *models.TSession, user *models.User) {
@@ -81,6 +114,11 @@ func GetInitialSession(c *gin.Context, cfg *config.Config, session *models.TSess
 	}
 	sessionCookie := util.SignSession(session.CkId, cfg.Store.Secret)
 	c.SetCookie(cfg.Store.CookieName, sessionCookie, int(cfg.Store.SessionDuration.Seconds()), cfg.Store.CookiePath, cfg.Store.CookieDomain, cfg.Store.CookieSecure, cfg.Store.CookieHttpOnly)
+	setAuthContext(c, session, user)
+}
+
+// setAuthContext кладёт пользователя и сессию в контекст запроса и передаёт управление дальше
+func setAuthContext(c *gin.Context, session *models.TSession, user *models.User) {
 	c.Set("user_id", user.ID.String())
 	c.Set("external_id", user.ExternalID)
 	c.Set("roles", &user.Roles)
@@ -94,8 +132,14 @@ func GetInitialSession(c *gin.Context, cfg *config.Config, session *models.TSess
 }
 
 // KeycloakAuthMiddleware создает middleware для аутентификации через Keycloak
-func KeycloakAuthMiddleware(cfg *config.Config, keycloakService *service.KeycloakService, isError bool) gin.HandlerFunc {
+func KeycloakAuthMiddleware(cfg *config.Config, keycloakService *service.KeycloakService, tokenService *service.APITokenService, isError bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// API токены интеграций проверяются без cookie сессии
+		if tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && util.IsAPIToken(tokenString) {
+			handleAPITokenAuth(c, tokenService, tokenString)
+			return
+		}
+
 		session, err := GetSessionFromCookie(c, cfg, keycloakService)
 		if err != nil {
 			if isError {
@@ -130,6 +174,51 @@ func KeycloakAuthMiddleware(cfg *config.Config, keycloakService *service.Keycloa
 	}
 }
 
+// handleAPITokenAuth аутентифицирует запрос API токеном. Сессия не сохраняется и cookie не выставляется.
+// Токен принимается только маршрутами с RequireTableAccess и RequirePropertyAccess: там проверяются его области действия.
+func handleAPITokenAuth(c *gin.Context, tokenService *service.APITokenService, tokenString string) {
+	user, token, err := tokenService.Authenticate(tokenString)
+	if err != nil {
+		if !errors.Is(err, service.ErrInvalidAPIToken) {
+			log.Printf("Failed to authenticate api token: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid API token",
+		})
+		c.Abort()
+		return
+	}
+	// Токен ограничен доступами к таблицам и свойствам, поэтому принимается только маршрутами с TableAccess и PropertyAccess
+	if perm, ok := RoutePermission(c); !ok || !perm.IsScoped() {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "API tokens are not accepted by this endpoint",
+		})
+		c.Abort()
+		return
+	}
+	expire := time.Now().Add(time.Minute)
+	if token.CtExpire != nil {
+		expire = *token.CtExpire
+	}
+	session := &models.TSession{
+		CkId:     token.CkId,
+		CkUser:   &user.ID,
+		CtExpire: expire,
+		User:     *user,
+	}
+	c.Set("api_token", token)
+	setAuthContext(c, session, user)
+}
+
+// GetAPIToken возвращает API токен запроса или nil, если запрос аутентифицирован иначе
+func GetAPIToken(c *gin.Context) *models.TAPIToken {
+	token, ok := c.Get("api_token")
+	if !ok {
+		return nil
+	}
+	return token.(*models.TAPIToken)
+}
+
 // handleKeycloakAuth обрабатывает аутентификацию для конкретного realm
 func handleKeycloakAuth(c *gin.Context, cfg *config.Config, tokenString string, keycloakService *service.KeycloakService, realm string, session *models.TSession) {
 	claims, err := keycloakService.ValidateToken(c, tokenString, realm)
@@ -164,12 +253,15 @@ func handleKeycloakAuth(c *gin.Context, cfg *config.Config, tokenString string,
 			return
 		}
 	} else {
-		session.CkUser = &user.ID
-		session.User = *user
-
-		go func() {
-			keycloakService.UpdateSession(session)
-		}()
+		session, err = keycloakService.BindSession(session, user)
+		if err != nil {
+			log.Printf("Failed to bind session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to bind session",
+			})
+			c.Abort()
+			return
+		}
 	}
 	user.SessionID = session.CkId
 	GetInitialSession(c, cfg, session, user)
@@ -388,48 +480,69 @@ func GetEmail(c *gin.Context) (string, error) {
 // RequireTableAccess middleware that checks if user has access to specific table with specific action
 func RequireTableAccess(tableName string, action models.ActionType) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		db := c.MustGet("db").(*gorm.DB)
-		userRoles, exists := c.Get("roles")
-		if !exists {
+		permissions, err := RequestPermissions(c)
+		if err != nil {
+			log.Printf("Failed to load permissions: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to check table access permissions",
+			})
+			c.Abort()
+			return
+		}
+
+		if !permissions.CanTable(tableName, action) {
 			c.JSON(http.StatusForbidden, gin.H{
-				"error": "User roles not found in context",
+				"error": fmt.Sprintf("Access denied to table '%s' for action '%s'", tableName, action),
 			})
 			c.Abort()
 			return
 		}
 
-		roles, ok := userRoles.(*[]string)
-		if !ok {
+		// API токен сужает права владельца до своих областей действия
+		if token := GetAPIToken(c); token != nil && !token.Allows(models.TokenScopeTable, tableName, action) {
 			c.JSON(http.StatusForbidden, gin.H{
-				"error": "Invalid user roles format",
+				"error": fmt.Sprintf("API token scope does not allow '%s' on table '%s'", action, tableName),
 			})
 			c.Abort()
 			return
 		}
 
-		// Check table access permissions
-		hasAccess := false
+		c.Next()
+	}
+}
 
-		var tableRoles []models.TDTableRole
-		if err := db.Where("ck_role IN ? AND ck_table = ? AND ct_delete IS NULL", *roles, tableName).
-			Find(&tableRoles).Error; err != nil {
+// CanProperty проверяет доступ action к типу свойства по ролям посетителя
+// и, для запроса по API токену, по областям действия токена
+func CanProperty(c *gin.Context, propertyType string, action models.ActionType) (bool, error) {
+	permissions, err := RequestPermissions(c)
+	if err != nil {
+		return false, err
+	}
+	if !permissions.CanProperty(propertyType, action) {
+		return false, nil
+	}
+	if token := GetAPIToken(c); token != nil && !token.Allows(models.TokenScopeProperty, propertyType, action) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// RequirePropertyAccess middleware that checks if user has access to specific property type with specific action
+func RequirePropertyAccess(propertyType string, action models.ActionType) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, err := CanProperty(c, propertyType, action)
+		if err != nil {
+			log.Printf("Failed to load permissions: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to check table access permissions",
+				"error": "Failed to check property access permissions",
 			})
 			c.Abort()
 			return
 		}
 
-		for _, tableRole := range tableRoles {
-			if tableRole.CrAction == models.ActionTypeAll || tableRole.CrAction == action {
-				hasAccess = true
-				break
-			}
-		}
-
-		if !hasAccess {
+		if !allowed {
 			c.JSON(http.StatusForbidden, gin.H{
-				"error": fmt.Sprintf("Access denied to table '%s' for action '%s'", tableName, action),
+				"error": fmt.Sprintf("Access denied to property '%s' for action '%s'", propertyType, action),
 			})
 			c.Abort()
 			return
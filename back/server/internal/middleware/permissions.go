@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"parier-server/internal/models"
+	"parier-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type accessKind int
+
+const (
+	accessPublic accessKind = iota + 1
+	accessSession
+	accessTable
+	accessProperty
+)
+
+// Permission - объявленное право доступа к маршруту
+type Permission struct {
+	kind     accessKind
+	Table    string
+	Property string
+	Action   models.ActionType
+}
+
+// TableAccess - маршрут доступен ролям с доступом action к таблице (t_d_table_role)
+func TableAccess(table string, action models.ActionType) Permission {
+	return Permission{kind: accessTable, Table: table, Action: action}
+}
+
+// PropertyAccess - маршрут доступен ролям с доступом action к типу свойства (t_properties_role)
+func PropertyAccess(propertyType string, action models.ActionType) Permission {
+	return Permission{kind: accessProperty, Property: propertyType, Action: action}
+}
+
+// SessionAccess - маршрут работает только с данными текущего посетителя и доступен любой сессии
+func SessionAccess() Permission {
+	return Permission{kind: accessSession}
+}
+
+// PublicAccess - маршрут не требует сессии: вход, подписанные ссылки
+func PublicAccess() Permission {
+	return Permission{kind: accessPublic}
+}
+
+// IsScoped сообщает, проверяет ли право доступ к таблице или свойству,
+// то есть то, чем ограничены области действия API токена
+func (p Permission) IsScoped() bool {
+	return p.kind == accessTable || p.kind == accessProperty
+}
+
+func (p Permission) String() string {
+	switch p.kind {
+	case accessPublic:
+		return "public"
+	case accessSession:
+		return "session"
+	case accessTable:
+		return fmt.Sprintf("table:%s:%s", p.Table, p.Action)
+	case accessProperty:
+		return fmt.Sprintf("property:%s:%s", p.Property, p.Action)
+	default:
+		return "undeclared"
+	}
+}
+
+// PermissionsMiddleware подключает к запросу вычислитель прав
+func PermissionsMiddleware(evaluator *service.PermissionEvaluator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("permission_evaluator", evaluator)
+		c.Next()
+	}
+}
+
+// RequestPermissions возвращает права текущего посетителя; они загружаются один раз за запрос.
+// Права пользователя считаются по его действующим ролям в БД, анонимной сессии - по ролям сессии.
+func RequestPermissions(c *gin.Context) (*service.Permissions, error) {
+	if permissions, ok := c.Get("permissions"); ok {
+		return permissions.(*service.Permissions), nil
+	}
+	value, ok := c.Get("permission_evaluator")
+	if !ok {
+		return nil, errors.New("permission evaluator not found in context")
+	}
+	evaluator := value.(*service.PermissionEvaluator)
+
+	var permissions *service.Permissions
+	var err error
+	if session, _ := GetSession(c); session != nil && session.CkUser != nil {
+		permissions, err = evaluator.ForUser(*session.CkUser)
+	} else {
+		var roles []string
+		if value, ok := c.Get("roles"); ok {
+			if sessionRoles, ok := value.(*[]string); ok && sessionRoles != nil {
+				roles = *sessionRoles
+			}
+		}
+		permissions, err = evaluator.ForRoles(roles)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.Set("permissions", permissions)
+	return permissions, nil
+}
+
+// routePermissions - объявленные права маршрутов по "METHOD /full/path"
+var routePermissions = struct {
+	sync.RWMutex
+	routes map[string]Permission
+}{routes: make(map[string]Permission)}
+
+func routeKey(method, fullPath string) string {
+	return method + " " + fullPath
+}
+
+// RoutePermission возвращает объявленное право маршрута запроса
+func RoutePermission(c *gin.Context) (Permission, bool) {
+	routePermissions.RLock()
+	defer routePermissions.RUnlock()
+	perm, ok := routePermissions.routes[routeKey(c.Request.Method, c.FullPath())]
+	return perm, ok
+}
+
+// Routes регистрирует маршруты группы вместе с их правами доступа.
+// Право TableAccess добавляет в цепочку RequireTableAccess, PropertyAccess - RequirePropertyAccess.
+type Routes struct {
+	group *gin.RouterGroup
+}
+
+// Declare оборачивает группу для регистрации маршрутов с правами
+func Declare(group *gin.RouterGroup) *Routes {
+	return &Routes{group: group}
+}
+
+// Group создаёт вложенную группу
+func (r *Routes) Group(relativePath string, handlers ...gin.HandlerFunc) *Routes {
+	return &Routes{group: r.group.Group(relativePath, handlers...)}
+}
+
+func (r *Routes) GET(relativePath string, perm Permission, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodGet, relativePath, perm, handlers...)
+}
+
+func (r *Routes) POST(relativePath string, perm Permission, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodPost, relativePath, perm, handlers...)
+}
+
+func (r *Routes) PUT(relativePath string, perm Permission, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodPut, relativePath, perm, handlers...)
+}
+
+func (r *Routes) DELETE(relativePath string, perm Permission, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodDelete, relativePath, perm, handlers...)
+}
+
+// Handle регистрирует маршрут и запоминает его право доступа
+func (r *Routes) Handle(method, relativePath string, perm Permission, handlers ...gin.HandlerFunc) {
+	if perm.kind == 0 {
+		panic(fmt.Sprintf("route %s %s: permission is not declared", method, joinPaths(r.group.BasePath(), relativePath)))
+	}
+	switch perm.kind {
+	case accessTable:
+		handlers = append([]gin.HandlerFunc{RequireTableAccess(perm.Table, perm.Action)}, handlers...)
+	case accessProperty:
+		handlers = append([]gin.HandlerFunc{RequirePropertyAccess(perm.Property, perm.Action)}, handlers...)
+	}
+	r.group.Handle(method, relativePath, handlers...)
+
+	routePermissions.Lock()
+	routePermissions.routes[routeKey(method, joinPaths(r.group.BasePath(), relativePath))] = perm
+	routePermissions.Unlock()
+}
+
+// CheckRoutePermissions проверяет при старте, что у каждого маршрута с префиксом объявлено право доступа
+func CheckRoutePermissions(routes gin.RoutesInfo, prefix string) error {
+	routePermissions.RLock()
+	defer routePermissions.RUnlock()
+	var missing []string
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Path, prefix) {
+			continue
+		}
+		if _, ok := routePermissions.routes[routeKey(route.Method, route.Path)]; !ok {
+			missing = append(missing, routeKey(route.Method, route.Path))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("routes without declared permission: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// joinPaths повторяет сборку полного пути маршрута в gin
+func joinPaths(absolutePath, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+	finalPath := path.Join(absolutePath, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(finalPath, "/") {
+		return finalPath + "/"
+	}
+	return finalPath
+}
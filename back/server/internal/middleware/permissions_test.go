@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"parier-server/internal/config"
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+	"parier-server/internal/service"
+	"parier-server/internal/util/dbtest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// grantStore выдаёт роль MEMBER с доступом к таблице t_bet и к свойству USER_PHONE на просмотр
+type grantStore struct{}
+
+func (grantStore) GetUserRoleByID(uuid.UUID) ([]string, error) { return []string{"MEMBER"}, nil }
+
+func (grantStore) GetTableRolesByRoles([]string) ([]models.TDTableRole, error) {
+	return []models.TDTableRole{{CkRole: "MEMBER", CkTable: "t_bet", CrAction: models.ActionTypeView}}, nil
+}
+
+func (grantStore) GetPropertiesRolesByRoles([]string) ([]models.TPropertiesRole, error) {
+	return []models.TPropertiesRole{{CkRole: "MEMBER", CkType: "USER_PHONE", CrAction: models.ActionTypeView}}, nil
+}
+
+// testEngine собирает gin с вычислителем прав; token, если задан, кладётся в запрос как API токен
+func testEngine(token *models.TAPIToken) (*gin.Engine, *Routes) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	evaluator := service.NewPermissionEvaluator(grantStore{}, 0)
+	userID := uuid.New()
+	engine.Use(PermissionsMiddleware(evaluator), func(c *gin.Context) {
+		c.Set("session", &models.TSession{CkUser: &userID})
+		if token != nil {
+			c.Set("api_token", token)
+		}
+	})
+	return engine, Declare(engine.Group("/api"))
+}
+
+func serve(engine *gin.Engine, method, target string) int {
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w.Code
+}
+
+func TestPropertyAccess(t *testing.T) {
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	scope := func(kind models.TokenScopeKind, object string, action models.ActionType) *models.TAPIToken {
+		return &models.TAPIToken{Scopes: []models.TAPITokenScope{{CrScope: kind, CkObject: object, CrAction: action}}}
+	}
+
+	tests := []struct {
+		name   string
+		token  *models.TAPIToken
+		perm   Permission
+		status int
+	}{
+		{"role grant", nil, PropertyAccess("USER_PHONE", models.ActionTypeView), http.StatusOK},
+		{"no role grant", nil, PropertyAccess("USER_PHONE", models.ActionTypeUpdate), http.StatusForbidden},
+		{"token scope", scope(models.TokenScopeProperty, "USER_PHONE", models.ActionTypeAll), PropertyAccess("USER_PHONE", models.ActionTypeView), http.StatusOK},
+		{"token scope of another property", scope(models.TokenScopeProperty, "USER_EMAIL", models.ActionTypeAll), PropertyAccess("USER_PHONE", models.ActionTypeView), http.StatusForbidden},
+		{"table scope of the same name", scope(models.TokenScopeTable, "USER_PHONE", models.ActionTypeAll), PropertyAccess("USER_PHONE", models.ActionTypeView), http.StatusForbidden},
+		{"property scope on a table route", scope(models.TokenScopeProperty, "t_bet", models.ActionTypeAll), TableAccess("t_bet", models.ActionTypeView), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, routes := testEngine(tt.token)
+			routes.GET("/phone", tt.perm, ok)
+			if status := serve(engine, http.MethodGet, "/api/phone"); status != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, status)
+			}
+		})
+	}
+}
+
+func TestCheckRoutePermissions(t *testing.T) {
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	group := engine.Group("/declared")
+	routes := Declare(group)
+	routes.GET("", SessionAccess(), ok)
+	routes.GET("/bets/", TableAccess("t_bet", models.ActionTypeView), ok)
+	admin := routes.Group("/admin").Group("/users/")
+	admin.GET("/:id", TableAccess("t_user", models.ActionTypeView), ok)
+	admin.DELETE("", TableAccess("t_user", models.ActionTypeDelete), ok)
+	engine.GET("/outside", ok)
+
+	// завершающий слеш и вложенные группы дают те же пути, что и у gin
+	if err := CheckRoutePermissions(engine.Routes(), "/declared"); err != nil {
+		t.Errorf("declared routes must pass the check: %v", err)
+	}
+
+	group.POST("/raw", ok)
+	routes.Group("/admin").Group("/users").Handle(http.MethodPut, "/:id", TableAccess("t_user", models.ActionTypeUpdate), ok)
+	engine.Group("/declared/admin").PATCH("/users/:id", ok)
+	err := CheckRoutePermissions(engine.Routes(), "/declared")
+	if err == nil {
+		t.Fatal("routes without declared permission must fail the check")
+	}
+	for _, route := range []string{"POST /declared/raw", "PATCH /declared/admin/users/:id"} {
+		if !strings.Contains(err.Error(), route) {
+			t.Errorf("expected %s in %v", route, err)
+		}
+	}
+	if strings.Contains(err.Error(), "/outside") || strings.Contains(err.Error(), "PUT ") {
+		t.Errorf("only undeclared routes with the prefix must be reported, got %v", err)
+	}
+}
+
+func TestAPITokenRoutes(t *testing.T) {
+	secret, tokenID, userID := "prt_secret", uuid.NewString(), uuid.NewString()
+	db, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		switch {
+		case q.Has(`FROM "t_api_token_scope"`):
+			return dbtest.Result{
+				Columns: []string{"ck_id", "ck_token", "cr_scope", "ck_object", "cr_action"},
+				Rows:    [][]driver.Value{{uuid.NewString(), tokenID, string(models.TokenScopeTable), "t_bet", string(models.ActionTypeView)}},
+			}
+		case q.Has(`FROM "t_api_token"`):
+			return dbtest.Result{
+				Columns: []string{"ck_id", "ck_user", "cv_name", "cv_prefix", "cv_hash"},
+				Rows:    [][]driver.Value{{tokenID, userID, "ci", "prt_secr", q.Args[0]}},
+			}
+		}
+		return dbtest.Result{}
+	})
+	tokens := service.NewAPITokenService(repository.NewAPITokenRepository(db), repository.NewUserRepository(db), nil)
+
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(PermissionsMiddleware(service.NewPermissionEvaluator(grantStore{}, 0)), KeycloakAuthMiddleware(&config.Config{}, nil, tokens, true))
+	routes := Declare(engine.Group("/token"))
+	routes.GET("/me", SessionAccess(), ok)
+	routes.GET("/login", PublicAccess(), ok)
+	routes.GET("/bets", TableAccess("t_bet", models.ActionTypeView), ok)
+
+	for target, want := range map[string]int{"/token/me": http.StatusForbidden, "/token/login": http.StatusForbidden, "/token/bets": http.StatusOK} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer "+secret)
+		engine.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s with an API token: expected status %d, got %d", target, want, w.Code)
+		}
+	}
+}
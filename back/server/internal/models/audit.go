@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TAuditLog - запись журнала аудита. Записи только добавляются; cv_hash связывает запись с предыдущей
+type TAuditLog struct {
+	CkId         uuid.UUID  `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
+	CnSeq        int64      `json:"cn_seq" gorm:"column:cn_seq;type:bigint;not null;uniqueIndex"`
+	CkActor      string     `json:"ck_actor" gorm:"column:ck_actor;type:varchar(255);not null;index"`
+	CkSession    *uuid.UUID `json:"ck_session,omitempty" gorm:"column:ck_session;type:uuid"`
+	CvIp         *string    `json:"cv_ip,omitempty" gorm:"column:cv_ip;type:varchar(64)"`
+	CvAction     string     `json:"cv_action" gorm:"column:cv_action;type:varchar(100);not null"`
+	CvEntityType string     `json:"cv_entity_type" gorm:"column:cv_entity_type;type:varchar(100);not null"`
+	CkEntity     string     `json:"ck_entity" gorm:"column:ck_entity;type:varchar(255);not null"`
+	CjBefore     *string    `json:"cj_before,omitempty" gorm:"column:cj_before;type:json"`
+	CjAfter      *string    `json:"cj_after,omitempty" gorm:"column:cj_after;type:json"`
+	CvPrevHash   string     `json:"cv_prev_hash" gorm:"column:cv_prev_hash;type:varchar(64);not null"`
+	CvHash       string     `json:"cv_hash" gorm:"column:cv_hash;type:varchar(64);not null"`
+	CtCreate     time.Time  `json:"ct_create" gorm:"column:ct_create;type:timestamp;not null"`
+}
+
+func (TAuditLog) TableName() string {
+	return "t_audit_log"
+}
+
+// AuditLogFilter - условия выборки журнала аудита
+type AuditLogFilter struct {
+	Actor      *string
+	Action     *string
+	EntityType *string
+	EntityID   *string
+	From       *time.Time
+	To         *time.Time
+}
@@ -235,3 +235,42 @@ const (
 	ChatUserBanTypeBan  ChatUserBanType = "BAN"
 	ChatUserBanTypeMute ChatUserBanType = "MUTE"
 )
+
+// ReferralEarningType - правило начисления реферального вознаграждения (SIGNUP, STAKE, WINNINGS)
+type ReferralEarningType string
+
+const (
+	ReferralEarningTypeSignup   ReferralEarningType = "SIGNUP"
+	ReferralEarningTypeStake    ReferralEarningType = "STAKE"
+	ReferralEarningTypeWinnings ReferralEarningType = "WINNINGS"
+)
+
+// ReferralEarningStatus - статус реферального вознаграждения (PENDING, PAID, FROZEN)
+type ReferralEarningStatus string
+
+const (
+	ReferralEarningStatusPending ReferralEarningStatus = "PENDING"
+	ReferralEarningStatusPaid    ReferralEarningStatus = "PAID"
+	ReferralEarningStatusFrozen  ReferralEarningStatus = "FROZEN"
+)
+
+// CommentRemover - кто удалил комментарий (AUTHOR, MODERATOR)
+type CommentRemover string
+
+const (
+	CommentRemoverAuthor    CommentRemover = "AUTHOR"
+	CommentRemoverModerator CommentRemover = "MODERATOR"
+)
+
+// NotificationType - событие, о котором уведомляется пользователь (справочник t_d_notification_type)
+type NotificationType string
+
+const (
+	NotificationTypeCommentReply     NotificationType = "COMMENT_REPLY"
+	NotificationTypeCommentMention   NotificationType = "COMMENT_MENTION"
+	NotificationTypeCommentLike      NotificationType = "COMMENT_LIKE"
+	NotificationTypeBetLike          NotificationType = "BET_LIKE"
+	NotificationTypeWalletDeposit    NotificationType = "WALLET_DEPOSIT"
+	NotificationTypeWalletWithdrawal NotificationType = "WALLET_WITHDRAWAL"
+	NotificationTypeAdminCredit      NotificationType = "ADMIN_CREDIT"
+)
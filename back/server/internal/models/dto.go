@@ -199,6 +199,8 @@ type AuthorResponse struct {
 	Likes      int        `json:"likes"`
 	Rating     int        `json:"rating"`
 	WinRate    int        `json:"win_rate"`
+	Reputation float64    `json:"reputation"`     // репутация от 0 до 100
+	RatingAvg  float64    `json:"rating_average"` // средняя полученная оценка пользователя и его ставок, 0 без оценок
 	Interests  *[]string  `json:"interests"`
 	Location   *string    `json:"location"`
 	CreatedAt  time.Time  `json:"created_at"`
@@ -246,15 +248,257 @@ type BetCreateRequest struct {
 }
 
 type BetCommentResponse struct {
+	ID            uuid.UUID                `json:"id"`
+	Content       string                   `json:"content"` // пусто у удалённого комментария
+	CreatedAt     time.Time                `json:"created_at"`
+	UpdatedAt     time.Time                `json:"updated_at"`
+	DeletedAt     *time.Time               `json:"deleted_at,omitempty"`
+	EditedAt      *time.Time               `json:"edited_at,omitempty"`
+	RemovedBy     *CommentRemover          `json:"removed_by,omitempty"`
+	CanEdit       bool                     `json:"can_edit"`                 // текущий пользователь - автор и срок изменения не истёк
+	EditableUntil *time.Time               `json:"editable_until,omitempty"` // до какого времени автор может изменить текст
+	Mentions      []CommentMentionResponse `json:"mentions,omitempty"`       // упоминания для выделения в тексте
+	Author        AuthorResponse           `json:"author"`
+	Parent        *BetCommentResponse      `json:"parent,omitempty"`
+	Likes         int                      `json:"likes"`
+	IsLikedByMe   bool                     `json:"is_liked_by_me"`
+}
+
+// CommentMentionResponse - упоминание пользователя в тексте комментария.
+// Offset и Length считаются в символах (Unicode code points), упоминание включает @
+type CommentMentionResponse struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Offset   int       `json:"offset"`
+	Length   int       `json:"length"`
+}
+
+// MentionResponse - непрочитанное упоминание текущего пользователя
+type MentionResponse struct {
+	ID        uuid.UUID          `json:"id"`
+	BetID     uuid.UUID          `json:"bet_id"`
+	Comment   BetCommentResponse `json:"comment"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// MentionReadRequest - упоминания, которые нужно отметить прочитанными; пустой список - все
+type MentionReadRequest struct {
+	IDs []uuid.UUID `json:"ids,omitempty"`
+}
+
+// NotificationResponse - уведомление с заголовком и текстом на языке запроса
+type NotificationResponse struct {
+	ID        uuid.UUID        `json:"id"`
+	Type      NotificationType `json:"type"`
+	Title     string           `json:"title"`
+	Body      string           `json:"body"`
+	Payload   map[string]any   `json:"payload"`
+	ReadAt    *time.Time       `json:"read_at,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// NotificationReadRequest - уведомления, которые нужно отметить прочитанными; пустой список - все
+type NotificationReadRequest struct {
+	IDs []uuid.UUID `json:"ids,omitempty"`
+}
+
+// NotificationPreferences - настройки уведомлений пользователя. Уведомления отключённых
+// типов не создаются; в приложении остальные доступны всегда
+type NotificationPreferences struct {
+	Email   bool               `json:"email"`
+	Webhook *string            `json:"webhook,omitempty"`
+	Muted   []NotificationType `json:"muted"`
+}
+
+// NotificationPreferencesRequest - изменяемые настройки уведомлений; пустой webhook отключает его
+type NotificationPreferencesRequest struct {
+	Email   *bool               `json:"email,omitempty"`
+	Webhook *string             `json:"webhook,omitempty"`
+	Muted   *[]NotificationType `json:"muted,omitempty"`
+}
+
+// ChatResponse - чат с ролью текущего пользователя в нём
+type ChatResponse struct {
+	ID            uuid.UUID       `json:"id"`
+	Type          ChatType        `json:"type"`
+	BetID         *uuid.UUID      `json:"bet_id,omitempty"` // чат - комната ставки
+	Direct        bool            `json:"direct"`           // личный чат двух пользователей
+	Peer          *AuthorResponse `json:"peer,omitempty"`   // собеседник в личном чате
+	Members       int64           `json:"members"`
+	IsMember      bool            `json:"is_member"`
+	IsAdmin       bool            `json:"is_admin"`
+	IsModerator   bool            `json:"is_moderator"`
+	Restriction   *ChatBanInfo    `json:"restriction,omitempty"` // действующий бан или мут текущего пользователя
+	LastMessageAt *time.Time      `json:"last_message_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// ChatBanInfo - ограничение пользователя в чате; Until nil - бессрочное
+type ChatBanInfo struct {
+	Type   ChatUserBanType `json:"type"`
+	Reason *string         `json:"reason,omitempty"`
+	Start  time.Time       `json:"start"`
+	Until  *time.Time      `json:"until,omitempty"`
+}
+
+// ChatMemberResponse - участник чата
+type ChatMemberResponse struct {
+	User        AuthorResponse `json:"user"`
+	IsAdmin     bool           `json:"is_admin"`
+	IsModerator bool           `json:"is_moderator"`
+	JoinedAt    time.Time      `json:"joined_at"`
+}
+
+// ChatMessageResponse - сообщение чата. Likes - число лайков по типам
+type ChatMessageResponse struct {
+	ID        uuid.UUID        `json:"id"`
+	ChatID    uuid.UUID        `json:"chat_id"`
+	ParentID  *uuid.UUID       `json:"parent_id,omitempty"`
+	Author    AuthorResponse   `json:"author"`
+	Content   string           `json:"content"`
+	Likes     map[string]int64 `json:"likes"`
+	MyLike    *string          `json:"my_like,omitempty"` // тип лайка текущего пользователя
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// ChatDirectRequest - собеседник личного чата
+type ChatDirectRequest struct {
+	UserID uuid.UUID `json:"user_id" form:"user_id" binding:"required"`
+	DefaultRequest
+}
+
+// ChatMessagesRequest - страница истории чата, от новых сообщений к старым
+type ChatMessagesRequest struct {
+	DefaultRequest
+	Cursor *uuid.UUID `json:"cursor,omitempty" form:"cursor"` // next_cursor из предыдущего ответа
+	Limit  *int       `json:"limit,omitempty" form:"limit"`
+}
+
+// ChatMessagesResponse - страница истории чата
+type ChatMessagesResponse struct {
+	Messages   []ChatMessageResponse `json:"messages"`
+	HasMore    bool                  `json:"has_more"`
+	NextCursor *uuid.UUID            `json:"next_cursor,omitempty"`
+}
+
+// ChatMessageCreateRequest - новое сообщение или ответ на сообщение parent_id
+type ChatMessageCreateRequest struct {
+	Content  string     `json:"content" form:"content" binding:"required"`
+	ParentID *uuid.UUID `json:"parent_id,omitempty" form:"parent_id"`
+	DefaultRequest
+}
+
+// ChatMessageLikeRequest - лайк сообщению; тип из t_d_like_type, по умолчанию LIKE
+type ChatMessageLikeRequest struct {
+	Type *string `json:"type,omitempty" form:"type"`
+	DefaultRequest
+}
+
+// ChatJoinRequest - вход в чат; для приватного чата нужны credentials приглашения
+type ChatJoinRequest struct {
+	Credentials *string `json:"credentials,omitempty" form:"credentials"`
+	DefaultRequest
+}
+
+// ChatModeratorRequest - назначение или снятие модератора чата
+type ChatModeratorRequest struct {
+	UserID    uuid.UUID `json:"user_id" form:"user_id" binding:"required"`
+	Moderator bool      `json:"moderator" form:"moderator"`
+	DefaultRequest
+}
+
+// ChatBanRequest - бан (BAN) или мут (MUTE) участника до until; без until - бессрочно
+type ChatBanRequest struct {
+	UserID uuid.UUID       `json:"user_id" form:"user_id" binding:"required"`
+	Type   ChatUserBanType `json:"type" form:"type" binding:"required"`
+	Until  *time.Time      `json:"until,omitempty" form:"until"`
+	Reason *string         `json:"reason,omitempty" form:"reason"`
+	DefaultRequest
+}
+
+// ChatUnbanRequest - снятие бана или мута
+type ChatUnbanRequest struct {
+	UserID uuid.UUID `json:"user_id" form:"user_id" binding:"required"`
+	DefaultRequest
+}
+
+// ChatBanResponse - ограниченный пользователь чата
+type ChatBanResponse struct {
+	User AuthorResponse `json:"user"`
+	ChatBanInfo
+	Active bool `json:"active"`
+}
+
+// ChatInviteCreateRequest - приглашение в приватный чат. TOKEN и API_KEY генерирует сервер,
+// PASSWORD задаёт создатель; приглашение действует с start (по умолчанию сейчас) до until
+type ChatInviteCreateRequest struct {
+	Type     *ChatCredentialsType `json:"type,omitempty" form:"type"` // по умолчанию TOKEN
+	Password *string              `json:"password,omitempty" form:"password"`
+	OneTime  bool                 `json:"one_time" form:"one_time"`
+	Start    *time.Time           `json:"start,omitempty" form:"start"`
+	Until    *time.Time           `json:"until,omitempty" form:"until"`
+	DefaultRequest
+}
+
+// ChatInviteResponse - приглашение в чат. Credentials возвращаются только при создании
+type ChatInviteResponse struct {
 	ID          uuid.UUID           `json:"id"`
-	Content     string              `json:"content"`
+	ChatID      uuid.UUID           `json:"chat_id"`
+	Type        ChatCredentialsType `json:"type"`
+	Credentials *string             `json:"credentials,omitempty"`
+	OneTime     bool                `json:"one_time"`
+	Start       time.Time           `json:"start"`
+	Until       *time.Time          `json:"until,omitempty"`
+	Active      bool                `json:"active"`
 	CreatedAt   time.Time           `json:"created_at"`
-	UpdatedAt   time.Time           `json:"updated_at"`
-	DeletedAt   *time.Time          `json:"deleted_at,omitempty"`
-	Author      AuthorResponse      `json:"author"`
-	Parent      *BetCommentResponse `json:"parent,omitempty"`
-	Likes       int                 `json:"likes"`
-	IsLikedByMe bool                `json:"is_liked_by_me"`
+}
+
+// RatingRequest - оценка пользователя или ставки от 1 до 5
+type RatingRequest struct {
+	Rating int `json:"rating" form:"rating" binding:"required,min=1,max=5"`
+	DefaultRequest
+}
+
+// BetSettleRequest - результат ставки для её автора
+type BetSettleRequest struct {
+	Win *bool `json:"win" form:"win" binding:"required"`
+	DefaultRequest
+}
+
+// ReputationResponse - репутация пользователя и из чего она сложилась
+type ReputationResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Score     float64   `json:"score"`
+	RatingAvg float64   `json:"rating_average"`
+	Ratings   int       `json:"ratings"` // полученные оценки пользователя и его ставок
+	Bets      int       `json:"bets"`
+	Wins      int       `json:"wins"`
+	WinRate   int       `json:"win_rate"`
+	MyRating  *int      `json:"my_rating,omitempty"` // оценка пользователя текущим пользователем
+}
+
+// BetRatingResponse - оценка ставки после изменения
+type BetRatingResponse struct {
+	BetID      uuid.UUID `json:"bet_id"`
+	Rating     float64   `json:"rating"` // средняя оценка, 0 без оценок
+	Ratings    int64     `json:"ratings"`
+	MyRating   *int      `json:"my_rating,omitempty"`
+	Reputation float64   `json:"reputation"` // репутация автора ставки
+}
+
+// Event - событие потока реального времени. Topic - тема, на которую подписан клиент
+type Event struct {
+	ID        uuid.UUID      `json:"id"`
+	Topic     string         `json:"topic,omitempty"`
+	Type      string         `json:"type"`
+	Data      map[string]any `json:"data,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// EventCommand - сообщение клиента WebSocket: action "subscribe" или "unsubscribe"
+type EventCommand struct {
+	Action string   `json:"action"`
+	Topics []string `json:"topics"`
 }
 
 type BetCommentRequest struct {
@@ -262,6 +506,55 @@ type BetCommentRequest struct {
 	Search *string `json:"search,omitempty" form:"search"`
 }
 
+// BetCommentTreeRequest - комментарии верхнего уровня с вложенными ответами.
+// Сортировка только по времени создания, sort_dir задаёт направление для верхнего уровня
+type BetCommentTreeRequest struct {
+	PaginationRequest
+	Depth   *int `json:"depth,omitempty" form:"depth"`     // уровней ответов, не больше COMMENTS_MAX_DEPTH
+	Replies *int `json:"replies,omitempty" form:"replies"` // ответов, загружаемых на каждый комментарий
+}
+
+// BetCommentRepliesRequest - следующая страница ответов на комментарий
+type BetCommentRepliesRequest struct {
+	DefaultRequest
+	Cursor  *uuid.UUID `json:"cursor,omitempty" form:"cursor"` // next_cursor из предыдущего ответа
+	Limit   *int       `json:"limit,omitempty" form:"limit"`
+	Depth   *int       `json:"depth,omitempty" form:"depth"`
+	Replies *int       `json:"replies,omitempty" form:"replies"`
+}
+
+// BetCommentThreadResponse - комментарий с загруженной частью ответов
+type BetCommentThreadResponse struct {
+	BetCommentResponse
+	Replies        int64                      `json:"replies"`               // всего прямых ответов
+	Children       []BetCommentThreadResponse `json:"children"`              // загруженные ответы
+	HasMoreReplies bool                       `json:"has_more_replies"`      // загружены не все ответы
+	NextCursor     *uuid.UUID                 `json:"next_cursor,omitempty"` // курсор следующих ответов, без него - с начала
+}
+
+// BetCommentRepliesResponse - страница ответов на комментарий
+type BetCommentRepliesResponse struct {
+	Replies    []BetCommentThreadResponse `json:"replies"`
+	Total      int64                      `json:"total"`
+	HasMore    bool                       `json:"has_more"`
+	NextCursor *uuid.UUID                 `json:"next_cursor,omitempty"`
+}
+
+// BetCommentEditRequest - новый текст комментария
+type BetCommentEditRequest struct {
+	Content string `json:"content" form:"content" binding:"required"`
+	DefaultRequest
+}
+
+// BetCommentRevisionResponse - предыдущая версия комментария
+type BetCommentRevisionResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Content     string    `json:"content"`
+	EditorID    string    `json:"editor_id"`
+	IsModerator bool      `json:"is_moderator"`
+	ReplacedAt  time.Time `json:"replaced_at"`
+}
+
 type BetCommentCreateRequest struct {
 	Content  string     `json:"content" form:"content"`
 	ParentID *uuid.UUID `json:"parent_id,omitempty" form:"parent_id"`
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -54,6 +56,10 @@ type TLocalizationWord struct {
 	CkLocalization string    `json:"ck_localization" gorm:"column:ck_localization;type:varchar(255);primaryKey"`
 	CkLang         string    `json:"ck_lang" gorm:"column:ck_lang;type:varchar(20);primaryKey"`
 	CkText         uuid.UUID `json:"ck_text" gorm:"column:ck_text;type:uuid;not null"`
+	// Машинный перевод ожидает проверки, пока ct_review пуст
+	ClMachine bool       `json:"cl_machine" gorm:"column:cl_machine;not null;default:false"`
+	CkReview  *string    `json:"ck_review,omitempty" gorm:"column:ck_review;type:varchar(255)"`
+	CtReview  *time.Time `json:"ct_review,omitempty" gorm:"column:ct_review"`
 
 	// Relations
 	Localization *TLocalization `json:"localization,omitempty" gorm:"foreignKey:CkLocalization;references:CkId"`
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TDNotificationType - Тип уведомления. ck_name и ck_description - шаблоны заголовка и текста
+type TDNotificationType struct {
+	CkId          NotificationType `json:"ck_id" gorm:"column:ck_id;type:varchar(100);primaryKey"`
+	CkName        string           `json:"ck_name" gorm:"column:ck_name;type:varchar(255);not null"`
+	CkDescription string           `json:"ck_description" gorm:"column:ck_description;type:varchar(255);not null"`
+
+	BaseModel
+}
+
+func (TDNotificationType) TableName() string {
+	return "t_d_notification_type"
+}
+
+// TNotification - Уведомление пользователя. В приложении доступно сразу после создания,
+// по внешним каналам отправляется задачей доставки (ct_deliver)
+type TNotification struct {
+	CkId           uuid.UUID        `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
+	CkUser         uuid.UUID        `json:"ck_user" gorm:"column:ck_user;type:uuid;not null"`
+	CkType         NotificationType `json:"ck_type" gorm:"column:ck_type;type:varchar(100);not null"`
+	CjPayload      string           `json:"cj_payload" gorm:"column:cj_payload;type:json;not null"`
+	CtRead         *time.Time       `json:"ct_read,omitempty" gorm:"column:ct_read"`
+	CtDeliver      *time.Time       `json:"ct_deliver,omitempty" gorm:"column:ct_deliver"`
+	CvDeliverError *string          `json:"cv_deliver_error,omitempty" gorm:"column:cv_deliver_error;type:text"`
+
+	// Relations
+	Type *TDNotificationType `json:"type,omitempty" gorm:"foreignKey:CkType;references:CkId"`
+	User *TUser              `json:"user,omitempty" gorm:"foreignKey:CkUser;references:CkId"`
+
+	BaseModel
+}
+
+func (TNotification) TableName() string {
+	return "t_notification"
+}
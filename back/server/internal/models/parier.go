@@ -191,19 +191,22 @@ func (TBetMedia) TableName() string {
 
 // TBetComment - Комментарии к ставкам
 type TBetComment struct {
-	CkId      uuid.UUID  `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
-	CkBet     uuid.UUID  `json:"ck_bet" gorm:"column:ck_bet;type:uuid;not null"`
-	CkAuthor  uuid.UUID  `json:"ck_author" gorm:"column:ck_author;type:uuid;not null"`
-	CvContent string     `json:"cv_content" gorm:"column:cv_content;type:text;not null"`
-	CkParent  *uuid.UUID `json:"ck_parent,omitempty" gorm:"column:ck_parent;type:uuid"`
+	CkId      uuid.UUID       `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
+	CkBet     uuid.UUID       `json:"ck_bet" gorm:"column:ck_bet;type:uuid;not null"`
+	CkAuthor  uuid.UUID       `json:"ck_author" gorm:"column:ck_author;type:uuid;not null"`
+	CvContent string          `json:"cv_content" gorm:"column:cv_content;type:text;not null"`
+	CkParent  *uuid.UUID      `json:"ck_parent,omitempty" gorm:"column:ck_parent;type:uuid"`
+	CtEdit    *time.Time      `json:"ct_edit,omitempty" gorm:"column:ct_edit"`
+	CrRemove  *CommentRemover `json:"cr_remove,omitempty" gorm:"column:cr_remove;type:varchar(20)"`
 
 	// Relations
-	Bet      *TBet              `json:"bet,omitempty" gorm:"foreignKey:CkBet;references:CkId"`
-	Author   *TUser             `json:"author,omitempty" gorm:"foreignKey:CkAuthor;references:CkId"`
-	Parent   *TBetComment       `json:"parent,omitempty" gorm:"foreignKey:CkParent;references:CkId"`
-	Children []TBetComment      `json:"children,omitempty" gorm:"foreignKey:CkParent;references:CkId"`
-	Likes    []TBetCommentLike  `json:"likes,omitempty" gorm:"foreignKey:CkComment;references:CkId"`
-	Media    []TBetCommentMedia `json:"media,omitempty" gorm:"foreignKey:CkComment;references:CkId"`
+	Bet      *TBet                `json:"bet,omitempty" gorm:"foreignKey:CkBet;references:CkId"`
+	Author   *TUser               `json:"author,omitempty" gorm:"foreignKey:CkAuthor;references:CkId"`
+	Parent   *TBetComment         `json:"parent,omitempty" gorm:"foreignKey:CkParent;references:CkId"`
+	Children []TBetComment        `json:"children,omitempty" gorm:"foreignKey:CkParent;references:CkId"`
+	Likes    []TBetCommentLike    `json:"likes,omitempty" gorm:"foreignKey:CkComment;references:CkId"`
+	Media    []TBetCommentMedia   `json:"media,omitempty" gorm:"foreignKey:CkComment;references:CkId"`
+	Mentions []TBetCommentMention `json:"mentions,omitempty" gorm:"foreignKey:CkComment;references:CkId"`
 
 	BaseModel
 }
@@ -212,6 +215,41 @@ func (TBetComment) TableName() string {
 	return "t_bet_comment"
 }
 
+// TBetCommentRevision - Предыдущие версии комментариев
+type TBetCommentRevision struct {
+	CkId        uuid.UUID `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
+	CkComment   uuid.UUID `json:"ck_comment" gorm:"column:ck_comment;type:uuid;not null"`
+	CvContent   string    `json:"cv_content" gorm:"column:cv_content;type:text;not null"`
+	ClModerator bool      `json:"cl_moderator" gorm:"column:cl_moderator;not null;default:false"`
+
+	BaseModel
+}
+
+func (TBetCommentRevision) TableName() string {
+	return "t_bet_comment_revision"
+}
+
+// TBetCommentMention - Упоминания пользователей в комментариях
+type TBetCommentMention struct {
+	CkId       uuid.UUID  `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
+	CkComment  uuid.UUID  `json:"ck_comment" gorm:"column:ck_comment;type:uuid;not null"`
+	CkUser     uuid.UUID  `json:"ck_user" gorm:"column:ck_user;type:uuid;not null"`
+	CvUsername string     `json:"cv_username" gorm:"column:cv_username;type:varchar(255);not null"`
+	CnOffset   int        `json:"cn_offset" gorm:"column:cn_offset;not null"`
+	CnLength   int        `json:"cn_length" gorm:"column:cn_length;not null"`
+	CtRead     *time.Time `json:"ct_read,omitempty" gorm:"column:ct_read"`
+
+	// Relations
+	Comment *TBetComment `json:"comment,omitempty" gorm:"foreignKey:CkComment;references:CkId"`
+	User    *TUser       `json:"user,omitempty" gorm:"foreignKey:CkUser;references:CkId"`
+
+	BaseModel
+}
+
+func (TBetCommentMention) TableName() string {
+	return "t_bet_comment_mention"
+}
+
 // TBetCommentLike - Лайки к комментариям
 type TBetCommentLike struct {
 	CkId      uuid.UUID `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
@@ -366,17 +404,71 @@ func (TUserBetHistory) TableName() string {
 	return "t_user_bet_history"
 }
 
+// TUserReputation - Репутация пользователя: счётчики полученных оценок и рассчитанных ставок
+type TUserReputation struct {
+	CkUser        uuid.UUID `json:"ck_user" gorm:"column:ck_user;type:uuid;primaryKey"`
+	CnRatingSum   int64     `json:"cn_rating_sum" gorm:"column:cn_rating_sum;type:bigint;not null;default:0"`
+	CnRatingCount int       `json:"cn_rating_count" gorm:"column:cn_rating_count;type:int;not null;default:0"`
+	CnBets        int       `json:"cn_bets" gorm:"column:cn_bets;type:int;not null;default:0"`
+	CnWins        int       `json:"cn_wins" gorm:"column:cn_wins;type:int;not null;default:0"`
+	CnScore       float64   `json:"cn_score" gorm:"column:cn_score;type:numeric(5,2);not null;default:0"`
+
+	BaseModel
+}
+
+func (TUserReputation) TableName() string {
+	return "t_user_reputation"
+}
+
+// TUserBetView - Просмотренные пользователем ставки
+type TUserBetView struct {
+	CkId   uuid.UUID `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
+	CkUser uuid.UUID `json:"ck_user" gorm:"column:ck_user;type:uuid;not null"`
+	CkBet  uuid.UUID `json:"ck_bet" gorm:"column:ck_bet;type:uuid;not null"`
+	CtView time.Time `json:"ct_view" gorm:"column:ct_view;type:timestamp;not null"`
+
+	// Relations
+	User *TUser `json:"user,omitempty" gorm:"foreignKey:CkUser;references:CkId"`
+	Bet  *TBet  `json:"bet,omitempty" gorm:"foreignKey:CkBet;references:CkId"`
+
+	BaseModel
+}
+
+func (TUserBetView) TableName() string {
+	return "t_user_bet_view"
+}
+
+// TUserBetDraft - Черновики ставок пользователей
+type TUserBetDraft struct {
+	CkId    uuid.UUID `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey"`
+	CkUser  uuid.UUID `json:"ck_user" gorm:"column:ck_user;type:uuid;not null;index"`
+	CvData  string    `json:"cv_data" gorm:"column:cv_data;type:text;not null"`
+	CtDraft time.Time `json:"ct_draft" gorm:"column:ct_draft;type:timestamp;not null"`
+
+	// Relations
+	User *TUser `json:"user,omitempty" gorm:"foreignKey:CkUser;references:CkId"`
+
+	BaseModel
+}
+
+func (TUserBetDraft) TableName() string {
+	return "t_user_bet_draft"
+}
+
 // ================== ЧАТЫ ==================
 
-// TChat - Чаты
+// TChat - Чаты. Публичный чат с ck_bet - комната ставки, cl_direct - личный чат двух пользователей
 type TChat struct {
-	CkId          uuid.UUID `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
-	CkAuthor      uuid.UUID `json:"ck_author" gorm:"column:ck_author;type:uuid;not null"`
-	CkDescription *string   `json:"ck_description,omitempty" gorm:"column:ck_description;type:varchar(255)"`
-	CrType        ChatType  `json:"cr_type" gorm:"column:cr_type;type:varchar(20);not null"`
+	CkId          uuid.UUID  `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
+	CkAuthor      uuid.UUID  `json:"ck_author" gorm:"column:ck_author;type:uuid;not null"`
+	CkDescription *string    `json:"ck_description,omitempty" gorm:"column:ck_description;type:varchar(255)"`
+	CrType        ChatType   `json:"cr_type" gorm:"column:cr_type;type:varchar(20);not null"`
+	CkBet         *uuid.UUID `json:"ck_bet,omitempty" gorm:"column:ck_bet;type:uuid"`
+	ClDirect      bool       `json:"cl_direct" gorm:"column:cl_direct;type:boolean;not null;default:false"`
 
 	// Relations
 	Author      *TUser             `json:"author,omitempty" gorm:"foreignKey:CkAuthor;references:CkId"`
+	Bet         *TBet              `json:"bet,omitempty" gorm:"foreignKey:CkBet;references:CkId"`
 	Description *TLocalization     `json:"description,omitempty" gorm:"foreignKey:CkDescription;references:CkId"`
 	Credentials []TChatCredentials `json:"credentials,omitempty" gorm:"foreignKey:CkChat;references:CkId"`
 	Users       []TChatUser        `json:"users,omitempty" gorm:"foreignKey:CkChat;references:CkId"`
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -39,14 +41,25 @@ func (TReferral) TableName() string {
 	return "t_referral"
 }
 
-// TReferralEarning - Заработок с реферала
+// TReferralEarning - Заработок с реферала. ck_source - операция приглашенного, за которую
+// начислено вознаграждение (для SIGNUP - сам реферал), ck_transaction - зачисление пригласившему.
+// Вознаграждение второго уровня привязано к реферальной связи пригласившего со своим пригласившим
 type TReferralEarning struct {
-	CkId       uuid.UUID `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
-	CkReferral uuid.UUID `json:"ck_referral" gorm:"column:ck_referral;type:uuid;not null;index"`
-	CnAmount   float64   `json:"cn_amount" gorm:"column:cn_amount;type:decimal;not null"`
+	CkId           uuid.UUID             `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
+	CkReferral     uuid.UUID             `json:"ck_referral" gorm:"column:ck_referral;type:uuid;not null;index"`
+	CrType         ReferralEarningType   `json:"cr_type" gorm:"column:cr_type;type:varchar(20);not null"`
+	CrStatus       ReferralEarningStatus `json:"cr_status" gorm:"column:cr_status;type:varchar(20);not null"`
+	CnLevel        int                   `json:"cn_level" gorm:"column:cn_level;type:smallint;not null;default:1"`
+	CkSource       *uuid.UUID            `json:"ck_source,omitempty" gorm:"column:ck_source;type:uuid"`
+	CnBase         *float64              `json:"cn_base,omitempty" gorm:"column:cn_base;type:decimal"`
+	CnAmount       float64               `json:"cn_amount" gorm:"column:cn_amount;type:decimal;not null"`
+	CkTransaction  *uuid.UUID            `json:"ck_transaction,omitempty" gorm:"column:ck_transaction;type:uuid"`
+	CtPaid         *time.Time            `json:"ct_paid,omitempty" gorm:"column:ct_paid;type:timestamp"`
+	CvFreezeReason *string               `json:"cv_freeze_reason,omitempty" gorm:"column:cv_freeze_reason;type:varchar(1000)"`
 
 	// Relations
-	Referral *TReferral `json:"referral,omitempty" gorm:"foreignKey:CkReferral;references:CkId"`
+	Referral    *TReferral        `json:"referral,omitempty" gorm:"foreignKey:CkReferral;references:CkId"`
+	Transaction *TUserTransaction `json:"transaction,omitempty" gorm:"foreignKey:CkTransaction;references:CkId"`
 
 	BaseModel
 }
@@ -54,3 +67,17 @@ type TReferralEarning struct {
 func (TReferralEarning) TableName() string {
 	return "t_referral_earning"
 }
+
+// TReferralFreeze - Заморозка вознаграждений пригласившего. Пока она действует (ct_delete пуст),
+// новые вознаграждения начисляются замороженными, а ожидающие не выплачиваются
+type TReferralFreeze struct {
+	CkId     uuid.UUID `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
+	CkUser   uuid.UUID `json:"ck_user" gorm:"column:ck_user;type:uuid;not null"`
+	CvReason string    `json:"cv_reason" gorm:"column:cv_reason;type:varchar(1000);not null"`
+
+	BaseModel
+}
+
+func (TReferralFreeze) TableName() string {
+	return "t_referral_freeze"
+}
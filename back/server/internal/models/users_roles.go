@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -32,6 +33,12 @@ func (TDRole) TableName() string {
 type TUser struct {
 	CkId       uuid.UUID `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
 	CkExternal string    `json:"ck_external" gorm:"column:ck_external;type:varchar(255);not null"`
+	ClService  bool      `json:"cl_service" gorm:"column:cl_service;type:boolean;not null;default:false"`
+
+	// First login metadata
+	CtFirstLogin  *time.Time `json:"ct_first_login,omitempty" gorm:"column:ct_first_login;type:timestamp"`
+	CkFirstIss    *string    `json:"ck_first_iss,omitempty" gorm:"column:ck_first_iss;type:varchar(255)"`
+	CkFirstClient *string    `json:"ck_first_client,omitempty" gorm:"column:ck_first_client;type:varchar(255)"`
 
 	// Relations
 	UserRoles        []TUserRole         `json:"user_roles,omitempty" gorm:"foreignKey:CkUser;references:CkId"`
@@ -40,6 +47,7 @@ type TUser struct {
 	UserTransactions []TUserTransaction  `json:"user_transactions,omitempty" gorm:"foreignKey:CkUser;references:CkId"`
 	Sessions         []TSession          `json:"sessions,omitempty" gorm:"foreignKey:CkUser;references:CkId"`
 	BetHistory       []TUserBetHistory   `json:"bet_history,omitempty" gorm:"foreignKey:CkUser;references:CkId"`
+	Reputation       *TUserReputation    `json:"reputation,omitempty" gorm:"foreignKey:CkUser;references:CkId"`
 	RatingsReceived  []TUserRating       `json:"ratings_received,omitempty" gorm:"foreignKey:CkUser;references:CkId"`
 	RatingsGiven     []TUserRating       `json:"ratings_given,omitempty" gorm:"foreignKey:CkAuthor;references:CkId"`
 	LikesReceived    []TUserLike         `json:"likes_received,omitempty" gorm:"foreignKey:CkUser;references:CkId"`
@@ -248,21 +256,122 @@ func (TUserLike) TableName() string {
 }
 
 type TSession struct {
-	CkId        uuid.UUID  `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
-	CkUser      *uuid.UUID `json:"ck_user,omitempty" gorm:"column:ck_user;type:uuid;index"`
-	CvData      string     `json:"cv_data" gorm:"column:cv_data;type:text;not null"`
-	CtExpire    time.Time  `json:"ct_expire" gorm:"column:ct_expire;type:timestamp;not null"`
-	CkIp        string     `json:"ck_ip,omitempty" gorm:"column:ck_ip;type:varchar(255)"`
-	CkUserAgent string     `json:"ck_user_agent,omitempty" gorm:"column:ck_user_agent;type:varchar(255)"`
-	User        User       `json:"user,omitempty" gorm:"-"`
-	Saved       bool       `json:"saved" gorm:"-"`
+	CkId        uuid.UUID      `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
+	CkUser      *uuid.UUID     `json:"ck_user,omitempty" gorm:"column:ck_user;type:uuid;index"`
+	CvData      string         `json:"cv_data" gorm:"column:cv_data;type:text;not null"`
+	CtExpire    time.Time      `json:"ct_expire" gorm:"column:ct_expire;type:timestamp;not null"`
+	CkIp        string         `json:"ck_ip,omitempty" gorm:"column:ck_ip;type:varchar(255)"`
+	CkUserAgent string         `json:"ck_user_agent,omitempty" gorm:"column:ck_user_agent;type:varchar(255)"`
+	CtActivity  *time.Time     `json:"ct_activity,omitempty" gorm:"column:ct_activity;type:timestamp"`
+	User        User           `json:"user,omitempty" gorm:"-"`
+	Tokens      *SessionTokens `json:"-" gorm:"-"`
+	Saved       bool           `json:"saved" gorm:"-"`
 	BaseModel
 }
 
+// SessionTokens - токены Keycloak сессии, хранятся в cv_data вместе с пользователем
+type SessionTokens struct {
+	Iss           string    `json:"iss"`
+	RefreshToken  string    `json:"refresh_token"` // зашифрован util.EncryptString
+	AccessExpire  time.Time `json:"access_expire"`
+	RefreshExpire time.Time `json:"refresh_expire"`
+}
+
 func (TSession) TableName() string {
 	return "t_session"
 }
 
+// TAnonymousState - Активность анонимного посетителя до входа, ck_id - ID из cookie посетителя
+type TAnonymousState struct {
+	CkId     uuid.UUID      `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey"`
+	CvData   string         `json:"cv_data" gorm:"column:cv_data;type:text;not null"`
+	CtExpire time.Time      `json:"ct_expire" gorm:"column:ct_expire;type:timestamp;not null"`
+	State    AnonymousState `json:"state" gorm:"-"`
+	BaseModel
+}
+
+func (TAnonymousState) TableName() string {
+	return "t_anonymous_state"
+}
+
+// TokenScopeKind - к чему относится область действия API токена
+type TokenScopeKind string
+
+const (
+	TokenScopeTable    TokenScopeKind = "TABLE"    // как TDTableRole
+	TokenScopeProperty TokenScopeKind = "PROPERTY" // как TPropertiesRole
+)
+
+// TAPIToken - API токены пользователей и сервисных аккаунтов
+type TAPIToken struct {
+	CkId       uuid.UUID  `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
+	CkUser     uuid.UUID  `json:"ck_user" gorm:"column:ck_user;type:uuid;not null;index"`
+	CvName     string     `json:"cv_name" gorm:"column:cv_name;type:varchar(255);not null"`
+	CvPrefix   string     `json:"cv_prefix" gorm:"column:cv_prefix;type:varchar(16);not null"`
+	CvHash     string     `json:"-" gorm:"column:cv_hash;type:varchar(64);not null;uniqueIndex"`
+	CtExpire   *time.Time `json:"ct_expire,omitempty" gorm:"column:ct_expire;type:timestamp"`
+	CtLastUsed *time.Time `json:"ct_last_used,omitempty" gorm:"column:ct_last_used;type:timestamp"`
+
+	// Relations
+	User   *TUser           `json:"user,omitempty" gorm:"foreignKey:CkUser;references:CkId"`
+	Scopes []TAPITokenScope `json:"scopes,omitempty" gorm:"foreignKey:CkToken;references:CkId"`
+
+	BaseModel
+}
+
+func (TAPIToken) TableName() string {
+	return "t_api_token"
+}
+
+// Allows проверяет, разрешает ли токен действие над таблицей или свойством.
+// Права ролей владельца проверяются отдельно: токен только сужает их.
+func (t *TAPIToken) Allows(kind TokenScopeKind, object string, action ActionType) bool {
+	for _, scope := range t.Scopes {
+		if scope.CrScope == kind && scope.CkObject == object &&
+			(scope.CrAction == ActionTypeAll || scope.CrAction == action) {
+			return true
+		}
+	}
+	return false
+}
+
+// TAPITokenScope - Области действия API токена
+type TAPITokenScope struct {
+	CkId     uuid.UUID      `json:"ck_id" gorm:"column:ck_id;type:uuid;primaryKey;default:uuid_generate_v4()"`
+	CkToken  uuid.UUID      `json:"ck_token" gorm:"column:ck_token;type:uuid;not null;index"`
+	CrScope  TokenScopeKind `json:"cr_scope" gorm:"column:cr_scope;type:varchar(10);not null"`
+	CkObject string         `json:"ck_object" gorm:"column:ck_object;type:varchar(255);not null"`
+	CrAction ActionType     `json:"cr_action" gorm:"column:cr_action;type:varchar(10);not null"`
+
+	BaseModel
+}
+
+func (TAPITokenScope) TableName() string {
+	return "t_api_token_scope"
+}
+
+// AnonymousState - то, что анонимный посетитель успел сделать; хранится в cv_data
+// и переносится пользователю при входе
+type AnonymousState struct {
+	ViewedBets   []ViewedBet `json:"viewed_bets,omitempty"`
+	ReferralCode *string     `json:"referral_code,omitempty"`
+	Lang         *string     `json:"lang,omitempty"`
+	Drafts       []BetDraft  `json:"drafts,omitempty"`
+}
+
+// ViewedBet - просмотр ставки
+type ViewedBet struct {
+	BetID    uuid.UUID `json:"bet_id"`
+	ViewedAt time.Time `json:"viewed_at"`
+}
+
+// BetDraft - черновик ставки, Data - состояние формы создания ставки как есть
+type BetDraft struct {
+	ID        uuid.UUID       `json:"id"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
 // ================== DTO STRUCTS ==================
 
 // UserWithRoles - DTO для пользователя с ролями
@@ -1,6 +1,7 @@
 package ai
 
 import (
+	"fmt"
 	"parier-server/internal/config"
 
 	"github.com/google/uuid"
@@ -29,6 +30,18 @@ func NewAIModule(config *config.Config) *AIModule {
 	return &AIModule{config: config}
 }
 
+// NewAIModuleFromConfig creates the backend selected by AI_TYPE
+func NewAIModuleFromConfig(cfg *config.Config) (AIModuleInterface, error) {
+	switch cfg.AI.Type {
+	case config.AITypeN8N:
+		if cfg.AI.URL == nil || *cfg.AI.URL == "" {
+			return nil, fmt.Errorf("AI_URL is required for the %s backend", cfg.AI.Type)
+		}
+		return NewN8NModule(cfg), nil
+	}
+	return nil, fmt.Errorf("unsupported AI type: %s", cfg.AI.Type)
+}
+
 func (m *AIModule) Init() error {
 	return nil
 }
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"parier-server/internal/config"
+	"path/filepath"
+	"time"
+)
+
+// FSBlobStore keeps blobs on the local filesystem. Metadata is stored in a
+// sidecar file next to the blob.
+type FSBlobStore struct {
+	urlSigner
+	dir string
+}
+
+type fsBlobMeta struct {
+	ContentType string            `json:"content_type"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+func NewFSBlobStore(cfg *config.StorageConfig, publicDuration time.Duration) (*FSBlobStore, error) {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FSBlobStore{
+		urlSigner: urlSigner{baseURL: cfg.BaseURL, secret: cfg.Secret, publicDuration: publicDuration},
+		dir:       cfg.Dir,
+	}, nil
+}
+
+// path resolves key inside the root directory; Clean on a rooted key
+// strips any ".." so the result can't escape dir
+func (s *FSBlobStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(filepath.Clean(normalizeKey(key))))
+}
+
+func (s *FSBlobStore) Put(ctx context.Context, key string, body io.Reader, contentType string, metadata map[string]string) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	meta, err := json.Marshal(fsBlobMeta{ContentType: contentType, Metadata: metadata})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".meta", meta, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FSBlobStore) Get(ctx context.Context, key string) (*BlobObject, error) {
+	path := s.path(key)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	object := &BlobObject{Content: content}
+	if raw, err := os.ReadFile(path + ".meta"); err == nil {
+		var meta fsBlobMeta
+		if json.Unmarshal(raw, &meta) == nil {
+			object.ContentType = meta.ContentType
+			object.Metadata = meta.Metadata
+		}
+	}
+	return object, nil
+}
+
+func (s *FSBlobStore) Delete(ctx context.Context, key string) error {
+	path := s.path(key)
+	os.Remove(path + ".meta")
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FSBlobStore) PresignPut(ctx context.Context, key string, contentType string, metadata map[string]string, expires time.Duration) (string, error) {
+	return s.signedURL(http.MethodPut, key, contentType, expires), nil
+}
+
+func (s *FSBlobStore) PublicURL(key string) string {
+	return s.publicURL(key)
+}
+
+func (s *FSBlobStore) CheckBucket(ctx context.Context) error {
+	info, err := os.Stat(s.dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("storage path is not a directory")
+	}
+	return nil
+}
+
+func (s *FSBlobStore) CreateBucket(ctx context.Context) error {
+	return os.MkdirAll(s.dir, 0755)
+}
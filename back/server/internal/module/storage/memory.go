@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"maps"
+	"net/http"
+	"parier-server/internal/config"
+	"sync"
+	"time"
+)
+
+// MemoryBlobStore keeps blobs in process memory. Intended for tests and local runs.
+type MemoryBlobStore struct {
+	urlSigner
+	sync.RWMutex
+	objects map[string]*BlobObject
+}
+
+func NewMemoryBlobStore(cfg *config.StorageConfig, publicDuration time.Duration) *MemoryBlobStore {
+	return &MemoryBlobStore{
+		urlSigner: urlSigner{baseURL: cfg.BaseURL, secret: cfg.Secret, publicDuration: publicDuration},
+		objects:   make(map[string]*BlobObject),
+	}
+}
+
+func (s *MemoryBlobStore) Put(ctx context.Context, key string, body io.Reader, contentType string, metadata map[string]string) error {
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.objects[normalizeKey(key)] = &BlobObject{
+		Content:     content,
+		ContentType: contentType,
+		Metadata:    maps.Clone(metadata),
+	}
+	return nil
+}
+
+func (s *MemoryBlobStore) Get(ctx context.Context, key string) (*BlobObject, error) {
+	s.RLock()
+	defer s.RUnlock()
+	object, ok := s.objects[normalizeKey(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &BlobObject{
+		Content:     append([]byte(nil), object.Content...),
+		ContentType: object.ContentType,
+		Metadata:    maps.Clone(object.Metadata),
+	}, nil
+}
+
+func (s *MemoryBlobStore) Delete(ctx context.Context, key string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.objects, normalizeKey(key))
+	return nil
+}
+
+func (s *MemoryBlobStore) PresignPut(ctx context.Context, key string, contentType string, metadata map[string]string, expires time.Duration) (string, error) {
+	return s.signedURL(http.MethodPut, key, contentType, expires), nil
+}
+
+func (s *MemoryBlobStore) PublicURL(key string) string {
+	return s.publicURL(key)
+}
+
+func (s *MemoryBlobStore) CheckBucket(ctx context.Context) error {
+	return nil
+}
+
+func (s *MemoryBlobStore) CreateBucket(ctx context.Context) error {
+	return nil
+}
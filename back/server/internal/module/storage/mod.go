@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"parier-server/internal/config"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("blob not found")
+	ErrInvalidSignature = errors.New("invalid or expired signature")
+)
+
+// BlobObject is a stored blob together with its metadata
+type BlobObject struct {
+	Content     []byte
+	ContentType string
+	Metadata    map[string]string
+}
+
+// BlobStore abstracts the storage backend used by MediaService
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.Reader, contentType string, metadata map[string]string) error
+	Get(ctx context.Context, key string) (*BlobObject, error)
+	Delete(ctx context.Context, key string) error
+	// PresignPut returns a URL that allows a client to upload the blob directly
+	PresignPut(ctx context.Context, key string, contentType string, metadata map[string]string, expires time.Duration) (string, error)
+	// PublicURL returns a URL the blob can be fetched from
+	PublicURL(key string) string
+	CheckBucket(ctx context.Context) error
+	CreateBucket(ctx context.Context) error
+}
+
+// LocalBlobStore is implemented by backends that emulate presigned URLs
+// with HMAC-signed links served by the API itself
+type LocalBlobStore interface {
+	BlobStore
+	VerifyURL(method string, key string, contentType string, expires int64, signature string) error
+}
+
+// NewBlobStore creates the backend selected by cfg.Storage.Type. The fs and memory
+// backends sign upload links themselves, so they refuse to start without STORAGE_SECRET
+func NewBlobStore(cfg *config.Config) (BlobStore, error) {
+	if (cfg.Storage.Type == config.StorageTypeFS || cfg.Storage.Type == config.StorageTypeMemory) && cfg.Storage.Secret == "" {
+		return nil, fmt.Errorf("STORAGE_SECRET must be set for %s storage", cfg.Storage.Type)
+	}
+	switch cfg.Storage.Type {
+	case config.StorageTypeS3, "":
+		return NewS3BlobStore(&cfg.S3)
+	case config.StorageTypeFS:
+		return NewFSBlobStore(&cfg.Storage, cfg.Media.Duration)
+	case config.StorageTypeMemory:
+		return NewMemoryBlobStore(&cfg.Storage, cfg.Media.Duration), nil
+	default:
+		return nil, fmt.Errorf("unknown storage type: %s", cfg.Storage.Type)
+	}
+}
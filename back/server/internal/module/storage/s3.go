@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"parier-server/internal/config"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type S3BlobStore struct {
+	client     *s3.Client
+	uploader   *manager.Uploader
+	downloader *manager.Downloader
+	bucket     string
+	config     *config.S3Config
+}
+
+func NewS3BlobStore(s3Config *config.S3Config) (*S3BlobStore, error) {
+	// Configure AWS credentials
+	var creds aws.CredentialsProvider
+	if s3Config.AccessKey != "" && s3Config.SecretKey != "" {
+		creds = credentials.NewStaticCredentialsProvider(s3Config.AccessKey, s3Config.SecretKey, "")
+	} else {
+		// Use default credential chain (environment variables, IAM role, etc.)
+		creds = nil
+	}
+
+	// Create AWS config
+	cfg := aws.Config{
+		Region:      s3Config.Region,
+		Credentials: creds,
+	}
+
+	// Configure custom endpoint for MinIO or other S3-compatible services
+	if s3Config.Endpoint != "" {
+		cfg.EndpointResolverWithOptions = aws.EndpointResolverWithOptionsFunc(
+			func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+				return aws.Endpoint{
+					URL:           s3Config.Endpoint,
+					SigningRegion: region,
+				}, nil
+			})
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.UsePathStyle = s3Config.ForcePathStyle
+	})
+
+	return &S3BlobStore{
+		client:     client,
+		uploader:   manager.NewUploader(client),
+		downloader: manager.NewDownloader(client),
+		bucket:     s3Config.Bucket,
+		config:     s3Config,
+	}, nil
+}
+
+func (s *S3BlobStore) Put(ctx context.Context, key string, body io.Reader, contentType string, metadata map[string]string) error {
+	_, err := s.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+		Metadata:    metadata,
+	})
+	return err
+}
+
+func (s *S3BlobStore) Get(ctx context.Context, key string) (*BlobObject, error) {
+	buffer := manager.NewWriteAtBuffer([]byte{})
+	_, err := s.downloader.Download(ctx, buffer, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &BlobObject{Content: buffer.Bytes()}, nil
+}
+
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (s *S3BlobStore) PresignPut(ctx context.Context, key string, contentType string, metadata map[string]string, expires time.Duration) (string, error) {
+	presignClient := s3.NewPresignClient(s.client)
+	presignedRequest, err := presignClient.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+		Metadata:    metadata,
+	}, func(opts *s3.PresignOptions) {
+		opts.Expires = expires
+	})
+	if err != nil {
+		return "", err
+	}
+	return presignedRequest.URL, nil
+}
+
+func (s *S3BlobStore) PublicURL(key string) string {
+	if s.config.Endpoint != "" {
+		// For MinIO or custom endpoints
+		protocol := "https"
+		if !s.config.UseSSL {
+			protocol = "http"
+		}
+		return fmt.Sprintf("%s://%s/%s/%s", protocol, strings.TrimPrefix(s.config.Endpoint, protocol+"://"), s.bucket, key)
+	}
+
+	// For AWS S3
+	if s.config.ForcePathStyle {
+		return fmt.Sprintf("https://s3.%s.amazonaws.com/%s/%s", s.config.Region, s.bucket, key)
+	}
+
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.config.Region, key)
+}
+
+func (s *S3BlobStore) CheckBucket(ctx context.Context) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
+	})
+	return err
+}
+
+func (s *S3BlobStore) CreateBucket(ctx context.Context) error {
+	createInput := &s3.CreateBucketInput{
+		Bucket: aws.String(s.bucket),
+	}
+
+	// For regions other than us-east-1, we need to specify the location constraint
+	if s.config.Region != "us-east-1" {
+		createInput.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(s.config.Region),
+		}
+	}
+
+	_, err := s.client.CreateBucket(ctx, createInput)
+	return err
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"parier-server/internal/config"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testStores(t *testing.T) map[string]LocalBlobStore {
+	cfg := &config.StorageConfig{
+		Dir:     t.TempDir(),
+		BaseURL: "http://localhost:8080/api/v1/media/blob",
+		Secret:  "test-secret",
+	}
+	fs, err := NewFSBlobStore(cfg, time.Hour)
+	if err != nil {
+		t.Fatalf("NewFSBlobStore: %v", err)
+	}
+	return map[string]LocalBlobStore{
+		"fs":     fs,
+		"memory": NewMemoryBlobStore(cfg, time.Hour),
+	}
+}
+
+func TestLocalBlobStore_PutGetDelete(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			err := store.Put(ctx, "/media/abc/file.txt", bytes.NewBufferString("hello"), "text/plain", map[string]string{"uploaded-by": "u1"})
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			object, err := store.Get(ctx, "media/abc/file.txt")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if string(object.Content) != "hello" || object.ContentType != "text/plain" || object.Metadata["uploaded-by"] != "u1" {
+				t.Errorf("unexpected object: %+v", object)
+			}
+			if err := store.Delete(ctx, "/media/abc/file.txt"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := store.Get(ctx, "/media/abc/file.txt"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected ErrNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLocalBlobStore_SignedURL(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			raw, err := store.PresignPut(ctx, "media/x.png", "image/png", nil, time.Minute)
+			if err != nil {
+				t.Fatalf("PresignPut: %v", err)
+			}
+			u, err := url.Parse(raw)
+			if err != nil {
+				t.Fatalf("parse url: %v", err)
+			}
+			if u.Path != "/api/v1/media/blob/media/x.png" {
+				t.Errorf("unexpected path %q", u.Path)
+			}
+			expires, _ := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+			signature := u.Query().Get("signature")
+			if err := store.VerifyURL(http.MethodPut, "/media/x.png", "image/png", expires, signature); err != nil {
+				t.Errorf("valid signature rejected: %v", err)
+			}
+			if err := store.VerifyURL(http.MethodPut, "/media/x.png", "text/html", expires, signature); err == nil {
+				t.Error("signature accepted for a different content type")
+			}
+			if err := store.VerifyURL(http.MethodGet, "/media/x.png", "image/png", expires, signature); err == nil {
+				t.Error("signature accepted for a different method")
+			}
+			if err := store.VerifyURL(http.MethodPut, "/media/y.png", "image/png", expires, signature); err == nil {
+				t.Error("signature accepted for a different key")
+			}
+			if err := store.VerifyURL(http.MethodPut, "/media/x.png", "image/png", time.Now().Add(-time.Second).Unix(), signature); err == nil {
+				t.Error("expired signature accepted")
+			}
+		})
+	}
+}
+
+func TestFSBlobStore_PathTraversal(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFSBlobStore(&config.StorageConfig{Dir: dir}, time.Hour)
+	if err != nil {
+		t.Fatalf("NewFSBlobStore: %v", err)
+	}
+	if path := store.path("../../etc/passwd"); path != dir+"/etc/passwd" {
+		t.Errorf("key escaped storage dir: %s", path)
+	}
+}
+
+func TestNewBlobStore_RequiresSecret(t *testing.T) {
+	for _, storageType := range []config.StorageType{config.StorageTypeFS, config.StorageTypeMemory} {
+		cfg := &config.Config{Storage: config.StorageConfig{Type: storageType, Dir: t.TempDir()}}
+		if _, err := NewBlobStore(cfg); err == nil {
+			t.Errorf("%s storage started without STORAGE_SECRET", storageType)
+		}
+		cfg.Storage.Secret = "test-secret"
+		if _, err := NewBlobStore(cfg); err != nil {
+			t.Errorf("%s storage: %v", storageType, err)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"parier-server/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserLangProperty - свойство пользователя с выбранным языком интерфейса
+const UserLangProperty = "USER_LANG"
+
+// ActivityRepository хранит активность посетителей: просмотры, черновики ставок
+// и состояние анонимных посетителей до входа
+type ActivityRepository struct {
+	db *gorm.DB
+}
+
+func NewActivityRepository(db *gorm.DB) *ActivityRepository {
+	return &ActivityRepository{db: db}
+}
+
+// AnonymousMerge - изменения, переносимые пользователю из состояния анонимного посетителя
+type AnonymousMerge struct {
+	ViewedBets   []models.ViewedBet
+	Drafts       []models.BetDraft
+	Lang         *string
+	ReferralCode *string
+}
+
+// === T_ANONYMOUS_STATE ===
+
+// GetAnonymousState returns the unexpired state of the visitor or nil if there is none
+func (r *ActivityRepository) GetAnonymousState(id uuid.UUID) (*models.TAnonymousState, error) {
+	var state models.TAnonymousState
+	err := r.db.Where("ck_id = ? AND ct_delete IS NULL AND ct_expire > NOW()", id).
+		First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get anonymous state: %w", err)
+	}
+	if err := json.Unmarshal([]byte(state.CvData), &state.State); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal anonymous state: %w", err)
+	}
+	return &state, nil
+}
+
+// SaveAnonymousState creates or replaces the state of the visitor and extends its expiry
+func (r *ActivityRepository) SaveAnonymousState(state *models.TAnonymousState) error {
+	data, err := json.Marshal(state.State)
+	if err != nil {
+		return fmt.Errorf("failed to marshal anonymous state: %w", err)
+	}
+	state.CvData = string(data)
+	state.CkCreate = state.CkId.String()
+	state.CkModify = state.CkId.String()
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "ck_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"cv_data":   state.CvData,
+			"ct_expire": state.CtExpire,
+			"ck_modify": state.CkModify,
+			"ct_modify": gorm.Expr("NOW()"),
+			"ct_delete": nil,
+		}),
+	}).Create(state).Error
+}
+
+// === T_USER_BET_VIEW ===
+
+// GetBetViews returns the last view time of the given bets by the user
+func (r *ActivityRepository) GetBetViews(userID uuid.UUID, betIDs []uuid.UUID) (map[uuid.UUID]time.Time, error) {
+	result := make(map[uuid.UUID]time.Time, len(betIDs))
+	if len(betIDs) == 0 {
+		return result, nil
+	}
+	var views []models.TUserBetView
+	err := r.db.Where("ck_user = ? AND ck_bet IN ? AND ct_delete IS NULL", userID, betIDs).
+		Find(&views).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bet views: %w", err)
+	}
+	for _, view := range views {
+		result[view.CkBet] = view.CtView
+	}
+	return result, nil
+}
+
+// GetRecentBetViews returns the latest bet views of the user
+func (r *ActivityRepository) GetRecentBetViews(userID uuid.UUID, limit int) ([]models.ViewedBet, error) {
+	var views []models.TUserBetView
+	err := r.db.Where("ck_user = ? AND ct_delete IS NULL", userID).
+		Order("ct_view DESC").
+		Limit(limit).
+		Find(&views).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bet views: %w", err)
+	}
+	result := make([]models.ViewedBet, len(views))
+	for i, view := range views {
+		result[i] = models.ViewedBet{BetID: view.CkBet, ViewedAt: view.CtView}
+	}
+	return result, nil
+}
+
+// SaveBetView records that the user viewed the bet, keeping the latest view time
+func (r *ActivityRepository) SaveBetView(userID uuid.UUID, view models.ViewedBet) error {
+	return saveBetViews(r.db, userID, []models.ViewedBet{view})
+}
+
+func saveBetViews(tx *gorm.DB, userID uuid.UUID, views []models.ViewedBet) error {
+	if len(views) == 0 {
+		return nil
+	}
+	rows := make([]models.TUserBetView, len(views))
+	for i, view := range views {
+		rows[i] = models.TUserBetView{
+			CkId:   uuid.New(),
+			CkUser: userID,
+			CkBet:  view.BetID,
+			CtView: view.ViewedAt,
+			BaseModel: models.BaseModel{
+				CkCreate: userID.String(),
+				CkModify: userID.String(),
+			},
+		}
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "ck_user"}, {Name: "ck_bet"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"ct_view":   gorm.Expr("GREATEST(t_user_bet_view.ct_view, EXCLUDED.ct_view)"),
+			"ct_delete": nil,
+		}),
+	}).Create(&rows).Error
+}
+
+// === T_USER_BET_DRAFT ===
+
+// GetBetDrafts returns drafts of the user, most recently changed first
+func (r *ActivityRepository) GetBetDrafts(userID uuid.UUID) ([]models.BetDraft, error) {
+	var rows []models.TUserBetDraft
+	err := r.db.Where("ck_user = ? AND ct_delete IS NULL", userID).
+		Order("ct_draft DESC").
+		Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bet drafts: %w", err)
+	}
+	drafts := make([]models.BetDraft, len(rows))
+	for i, row := range rows {
+		drafts[i] = models.BetDraft{
+			ID:        row.CkId,
+			Data:      json.RawMessage(row.CvData),
+			UpdatedAt: row.CtDraft,
+		}
+	}
+	return drafts, nil
+}
+
+// SaveBetDraft creates or updates a draft of the user.
+// A draft with the same ID owned by another user is left untouched.
+func (r *ActivityRepository) SaveBetDraft(userID uuid.UUID, draft models.BetDraft) error {
+	return saveBetDrafts(r.db, userID, []models.BetDraft{draft})
+}
+
+func saveBetDrafts(tx *gorm.DB, userID uuid.UUID, drafts []models.BetDraft) error {
+	if len(drafts) == 0 {
+		return nil
+	}
+	rows := make([]models.TUserBetDraft, len(drafts))
+	for i, draft := range drafts {
+		rows[i] = models.TUserBetDraft{
+			CkId:    draft.ID,
+			CkUser:  userID,
+			CvData:  string(draft.Data),
+			CtDraft: draft.UpdatedAt,
+			BaseModel: models.BaseModel{
+				CkCreate: userID.String(),
+				CkModify: userID.String(),
+			},
+		}
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "ck_id"}},
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "t_user_bet_draft.ck_user = EXCLUDED.ck_user"},
+		}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"cv_data":   gorm.Expr("EXCLUDED.cv_data"),
+			"ct_draft":  gorm.Expr("EXCLUDED.ct_draft"),
+			"ck_modify": gorm.Expr("EXCLUDED.ck_modify"),
+			"ct_modify": gorm.Expr("NOW()"),
+			"ct_delete": nil,
+		}),
+	}).Create(&rows).Error
+}
+
+// DeleteBetDraft removes a draft of the user, reporting whether it existed
+func (r *ActivityRepository) DeleteBetDraft(userID uuid.UUID, draftID uuid.UUID) (bool, error) {
+	result := r.db.Model(&models.TUserBetDraft{}).
+		Where("ck_id = ? AND ck_user = ? AND ct_delete IS NULL", draftID, userID).
+		Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ck_modify": userID.String()})
+	return result.RowsAffected > 0, result.Error
+}
+
+// === LANGUAGE ===
+
+// GetUserLang returns the saved interface language of the user or nil
+func (r *ActivityRepository) GetUserLang(userID uuid.UUID) (*string, error) {
+	var prop models.TUserProperties
+	err := r.db.Where("ck_user = ? AND ck_type = ? AND ct_delete IS NULL", userID, UserLangProperty).
+		First(&prop).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user language: %w", err)
+	}
+	return prop.CvText, nil
+}
+
+// SetUserLang saves the interface language of the user
+func (r *ActivityRepository) SetUserLang(userID uuid.UUID, lang string) error {
+	return setUserLang(r.db, userID, lang)
+}
+
+func setUserLang(tx *gorm.DB, userID uuid.UUID, lang string) error {
+	result := tx.Model(&models.TUserProperties{}).
+		Where("ck_user = ? AND ck_type = ? AND ct_delete IS NULL", userID, UserLangProperty).
+		Updates(map[string]any{"cv_text": lang, "ck_modify": userID.String()})
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	return tx.Create(&models.TUserProperties{
+		CkId:   uuid.New(),
+		CkUser: userID,
+		CkType: UserLangProperty,
+		CvText: &lang,
+		BaseModel: models.BaseModel{
+			CkCreate: userID.String(),
+			CkModify: userID.String(),
+		},
+	}).Error
+}
+
+// === MERGE ===
+
+// ReferralAttribution links the user to the owner of the referral code within the merge transaction.
+// Its failure is logged and does not abort the merge
+type ReferralAttribution func(tx *gorm.DB, userID uuid.UUID, code string) error
+
+// ApplyAnonymousMerge transfers the visitor's activity to the user in one transaction
+// and removes the anonymous state, so the merge is not repeated on the next login
+func (r *ActivityRepository) ApplyAnonymousMerge(anonymousID uuid.UUID, userID uuid.UUID, merge *AnonymousMerge, attribute ReferralAttribution) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := saveBetViews(tx, userID, merge.ViewedBets); err != nil {
+			return fmt.Errorf("failed to merge bet views: %w", err)
+		}
+		if err := saveBetDrafts(tx, userID, merge.Drafts); err != nil {
+			return fmt.Errorf("failed to merge bet drafts: %w", err)
+		}
+		if merge.Lang != nil {
+			if err := setUserLang(tx, userID, *merge.Lang); err != nil {
+				return fmt.Errorf("failed to merge language: %w", err)
+			}
+		}
+		if merge.ReferralCode != nil {
+			// a bad referral code must not block the rest of the merge: it would fail
+			// again on every login, so its changes are rolled back and the code is dropped
+			if err := tx.SavePoint("referral").Error; err != nil {
+				return fmt.Errorf("failed to merge referral code: %w", err)
+			}
+			if err := attribute(tx, userID, *merge.ReferralCode); err != nil {
+				log.Printf("Failed to attribute referral code %q to user %s: %v", *merge.ReferralCode, userID, err)
+				if err := tx.RollbackTo("referral").Error; err != nil {
+					return fmt.Errorf("failed to merge referral code: %w", err)
+				}
+				merge.ReferralCode = nil
+			}
+		}
+		return tx.Model(&models.TAnonymousState{}).
+			Where("ck_id = ? AND ct_delete IS NULL", anonymousID).
+			Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ck_modify": userID.String()}).Error
+	})
+}
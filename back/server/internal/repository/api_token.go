@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"parier-server/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ServiceAccountPrefix - префикс ck_external сервисных аккаунтов, не пересекается с ID Keycloak
+const ServiceAccountPrefix = "service:"
+
+// APITokenRepository хранит API токены и сервисные аккаунты
+type APITokenRepository struct {
+	db *gorm.DB
+}
+
+func NewAPITokenRepository(db *gorm.DB) *APITokenRepository {
+	return &APITokenRepository{db: db}
+}
+
+// === T_API_TOKEN ===
+
+// CreateToken saves the token together with its scopes
+func (r *APITokenRepository) CreateToken(token *models.TAPIToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		scopes := token.Scopes
+		token.Scopes = nil
+		if err := tx.Create(token).Error; err != nil {
+			return err
+		}
+		for i := range scopes {
+			scopes[i].CkToken = token.CkId
+			scopes[i].CkCreate = token.CkCreate
+			scopes[i].CkModify = token.CkModify
+		}
+		if len(scopes) > 0 {
+			if err := tx.Create(&scopes).Error; err != nil {
+				return err
+			}
+		}
+		token.Scopes = scopes
+		return nil
+	})
+}
+
+// GetActiveTokenByHash returns an unrevoked, unexpired token of an existing owner or nil
+func (r *APITokenRepository) GetActiveTokenByHash(hash string) (*models.TAPIToken, error) {
+	var token models.TAPIToken
+	err := r.db.Where("cv_hash = ? AND ct_delete IS NULL AND (ct_expire IS NULL OR ct_expire > NOW())", hash).
+		Where("EXISTS (SELECT 1 FROM t_user u WHERE u.ck_id = t_api_token.ck_user AND u.ct_delete IS NULL)").
+		Preload("Scopes", "ct_delete IS NULL").
+		First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api token: %w", err)
+	}
+	return &token, nil
+}
+
+// GetTokensByUserID returns unrevoked tokens of the user, newest first
+func (r *APITokenRepository) GetTokensByUserID(userID uuid.UUID) ([]models.TAPIToken, error) {
+	var tokens []models.TAPIToken
+	err := r.db.Where("ck_user = ? AND ct_delete IS NULL", userID).
+		Preload("Scopes", "ct_delete IS NULL").
+		Order("ct_create DESC").
+		Find(&tokens).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// RevokeToken revokes a token of the user, reporting whether it existed
+func (r *APITokenRepository) RevokeToken(userID uuid.UUID, tokenID uuid.UUID, revokedBy string) (bool, error) {
+	result := r.db.Model(&models.TAPIToken{}).
+		Where("ck_id = ? AND ck_user = ? AND ct_delete IS NULL", tokenID, userID).
+		Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ck_modify": revokedBy})
+	return result.RowsAffected > 0, result.Error
+}
+
+// TouchToken records the last use of a token without bumping ct_modify
+func (r *APITokenRepository) TouchToken(id uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.TAPIToken{}).
+		Where("ck_id = ?", id).
+		UpdateColumn("ct_last_used", at).Error
+}
+
+// === SERVICE ACCOUNTS ===
+
+// CreateServiceAccount creates a service account user with its name and roles
+func (r *APITokenRepository) CreateServiceAccount(name string, roles []string, createdBy string) (*models.TUser, error) {
+	id := uuid.New()
+	user := &models.TUser{
+		CkId:       id,
+		CkExternal: ServiceAccountPrefix + id.String(),
+		ClService:  true,
+		BaseModel: models.BaseModel{
+			CkCreate: createdBy,
+			CkModify: createdBy,
+		},
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.TUserProperties{
+			CkId:   uuid.New(),
+			CkUser: id,
+			CkType: "USER_USERNAME",
+			CvText: &name,
+			BaseModel: models.BaseModel{
+				CkCreate: createdBy,
+				CkModify: createdBy,
+			},
+		}).Error; err != nil {
+			return err
+		}
+		for _, role := range roles {
+			if err := tx.Create(&models.TUserRole{
+				CkUser:  id,
+				CkRole:  role,
+				CtStart: time.Now(),
+				BaseModel: models.BaseModel{
+					CkCreate: createdBy,
+					CkModify: createdBy,
+				},
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service account: %w", err)
+	}
+	return user, nil
+}
+
+// GetServiceAccounts returns service accounts with their properties and roles
+func (r *APITokenRepository) GetServiceAccounts() ([]models.TUser, error) {
+	var users []models.TUser
+	err := r.db.Where("cl_service AND ct_delete IS NULL").
+		Preload("UserProperties", "ct_delete IS NULL").
+		Preload("UserRoles", "ct_delete IS NULL AND (ct_end IS NULL OR ct_end > NOW())").
+		Order("ct_create DESC").
+		Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service accounts: %w", err)
+	}
+	return users, nil
+}
+
+// GetServiceAccount returns the service account or nil if there is none
+func (r *APITokenRepository) GetServiceAccount(id uuid.UUID) (*models.TUser, error) {
+	var user models.TUser
+	err := r.db.Where("ck_id = ? AND cl_service AND ct_delete IS NULL", id).
+		Preload("UserProperties", "ct_delete IS NULL").
+		Preload("UserRoles", "ct_delete IS NULL AND (ct_end IS NULL OR ct_end > NOW())").
+		First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service account: %w", err)
+	}
+	return &user, nil
+}
+
+// DeleteServiceAccount deletes the service account and revokes its tokens
+func (r *APITokenRepository) DeleteServiceAccount(id uuid.UUID, deletedBy string) (bool, error) {
+	deleted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.TUser{}).
+			Where("ck_id = ? AND cl_service AND ct_delete IS NULL", id).
+			Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ck_modify": deletedBy})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		deleted = true
+		return tx.Model(&models.TAPIToken{}).
+			Where("ck_user = ? AND ct_delete IS NULL", id).
+			Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ck_modify": deletedBy}).Error
+	})
+	return deleted, err
+}
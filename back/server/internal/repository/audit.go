@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"parier-server/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// auditLockKey - ключ advisory lock, под которым записи журнала получают номер и хеш предыдущей
+const auditLockKey = 7305011
+
+// AuditGenesisHash - хеш "предыдущей" записи для первой записи журнала
+var AuditGenesisHash = strings.Repeat("0", 64)
+
+// AuditRepository хранит журнал аудита
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Append добавляет запись в конец цепочки: присваивает номер, хеш предыдущей записи и
+// собственный хеш, посчитанный seal. В транзакции tx запись фиксируется вместе с изменением,
+// которое она описывает; без tx используется отдельная транзакция.
+// Блокировка держится до конца транзакции, поэтому записи журнала выстраиваются по очереди.
+func (r *AuditRepository) Append(tx *gorm.DB, entry *models.TAuditLog, seal func(*models.TAuditLog) string) error {
+	if tx == nil {
+		return r.db.Transaction(func(tx *gorm.DB) error {
+			return r.Append(tx, entry, seal)
+		})
+	}
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditLockKey).Error; err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	var last models.TAuditLog
+	result := tx.Select("cn_seq", "cv_hash").Order("cn_seq DESC").Limit(1).Find(&last)
+	if result.Error != nil {
+		return fmt.Errorf("failed to get last audit entry: %w", result.Error)
+	}
+	entry.CnSeq = 1
+	entry.CvPrevHash = AuditGenesisHash
+	if result.RowsAffected > 0 {
+		entry.CnSeq = last.CnSeq + 1
+		entry.CvPrevHash = last.CvHash
+	}
+	entry.CvHash = seal(entry)
+	if err := tx.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to create audit entry: %w", err)
+	}
+	return nil
+}
+
+// Find возвращает записи журнала по фильтру, новые первыми
+func (r *AuditRepository) Find(filter models.AuditLogFilter, offsetref, limitref *int) ([]models.TAuditLog, int64, error) {
+	offset, limit := ValidatePageAndPageSize(offsetref, limitref)
+	query := r.db.Model(&models.TAuditLog{})
+	if filter.Actor != nil {
+		query = query.Where("ck_actor = ?", *filter.Actor)
+	}
+	if filter.Action != nil {
+		query = query.Where("cv_action = ?", *filter.Action)
+	}
+	if filter.EntityType != nil {
+		query = query.Where("cv_entity_type = ?", *filter.EntityType)
+	}
+	if filter.EntityID != nil {
+		query = query.Where("ck_entity = ?", *filter.EntityID)
+	}
+	if filter.From != nil {
+		query = query.Where("ct_create >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("ct_create < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+	var entries []models.TAuditLog
+	if err := query.Order("cn_seq DESC").Offset(offset).Limit(limit).Find(&entries).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get audit entries: %w", err)
+	}
+	return entries, total, nil
+}
+
+// GetChain возвращает до limit записей с номерами больше afterSeq в порядке цепочки
+func (r *AuditRepository) GetChain(afterSeq int64, limit int) ([]models.TAuditLog, error) {
+	var entries []models.TAuditLog
+	err := r.db.Where("cn_seq > ?", afterSeq).Order("cn_seq").Limit(limit).Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit chain: %w", err)
+	}
+	return entries, nil
+}
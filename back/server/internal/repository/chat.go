@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"time"
+
+	"parier-server/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChatRow - чат пользователя со временем последнего сообщения
+type ChatRow struct {
+	models.TChat
+	CtLastMessage *time.Time `gorm:"column:ct_last_message"`
+}
+
+// ChatMessageQuery - страница истории чата от новых сообщений к старым
+type ChatMessageQuery struct {
+	Chat   uuid.UUID
+	Before *uuid.UUID // курсор: сообщения старше этого
+	Limit  int
+}
+
+// GetBetChat возвращает комнату ставки
+func (r *ParierRepository) GetBetChat(betID uuid.UUID) (*models.TChat, error) {
+	var chat models.TChat
+	err := r.db.Where("ck_bet = ? AND ct_delete IS NULL", betID).First(&chat).Error
+	return &chat, err
+}
+
+// CreateBetChat создаёт комнату ставки, если её ещё нет; возвращает false, если её уже
+// создал одновременный запрос
+func (r *ParierRepository) CreateBetChat(chat *models.TChat, tx *gorm.DB) (bool, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	result := tx.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "ck_bet"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "ct_delete IS NULL"}}},
+		DoNothing:   true,
+	}).Create(chat)
+	return result.RowsAffected > 0, result.Error
+}
+
+// LockDirectChat блокирует создание личного чата пары пользователей до конца транзакции
+func (r *ParierRepository) LockDirectChat(tx *gorm.DB, a, b uuid.UUID) error {
+	if a.String() > b.String() {
+		a, b = b, a
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "t_chat:"+a.String()+":"+b.String()).Error
+}
+
+// GetDirectChat возвращает личный чат двух пользователей
+func (r *ParierRepository) GetDirectChat(tx *gorm.DB, a, b uuid.UUID) (*models.TChat, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var chat models.TChat
+	err := tx.Where("cl_direct AND ct_delete IS NULL").
+		Where("ck_id IN (SELECT ck_chat FROM t_chat_user WHERE ck_user = ? AND ct_delete IS NULL)", a).
+		Where("ck_id IN (SELECT ck_chat FROM t_chat_user WHERE ck_user = ? AND ct_delete IS NULL)", b).
+		First(&chat).Error
+	return &chat, err
+}
+
+// GetChatMember возвращает участие пользователя в чате
+func (r *ParierRepository) GetChatMember(chatID, userID uuid.UUID) (*models.TChatUser, error) {
+	var member models.TChatUser
+	err := r.db.Where("ck_chat = ? AND ck_user = ? AND ct_delete IS NULL", chatID, userID).First(&member).Error
+	return &member, err
+}
+
+// AddChatMember добавляет пользователя в чат; вышедший ранее участник возвращается
+func (r *ParierRepository) AddChatMember(member *models.TChatUser, tx *gorm.DB) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "ck_chat"}, {Name: "ck_user"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "cl_admin"}, Value: member.ClAdmin},
+			{Column: clause.Column{Name: "cl_moderator"}, Value: member.ClModerator},
+			{Column: clause.Column{Name: "ck_modify"}, Value: member.CkModify},
+			{Column: clause.Column{Name: "ct_modify"}, Value: gorm.Expr("now()")},
+			{Column: clause.Column{Name: "ct_delete"}, Value: nil},
+		},
+	}).Create(member).Error
+}
+
+// RemoveChatMember удаляет пользователя из чата; возвращает false, если он не участник
+func (r *ParierRepository) RemoveChatMember(chatID, userID uuid.UUID, actor string, tx *gorm.DB) (bool, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	result := tx.Model(&models.TChatUser{}).
+		Where("ck_chat = ? AND ck_user = ? AND ct_delete IS NULL", chatID, userID).
+		Updates(map[string]any{"ct_delete": time.Now(), "ck_modify": actor})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetChatMembers возвращает участников чата в порядке первого вступления
+func (r *ParierRepository) GetChatMembers(chatID uuid.UUID) ([]models.TChatUser, error) {
+	var members []models.TChatUser
+	err := r.db.Where("ck_chat = ? AND ct_delete IS NULL", chatID).
+		Order("ct_create, ck_id").
+		Find(&members).Error
+	return members, err
+}
+
+// CountChatMembers возвращает число участников чатов
+func (r *ParierRepository) CountChatMembers(chatIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	result := make(map[uuid.UUID]int64, len(chatIDs))
+	if len(chatIDs) == 0 {
+		return result, nil
+	}
+	var rows []struct {
+		CkChat uuid.UUID
+		Count  int64
+	}
+	err := r.db.Model(&models.TChatUser{}).
+		Select("ck_chat, count(*) AS count").
+		Where("ck_chat IN ? AND ct_delete IS NULL", chatIDs).
+		Group("ck_chat").
+		Scan(&rows).Error
+	for _, row := range rows {
+		result[row.CkChat] = row.Count
+	}
+	return result, err
+}
+
+// GetDirectPeers возвращает собеседников userID в личных чатах
+func (r *ParierRepository) GetDirectPeers(chatIDs []uuid.UUID, userID uuid.UUID) ([]models.TChatUser, error) {
+	var peers []models.TChatUser
+	if len(chatIDs) == 0 {
+		return peers, nil
+	}
+	err := r.db.Where("ck_chat IN ? AND ck_user <> ? AND ct_delete IS NULL", chatIDs, userID).Find(&peers).Error
+	return peers, err
+}
+
+// GetUserChats возвращает чаты пользователя, сначала с последними сообщениями
+func (r *ParierRepository) GetUserChats(userID uuid.UUID, offset, limit int) ([]ChatRow, int64, error) {
+	var total int64
+	err := r.db.Model(&models.TChatUser{}).
+		Joins("JOIN t_chat c ON c.ck_id = t_chat_user.ck_chat AND c.ct_delete IS NULL").
+		Where("t_chat_user.ck_user = ? AND t_chat_user.ct_delete IS NULL", userID).
+		Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	var rows []ChatRow
+	err = r.db.Raw(`SELECT c.*, m.ct_last_message
+		FROM t_chat c
+		JOIN t_chat_user u ON u.ck_chat = c.ck_id AND u.ck_user = @user AND u.ct_delete IS NULL
+		LEFT JOIN LATERAL (
+			SELECT max(ct_create) AS ct_last_message FROM t_chat_message
+			WHERE ck_chat = c.ck_id AND ct_delete IS NULL
+		) m ON true
+		WHERE c.ct_delete IS NULL
+		ORDER BY COALESCE(m.ct_last_message, c.ct_create) DESC, c.ck_id
+		OFFSET @offset LIMIT @limit`,
+		map[string]any{"user": userID, "offset": offset, "limit": limit}).
+		Scan(&rows).Error
+	return rows, total, err
+}
+
+// GetLastMessageTime возвращает время последнего сообщения чата
+func (r *ParierRepository) GetLastMessageTime(chatID uuid.UUID) (*time.Time, error) {
+	var last *time.Time
+	err := r.db.Model(&models.TChatMessage{}).
+		Select("max(ct_create)").
+		Where("ck_chat = ? AND ct_delete IS NULL", chatID).
+		Scan(&last).Error
+	return last, err
+}
+
+// GetChatMessages возвращает страницу истории чата с лайками, новые сообщения первыми
+func (r *ParierRepository) GetChatMessages(q ChatMessageQuery) ([]models.TChatMessage, error) {
+	query := r.db.Where("ck_chat = ? AND ct_delete IS NULL", q.Chat)
+	if q.Before != nil {
+		query = query.Where("(ct_create, ck_id) < (SELECT b.ct_create, b.ck_id FROM t_chat_message b WHERE b.ck_id = ?)", *q.Before)
+	}
+	var messages []models.TChatMessage
+	err := query.Preload("Likes", "ct_delete IS NULL").
+		Order("ct_create DESC, ck_id DESC").
+		Limit(q.Limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// GetChatMessage возвращает сообщение чата
+func (r *ParierRepository) GetChatMessage(id uuid.UUID) (*models.TChatMessage, error) {
+	var message models.TChatMessage
+	err := r.db.Where("ck_id = ? AND ct_delete IS NULL", id).First(&message).Error
+	return &message, err
+}
+
+// SetChatMessageLike ставит лайк сообщению или меняет его тип
+func (r *ParierRepository) SetChatMessageLike(like *models.TChatMessageLike, tx *gorm.DB) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "ck_message"}, {Name: "ck_author"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "ck_type"}, Value: like.CkType},
+			{Column: clause.Column{Name: "ck_modify"}, Value: like.CkModify},
+			{Column: clause.Column{Name: "ct_modify"}, Value: gorm.Expr("now()")},
+			{Column: clause.Column{Name: "ct_delete"}, Value: nil},
+		},
+	}).Create(like).Error
+}
+
+// RemoveChatMessageLike снимает лайк пользователя с сообщения
+func (r *ParierRepository) RemoveChatMessageLike(messageID, userID uuid.UUID, tx *gorm.DB) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&models.TChatMessageLike{}).
+		Where("ck_message = ? AND ck_author = ? AND ct_delete IS NULL", messageID, userID).
+		Updates(map[string]any{"ct_delete": time.Now(), "ck_modify": userID.String()}).Error
+}
+
+// SetChatMemberModerator назначает или снимает модератора чата; возвращает false, если пользователь не участник
+func (r *ParierRepository) SetChatMemberModerator(chatID, userID uuid.UUID, moderator bool, actor string, tx *gorm.DB) (bool, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	result := tx.Model(&models.TChatUser{}).
+		Where("ck_chat = ? AND ck_user = ? AND ct_delete IS NULL", chatID, userID).
+		Updates(map[string]any{"cl_moderator": moderator, "ck_modify": actor, "ct_modify": time.Now()})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetChatUserBan возвращает ограничение пользователя в чате, в том числе истёкшее.
+// В транзакции tx ограничение блокируется до её конца (FOR SHARE): новый бан дождётся
+// входа или сообщения, которые проверили ограничение
+func (r *ParierRepository) GetChatUserBan(chatID, userID uuid.UUID, tx *gorm.DB) (*models.TChatUserBan, error) {
+	query := r.db
+	if tx != nil {
+		query = tx.Clauses(clause.Locking{Strength: "SHARE"})
+	}
+	var ban models.TChatUserBan
+	err := query.Where("ck_chat = ? AND ck_user = ? AND ct_delete IS NULL", chatID, userID).First(&ban).Error
+	return &ban, err
+}
+
+// SetChatUserBan ограничивает пользователя в чате; у пользователя одно ограничение, новое заменяет прежнее
+func (r *ParierRepository) SetChatUserBan(ban *models.TChatUserBan, tx *gorm.DB) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "ck_chat"}, {Name: "ck_user"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "cr_type"}, Value: ban.CrType},
+			{Column: clause.Column{Name: "ct_start"}, Value: ban.CtStart},
+			{Column: clause.Column{Name: "ct_end"}, Value: ban.CtEnd},
+			{Column: clause.Column{Name: "cv_reason"}, Value: ban.CvReason},
+			{Column: clause.Column{Name: "ck_modify"}, Value: ban.CkModify},
+			{Column: clause.Column{Name: "ct_modify"}, Value: gorm.Expr("now()")},
+			{Column: clause.Column{Name: "ct_delete"}, Value: nil},
+		},
+	}).Create(ban).Error
+}
+
+// RemoveChatUserBan снимает ограничение пользователя в чате; возвращает false, если его нет
+func (r *ParierRepository) RemoveChatUserBan(chatID, userID uuid.UUID, actor string, tx *gorm.DB) (bool, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	result := tx.Model(&models.TChatUserBan{}).
+		Where("ck_chat = ? AND ck_user = ? AND ct_delete IS NULL", chatID, userID).
+		Updates(map[string]any{"ct_delete": time.Now(), "ck_modify": actor})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetChatUserBans возвращает действующие и будущие ограничения чата, новые первыми
+func (r *ParierRepository) GetChatUserBans(chatID uuid.UUID) ([]models.TChatUserBan, error) {
+	var bans []models.TChatUserBan
+	err := r.db.Where("ck_chat = ? AND ct_delete IS NULL AND (ct_end IS NULL OR ct_end > NOW())", chatID).
+		Order("ct_modify DESC, ck_id").
+		Find(&bans).Error
+	return bans, err
+}
+
+// GetChatInvites возвращает неотозванные приглашения чата, новые первыми
+func (r *ParierRepository) GetChatInvites(chatID uuid.UUID) ([]models.TChatCredentials, error) {
+	var invites []models.TChatCredentials
+	err := r.db.Where("ck_chat = ? AND ct_delete IS NULL", chatID).
+		Order("ct_create DESC, ck_id").
+		Find(&invites).Error
+	return invites, err
+}
+
+// CountActiveChatInvites возвращает число неотозванных и неистёкших приглашений чата типа kind
+func (r *ParierRepository) CountActiveChatInvites(chatID uuid.UUID, kind models.ChatCredentialsType) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.TChatCredentials{}).
+		Where("ck_chat = ? AND cr_type = ? AND ct_delete IS NULL AND (ct_end IS NULL OR ct_end > NOW())", chatID, kind).
+		Count(&count).Error
+	return count, err
+}
+
+// ConsumeChatCredentials отзывает приглашение; возвращает false, если оно уже отозвано или использовано.
+// Для одноразового приглашения в транзакции входа гарантирует, что войдёт только один пользователь
+func (r *ParierRepository) ConsumeChatCredentials(id uuid.UUID, actor string, tx *gorm.DB) (bool, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	result := tx.Model(&models.TChatCredentials{}).
+		Where("ck_id = ? AND ct_delete IS NULL", id).
+		Updates(map[string]any{"ct_delete": time.Now(), "ck_modify": actor})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"fmt"
+
+	"parier-server/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// CommentVisible - условие показа комментария с псевдонимом таблицы alias: удалённый
+// остаётся в дереве как "комментарий удалён", пока под ним есть неудалённые ответы
+// на любой глубине. Удалённые ответы сами по себе ветку не держат
+func CommentVisible(alias string) string {
+	return fmt.Sprintf(`(%[1]s.ct_delete IS NULL OR EXISTS (
+		WITH RECURSIVE v AS (
+			SELECT ck_id, ct_delete FROM t_bet_comment WHERE ck_parent = %[1]s.ck_id
+			UNION ALL
+			SELECT r.ck_id, r.ct_delete FROM t_bet_comment r JOIN v ON r.ck_parent = v.ck_id
+		)
+		SELECT 1 FROM v WHERE v.ct_delete IS NULL))`, alias)
+}
+
+// CommentTreeQuery описывает выборку части дерева комментариев к ставке.
+// Первый уровень - комментарии верхнего уровня ставки или ответы на комментарий Parent,
+// под каждым из них загружается до Depth уровней ответов, по Replies ответов на комментарий
+type CommentTreeQuery struct {
+	BetID   uuid.UUID
+	Parent  *uuid.UUID // nil - первый уровень состоит из комментариев верхнего уровня
+	After   *uuid.UUID // курсор: первый уровень начинается после этого комментария
+	Desc    bool       // первый уровень от новых к старым, ответы всегда по времени создания
+	Offset  int
+	Limit   int
+	Depth   int
+	Replies int
+	Viewer  uuid.UUID // для признака cl_liked_by_me
+}
+
+// CommentNode - комментарий дерева с глубиной относительно первого уровня и счётчиками
+type CommentNode struct {
+	models.TBetComment
+	CnDepth     int   `gorm:"column:cn_depth"`
+	CnReplies   int64 `gorm:"column:cn_replies"`
+	CnLikes     int64 `gorm:"column:cn_likes"`
+	ClLikedByMe bool  `gorm:"column:cl_liked_by_me"`
+}
+
+// CountCommentLevel возвращает число комментариев первого уровня выборки q
+func (r *ParierRepository) CountCommentLevel(q CommentTreeQuery) (int64, error) {
+	query := r.db.Model(&models.TBetComment{}).Where(CommentVisible("t_bet_comment"))
+	if q.Parent != nil {
+		query = query.Where("ck_parent = ?", *q.Parent)
+	} else {
+		query = query.Where("ck_bet = ? AND ck_parent IS NULL", q.BetID)
+	}
+	var total int64
+	err := query.Count(&total).Error
+	return total, err
+}
+
+// GetCommentTree загружает часть дерева одним запросом с рекурсивным CTE. Комментарии
+// возвращаются по уровням: первый уровень в порядке выборки, ответы - по времени создания
+func (r *ParierRepository) GetCommentTree(q CommentTreeQuery) ([]CommentNode, error) {
+	level := "c.ck_bet = @bet AND c.ck_parent IS NULL"
+	if q.Parent != nil {
+		level = "c.ck_parent = @parent"
+	}
+	order, after := "ASC", ">"
+	if q.Desc {
+		order, after = "DESC", "<"
+	}
+	if q.After != nil {
+		level += " AND (c.ct_create, c.ck_id) " + after + " (SELECT a.ct_create, a.ck_id FROM t_bet_comment a WHERE a.ck_id = @after)"
+	}
+	var nodes []CommentNode
+	err := r.db.Raw(`WITH RECURSIVE tree AS (
+			(SELECT c.*, 0 AS cn_depth, row_number() OVER (ORDER BY c.ct_create `+order+`, c.ck_id `+order+`) AS cn_order
+			FROM t_bet_comment c
+			WHERE `+level+` AND `+CommentVisible("c")+`
+			ORDER BY c.ct_create `+order+`, c.ck_id `+order+`
+			OFFSET @offset LIMIT @limit)
+			UNION ALL
+			SELECT r.*, t.cn_depth + 1, 0::bigint
+			FROM tree t
+			CROSS JOIN LATERAL (
+				SELECT c.* FROM t_bet_comment c
+				WHERE c.ck_parent = t.ck_id AND `+CommentVisible("c")+`
+				ORDER BY c.ct_create, c.ck_id
+				LIMIT @replies
+			) r
+			WHERE t.cn_depth < @depth
+		)
+		SELECT tree.*,
+			(SELECT count(*) FROM t_bet_comment c WHERE c.ck_parent = tree.ck_id AND `+CommentVisible("c")+`) AS cn_replies,
+			(SELECT count(*) FROM t_bet_comment_like l WHERE l.ck_comment = tree.ck_id AND l.ct_delete IS NULL) AS cn_likes,
+			EXISTS(SELECT 1 FROM t_bet_comment_like l WHERE l.ck_comment = tree.ck_id AND l.ck_author = @viewer AND l.ct_delete IS NULL) AS cl_liked_by_me
+		FROM tree
+		ORDER BY tree.cn_depth, tree.cn_order, tree.ct_create, tree.ck_id`,
+		map[string]any{
+			"bet":     q.BetID,
+			"parent":  q.Parent,
+			"after":   q.After,
+			"offset":  q.Offset,
+			"limit":   q.Limit,
+			"replies": q.Replies,
+			"depth":   q.Depth,
+			"viewer":  q.Viewer,
+		}).Scan(&nodes).Error
+	return nodes, err
+}
+
+// FindBetComment возвращает комментарий, в том числе удалённый
+func (r *ParierRepository) FindBetComment(id uuid.UUID) (*models.TBetComment, error) {
+	var betComment models.TBetComment
+	err := r.db.Where("ck_id = ?", id).First(&betComment).Error
+	return &betComment, err
+}
+
+// GetCommentAuthors загружает авторов комментариев со свойствами и статистикой для профиля
+func (r *ParierRepository) GetCommentAuthors(ids []uuid.UUID) ([]models.TUser, error) {
+	var users []models.TUser
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.Where("ck_id IN ?", ids).
+		Preload("UserProperties").
+		Preload("UserProperties.PropertyType").
+		Preload("LikesReceived").
+		Preload("RatingsReceived").
+		Preload("Reputation").
+		Find(&users).Error
+	return users, err
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// eventChannel is the Postgres NOTIFY channel of the real-time event stream
+const eventChannel = "parier_event"
+
+// eventListenRetry is the pause before reconnecting a dropped LISTEN connection
+const eventListenRetry = 5 * time.Second
+
+// EventNotifier carries real-time events between API instances. Every instance,
+// including the publisher, receives events from Postgres, so the publisher needs
+// no local shortcut. NOTIFY sent inside a transaction is delivered only after commit.
+type EventNotifier struct {
+	db  *gorm.DB
+	dsn string
+}
+
+func NewEventNotifier(db *gorm.DB, dsn string) *EventNotifier {
+	return &EventNotifier{db: db, dsn: dsn}
+}
+
+// Publish sends an event payload; with tx it is delivered when tx commits
+func (n *EventNotifier) Publish(tx *gorm.DB, payload string) error {
+	if tx == nil {
+		tx = n.db
+	}
+	return tx.Exec("SELECT pg_notify(?, ?)", eventChannel, payload).Error
+}
+
+// Listen calls handler with event payloads until ctx is cancelled. Events sent
+// while the connection was down are lost, so after every (re)connect handler is
+// called with an empty payload, meaning "state may be stale".
+func (n *EventNotifier) Listen(ctx context.Context, handler func(payload string)) {
+	for ctx.Err() == nil {
+		if err := n.listen(ctx, handler); err != nil && ctx.Err() == nil {
+			log.Printf("Event listener failed: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(eventListenRetry):
+			}
+		}
+	}
+}
+
+func (n *EventNotifier) listen(ctx context.Context, handler func(payload string)) error {
+	conn, err := pgx.Connect(ctx, n.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+eventChannel); err != nil {
+		return err
+	}
+	handler("")
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		if notification.Payload != "" {
+			handler(notification.Payload)
+		}
+	}
+}
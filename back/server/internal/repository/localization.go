@@ -2,20 +2,35 @@ package repository
 
 import (
 	"parier-server/internal/models"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type LocalizationRepository struct {
 	db *gorm.DB
+	// version is bumped on every translation or language write so that
+	// LocalizationResolver caches drop stale texts
+	version atomic.Uint64
 }
 
 func NewLocalizationRepository(db *gorm.DB) *LocalizationRepository {
 	return &LocalizationRepository{db: db}
 }
 
+func (r *LocalizationRepository) GetDB() *gorm.DB {
+	return r.db
+}
+
+// Invalidate marks cached translations as stale. Call it after committing a
+// transaction that changed translations.
+func (r *LocalizationRepository) Invalidate() {
+	r.version.Add(1)
+}
+
 // === T_L_WORD ===
 
 func (r *LocalizationRepository) CreateWord(word *models.TLWord, tx *gorm.DB) error {
@@ -104,7 +119,13 @@ func (r *LocalizationRepository) GetLocalesAfter(lang *string, ns *string, after
 }
 
 func (r *LocalizationRepository) GetOrCreateWord(text string, userID string, tx *gorm.DB) (*models.TLWord, error) {
-	word, err := r.GetWordByText(text)
+	db := r.db
+	if tx != nil {
+		// words created earlier in the same transaction are only visible through tx
+		db = tx
+	}
+	word := &models.TLWord{}
+	err := db.Where("cv_text = ? AND ct_delete IS NULL", text).First(word).Error
 	if err == nil {
 		return word, nil
 	}
@@ -159,6 +180,7 @@ func (r *LocalizationRepository) DeleteWord(id uuid.UUID, userID string) error {
 // === T_D_LANG ===
 
 func (r *LocalizationRepository) CreateLanguage(lang *models.TDLang, tx *gorm.DB) error {
+	defer r.Invalidate()
 	if tx != nil {
 		return tx.Create(lang).Error
 	}
@@ -206,10 +228,12 @@ func (r *LocalizationRepository) GetAllLanguages() ([]models.TDLang, error) {
 }
 
 func (r *LocalizationRepository) UpdateLanguage(lang *models.TDLang) error {
+	defer r.Invalidate()
 	return r.db.Save(lang).Error
 }
 
 func (r *LocalizationRepository) DeleteLanguage(id string, userID string) error {
+	defer r.Invalidate()
 	return r.db.Model(&models.TDLang{}).
 		Where("ck_id = ? AND ct_delete IS NULL", id).
 		Update("ct_delete", gorm.Expr("NOW()")).
@@ -351,18 +375,199 @@ func (r *LocalizationRepository) GetLocalizationWordByID(localizationID string,
 }
 
 func (r *LocalizationRepository) UpdateLocalizationWord(locWord *models.TLocalizationWord) error {
+	defer r.Invalidate()
 	return r.db.Save(locWord).Error
 }
 
 func (r *LocalizationRepository) DeleteLocalizationWord(localizationID string, langID string, userID string) error {
+	defer r.Invalidate()
 	return r.db.Model(&models.TLocalizationWord{}).
 		Where("ck_localization = ? AND ck_lang = ? AND ct_delete IS NULL", localizationID, langID).
 		Update("ct_delete", gorm.Expr("NOW()")).
 		Update("ck_modify", userID).Error
 }
 
+func (r *LocalizationRepository) UpsertLocalizationWord(locWord *models.TLocalizationWord, tx *gorm.DB) error {
+	defer r.Invalidate()
+	db := r.db
+	if tx != nil {
+		db = tx
+	}
+	// a soft-deleted row still holds the primary key, so revive it instead of inserting
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "ck_localization"}, {Name: "ck_lang"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"ck_text":    locWord.CkText,
+			"cl_machine": locWord.ClMachine,
+			"ck_review":  locWord.CkReview,
+			"ct_review":  locWord.CtReview,
+			"ck_modify":  locWord.CkModify,
+			"ct_modify":  gorm.Expr("NOW()"),
+			"ct_delete":  nil,
+		}),
+	}).Create(locWord).Error
+}
+
+// InsertMachineTranslation adds a machine translation only if the key has no row in
+// the language: a person may have saved one while the AI call was running, and a
+// soft-deleted row was removed on purpose and must not come back. Reports whether it was added
+func (r *LocalizationRepository) InsertMachineTranslation(locWord *models.TLocalizationWord) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "ck_localization"}, {Name: "ck_lang"}},
+		DoNothing: true,
+	}).Create(locWord)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	r.Invalidate()
+	return true, nil
+}
+
+// === TRANSLATION MANAGEMENT ===
+
+type TranslationCoverage struct {
+	Lang       string `json:"lang" gorm:"column:ck_lang"`
+	Namespace  string `json:"namespace" gorm:"column:cr_type"`
+	Total      int64  `json:"total" gorm:"column:cn_total"`
+	Translated int64  `json:"translated" gorm:"column:cn_translated"`
+}
+
+// GetTranslationCoverage counts translated keys per language and namespace
+func (r *LocalizationRepository) GetTranslationCoverage(ns *string) ([]TranslationCoverage, error) {
+	var coverage []TranslationCoverage
+	query := r.db.Table("t_d_lang tdl").
+		Select("tdl.ck_id as ck_lang, tl.cr_type, count(tl.ck_id) as cn_total, count(tlw.ck_localization) as cn_translated").
+		Joins("cross join t_localization tl").
+		Joins("left join t_localization_word tlw on tlw.ck_localization = tl.ck_id and tlw.ck_lang = tdl.ck_id and tlw.ct_delete IS NULL").
+		Where("tdl.ct_delete IS NULL AND tl.ct_delete IS NULL")
+	if ns != nil {
+		query = query.Where("tl.cr_type = ?", *ns)
+	}
+	err := query.Group("tdl.ck_id, tl.cr_type").
+		Order("tdl.ck_id ASC, tl.cr_type ASC").
+		Scan(&coverage).Error
+	return coverage, err
+}
+
+// GetMissingTranslations returns localization keys without an active translation for langID
+func (r *LocalizationRepository) GetMissingTranslations(langID string, ns *string, offsetref, limitref *int) ([]models.TLocalization, int64, error) {
+	var localizations []models.TLocalization
+	var total int64
+	query := r.db.Model(&models.TLocalization{}).
+		Where("t_localization.ct_delete IS NULL").
+		Where("NOT EXISTS (select 1 from t_localization_word tlw where tlw.ck_localization = t_localization.ck_id and tlw.ck_lang = ? and tlw.ct_delete IS NULL)", langID)
+	if ns != nil {
+		query = query.Where("t_localization.cr_type = ?", *ns)
+	}
+	err := query.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	offset, limit := ValidatePageAndPageSize(offsetref, limitref)
+	err = query.Offset(offset).Limit(limit).
+		Preload("LocalizationWords", "ct_delete IS NULL").
+		Preload("LocalizationWords.TextWord", "ct_delete IS NULL").
+		Order("t_localization.ck_id ASC").
+		Find(&localizations).Error
+	return localizations, total, err
+}
+
+// GetMachineTranslationCandidates returns localizations that have a translation
+// in some language but none in langID, oldest first, skipping excluded keys
+func (r *LocalizationRepository) GetMachineTranslationCandidates(langID string, excluded []string, limit int) ([]models.TLocalization, error) {
+	var localizations []models.TLocalization
+	query := r.db.Model(&models.TLocalization{}).
+		Where("t_localization.ct_delete IS NULL").
+		Where("EXISTS (select 1 from t_localization_word tlw where tlw.ck_localization = t_localization.ck_id and tlw.ct_delete IS NULL)").
+		// a deleted translation was removed on purpose, so the key is not translated again
+		Where("NOT EXISTS (select 1 from t_localization_word tlw where tlw.ck_localization = t_localization.ck_id and tlw.ck_lang = ?)", langID)
+	if len(excluded) > 0 {
+		query = query.Where("t_localization.ck_id NOT IN ?", excluded)
+	}
+	err := query.Limit(limit).
+		Preload("LocalizationWords", "ct_delete IS NULL").
+		Preload("LocalizationWords.TextWord", "ct_delete IS NULL").
+		Order("t_localization.ct_create ASC").
+		Find(&localizations).Error
+	return localizations, err
+}
+
+// GetPendingReview returns machine translations of langID not yet reviewed by a person
+func (r *LocalizationRepository) GetPendingReview(langID string, offsetref, limitref *int) ([]models.TLocalizationWord, int64, error) {
+	var words []models.TLocalizationWord
+	var total int64
+	query := r.db.Model(&models.TLocalizationWord{}).
+		Where("ck_lang = ? AND cl_machine AND ct_review IS NULL AND ct_delete IS NULL", langID)
+	err := query.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	offset, limit := ValidatePageAndPageSize(offsetref, limitref)
+	err = query.Offset(offset).Limit(limit).
+		Preload("TextWord", "ct_delete IS NULL").
+		Preload("Localization.LocalizationWords", "ct_delete IS NULL AND NOT cl_machine").
+		Preload("Localization.LocalizationWords.TextWord", "ct_delete IS NULL").
+		Order("ct_modify ASC").
+		Find(&words).Error
+	return words, total, err
+}
+
+// ApproveTranslations marks machine translations of langID as reviewed
+func (r *LocalizationRepository) ApproveTranslations(langID string, keys []string, userID string) (int64, error) {
+	res := r.db.Model(&models.TLocalizationWord{}).
+		Where("ck_lang = ? AND ck_localization IN ? AND cl_machine AND ct_review IS NULL AND ct_delete IS NULL", langID, keys).
+		Updates(map[string]interface{}{"ck_review": userID, "ct_review": gorm.Expr("NOW()"), "ck_modify": userID})
+	return res.RowsAffected, res.Error
+}
+
+// GetLocalizationKeys returns all active localization keys of the namespace
+func (r *LocalizationRepository) GetLocalizationKeys(ns string) ([]string, error) {
+	var keys []string
+	err := r.db.Model(&models.TLocalization{}).
+		Where("cr_type = ? AND ct_delete IS NULL", ns).
+		Order("ck_id ASC").
+		Pluck("ck_id", &keys).Error
+	return keys, err
+}
+
+// GetLocalizationNamespaces maps the existing keys among keys to their namespace
+func (r *LocalizationRepository) GetLocalizationNamespaces(keys []string) (map[string]string, error) {
+	namespaces := make(map[string]string)
+	if len(keys) == 0 {
+		return namespaces, nil
+	}
+	var localizations []models.TLocalization
+	err := r.db.Select("ck_id, cr_type").
+		Where("ck_id IN ? AND ct_delete IS NULL", keys).
+		Find(&localizations).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, loc := range localizations {
+		namespaces[loc.CkId] = string(loc.CrType)
+	}
+	return namespaces, nil
+}
+
 // === HELPER METHODS ===
 
+// GetTranslationText returns the current translation of the key in the language, nil if there is none
+func (r *LocalizationRepository) GetTranslationText(localizationID string, langID string, tx *gorm.DB) (*string, error) {
+	db := r.db
+	if tx != nil {
+		db = tx
+	}
+	var locWord models.TLocalizationWord
+	err := db.Where("ck_localization = ? AND ck_lang = ? AND ct_delete IS NULL", localizationID, langID).
+		Preload("TextWord", "ct_delete IS NULL").
+		Limit(1).
+		Find(&locWord).Error
+	if err != nil || locWord.TextWord == nil {
+		return nil, err
+	}
+	return &locWord.TextWord.CvText, nil
+}
+
 func (r *LocalizationRepository) GetLocalizedText(localizationID string, langID string) (string, error) {
 	// Try to get localized version
 	var locWord models.TLocalizationWord
@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resolverCheckInterval limits how often the resolver asks the database whether
+// translations changed, which also picks up writes made by other instances
+const resolverCheckInterval = 5 * time.Second
+
+// LocalizedTexts maps localization IDs to resolved texts
+type LocalizedTexts map[string]string
+
+// Get returns the text for id, or nil when id is nil or unresolved
+func (t LocalizedTexts) Get(id *string) *string {
+	if id == nil {
+		return nil
+	}
+	text, ok := t[*id]
+	if !ok {
+		return nil
+	}
+	return &text
+}
+
+// Text returns the text for id, or an empty string when unresolved
+func (t LocalizedTexts) Text(id string) string {
+	return t[id]
+}
+
+// LocalizationResolver resolves localization IDs in bulk with the same fallback
+// as GetWordOrDefault (requested language, then default language, then any)
+// and caches the results in process.
+type LocalizationResolver struct {
+	repo *LocalizationRepository
+	ttl  time.Duration
+
+	mu          sync.RWMutex
+	texts       map[string]map[string]resolvedText // lang -> localization ID -> text
+	defaultLang string
+	langs       map[string]bool // IDs of t_d_lang; texts are cached only for them
+	version     uint64
+	stamp       sql.NullTime
+	checkedAt   time.Time
+}
+
+type resolvedText struct {
+	text string
+	at   time.Time
+}
+
+type localizedRow struct {
+	CkLocalization string `gorm:"column:ck_localization"`
+	CkLang         string `gorm:"column:ck_lang"`
+	CvText         string `gorm:"column:cv_text"`
+}
+
+func NewLocalizationResolver(repo *LocalizationRepository, ttl time.Duration) *LocalizationResolver {
+	return &LocalizationResolver{
+		repo:  repo,
+		ttl:   ttl,
+		texts: make(map[string]map[string]resolvedText),
+	}
+}
+
+// Word resolves a single localization ID
+func (r *LocalizationResolver) Word(id *string, lang *string) *string {
+	if id == nil {
+		return nil
+	}
+	return r.Resolve(lang, id).Get(id)
+}
+
+// Resolve returns texts for all non-nil ids in lang, querying the database
+// once for the ids that are not cached. lang is matched against t_d_lang
+// ignoring case; an unknown or missing language resolves in the default one
+func (r *LocalizationResolver) Resolve(lang *string, ids ...*string) LocalizedTexts {
+	r.refresh()
+	defaultLang, langs := r.getLanguages()
+	target := defaultLang
+	if lang != nil {
+		if normalized := strings.ToUpper(strings.TrimSpace(*lang)); langs[normalized] {
+			target = normalized
+		}
+	}
+
+	result := make(LocalizedTexts, len(ids))
+	seen := make(map[string]bool, len(ids))
+	var missing []string
+	now := time.Now()
+	r.mu.RLock()
+	cached := r.texts[target]
+	for _, id := range ids {
+		if id == nil || seen[*id] {
+			continue
+		}
+		seen[*id] = true
+		if entry, ok := cached[*id]; ok && now.Sub(entry.at) < r.ttl {
+			result[*id] = entry.text
+			continue
+		}
+		missing = append(missing, *id)
+	}
+	r.mu.RUnlock()
+	if len(missing) == 0 {
+		return result
+	}
+
+	var rows []localizedRow
+	err := r.repo.db.Table("t_localization_word tlw").
+		Select("tlw.ck_localization, tlw.ck_lang, tw.cv_text").
+		Joins("join t_l_word tw on tw.ck_id = tlw.ck_text and tw.ct_delete IS NULL").
+		Where("tlw.ck_localization IN ? AND tlw.ct_delete IS NULL", missing).
+		Scan(&rows).Error
+	if err != nil {
+		log.Printf("Failed to resolve localizations: %v", err)
+		return result
+	}
+	// rows are ordered so the requested language wins, then the default one,
+	// then the first language alphabetically
+	rank := func(row localizedRow) int {
+		switch row.CkLang {
+		case target:
+			return 0
+		case defaultLang:
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rank(rows[i]) != rank(rows[j]) {
+			return rank(rows[i]) < rank(rows[j])
+		}
+		return rows[i].CkLang < rows[j].CkLang
+	})
+	resolved := make(map[string]string, len(missing))
+	for _, row := range rows {
+		if _, ok := resolved[row.CkLocalization]; !ok {
+			resolved[row.CkLocalization] = row.CvText
+		}
+	}
+
+	r.mu.Lock()
+	if r.texts[target] == nil {
+		r.texts[target] = make(map[string]resolvedText)
+	}
+	for id, text := range resolved {
+		r.texts[target][id] = resolvedText{text: text, at: now}
+		result[id] = text
+	}
+	r.mu.Unlock()
+	return result
+}
+
+// Invalidate drops all cached texts
+func (r *LocalizationResolver) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.texts = make(map[string]map[string]resolvedText)
+	r.defaultLang = ""
+	r.langs = nil
+}
+
+// refresh drops the cache when the repository recorded a write or, at most
+// every resolverCheckInterval, when the latest change in the database moved
+func (r *LocalizationResolver) refresh() {
+	version := r.repo.version.Load()
+	r.mu.RLock()
+	stale := version != r.version
+	check := time.Since(r.checkedAt) > resolverCheckInterval
+	r.mu.RUnlock()
+	if !stale && !check {
+		return
+	}
+
+	var stamp sql.NullTime
+	checked := false
+	if check {
+		err := r.repo.db.Raw(`select greatest(
+	(select max(greatest(ct_modify, coalesce(ct_delete, ct_modify))) from t_localization_word),
+	(select max(greatest(ct_modify, coalesce(ct_delete, ct_modify))) from t_d_lang))`).
+			Row().Scan(&stamp)
+		if err != nil {
+			log.Printf("Failed to check localization changes: %v", err)
+		} else {
+			checked = true
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if check {
+		r.checkedAt = time.Now()
+	}
+	if checked && (stamp.Valid != r.stamp.Valid || !stamp.Time.Equal(r.stamp.Time)) {
+		r.stamp = stamp
+		stale = true
+	}
+	if stale {
+		r.version = version
+		r.texts = make(map[string]map[string]resolvedText)
+		r.defaultLang = ""
+		r.langs = nil
+	}
+}
+
+// getLanguages returns the default language and the IDs of all languages.
+// When they cannot be loaded every request resolves in DEFAULT_LANGUAGE
+func (r *LocalizationResolver) getLanguages() (string, map[string]bool) {
+	r.mu.RLock()
+	defaultLang, langs := r.defaultLang, r.langs
+	r.mu.RUnlock()
+	if langs != nil {
+		return defaultLang, langs
+	}
+
+	var rows []struct {
+		CkId      string `gorm:"column:ck_id"`
+		ClDefault bool   `gorm:"column:cl_default"`
+	}
+	err := r.repo.db.Table("t_d_lang").Select("ck_id, cl_default").Where("ct_delete IS NULL").Scan(&rows).Error
+	if err != nil {
+		log.Printf("Failed to load languages: %v", err)
+		return DEFAULT_LANGUAGE, map[string]bool{DEFAULT_LANGUAGE: true}
+	}
+	defaultLang, langs = DEFAULT_LANGUAGE, make(map[string]bool, len(rows))
+	for _, row := range rows {
+		langs[row.CkId] = true
+		if row.ClDefault {
+			defaultLang = row.CkId
+		}
+	}
+	r.mu.Lock()
+	r.defaultLang, r.langs = defaultLang, langs
+	r.mu.Unlock()
+	return defaultLang, langs
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"parier-server/internal/util/dbtest"
+)
+
+// resolverDB answers the resolver queries: languages EN (default) and DE,
+// and an English text "<id>:EN" for every requested id
+func resolverDB(t *testing.T) (*LocalizationResolver, *dbtest.DB) {
+	db, fake := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		switch {
+		case q.Has("select greatest"):
+			return dbtest.Result{Columns: []string{"greatest"}, Rows: [][]driver.Value{{nil}}}
+		case q.Has(`FROM "t_d_lang"`):
+			return dbtest.Result{Columns: []string{"ck_id", "cl_default"}, Rows: [][]driver.Value{{"EN", true}, {"DE", false}}}
+		case q.Has("FROM t_localization_word tlw"):
+			res := dbtest.Result{Columns: []string{"ck_localization", "ck_lang", "cv_text"}}
+			for _, arg := range q.Args {
+				res.Rows = append(res.Rows, []driver.Value{arg, "EN", fmt.Sprintf("%s:EN", arg)})
+			}
+			return res
+		}
+		return dbtest.Result{}
+	})
+	return NewLocalizationResolver(NewLocalizationRepository(db), time.Minute), fake
+}
+
+func resolverIDs(ids ...string) []*string {
+	refs := make([]*string, len(ids))
+	for i := range ids {
+		refs[i] = &ids[i]
+	}
+	return refs
+}
+
+func TestLocalizationResolverBatch(t *testing.T) {
+	r, fake := resolverDB(t)
+	ids := resolverIDs("bet.one", "bet.two", "bet.three", "bet.one")
+
+	texts := r.Resolve(nil, ids...)
+	if len(texts) != 3 || texts.Text("bet.two") != "bet.two:EN" {
+		t.Fatalf("unexpected texts %v", texts)
+	}
+	if count := fake.Count("FROM t_localization_word tlw"); count != 1 {
+		t.Fatalf("expected one query for all ids, got %d", count)
+	}
+	r.Resolve(nil, ids...)
+	if count := fake.Count("FROM t_localization_word tlw"); count != 1 {
+		t.Errorf("expected cached texts to be reused, got %d queries", count)
+	}
+}
+
+func TestLocalizationResolverLanguage(t *testing.T) {
+	r, fake := resolverDB(t)
+	id := resolverIDs("bet.one")
+
+	for _, lang := range []string{"en", "EN", " En ", "xx", "<script>"} {
+		if text := r.Resolve(&lang, id...).Text("bet.one"); text != "bet.one:EN" {
+			t.Errorf("language %q: unexpected text %q", lang, text)
+		}
+	}
+	r.Resolve(nil, id...)
+	if count := fake.Count("FROM t_localization_word tlw"); count != 1 {
+		t.Errorf("expected one query for the default language, got %d", count)
+	}
+	de := "de"
+	r.Resolve(&de, id...)
+	if len(r.texts) != 2 || r.texts["EN"] == nil || r.texts["DE"] == nil {
+		t.Errorf("expected texts cached only under known languages, got %d languages", len(r.texts))
+	}
+}
+
+func TestLocalizationResolverTTL(t *testing.T) {
+	r, fake := resolverDB(t)
+	id := resolverIDs("bet.one")
+
+	r.Resolve(nil, id...)
+	entry := r.texts["EN"]["bet.one"]
+	entry.at = entry.at.Add(-r.ttl)
+	r.texts["EN"]["bet.one"] = entry
+	r.Resolve(nil, id...)
+	if count := fake.Count("FROM t_localization_word tlw"); count != 2 {
+		t.Errorf("expected an expired text to be loaded again, got %d queries", count)
+	}
+}
+
+func TestLocalizationResolverInvalidate(t *testing.T) {
+	r, fake := resolverDB(t)
+	id := resolverIDs("bet.one")
+
+	r.Resolve(nil, id...)
+	r.Invalidate()
+	if len(r.texts) != 0 || r.langs != nil {
+		t.Fatal("expected Invalidate to clear cached texts and languages")
+	}
+	r.Resolve(nil, id...)
+	if count := fake.Count("FROM t_localization_word tlw"); count != 2 {
+		t.Errorf("expected texts to be loaded again after Invalidate, got %d queries", count)
+	}
+	if count := fake.Count(`FROM "t_d_lang"`); count != 2 {
+		t.Errorf("expected languages to be loaded again after Invalidate, got %d queries", count)
+	}
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"parier-server/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GetUsersByUsernames находит пользователей по именам без учёта регистра.
+// Возвращает имя в нижнем регистре -> пользователи с этим именем
+func (r *ParierRepository) GetUsersByUsernames(usernames []string, tx *gorm.DB) (map[string][]uuid.UUID, error) {
+	users := make(map[string][]uuid.UUID)
+	if len(usernames) == 0 {
+		return users, nil
+	}
+	if tx == nil {
+		tx = r.db
+	}
+	var rows []struct {
+		CkUser uuid.UUID
+		CvText string
+	}
+	err := tx.Model(&models.TUserProperties{}).
+		Select("DISTINCT ck_user, cv_text").
+		Where("ck_type = ? AND lower(cv_text) IN ? AND ct_delete IS NULL", "USER_USERNAME", usernames).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		key := strings.ToLower(row.CvText)
+		users[key] = append(users[key], row.CkUser)
+	}
+	return users, nil
+}
+
+func (r *ParierRepository) GetCommentMentions(commentIDs []uuid.UUID, tx *gorm.DB) ([]models.TBetCommentMention, error) {
+	var mentions []models.TBetCommentMention
+	if len(commentIDs) == 0 {
+		return mentions, nil
+	}
+	if tx == nil {
+		tx = r.db
+	}
+	err := tx.Where("ck_comment IN ? AND ct_delete IS NULL", commentIDs).
+		Order("cn_offset ASC").Find(&mentions).Error
+	return mentions, err
+}
+
+// ReplaceCommentMentions заменяет упоминания комментария новыми
+func (r *ParierRepository) ReplaceCommentMentions(commentID uuid.UUID, mentions []models.TBetCommentMention, tx *gorm.DB) error {
+	if err := tx.Where("ck_comment = ?", commentID).Delete(&models.TBetCommentMention{}).Error; err != nil {
+		return err
+	}
+	if len(mentions) == 0 {
+		return nil
+	}
+	return tx.Create(&mentions).Error
+}
+
+// GetUnreadMentions возвращает непрочитанные упоминания пользователя в неудалённых комментариях, новые первыми
+func (r *ParierRepository) GetUnreadMentions(userID uuid.UUID, offset, limit int) ([]models.TBetCommentMention, int64, error) {
+	query := r.db.Model(&models.TBetCommentMention{}).
+		Joins("JOIN t_bet_comment c ON c.ck_id = t_bet_comment_mention.ck_comment AND c.ct_delete IS NULL").
+		Where("t_bet_comment_mention.ck_user = ? AND t_bet_comment_mention.ct_read IS NULL AND t_bet_comment_mention.ct_delete IS NULL", userID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var mentions []models.TBetCommentMention
+	err := query.
+		Preload("Comment").
+		Preload("Comment.Author").
+		Preload("Comment.Author.UserProperties").
+		Preload("Comment.Author.UserProperties.PropertyType").
+		Preload("Comment.Author.Reputation").
+		Preload("Comment.Mentions", "ct_delete IS NULL").
+		Order("t_bet_comment_mention.ct_create DESC").
+		Offset(offset).Limit(limit).
+		Find(&mentions).Error
+	return mentions, total, err
+}
+
+// MarkMentionsRead отмечает упоминания пользователя прочитанными; без ids - все
+func (r *ParierRepository) MarkMentionsRead(userID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	query := r.db.Model(&models.TBetCommentMention{}).
+		Where("ck_user = ? AND ct_read IS NULL AND ct_delete IS NULL", userID)
+	if len(ids) > 0 {
+		query = query.Where("ck_id IN ?", ids)
+	}
+	result := query.Updates(map[string]any{
+		"ct_read":   time.Now(),
+		"ct_modify": gorm.Expr("NOW()"),
+		"ck_modify": userID.String(),
+	})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"time"
+
+	"parier-server/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Свойства пользователя с настройками уведомлений
+const (
+	UserEmailProperty         = "USER_EMAIL"
+	UserNotifyEmailProperty   = "USER_NOTIFY_EMAIL"   // cl_bool - отправлять уведомления на почту
+	UserNotifyWebhookProperty = "USER_NOTIFY_WEBHOOK" // cv_text - адрес webhook
+	UserNotifyMutedProperty   = "USER_NOTIFY_MUTED"   // cv_text - JSON массив отключённых типов уведомлений
+)
+
+// notifyProperties - свойства, которые читаются для доставки уведомлений
+var notifyProperties = []string{UserLangProperty, UserEmailProperty, UserNotifyEmailProperty, UserNotifyWebhookProperty, UserNotifyMutedProperty}
+
+type NotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+func (r *NotificationRepository) GetDB() *gorm.DB {
+	return r.db
+}
+
+// CreateNotifications сохраняет уведомления в транзакции события, которое их вызвало
+func (r *NotificationRepository) CreateNotifications(notifications []models.TNotification, tx *gorm.DB) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(&notifications).Error
+}
+
+// GetNotifyProperties возвращает язык, почту и настройки уведомлений пользователей
+func (r *NotificationRepository) GetNotifyProperties(userIDs []uuid.UUID, tx *gorm.DB) (map[uuid.UUID][]models.TUserProperties, error) {
+	result := make(map[uuid.UUID][]models.TUserProperties)
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	if tx == nil {
+		tx = r.db
+	}
+	var props []models.TUserProperties
+	err := tx.Where("ck_user IN ? AND ck_type IN ? AND ct_delete IS NULL", userIDs, notifyProperties).
+		Find(&props).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, prop := range props {
+		result[prop.CkUser] = append(result[prop.CkUser], prop)
+	}
+	return result, nil
+}
+
+// SetNotifyProperty сохраняет настройку уведомлений пользователя; nil удаляет её
+func (r *NotificationRepository) SetNotifyProperty(tx *gorm.DB, userID uuid.UUID, ckType string, text *string, flag *bool) error {
+	query := tx.Model(&models.TUserProperties{}).
+		Where("ck_user = ? AND ck_type = ? AND ct_delete IS NULL", userID, ckType)
+	if text == nil && flag == nil {
+		return query.Updates(map[string]any{"ct_delete": time.Now(), "ck_modify": userID.String()}).Error
+	}
+	result := query.Updates(map[string]any{"cv_text": text, "cl_bool": flag, "ck_modify": userID.String()})
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	return tx.Create(&models.TUserProperties{
+		CkId:   uuid.New(),
+		CkUser: userID,
+		CkType: ckType,
+		CvText: text,
+		ClBool: flag,
+		BaseModel: models.BaseModel{
+			CkCreate: userID.String(),
+			CkModify: userID.String(),
+		},
+	}).Error
+}
+
+// GetNotifications возвращает уведомления пользователя, новые первыми
+func (r *NotificationRepository) GetNotifications(userID uuid.UUID, unread bool, offset, limit int) ([]models.TNotification, int64, error) {
+	query := r.db.Model(&models.TNotification{}).Where("ck_user = ? AND ct_delete IS NULL", userID)
+	if unread {
+		query = query.Where("ct_read IS NULL")
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var notifications []models.TNotification
+	err := query.Preload("Type").
+		Order("ct_create DESC, ck_id DESC").
+		Offset(offset).Limit(limit).
+		Find(&notifications).Error
+	return notifications, total, err
+}
+
+// CountUnread возвращает число непрочитанных уведомлений пользователя
+func (r *NotificationRepository) CountUnread(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.TNotification{}).
+		Where("ck_user = ? AND ct_read IS NULL AND ct_delete IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// MarkRead отмечает уведомления пользователя прочитанными; без ids - все
+func (r *NotificationRepository) MarkRead(userID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	query := r.db.Model(&models.TNotification{}).
+		Where("ck_user = ? AND ct_read IS NULL AND ct_delete IS NULL", userID)
+	if len(ids) > 0 {
+		query = query.Where("ck_id IN ?", ids)
+	}
+	result := query.Updates(map[string]any{
+		"ct_read":   time.Now(),
+		"ck_modify": userID.String(),
+	})
+	return result.RowsAffected, result.Error
+}
+
+// ClaimUndelivered отмечает отправленными и возвращает до limit уведомлений, ещё не
+// отправленных по внешним каналам. Уведомления, которые забрал другой экземпляр, пропускаются
+func (r *NotificationRepository) ClaimUndelivered(limit int) ([]models.TNotification, error) {
+	var notifications []models.TNotification
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("ct_deliver IS NULL AND ct_delete IS NULL").
+			Order("ct_create").
+			Limit(limit).
+			Find(&notifications).Error
+		if err != nil || len(notifications) == 0 {
+			return err
+		}
+		ids := make([]uuid.UUID, 0, len(notifications))
+		for _, notification := range notifications {
+			ids = append(ids, notification.CkId)
+		}
+		return tx.Model(&models.TNotification{}).
+			Where("ck_id IN ?", ids).
+			Update("ct_deliver", time.Now()).Error
+	})
+	if err != nil || len(notifications) == 0 {
+		return nil, err
+	}
+	var types []models.TDNotificationType
+	if err := r.db.Where("ct_delete IS NULL").Find(&types).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[models.NotificationType]*models.TDNotificationType, len(types))
+	for i := range types {
+		byID[types[i].CkId] = &types[i]
+	}
+	for i := range notifications {
+		notifications[i].Type = byID[notifications[i].CkType]
+	}
+	return notifications, nil
+}
+
+// SetDeliverError сохраняет ошибки отправки уведомления
+func (r *NotificationRepository) SetDeliverError(id uuid.UUID, message string) error {
+	return r.db.Model(&models.TNotification{}).
+		Where("ck_id = ?", id).
+		Update("cv_deliver_error", message).Error
+}
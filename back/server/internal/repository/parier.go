@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ParierRepository struct {
@@ -290,17 +291,50 @@ func (r *ParierRepository) GetAllBetComments() ([]models.TBetComment, error) {
 	return betComments, err
 }
 
-func (r *ParierRepository) UpdateBetComment(betComment *models.TBetComment) error {
-	return r.db.Save(betComment).Error
+// LockBetComment блокирует комментарий до конца транзакции; удалённый комментарий тоже возвращается
+func (r *ParierRepository) LockBetComment(tx *gorm.DB, id uuid.UUID) (*models.TBetComment, error) {
+	var betComment models.TBetComment
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("ck_id = ?", id).First(&betComment).Error
+	return &betComment, err
 }
 
-func (r *ParierRepository) DeleteBetComment(id uuid.UUID, userID string) error {
-	return r.db.Model(&models.TBetComment{}).
-		Update("ct_delete", gorm.Expr("NOW()")).
-		Update("ct_modify", gorm.Expr("NOW()")).
-		Update("ck_modify", userID).
+func (r *ParierRepository) UpdateBetComment(betComment *models.TBetComment, tx *gorm.DB) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Save(betComment).Error
+}
+
+// DeleteBetComment помечает комментарий удалённым; ответы на него остаются в дереве
+func (r *ParierRepository) DeleteBetComment(id uuid.UUID, remover models.CommentRemover, userID string, tx *gorm.DB) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&models.TBetComment{}).
 		Where("ck_id = ? AND ct_delete IS NULL", id).
-		Error
+		Updates(map[string]any{
+			"ct_delete": gorm.Expr("NOW()"),
+			"ct_modify": gorm.Expr("NOW()"),
+			"ck_modify": userID,
+			"cr_remove": remover,
+		}).Error
+}
+
+// === T_BET_COMMENT_REVISION ===
+
+func (r *ParierRepository) CreateBetCommentRevision(revision *models.TBetCommentRevision, tx *gorm.DB) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(revision).Error
+}
+
+// GetBetCommentRevisions возвращает предыдущие версии комментария, новые первыми
+func (r *ParierRepository) GetBetCommentRevisions(commentID uuid.UUID) ([]models.TBetCommentRevision, error) {
+	var revisions []models.TBetCommentRevision
+	err := r.db.Where("ck_comment = ? AND ct_delete IS NULL", commentID).
+		Order("ct_create DESC").Find(&revisions).Error
+	return revisions, err
 }
 
 // === T_BET_COMMENT_LIKE ===
@@ -592,32 +626,33 @@ func (r *ParierRepository) DeleteBetVerificationSource(id uuid.UUID, userID stri
 
 // === T_CHAT ===
 
-func (r *ParierRepository) CreateChat(chat *models.TBetRating) error {
+func (r *ParierRepository) CreateChat(chat *models.TChat, tx *gorm.DB) error {
+	if tx != nil {
+		return tx.Create(chat).Error
+	}
 	return r.db.Create(chat).Error
 }
 
-func (r *ParierRepository) GetChatByID(id uuid.UUID) (*models.TBetRating, error) {
-	var chat models.TBetRating
+func (r *ParierRepository) GetChatByID(id uuid.UUID) (*models.TChat, error) {
+	var chat models.TChat
 	err := r.db.Where("ck_id = ? AND ct_delete IS NULL", id).First(&chat).Error
 	return &chat, err
 }
 
-func (r *ParierRepository) GetAllChats() ([]models.TBetRating, error) {
-	var chats []models.TBetRating
+func (r *ParierRepository) GetAllChats() ([]models.TChat, error) {
+	var chats []models.TChat
 	err := r.db.Where("ct_delete IS NULL").Order("ck_id ASC").Find(&chats).Error
 	return chats, err
 }
 
-func (r *ParierRepository) UpdateChat(chat *models.TBetRating) error {
+func (r *ParierRepository) UpdateChat(chat *models.TChat) error {
 	return r.db.Save(chat).Error
 }
 
 func (r *ParierRepository) DeleteChat(id uuid.UUID, userID string) error {
-	return r.db.Model(&models.TBetRating{}).
-		Update("ct_delete", gorm.Expr("NOW()")).
-		Update("ct_modify", gorm.Expr("NOW()")).
-		Update("ck_modify", userID).
+	return r.db.Model(&models.TChat{}).
 		Where("ck_id = ? AND ct_delete IS NULL", id).
+		Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ct_modify": gorm.Expr("NOW()"), "ck_modify": userID}).
 		Error
 }
 
@@ -645,10 +680,8 @@ func (r *ParierRepository) UpdateChatCredentials(chatCredentials *models.TChatCr
 
 func (r *ParierRepository) DeleteChatCredentials(id uuid.UUID, userID string) error {
 	return r.db.Model(&models.TChatCredentials{}).
-		Update("ct_delete", gorm.Expr("NOW()")).
-		Update("ct_modify", gorm.Expr("NOW()")).
-		Update("ck_modify", userID).
 		Where("ck_id = ? AND ct_delete IS NULL", id).
+		Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ct_modify": gorm.Expr("NOW()"), "ck_modify": userID}).
 		Error
 }
 
@@ -676,10 +709,8 @@ func (r *ParierRepository) UpdateChatUser(chatUser *models.TChatUser) error {
 
 func (r *ParierRepository) DeleteChatUser(id uuid.UUID, userID string) error {
 	return r.db.Model(&models.TChatUser{}).
-		Update("ct_delete", gorm.Expr("NOW()")).
-		Update("ct_modify", gorm.Expr("NOW()")).
-		Update("ck_modify", userID).
 		Where("ck_id = ? AND ct_delete IS NULL", id).
+		Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ct_modify": gorm.Expr("NOW()"), "ck_modify": userID}).
 		Error
 }
 
@@ -703,16 +734,17 @@ func (r *ParierRepository) GetAllChatUserBans() ([]models.TChatUserBan, error) {
 
 func (r *ParierRepository) DeleteChatUserBan(id uuid.UUID, userID string) error {
 	return r.db.Model(&models.TChatUserBan{}).
-		Update("ct_delete", gorm.Expr("NOW()")).
-		Update("ct_modify", gorm.Expr("NOW()")).
-		Update("ck_modify", userID).
 		Where("ck_id = ? AND ct_delete IS NULL", id).
+		Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ct_modify": gorm.Expr("NOW()"), "ck_modify": userID}).
 		Error
 }
 
 // === T_CHAT_MESSAGE ===
 
-func (r *ParierRepository) CreateChatMessage(chatMessage *models.TChatMessage) error {
+func (r *ParierRepository) CreateChatMessage(chatMessage *models.TChatMessage, tx *gorm.DB) error {
+	if tx != nil {
+		return tx.Create(chatMessage).Error
+	}
 	return r.db.Create(chatMessage).Error
 }
 
@@ -734,10 +766,8 @@ func (r *ParierRepository) UpdateChatMessage(chatMessage *models.TChatMessage) e
 
 func (r *ParierRepository) DeleteChatMessage(id uuid.UUID, userID string) error {
 	return r.db.Model(&models.TChatMessage{}).
-		Update("ct_delete", gorm.Expr("NOW()")).
-		Update("ct_modify", gorm.Expr("NOW()")).
-		Update("ck_modify", userID).
 		Where("ck_id = ? AND ct_delete IS NULL", id).
+		Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ct_modify": gorm.Expr("NOW()"), "ck_modify": userID}).
 		Error
 }
 
@@ -765,10 +795,8 @@ func (r *ParierRepository) UpdateChatMessageMedia(chatMessageMedia *models.TChat
 
 func (r *ParierRepository) DeleteChatMessageMedia(id uuid.UUID, userID string) error {
 	return r.db.Model(&models.TChatMessageMedia{}).
-		Update("ct_delete", gorm.Expr("NOW()")).
-		Update("ct_modify", gorm.Expr("NOW()")).
-		Update("ck_modify", userID).
 		Where("ck_id = ? AND ct_delete IS NULL", id).
+		Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ct_modify": gorm.Expr("NOW()"), "ck_modify": userID}).
 		Error
 }
 
@@ -796,10 +824,8 @@ func (r *ParierRepository) UpdateChatMessageLike(chatMessageLike *models.TChatMe
 
 func (r *ParierRepository) DeleteChatMessageLike(id uuid.UUID, userID string) error {
 	return r.db.Model(&models.TChatMessageLike{}).
-		Update("ct_delete", gorm.Expr("NOW()")).
-		Update("ct_modify", gorm.Expr("NOW()")).
-		Update("ck_modify", userID).
 		Where("ck_id = ? AND ct_delete IS NULL", id).
+		Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ct_modify": gorm.Expr("NOW()"), "ck_modify": userID}).
 		Error
 }
 
@@ -827,9 +853,7 @@ func (r *ParierRepository) UpdateChatMessageRead(chatMessageRead *models.TChatMe
 
 func (r *ParierRepository) DeleteChatMessageRead(id uuid.UUID, userID string) error {
 	return r.db.Model(&models.TChatMessageRead{}).
-		Update("ct_delete", gorm.Expr("NOW()")).
-		Update("ct_modify", gorm.Expr("NOW()")).
-		Update("ck_modify", userID).
 		Where("ck_id = ? AND ct_delete IS NULL", id).
+		Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ct_modify": gorm.Expr("NOW()"), "ck_modify": userID}).
 		Error
 }
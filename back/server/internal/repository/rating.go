@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"errors"
+
+	"parier-server/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LockUserReputation блокирует репутацию пользователя до конца транзакции и читает её
+// в reputation; если записи ещё нет, сохраняет reputation как начальную.
+// Под этой блокировкой меняются оценки пользователя и его ставок
+func (r *ParierRepository) LockUserReputation(reputation *models.TUserReputation, tx *gorm.DB) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(reputation).Error; err != nil {
+		return err
+	}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("ck_user = ?", reputation.CkUser).First(reputation).Error
+}
+
+// SaveUserReputation сохраняет счётчики и репутацию, заблокированные LockUserReputation
+func (r *ParierRepository) SaveUserReputation(reputation *models.TUserReputation, tx *gorm.DB) error {
+	return tx.Model(&models.TUserReputation{}).
+		Where("ck_user = ?", reputation.CkUser).
+		Updates(map[string]any{
+			"cn_rating_sum":   reputation.CnRatingSum,
+			"cn_rating_count": reputation.CnRatingCount,
+			"cn_bets":         reputation.CnBets,
+			"cn_wins":         reputation.CnWins,
+			"cn_score":        reputation.CnScore,
+			"ck_modify":       reputation.CkModify,
+			"ct_modify":       gorm.Expr("now()"),
+		}).Error
+}
+
+func (r *ParierRepository) GetUserReputation(userID uuid.UUID) (*models.TUserReputation, error) {
+	var reputation models.TUserReputation
+	err := r.db.Where("ck_user = ? AND ct_delete IS NULL", userID).First(&reputation).Error
+	return &reputation, err
+}
+
+// GetUserRating возвращает оценку пользователя userID от authorID или nil, если её нет
+func (r *ParierRepository) GetUserRating(userID, authorID uuid.UUID, tx *gorm.DB) (*models.TUserRating, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var rating models.TUserRating
+	err := tx.Where("ck_user = ? AND ck_author = ? AND ct_delete IS NULL", userID, authorID).First(&rating).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &rating, err
+}
+
+// SetUserRating ставит оценку пользователю или меняет её; снятая ранее оценка возвращается
+func (r *ParierRepository) SetUserRating(rating *models.TUserRating, tx *gorm.DB) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "ck_user"}, {Name: "ck_author"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "cn_rating"}, Value: rating.CnRating},
+			{Column: clause.Column{Name: "ck_modify"}, Value: rating.CkModify},
+			{Column: clause.Column{Name: "ct_modify"}, Value: gorm.Expr("now()")},
+			{Column: clause.Column{Name: "ct_delete"}, Value: nil},
+		},
+	}).Create(rating).Error
+}
+
+func (r *ParierRepository) RemoveUserRating(userID, authorID uuid.UUID, actor string, tx *gorm.DB) error {
+	return tx.Model(&models.TUserRating{}).
+		Where("ck_user = ? AND ck_author = ? AND ct_delete IS NULL", userID, authorID).
+		Updates(map[string]any{"ct_delete": gorm.Expr("now()"), "ck_modify": actor}).Error
+}
+
+// GetBetRating возвращает оценку ставки от пользователя или nil, если её нет
+func (r *ParierRepository) GetBetRating(betID, userID uuid.UUID, tx *gorm.DB) (*models.TBetRating, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var rating models.TBetRating
+	err := tx.Where("ck_bet = ? AND ck_user = ? AND ct_delete IS NULL", betID, userID).First(&rating).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &rating, err
+}
+
+// SetBetRating ставит оценку ставке или меняет её; снятая ранее оценка возвращается
+func (r *ParierRepository) SetBetRating(rating *models.TBetRating, tx *gorm.DB) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "ck_bet"}, {Name: "ck_user"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "cn_rating"}, Value: rating.CnRating},
+			{Column: clause.Column{Name: "ck_modify"}, Value: rating.CkModify},
+			{Column: clause.Column{Name: "ct_modify"}, Value: gorm.Expr("now()")},
+			{Column: clause.Column{Name: "ct_delete"}, Value: nil},
+		},
+	}).Create(rating).Error
+}
+
+func (r *ParierRepository) RemoveBetRating(betID, userID uuid.UUID, actor string, tx *gorm.DB) error {
+	return tx.Model(&models.TBetRating{}).
+		Where("ck_bet = ? AND ck_user = ? AND ct_delete IS NULL", betID, userID).
+		Updates(map[string]any{"ct_delete": gorm.Expr("now()"), "ck_modify": actor}).Error
+}
+
+// GetBetRatingStats возвращает среднюю оценку ставки и количество оценок
+func (r *ParierRepository) GetBetRatingStats(betID uuid.UUID, tx *gorm.DB) (float64, int64, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var stats struct {
+		Average float64
+		Count   int64
+	}
+	err := tx.Model(&models.TBetRating{}).
+		Select("coalesce(avg(cn_rating), 0) AS average, count(*) AS count").
+		Where("ck_bet = ? AND ct_delete IS NULL", betID).
+		Scan(&stats).Error
+	return stats.Average, stats.Count, err
+}
+
+// LockBet блокирует ставку до конца транзакции и читает её
+func (r *ParierRepository) LockBet(betID uuid.UUID, tx *gorm.DB) (*models.TBet, error) {
+	var bet models.TBet
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("ck_id = ? AND ct_delete IS NULL", betID).First(&bet).Error
+	return &bet, err
+}
+
+func (r *ParierRepository) SetBetStatus(betID uuid.UUID, status string, actor string, tx *gorm.DB) error {
+	return tx.Model(&models.TBet{}).
+		Where("ck_id = ? AND ct_delete IS NULL", betID).
+		Updates(map[string]any{"ck_status": status, "ck_modify": actor, "ct_modify": gorm.Expr("now()")}).Error
+}
+
+func (r *ParierRepository) CreateUserBetHistory(history *models.TUserBetHistory, tx *gorm.DB) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(history).Error
+}
@@ -1,10 +1,14 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"parier-server/internal/models"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ReferralRepository struct {
@@ -25,9 +29,12 @@ func (r *ReferralRepository) GetReferralCodeByUserID(userID uuid.UUID) (*models.
 	return &code, err
 }
 
-func (r *ReferralRepository) GetReferralCodeByCode(code string) (*models.TReferralCode, error) {
+func (r *ReferralRepository) GetReferralCodeByCode(code string, tx *gorm.DB) (*models.TReferralCode, error) {
+	if tx == nil {
+		tx = r.db
+	}
 	var rc models.TReferralCode
-	err := r.db.Where("cv_code = ? AND ct_delete IS NULL", code).First(&rc).Error
+	err := tx.Where("cv_code = ? AND ct_delete IS NULL", code).First(&rc).Error
 	return &rc, err
 }
 
@@ -44,8 +51,39 @@ func (r *ReferralRepository) GetReferralsByReferrerID(referrerID uuid.UUID) ([]m
 	return refs, err
 }
 
-func (r *ReferralRepository) CreateReferral(ref *models.TReferral) error {
-	return r.db.Create(ref).Error
+// HasReferrer reports whether the user was already brought by someone
+func (r *ReferralRepository) HasReferrer(userID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.TReferral{}).
+		Where("ck_referred = ? AND ct_delete IS NULL", userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetReferralByReferred returns the referral that brought the user or nil if nobody did
+func (r *ReferralRepository) GetReferralByReferred(userID uuid.UUID, tx *gorm.DB) (*models.TReferral, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var ref models.TReferral
+	err := tx.Where("ck_referred = ? AND ct_delete IS NULL", userID).First(&ref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ref, nil
+}
+
+// CreateReferral links the referred user to the referrer. Reports false if the user
+// already has a referrer
+func (r *ReferralRepository) CreateReferral(ref *models.TReferral, tx *gorm.DB) (bool, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(ref)
+	return result.RowsAffected > 0, result.Error
 }
 
 func (r *ReferralRepository) GetReferralEarningsByReferralID(referralID uuid.UUID) ([]models.TReferralEarning, error) {
@@ -54,6 +92,212 @@ func (r *ReferralRepository) GetReferralEarningsByReferralID(referralID uuid.UUI
 	return earnings, err
 }
 
-func (r *ReferralRepository) CreateReferralEarning(e *models.TReferralEarning) error {
+func (r *ReferralRepository) CreateReferralEarning(e *models.TReferralEarning, tx *gorm.DB) error {
+	if tx != nil {
+		return tx.Create(e).Error
+	}
 	return r.db.Create(e).Error
 }
+
+// CountActiveReferees returns how many users brought by the referrer have a transaction
+// of stakeType since the given time
+func (r *ReferralRepository) CountActiveReferees(tx *gorm.DB, referrerID uuid.UUID, stakeType string, since time.Time) (int64, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var count int64
+	err := tx.Model(&models.TReferral{}).
+		Where("ck_referrer = ? AND ct_delete IS NULL", referrerID).
+		Where("EXISTS (SELECT 1 FROM t_user_transaction t WHERE t.ck_user = t_referral.ck_referred AND t.ck_type = ? AND t.ct_create >= ? AND t.ct_delete IS NULL)", stakeType, since).
+		Count(&count).Error
+	return count, err
+}
+
+// GetDueEarnings returns pending earnings accrued before the given time, oldest first
+func (r *ReferralRepository) GetDueEarnings(before time.Time, limit int) ([]models.TReferralEarning, error) {
+	var earnings []models.TReferralEarning
+	err := r.db.Where("cr_status = ? AND ct_create <= ? AND ct_delete IS NULL", models.ReferralEarningStatusPending, before).
+		Order("ct_create").
+		Limit(limit).
+		Find(&earnings).Error
+	return earnings, err
+}
+
+// LockPendingEarning locks the earning until the end of the transaction. Returns nil if
+// the earning is no longer pending or is being paid by another transaction
+func (r *ReferralRepository) LockPendingEarning(tx *gorm.DB, id uuid.UUID) (*models.TReferralEarning, error) {
+	var earning models.TReferralEarning
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("ck_id = ? AND cr_status = ? AND ct_delete IS NULL", id, models.ReferralEarningStatusPending).
+		Preload("Referral").
+		First(&earning).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &earning, nil
+}
+
+// MarkEarningPaid links the paid earning to the wallet transaction
+func (r *ReferralRepository) MarkEarningPaid(tx *gorm.DB, earning *models.TReferralEarning, transactionID uuid.UUID, by string) error {
+	now := time.Now()
+	earning.CrStatus = models.ReferralEarningStatusPaid
+	earning.CkTransaction = &transactionID
+	earning.CtPaid = &now
+	return tx.Model(&models.TReferralEarning{}).
+		Where("ck_id = ?", earning.CkId).
+		Updates(map[string]any{
+			"cr_status":      earning.CrStatus,
+			"ck_transaction": transactionID,
+			"ct_paid":        now,
+			"ck_modify":      by,
+		}).Error
+}
+
+// SetReferrerEarningsStatus moves all earnings of the referrer from one status to another.
+// Returns the number of changed earnings
+func (r *ReferralRepository) SetReferrerEarningsStatus(tx *gorm.DB, referrerID uuid.UUID, from, to models.ReferralEarningStatus, reason *string, by string) (int64, error) {
+	result := tx.Model(&models.TReferralEarning{}).
+		Where("cr_status = ? AND ct_delete IS NULL", from).
+		Where("ck_referral IN (SELECT ck_id FROM t_referral WHERE ck_referrer = ?)", referrerID).
+		Updates(map[string]any{
+			"cr_status":        to,
+			"cv_freeze_reason": reason,
+			"ck_modify":        by,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// GetReferrerFreeze returns the active freeze of the referrer's earnings, or nil if there is none
+func (r *ReferralRepository) GetReferrerFreeze(tx *gorm.DB, referrerID uuid.UUID) (*models.TReferralFreeze, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var freeze models.TReferralFreeze
+	err := tx.Where("ck_user = ? AND ct_delete IS NULL", referrerID).First(&freeze).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &freeze, nil
+}
+
+// FreezeReferrer puts an active freeze on the referrer's earnings or replaces the reason of the
+// existing one
+func (r *ReferralRepository) FreezeReferrer(tx *gorm.DB, referrerID uuid.UUID, reason string, by string) error {
+	freeze := &models.TReferralFreeze{
+		CkId:      uuid.New(),
+		CkUser:    referrerID,
+		CvReason:  reason,
+		BaseModel: models.BaseModel{CkCreate: by, CkModify: by},
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "ck_user"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "ct_delete IS NULL"}}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"cv_reason": reason,
+			"ck_modify": by,
+			"ct_modify": gorm.Expr("now()"),
+		}),
+	}).Create(freeze).Error
+}
+
+// UnfreezeReferrer lifts the active freeze of the referrer's earnings
+func (r *ReferralRepository) UnfreezeReferrer(tx *gorm.DB, referrerID uuid.UUID, by string) error {
+	return tx.Model(&models.TReferralFreeze{}).
+		Where("ck_user = ? AND ct_delete IS NULL", referrerID).
+		Updates(map[string]any{
+			"ct_delete": time.Now(),
+			"ck_modify": by,
+		}).Error
+}
+
+// ReferrerEarnings - сумма вознаграждений пригласившего по статусам
+type ReferrerEarnings struct {
+	CkReferrer uuid.UUID
+	CnPending  float64
+	CnFrozen   float64
+	CnPaid     float64
+}
+
+// GetUnpaidReferrers returns referrers with pending or frozen earnings, the largest unpaid first
+func (r *ReferralRepository) GetUnpaidReferrers(offsetref, limitref *int) ([]ReferrerEarnings, int64, error) {
+	offset, limit := ValidatePageAndPageSize(offsetref, limitref)
+	unpaid := clause.Expr{SQL: "SUM(e.cn_amount) FILTER (WHERE e.cr_status <> ?)", Vars: []any{models.ReferralEarningStatusPaid}}
+	referrers := func() *gorm.DB {
+		return r.db.Table("t_referral_earning e").
+			Joins("JOIN t_referral r ON r.ck_id = e.ck_referral").
+			Where("e.ct_delete IS NULL").
+			Group("r.ck_referrer").
+			Having("? > 0", unpaid)
+	}
+
+	var total int64
+	if err := r.db.Table("(?) AS u", referrers().Select("r.ck_referrer")).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []ReferrerEarnings
+	err := referrers().
+		Select(`r.ck_referrer,
+			COALESCE(SUM(e.cn_amount) FILTER (WHERE e.cr_status = ?), 0) AS cn_pending,
+			COALESCE(SUM(e.cn_amount) FILTER (WHERE e.cr_status = ?), 0) AS cn_frozen,
+			COALESCE(SUM(e.cn_amount) FILTER (WHERE e.cr_status = ?), 0) AS cn_paid`,
+			models.ReferralEarningStatusPending, models.ReferralEarningStatusFrozen, models.ReferralEarningStatusPaid).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "? DESC", Vars: []any{unpaid}}}).
+		Offset(offset).Limit(limit).
+		Scan(&rows).Error
+	return rows, total, err
+}
+
+// RefereeActivity - данные приглашённого для поиска мошенничества
+type RefereeActivity struct {
+	CkReferral        uuid.UUID
+	CkReferrer        uuid.UUID
+	CkReferred        uuid.UUID
+	CtCreate          time.Time
+	CnStakes          int64
+	ClSharedIp        bool
+	ClSharedUserAgent bool
+}
+
+// GetRefereeActivity returns the users brought by the referrers with the number of their stakes
+// and whether any of their sessions shares an IP or user agent with a session of the referrer
+func (r *ReferralRepository) GetRefereeActivity(referrerIDs []uuid.UUID, stakeType string) ([]RefereeActivity, error) {
+	var rows []RefereeActivity
+	err := r.db.Table("t_referral r").
+		Select(`r.ck_id AS ck_referral, r.ck_referrer, r.ck_referred, r.ct_create,
+			(SELECT COUNT(*) FROM t_user_transaction t
+				WHERE t.ck_user = r.ck_referred AND t.ck_type = ? AND t.ct_delete IS NULL) AS cn_stakes,
+			EXISTS (SELECT 1 FROM t_session a JOIN t_session b ON a.ck_ip = b.ck_ip
+				WHERE a.ck_user = r.ck_referrer AND b.ck_user = r.ck_referred AND a.ck_ip <> '') AS cl_shared_ip,
+			EXISTS (SELECT 1 FROM t_session a JOIN t_session b ON a.ck_user_agent = b.ck_user_agent
+				WHERE a.ck_user = r.ck_referrer AND b.ck_user = r.ck_referred AND a.ck_user_agent <> '') AS cl_shared_user_agent`,
+			stakeType).
+		Where("r.ck_referrer IN ? AND r.ct_delete IS NULL", referrerIDs).
+		Order("r.ct_create").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetReferralCycles returns the users from userIDs whose chain of referrers leads back to them
+// within maxDepth steps
+func (r *ReferralRepository) GetReferralCycles(userIDs []uuid.UUID, maxDepth int) ([]uuid.UUID, error) {
+	var cycled []uuid.UUID
+	err := r.db.Raw(`WITH RECURSIVE chain AS (
+			SELECT r.ck_referred AS ck_start, r.ck_referrer AS ck_user, 1 AS cn_depth
+			FROM t_referral r
+			WHERE r.ck_referred IN ? AND r.ct_delete IS NULL
+			UNION ALL
+			SELECT c.ck_start, r.ck_referrer, c.cn_depth + 1
+			FROM chain c
+			JOIN t_referral r ON r.ck_referred = c.ck_user AND r.ct_delete IS NULL
+			WHERE c.cn_depth < ? AND c.ck_user <> c.ck_start
+		)
+		SELECT DISTINCT ck_start FROM chain WHERE ck_user = ck_start`, userIDs, maxDepth).
+		Scan(&cycled).Error
+	return cycled, err
+}
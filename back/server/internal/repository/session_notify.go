@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// sessionChannel is the Postgres NOTIFY channel for changed or deleted sessions
+const sessionChannel = "parier_session"
+
+// sessionListenRetry is the pause before reconnecting a dropped LISTEN connection
+const sessionListenRetry = 5 * time.Second
+
+// SessionNotifier tells other API instances that a session changed, so they
+// drop their cached copy. Payload is "<instance>:<session id>".
+type SessionNotifier struct {
+	db       *gorm.DB
+	dsn      string
+	instance string
+}
+
+func NewSessionNotifier(db *gorm.DB, dsn string) *SessionNotifier {
+	return &SessionNotifier{
+		db:       db,
+		dsn:      dsn,
+		instance: uuid.NewString(),
+	}
+}
+
+// Publish notifies other instances about a changed session
+func (n *SessionNotifier) Publish(id uuid.UUID) error {
+	return n.db.Exec("SELECT pg_notify(?, ?)", sessionChannel, n.instance+":"+id.String()).Error
+}
+
+// Listen calls handler with IDs of sessions changed by other instances until
+// ctx is cancelled. Notifications sent while the connection was down are lost,
+// so after every (re)connect handler is called with uuid.Nil, meaning "all sessions".
+func (n *SessionNotifier) Listen(ctx context.Context, handler func(id uuid.UUID)) {
+	for ctx.Err() == nil {
+		if err := n.listen(ctx, handler); err != nil && ctx.Err() == nil {
+			log.Printf("Session notification listener failed: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(sessionListenRetry):
+			}
+		}
+	}
+}
+
+func (n *SessionNotifier) listen(ctx context.Context, handler func(id uuid.UUID)) error {
+	conn, err := pgx.Connect(ctx, n.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+sessionChannel); err != nil {
+		return err
+	}
+	handler(uuid.Nil)
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		instance, payload, ok := strings.Cut(notification.Payload, ":")
+		if !ok || instance == n.instance {
+			continue
+		}
+		id, err := uuid.Parse(payload)
+		if err != nil {
+			log.Printf("Invalid session notification %q", notification.Payload)
+			continue
+		}
+		handler(id)
+	}
+}
@@ -2,22 +2,50 @@ package repository
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"parier-server/internal/models"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// PermissionInvalidator сбрасывает закешированные права при изменении ролей и доступов
+type PermissionInvalidator interface {
+	InvalidateUser(userID uuid.UUID)
+	InvalidateAll()
+}
+
 type UserRepository struct {
-	db *gorm.DB
+	db          *gorm.DB
+	permissions PermissionInvalidator
 }
 
 func NewUserRepository(db *gorm.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// SetPermissionInvalidator подключает кеш прав, который сбрасывается при записи ролей и доступов
+func (r *UserRepository) SetPermissionInvalidator(permissions PermissionInvalidator) {
+	r.permissions = permissions
+}
+
+func (r *UserRepository) userRolesChanged(userID uuid.UUID, err error) error {
+	if err == nil && r.permissions != nil {
+		r.permissions.InvalidateUser(userID)
+	}
+	return err
+}
+
+func (r *UserRepository) grantsChanged(err error) error {
+	if err == nil && r.permissions != nil {
+		r.permissions.InvalidateAll()
+	}
+	return err
+}
+
 // === T_USER ===
 
 func (r *UserRepository) CreateUser(user *models.TUser) error {
@@ -147,6 +175,191 @@ func (r *UserRepository) DeleteUser(id uuid.UUID, userID string) error {
 		Update("ck_modify", userID).Error
 }
 
+// KeycloakSyncUser is recorded as creator of rows maintained by the Keycloak sync.
+// Only roles created by it are revoked when Keycloak stops granting them.
+const KeycloakSyncUser = "keycloak"
+
+// KeycloakUserSync is the local state of a Keycloak user
+type KeycloakUserSync struct {
+	ExternalID     string
+	Iss            string
+	Client         string
+	Roles          []string           // candidate role IDs; unknown ones are skipped
+	Properties     map[string]*string // property type -> text, empty values are left as is
+	DefaultBalance float64            // balance of a newly created wallet
+}
+
+// SyncKeycloakUser creates or updates the user with ck_external = ExternalID
+// together with roles, properties and wallet. Reports whether the user was created.
+func (r *UserRepository) SyncKeycloakUser(sync *KeycloakUserSync) (*models.TUser, bool, error) {
+	var user models.TUser
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// serializes concurrent first requests of the same user
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "t_user:"+sync.ExternalID).Error; err != nil {
+			return err
+		}
+		err := tx.Where("ck_external = ? AND ct_delete IS NULL", sync.ExternalID).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			now := time.Now()
+			user = models.TUser{
+				CkId:          uuid.New(),
+				CkExternal:    sync.ExternalID,
+				CtFirstLogin:  &now,
+				CkFirstIss:    &sync.Iss,
+				CkFirstClient: &sync.Client,
+				BaseModel: models.BaseModel{
+					CkCreate: KeycloakSyncUser,
+					CkModify: KeycloakSyncUser,
+				},
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			created = true
+		case err != nil:
+			return err
+		case user.CtFirstLogin == nil:
+			// users created before first logins were recorded
+			user.CtFirstLogin = &user.CtCreate
+			user.CkFirstIss = &sync.Iss
+			user.CkFirstClient = &sync.Client
+			if err := tx.Model(&user).UpdateColumns(map[string]any{
+				"ct_first_login":  user.CtFirstLogin,
+				"ck_first_iss":    user.CkFirstIss,
+				"ck_first_client": user.CkFirstClient,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if err := syncKeycloakRoles(tx, user.CkId, sync.Roles); err != nil {
+			return err
+		}
+		if err := syncKeycloakProperties(tx, user.CkId, sync.Properties); err != nil {
+			return err
+		}
+		var wallets int64
+		if err := tx.Model(&models.TUserWallet{}).Where("ck_user = ? AND ct_delete IS NULL", user.CkId).Count(&wallets).Error; err != nil {
+			return err
+		}
+		if wallets > 0 {
+			return nil
+		}
+		return tx.Create(&models.TUserWallet{
+			CkId:    uuid.New(),
+			CkUser:  user.CkId,
+			CnValue: sync.DefaultBalance,
+			BaseModel: models.BaseModel{
+				CkCreate: KeycloakSyncUser,
+				CkModify: KeycloakSyncUser,
+			},
+		}).Error
+	})
+	if err := r.userRolesChanged(user.CkId, err); err != nil {
+		return nil, false, err
+	}
+	return &user, created, nil
+}
+
+// syncKeycloakRoles grants the known roles among candidates (or the default role
+// when none is known) and ends roles previously granted by the sync that are gone
+func syncKeycloakRoles(tx *gorm.DB, userID uuid.UUID, candidates []string) error {
+	var granted []string
+	if len(candidates) > 0 {
+		if err := tx.Model(&models.TDRole{}).
+			Where("ck_id IN ? AND ct_delete IS NULL", candidates).
+			Pluck("ck_id", &granted).Error; err != nil {
+			return err
+		}
+	}
+	if len(granted) == 0 {
+		var role models.TDRole
+		err := tx.Where("cl_default = true AND ct_delete IS NULL").First(&role).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			granted = []string{role.CkId}
+		}
+	}
+	wanted := make(map[string]bool, len(granted))
+	for _, role := range granted {
+		wanted[role] = true
+	}
+
+	var current []models.TUserRole
+	if err := tx.Where("ck_user = ? AND ct_delete IS NULL AND (ct_end IS NULL OR ct_end > NOW())", userID).
+		Find(&current).Error; err != nil {
+		return err
+	}
+	for _, userRole := range current {
+		if wanted[userRole.CkRole] {
+			delete(wanted, userRole.CkRole)
+			continue
+		}
+		if userRole.CkCreate != KeycloakSyncUser {
+			continue
+		}
+		if err := tx.Model(&models.TUserRole{}).Where("ck_id = ?", userRole.CkId).
+			Updates(map[string]any{"ct_end": gorm.Expr("NOW()"), "ck_modify": KeycloakSyncUser}).Error; err != nil {
+			return err
+		}
+	}
+	for role := range wanted {
+		if err := tx.Create(&models.TUserRole{
+			CkId:    uuid.New(),
+			CkUser:  userID,
+			CkRole:  role,
+			CtStart: time.Now(),
+			BaseModel: models.BaseModel{
+				CkCreate: KeycloakSyncUser,
+				CkModify: KeycloakSyncUser,
+			},
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncKeycloakProperties creates or updates text properties that changed in Keycloak
+func syncKeycloakProperties(tx *gorm.DB, userID uuid.UUID, properties map[string]*string) error {
+	for propertyType, value := range properties {
+		if value == nil || *value == "" {
+			continue
+		}
+		var prop models.TUserProperties
+		err := tx.Where("ck_user = ? AND ck_type = ? AND ct_delete IS NULL", userID, propertyType).First(&prop).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			text := *value
+			if err := tx.Create(&models.TUserProperties{
+				CkId:   uuid.New(),
+				CkUser: userID,
+				CkType: propertyType,
+				CvText: &text,
+				BaseModel: models.BaseModel{
+					CkCreate: KeycloakSyncUser,
+					CkModify: KeycloakSyncUser,
+				},
+			}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if prop.CvText != nil && *prop.CvText == *value {
+			continue
+		}
+		if err := tx.Model(&prop).Updates(map[string]any{"cv_text": *value, "ck_modify": KeycloakSyncUser}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *UserRepository) DeleteSession(id uuid.UUID) error {
 	return r.db.Model(&models.TSession{}).
 		Where("ck_id = ? AND ct_delete IS NULL", id).
@@ -169,7 +382,7 @@ func (r *UserRepository) GetRoleByID(id string) (*models.TDRole, error) {
 }
 
 func (r *UserRepository) CreateUserRole(userRole *models.TUserRole) error {
-	return r.db.Create(userRole).Error
+	return r.userRolesChanged(userRole.CkUser, r.db.Create(userRole).Error)
 }
 
 func (r *UserRepository) CreateUserRoleNotExists(userRole *models.TUserRole) error {
@@ -183,12 +396,12 @@ func (r *UserRepository) CreateUserRoleNotExists(userRole *models.TUserRole) err
 	if count > 0 {
 		return nil
 	}
-	return r.db.Create(userRole).Error
+	return r.userRolesChanged(userRole.CkUser, r.db.Create(userRole).Error)
 }
 
 func (r *UserRepository) GetUserRoleByID(id uuid.UUID) (roles []string, err error) {
 	var userRoles []models.TUserRole
-	err = r.db.Where("ck_user = ? AND ct_delete IS NULL AND (ct_end IS NULL OR ct_end > NOW())", id).
+	err = r.db.Where("ck_user = ? AND ct_delete IS NULL AND ct_start <= NOW() AND (ct_end IS NULL OR ct_end > NOW())", id).
 		Find(&userRoles).Error
 	if err != nil {
 		return nil, err
@@ -258,33 +471,172 @@ func (r *UserRepository) UpdateRole(role *models.TDRole) error {
 	return r.db.Save(role).Error
 }
 
+// DeleteRole deletes the role together with its grants and ends its assignments
 func (r *UserRepository) DeleteRole(id string, userID string) error {
-	return r.db.Model(&models.TDRole{}).
-		Where("ck_id = ? AND ct_delete IS NULL", id).
-		Update("ct_delete", gorm.Expr("NOW()")).
-		Update("ck_modify", userID).Error
+	deleted := map[string]any{"ct_delete": gorm.Expr("NOW()"), "ck_modify": userID}
+	return r.grantsChanged(r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.TDRole{}).
+			Where("ck_id = ? AND ct_delete IS NULL", id).
+			Updates(deleted).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TDTableRole{}).
+			Where("ck_role = ? AND ct_delete IS NULL", id).
+			Updates(deleted).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TPropertiesRole{}).
+			Where("ck_role = ? AND ct_delete IS NULL", id).
+			Updates(deleted).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.TUserRole{}).
+			Where("ck_role = ? AND ct_delete IS NULL AND (ct_end IS NULL OR ct_end > NOW())", id).
+			Updates(map[string]any{"ct_end": gorm.Expr("NOW()"), "ck_modify": userID}).Error
+	}))
+}
+
+// GetRolesWithGrants returns all roles with their table and property grants
+func (r *UserRepository) GetRolesWithGrants() ([]models.TDRole, error) {
+	var roles []models.TDRole
+	err := r.db.Where("ct_delete IS NULL").
+		Preload("NameLocalization", "ct_delete IS NULL").
+		Preload("DescriptionLocalization", "ct_delete IS NULL").
+		Preload("TableRoles", func(db *gorm.DB) *gorm.DB {
+			return db.Where("ct_delete IS NULL").Order("ck_table, cr_action")
+		}).
+		Preload("PropertiesRoles", func(db *gorm.DB) *gorm.DB {
+			return db.Where("ct_delete IS NULL").Order("ck_type, cr_action")
+		}).
+		Order("cr_place, ck_id").
+		Find(&roles).Error
+	return roles, err
+}
+
+// === T_D_TABLE_ROLE ===
+
+func (r *UserRepository) CreateTableRole(grant *models.TDTableRole) error {
+	return r.grantsChanged(r.db.Create(grant).Error)
+}
+
+// GetTableRolesByRoles returns table grants of the given roles
+func (r *UserRepository) GetTableRolesByRoles(roles []string) ([]models.TDTableRole, error) {
+	var grants []models.TDTableRole
+	if len(roles) == 0 {
+		return grants, nil
+	}
+	err := r.db.Where("ck_role IN ? AND ct_delete IS NULL", roles).
+		Order("ck_table, cr_action, ck_role").
+		Find(&grants).Error
+	return grants, err
+}
+
+// HasTableRole reports whether the role already has exactly this grant
+func (r *UserRepository) HasTableRole(roleID string, tableName string, action models.ActionType) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.TDTableRole{}).
+		Where("ck_role = ? AND ck_table = ? AND cr_action = ? AND ct_delete IS NULL", roleID, tableName, action).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteTableRole revokes a table grant of the role and returns it, nil if there was none
+func (r *UserRepository) DeleteTableRole(roleID string, id uuid.UUID, userID string) (*models.TDTableRole, error) {
+	var grant models.TDTableRole
+	result := r.db.Model(&grant).Clauses(clause.Returning{}).
+		Where("ck_id = ? AND ck_role = ? AND ct_delete IS NULL", id, roleID).
+		Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ck_modify": userID})
+	if err := r.grantsChanged(result.Error); err != nil || result.RowsAffected == 0 {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// === T_PROPERTIES_ROLE ===
+
+func (r *UserRepository) CreatePropertiesRole(grant *models.TPropertiesRole) error {
+	return r.grantsChanged(r.db.Create(grant).Error)
+}
+
+// GetPropertiesRolesByRoles returns property grants of the given roles
+func (r *UserRepository) GetPropertiesRolesByRoles(roles []string) ([]models.TPropertiesRole, error) {
+	var grants []models.TPropertiesRole
+	if len(roles) == 0 {
+		return grants, nil
+	}
+	err := r.db.Where("ck_role IN ? AND ct_delete IS NULL", roles).
+		Order("ck_type, cr_action, ck_role").
+		Find(&grants).Error
+	return grants, err
+}
+
+// HasPropertiesRole reports whether the role already has exactly this grant
+func (r *UserRepository) HasPropertiesRole(roleID string, propertyTypeID string, action models.ActionType) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.TPropertiesRole{}).
+		Where("ck_role = ? AND ck_type = ? AND cr_action = ? AND ct_delete IS NULL", roleID, propertyTypeID, action).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// DeletePropertiesRole revokes a property grant of the role and returns it, nil if there was none
+func (r *UserRepository) DeletePropertiesRole(roleID string, id uuid.UUID, userID string) (*models.TPropertiesRole, error) {
+	var grant models.TPropertiesRole
+	result := r.db.Model(&grant).Clauses(clause.Returning{}).
+		Where("ck_id = ? AND ck_role = ? AND ct_delete IS NULL", id, roleID).
+		Updates(map[string]any{"ct_delete": gorm.Expr("NOW()"), "ck_modify": userID})
+	if err := r.grantsChanged(result.Error); err != nil || result.RowsAffected == 0 {
+		return nil, err
+	}
+	return &grant, nil
 }
 
 // === T_SESSION ===
 
+// sessionData is the cv_data layout: the user fields with the tokens next to them,
+// so rows written before tokens were stored still decode
+type sessionData struct {
+	models.User
+	Tokens *models.SessionTokens `json:"tokens,omitempty"`
+}
+
+func marshalSessionData(session *models.TSession) (string, error) {
+	data, err := json.Marshal(sessionData{User: session.User, Tokens: session.Tokens})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal user: %w", err)
+	}
+	return string(data), nil
+}
+
+func unmarshalSessionData(session *models.TSession) error {
+	var data sessionData
+	if err := json.Unmarshal([]byte(session.CvData), &data); err != nil {
+		return fmt.Errorf("failed to unmarshal user: %w", err)
+	}
+	session.User = data.User
+	session.Tokens = data.Tokens
+	return nil
+}
+
 func (r *UserRepository) CreateSession(session *models.TSession) error {
-	data, err := json.Marshal(session.User)
+	data, err := marshalSessionData(session)
 	if err != nil {
-		return fmt.Errorf("failed to marshal user: %w", err)
+		return err
 	}
-	session.CvData = string(data)
+	session.CvData = data
 	return r.db.Create(session).Error
 }
 
+// GetSessionByID returns a session that is neither deleted nor expired
 func (r *UserRepository) GetSessionByID(id uuid.UUID) (*models.TSession, error) {
 	var session models.TSession
-	err := r.db.Where("ck_id = ? AND ct_delete IS NULL", id).
+	err := r.db.Where("ck_id = ? AND ct_delete IS NULL AND ct_expire > NOW()", id).
 		First(&session).Error
 	if err != nil {
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
-	if err = json.Unmarshal([]byte(session.CvData), &session.User); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal user: %w", err)
+	if err = unmarshalSessionData(&session); err != nil {
+		return nil, err
 	}
 	session.Saved = true
 	return &session, err
@@ -297,23 +649,48 @@ func (r *UserRepository) GetSessionByUserID(userID uuid.UUID) (*models.TSession,
 	if err != nil {
 		return nil, err
 	}
-	if err = json.Unmarshal([]byte(session.CvData), &session.User); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal user: %w", err)
+	if err = unmarshalSessionData(&session); err != nil {
+		return nil, err
 	}
 	session.Saved = true
 	return &session, err
 }
 
 func (r *UserRepository) UpdateSession(data *models.TSession) error {
-	dataJSON, err := json.Marshal(data.User)
+	dataJSON, err := marshalSessionData(data)
 	if err != nil {
-		return fmt.Errorf("failed to marshal user: %w", err)
+		return err
 	}
-	data.CvData = string(dataJSON)
+	data.CvData = dataJSON
 	data.Saved = true
 	return r.db.Save(data).Error
 }
 
+// GetActiveSessionsByUserID returns unexpired sessions of the user, most recently active first
+func (r *UserRepository) GetActiveSessionsByUserID(userID uuid.UUID) ([]models.TSession, error) {
+	var sessions []models.TSession
+	err := r.db.Where("ck_user = ? AND ct_delete IS NULL AND ct_expire > NOW()", userID).
+		Order("coalesce(ct_activity, ct_create) DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	for i := range sessions {
+		if err := unmarshalSessionData(&sessions[i]); err != nil {
+			return nil, err
+		}
+		sessions[i].Saved = true
+	}
+	return sessions, nil
+}
+
+// TouchSession records the last activity of a session without bumping ct_modify
+func (r *UserRepository) TouchSession(id uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.TSession{}).
+		Where("ck_id = ? AND ct_delete IS NULL", id).
+		UpdateColumn("ct_activity", at).Error
+}
+
 // === T_USER_ROLE ===
 
 func (r *UserRepository) AssignRole(userID uuid.UUID, roleID string, startTime *time.Time, endTime *time.Time, assignedBy string) error {
@@ -332,14 +709,26 @@ func (r *UserRepository) AssignRole(userID uuid.UUID, roleID string, startTime *
 		userRole.CtStart = *startTime
 	}
 
-	return r.db.Create(userRole).Error
+	return r.userRolesChanged(userID, r.db.Create(userRole).Error)
 }
 
 func (r *UserRepository) RevokeRole(userID uuid.UUID, roleID string, revokedBy string) error {
-	return r.db.Model(&models.TUserRole{}).
+	return r.userRolesChanged(userID, r.db.Model(&models.TUserRole{}).
 		Where("ck_user = ? AND ck_role = ? AND ct_delete IS NULL", userID, roleID).
 		Update("ct_end", gorm.Expr("NOW()")).
-		Update("ck_modify", revokedBy).Error
+		Update("ck_modify", revokedBy).Error)
+}
+
+// EndUserRole ends a single role assignment of the user and returns it, nil if it was not in effect
+func (r *UserRepository) EndUserRole(userID uuid.UUID, id uuid.UUID, revokedBy string) (*models.TUserRole, error) {
+	var userRole models.TUserRole
+	result := r.db.Model(&userRole).Clauses(clause.Returning{}).
+		Where("ck_id = ? AND ck_user = ? AND ct_delete IS NULL AND (ct_end IS NULL OR ct_end > NOW())", id, userID).
+		Updates(map[string]any{"ct_end": gorm.Expr("GREATEST(ct_start, NOW())"), "ck_modify": revokedBy})
+	if err := r.userRolesChanged(userID, result.Error); err != nil || result.RowsAffected == 0 {
+		return nil, err
+	}
+	return &userRole, nil
 }
 
 func (r *UserRepository) GetUserRoles(userID uuid.UUID) ([]models.TUserRole, error) {
@@ -354,7 +743,7 @@ func (r *UserRepository) GetUserRoles(userID uuid.UUID) ([]models.TUserRole, err
 
 func (r *UserRepository) GetActiveUserRoles(userID uuid.UUID) ([]models.TUserRole, error) {
 	var userRoles []models.TUserRole
-	err := r.db.Where("ck_user = ? AND ct_delete IS NULL AND (ct_end IS NULL OR ct_end > NOW())", userID).
+	err := r.db.Where("ck_user = ? AND ct_delete IS NULL AND ct_start <= NOW() AND (ct_end IS NULL OR ct_end > NOW())", userID).
 		Preload("Role", "ct_delete IS NULL").
 		Preload("Role.NameLocalization", "ct_delete IS NULL").
 		Order("ct_create DESC").
@@ -365,7 +754,7 @@ func (r *UserRepository) GetActiveUserRoles(userID uuid.UUID) ([]models.TUserRol
 func (r *UserRepository) HasRole(userID uuid.UUID, roleID string) (bool, error) {
 	var count int64
 	err := r.db.Model(&models.TUserRole{}).
-		Where("ck_user = ? AND ck_role = ? AND ct_delete IS NULL AND (ct_end IS NULL OR ct_end > NOW())", userID, roleID).
+		Where("ck_user = ? AND ck_role = ? AND ct_delete IS NULL AND ct_start <= NOW() AND (ct_end IS NULL OR ct_end > NOW())", userID, roleID).
 		Count(&count).Error
 	return count > 0, err
 }
@@ -373,7 +762,7 @@ func (r *UserRepository) HasRole(userID uuid.UUID, roleID string) (bool, error)
 func (r *UserRepository) HasAnyRole(userID uuid.UUID, roleIDs []string) (bool, error) {
 	var count int64
 	err := r.db.Model(&models.TUserRole{}).
-		Where("ck_user = ? AND ck_role IN ? AND ct_delete IS NULL AND (ct_end IS NULL OR ct_end > NOW())", userID, roleIDs).
+		Where("ck_user = ? AND ck_role IN ? AND ct_delete IS NULL AND ct_start <= NOW() AND (ct_end IS NULL OR ct_end > NOW())", userID, roleIDs).
 		Count(&count).Error
 	return count > 0, err
 }
@@ -430,6 +819,26 @@ func (r *UserRepository) GetUserWalletByUserID(userID uuid.UUID) (*models.TUserW
 	return &wallet, err
 }
 
+// LockUserWallet returns the user's wallet locked until the end of the transaction,
+// creating an empty one if the user has none
+func (r *UserRepository) LockUserWallet(tx *gorm.DB, userID uuid.UUID, by string) (*models.TUserWallet, error) {
+	var wallet models.TUserWallet
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("ck_user = ? AND ct_delete IS NULL", userID).First(&wallet).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return &wallet, err
+	}
+	wallet = models.TUserWallet{
+		CkId:   uuid.New(),
+		CkUser: userID,
+		BaseModel: models.BaseModel{
+			CkCreate: by,
+			CkModify: by,
+		},
+	}
+	return &wallet, tx.Create(&wallet).Error
+}
+
 func (r *UserRepository) UpdateUserWallet(wallet *models.TUserWallet) error {
 	return r.db.Save(wallet).Error
 }
@@ -562,7 +971,7 @@ func (r *UserRepository) HasTablePermission(userID uuid.UUID, tableName string,
 	var count int64
 	err := r.db.Table("t_user_role ur").
 		Joins("JOIN t_d_table_role tr ON ur.ck_role = tr.ck_role").
-		Where("ur.ck_user = ? AND tr.ck_table = ? AND (tr.cr_action = ? OR tr.cr_action = ?) AND ur.ct_delete IS NULL AND tr.ct_delete IS NULL AND (ur.ct_end IS NULL OR ur.ct_end > NOW())",
+		Where("ur.ck_user = ? AND tr.ck_table = ? AND (tr.cr_action = ? OR tr.cr_action = ?) AND ur.ct_delete IS NULL AND tr.ct_delete IS NULL AND ur.ct_start <= NOW() AND (ur.ct_end IS NULL OR ur.ct_end > NOW())",
 			userID, tableName, action, models.ActionTypeAll).
 		Count(&count).Error
 	return count > 0, err
@@ -572,12 +981,33 @@ func (r *UserRepository) HasPropertyPermission(userID uuid.UUID, propertyTypeID
 	var count int64
 	err := r.db.Table("t_user_role ur").
 		Joins("JOIN t_properties_role pr ON ur.ck_role = pr.ck_role").
-		Where("ur.ck_user = ? AND pr.ck_type = ? AND (pr.cr_action = ? OR pr.cr_action = ?) AND ur.ct_delete IS NULL AND pr.ct_delete IS NULL AND (ur.ct_end IS NULL OR ur.ct_end > NOW())",
+		Where("ur.ck_user = ? AND pr.ck_type = ? AND (pr.cr_action = ? OR pr.cr_action = ?) AND ur.ct_delete IS NULL AND pr.ct_delete IS NULL AND ur.ct_start <= NOW() AND (ur.ct_end IS NULL OR ur.ct_end > NOW())",
 			userID, propertyTypeID, action, models.ActionTypeAll).
 		Count(&count).Error
 	return count > 0, err
 }
 
+// UserExists reports whether the user exists and is not deleted
+func (r *UserRepository) UserExists(id uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.TUser{}).Where("ck_id = ? AND ct_delete IS NULL", id).Count(&count).Error
+	return count > 0, err
+}
+
+// TableExists reports whether a table with this name exists in the search path
+func (r *UserRepository) TableExists(tableName string) (bool, error) {
+	var exists bool
+	err := r.db.Raw("SELECT to_regclass(?) IS NOT NULL", tableName).Scan(&exists).Error
+	return exists, err
+}
+
+// PropertyTypeExists reports whether the property type exists and is not deleted
+func (r *UserRepository) PropertyTypeExists(id string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.TDPropertiesType{}).Where("ck_id = ? AND ct_delete IS NULL", id).Count(&count).Error
+	return count > 0, err
+}
+
 // === HELPER FUNCTIONS ===
 
 func getPropertyValue(prop models.TUserProperties) interface{} {
@@ -4,6 +4,7 @@ import (
 	"parier-server/internal/config"
 	"parier-server/internal/handlers"
 	"parier-server/internal/middleware"
+	"parier-server/internal/models"
 	"parier-server/internal/service"
 
 	doc "parier-server/docs"
@@ -44,33 +45,51 @@ func SetupRoutes(cfg *config.Config, db *gorm.DB) *gin.Engine {
 	}
 	defaultLang, _ := services.Localization.GetDefaultLanguage()
 	router.Use(middleware.LanguageMiddleware(defaultLang.CkId))
+	router.Use(middleware.PermissionsMiddleware(services.Permissions))
 
 	// API version 1 group
 	v1 := router.Group("/api/v1")
 
 	// Initialize handlers
-	authHandler := handlers.NewKeycloakAuthHandler(services.Keycloak, cfg)
+	authHandler := handlers.NewKeycloakAuthHandler(services.Keycloak, services.Activity, cfg)
 	mediaHandler := handlers.NewMediaHandler(services.Media, cfg)
-	coreHandler := handlers.NewCoreHandler(services.Core, cfg)
+	coreHandler := handlers.NewCoreHandler(services.Core, services.Localization, cfg)
 	parierHandler := handlers.NewParierHandler(services.Parier)
+	chatHandler := handlers.NewChatHandler(services.Chat)
 	adminHandler := handlers.NewAdminHandler(services.Admin)
 	walletHandler := handlers.NewWalletHandler(services.Wallet)
 	referralHandler := handlers.NewReferralHandler(services.Referral)
+	activityHandler := handlers.NewActivityHandler(services.Activity)
+	apiTokenHandler := handlers.NewAPITokenHandler(services.APIToken)
+	rbacHandler := handlers.NewRBACHandler(services.RBAC)
+	auditHandler := handlers.NewAuditHandler(services.Audit)
+	notificationHandler := handlers.NewNotificationHandler(services.Notification)
+	eventHandler := handlers.NewEventHandler(services.Events, cfg)
+	localizationHandler := handlers.NewLocalizationHandler(services.Localization, services.Translate)
+
+	// Signed blob URLs (fs and memory storage backends)
+	mediaHandler.RegisterBlobRoutes(v1)
+
 	// Authentication routes (public)
 	public := v1.Group("")
-	public.Use(middleware.KeycloakAuthMiddleware(cfg, services.Keycloak, false))
+	public.Use(middleware.KeycloakAuthMiddleware(cfg, services.Keycloak, services.APIToken, false))
 	{
 		// Login via code
-		public.PUT("/auth/login-code", authHandler.LoginCode)
+		middleware.Declare(public).PUT("/auth/login-code", middleware.PublicAccess(), authHandler.LoginCode)
 	}
 
 	// Authentication routes (protected)
 	protected := v1.Group("")
-	protected.Use(middleware.KeycloakAuthMiddleware(cfg, services.Keycloak, true))
+	protected.Use(middleware.KeycloakAuthMiddleware(cfg, services.Keycloak, services.APIToken, true))
 	{
 		// Auth endpoints
-		protected.POST("/auth/profile", authHandler.GetProfile)
-		protected.PUT("/auth/logout", authHandler.Logout)
+		auth := middleware.Declare(protected)
+		auth.POST("/auth/profile", middleware.SessionAccess(), authHandler.GetProfile)
+		auth.PUT("/auth/logout", middleware.SessionAccess(), authHandler.Logout)
+		auth.GET("/auth/sessions", middleware.SessionAccess(), authHandler.GetSessions)
+		auth.DELETE("/auth/sessions/:id", middleware.SessionAccess(), authHandler.DeleteSession)
+		auth.POST("/auth/sessions/logout-others", middleware.SessionAccess(), authHandler.LogoutOthers)
+		auth.DELETE("/admin/users/:id/sessions", middleware.TableAccess("t_session", models.ActionTypeDelete), authHandler.ForceLogoutUser)
 
 		// Media
 		mediaHandler.RegisterRoutes(protected)
@@ -81,6 +100,9 @@ func SetupRoutes(cfg *config.Config, db *gorm.DB) *gin.Engine {
 		// Parier endpoints
 		parierHandler.RegisterRoutes(protected)
 
+		// Bet chat rooms and direct messages
+		chatHandler.RegisterRoutes(protected)
+
 		// Admin endpoints
 		adminHandler.RegisterRoutes(protected)
 
@@ -90,12 +112,38 @@ func SetupRoutes(cfg *config.Config, db *gorm.DB) *gin.Engine {
 		// Referral endpoints
 		referralHandler.RegisterRoutes(protected)
 
+		// Visitor activity endpoints (anonymous visitors included)
+		activityHandler.RegisterRoutes(protected)
+
+		// API tokens and service accounts
+		apiTokenHandler.RegisterRoutes(protected)
+
+		// Roles, grants and role assignments
+		rbacHandler.RegisterRoutes(protected)
+
+		// Audit log
+		auditHandler.RegisterRoutes(protected)
+
+		// Notifications of the current user
+		notificationHandler.RegisterRoutes(protected)
+
+		// Real-time event stream (SSE and WebSocket)
+		eventHandler.RegisterRoutes(protected)
+
+		// Translation management endpoints
+		localizationHandler.RegisterRoutes(protected)
+
 		// MCP endpoints
 		if cfg.MCP.Enabled {
 			SetupMCPRoutes(cfg, db, services)
 		}
 	}
 
+	// Every API route must declare its permission
+	if err := middleware.CheckRoutePermissions(router.Routes(), v1.BasePath()); err != nil {
+		panic("Route permission self-check failed: " + err.Error())
+	}
+
 	// Swagger documentation
 	setupSwagger(router, cfg)
 
@@ -0,0 +1,409 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxAnonymousViews - сколько последних просмотров хранится у анонимного посетителя
+	maxAnonymousViews = 100
+	// maxAnonymousDrafts - сколько черновиков может сохранить анонимный посетитель
+	maxAnonymousDrafts = 20
+	// recentViewsLimit - сколько просмотров возвращает GetActivity
+	recentViewsLimit = 100
+)
+
+// Visitor - автор действия: зарегистрированный пользователь или анонимный посетитель
+type Visitor struct {
+	ID        uuid.UUID
+	Anonymous bool
+}
+
+// VisitorFromSession определяет посетителя по сессии; у анонимной сессии нет ck_user
+func VisitorFromSession(session *models.TSession) Visitor {
+	if session.CkUser == nil {
+		return Visitor{ID: session.User.ID, Anonymous: true}
+	}
+	return Visitor{ID: *session.CkUser}
+}
+
+// ActivityService хранит просмотры, черновики ставок, язык и реферальный код посетителя.
+// Пока посетитель анонимен, всё хранится в t_anonymous_state и переносится пользователю при входе.
+type ActivityService struct {
+	repo         *repository.ActivityRepository
+	userRepo     *repository.UserRepository
+	referralRepo *repository.ReferralRepository
+	referrals    *ReferralService
+	locRepo      *repository.LocalizationRepository
+	duration     time.Duration
+	locks        [64]sync.Mutex // сериализуют изменения состояния посетителя, выбираются по id
+}
+
+func NewActivityService(repo *repository.ActivityRepository, userRepo *repository.UserRepository, referralRepo *repository.ReferralRepository, referrals *ReferralService, locRepo *repository.LocalizationRepository, duration time.Duration) *ActivityService {
+	return &ActivityService{
+		repo:         repo,
+		userRepo:     userRepo,
+		referralRepo: referralRepo,
+		referrals:    referrals,
+		locRepo:      locRepo,
+		duration:     duration,
+	}
+}
+
+// GetActivity возвращает просмотры, черновики, язык и отложенный реферальный код посетителя
+func (s *ActivityService) GetActivity(visitor Visitor) (*models.AnonymousState, error) {
+	if visitor.Anonymous {
+		state, err := s.getAnonymousState(visitor.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &state.State, nil
+	}
+	views, err := s.repo.GetRecentBetViews(visitor.ID, recentViewsLimit)
+	if err != nil {
+		return nil, databaseError("Failed to get bet views", err)
+	}
+	drafts, err := s.repo.GetBetDrafts(visitor.ID)
+	if err != nil {
+		return nil, databaseError("Failed to get bet drafts", err)
+	}
+	lang, err := s.repo.GetUserLang(visitor.ID)
+	if err != nil {
+		return nil, databaseError("Failed to get language", err)
+	}
+	return &models.AnonymousState{ViewedBets: views, Drafts: drafts, Lang: lang}, nil
+}
+
+// RecordBetView отмечает просмотр ставки
+func (s *ActivityService) RecordBetView(visitor Visitor, betID uuid.UUID) error {
+	view := models.ViewedBet{BetID: betID, ViewedAt: time.Now()}
+	if !visitor.Anonymous {
+		if err := s.repo.SaveBetView(visitor.ID, view); err != nil {
+			return databaseError("Failed to save bet view", err)
+		}
+		return nil
+	}
+	return s.updateAnonymousState(visitor.ID, func(state *models.AnonymousState) error {
+		state.ViewedBets = addViewedBet(state.ViewedBets, view, maxAnonymousViews)
+		return nil
+	})
+}
+
+// SetLang сохраняет язык интерфейса посетителя
+func (s *ActivityService) SetLang(visitor Visitor, lang string) error {
+	lang = strings.ToUpper(strings.TrimSpace(lang))
+	if _, err := s.locRepo.GetLanguageByID(lang); err != nil {
+		return &ServiceError{
+			Code:    "VALIDATION_ERROR",
+			Message: "Unknown language",
+			Cause:   err,
+		}
+	}
+	if !visitor.Anonymous {
+		if err := s.repo.SetUserLang(visitor.ID, lang); err != nil {
+			return databaseError("Failed to save language", err)
+		}
+		return nil
+	}
+	return s.updateAnonymousState(visitor.ID, func(state *models.AnonymousState) error {
+		state.Lang = &lang
+		return nil
+	})
+}
+
+// SetReferralCode запоминает реферальный код, по которому пришёл анонимный посетитель.
+// Код применяется при регистрации, поэтому зарегистрированному пользователю он недоступен.
+func (s *ActivityService) SetReferralCode(visitor Visitor, code string) error {
+	if !visitor.Anonymous {
+		return &ServiceError{
+			Code:    "VALIDATION_ERROR",
+			Message: "Referral code can only be applied before registration",
+		}
+	}
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, err := s.referralRepo.GetReferralCodeByCode(code, nil); err != nil {
+		return &ServiceError{
+			Code:    "NOT_FOUND",
+			Message: "Referral code not found",
+			Cause:   err,
+		}
+	}
+	return s.updateAnonymousState(visitor.ID, func(state *models.AnonymousState) error {
+		state.ReferralCode = &code
+		return nil
+	})
+}
+
+// SaveDraft создаёт или обновляет черновик ставки; при нулевом id создаётся новый черновик
+func (s *ActivityService) SaveDraft(visitor Visitor, id uuid.UUID, data json.RawMessage) (*models.BetDraft, error) {
+	if !json.Valid(data) {
+		return nil, &ServiceError{
+			Code:    "VALIDATION_ERROR",
+			Message: "Draft data must be valid JSON",
+		}
+	}
+	if id == uuid.Nil {
+		id = uuid.New()
+	}
+	draft := models.BetDraft{ID: id, Data: data, UpdatedAt: time.Now()}
+	if !visitor.Anonymous {
+		if err := s.repo.SaveBetDraft(visitor.ID, draft); err != nil {
+			return nil, databaseError("Failed to save bet draft", err)
+		}
+		return &draft, nil
+	}
+	err := s.updateAnonymousState(visitor.ID, func(state *models.AnonymousState) error {
+		drafts, ok := putDraft(state.Drafts, draft, maxAnonymousDrafts)
+		if !ok {
+			return &ServiceError{
+				Code:    "VALIDATION_ERROR",
+				Message: "Too many drafts",
+			}
+		}
+		state.Drafts = drafts
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// DeleteDraft удаляет черновик ставки
+func (s *ActivityService) DeleteDraft(visitor Visitor, id uuid.UUID) error {
+	notFound := &ServiceError{
+		Code:    "NOT_FOUND",
+		Message: "Draft not found",
+	}
+	if !visitor.Anonymous {
+		deleted, err := s.repo.DeleteBetDraft(visitor.ID, id)
+		if err != nil {
+			return databaseError("Failed to delete bet draft", err)
+		}
+		if !deleted {
+			return notFound
+		}
+		return nil
+	}
+	return s.updateAnonymousState(visitor.ID, func(state *models.AnonymousState) error {
+		for i := range state.Drafts {
+			if state.Drafts[i].ID == id {
+				state.Drafts = append(state.Drafts[:i], state.Drafts[i+1:]...)
+				return nil
+			}
+		}
+		return notFound
+	})
+}
+
+// MergeAnonymous переносит активность анонимного посетителя пользователю после входа.
+// Реферальный код применяется в той же транзакции вместе с бонусом за регистрацию;
+// если применить его не удалось, код отбрасывается, а остальное переносится.
+// Ошибки только логируются: вход не должен срываться из-за переноса истории.
+func (s *ActivityService) MergeAnonymous(anonymousID uuid.UUID, userID uuid.UUID, actor AuditActor) {
+	lock := s.lock(anonymousID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	state, err := s.repo.GetAnonymousState(anonymousID)
+	if err != nil || state == nil {
+		if err != nil {
+			log.Printf("Failed to get anonymous state %s: %v", anonymousID, err)
+		}
+		return
+	}
+	user, err := s.loadUserActivity(userID, &state.State)
+	if err != nil {
+		log.Printf("Failed to load activity of user %s: %v", userID, err)
+		return
+	}
+	merge := mergeAnonymousState(state, user)
+	attribute := func(tx *gorm.DB, userID uuid.UUID, code string) error {
+		return s.referrals.Attribute(tx, userID, code, actor)
+	}
+	if err := s.repo.ApplyAnonymousMerge(anonymousID, userID, merge, attribute); err != nil {
+		log.Printf("Failed to merge anonymous state %s into user %s: %v", anonymousID, userID, err)
+	}
+}
+
+// userActivity - то, что уже есть у пользователя и влияет на перенос анонимного состояния
+type userActivity struct {
+	ViewedBets   map[uuid.UUID]time.Time // время просмотра ставок из анонимного состояния
+	Drafts       map[uuid.UUID]time.Time // время изменения черновиков пользователя
+	Lang         *string
+	Referred     bool
+	ReferralCode string // собственный код пользователя
+	FirstLogin   *time.Time
+}
+
+func (s *ActivityService) loadUserActivity(userID uuid.UUID, state *models.AnonymousState) (*userActivity, error) {
+	betIDs := make([]uuid.UUID, len(state.ViewedBets))
+	for i, view := range state.ViewedBets {
+		betIDs[i] = view.BetID
+	}
+	views, err := s.repo.GetBetViews(userID, betIDs)
+	if err != nil {
+		return nil, err
+	}
+	drafts, err := s.repo.GetBetDrafts(userID)
+	if err != nil {
+		return nil, err
+	}
+	lang, err := s.repo.GetUserLang(userID)
+	if err != nil {
+		return nil, err
+	}
+	referred, err := s.referralRepo.HasReferrer(userID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	activity := &userActivity{
+		ViewedBets: views,
+		Drafts:     make(map[uuid.UUID]time.Time, len(drafts)),
+		Lang:       lang,
+		Referred:   referred,
+		FirstLogin: user.CtFirstLogin,
+	}
+	for _, draft := range drafts {
+		activity.Drafts[draft.ID] = draft.UpdatedAt
+	}
+	if code, err := s.referralRepo.GetReferralCodeByUserID(userID); err == nil {
+		activity.ReferralCode = code.CvCode
+	}
+	return activity, nil
+}
+
+// mergeAnonymousState решает, что из анонимного состояния перенести пользователю:
+//   - просмотры ставок добавляются, если ставку не смотрели или смотрели раньше;
+//   - черновики добавляются, если у пользователя нет черновика с тем же id или он старее;
+//   - язык переносится, только если пользователь ещё не выбирал язык;
+//   - реферальный код применяется, только если аккаунт зарегистрирован после появления
+//     анонимного посетителя, пользователя ещё никто не приглашал и код не его собственный.
+func mergeAnonymousState(state *models.TAnonymousState, user *userActivity) *repository.AnonymousMerge {
+	merge := &repository.AnonymousMerge{}
+
+	latest := make(map[uuid.UUID]time.Time, len(state.State.ViewedBets))
+	for _, view := range state.State.ViewedBets {
+		if view.ViewedAt.After(latest[view.BetID]) {
+			latest[view.BetID] = view.ViewedAt
+		}
+	}
+	for betID, viewedAt := range latest {
+		if seen, ok := user.ViewedBets[betID]; ok && !viewedAt.After(seen) {
+			continue
+		}
+		merge.ViewedBets = append(merge.ViewedBets, models.ViewedBet{BetID: betID, ViewedAt: viewedAt})
+	}
+	sort.Slice(merge.ViewedBets, func(i, j int) bool {
+		return merge.ViewedBets[i].ViewedAt.After(merge.ViewedBets[j].ViewedAt)
+	})
+
+	for _, draft := range state.State.Drafts {
+		if len(draft.Data) == 0 {
+			continue
+		}
+		if updated, ok := user.Drafts[draft.ID]; ok && !draft.UpdatedAt.After(updated) {
+			continue
+		}
+		merge.Drafts = append(merge.Drafts, draft)
+	}
+
+	if user.Lang == nil && state.State.Lang != nil && *state.State.Lang != "" {
+		merge.Lang = state.State.Lang
+	}
+
+	code := state.State.ReferralCode
+	if code != nil && *code != "" && !user.Referred &&
+		!strings.EqualFold(*code, user.ReferralCode) &&
+		user.FirstLogin != nil && !user.FirstLogin.Before(state.CtCreate) {
+		merge.ReferralCode = code
+	}
+	return merge
+}
+
+// addViewedBet поднимает просмотр ставки в начало списка и оставляет не больше limit просмотров
+func addViewedBet(views []models.ViewedBet, view models.ViewedBet, limit int) []models.ViewedBet {
+	result := make([]models.ViewedBet, 0, len(views)+1)
+	result = append(result, view)
+	for _, v := range views {
+		if v.BetID != view.BetID {
+			result = append(result, v)
+		}
+	}
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// putDraft заменяет черновик с тем же id или добавляет новый; false - превышен limit
+func putDraft(drafts []models.BetDraft, draft models.BetDraft, limit int) ([]models.BetDraft, bool) {
+	for i := range drafts {
+		if drafts[i].ID == draft.ID {
+			drafts[i] = draft
+			return drafts, true
+		}
+	}
+	if len(drafts) >= limit {
+		return drafts, false
+	}
+	return append(drafts, draft), true
+}
+
+func (s *ActivityService) getAnonymousState(id uuid.UUID) (*models.TAnonymousState, error) {
+	state, err := s.repo.GetAnonymousState(id)
+	if err != nil {
+		return nil, databaseError("Failed to get anonymous state", err)
+	}
+	if state == nil {
+		state = &models.TAnonymousState{CkId: id}
+	}
+	return state, nil
+}
+
+// updateAnonymousState читает состояние анонимного посетителя, изменяет его и продлевает срок хранения
+func (s *ActivityService) updateAnonymousState(id uuid.UUID, update func(state *models.AnonymousState) error) error {
+	lock := s.lock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	state, err := s.getAnonymousState(id)
+	if err != nil {
+		return err
+	}
+	if err := update(&state.State); err != nil {
+		return err
+	}
+	state.CtExpire = time.Now().Add(s.duration)
+	if err := s.repo.SaveAnonymousState(state); err != nil {
+		return databaseError("Failed to save anonymous state", err)
+	}
+	return nil
+}
+
+func (s *ActivityService) lock(id uuid.UUID) *sync.Mutex {
+	return &s.locks[int(id[0])%len(s.locks)]
+}
+
+func databaseError(message string, err error) *ServiceError {
+	return &ServiceError{
+		Code:    "DATABASE_ERROR",
+		Message: message,
+		Cause:   err,
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+	"parier-server/internal/util/dbtest"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func strPtr(s string) *string { return &s }
+
+func anonymousState(created time.Time, state models.AnonymousState) *models.TAnonymousState {
+	return &models.TAnonymousState{
+		CkId:      uuid.New(),
+		State:     state,
+		BaseModel: models.BaseModel{CtCreate: created},
+	}
+}
+
+func TestMergeAnonymousStateViewedBets(t *testing.T) {
+	now := time.Now()
+	fresh, seenEarlier, seenLater := uuid.New(), uuid.New(), uuid.New()
+	state := anonymousState(now.Add(-time.Hour), models.AnonymousState{
+		ViewedBets: []models.ViewedBet{
+			{BetID: fresh, ViewedAt: now.Add(-3 * time.Minute)},
+			{BetID: fresh, ViewedAt: now.Add(-time.Minute)},
+			{BetID: seenEarlier, ViewedAt: now.Add(-2 * time.Minute)},
+			{BetID: seenLater, ViewedAt: now.Add(-10 * time.Minute)},
+		},
+	})
+	user := &userActivity{ViewedBets: map[uuid.UUID]time.Time{
+		seenEarlier: now.Add(-time.Hour),
+		seenLater:   now.Add(-5 * time.Minute),
+	}}
+
+	merge := mergeAnonymousState(state, user)
+	if len(merge.ViewedBets) != 2 {
+		t.Fatalf("expected 2 views to merge, got %+v", merge.ViewedBets)
+	}
+	// the latest anonymous view of a bet wins, most recent first
+	if merge.ViewedBets[0].BetID != fresh || !merge.ViewedBets[0].ViewedAt.Equal(now.Add(-time.Minute)) {
+		t.Errorf("expected latest view of the new bet first, got %+v", merge.ViewedBets[0])
+	}
+	if merge.ViewedBets[1].BetID != seenEarlier {
+		t.Errorf("expected the bet viewed earlier by the user to be updated, got %+v", merge.ViewedBets[1])
+	}
+}
+
+func TestMergeAnonymousStateDrafts(t *testing.T) {
+	now := time.Now()
+	newDraft := models.BetDraft{ID: uuid.New(), Data: json.RawMessage(`{"title":"new"}`), UpdatedAt: now}
+	newer := models.BetDraft{ID: uuid.New(), Data: json.RawMessage(`{"title":"newer"}`), UpdatedAt: now}
+	older := models.BetDraft{ID: uuid.New(), Data: json.RawMessage(`{"title":"older"}`), UpdatedAt: now.Add(-time.Hour)}
+	empty := models.BetDraft{ID: uuid.New(), UpdatedAt: now}
+	state := anonymousState(now.Add(-time.Hour), models.AnonymousState{
+		Drafts: []models.BetDraft{newDraft, newer, older, empty},
+	})
+	user := &userActivity{Drafts: map[uuid.UUID]time.Time{
+		newer.ID: now.Add(-time.Minute),
+		older.ID: now.Add(-time.Minute),
+	}}
+
+	merge := mergeAnonymousState(state, user)
+	if len(merge.Drafts) != 2 || merge.Drafts[0].ID != newDraft.ID || merge.Drafts[1].ID != newer.ID {
+		t.Errorf("expected new and newer drafts to merge, got %+v", merge.Drafts)
+	}
+}
+
+func TestMergeAnonymousStateLang(t *testing.T) {
+	state := anonymousState(time.Now(), models.AnonymousState{Lang: strPtr("EN")})
+
+	merge := mergeAnonymousState(state, &userActivity{})
+	if merge.Lang == nil || *merge.Lang != "EN" {
+		t.Errorf("expected language to merge for a user without one, got %v", merge.Lang)
+	}
+
+	merge = mergeAnonymousState(state, &userActivity{Lang: strPtr("RU")})
+	if merge.Lang != nil {
+		t.Errorf("expected the user's language to be kept, got %v", *merge.Lang)
+	}
+}
+
+func TestMergeAnonymousStateReferralCode(t *testing.T) {
+	visited := time.Now().Add(-time.Hour)
+	registered := visited.Add(30 * time.Minute)
+	registeredBefore := visited.Add(-24 * time.Hour)
+	state := anonymousState(visited, models.AnonymousState{ReferralCode: strPtr("ABCD1234")})
+
+	tests := []struct {
+		name  string
+		user  userActivity
+		apply bool
+	}{
+		{"registered after visit", userActivity{FirstLogin: &registered}, true},
+		{"registered before visit", userActivity{FirstLogin: &registeredBefore}, false},
+		{"first login unknown", userActivity{}, false},
+		{"already referred", userActivity{FirstLogin: &registered, Referred: true}, false},
+		{"own code", userActivity{FirstLogin: &registered, ReferralCode: "abcd1234"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merge := mergeAnonymousState(state, &tt.user)
+			if applied := merge.ReferralCode != nil; applied != tt.apply {
+				t.Errorf("expected referral applied=%v, got %v", tt.apply, applied)
+			}
+		})
+	}
+}
+
+func TestApplyAnonymousMergeReferralFailure(t *testing.T) {
+	db, fake := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		if q.Has("UPDATE") {
+			return dbtest.Result{Affected: 1}
+		}
+		return dbtest.Result{}
+	})
+	merge := &repository.AnonymousMerge{Lang: strPtr("DE"), ReferralCode: strPtr("ABCD1234")}
+	attribute := func(tx *gorm.DB, userID uuid.UUID, code string) error {
+		tx.Exec("INSERT INTO t_user_referral DEFAULT VALUES")
+		return errors.New("referral code is not found")
+	}
+	err := repository.NewActivityRepository(db).ApplyAnonymousMerge(uuid.New(), uuid.New(), merge, attribute)
+	if err != nil {
+		t.Fatalf("merge must not fail on the referral code: %v", err)
+	}
+	if merge.ReferralCode != nil {
+		t.Error("expected the failed referral code to be dropped")
+	}
+	var queries []string
+	for _, q := range fake.Queries() {
+		switch {
+		case q.Has(`UPDATE "t_anonymous_state"`):
+			queries = append(queries, "UPDATE t_anonymous_state")
+		case q.Has("SAVEPOINT"), q.SQL == "COMMIT":
+			queries = append(queries, q.SQL)
+		}
+	}
+	want := []string{"SAVEPOINT referral", "ROLLBACK TO SAVEPOINT referral", "UPDATE t_anonymous_state", "COMMIT"}
+	if !slices.Equal(queries, want) {
+		t.Errorf("expected queries %q, got %q", want, queries)
+	}
+}
+
+func TestAddViewedBet(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	views := []models.ViewedBet{{BetID: a}, {BetID: b}}
+
+	views = addViewedBet(views, models.ViewedBet{BetID: b}, 2)
+	if views[0].BetID != b || views[1].BetID != a || len(views) != 2 {
+		t.Fatalf("expected repeated view to move to the front, got %+v", views)
+	}
+	views = addViewedBet(views, models.ViewedBet{BetID: c}, 2)
+	if len(views) != 2 || views[0].BetID != c || views[1].BetID != b {
+		t.Errorf("expected the oldest view to be dropped, got %+v", views)
+	}
+}
+
+func TestPutDraft(t *testing.T) {
+	first := models.BetDraft{ID: uuid.New(), Data: json.RawMessage(`{}`)}
+	drafts, ok := putDraft(nil, first, 1)
+	if !ok || len(drafts) != 1 {
+		t.Fatalf("expected draft to be added, got %+v", drafts)
+	}
+	updated := first
+	updated.Data = json.RawMessage(`{"title":"x"}`)
+	if drafts, ok = putDraft(drafts, updated, 1); !ok || string(drafts[0].Data) != `{"title":"x"}` {
+		t.Errorf("expected draft to be replaced at the limit, got %+v", drafts)
+	}
+	if _, ok = putDraft(drafts, models.BetDraft{ID: uuid.New()}, 1); ok {
+		t.Error("expected new draft over the limit to be rejected")
+	}
+}
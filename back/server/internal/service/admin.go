@@ -10,12 +10,15 @@ import (
 )
 
 type AdminService struct {
-	repo *repository.UserRepository
-	db   *gorm.DB
+	repo          *repository.UserRepository
+	db            *gorm.DB
+	audit         *AuditService
+	notifications *NotificationService
+	events        *EventHub
 }
 
-func NewAdminService(repo *repository.UserRepository, db *gorm.DB) *AdminService {
-	return &AdminService{repo: repo, db: db}
+func NewAdminService(repo *repository.UserRepository, db *gorm.DB, audit *AuditService, notifications *NotificationService, events *EventHub) *AdminService {
+	return &AdminService{repo: repo, db: db, audit: audit, notifications: notifications, events: events}
 }
 
 // ResolveCreditTargets returns user IDs matching the rule
@@ -71,7 +74,8 @@ func (s *AdminService) ResolveCreditTargets(rule string, params map[string]inter
 }
 
 // CreditUsers adds amount to each user's wallet and creates transaction
-func (s *AdminService) CreditUsers(userIDs []uuid.UUID, amount float64, description string, adminID string) (map[string]float64, error) {
+func (s *AdminService) CreditUsers(userIDs []uuid.UUID, amount float64, description string, actor AuditActor) (map[string]float64, error) {
+	adminID := actor.User
 	result := make(map[string]float64)
 	tx := s.db.Begin()
 	defer func() {
@@ -98,6 +102,7 @@ func (s *AdminService) CreditUsers(userIDs []uuid.UUID, amount float64, descript
 			}
 		}
 
+		balanceBefore := wallet.CnValue
 		wallet.CnValue += amount
 		wallet.CkModify = adminID
 		if err := tx.Save(wallet).Error; err != nil {
@@ -120,6 +125,18 @@ func (s *AdminService) CreditUsers(userIDs []uuid.UUID, amount float64, descript
 			tx.Rollback()
 			return nil, err
 		}
+		if err := s.audit.Record(tx, actor, walletAuditEntry(AuditAdminCredit, wallet, balanceBefore, tr)); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := s.notifications.Notify(tx, adminID, walletNotice(models.NotificationTypeAdminCredit, wallet, tr, description)); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := publishWalletEvent(s.events, tx, wallet, tr); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
 
 		result[userID.String()] = wallet.CnValue
 	}
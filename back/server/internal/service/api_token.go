@@ -0,0 +1,324 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+	"parier-server/internal/util"
+
+	"github.com/google/uuid"
+)
+
+// apiTokenTouchInterval - точность времени последнего использования токена
+const apiTokenTouchInterval = time.Minute
+
+// ErrInvalidAPIToken - токен не найден, отозван или истёк
+var ErrInvalidAPIToken = errors.New("invalid api token")
+
+// APITokenInfo describes a token without its secret
+type APITokenInfo struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CreatedAPIToken is returned once, right after creation; only the hash is stored
+type CreatedAPIToken struct {
+	APITokenInfo
+	Token string `json:"token"`
+}
+
+// ServiceAccountInfo describes a service account
+type ServiceAccountInfo struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Roles     []string  `json:"roles"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APITokenService выдаёт и проверяет API токены пользователей и сервисных аккаунтов.
+// Токен действует от имени владельца, но только в пределах своих областей действия.
+type APITokenService struct {
+	repo     *repository.APITokenRepository
+	userRepo *repository.UserRepository
+	audit    *AuditService
+}
+
+func NewAPITokenService(repo *repository.APITokenRepository, userRepo *repository.UserRepository, audit *AuditService) *APITokenService {
+	return &APITokenService{repo: repo, userRepo: userRepo, audit: audit}
+}
+
+// ParseTokenScope разбирает область действия вида "table:<таблица>:<действие>"
+// или "property:<тип свойства>:<действие>"
+func ParseTokenScope(scope string) (models.TAPITokenScope, error) {
+	parts := strings.Split(strings.TrimSpace(scope), ":")
+	if len(parts) != 3 || parts[1] == "" {
+		return models.TAPITokenScope{}, fmt.Errorf("scope %q must look like table:<table>:<action> or property:<type>:<action>", scope)
+	}
+	var kind models.TokenScopeKind
+	switch strings.ToLower(parts[0]) {
+	case "table":
+		kind = models.TokenScopeTable
+	case "property":
+		kind = models.TokenScopeProperty
+	default:
+		return models.TAPITokenScope{}, fmt.Errorf("scope %q: unknown kind %q", scope, parts[0])
+	}
+	action := models.ActionType(strings.ToUpper(parts[2]))
+	if !models.IsValidActionType(action) {
+		return models.TAPITokenScope{}, fmt.Errorf("scope %q: unknown action %q", scope, parts[2])
+	}
+	return models.TAPITokenScope{CrScope: kind, CkObject: parts[1], CrAction: action}, nil
+}
+
+// FormatTokenScope - обратное ParseTokenScope
+func FormatTokenScope(scope models.TAPITokenScope) string {
+	return fmt.Sprintf("%s:%s:%s", strings.ToLower(string(scope.CrScope)), scope.CkObject, scope.CrAction)
+}
+
+// CreateToken выдаёт токен владельцу. Каждая область действия должна быть разрешена
+// ролям владельца, иначе токен мог бы дать больше прав, чем есть у владельца.
+func (s *APITokenService) CreateToken(ownerID uuid.UUID, name string, scopes []string, expiresAt *time.Time, actor AuditActor) (*CreatedAPIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Token name is required"}
+	}
+	if len(scopes) == 0 {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "At least one scope is required"}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Expiry must be in the future"}
+	}
+	parsed := make([]models.TAPITokenScope, 0, len(scopes))
+	for _, raw := range scopes {
+		scope, err := ParseTokenScope(raw)
+		if err != nil {
+			return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: err.Error()}
+		}
+		allowed, err := s.ownerHasPermission(ownerID, scope)
+		if err != nil {
+			return nil, databaseError("Failed to check permissions", err)
+		}
+		if !allowed {
+			return nil, &ServiceError{
+				Code:    "FORBIDDEN",
+				Message: fmt.Sprintf("Owner has no permission for scope %s", FormatTokenScope(scope)),
+			}
+		}
+		scope.CkId = uuid.New()
+		parsed = append(parsed, scope)
+	}
+
+	secret, err := util.GenerateAPIToken()
+	if err != nil {
+		return nil, &ServiceError{Code: "INTERNAL_ERROR", Message: "Failed to generate token", Cause: err}
+	}
+	token := &models.TAPIToken{
+		CkId:     uuid.New(),
+		CkUser:   ownerID,
+		CvName:   name,
+		CvPrefix: secret[:len(util.APITokenPrefix)+8],
+		CvHash:   util.HashAPIToken(secret),
+		CtExpire: expiresAt,
+		Scopes:   parsed,
+		BaseModel: models.BaseModel{
+			CkCreate: actor.User,
+			CkModify: actor.User,
+		},
+	}
+	if err := s.repo.CreateToken(token); err != nil {
+		return nil, databaseError("Failed to create token", err)
+	}
+	info := tokenInfo(token)
+	s.audit.RecordCommitted(actor, AuditEntry{Action: AuditAPITokenCreate, EntityType: "t_api_token", EntityID: token.CkId.String(), After: info})
+	return &CreatedAPIToken{APITokenInfo: info, Token: secret}, nil
+}
+
+// ListTokens возвращает действующие токены владельца
+func (s *APITokenService) ListTokens(ownerID uuid.UUID) ([]APITokenInfo, error) {
+	tokens, err := s.repo.GetTokensByUserID(ownerID)
+	if err != nil {
+		return nil, databaseError("Failed to get tokens", err)
+	}
+	result := make([]APITokenInfo, len(tokens))
+	for i := range tokens {
+		result[i] = tokenInfo(&tokens[i])
+	}
+	return result, nil
+}
+
+// RevokeToken отзывает токен владельца
+func (s *APITokenService) RevokeToken(ownerID uuid.UUID, tokenID uuid.UUID, actor AuditActor) error {
+	revoked, err := s.repo.RevokeToken(ownerID, tokenID, actor.User)
+	if err != nil {
+		return databaseError("Failed to revoke token", err)
+	}
+	if !revoked {
+		return &ServiceError{Code: "NOT_FOUND", Message: "Token not found"}
+	}
+	s.audit.RecordCommitted(actor, AuditEntry{
+		Action:     AuditAPITokenRevoke,
+		EntityType: "t_api_token",
+		EntityID:   tokenID.String(),
+		Before:     map[string]any{"ck_user": ownerID, "revoked": false},
+		After:      map[string]any{"ck_user": ownerID, "revoked": true},
+	})
+	return nil
+}
+
+// Authenticate проверяет токен и возвращает его владельца с текущими ролями
+func (s *APITokenService) Authenticate(secret string) (*models.User, *models.TAPIToken, error) {
+	token, err := s.repo.GetActiveTokenByHash(util.HashAPIToken(secret))
+	if err != nil {
+		return nil, nil, err
+	}
+	if token == nil {
+		return nil, nil, ErrInvalidAPIToken
+	}
+	roles, err := s.userRepo.GetUserRoleByID(token.CkUser)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get user roles: %w", err)
+	}
+	data, err := s.userRepo.GetUserDataByID(token.CkUser)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get user data: %w", err)
+	}
+	now := time.Now()
+	if token.CtLastUsed == nil || now.Sub(*token.CtLastUsed) >= apiTokenTouchInterval {
+		token.CtLastUsed = &now
+		if err := s.repo.TouchToken(token.CkId, now); err != nil {
+			log.Printf("Failed to record use of api token %s: %v", token.CkId, err)
+		}
+	}
+	username, _ := data["USER_USERNAME"].(string)
+	return &models.User{
+		ID:        token.CkUser,
+		Username:  username,
+		SessionID: token.CkId,
+		Realm:     "api-token",
+		Data:      data,
+		Roles:     roles,
+	}, token, nil
+}
+
+// CreateServiceAccount создаёт сервисный аккаунт с заданными ролями
+func (s *APITokenService) CreateServiceAccount(name string, roles []string, actor AuditActor) (*ServiceAccountInfo, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Service account name is required"}
+	}
+	for _, role := range roles {
+		if _, err := s.userRepo.GetRoleByID(role); err != nil {
+			return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: fmt.Sprintf("Unknown role %s", role), Cause: err}
+		}
+	}
+	user, err := s.repo.CreateServiceAccount(name, roles, actor.User)
+	if err != nil {
+		return nil, databaseError("Failed to create service account", err)
+	}
+	info := &ServiceAccountInfo{ID: user.CkId, Name: name, Roles: roles, CreatedAt: user.CtCreate}
+	s.audit.RecordCommitted(actor, AuditEntry{Action: AuditServiceAccountCreate, EntityType: "t_user", EntityID: user.CkId.String(), After: info})
+	return info, nil
+}
+
+// ListServiceAccounts возвращает сервисные аккаунты
+func (s *APITokenService) ListServiceAccounts() ([]ServiceAccountInfo, error) {
+	users, err := s.repo.GetServiceAccounts()
+	if err != nil {
+		return nil, databaseError("Failed to get service accounts", err)
+	}
+	result := make([]ServiceAccountInfo, len(users))
+	for i := range users {
+		result[i] = serviceAccountInfo(&users[i])
+	}
+	return result, nil
+}
+
+// GetServiceAccount возвращает сервисный аккаунт или NOT_FOUND
+func (s *APITokenService) GetServiceAccount(id uuid.UUID) (*ServiceAccountInfo, error) {
+	user, err := s.repo.GetServiceAccount(id)
+	if err != nil {
+		return nil, databaseError("Failed to get service account", err)
+	}
+	if user == nil {
+		return nil, &ServiceError{Code: "NOT_FOUND", Message: "Service account not found"}
+	}
+	info := serviceAccountInfo(user)
+	return &info, nil
+}
+
+// DeleteServiceAccount удаляет сервисный аккаунт и отзывает его токены
+func (s *APITokenService) DeleteServiceAccount(id uuid.UUID, actor AuditActor) error {
+	info, err := s.GetServiceAccount(id)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.repo.DeleteServiceAccount(id, actor.User)
+	if err != nil {
+		return databaseError("Failed to delete service account", err)
+	}
+	if !deleted {
+		return &ServiceError{Code: "NOT_FOUND", Message: "Service account not found"}
+	}
+	s.audit.RecordCommitted(actor, AuditEntry{Action: AuditServiceAccountDelete, EntityType: "t_user", EntityID: id.String(), Before: info})
+	return nil
+}
+
+func (s *APITokenService) ownerHasPermission(ownerID uuid.UUID, scope models.TAPITokenScope) (bool, error) {
+	actions := []models.ActionType{scope.CrAction}
+	if scope.CrAction == models.ActionTypeAll {
+		// ALL допустим, только если владелец может всё
+		actions = []models.ActionType{models.ActionTypeView, models.ActionTypeInsert, models.ActionTypeUpdate, models.ActionTypeDelete}
+	}
+	for _, action := range actions {
+		var allowed bool
+		var err error
+		if scope.CrScope == models.TokenScopeTable {
+			allowed, err = s.userRepo.HasTablePermission(ownerID, scope.CkObject, action)
+		} else {
+			allowed, err = s.userRepo.HasPropertyPermission(ownerID, scope.CkObject, action)
+		}
+		if err != nil || !allowed {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func tokenInfo(token *models.TAPIToken) APITokenInfo {
+	scopes := make([]string, len(token.Scopes))
+	for i, scope := range token.Scopes {
+		scopes[i] = FormatTokenScope(scope)
+	}
+	return APITokenInfo{
+		ID:        token.CkId,
+		Name:      token.CvName,
+		Prefix:    token.CvPrefix,
+		Scopes:    scopes,
+		ExpiresAt: token.CtExpire,
+		LastUsed:  token.CtLastUsed,
+		CreatedAt: token.CtCreate,
+	}
+}
+
+func serviceAccountInfo(user *models.TUser) ServiceAccountInfo {
+	info := ServiceAccountInfo{ID: user.CkId, Roles: []string{}, CreatedAt: user.CtCreate}
+	for _, prop := range user.UserProperties {
+		if prop.CkType == "USER_USERNAME" && prop.CvText != nil {
+			info.Name = *prop.CvText
+		}
+	}
+	for _, role := range user.UserRoles {
+		info.Roles = append(info.Roles, role.CkRole)
+	}
+	return info
+}
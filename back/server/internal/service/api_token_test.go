@@ -0,0 +1,70 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"parier-server/internal/models"
+	"parier-server/internal/util"
+)
+
+func TestParseTokenScope(t *testing.T) {
+	scope, err := ParseTokenScope("Table:t_localization_word:view")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if scope.CrScope != models.TokenScopeTable || scope.CkObject != "t_localization_word" || scope.CrAction != models.ActionTypeView {
+		t.Errorf("unexpected scope %+v", scope)
+	}
+	if formatted := FormatTokenScope(scope); formatted != "table:t_localization_word:VIEW" {
+		t.Errorf("unexpected formatted scope %q", formatted)
+	}
+
+	for _, raw := range []string{"", "table:t_user", "table::VIEW", "role:t_user:VIEW", "table:t_user:READ", "table:t_user:VIEW:x"} {
+		if _, err := ParseTokenScope(raw); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestAPITokenAllows(t *testing.T) {
+	token := &models.TAPIToken{Scopes: []models.TAPITokenScope{
+		{CrScope: models.TokenScopeTable, CkObject: "t_bet", CrAction: models.ActionTypeView},
+		{CrScope: models.TokenScopeProperty, CkObject: "USER_WALLET", CrAction: models.ActionTypeAll},
+	}}
+
+	tests := []struct {
+		kind   models.TokenScopeKind
+		object string
+		action models.ActionType
+		allow  bool
+	}{
+		{models.TokenScopeTable, "t_bet", models.ActionTypeView, true},
+		{models.TokenScopeTable, "t_bet", models.ActionTypeUpdate, false},
+		{models.TokenScopeTable, "t_user", models.ActionTypeView, false},
+		{models.TokenScopeProperty, "USER_WALLET", models.ActionTypeDelete, true},
+		{models.TokenScopeTable, "USER_WALLET", models.ActionTypeView, false},
+	}
+	for _, tt := range tests {
+		if got := token.Allows(tt.kind, tt.object, tt.action); got != tt.allow {
+			t.Errorf("Allows(%s, %s, %s) = %v, want %v", tt.kind, tt.object, tt.action, got, tt.allow)
+		}
+	}
+}
+
+func TestGenerateAPIToken(t *testing.T) {
+	a, err := util.GenerateAPIToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, _ := util.GenerateAPIToken()
+	if a == b {
+		t.Error("expected tokens to differ")
+	}
+	if !strings.HasPrefix(a, util.APITokenPrefix) || !util.IsAPIToken(a) {
+		t.Errorf("expected token with %q prefix, got %q", util.APITokenPrefix, a)
+	}
+	if util.HashAPIToken(a) != util.HashAPIToken(a) || util.HashAPIToken(a) == util.HashAPIToken(b) {
+		t.Error("expected hash to be deterministic and distinct per token")
+	}
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Действия журнала аудита
+const (
+	AuditWalletDeposit        = "wallet.deposit"
+	AuditWalletWithdraw       = "wallet.withdraw"
+	AuditAdminCredit          = "admin.credit"
+	AuditBetCreate            = "bet.create"
+	AuditBetSettle            = "bet.settle"
+	AuditCommentEdit          = "comment.edit"
+	AuditCommentRemove        = "comment.remove"
+	AuditChatModerator        = "chat.moderator"
+	AuditChatBan              = "chat.ban"
+	AuditChatUnban            = "chat.unban"
+	AuditReferralCommission   = "referral.commission"
+	AuditReferralFreeze       = "referral.freeze"
+	AuditReferralUnfreeze     = "referral.unfreeze"
+	AuditRoleCreate           = "role.create"
+	AuditRoleDelete           = "role.delete"
+	AuditRoleGrantTable       = "role.grant_table"
+	AuditRoleRevokeTable      = "role.revoke_table"
+	AuditRoleGrantProperty    = "role.grant_property"
+	AuditRoleRevokeProperty   = "role.revoke_property"
+	AuditUserRoleAssign       = "user_role.assign"
+	AuditUserRoleRevoke       = "user_role.revoke"
+	AuditLanguageCreate       = "language.create"
+	AuditTranslationsUpdate   = "localization.bulk_update"
+	AuditTranslationsApprove  = "localization.approve"
+	AuditAPITokenCreate       = "api_token.create"
+	AuditAPITokenRevoke       = "api_token.revoke"
+	AuditServiceAccountCreate = "service_account.create"
+	AuditServiceAccountDelete = "service_account.delete"
+)
+
+const (
+	auditActorSystem = "system"
+	auditVerifyBatch = 1000
+)
+
+// AuditActor - кто выполняет действие: пользователь, его сессия (для API токена - токен) и IP
+type AuditActor struct {
+	User    string
+	Session *uuid.UUID
+	IP      string
+}
+
+// SystemActor - автор действий, выполняемых сервером без запроса пользователя
+var SystemActor = AuditActor{User: auditActorSystem}
+
+// AuditActorFromSession определяет автора действия по сессии запроса
+func AuditActorFromSession(session *models.TSession, ip string) AuditActor {
+	sessionID := session.CkId
+	return AuditActor{User: VisitorFromSession(session).ID.String(), Session: &sessionID, IP: ip}
+}
+
+// AuditEntry - изменение объекта. Before и After - состояния объекта до и после операции
+// (nil при создании и удалении); в журнал попадают только отличающиеся поля
+type AuditEntry struct {
+	Action     string
+	EntityType string
+	EntityID   string
+	Before     any
+	After      any
+}
+
+// AuditVerification - результат проверки цепочки хешей журнала
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`
+	Head     string `json:"head"`
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// AuditService ведёт журнал аудита изменений. Каждая запись содержит хеш предыдущей,
+// поэтому изменение или удаление записи в середине журнала обнаруживается проверкой цепочки
+type AuditService struct {
+	repo *repository.AuditRepository
+}
+
+func NewAuditService(repo *repository.AuditRepository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Record записывает изменение в транзакции tx, чтобы запись и изменение фиксировались вместе
+func (s *AuditService) Record(tx *gorm.DB, actor AuditActor, entry AuditEntry) error {
+	record, err := newAuditRecord(actor, entry, time.Now())
+	if err != nil {
+		return err
+	}
+	return s.repo.Append(tx, record, auditHash)
+}
+
+// RecordCommitted записывает уже зафиксированное изменение. Ошибка записи только логируется:
+// отменить изменение уже нельзя, а ответ клиенту не должен сообщать о неудаче
+func (s *AuditService) RecordCommitted(actor AuditActor, entry AuditEntry) {
+	if err := s.Record(nil, actor, entry); err != nil {
+		log.Printf("Failed to record audit entry %s %s %s: %v", entry.Action, entry.EntityType, entry.EntityID, err)
+	}
+}
+
+// List возвращает записи журнала по фильтру, новые первыми
+func (s *AuditService) List(filter models.AuditLogFilter, offset, limit *int) ([]models.TAuditLog, int64, error) {
+	entries, total, err := s.repo.Find(filter, offset, limit)
+	if err != nil {
+		return nil, 0, databaseError("Failed to get audit log", err)
+	}
+	return entries, total, nil
+}
+
+// Verify проверяет всю цепочку: номера идут подряд, каждая запись ссылается на хеш предыдущей
+// и её хеш совпадает с пересчитанным. Head - хеш последней записи; сохранённый вне БД,
+// он позволяет обнаружить и удаление записей с конца журнала
+func (s *AuditService) Verify() (*AuditVerification, error) {
+	result := &AuditVerification{Valid: true, Head: repository.AuditGenesisHash}
+	var seq int64
+	for {
+		entries, err := s.repo.GetChain(seq, auditVerifyBatch)
+		if err != nil {
+			return nil, databaseError("Failed to get audit log", err)
+		}
+		checked, reason := verifyAuditChain(seq, result.Head, entries)
+		result.Checked += int64(checked)
+		if reason != "" {
+			brokenAt := entries[checked].CnSeq
+			result.Valid = false
+			result.BrokenAt = &brokenAt
+			result.Reason = reason
+			return result, nil
+		}
+		if len(entries) > 0 {
+			last := entries[len(entries)-1]
+			seq, result.Head = last.CnSeq, last.CvHash
+		}
+		if len(entries) < auditVerifyBatch {
+			return result, nil
+		}
+	}
+}
+
+func newAuditRecord(actor AuditActor, entry AuditEntry, at time.Time) (*models.TAuditLog, error) {
+	before, after, err := auditDiff(entry.Before, entry.After)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build audit diff: %w", err)
+	}
+	record := &models.TAuditLog{
+		CkId:         uuid.New(),
+		CkActor:      actor.User,
+		CkSession:    actor.Session,
+		CvAction:     entry.Action,
+		CvEntityType: entry.EntityType,
+		CkEntity:     entry.EntityID,
+		CjBefore:     before,
+		CjAfter:      after,
+		// в БД время хранится с точностью до микросекунд, хеш считается по сохраняемому значению
+		CtCreate: at.UTC().Truncate(time.Microsecond),
+	}
+	if record.CkActor == "" {
+		record.CkActor = auditActorSystem
+	}
+	if actor.IP != "" {
+		record.CvIp = &actor.IP
+	}
+	return record, nil
+}
+
+// auditHash - SHA-256 полей записи вместе с хешем предыдущей записи
+func auditHash(entry *models.TAuditLog) string {
+	var session *string
+	if entry.CkSession != nil {
+		value := entry.CkSession.String()
+		session = &value
+	}
+	payload, _ := json.Marshal([]any{
+		entry.CnSeq,
+		entry.CvPrevHash,
+		entry.CkId.String(),
+		entry.CtCreate.UTC().Format(time.RFC3339Nano),
+		entry.CkActor,
+		session,
+		entry.CvIp,
+		entry.CvAction,
+		entry.CvEntityType,
+		entry.CkEntity,
+		entry.CjBefore,
+		entry.CjAfter,
+	})
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyAuditChain проверяет записи, следующие за записью seq с хешем prevHash.
+// Возвращает число верных записей и причину ошибки для следующей за ними записи
+func verifyAuditChain(seq int64, prevHash string, entries []models.TAuditLog) (int, string) {
+	for i := range entries {
+		entry := &entries[i]
+		switch {
+		case entry.CnSeq != seq+1:
+			return i, fmt.Sprintf("expected entry %d, found %d", seq+1, entry.CnSeq)
+		case entry.CvPrevHash != prevHash:
+			return i, "previous hash does not match"
+		case auditHash(entry) != entry.CvHash:
+			return i, "entry hash does not match its content"
+		}
+		seq, prevHash = entry.CnSeq, entry.CvHash
+	}
+	return len(entries), ""
+}
+
+// auditDiff оставляет в состояниях до и после только отличающиеся поля.
+// При создании или удалении объекта сохраняется его состояние целиком
+func auditDiff(before, after any) (*string, *string, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	if beforeFields != nil && afterFields != nil {
+		for key, value := range beforeFields {
+			if other, ok := afterFields[key]; ok && reflect.DeepEqual(value, other) {
+				delete(beforeFields, key)
+				delete(afterFields, key)
+			}
+		}
+	}
+	beforeJSON, err := auditJSON(beforeFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterJSON, err := auditJSON(afterFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	return beforeJSON, afterJSON, nil
+}
+
+func auditFields(state any) (map[string]any, error) {
+	if state == nil {
+		return nil, nil
+	}
+	value := reflect.ValueOf(state)
+	if value.Kind() == reflect.Pointer && value.IsNil() {
+		return nil, nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]any)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func auditJSON(fields map[string]any) (*string, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	// json.Marshal сортирует ключи, поэтому текст однозначен
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+	return &text, nil
+}
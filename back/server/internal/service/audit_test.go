@@ -0,0 +1,110 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// buildAuditChain связывает записи так же, как AuditRepository.Append
+func buildAuditChain(t *testing.T, entries ...AuditEntry) []models.TAuditLog {
+	t.Helper()
+	sessionID := uuid.New()
+	actor := AuditActor{User: uuid.NewString(), Session: &sessionID, IP: "10.0.0.1"}
+	prevHash := repository.AuditGenesisHash
+	chain := make([]models.TAuditLog, 0, len(entries))
+	for i, entry := range entries {
+		record, err := newAuditRecord(actor, entry, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		record.CnSeq = int64(i + 1)
+		record.CvPrevHash = prevHash
+		record.CvHash = auditHash(record)
+		prevHash = record.CvHash
+		chain = append(chain, *record)
+	}
+	return chain
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	wallet := uuid.NewString()
+	newChain := func() []models.TAuditLog {
+		return buildAuditChain(t,
+			AuditEntry{Action: AuditWalletDeposit, EntityType: "t_user_wallet", EntityID: wallet,
+				Before: map[string]any{"cn_value": 0}, After: map[string]any{"cn_value": 100}},
+			AuditEntry{Action: AuditWalletWithdraw, EntityType: "t_user_wallet", EntityID: wallet,
+				Before: map[string]any{"cn_value": 100}, After: map[string]any{"cn_value": 40}},
+			AuditEntry{Action: AuditRoleCreate, EntityType: "t_d_role", EntityID: "MODERATOR",
+				After: map[string]any{"ck_id": "MODERATOR"}},
+		)
+	}
+
+	if checked, reason := verifyAuditChain(0, repository.AuditGenesisHash, newChain()); checked != 3 || reason != "" {
+		t.Fatalf("intact chain: checked %d, reason %q", checked, reason)
+	}
+
+	tampered := newChain()
+	forged := `{"cn_value":1000}`
+	tampered[1].CjAfter = &forged
+	if checked, reason := verifyAuditChain(0, repository.AuditGenesisHash, tampered); checked != 1 || reason == "" {
+		t.Errorf("changed entry: checked %d, reason %q", checked, reason)
+	}
+
+	rehashed := newChain()
+	rehashed[1].CkActor = uuid.NewString()
+	rehashed[1].CvHash = auditHash(&rehashed[1])
+	if checked, reason := verifyAuditChain(0, repository.AuditGenesisHash, rehashed); checked != 2 || reason == "" {
+		t.Errorf("rehashed entry must break the link of the next one: checked %d, reason %q", checked, reason)
+	}
+
+	removed := newChain()
+	removed = append(removed[:1], removed[2:]...)
+	if checked, reason := verifyAuditChain(0, repository.AuditGenesisHash, removed); checked != 1 || reason == "" {
+		t.Errorf("removed entry: checked %d, reason %q", checked, reason)
+	}
+}
+
+func TestAuditHashStableAfterStorage(t *testing.T) {
+	chain := buildAuditChain(t, AuditEntry{Action: AuditBetCreate, EntityType: "t_bet", EntityID: uuid.NewString(),
+		After: map[string]any{"cn_amount": 10.5}})
+	// значение, прочитанное из колонки timestamp, приходит без зоны и с точностью до микросекунд
+	stored := chain[0]
+	stored.CtCreate = time.Date(stored.CtCreate.Year(), stored.CtCreate.Month(), stored.CtCreate.Day(),
+		stored.CtCreate.Hour(), stored.CtCreate.Minute(), stored.CtCreate.Second(), stored.CtCreate.Nanosecond(), time.UTC)
+	if auditHash(&stored) != chain[0].CvHash {
+		t.Errorf("hash changed after storage round trip")
+	}
+}
+
+func TestAuditDiff(t *testing.T) {
+	before, after, err := auditDiff(
+		map[string]any{"ck_user": "u1", "cn_value": 100, "ck_modify": "u1"},
+		map[string]any{"ck_user": "u1", "cn_value": 40, "ck_modify": "admin"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *before != `{"ck_modify":"u1","cn_value":100}` || *after != `{"ck_modify":"admin","cn_value":40}` {
+		t.Errorf("unexpected diff %s -> %s", *before, *after)
+	}
+
+	role := &models.TDRole{CkId: "MODERATOR", CkName: "role.moderator", CrPlace: models.RolePlaceGlobal}
+	before, after, err = auditDiff(nil, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != nil || after == nil {
+		t.Fatalf("created object must be stored whole, got %v -> %v", before, after)
+	}
+
+	var grant *models.TDTableRole
+	before, after, err = auditDiff(grant, nil)
+	if err != nil || before != nil || after != nil {
+		t.Errorf("nil pointer must be treated as missing state, got %v -> %v (%v)", before, after, err)
+	}
+}
@@ -0,0 +1,715 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// chatMessageMaxLength - длина сообщения чата, в символах
+	chatMessageMaxLength = 4000
+	// chatMessagesPage - сообщений истории по умолчанию
+	chatMessagesPage = 50
+	// chatPageMax - больше сообщений или чатов за один запрос не отдаётся
+	chatPageMax = 100
+	// chatDefaultLike - тип лайка, если клиент его не указал
+	chatDefaultLike = "LIKE"
+)
+
+// ChatService - комнаты ставок, личные и групповые чаты: участники, история сообщений, ответы,
+// лайки, баны и приглашения. Новые сообщения доставляются подписчикам темы chat:<id> потока событий
+type ChatService struct {
+	repo     *repository.ParierRepository
+	repoUser *repository.UserRepository
+	parier   *ParierService
+	audit    *AuditService
+	events   *EventHub
+	joins    chatJoinAttempts
+}
+
+func NewChatService(repo *repository.ParierRepository, repoUser *repository.UserRepository, parier *ParierService, audit *AuditService, events *EventHub) *ChatService {
+	s := &ChatService{repo: repo, repoUser: repoUser, parier: parier, audit: audit, events: events}
+	events.Authorize(TopicChat, s.authorizeTopic)
+	return s
+}
+
+// GetBetChat возвращает комнату ставки, создавая её при первом обращении. Автор ставки
+// становится администратором комнаты
+func (s *ChatService) GetBetChat(betID uuid.UUID, request models.DefaultRequest) (*models.ChatResponse, error) {
+	chat, err := s.repo.GetBetChat(betID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		bet, err := s.repo.GetBetByID(betID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Code: "NOT_FOUND", Message: "Bet not found"}
+		}
+		if err != nil {
+			return nil, databaseError("Failed to get bet", err)
+		}
+		err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+			chat := &models.TChat{
+				CkAuthor: bet.CkAuthor,
+				CrType:   models.ChatTypePublic,
+				CkBet:    &bet.CkId,
+			}
+			created, err := s.repo.CreateBetChat(chat, tx)
+			if err != nil {
+				return databaseError("Failed to create bet chat", err)
+			}
+			if !created {
+				return nil
+			}
+			return s.addMember(tx, chat.CkId, bet.CkAuthor, bet.CkAuthor, true, false)
+		})
+		if err != nil {
+			return nil, err
+		}
+		chat, err = s.repo.GetBetChat(betID)
+	}
+	if err != nil {
+		return nil, databaseError("Failed to get bet chat", err)
+	}
+	return s.chatResponse(chat, viewerID(request.User))
+}
+
+// OpenDirectChat возвращает личный чат с пользователем, создавая его при первом обращении
+func (s *ChatService) OpenDirectChat(request models.ChatDirectRequest) (*models.ChatResponse, error) {
+	userID := request.User.ID
+	if request.UserID == userID {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Cannot open a direct chat with yourself"}
+	}
+	if _, err := s.repoUser.GetUserByID(request.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Code: "NOT_FOUND", Message: "User not found"}
+		}
+		return nil, databaseError("Failed to get user", err)
+	}
+	var chat *models.TChat
+	err := s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.LockDirectChat(tx, userID, request.UserID); err != nil {
+			return databaseError("Failed to lock direct chat", err)
+		}
+		existing, err := s.repo.GetDirectChat(tx, userID, request.UserID)
+		if err == nil {
+			chat = existing
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return databaseError("Failed to get direct chat", err)
+		}
+		chat = &models.TChat{
+			CkAuthor: userID,
+			CrType:   models.ChatTypePrivate,
+			ClDirect: true,
+			BaseModel: models.BaseModel{
+				CkCreate: userID.String(),
+				CkModify: userID.String(),
+			},
+		}
+		if err := s.repo.CreateChat(chat, tx); err != nil {
+			return databaseError("Failed to create direct chat", err)
+		}
+		for _, member := range []uuid.UUID{userID, request.UserID} {
+			if err := s.addMember(tx, chat.CkId, member, userID, false, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.chatResponse(chat, &userID)
+}
+
+// CreateChat создаёт приватный групповой чат. Создатель становится его администратором,
+// остальные участники входят по приглашениям
+func (s *ChatService) CreateChat(request models.DefaultRequest) (*models.ChatResponse, error) {
+	userID := request.User.ID
+	chat := &models.TChat{
+		CkAuthor: userID,
+		CrType:   models.ChatTypePrivate,
+		BaseModel: models.BaseModel{
+			CkCreate: userID.String(),
+			CkModify: userID.String(),
+		},
+	}
+	err := s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.CreateChat(chat, tx); err != nil {
+			return databaseError("Failed to create chat", err)
+		}
+		return s.addMember(tx, chat.CkId, userID, userID, true, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.chatResponse(chat, &userID)
+}
+
+// GetChats возвращает чаты пользователя, сначала с последними сообщениями
+func (s *ChatService) GetChats(userID uuid.UUID, offset, limit *int) ([]models.ChatResponse, int64, error) {
+	o, l := 0, 20
+	if offset != nil {
+		o = *offset
+	}
+	if limit != nil {
+		l = min(*limit, chatPageMax)
+	}
+	rows, total, err := s.repo.GetUserChats(userID, o, l)
+	if err != nil {
+		return nil, 0, databaseError("Failed to get chats", err)
+	}
+	ids := make([]uuid.UUID, 0, len(rows))
+	var direct []uuid.UUID
+	for _, row := range rows {
+		ids = append(ids, row.CkId)
+		if row.ClDirect {
+			direct = append(direct, row.CkId)
+		}
+	}
+	counts, err := s.repo.CountChatMembers(ids)
+	if err != nil {
+		return nil, 0, databaseError("Failed to count chat members", err)
+	}
+	peers, err := s.directPeers(direct, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := make([]models.ChatResponse, 0, len(rows))
+	for i := range rows {
+		res := chatBase(&rows[i].TChat)
+		res.Members = counts[rows[i].CkId]
+		res.IsMember = true
+		res.Peer = peers[rows[i].CkId]
+		res.LastMessageAt = rows[i].CtLastMessage
+		result = append(result, res)
+	}
+	// роли в чате загружаются отдельно, чтобы не усложнять выборку списка
+	for i := range result {
+		member, err := s.repo.GetChatMember(result[i].ID, userID)
+		if err == nil {
+			result[i].IsAdmin, result[i].IsModerator = member.ClAdmin, member.ClModerator
+		}
+	}
+	return result, total, nil
+}
+
+// GetChat возвращает чат, доступный пользователю
+func (s *ChatService) GetChat(chatID uuid.UUID, request models.DefaultRequest) (*models.ChatResponse, error) {
+	chat, _, err := s.readableChat(chatID, viewerID(request.User))
+	if err != nil {
+		return nil, err
+	}
+	return s.chatResponse(chat, viewerID(request.User))
+}
+
+// JoinChat добавляет пользователя в публичный чат или, по приглашению, в приватный.
+// Забаненный пользователь войти не может; повторный вход ничего не меняет. Попытки входа
+// по приглашению ограничены chatJoinAttemptsMax за chatJoinAttemptWindow
+func (s *ChatService) JoinChat(chatID uuid.UUID, request models.ChatJoinRequest) (*models.ChatResponse, error) {
+	chat, err := s.getChat(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.ClDirect {
+		return nil, &ServiceError{Code: "FORBIDDEN", Message: "Direct chat cannot be joined"}
+	}
+	userID := request.User.ID
+	if _, err := s.repo.GetChatMember(chat.CkId, userID); err == nil {
+		return s.chatResponse(chat, &userID)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, databaseError("Failed to get chat member", err)
+	}
+	if err := s.checkRestriction(nil, chat.CkId, userID, models.ChatUserBanTypeBan); err != nil {
+		return nil, err
+	}
+	var invite *models.TChatCredentials
+	key := chatJoinKey{chat: chat.CkId, user: userID}
+	if chat.CrType != models.ChatTypePublic {
+		if request.Credentials == nil || *request.Credentials == "" {
+			return nil, &ServiceError{Code: "FORBIDDEN", Message: "Private chat can be joined only by invitation"}
+		}
+		if !s.joins.attempt(key, time.Now()) {
+			return nil, &ServiceError{Code: "TOO_MANY_REQUESTS", Message: "Too many attempts to join the chat, try again later"}
+		}
+		invites, err := s.repo.GetChatInvites(chat.CkId)
+		if err != nil {
+			return nil, databaseError("Failed to get chat invites", err)
+		}
+		if invite = matchChatInvite(invites, *request.Credentials, time.Now()); invite == nil {
+			return nil, &ServiceError{Code: "FORBIDDEN", Message: "Invalid or expired invitation"}
+		}
+	}
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		// бан мог появиться после проверки выше
+		if err := s.checkRestriction(tx, chat.CkId, userID, models.ChatUserBanTypeBan); err != nil {
+			return err
+		}
+		if invite != nil && invite.ClOneTime {
+			consumed, err := s.repo.ConsumeChatCredentials(invite.CkId, userID.String(), tx)
+			if err != nil {
+				return databaseError("Failed to use chat invite", err)
+			}
+			if !consumed {
+				return &ServiceError{Code: "FORBIDDEN", Message: "Invalid or expired invitation"}
+			}
+		}
+		return s.addMember(tx, chat.CkId, userID, userID, false, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if invite != nil {
+		s.joins.reset(key)
+	}
+	return s.chatResponse(chat, &userID)
+}
+
+// LeaveChat удаляет пользователя из чата. Из личного чата выйти нельзя
+func (s *ChatService) LeaveChat(chatID uuid.UUID, request models.DefaultRequest) (bool, error) {
+	chat, err := s.getChat(chatID)
+	if err != nil {
+		return false, err
+	}
+	if chat.ClDirect {
+		return false, &ServiceError{Code: "FORBIDDEN", Message: "Direct chat cannot be left"}
+	}
+	userID := request.User.ID
+	var left bool
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		left, err = s.repo.RemoveChatMember(chat.CkId, userID, userID.String(), tx)
+		if err != nil {
+			return databaseError("Failed to leave chat", err)
+		}
+		if !left {
+			return nil
+		}
+		if err := s.events.Publish(tx, ChatTopic(chat.CkId), EventChatMemberLeft, map[string]any{"user_id": userID}); err != nil {
+			return err
+		}
+		// публичный чат остаётся доступным для чтения и после выхода
+		if chat.CrType == models.ChatTypePublic {
+			return nil
+		}
+		return s.events.Revoke(tx, ChatTopic(chat.CkId), userID)
+	})
+	return left, err
+}
+
+// GetChatMembers возвращает участников чата, доступного пользователю
+func (s *ChatService) GetChatMembers(chatID uuid.UUID, request models.DefaultRequest) ([]models.ChatMemberResponse, error) {
+	if _, _, err := s.readableChat(chatID, viewerID(request.User)); err != nil {
+		return nil, err
+	}
+	members, err := s.repo.GetChatMembers(chatID)
+	if err != nil {
+		return nil, databaseError("Failed to get chat members", err)
+	}
+	ids := make([]uuid.UUID, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.CkUser)
+	}
+	authors, err := s.authors(ids)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.ChatMemberResponse, 0, len(members))
+	for _, member := range members {
+		result = append(result, models.ChatMemberResponse{
+			User:        authors[member.CkUser],
+			IsAdmin:     member.ClAdmin,
+			IsModerator: member.ClModerator,
+			JoinedAt:    member.CtCreate,
+		})
+	}
+	return result, nil
+}
+
+// GetChatMessages возвращает страницу истории чата, от новых сообщений к старым
+func (s *ChatService) GetChatMessages(chatID uuid.UUID, request models.ChatMessagesRequest) (*models.ChatMessagesResponse, error) {
+	viewer := viewerID(request.User)
+	if _, _, err := s.readableChat(chatID, viewer); err != nil {
+		return nil, err
+	}
+	limit := chatMessagesPage
+	if request.Limit != nil && *request.Limit > 0 {
+		limit = min(*request.Limit, chatPageMax)
+	}
+	messages, err := s.repo.GetChatMessages(repository.ChatMessageQuery{
+		Chat:   chatID,
+		Before: request.Cursor,
+		Limit:  limit + 1, // лишнее сообщение показывает, что есть следующая страница
+	})
+	if err != nil {
+		return nil, databaseError("Failed to get chat messages", err)
+	}
+	res := &models.ChatMessagesResponse{}
+	if len(messages) > limit {
+		messages = messages[:limit]
+		last := messages[limit-1].CkId
+		res.HasMore, res.NextCursor = true, &last
+	}
+	ids := make([]uuid.UUID, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.CkAuthor)
+	}
+	authors, err := s.authors(ids)
+	if err != nil {
+		return nil, err
+	}
+	res.Messages = make([]models.ChatMessageResponse, 0, len(messages))
+	for i := range messages {
+		res.Messages = append(res.Messages, chatMessageResponse(&messages[i], authors[messages[i].CkAuthor], viewer))
+	}
+	return res, nil
+}
+
+// SendChatMessage отправляет сообщение или ответ на сообщение того же чата.
+// В публичном чате отправитель становится участником
+func (s *ChatService) SendChatMessage(chatID uuid.UUID, request models.ChatMessageCreateRequest) (*models.ChatMessageResponse, error) {
+	content := strings.TrimSpace(request.Content)
+	if content == "" {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Message content is required"}
+	}
+	if utf8.RuneCountInString(content) > chatMessageMaxLength {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Message is too long"}
+	}
+	userID := request.User.ID
+	chat, member, err := s.readableChat(chatID, &userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRestriction(nil, chat.CkId, userID, models.ChatUserBanTypeBan, models.ChatUserBanTypeMute); err != nil {
+		return nil, err
+	}
+	if request.ParentID != nil {
+		parent, err := s.repo.GetChatMessage(*request.ParentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && parent.CkChat != chat.CkId) {
+			return nil, &ServiceError{Code: "NOT_FOUND", Message: "Parent message not found"}
+		}
+		if err != nil {
+			return nil, databaseError("Failed to get parent message", err)
+		}
+	}
+	message := models.TChatMessage{
+		CkChat:    chat.CkId,
+		CkAuthor:  userID,
+		CvContent: content,
+		CkParent:  request.ParentID,
+		BaseModel: models.BaseModel{
+			CkCreate: userID.String(),
+			CkModify: userID.String(),
+		},
+	}
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		// бан или мут мог появиться после проверки выше
+		if err := s.checkRestriction(tx, chat.CkId, userID, models.ChatUserBanTypeBan, models.ChatUserBanTypeMute); err != nil {
+			return err
+		}
+		if member == nil {
+			if err := s.addMember(tx, chat.CkId, userID, userID, false, true); err != nil {
+				return err
+			}
+		}
+		if err := s.repo.CreateChatMessage(&message, tx); err != nil {
+			return databaseError("Failed to create chat message", err)
+		}
+		return s.events.Publish(tx, ChatTopic(chat.CkId), EventChatMessage, map[string]any{
+			"message_id": message.CkId,
+			"parent_id":  message.CkParent,
+			"author_id":  userID,
+			"text":       excerpt(content),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	authors, err := s.authors([]uuid.UUID{userID})
+	if err != nil {
+		return nil, err
+	}
+	res := chatMessageResponse(&message, authors[userID], &userID)
+	return &res, nil
+}
+
+// LikeChatMessage ставит лайк сообщению или меняет его тип
+func (s *ChatService) LikeChatMessage(messageID uuid.UUID, request models.ChatMessageLikeRequest) (bool, error) {
+	likeType := chatDefaultLike
+	if request.Type != nil && *request.Type != "" {
+		likeType = *request.Type
+	}
+	if _, err := s.parier.repo.GetLikeTypeByID(likeType); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, &ServiceError{Code: "VALIDATION_ERROR", Message: "Unknown like type " + likeType}
+		}
+		return false, databaseError("Failed to get like type", err)
+	}
+	userID := request.User.ID
+	message, err := s.readableMessage(messageID, &userID)
+	if err != nil {
+		return false, err
+	}
+	if err := s.checkRestriction(nil, message.CkChat, userID, models.ChatUserBanTypeBan); err != nil {
+		return false, err
+	}
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		like := models.TChatMessageLike{
+			CkMessage: message.CkId,
+			CkAuthor:  userID,
+			CkType:    likeType,
+			BaseModel: models.BaseModel{
+				CkCreate: userID.String(),
+				CkModify: userID.String(),
+			},
+		}
+		if err := s.repo.SetChatMessageLike(&like, tx); err != nil {
+			return databaseError("Failed to like chat message", err)
+		}
+		return s.events.Publish(tx, ChatTopic(message.CkChat), EventChatMessageLiked, map[string]any{
+			"message_id": message.CkId,
+			"user_id":    userID,
+			"type":       likeType,
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnlikeChatMessage снимает лайк с сообщения
+func (s *ChatService) UnlikeChatMessage(messageID uuid.UUID, request models.DefaultRequest) (bool, error) {
+	userID := request.User.ID
+	message, err := s.readableMessage(messageID, &userID)
+	if err != nil {
+		return false, err
+	}
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.RemoveChatMessageLike(message.CkId, userID, tx); err != nil {
+			return databaseError("Failed to unlike chat message", err)
+		}
+		return s.events.Publish(tx, ChatTopic(message.CkChat), EventChatMessageUnliked, map[string]any{
+			"message_id": message.CkId,
+			"user_id":    userID,
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// authorizeTopic пускает в тему chat:<id> тех, кто может читать чат
+// authorizeTopic пускает в поток событий чата тех, кто может его читать, кроме забаненных
+func (s *ChatService) authorizeTopic(user *uuid.UUID, chatID uuid.UUID) error {
+	if _, _, err := s.readableChat(chatID, user); err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+	return s.checkRestriction(nil, chatID, *user, models.ChatUserBanTypeBan)
+}
+
+func (s *ChatService) getChat(chatID uuid.UUID) (*models.TChat, error) {
+	chat, err := s.repo.GetChatByID(chatID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &ServiceError{Code: "NOT_FOUND", Message: "Chat not found"}
+	}
+	if err != nil {
+		return nil, databaseError("Failed to get chat", err)
+	}
+	return chat, nil
+}
+
+// readableChat возвращает чат и участие в нём пользователя (nil - не участник).
+// Публичный чат читают все, приватный - только участники; для остальных он не существует
+func (s *ChatService) readableChat(chatID uuid.UUID, userID *uuid.UUID) (*models.TChat, *models.TChatUser, error) {
+	chat, err := s.getChat(chatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var member *models.TChatUser
+	if userID != nil {
+		member, err = s.repo.GetChatMember(chat.CkId, *userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			member = nil
+		} else if err != nil {
+			return nil, nil, databaseError("Failed to get chat member", err)
+		}
+	}
+	if chat.CrType != models.ChatTypePublic && member == nil {
+		return nil, nil, &ServiceError{Code: "NOT_FOUND", Message: "Chat not found"}
+	}
+	return chat, member, nil
+}
+
+func (s *ChatService) readableMessage(messageID uuid.UUID, userID *uuid.UUID) (*models.TChatMessage, error) {
+	message, err := s.repo.GetChatMessage(messageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &ServiceError{Code: "NOT_FOUND", Message: "Message not found"}
+	}
+	if err != nil {
+		return nil, databaseError("Failed to get chat message", err)
+	}
+	if _, _, err := s.readableChat(message.CkChat, userID); err != nil {
+		var serviceErr *ServiceError
+		if errors.As(err, &serviceErr) && serviceErr.Code == "NOT_FOUND" {
+			return nil, &ServiceError{Code: "NOT_FOUND", Message: "Message not found"}
+		}
+		return nil, err
+	}
+	return message, nil
+}
+
+// addMember добавляет участника в транзакции tx; admin - администратором чата,
+// joined сообщает о входе подписчикам чата
+func (s *ChatService) addMember(tx *gorm.DB, chatID, userID, actor uuid.UUID, admin, joined bool) error {
+	member := models.TChatUser{
+		CkChat:  chatID,
+		CkUser:  userID,
+		ClAdmin: admin,
+		BaseModel: models.BaseModel{
+			CkCreate: actor.String(),
+			CkModify: actor.String(),
+		},
+	}
+	if err := s.repo.AddChatMember(&member, tx); err != nil {
+		return databaseError("Failed to add chat member", err)
+	}
+	if !joined {
+		return nil
+	}
+	return s.events.Publish(tx, ChatTopic(chatID), EventChatMemberJoined, map[string]any{"user_id": userID})
+}
+
+func (s *ChatService) chatResponse(chat *models.TChat, viewer *uuid.UUID) (*models.ChatResponse, error) {
+	res := chatBase(chat)
+	counts, err := s.repo.CountChatMembers([]uuid.UUID{chat.CkId})
+	if err != nil {
+		return nil, databaseError("Failed to count chat members", err)
+	}
+	res.Members = counts[chat.CkId]
+	res.LastMessageAt, err = s.repo.GetLastMessageTime(chat.CkId)
+	if err != nil {
+		return nil, databaseError("Failed to get last chat message", err)
+	}
+	if viewer == nil {
+		return &res, nil
+	}
+	member, err := s.repo.GetChatMember(chat.CkId, *viewer)
+	if err == nil {
+		res.IsMember, res.IsAdmin, res.IsModerator = true, member.ClAdmin, member.ClModerator
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, databaseError("Failed to get chat member", err)
+	}
+	ban, err := s.activeRestriction(nil, chat.CkId, *viewer)
+	if err != nil {
+		return nil, err
+	}
+	if ban != nil {
+		info := chatBanInfo(ban)
+		res.Restriction = &info
+	}
+	if chat.ClDirect {
+		peers, err := s.directPeers([]uuid.UUID{chat.CkId}, *viewer)
+		if err != nil {
+			return nil, err
+		}
+		res.Peer = peers[chat.CkId]
+	}
+	return &res, nil
+}
+
+// directPeers возвращает собеседников пользователя в личных чатах
+func (s *ChatService) directPeers(chatIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID]*models.AuthorResponse, error) {
+	peers, err := s.repo.GetDirectPeers(chatIDs, userID)
+	if err != nil {
+		return nil, databaseError("Failed to get chat peers", err)
+	}
+	ids := make([]uuid.UUID, 0, len(peers))
+	for _, peer := range peers {
+		ids = append(ids, peer.CkUser)
+	}
+	authors, err := s.authors(ids)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[uuid.UUID]*models.AuthorResponse, len(peers))
+	for _, peer := range peers {
+		author := authors[peer.CkUser]
+		result[peer.CkChat] = &author
+	}
+	return result, nil
+}
+
+// authors возвращает профили пользователей; ID повторяются
+func (s *ChatService) authors(ids []uuid.UUID) (map[uuid.UUID]models.AuthorResponse, error) {
+	unique := make([]uuid.UUID, 0, len(ids))
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	users, err := s.repo.GetCommentAuthors(unique)
+	if err != nil {
+		return nil, databaseError("Failed to get users", err)
+	}
+	result := make(map[uuid.UUID]models.AuthorResponse, len(unique))
+	for _, id := range unique {
+		result[id] = models.AuthorResponse{ID: id}
+	}
+	for i := range users {
+		result[users[i].CkId] = s.parier.authorResponse(users[i].CkId, &users[i])
+	}
+	return result, nil
+}
+
+func chatBase(chat *models.TChat) models.ChatResponse {
+	return models.ChatResponse{
+		ID:        chat.CkId,
+		Type:      chat.CrType,
+		BetID:     chat.CkBet,
+		Direct:    chat.ClDirect,
+		CreatedAt: chat.CtCreate,
+	}
+}
+
+// chatMessageResponse собирает сообщение с числом лайков по типам и лайком viewer
+func chatMessageResponse(message *models.TChatMessage, author models.AuthorResponse, viewer *uuid.UUID) models.ChatMessageResponse {
+	res := models.ChatMessageResponse{
+		ID:        message.CkId,
+		ChatID:    message.CkChat,
+		ParentID:  message.CkParent,
+		Author:    author,
+		Content:   message.CvContent,
+		Likes:     make(map[string]int64),
+		CreatedAt: message.CtCreate,
+	}
+	for _, like := range message.Likes {
+		if like.CtDelete != nil {
+			continue
+		}
+		res.Likes[like.CkType]++
+		if viewer != nil && like.CkAuthor == *viewer {
+			res.MyLike = &like.CkType
+		}
+	}
+	return res
+}
+
+func viewerID(user *models.User) *uuid.UUID {
+	if user == nil {
+		return nil
+	}
+	return &user.ID
+}
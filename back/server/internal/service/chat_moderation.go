@@ -0,0 +1,540 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"parier-server/internal/models"
+	"parier-server/internal/util"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// chatInvitePasswordMin - минимальная длина пароля приглашения
+	chatInvitePasswordMin = 8
+	// chatBanReasonMaxLength - длина причины бана, в символах
+	chatBanReasonMaxLength = 500
+	// chatPasswordInvitesMax - действующих приглашений по паролю в одном чате; при входе
+	// пароль сверяется только с этим числом самых новых, чтобы не считать bcrypt без конца
+	chatPasswordInvitesMax = 5
+	// chatJoinAttemptsMax - попыток входа по приглашению одного пользователя в один чат за chatJoinAttemptWindow
+	chatJoinAttemptsMax   = 5
+	chatJoinAttemptWindow = 15 * time.Minute
+	// chatJoinAttemptsPurge - с какого числа счётчиков попыток удаляются закончившиеся окна
+	chatJoinAttemptsPurge = 1024
+)
+
+// SetChatModerator назначает или снимает модератора. Доступно администраторам чата
+// и модераторам сайта (UPDATE на t_chat_user_ban)
+func (s *ChatService) SetChatModerator(chatID uuid.UUID, request models.ChatModeratorRequest, moderator bool, actor AuditActor) (bool, error) {
+	chat, staff, err := s.staffChat(chatID, request.User.ID, moderator)
+	if err != nil {
+		return false, err
+	}
+	if !moderator && !staff.ClAdmin {
+		return false, &ServiceError{Code: "FORBIDDEN", Message: "Only chat admins can appoint moderators"}
+	}
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		updated, err := s.repo.SetChatMemberModerator(chat.CkId, request.UserID, request.Moderator, request.User.ID.String(), tx)
+		if err != nil {
+			return databaseError("Failed to update chat member", err)
+		}
+		if !updated {
+			return &ServiceError{Code: "NOT_FOUND", Message: "Chat member not found"}
+		}
+		err = s.events.Publish(tx, ChatTopic(chat.CkId), EventChatMemberRole, map[string]any{
+			"user_id":   request.UserID,
+			"moderator": request.Moderator,
+		})
+		if err != nil {
+			return err
+		}
+		return s.audit.Record(tx, actor, AuditEntry{
+			Action:     AuditChatModerator,
+			EntityType: "t_chat_user",
+			EntityID:   chat.CkId.String() + ":" + request.UserID.String(),
+			Before:     map[string]any{"cl_moderator": !request.Moderator},
+			After:      map[string]any{"cl_moderator": request.Moderator},
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// BanChatUser банит (BAN: выход из чата, запрет входа, сообщений и лайков) или мутит
+// (MUTE: запрет сообщений) пользователя до until. Новое ограничение заменяет прежнее.
+// Модератор чата не может ограничить администратора или другого модератора
+func (s *ChatService) BanChatUser(chatID uuid.UUID, request models.ChatBanRequest, moderator bool, actor AuditActor) (*models.ChatBanResponse, error) {
+	if request.Type != models.ChatUserBanTypeBan && request.Type != models.ChatUserBanTypeMute {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Type must be BAN or MUTE"}
+	}
+	now := time.Now()
+	if request.Until != nil && !request.Until.After(now) {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Until must be in the future"}
+	}
+	if request.Reason != nil && utf8.RuneCountInString(*request.Reason) > chatBanReasonMaxLength {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Reason is too long"}
+	}
+	userID := request.User.ID
+	if request.UserID == userID {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Cannot ban yourself"}
+	}
+	chat, staff, err := s.staffChat(chatID, userID, moderator)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repoUser.GetUserByID(request.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Code: "NOT_FOUND", Message: "User not found"}
+		}
+		return nil, databaseError("Failed to get user", err)
+	}
+	target, err := s.repo.GetChatMember(chat.CkId, request.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		target = nil
+	} else if err != nil {
+		return nil, databaseError("Failed to get chat member", err)
+	}
+	if err := canRestrict(staff, target, moderator); err != nil {
+		return nil, err
+	}
+	previous, err := s.repo.GetChatUserBan(chat.CkId, request.UserID, nil)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		previous = nil
+	} else if err != nil {
+		return nil, databaseError("Failed to get chat ban", err)
+	}
+	ban := &models.TChatUserBan{
+		CkChat:   chat.CkId,
+		CkUser:   request.UserID,
+		CrType:   request.Type,
+		CtStart:  now,
+		CtEnd:    request.Until,
+		CvReason: request.Reason,
+		BaseModel: models.BaseModel{
+			CkCreate: userID.String(),
+			CkModify: userID.String(),
+		},
+	}
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.SetChatUserBan(ban, tx); err != nil {
+			return databaseError("Failed to ban chat user", err)
+		}
+		// участник удаляется и без прочитанного участия: вход мог завершиться после проверки
+		if ban.CrType == models.ChatUserBanTypeBan {
+			if _, err := s.repo.RemoveChatMember(chat.CkId, request.UserID, userID.String(), tx); err != nil {
+				return databaseError("Failed to remove chat member", err)
+			}
+		}
+		kind := EventChatMemberMuted
+		if ban.CrType == models.ChatUserBanTypeBan {
+			kind = EventChatMemberBanned
+		}
+		err := s.events.Publish(tx, ChatTopic(chat.CkId), kind, map[string]any{
+			"user_id": request.UserID,
+			"until":   ban.CtEnd,
+		})
+		if err != nil {
+			return err
+		}
+		if ban.CrType == models.ChatUserBanTypeBan {
+			// открытые подписки на чат получали бы сообщения и после бана
+			if err := s.events.Revoke(tx, ChatTopic(chat.CkId), request.UserID); err != nil {
+				return err
+			}
+		}
+		entry := AuditEntry{
+			Action:     AuditChatBan,
+			EntityType: "t_chat_user_ban",
+			EntityID:   chat.CkId.String() + ":" + request.UserID.String(),
+			After:      chatBanInfo(ban),
+		}
+		if previous != nil && chatPeriodActive(previous.CtStart, previous.CtEnd, now) {
+			entry.Before = chatBanInfo(previous)
+		}
+		return s.audit.Record(tx, actor, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	authors, err := s.authors([]uuid.UUID{request.UserID})
+	if err != nil {
+		return nil, err
+	}
+	return &models.ChatBanResponse{User: authors[request.UserID], ChatBanInfo: chatBanInfo(ban), Active: true}, nil
+}
+
+// UnbanChatUser снимает бан или мут пользователя
+func (s *ChatService) UnbanChatUser(chatID uuid.UUID, request models.ChatUnbanRequest, moderator bool, actor AuditActor) (bool, error) {
+	chat, _, err := s.staffChat(chatID, request.User.ID, moderator)
+	if err != nil {
+		return false, err
+	}
+	ban, err := s.repo.GetChatUserBan(chat.CkId, request.UserID, nil)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, &ServiceError{Code: "NOT_FOUND", Message: "Chat ban not found"}
+	}
+	if err != nil {
+		return false, databaseError("Failed to get chat ban", err)
+	}
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		removed, err := s.repo.RemoveChatUserBan(chat.CkId, request.UserID, request.User.ID.String(), tx)
+		if err != nil {
+			return databaseError("Failed to unban chat user", err)
+		}
+		if !removed {
+			return &ServiceError{Code: "NOT_FOUND", Message: "Chat ban not found"}
+		}
+		err = s.events.Publish(tx, ChatTopic(chat.CkId), EventChatMemberUnbanned, map[string]any{"user_id": request.UserID})
+		if err != nil {
+			return err
+		}
+		return s.audit.Record(tx, actor, AuditEntry{
+			Action:     AuditChatUnban,
+			EntityType: "t_chat_user_ban",
+			EntityID:   chat.CkId.String() + ":" + request.UserID.String(),
+			Before:     chatBanInfo(ban),
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetChatBans возвращает действующие и будущие баны и муты чата
+func (s *ChatService) GetChatBans(chatID uuid.UUID, request models.DefaultRequest, moderator bool) ([]models.ChatBanResponse, error) {
+	chat, _, err := s.staffChat(chatID, request.User.ID, moderator)
+	if err != nil {
+		return nil, err
+	}
+	bans, err := s.repo.GetChatUserBans(chat.CkId)
+	if err != nil {
+		return nil, databaseError("Failed to get chat bans", err)
+	}
+	ids := make([]uuid.UUID, 0, len(bans))
+	for _, ban := range bans {
+		ids = append(ids, ban.CkUser)
+	}
+	authors, err := s.authors(ids)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := make([]models.ChatBanResponse, 0, len(bans))
+	for i := range bans {
+		result = append(result, models.ChatBanResponse{
+			User:        authors[bans[i].CkUser],
+			ChatBanInfo: chatBanInfo(&bans[i]),
+			Active:      chatPeriodActive(bans[i].CtStart, bans[i].CtEnd, now),
+		})
+	}
+	return result, nil
+}
+
+// CreateChatInvite создаёт приглашение в приватный групповой чат. Секрет TOKEN и API_KEY
+// возвращается один раз, в базе хранится только его хеш; пароль хранится как bcrypt
+func (s *ChatService) CreateChatInvite(chatID uuid.UUID, request models.ChatInviteCreateRequest, moderator bool) (*models.ChatInviteResponse, error) {
+	chat, _, err := s.staffChat(chatID, request.User.ID, moderator)
+	if err != nil {
+		return nil, err
+	}
+	if chat.CrType == models.ChatTypePublic || chat.ClDirect {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Invitations are only for private group chats"}
+	}
+	kind := models.ChatCredentialsTypeToken
+	if request.Type != nil {
+		kind = *request.Type
+	}
+	now := time.Now()
+	invite := &models.TChatCredentials{
+		CkChat:    chat.CkId,
+		CrType:    kind,
+		CtStart:   now,
+		CtEnd:     request.Until,
+		ClOneTime: request.OneTime,
+		BaseModel: models.BaseModel{
+			CkCreate: request.User.ID.String(),
+			CkModify: request.User.ID.String(),
+		},
+	}
+	if request.Start != nil {
+		invite.CtStart = *request.Start
+	}
+	if request.Until != nil && (!request.Until.After(invite.CtStart) || !request.Until.After(now)) {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Until must be after start and in the future"}
+	}
+	var secret *string
+	switch kind {
+	case models.ChatCredentialsTypeToken, models.ChatCredentialsTypeAPIKey:
+		value, err := newChatInviteSecret()
+		if err != nil {
+			return nil, err
+		}
+		invite.CvCredentials = util.HashAPIToken(value)
+		secret = &value
+	case models.ChatCredentialsTypePassword:
+		if request.Password == nil || utf8.RuneCountInString(*request.Password) < chatInvitePasswordMin {
+			return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Password must be at least 8 characters"}
+		}
+		active, err := s.repo.CountActiveChatInvites(chat.CkId, models.ChatCredentialsTypePassword)
+		if err != nil {
+			return nil, databaseError("Failed to count chat invites", err)
+		}
+		if active >= chatPasswordInvitesMax {
+			return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Too many password invitations, revoke one first"}
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(*request.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Invalid password"}
+		}
+		invite.CvCredentials = string(hash)
+	default:
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Type must be TOKEN, API_KEY or PASSWORD"}
+	}
+	if err := s.repo.CreateChatCredentials(invite); err != nil {
+		return nil, databaseError("Failed to create chat invite", err)
+	}
+	res := chatInviteResponse(invite, now)
+	res.Credentials = secret
+	return &res, nil
+}
+
+// GetChatInvites возвращает неотозванные приглашения чата, в том числе истёкшие
+func (s *ChatService) GetChatInvites(chatID uuid.UUID, request models.DefaultRequest, moderator bool) ([]models.ChatInviteResponse, error) {
+	chat, _, err := s.staffChat(chatID, request.User.ID, moderator)
+	if err != nil {
+		return nil, err
+	}
+	invites, err := s.repo.GetChatInvites(chat.CkId)
+	if err != nil {
+		return nil, databaseError("Failed to get chat invites", err)
+	}
+	now := time.Now()
+	result := make([]models.ChatInviteResponse, 0, len(invites))
+	for i := range invites {
+		result = append(result, chatInviteResponse(&invites[i], now))
+	}
+	return result, nil
+}
+
+// RevokeChatInvite отзывает приглашение
+func (s *ChatService) RevokeChatInvite(inviteID uuid.UUID, request models.DefaultRequest, moderator bool) (bool, error) {
+	invite, err := s.repo.GetChatCredentialsByID(inviteID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, &ServiceError{Code: "NOT_FOUND", Message: "Invitation not found"}
+	}
+	if err != nil {
+		return false, databaseError("Failed to get chat invite", err)
+	}
+	if _, _, err := s.staffChat(invite.CkChat, request.User.ID, moderator); err != nil {
+		return false, err
+	}
+	revoked, err := s.repo.ConsumeChatCredentials(invite.CkId, request.User.ID.String(), nil)
+	if err != nil {
+		return false, databaseError("Failed to revoke chat invite", err)
+	}
+	if !revoked {
+		return false, &ServiceError{Code: "NOT_FOUND", Message: "Invitation not found"}
+	}
+	return true, nil
+}
+
+// staffChat возвращает чат, если пользователь - его администратор или модератор, либо
+// модератор сайта. Для модератора сайта участие может быть nil
+func (s *ChatService) staffChat(chatID, userID uuid.UUID, moderator bool) (*models.TChat, *models.TChatUser, error) {
+	chat, err := s.getChat(chatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	member, err := s.repo.GetChatMember(chat.CkId, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		member = nil
+	} else if err != nil {
+		return nil, nil, databaseError("Failed to get chat member", err)
+	}
+	if moderator {
+		return chat, member, nil
+	}
+	if member == nil && chat.CrType != models.ChatTypePublic {
+		// как и в readableChat, чужой приватный чат для пользователя не существует
+		return nil, nil, &ServiceError{Code: "NOT_FOUND", Message: "Chat not found"}
+	}
+	if chat.ClDirect || member == nil || !(member.ClAdmin || member.ClModerator) {
+		return nil, nil, &ServiceError{Code: "FORBIDDEN", Message: "Only chat admins and moderators can do this"}
+	}
+	return chat, member, nil
+}
+
+// checkRestriction возвращает FORBIDDEN, если у пользователя действует ограничение одного из типов.
+// В транзакции tx ограничение проверяется повторно и блокируется до конца записи
+func (s *ChatService) checkRestriction(tx *gorm.DB, chatID, userID uuid.UUID, types ...models.ChatUserBanType) error {
+	ban, err := s.activeRestriction(tx, chatID, userID)
+	if err != nil || ban == nil {
+		return err
+	}
+	for _, kind := range types {
+		if ban.CrType != kind {
+			continue
+		}
+		message := "You are banned in this chat"
+		if kind == models.ChatUserBanTypeMute {
+			message = "You are muted in this chat"
+		}
+		if ban.CtEnd != nil {
+			message += " until " + ban.CtEnd.UTC().Format(time.RFC3339)
+		}
+		return &ServiceError{Code: "FORBIDDEN", Message: message}
+	}
+	return nil
+}
+
+// activeRestriction возвращает действующий сейчас бан или мут пользователя или nil
+func (s *ChatService) activeRestriction(tx *gorm.DB, chatID, userID uuid.UUID) (*models.TChatUserBan, error) {
+	ban, err := s.repo.GetChatUserBan(chatID, userID, tx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, databaseError("Failed to get chat ban", err)
+	}
+	if !chatPeriodActive(ban.CtStart, ban.CtEnd, time.Now()) {
+		return nil, nil
+	}
+	return ban, nil
+}
+
+// canRestrict проверяет, может ли staff ограничить target (nil - не участник чата).
+// Модератор сайта ограничивает любого; администратор чата - всех, кроме администраторов;
+// модератор чата - только обычных участников
+func canRestrict(staff, target *models.TChatUser, moderator bool) error {
+	if moderator || target == nil {
+		return nil
+	}
+	if target.ClAdmin || (target.ClModerator && !staff.ClAdmin) {
+		return &ServiceError{Code: "FORBIDDEN", Message: "Cannot ban a chat admin or moderator"}
+	}
+	return nil
+}
+
+// chatPeriodActive - действует ли период [start, end) в момент now; end nil - бессрочно
+func chatPeriodActive(start time.Time, end *time.Time, now time.Time) bool {
+	return !start.After(now) && (end == nil || end.After(now))
+}
+
+// matchChatInvite ищет действующее приглашение с секретом secret. Приглашения идут от новых
+// к старым; пароль сверяется не больше чем с chatPasswordInvitesMax из них
+func matchChatInvite(invites []models.TChatCredentials, secret string, now time.Time) *models.TChatCredentials {
+	hash := util.HashAPIToken(secret)
+	passwords := 0
+	for i := range invites {
+		invite := &invites[i]
+		if invite.CtDelete != nil || !chatPeriodActive(invite.CtStart, invite.CtEnd, now) {
+			continue
+		}
+		switch invite.CrType {
+		case models.ChatCredentialsTypeToken, models.ChatCredentialsTypeAPIKey:
+			if subtle.ConstantTimeCompare([]byte(invite.CvCredentials), []byte(hash)) == 1 {
+				return invite
+			}
+		case models.ChatCredentialsTypePassword:
+			if passwords++; passwords > chatPasswordInvitesMax {
+				continue
+			}
+			if bcrypt.CompareHashAndPassword([]byte(invite.CvCredentials), []byte(secret)) == nil {
+				return invite
+			}
+		}
+	}
+	return nil
+}
+
+// chatJoinKey - пользователь, входящий в чат по приглашению
+type chatJoinKey struct {
+	chat, user uuid.UUID
+}
+
+type chatJoinWindow struct {
+	start    time.Time
+	attempts int
+}
+
+// chatJoinAttempts ограничивает попытки входа по приглашению, чтобы пароль нельзя было
+// подобрать перебором. Счётчики хранятся в памяти экземпляра сервера
+type chatJoinAttempts struct {
+	mu      sync.Mutex
+	windows map[chatJoinKey]*chatJoinWindow
+}
+
+// attempt учитывает попытку входа; false - попытки в текущем окне исчерпаны
+func (a *chatJoinAttempts) attempt(key chatJoinKey, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.windows == nil {
+		a.windows = make(map[chatJoinKey]*chatJoinWindow)
+	}
+	window := a.windows[key]
+	if window == nil || now.Sub(window.start) >= chatJoinAttemptWindow {
+		if len(a.windows) >= chatJoinAttemptsPurge {
+			a.purge(now)
+		}
+		window = &chatJoinWindow{start: now}
+		a.windows[key] = window
+	}
+	if window.attempts >= chatJoinAttemptsMax {
+		return false
+	}
+	window.attempts++
+	return true
+}
+
+// reset забывает попытки после успешного входа
+func (a *chatJoinAttempts) reset(key chatJoinKey) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.windows, key)
+}
+
+// purge удаляет закончившиеся окна; вызывается под mu
+func (a *chatJoinAttempts) purge(now time.Time) {
+	for key, window := range a.windows {
+		if now.Sub(window.start) >= chatJoinAttemptWindow {
+			delete(a.windows, key)
+		}
+	}
+}
+
+func newChatInviteSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func chatBanInfo(ban *models.TChatUserBan) models.ChatBanInfo {
+	return models.ChatBanInfo{Type: ban.CrType, Reason: ban.CvReason, Start: ban.CtStart, Until: ban.CtEnd}
+}
+
+func chatInviteResponse(invite *models.TChatCredentials, now time.Time) models.ChatInviteResponse {
+	return models.ChatInviteResponse{
+		ID:        invite.CkId,
+		ChatID:    invite.CkChat,
+		Type:      invite.CrType,
+		OneTime:   invite.ClOneTime,
+		Start:     invite.CtStart,
+		Until:     invite.CtEnd,
+		Active:    invite.CtDelete == nil && chatPeriodActive(invite.CtStart, invite.CtEnd, now),
+		CreatedAt: invite.CtCreate,
+	}
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"parier-server/internal/config"
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+	"parier-server/internal/util"
+	"parier-server/internal/util/dbtest"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestChatMessageResponse(t *testing.T) {
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	removed := time.Now()
+	message := models.TChatMessage{
+		CkId:      uuid.New(),
+		CkChat:    uuid.New(),
+		CkAuthor:  alice,
+		CvContent: "hello",
+		Likes: []models.TChatMessageLike{
+			{CkAuthor: alice, CkType: "LIKE"},
+			{CkAuthor: bob, CkType: "FIRE"},
+			{CkAuthor: carol, CkType: "LIKE", BaseModel: models.BaseModel{CtDelete: &removed}},
+		},
+	}
+
+	res := chatMessageResponse(&message, models.AuthorResponse{ID: alice}, &bob)
+	if res.Likes["LIKE"] != 1 || res.Likes["FIRE"] != 1 || len(res.Likes) != 2 {
+		t.Errorf("likes must be counted by type without removed ones, got %v", res.Likes)
+	}
+	if res.MyLike == nil || *res.MyLike != "FIRE" {
+		t.Errorf("viewer like must be returned, got %v", res.MyLike)
+	}
+	if res := chatMessageResponse(&message, models.AuthorResponse{ID: alice}, nil); res.MyLike != nil {
+		t.Errorf("anonymous viewer must have no like, got %v", *res.MyLike)
+	}
+}
+
+func TestChatTopicAuthorization(t *testing.T) {
+	hub := NewEventHub(nil, config.EventsConfig{Buffer: 1, MaxTopics: 5})
+	open, private := uuid.New(), uuid.New()
+	hub.Authorize(TopicChat, func(user *uuid.UUID, id uuid.UUID) error {
+		if id == private {
+			return &ServiceError{Code: "NOT_FOUND", Message: "Chat not found"}
+		}
+		return nil
+	})
+	user := uuid.New()
+	sub := hub.Subscribe(&user)
+	defer sub.Close()
+
+	if err := sub.Add(ChatTopic(open)); err != nil {
+		t.Errorf("readable chat must be subscribable, got %v", err)
+	}
+	if err := sub.Add("bets", ChatTopic(private)); err == nil {
+		t.Error("unreadable chat must be rejected")
+	}
+	if topics := sub.Topics(); len(topics) != 1 || topics[0] != ChatTopic(open) {
+		t.Errorf("rejected subscription must not change topics, got %v", topics)
+	}
+}
+
+func TestChatTopicRevoke(t *testing.T) {
+	hub := NewEventHub(nil, config.EventsConfig{Buffer: 4, MaxTopics: 5})
+	chat := ChatTopic(uuid.New())
+	banned, member := uuid.New(), uuid.New()
+	bannedSub, memberSub := hub.Subscribe(&banned), hub.Subscribe(&member)
+	defer bannedSub.Close()
+	defer memberSub.Close()
+	for _, sub := range []*Subscription{bannedSub, memberSub} {
+		if err := sub.Add(chat); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// событие проходит через NOTIFY, поэтому ID пользователя приходит строкой
+	hub.dispatch(models.Event{Topic: chat, Type: EventTopicRevoked, Data: map[string]any{"user_id": banned.String()}})
+	hub.dispatch(models.Event{Topic: chat, Type: EventChatMessage})
+
+	if event := <-bannedSub.Events(); event.Type != EventTopicRevoked || event.Topic != chat {
+		t.Errorf("revoked user must be told about it, got %+v", event)
+	}
+	select {
+	case event := <-bannedSub.Events():
+		t.Errorf("revoked user must not receive chat events, got %+v", event)
+	default:
+	}
+	if topics := bannedSub.Topics(); len(topics) != 0 {
+		t.Errorf("revoked topic must be removed, got %v", topics)
+	}
+	if event := <-memberSub.Events(); event.Type != EventChatMessage {
+		t.Errorf("other members must keep the topic and not see the revocation, got %+v", event)
+	}
+}
+
+func TestChatPeriodActive(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Second), now.Add(time.Second)
+	cases := []struct {
+		name   string
+		start  time.Time
+		end    *time.Time
+		active bool
+	}{
+		{"starts now", now, &after, true},
+		{"ends now", before, &now, false},
+		{"ends a moment later", before, &after, true},
+		{"permanent", before, nil, true},
+		{"not started", after, nil, false},
+		{"expired", before.Add(-time.Hour), &before, false},
+	}
+	for _, c := range cases {
+		if got := chatPeriodActive(c.start, c.end, now); got != c.active {
+			t.Errorf("%s: active = %v, want %v", c.name, got, c.active)
+		}
+	}
+}
+
+func TestCanRestrict(t *testing.T) {
+	admin := &models.TChatUser{ClAdmin: true}
+	moderator := &models.TChatUser{ClModerator: true}
+	member := &models.TChatUser{}
+	cases := []struct {
+		name          string
+		staff, target *models.TChatUser
+		site, allowed bool
+	}{
+		{"moderator restricts member", moderator, member, false, true},
+		{"moderator restricts non-member", moderator, nil, false, true},
+		{"moderator restricts moderator", moderator, moderator, false, false},
+		{"moderator restricts admin", moderator, admin, false, false},
+		{"admin restricts moderator", admin, moderator, false, true},
+		{"admin restricts admin", admin, admin, false, false},
+		{"site moderator restricts admin", nil, admin, true, true},
+	}
+	for _, c := range cases {
+		if err := canRestrict(c.staff, c.target, c.site); (err == nil) != c.allowed {
+			t.Errorf("%s: got %v", c.name, err)
+		}
+	}
+}
+
+func TestMatchChatInvite(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	hour := now.Add(time.Hour)
+	password, err := bcrypt.GenerateFromPassword([]byte("open sesame"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked := now.Add(-time.Minute)
+	invites := []models.TChatCredentials{
+		{CkId: uuid.New(), CrType: models.ChatCredentialsTypeToken, CvCredentials: util.HashAPIToken("expired"), CtStart: now.Add(-time.Hour), CtEnd: &now},
+		{CkId: uuid.New(), CrType: models.ChatCredentialsTypeToken, CvCredentials: util.HashAPIToken("later"), CtStart: hour},
+		{CkId: uuid.New(), CrType: models.ChatCredentialsTypeAPIKey, CvCredentials: util.HashAPIToken("revoked"), CtStart: now, BaseModel: models.BaseModel{CtDelete: &revoked}},
+		{CkId: uuid.New(), CrType: models.ChatCredentialsTypeToken, CvCredentials: util.HashAPIToken("valid"), CtStart: now, CtEnd: &hour, ClOneTime: true},
+		{CkId: uuid.New(), CrType: models.ChatCredentialsTypePassword, CvCredentials: string(password), CtStart: now.Add(-time.Hour)},
+	}
+
+	if invite := matchChatInvite(invites, "valid", now); invite == nil || invite.CkId != invites[3].CkId {
+		t.Errorf("invite must be valid from its start, got %v", invite)
+	}
+	if invite := matchChatInvite(invites, "open sesame", now); invite == nil || invite.CkId != invites[4].CkId {
+		t.Errorf("password must match its bcrypt hash, got %v", invite)
+	}
+	for _, secret := range []string{"expired", "later", "revoked", "wrong", util.HashAPIToken("valid")} {
+		if invite := matchChatInvite(invites, secret, now); invite != nil {
+			t.Errorf("credentials %q must not admit, got invite %s", secret, invite.CkId)
+		}
+	}
+	if invite := matchChatInvite(invites, "valid", hour); invite != nil {
+		t.Error("invite must expire at its end")
+	}
+
+	other, err := bcrypt.GenerateFromPassword([]byte("another one"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var passwords []models.TChatCredentials
+	for range chatPasswordInvitesMax {
+		passwords = append(passwords, models.TChatCredentials{CkId: uuid.New(), CrType: models.ChatCredentialsTypePassword, CvCredentials: string(other), CtStart: now})
+	}
+	passwords = append(passwords, invites[4])
+	if invite := matchChatInvite(passwords, "open sesame", now); invite != nil {
+		t.Error("password must be checked only against the newest invitations")
+	}
+}
+
+func TestChatJoinAttempts(t *testing.T) {
+	var attempts chatJoinAttempts
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	key, other := chatJoinKey{chat: uuid.New(), user: uuid.New()}, chatJoinKey{chat: uuid.New(), user: uuid.New()}
+
+	for i := range chatJoinAttemptsMax {
+		if !attempts.attempt(key, now) {
+			t.Fatalf("attempt %d must be allowed", i+1)
+		}
+	}
+	if attempts.attempt(key, now.Add(time.Minute)) {
+		t.Error("attempts over the limit must be refused")
+	}
+	if !attempts.attempt(other, now) {
+		t.Error("attempts of another user or chat must be counted separately")
+	}
+	if !attempts.attempt(key, now.Add(chatJoinAttemptWindow)) {
+		t.Error("attempts must be allowed again in the next window")
+	}
+	attempts.reset(key)
+	for range chatJoinAttemptsMax {
+		if !attempts.attempt(key, now) {
+			t.Fatal("successful join must reset the attempts")
+		}
+	}
+}
+
+// restrictionDB - публичный чат, в котором бан пользователя появляется между проверкой
+// и транзакцией записи: его видит только запрос с FOR SHARE
+func restrictionDB(t *testing.T, chatID uuid.UUID, kind models.ChatUserBanType) (*ChatService, *dbtest.DB) {
+	db, fake := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		switch {
+		case q.Has(`FROM "t_chat_user_ban"`, "FOR SHARE"):
+			return dbtest.Result{
+				Columns: []string{"ck_id", "ck_chat", "ck_user", "ct_start", "cr_type"},
+				Rows:    [][]driver.Value{{uuid.NewString(), chatID.String(), q.Args[1], time.Now().Add(-time.Minute), string(kind)}},
+			}
+		case q.Has(`FROM "t_chat"`):
+			return dbtest.Result{
+				Columns: []string{"ck_id", "ck_author", "cr_type", "cl_direct"},
+				Rows:    [][]driver.Value{{chatID.String(), uuid.NewString(), string(models.ChatTypePublic), false}},
+			}
+		}
+		return dbtest.Result{}
+	})
+	return NewChatService(repository.NewParierRepository(db), nil, nil, nil, nil), fake
+}
+
+func TestChatRestrictionInTransaction(t *testing.T) {
+	chatID := uuid.New()
+	user := models.DefaultRequest{User: &models.User{ID: uuid.New()}}
+
+	s, fake := restrictionDB(t, chatID, models.ChatUserBanTypeBan)
+	_, err := s.JoinChat(chatID, models.ChatJoinRequest{DefaultRequest: user})
+	if serviceErr := GetServiceError(err); serviceErr == nil || serviceErr.Code != "FORBIDDEN" {
+		t.Errorf("join must be refused by a ban found in the transaction, got %v", err)
+	}
+	if fake.Count(`INSERT INTO "t_chat_user"`) != 0 || fake.Count("ROLLBACK") != 1 {
+		t.Error("banned user must not be added to the chat")
+	}
+
+	s, fake = restrictionDB(t, chatID, models.ChatUserBanTypeMute)
+	_, err = s.SendChatMessage(chatID, models.ChatMessageCreateRequest{Content: "hello", DefaultRequest: user})
+	if serviceErr := GetServiceError(err); serviceErr == nil || serviceErr.Code != "FORBIDDEN" {
+		t.Errorf("message must be refused by a mute found in the transaction, got %v", err)
+	}
+	if fake.Count(`INSERT INTO "t_chat_message"`) != 0 || fake.Count("ROLLBACK") != 1 {
+		t.Error("muted user must not send a message")
+	}
+}
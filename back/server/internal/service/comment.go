@@ -0,0 +1,356 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+	"parier-server/internal/util"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	commentRepliesPage = 20  // ответов на странице "загрузить ещё" по умолчанию
+	commentPageMax     = 100 // больше ответов за один запрос не загружается
+)
+
+// GetBetCommentTree возвращает страницу комментариев верхнего уровня ставки с вложенными ответами.
+// Всё дерево страницы загружается одним запросом, авторы - вторым
+func (s *ParierService) GetBetCommentTree(betID uuid.UUID, request models.BetCommentTreeRequest) ([]models.BetCommentThreadResponse, int64, error) {
+	offset, limit := util.ValidatePageAndPageSize(request.Offset, request.Limit)
+	depth, replies := s.commentTreeLimits(request.Depth, request.Replies)
+	q := repository.CommentTreeQuery{
+		BetID:   betID,
+		Desc:    request.SortDir != nil && strings.EqualFold(*request.SortDir, "DESC"),
+		Offset:  offset,
+		Limit:   limit,
+		Depth:   depth,
+		Replies: replies,
+		Viewer:  request.User.ID,
+	}
+	total, err := s.repo.CountCommentLevel(q)
+	if err != nil {
+		return nil, 0, databaseError("Failed to count comments", err)
+	}
+	tree, err := s.loadCommentTree(q)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tree, total, nil
+}
+
+// GetBetCommentReplies возвращает следующие ответы на комментарий после курсора
+// вместе с их собственными ответами
+func (s *ParierService) GetBetCommentReplies(commentID uuid.UUID, request models.BetCommentRepliesRequest) (*models.BetCommentRepliesResponse, error) {
+	parent, err := s.repo.FindBetComment(commentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &ServiceError{Code: "NOT_FOUND", Message: "Comment not found"}
+	}
+	if err != nil {
+		return nil, databaseError("Failed to get comment", err)
+	}
+	limit := commentRepliesPage
+	if request.Limit != nil && *request.Limit > 0 {
+		limit = min(*request.Limit, commentPageMax)
+	}
+	depth, replies := s.commentTreeLimits(request.Depth, request.Replies)
+	q := repository.CommentTreeQuery{
+		BetID:   parent.CkBet,
+		Parent:  &parent.CkId,
+		After:   request.Cursor,
+		Limit:   limit + 1, // лишний ответ показывает, что есть следующая страница
+		Depth:   depth,
+		Replies: replies,
+		Viewer:  request.User.ID,
+	}
+	total, err := s.repo.CountCommentLevel(q)
+	if err != nil {
+		return nil, databaseError("Failed to count comments", err)
+	}
+	tree, err := s.loadCommentTree(q)
+	if err != nil {
+		return nil, err
+	}
+	res := &models.BetCommentRepliesResponse{Replies: tree, Total: total}
+	if len(tree) > limit {
+		res.Replies = tree[:limit]
+		last := tree[limit-1].ID
+		res.HasMore, res.NextCursor = true, &last
+	}
+	return res, nil
+}
+
+// commentTreeLimits ограничивает глубину и число ответов настройками комментариев
+func (s *ParierService) commentTreeLimits(depth, replies *int) (int, int) {
+	d, r := s.comments.MaxDepth, s.comments.Replies
+	if depth != nil {
+		d = min(max(*depth, 0), s.comments.MaxDepth)
+	}
+	if replies != nil {
+		r = min(max(*replies, 0), commentPageMax)
+	}
+	return d, r
+}
+
+func (s *ParierService) loadCommentTree(q repository.CommentTreeQuery) ([]models.BetCommentThreadResponse, error) {
+	nodes, err := s.repo.GetCommentTree(q)
+	if err != nil {
+		return nil, databaseError("Failed to get comments", err)
+	}
+	ids := make([]uuid.UUID, 0, len(nodes))
+	seen := make(map[uuid.UUID]bool, len(nodes))
+	for _, node := range nodes {
+		if !seen[node.CkAuthor] {
+			seen[node.CkAuthor] = true
+			ids = append(ids, node.CkAuthor)
+		}
+	}
+	users, err := s.repo.GetCommentAuthors(ids)
+	if err != nil {
+		return nil, databaseError("Failed to get comment authors", err)
+	}
+	authors := make(map[uuid.UUID]models.AuthorResponse, len(users))
+	for i := range users {
+		authors[users[i].CkId] = s.authorResponse(users[i].CkId, &users[i])
+	}
+	commentIDs := make([]uuid.UUID, 0, len(nodes))
+	index := make(map[uuid.UUID]int, len(nodes))
+	for i, node := range nodes {
+		commentIDs = append(commentIDs, node.CkId)
+		index[node.CkId] = i
+	}
+	mentions, err := s.repo.GetCommentMentions(commentIDs, nil)
+	if err != nil {
+		return nil, databaseError("Failed to get comment mentions", err)
+	}
+	for _, mention := range mentions {
+		node := &nodes[index[mention.CkComment]]
+		node.Mentions = append(node.Mentions, mention)
+	}
+	return s.buildCommentTree(nodes, authors, q.Viewer), nil
+}
+
+// buildCommentTree собирает дерево из комментариев, упорядоченных по уровням.
+// Если загружены не все ответы, курсор указывает на последний загруженный
+func (s *ParierService) buildCommentTree(nodes []repository.CommentNode, authors map[uuid.UUID]models.AuthorResponse, viewer uuid.UUID) []models.BetCommentThreadResponse {
+	children := make(map[uuid.UUID][]int, len(nodes))
+	var roots []int
+	for i, node := range nodes {
+		if node.CnDepth == 0 || node.CkParent == nil {
+			roots = append(roots, i)
+			continue
+		}
+		children[*node.CkParent] = append(children[*node.CkParent], i)
+	}
+	var build func(i int) models.BetCommentThreadResponse
+	build = func(i int) models.BetCommentThreadResponse {
+		node := &nodes[i]
+		author, ok := authors[node.CkAuthor]
+		if !ok {
+			author = models.AuthorResponse{ID: node.CkAuthor}
+		}
+		res := models.BetCommentThreadResponse{
+			BetCommentResponse: models.BetCommentResponse{
+				ID:          node.CkId,
+				Content:     node.CvContent,
+				CreatedAt:   node.CtCreate,
+				UpdatedAt:   node.CtModify,
+				DeletedAt:   node.CtDelete,
+				Author:      author,
+				Likes:       int(node.CnLikes),
+				IsLikedByMe: node.ClLikedByMe,
+			},
+			Replies:  node.CnReplies,
+			Children: make([]models.BetCommentThreadResponse, 0, len(children[node.CkId])),
+		}
+		s.commentState(&res.BetCommentResponse, &node.TBetComment, viewer, time.Now())
+		for _, j := range children[node.CkId] {
+			res.Children = append(res.Children, build(j))
+		}
+		if int64(len(res.Children)) < node.CnReplies {
+			res.HasMoreReplies = true
+			if n := len(res.Children); n > 0 {
+				last := res.Children[n-1].ID
+				res.NextCursor = &last
+			}
+		}
+		return res
+	}
+	tree := make([]models.BetCommentThreadResponse, 0, len(roots))
+	for _, i := range roots {
+		tree = append(tree, build(i))
+	}
+	return tree
+}
+
+// commentState заполняет признаки изменения и удаления комментария для viewer.
+// Текст удалённого комментария не отдаётся, в дереве он остаётся "комментарием удалён"
+func (s *ParierService) commentState(res *models.BetCommentResponse, comment *models.TBetComment, viewer uuid.UUID, now time.Time) {
+	res.EditedAt = comment.CtEdit
+	res.RemovedBy = comment.CrRemove
+	if comment.CtDelete != nil {
+		res.Content = ""
+		return
+	}
+	res.Mentions = mentionResponses(comment.Mentions)
+	if comment.CkAuthor != viewer || !s.canAuthorEdit(comment, now) {
+		return
+	}
+	res.CanEdit = true
+	if s.comments.EditWindow > 0 {
+		until := comment.CtCreate.Add(s.comments.EditWindow)
+		res.EditableUntil = &until
+	}
+}
+
+// EditBetComment меняет текст комментария. Автор может изменить текст в течение EditWindow
+// после создания, модератор - в любое время; прежний текст сохраняется в истории.
+// Изменения модератора записываются в журнал аудита
+func (s *ParierService) EditBetComment(commentID uuid.UUID, request models.BetCommentEditRequest, moderator bool, actor AuditActor) (bool, error) {
+	content := strings.TrimSpace(request.Content)
+	if content == "" {
+		return false, &ServiceError{Code: "VALIDATION_ERROR", Message: "Comment content is required"}
+	}
+	err := s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		comment, err := s.lockCommentForChange(tx, commentID, request.User.ID, moderator)
+		if err != nil {
+			return err
+		}
+		isAuthor := comment.CkAuthor == request.User.ID
+		if isAuthor && !moderator && !s.canAuthorEdit(comment, time.Now()) {
+			return &ServiceError{Code: "FORBIDDEN", Message: "Comment can no longer be edited"}
+		}
+		if comment.CvContent == content {
+			return nil
+		}
+		previous := comment.CvContent
+		revision := &models.TBetCommentRevision{
+			CkComment:   comment.CkId,
+			CvContent:   previous,
+			ClModerator: !isAuthor,
+			BaseModel: models.BaseModel{
+				CkCreate: request.User.ID.String(),
+				CkModify: request.User.ID.String(),
+			},
+		}
+		if err := s.repo.CreateBetCommentRevision(revision, tx); err != nil {
+			return databaseError("Failed to save comment revision", err)
+		}
+		now := time.Now()
+		comment.CvContent = content
+		comment.CtEdit = &now
+		comment.CkModify = request.User.ID.String()
+		if err := s.repo.UpdateBetComment(comment, tx); err != nil {
+			return databaseError("Failed to update comment", err)
+		}
+		mentioned, err := s.syncMentions(tx, comment)
+		if err != nil {
+			return err
+		}
+		err = s.events.Publish(tx, BetTopic(comment.CkBet), EventCommentEdited, map[string]any{"comment_id": comment.CkId})
+		if err != nil {
+			return err
+		}
+		if isAuthor {
+			// правка модератора не уведомляет упомянутых от имени автора
+			return s.notifications.Notify(tx, request.User.ID.String(), commentNotices(comment, request.User.Username, nil, mentioned)...)
+		}
+		return s.audit.Record(tx, actor, AuditEntry{
+			Action:     AuditCommentEdit,
+			EntityType: "t_bet_comment",
+			EntityID:   comment.CkId.String(),
+			Before:     map[string]any{"cv_content": previous},
+			After:      map[string]any{"cv_content": content},
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteBetComment удаляет комментарий автора или, для модератора, любой комментарий.
+// Ответы на удалённый комментарий остаются в дереве под "комментарием удалён"
+func (s *ParierService) DeleteBetComment(commentID uuid.UUID, request models.DefaultRequest, moderator bool, actor AuditActor) (bool, error) {
+	err := s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		comment, err := s.lockCommentForChange(tx, commentID, request.User.ID, moderator)
+		if err != nil {
+			return err
+		}
+		remover := models.CommentRemoverAuthor
+		if comment.CkAuthor != request.User.ID {
+			remover = models.CommentRemoverModerator
+		}
+		if err := s.repo.DeleteBetComment(comment.CkId, remover, request.User.ID.String(), tx); err != nil {
+			return databaseError("Failed to delete comment", err)
+		}
+		err = s.events.Publish(tx, BetTopic(comment.CkBet), EventCommentDeleted, map[string]any{"comment_id": comment.CkId})
+		if err != nil {
+			return err
+		}
+		if remover == models.CommentRemoverAuthor {
+			return nil
+		}
+		return s.audit.Record(tx, actor, AuditEntry{
+			Action:     AuditCommentRemove,
+			EntityType: "t_bet_comment",
+			EntityID:   comment.CkId.String(),
+			Before:     map[string]any{"cv_content": comment.CvContent, "ck_author": comment.CkAuthor},
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetBetCommentRevisions возвращает прежние версии комментария, новые первыми.
+// История удалённого комментария доступна только модератору
+func (s *ParierService) GetBetCommentRevisions(commentID uuid.UUID, moderator bool) ([]models.BetCommentRevisionResponse, error) {
+	comment, err := s.repo.FindBetComment(commentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && comment.CtDelete != nil && !moderator) {
+		return nil, &ServiceError{Code: "NOT_FOUND", Message: "Comment not found"}
+	}
+	if err != nil {
+		return nil, databaseError("Failed to get comment", err)
+	}
+	revisions, err := s.repo.GetBetCommentRevisions(commentID)
+	if err != nil {
+		return nil, databaseError("Failed to get comment revisions", err)
+	}
+	result := make([]models.BetCommentRevisionResponse, 0, len(revisions))
+	for _, revision := range revisions {
+		result = append(result, models.BetCommentRevisionResponse{
+			ID:          revision.CkId,
+			Content:     revision.CvContent,
+			EditorID:    revision.CkCreate,
+			IsModerator: revision.ClModerator,
+			ReplacedAt:  revision.CtCreate,
+		})
+	}
+	return result, nil
+}
+
+// lockCommentForChange блокирует комментарий, который пользователь меняет или удаляет.
+// Чужой комментарий может менять только модератор
+func (s *ParierService) lockCommentForChange(tx *gorm.DB, commentID, userID uuid.UUID, moderator bool) (*models.TBetComment, error) {
+	comment, err := s.repo.LockBetComment(tx, commentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && comment.CtDelete != nil) {
+		return nil, &ServiceError{Code: "NOT_FOUND", Message: "Comment not found"}
+	}
+	if err != nil {
+		return nil, databaseError("Failed to get comment", err)
+	}
+	if comment.CkAuthor != userID && !moderator {
+		return nil, &ServiceError{Code: "FORBIDDEN", Message: "Only the author or a moderator can change the comment"}
+	}
+	return comment, nil
+}
+
+func (s *ParierService) canAuthorEdit(comment *models.TBetComment, now time.Time) bool {
+	return s.comments.EditWindow <= 0 || now.Before(comment.CtCreate.Add(s.comments.EditWindow))
+}
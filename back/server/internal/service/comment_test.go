@@ -0,0 +1,100 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"parier-server/internal/config"
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+func commentNode(parent *uuid.UUID, depth int, replies int64) repository.CommentNode {
+	node := repository.CommentNode{CnDepth: depth, CnReplies: replies}
+	node.CkId = uuid.New()
+	node.CkParent = parent
+	node.CkAuthor = uuid.New()
+	return node
+}
+
+func TestBuildCommentTree(t *testing.T) {
+	// две ветки: у первой загружены оба ответа и один из трёх ответов второго уровня
+	first := commentNode(nil, 0, 2)
+	second := commentNode(nil, 0, 0)
+	reply1 := commentNode(&first.CkId, 1, 3)
+	reply2 := commentNode(&first.CkId, 1, 0)
+	nested := commentNode(&reply1.CkId, 2, 0)
+
+	s := &ParierService{}
+	tree := s.buildCommentTree([]repository.CommentNode{first, second, reply1, reply2, nested}, map[uuid.UUID]models.AuthorResponse{}, uuid.Nil)
+	if len(tree) != 2 || tree[0].ID != first.CkId || tree[1].ID != second.CkId {
+		t.Fatalf("top level order is broken: %+v", tree)
+	}
+	if tree[0].HasMoreReplies || len(tree[0].Children) != 2 || tree[0].Children[0].ID != reply1.CkId {
+		t.Fatalf("replies of the first thread are not attached in order: %+v", tree[0])
+	}
+	loaded := tree[0].Children[0]
+	if !loaded.HasMoreReplies || loaded.NextCursor == nil || *loaded.NextCursor != nested.CkId {
+		t.Errorf("partially loaded replies must continue after the last loaded one: %+v", loaded)
+	}
+	if tree[1].Children == nil || tree[1].HasMoreReplies {
+		t.Errorf("comment without replies must have an empty list and nothing to load: %+v", tree[1])
+	}
+	if tree[0].Author.ID != first.CkAuthor {
+		t.Errorf("author without profile must keep its ID")
+	}
+
+	// ответы за пределом глубины не загружены, курсора нет - загрузка с начала
+	deep := s.buildCommentTree([]repository.CommentNode{commentNode(nil, 0, 5)}, nil, uuid.Nil)
+	if !deep[0].HasMoreReplies || deep[0].NextCursor != nil {
+		t.Errorf("unloaded replies must be loaded from the start: %+v", deep[0])
+	}
+}
+
+func TestCommentTreeLimits(t *testing.T) {
+	s := &ParierService{comments: config.CommentsConfig{MaxDepth: 3, Replies: 2}}
+	ten, negative := 10, -1
+	if depth, replies := s.commentTreeLimits(nil, nil); depth != 3 || replies != 2 {
+		t.Errorf("defaults: got %d, %d", depth, replies)
+	}
+	if depth, replies := s.commentTreeLimits(&ten, &negative); depth != 3 || replies != 0 {
+		t.Errorf("limits: got %d, %d", depth, replies)
+	}
+}
+
+func TestCommentState(t *testing.T) {
+	s := &ParierService{comments: config.CommentsConfig{EditWindow: 15 * time.Minute}}
+	created := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	comment := &models.TBetComment{CkId: uuid.New(), CkAuthor: uuid.New(), CvContent: "text", BaseModel: models.BaseModel{CtCreate: created}}
+
+	res := models.BetCommentResponse{Content: comment.CvContent}
+	s.commentState(&res, comment, comment.CkAuthor, created.Add(10*time.Minute))
+	if !res.CanEdit || res.EditableUntil == nil || !res.EditableUntil.Equal(created.Add(15*time.Minute)) {
+		t.Errorf("author must be able to edit within the window: %+v", res)
+	}
+	res = models.BetCommentResponse{Content: comment.CvContent}
+	s.commentState(&res, comment, comment.CkAuthor, created.Add(15*time.Minute))
+	if res.CanEdit || res.EditableUntil != nil {
+		t.Errorf("author must not edit after the window: %+v", res)
+	}
+	res = models.BetCommentResponse{Content: comment.CvContent}
+	s.commentState(&res, comment, uuid.New(), created)
+	if res.CanEdit {
+		t.Errorf("other users must not edit the comment")
+	}
+
+	removed := models.CommentRemoverModerator
+	comment.CtDelete, comment.CrRemove = &created, &removed
+	res = models.BetCommentResponse{Content: comment.CvContent}
+	s.commentState(&res, comment, comment.CkAuthor, created)
+	if res.Content != "" || res.CanEdit || res.RemovedBy == nil || *res.RemovedBy != removed {
+		t.Errorf("removed comment must hide its content: %+v", res)
+	}
+
+	s.comments.EditWindow = 0
+	if !s.canAuthorEdit(&models.TBetComment{BaseModel: models.BaseModel{CtCreate: created}}, created.AddDate(1, 0, 0)) {
+		t.Errorf("zero window must not limit editing")
+	}
+}
@@ -10,10 +10,11 @@ import (
 type CoreService struct {
 	coreRepository  *repository.CoreRepository
 	LocRepo         *repository.LocalizationRepository
+	Resolver        *repository.LocalizationResolver
 }
 
-func NewCoreService(coreRepository *repository.CoreRepository, localizationRepository *repository.LocalizationRepository) *CoreService {
-	return &CoreService{coreRepository: coreRepository, LocRepo: localizationRepository}
+func NewCoreService(coreRepository *repository.CoreRepository, localizationRepository *repository.LocalizationRepository, resolver *repository.LocalizationResolver) *CoreService {
+	return &CoreService{coreRepository: coreRepository, LocRepo: localizationRepository, Resolver: resolver}
 }
 
 func (s *CoreService) GetPropertiesTypes(filterTypeFilterQuery repository.PropertiesTypeFilter) ([]models.TDPropertiesType, int64, error) {
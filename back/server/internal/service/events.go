@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"parier-server/internal/config"
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Типы событий потока реального времени
+const (
+	EventBetCreated          = "bet.created"
+	EventBetLiked            = "bet.liked"
+	EventBetUnliked          = "bet.unliked"
+	EventBetRated            = "bet.rated"
+	EventBetSettled          = "bet.settled"
+	EventCommentCreated      = "comment.created"
+	EventCommentEdited       = "comment.edited"
+	EventCommentDeleted      = "comment.deleted"
+	EventCommentLiked        = "comment.liked"
+	EventCommentUnliked      = "comment.unliked"
+	EventCommentReply        = "comment.reply"
+	EventWalletBalance       = "wallet.balance"
+	EventNotificationCreated = "notification.created"
+	EventChatMessage         = "chat.message"
+	EventChatMessageLiked    = "chat.message.liked"
+	EventChatMessageUnliked  = "chat.message.unliked"
+	EventChatMemberJoined    = "chat.member.joined"
+	EventChatMemberLeft      = "chat.member.left"
+	EventChatMemberRole      = "chat.member.role"
+	EventChatMemberBanned    = "chat.member.banned"
+	EventChatMemberMuted     = "chat.member.muted"
+	EventChatMemberUnbanned  = "chat.member.unbanned"
+
+	// Служебные события соединения
+	EventSubscribed = "subscribed"
+	EventPing       = "ping"
+	EventError      = "error"
+	// EventTopicRevoked - пользователь потерял доступ к теме, подписка на неё снята
+	EventTopicRevoked = "topic.revoked"
+	// EventResync - события могли быть потеряны при переподключении к БД, данные нужно перечитать
+	EventResync = "resync"
+)
+
+// Темы, на которые подписывается клиент: bets - новые ставки, bet:<id> - события ставки,
+// chat:<id> - сообщения чата, replies, wallet и notifications - ответы, баланс и уведомления
+// текущего пользователя
+const (
+	TopicBets          = "bets"
+	TopicBet           = "bet"
+	TopicChat          = "chat"
+	TopicReplies       = "replies"
+	TopicWallet        = "wallet"
+	TopicNotifications = "notifications"
+)
+
+// eventPayloadLimit - предел размера оповещения Postgres (8000 байт) с запасом
+const eventPayloadLimit = 7900
+
+// BetTopic - тема событий ставки
+func BetTopic(betID uuid.UUID) string {
+	return TopicBet + ":" + betID.String()
+}
+
+// ChatTopic - тема сообщений чата
+func ChatTopic(chatID uuid.UUID) string {
+	return TopicChat + ":" + chatID.String()
+}
+
+func userTopic(name string, userID uuid.UUID) string {
+	return name + ":" + userID.String()
+}
+
+// ResolveTopic переводит тему клиента во внутреннюю. Темы пользователя доступны только
+// ему самому, поэтому ID в них подставляет сервер; user nil - анонимная сессия
+func ResolveTopic(name string, user *uuid.UUID) (string, error) {
+	switch name {
+	case TopicBets:
+		return name, nil
+	case TopicReplies, TopicWallet, TopicNotifications:
+		if user == nil {
+			return "", &ServiceError{Code: "FORBIDDEN", Message: "Topic " + name + " requires a signed in user"}
+		}
+		return userTopic(name, *user), nil
+	}
+	if kind, id, ok := strings.Cut(name, ":"); ok && (kind == TopicBet || kind == TopicChat) {
+		entityID, err := uuid.Parse(id)
+		if err != nil {
+			return "", &ServiceError{Code: "VALIDATION_ERROR", Message: "Invalid " + kind + " ID in topic " + name}
+		}
+		return kind + ":" + entityID.String(), nil
+	}
+	return "", &ServiceError{Code: "VALIDATION_ERROR", Message: "Unknown topic " + name}
+}
+
+// TopicAuthorizer проверяет, что пользователь может получать события темы с ID сущности id
+type TopicAuthorizer func(user *uuid.UUID, id uuid.UUID) error
+
+// EventHub раздаёт события подписчикам этого экземпляра API. События публикуются через
+// Postgres NOTIFY в транзакции изменения, поэтому уходят только после её фиксации
+// и доходят до подписчиков всех экземпляров
+type EventHub struct {
+	notifier *repository.EventNotifier
+	cfg      config.EventsConfig
+	mu       sync.Mutex
+	subs     map[*Subscription]struct{}
+	topics   map[string]map[*Subscription]struct{}
+	checks   map[string]TopicAuthorizer
+	quit     context.CancelFunc
+}
+
+func NewEventHub(notifier *repository.EventNotifier, cfg config.EventsConfig) *EventHub {
+	return &EventHub{
+		notifier: notifier,
+		cfg:      cfg,
+		subs:     make(map[*Subscription]struct{}),
+		topics:   make(map[string]map[*Subscription]struct{}),
+		checks:   make(map[string]TopicAuthorizer),
+	}
+}
+
+// Authorize задаёт проверку доступа к темам вида kind:<id>. Вызывается при запуске, до подписок
+func (h *EventHub) Authorize(kind string, check TopicAuthorizer) {
+	if h != nil {
+		h.checks[kind] = check
+	}
+}
+
+func (h *EventHub) authorize(topic string, user *uuid.UUID) error {
+	kind, id, ok := strings.Cut(topic, ":")
+	check := h.checks[kind]
+	if !ok || check == nil {
+		return nil
+	}
+	entityID, err := uuid.Parse(id)
+	if err != nil {
+		// темы пользователя уже привязаны к нему в ResolveTopic
+		return nil
+	}
+	return check(user, entityID)
+}
+
+// Publish отправляет событие в тему; с tx оно уйдёт после фиксации транзакции.
+// Без хаба (поток событий отключён) ничего не делает
+func (h *EventHub) Publish(tx *gorm.DB, topic, kind string, data map[string]any) error {
+	if h == nil {
+		return nil
+	}
+	event := models.Event{ID: uuid.New(), Topic: topic, Type: kind, Data: data, CreatedAt: time.Now()}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if len(payload) > eventPayloadLimit {
+		// по типу и теме клиент сам перечитает данные
+		event.Data = nil
+		if payload, err = json.Marshal(event); err != nil {
+			return err
+		}
+	}
+	return h.notifier.Publish(tx, string(payload))
+}
+
+// Start запускает прослушивание событий всех экземпляров
+func (h *EventHub) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.quit = cancel
+	go h.notifier.Listen(ctx, h.receive)
+}
+
+// Stop останавливает прослушивание событий
+func (h *EventHub) Stop() {
+	if h.quit != nil {
+		h.quit()
+	}
+}
+
+func (h *EventHub) receive(payload string) {
+	if payload == "" {
+		h.broadcast(models.Event{ID: uuid.New(), Type: EventResync, CreatedAt: time.Now()})
+		return
+	}
+	var event models.Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		log.Printf("Invalid event notification: %v", err)
+		return
+	}
+	h.dispatch(event)
+}
+
+// Revoke снимает подписки пользователя на тему на всех экземплярах, когда он теряет
+// к ней доступ: права проверяются только при подписке. С tx - после фиксации транзакции
+func (h *EventHub) Revoke(tx *gorm.DB, topic string, user uuid.UUID) error {
+	return h.Publish(tx, topic, EventTopicRevoked, map[string]any{"user_id": user})
+}
+
+func (h *EventHub) dispatch(event models.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	revoked, revoke := revokedUser(event)
+	for sub := range h.topics[event.Topic] {
+		if revoke && (sub.user == nil || *sub.user != revoked) {
+			continue
+		}
+		delivered := event
+		delivered.Topic = sub.topics[event.Topic]
+		h.send(sub, delivered)
+		if revoke && !sub.closed {
+			delete(sub.topics, event.Topic)
+			h.unlink(event.Topic, sub)
+		}
+	}
+}
+
+// revokedUser возвращает пользователя, у которого событие отзывает тему
+func revokedUser(event models.Event) (uuid.UUID, bool) {
+	if event.Type != EventTopicRevoked {
+		return uuid.Nil, false
+	}
+	user, err := uuid.Parse(fmt.Sprint(event.Data["user_id"]))
+	return user, err == nil
+}
+
+func (h *EventHub) broadcast(event models.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		h.send(sub, event)
+	}
+}
+
+// send не ждёт медленного клиента: при переполненной очереди подписка закрывается,
+// клиент переподключится и перечитает данные
+func (h *EventHub) send(sub *Subscription, event models.Event) {
+	select {
+	case sub.events <- event:
+	default:
+		h.drop(sub)
+	}
+}
+
+// drop закрывает подписку; вызывается под h.mu
+func (h *EventHub) drop(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	for topic := range sub.topics {
+		h.unlink(topic, sub)
+	}
+	delete(h.subs, sub)
+	close(sub.events)
+}
+
+func (h *EventHub) unlink(topic string, sub *Subscription) {
+	delete(h.topics[topic], sub)
+	if len(h.topics[topic]) == 0 {
+		delete(h.topics, topic)
+	}
+}
+
+// Subscription - подписка одного соединения на темы событий
+type Subscription struct {
+	hub    *EventHub
+	user   *uuid.UUID
+	events chan models.Event
+	topics map[string]string // внутренняя тема -> тема клиента
+	closed bool
+}
+
+// Subscribe создаёт подписку без тем; user - текущий пользователь или nil для анонимной сессии
+func (h *EventHub) Subscribe(user *uuid.UUID) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		user:   user,
+		events: make(chan models.Event, max(h.cfg.Buffer, 1)),
+		topics: make(map[string]string),
+	}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// Events - очередь событий подписки; закрывается, когда подписка закрыта
+func (s *Subscription) Events() <-chan models.Event {
+	return s.events
+}
+
+// Add подписывает на темы клиента. Если хоть одна тема недоступна, подписка не меняется
+func (s *Subscription) Add(names ...string) error {
+	resolved, err := s.resolve(names)
+	if err != nil {
+		return err
+	}
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	added := 0
+	for topic := range resolved {
+		if _, ok := s.topics[topic]; !ok {
+			added++
+		}
+	}
+	if h.cfg.MaxTopics > 0 && len(s.topics)+added > h.cfg.MaxTopics {
+		return &ServiceError{Code: "VALIDATION_ERROR", Message: "Too many topics"}
+	}
+	if s.closed {
+		return nil
+	}
+	for topic, name := range resolved {
+		s.topics[topic] = name
+		if h.topics[topic] == nil {
+			h.topics[topic] = make(map[*Subscription]struct{})
+		}
+		h.topics[topic][s] = struct{}{}
+	}
+	return nil
+}
+
+// Remove отписывает от тем клиента
+func (s *Subscription) Remove(names ...string) error {
+	resolved, err := s.resolve(names)
+	if err != nil {
+		return err
+	}
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for topic := range resolved {
+		if _, ok := s.topics[topic]; ok {
+			delete(s.topics, topic)
+			h.unlink(topic, s)
+		}
+	}
+	return nil
+}
+
+// Topics возвращает темы подписки в том виде, в каком их передал клиент
+func (s *Subscription) Topics() []string {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	names := make([]string, 0, len(s.topics))
+	for _, name := range s.topics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close закрывает подписку; повторный вызов ничего не делает
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s)
+}
+
+func (s *Subscription) resolve(names []string) (map[string]string, error) {
+	resolved := make(map[string]string, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		topic, err := ResolveTopic(name, s.user)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.hub.authorize(topic, s.user); err != nil {
+			return nil, err
+		}
+		resolved[topic] = name
+	}
+	return resolved, nil
+}
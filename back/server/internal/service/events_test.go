@@ -0,0 +1,89 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"parier-server/internal/config"
+	"parier-server/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestResolveTopic(t *testing.T) {
+	user, bet := uuid.New(), uuid.New()
+
+	if topic, err := ResolveTopic("wallet", &user); err != nil || topic != "wallet:"+user.String() {
+		t.Errorf("user topic must be bound to the current user, got %q, %v", topic, err)
+	}
+	if topic, err := ResolveTopic("bet:"+bet.String(), nil); err != nil || topic != BetTopic(bet) {
+		t.Errorf("bet topic must be open to anonymous sessions, got %q, %v", topic, err)
+	}
+	if _, err := ResolveTopic("replies", nil); err == nil || err.(*ServiceError).Code != "FORBIDDEN" {
+		t.Errorf("user topic without a user must be forbidden, got %v", err)
+	}
+	for _, name := range []string{"bet:oops", "wallet:" + uuid.NewString(), "unknown"} {
+		if _, err := ResolveTopic(name, &user); err == nil || err.(*ServiceError).Code != "VALIDATION_ERROR" {
+			t.Errorf("topic %q must be rejected, got %v", name, err)
+		}
+	}
+}
+
+func eventPayload(t *testing.T, topic, kind string) string {
+	t.Helper()
+	payload, err := json.Marshal(models.Event{ID: uuid.New(), Topic: topic, Type: kind})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(payload)
+}
+
+func TestEventHubDispatch(t *testing.T) {
+	hub := NewEventHub(nil, config.EventsConfig{Buffer: 2, MaxTopics: 2})
+	alice, bob := uuid.New(), uuid.New()
+	aliceSub, bobSub := hub.Subscribe(&alice), hub.Subscribe(&bob)
+	if err := aliceSub.Add("wallet", "bets"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bobSub.Add("wallet"); err != nil {
+		t.Fatal(err)
+	}
+	if err := aliceSub.Add("replies"); err == nil {
+		t.Error("subscription must be limited to MaxTopics")
+	}
+
+	hub.receive(eventPayload(t, userTopic(TopicWallet, alice), EventWalletBalance))
+	event := <-aliceSub.Events()
+	if event.Topic != "wallet" || event.Type != EventWalletBalance {
+		t.Errorf("event must be delivered with the client topic, got %+v", event)
+	}
+	if len(bobSub.Events()) != 0 {
+		t.Error("wallet event of one user must not reach another")
+	}
+
+	hub.receive("")
+	if event := <-bobSub.Events(); event.Type != EventResync {
+		t.Errorf("reconnect must send resync to every subscription, got %+v", event)
+	}
+	<-aliceSub.Events()
+
+	// очередь медленного клиента переполнена - подписка закрывается
+	for range 3 {
+		hub.receive(eventPayload(t, TopicBets, EventBetCreated))
+	}
+	received := 0
+	for range aliceSub.Events() {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("slow subscription must be closed after its buffer is full, got %d events", received)
+	}
+	aliceSub.Close()
+	if err := aliceSub.Remove("bets"); err != nil {
+		t.Errorf("closed subscription must accept Remove, got %v", err)
+	}
+	bobSub.Close()
+	if len(hub.subs) != 0 || len(hub.topics) != 0 {
+		t.Errorf("closed subscriptions must be removed from the hub, got %d subscriptions and %d topics", len(hub.subs), len(hub.topics))
+	}
+}
@@ -2,9 +2,7 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"net/url"
@@ -21,59 +19,6 @@ import (
 	"github.com/google/uuid"
 )
 
-type Session struct {
-	AccessTimestamp time.Time
-	Session         *models.TSession
-}
-
-type SessionStore struct {
-	sync.RWMutex
-	sessions map[uuid.UUID]*Session
-}
-
-func (s *SessionStore) Get(id uuid.UUID) (*models.TSession, bool) {
-	s.RLock()
-	defer s.RUnlock()
-	session, ok := s.sessions[id]
-	if ok && session.Session.CtExpire.After(time.Now()) {
-		session.AccessTimestamp = time.Now()
-		return session.Session, true
-	}
-	return nil, false
-}
-
-func (s *SessionStore) Set(id uuid.UUID, session *models.TSession) {
-	s.Lock()
-	defer s.Unlock()
-	s.sessions[id] = &Session{
-		AccessTimestamp: time.Now(),
-		Session:         session,
-	}
-}
-
-func (s *SessionStore) Delete(id uuid.UUID) {
-	s.Lock()
-	defer s.Unlock()
-	delete(s.sessions, id)
-}
-
-func (s *SessionStore) Cleanup() {
-	s.RLock()
-	ids := make([]uuid.UUID, 0)
-	for id, session := range s.sessions {
-		if session.Session.CtExpire.Before(time.Now()) {
-			ids = append(ids, id)
-		}
-		if session.AccessTimestamp.Before(time.Now().Add(-30 * time.Minute)) {
-			ids = append(ids, id)
-		}
-	}
-	s.RUnlock()
-	for _, id := range ids {
-		s.Delete(id)
-	}
-}
-
 type KeycloakService struct {
 	cfg          *config.Config
 	config       *config.KeycloakConfig
@@ -81,7 +26,15 @@ type KeycloakService struct {
 	repo         *repository.UserRepository
 	LocRepo      *repository.LocalizationRepository
 	TenantsIss   map[string]*config.TenantConfig
-	sessionStore SessionStore
+	sessions     SessionBackend
+	refreshLocks sync.Map // session ID -> *sync.Mutex, one refresh per session at a time
+	synced       sync.Map // iss|sub|iat -> *keycloakSync, tokens already synced into the local DB
+}
+
+// keycloakSync запоминает локального пользователя, в которого синхронизирован токен
+type keycloakSync struct {
+	userID  uuid.UUID
+	expires time.Time
 }
 
 type KeycloakLoginResponse struct {
@@ -98,7 +51,7 @@ type KeycloakLoginResponse struct {
 	} `json:"user"`
 }
 
-func NewKeycloakService(cfg *config.Config, keycloakConfig *config.KeycloakConfig, repo *repository.UserRepository, locRepo *repository.LocalizationRepository) *KeycloakService {
+func NewKeycloakService(cfg *config.Config, keycloakConfig *config.KeycloakConfig, sessions SessionBackend, repo *repository.UserRepository, locRepo *repository.LocalizationRepository) *KeycloakService {
 	s := KeycloakService{
 		cfg:    cfg,
 		config: keycloakConfig,
@@ -108,9 +61,7 @@ func NewKeycloakService(cfg *config.Config, keycloakConfig *config.KeycloakConfi
 		repo:       repo,
 		LocRepo:    locRepo,
 		TenantsIss: make(map[string]*config.TenantConfig),
-		sessionStore: SessionStore{
-			sessions: make(map[uuid.UUID]*Session),
-		},
+		sessions:   sessions,
 	}
 	for _, tenant := range keycloakConfig.TenantsIss {
 		s.TenantsIss[tenant.Iss] = &tenant
@@ -123,19 +74,6 @@ func NewKeycloakService(cfg *config.Config, keycloakConfig *config.KeycloakConfi
 		JWTIssuer:    s.config.GetRealmIssuer(s.config.DefaultRealm),
 		CertEndpoint: s.config.GetRealmCertEndpoint(s.config.DefaultRealm),
 	}
-	ticker := time.NewTicker(30 * time.Minute)
-	quit := make(chan struct{})
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				s.sessionStore.Cleanup()
-			case <-quit:
-				ticker.Stop()
-				return
-			}
-		}
-	}()
 	return &s
 }
 
@@ -169,15 +107,17 @@ type KeycloakRole struct {
 
 // KeycloakTokenResponse represents token response from Keycloak
 type KeycloakTokenResponse struct {
-	AccessToken  string `json:"access_token"`
-	TokenType    string `json:"token_type"`
-	ExpiresIn    int    `json:"expires_in"`
-	RefreshToken string `json:"refresh_token"`
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int    `json:"refresh_expires_in"`
 }
 
 // KeycloakJWTClaims represents JWT claims from Keycloak token
 type KeycloakJWTClaims struct {
 	Sid               string                         `json:"sid"`
+	Azp               string                         `json:"azp"`
 	Phone             *string                        `json:"phone_number"`
 	Email             string                         `json:"email"`
 	PreferredUsername string                         `json:"preferred_username"`
@@ -188,6 +128,38 @@ type KeycloakJWTClaims struct {
 	jwt.RegisteredClaims
 }
 
+// keycloakRoleCandidates maps realm roles and roles of clientID onto local role IDs;
+// a mapping to "" drops the role. Roles without a mapping are dropped too unless
+// matchNames is set, then they keep their name in upper case: otherwise any realm
+// role called "admin" would grant the local ADMIN role.
+func keycloakRoleCandidates(claims *KeycloakJWTClaims, clientID string, mappings map[string]string, matchNames bool) []string {
+	seen := make(map[string]bool)
+	var roles []string
+	add := func(keys ...string) {
+		role := ""
+		if matchNames {
+			role = strings.ToUpper(keys[len(keys)-1])
+		}
+		for _, key := range keys {
+			if mapped, ok := mappings[key]; ok {
+				role = mapped
+				break
+			}
+		}
+		if role != "" && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	for _, role := range claims.RealmAccess.Roles {
+		add(role)
+	}
+	for _, role := range claims.ResourceAccess[clientID].Roles {
+		add(clientID+"/"+role, role)
+	}
+	return roles
+}
+
 // KeycloakRealmAccess represents realm access in JWT
 type KeycloakRealmAccess struct {
 	Roles []string `json:"roles"`
@@ -294,97 +266,55 @@ func (s *KeycloakService) GetRealmFromToken(tokenString string) (string, error)
 	return claims.Issuer, nil
 }
 
-// ConvertToLocalUser конвертирует Keycloak пользователя в локальную модель
+// ConvertToLocalUser сохраняет пользователя Keycloak в локальной БД и конвертирует в локальную модель
 func (s *KeycloakService) ConvertToLocalUser(claims *KeycloakJWTClaims, tenant *config.TenantConfig) (*models.User, error) {
 	id := claims.Subject
 	if id == "" {
 		id = claims.Sid
 	}
-	user, err := s.repo.GetUserByExternalID(id)
-	if err != nil {
-		user = &models.TUser{
-			CkId:       uuid.New(), // Генерируем новый UUID для локальной БД
-			CkExternal: id,         // Keycloak ID как external
-			BaseModel: models.BaseModel{
-				CkCreate: "system",
-				CkModify: "system",
-			},
-			// Другие поля можно заполнить из атрибутов или дополнительных данных
-		}
-		s.repo.CreateUser(user)
-		s.repo.CreateUserProperty(&models.TUserProperties{
-			CkId:   uuid.New(),
-			CkUser: user.CkId,
-			CkType: "USER_USERNAME",
-			CvText: &claims.PreferredUsername,
-			BaseModel: models.BaseModel{
-				CkCreate: "system",
-				CkModify: "system",
-			},
-		})
-		s.repo.CreateUserProperty(&models.TUserProperties{
-			CkId:   uuid.New(),
-			CkUser: user.CkId,
-			CkType: "USER_EMAIL",
-			CvText: &claims.Email,
-			BaseModel: models.BaseModel{
-				CkCreate: "system",
-				CkModify: "system",
-			},
-		})
-		s.repo.CreateUserProperty(&models.TUserProperties{
-			CkId:   uuid.New(),
-			CkUser: user.CkId,
-			CkType: "USER_PHONE",
-			CvText: claims.Phone,
-			BaseModel: models.BaseModel{
-				CkCreate: "system",
-				CkModify: "system",
-			},
-		})
-		s.repo.CreateUserWallet(&models.TUserWallet{
-			CkId:    uuid.New(),
-			CkUser:  user.CkId,
-			CnValue: s.cfg.Wallet.DefaultBalance,
-			BaseModel: models.BaseModel{
-				CkCreate: "system",
-				CkModify: "system",
+	client := claims.Azp
+	if client == "" {
+		client = tenant.ClientID
+	}
+	// Утверждения токена не меняются до его истечения: один и тот же токен
+	// синхронизируем один раз, а не на каждый запрос
+	key := syncKey(tenant.Iss, id, claims)
+	userID, ok := s.syncedUser(key)
+	if !ok {
+		user, created, err := s.repo.SyncKeycloakUser(&repository.KeycloakUserSync{
+			ExternalID: id,
+			Iss:        tenant.Iss,
+			Client:     client,
+			Roles:      keycloakRoleCandidates(claims, tenant.ClientID, s.config.RoleMappings, s.config.MatchRoles),
+			Properties: map[string]*string{
+				"USER_USERNAME": &claims.PreferredUsername,
+				"USER_EMAIL":    &claims.Email,
+				"USER_PHONE":    claims.Phone,
 			},
+			DefaultBalance: s.cfg.Wallet.DefaultBalance,
 		})
-	}
-	for _, role := range claims.RealmAccess.Roles {
-		if role, ok := models.RoleFromString(role); ok {
-			role := &models.TUserRole{
-				CkId:   uuid.New(),
-				CkUser: user.CkId,
-				CkRole: role.String(),
-			}
-			s.repo.CreateUserRoleNotExists(role)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sync user: %w", err)
 		}
-	}
-	for _, role := range claims.ResourceAccess[tenant.ClientID].Roles {
-		if role, ok := models.RoleFromString(role); ok {
-			role := &models.TUserRole{
-				CkId:   uuid.New(),
-				CkUser: user.CkId,
-				CkRole: role.String(),
-			}
-			s.repo.CreateUserRoleNotExists(role)
+		if created {
+			log.Printf("Created local user %s for Keycloak user %s (%s)", user.CkId, id, tenant.Iss)
 		}
+		userID = user.CkId
+		s.rememberSync(key, userID, claims)
 	}
 
-	roles, err := s.repo.GetUserRoleByID(user.CkId)
+	roles, err := s.repo.GetUserRoleByID(userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user roles: %w", err)
 	}
-	data, err := s.repo.GetUserDataByID(user.CkId)
+	data, err := s.repo.GetUserDataByID(userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user data: %w", err)
 	}
 
 	return &models.User{
-		ID:         user.CkId,
-		ExternalID: user.CkExternal,
+		ID:         userID,
+		ExternalID: id,
 		Username:   claims.PreferredUsername,
 		Email:      &claims.Email,
 		Phone:      claims.Phone,
@@ -394,6 +324,48 @@ func (s *KeycloakService) ConvertToLocalUser(claims *KeycloakJWTClaims, tenant *
 	}, nil
 }
 
+// syncKey возвращает ключ синхронизации токена или "", если токен без iat и его
+// не отличить от следующего токена того же пользователя
+func syncKey(iss, id string, claims *KeycloakJWTClaims) string {
+	if claims.IssuedAt == nil || claims.ExpiresAt == nil {
+		return ""
+	}
+	return iss + "|" + id + "|" + claims.IssuedAt.UTC().Format(time.RFC3339Nano)
+}
+
+// syncedUser возвращает локального пользователя, если токен уже синхронизирован
+func (s *KeycloakService) syncedUser(key string) (uuid.UUID, bool) {
+	if key == "" {
+		return uuid.Nil, false
+	}
+	value, ok := s.synced.Load(key)
+	if !ok {
+		return uuid.Nil, false
+	}
+	entry := value.(*keycloakSync)
+	if time.Now().After(entry.expires) {
+		s.synced.Delete(key)
+		return uuid.Nil, false
+	}
+	return entry.userID, true
+}
+
+// rememberSync запоминает синхронизацию токена до его истечения и заодно
+// вычищает истёкшие записи
+func (s *KeycloakService) rememberSync(key string, userID uuid.UUID, claims *KeycloakJWTClaims) {
+	if key == "" {
+		return
+	}
+	now := time.Now()
+	s.synced.Range(func(key, value any) bool {
+		if now.After(value.(*keycloakSync).expires) {
+			s.synced.Delete(key)
+		}
+		return true
+	})
+	s.synced.Store(key, &keycloakSync{userID: userID, expires: claims.ExpiresAt.Time})
+}
+
 // SyncUserFromKeycloak синхронизирует пользователя из Keycloak в локальную БД
 func (s *KeycloakService) SyncUserFromKeycloak(ctx context.Context, claims *KeycloakJWTClaims, realm string, accessToken string) (*models.User, error) {
 	// Конвертируем в локальную модель
@@ -405,9 +377,6 @@ func (s *KeycloakService) SyncUserFromKeycloak(ctx context.Context, claims *Keyc
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert user: %w", err)
 	}
-
-	// Здесь должна быть логика для сохранения в локальную БД
-	// Возвращаем пользователя для дальнейшей обработки
 	return user, nil
 }
 
@@ -423,7 +392,7 @@ func (s *KeycloakService) CreateSession(user *models.User, ip string, userAgent
 		CkUser:      ckUser,
 		CvData:      "{}",
 		User:        *user,
-		CtExpire:    time.Now().Add(time.Hour * 24),
+		CtExpire:    time.Now().Add(s.cfg.Store.SessionDuration),
 		CkIp:        ip,
 		CkUserAgent: userAgent,
 		BaseModel: models.BaseModel{
@@ -431,62 +400,42 @@ func (s *KeycloakService) CreateSession(user *models.User, ip string, userAgent
 			CkModify: user.ID.String(),
 		},
 	}
-	err := s.repo.CreateSession(session)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
-	}
-	return s.repo.GetSessionByID(session.CkId)
+	return s.sessions.Create(session)
 }
 
 func (s *KeycloakService) GetSessionByID(id uuid.UUID) (*models.TSession, error) {
-	session, ok := s.sessionStore.Get(id)
-	if ok {
-		return session, nil
-	}
-	session, err := s.repo.GetSessionByID(id)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get session: %w", err)
-	}
-	s.sessionStore.Set(id, session)
-	return session, nil
+	return s.sessions.Get(id)
 }
 
-func (s *KeycloakService) Logout(id uuid.UUID) error {
-	err := s.repo.DeleteSession(id)
-	if err != nil {
-		return fmt.Errorf("failed to delete session: %w", err)
+// Logout отзывает refresh токен сессии в Keycloak и удаляет сессию.
+// Ошибка отзыва не мешает выходу: токен всё равно перестанет храниться у нас.
+func (s *KeycloakService) Logout(ctx context.Context, session *models.TSession) error {
+	if session.Tokens != nil {
+		if err := s.revokeToken(ctx, session.Tokens); err != nil {
+			log.Printf("Failed to revoke refresh token of session %s: %v", session.CkId, err)
+		}
 	}
-	return nil
+	return s.deleteSession(session.CkId)
 }
 
+// GetCode обменивает код авторизации на токены и сохраняет пользователя в копии сессии
 func (s *KeycloakService) GetCode(ctx context.Context, code string, iss string, redirectUri string, session *models.TSession) (*models.User, error) {
 	tenant, ok := s.TenantsIss[iss]
 	if !ok {
 		return nil, fmt.Errorf("tenant not found: %s", iss)
 	}
-	resp, err := http.Post(tenant.GetTokenEndpoint(), "application/x-www-form-urlencoded", strings.NewReader(url.Values{
-		"code":          {code},
-		"client_id":     {tenant.ClientID},
-		"client_secret": {tenant.ClientSecret},
-		"grant_type":    {"authorization_code"},
-		"redirect_uri":  {redirectUri},
-	}.Encode()))
+	tokenResponse, err := s.requestToken(ctx, tenant, url.Values{
+		"code":         {code},
+		"grant_type":   {"authorization_code"},
+		"redirect_uri": {redirectUri},
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get code: %w", err)
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	session = copySession(session)
+	claims, err := s.storeTokens(ctx, tenant, session, tokenResponse)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-	var tokenResponse KeycloakTokenResponse
-	err = json.Unmarshal(body, &tokenResponse)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
-	}
-	claims, err := s.ValidateToken(ctx, tokenResponse.AccessToken, tenant.Iss)
-	if err != nil {
-		return nil, fmt.Errorf("failed to validate token: %w", err)
+		return nil, err
 	}
 	user, err := s.ConvertToLocalUser(claims, tenant)
 	if err != nil {
@@ -495,19 +444,24 @@ func (s *KeycloakService) GetCode(ctx context.Context, code string, iss string,
 	user.Data["claims"] = claims
 	session.User = *user
 	session.CkUser = &user.ID
-	err = s.repo.UpdateSession(session)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update session: %w", err)
+	if err := s.sessions.Update(session); err != nil {
+		return nil, err
 	}
-	s.sessionStore.Set(session.CkId, session)
 	return user, nil
 }
 
 func (s *KeycloakService) UpdateSession(session *models.TSession) error {
-	err := s.repo.UpdateSession(session)
-	if err != nil {
-		return fmt.Errorf("failed to update session: %w", err)
+	return s.sessions.Update(session)
+}
+
+// BindSession привязывает копию сессии к пользователю и сохраняет её до возврата,
+// чтобы следующий запрос по cookie уже видел пользователя; переданную сессию из кеша не меняет
+func (s *KeycloakService) BindSession(session *models.TSession, user *models.User) (*models.TSession, error) {
+	bound := copySession(session)
+	bound.CkUser = &user.ID
+	bound.User = *user
+	if err := s.UpdateSession(bound); err != nil {
+		return nil, fmt.Errorf("failed to bind session: %w", err)
 	}
-	s.sessionStore.Set(session.CkId, session)
-	return nil
+	return bound, nil
 }
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"parier-server/internal/models"
+	"parier-server/internal/util"
+
+	"github.com/google/uuid"
+)
+
+// SessionInfo describes a user's session (device) for the session list
+type SessionInfo struct {
+	ID         uuid.UUID `json:"id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Device     string    `json:"device"`
+	OS         string    `json:"os"`
+	Browser    string    `json:"browser"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastActive time.Time `json:"last_active"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// TouchSession records activity of the session; failures are only logged
+func (s *KeycloakService) TouchSession(session *models.TSession) {
+	if session.CkUser == nil {
+		return
+	}
+	if err := s.sessions.Touch(session); err != nil {
+		log.Printf("Failed to record activity of session %s: %v", session.CkId, err)
+	}
+}
+
+// ListSessions returns active sessions of the user, marking currentID
+func (s *KeycloakService) ListSessions(userID uuid.UUID, currentID uuid.UUID) ([]SessionInfo, error) {
+	sessions, err := s.sessions.ListByUser(userID)
+	if err != nil {
+		return nil, &ServiceError{
+			Code:    "DATABASE_ERROR",
+			Message: "Failed to get sessions",
+			Cause:   err,
+		}
+	}
+	result := make([]SessionInfo, len(sessions))
+	for i, session := range sessions {
+		agent := util.ParseUserAgent(session.CkUserAgent)
+		lastActive := session.CtCreate
+		if session.CtActivity != nil {
+			lastActive = *session.CtActivity
+		}
+		result[i] = SessionInfo{
+			ID:         session.CkId,
+			IP:         session.CkIp,
+			UserAgent:  session.CkUserAgent,
+			Device:     agent.Device,
+			OS:         agent.OS,
+			Browser:    agent.Browser,
+			CreatedAt:  session.CtCreate,
+			LastActive: lastActive,
+			ExpiresAt:  session.CtExpire,
+			Current:    session.CkId == currentID,
+		}
+	}
+	return result, nil
+}
+
+// RevokeSession logs out one session of the user
+func (s *KeycloakService) RevokeSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) error {
+	sessions, err := s.sessions.ListByUser(userID)
+	if err != nil {
+		return &ServiceError{
+			Code:    "DATABASE_ERROR",
+			Message: "Failed to get sessions",
+			Cause:   err,
+		}
+	}
+	for i := range sessions {
+		if sessions[i].CkId == sessionID {
+			return s.revokeSessions(ctx, sessions[i:i+1])
+		}
+	}
+	return &ServiceError{
+		Code:    "NOT_FOUND",
+		Message: "Session not found",
+	}
+}
+
+// RevokeOtherSessions logs out all sessions of the user except keepID ("log out everywhere else")
+func (s *KeycloakService) RevokeOtherSessions(ctx context.Context, userID uuid.UUID, keepID uuid.UUID) (int, error) {
+	sessions, err := s.sessions.ListByUser(userID)
+	if err != nil {
+		return 0, &ServiceError{
+			Code:    "DATABASE_ERROR",
+			Message: "Failed to get sessions",
+			Cause:   err,
+		}
+	}
+	others := make([]models.TSession, 0, len(sessions))
+	for _, session := range sessions {
+		if session.CkId != keepID {
+			others = append(others, session)
+		}
+	}
+	return len(others), s.revokeSessions(ctx, others)
+}
+
+// ForceLogout logs the user out of every session, for administrators
+func (s *KeycloakService) ForceLogout(ctx context.Context, userID uuid.UUID) (int, error) {
+	return s.RevokeOtherSessions(ctx, userID, uuid.Nil)
+}
+
+func (s *KeycloakService) revokeSessions(ctx context.Context, sessions []models.TSession) error {
+	for i := range sessions {
+		if err := s.Logout(ctx, &sessions[i]); err != nil {
+			return &ServiceError{
+				Code:    "DATABASE_ERROR",
+				Message: "Failed to revoke session",
+				Cause:   err,
+			}
+		}
+	}
+	return nil
+}
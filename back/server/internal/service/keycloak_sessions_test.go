@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"parier-server/internal/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memorySessionBackend keeps sessions in a map, for tests
+type memorySessionBackend struct {
+	sessions map[uuid.UUID]*models.TSession
+}
+
+func (m *memorySessionBackend) Create(session *models.TSession) (*models.TSession, error) {
+	m.sessions[session.CkId] = session
+	return session, nil
+}
+
+func (m *memorySessionBackend) Get(id uuid.UUID) (*models.TSession, error) {
+	if session, ok := m.sessions[id]; ok {
+		return session, nil
+	}
+	return nil, &ServiceError{Code: "NOT_FOUND", Message: "Session not found"}
+}
+
+func (m *memorySessionBackend) Update(session *models.TSession) error {
+	m.sessions[session.CkId] = session
+	return nil
+}
+
+func (m *memorySessionBackend) Delete(id uuid.UUID) error {
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *memorySessionBackend) ListByUser(userID uuid.UUID) ([]models.TSession, error) {
+	var result []models.TSession
+	for _, session := range m.sessions {
+		if session.CkUser != nil && *session.CkUser == userID {
+			result = append(result, *session)
+		}
+	}
+	return result, nil
+}
+
+func (m *memorySessionBackend) Touch(session *models.TSession) error {
+	now := time.Now()
+	session.CtActivity = &now
+	return nil
+}
+
+func TestUserSessions(t *testing.T) {
+	backend := &memorySessionBackend{sessions: make(map[uuid.UUID]*models.TSession)}
+	s := &KeycloakService{sessions: backend}
+	userID, otherUserID := uuid.New(), uuid.New()
+	add := func(user uuid.UUID, userAgent string) uuid.UUID {
+		session := &models.TSession{CkId: uuid.New(), CkUser: &user, CkUserAgent: userAgent, CtExpire: time.Now().Add(time.Hour)}
+		backend.Create(session)
+		return session.CkId
+	}
+	current := add(userID, "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1")
+	add(userID, "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36 Edg/120.0")
+	add(userID, "")
+	foreign := add(otherUserID, "")
+
+	sessions, err := s.ListSessions(userID, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("expected 3 sessions, got %d", len(sessions))
+	}
+	for _, info := range sessions {
+		if info.Current != (info.ID == current) {
+			t.Errorf("wrong current marker on %s", info.ID)
+		}
+		if info.Current && (info.Device != "mobile" || info.OS != "iOS" || info.Browser != "Safari") {
+			t.Errorf("unexpected device info %+v", info)
+		}
+	}
+
+	ctx := context.Background()
+	if err := s.RevokeSession(ctx, userID, foreign); !IsNotFoundError(err) {
+		t.Errorf("revoking another user's session should fail with NOT_FOUND, got %v", err)
+	}
+	revoked, err := s.RevokeOtherSessions(ctx, userID, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked != 2 {
+		t.Errorf("expected 2 revoked sessions, got %d", revoked)
+	}
+	if _, ok := backend.sessions[current]; !ok {
+		t.Error("current session was revoked")
+	}
+	if _, ok := backend.sessions[foreign]; !ok {
+		t.Error("another user's session was revoked")
+	}
+
+	if revoked, _ := s.ForceLogout(ctx, userID); revoked != 1 || len(backend.sessions) != 1 {
+		t.Errorf("force logout left %d sessions, revoked %d", len(backend.sessions), revoked)
+	}
+}
+
+// failingSessionBackend fails every update
+type failingSessionBackend struct {
+	memorySessionBackend
+}
+
+func (f *failingSessionBackend) Update(*models.TSession) error {
+	return &ServiceError{Code: "DATABASE_ERROR", Message: "Failed to update session"}
+}
+
+func TestBindSession(t *testing.T) {
+	backend := &memorySessionBackend{sessions: make(map[uuid.UUID]*models.TSession)}
+	s := &KeycloakService{sessions: backend}
+	session := &models.TSession{CkId: uuid.New(), CtExpire: time.Now().Add(time.Hour)}
+	user := &models.User{ID: uuid.New()}
+
+	bound, err := s.BindSession(session, user)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if session.CkUser != nil {
+		t.Error("the cached session must not be changed")
+	}
+	saved := backend.sessions[session.CkId]
+	if saved != bound || saved.CkUser == nil || *saved.CkUser != user.ID {
+		t.Error("the bound session must be saved before BindSession returns")
+	}
+
+	s.sessions = &failingSessionBackend{}
+	if _, err := s.BindSession(session, user); err == nil {
+		t.Error("expected the save error to be returned")
+	}
+}
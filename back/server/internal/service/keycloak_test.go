@@ -0,0 +1,43 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeycloakRoleCandidates(t *testing.T) {
+	claims := &KeycloakJWTClaims{
+		RealmAccess: KeycloakRealmAccess{Roles: []string{"viewer", "realm-admin", "offline_access"}},
+		ResourceAccess: map[string]KeycloakRealmAccess{
+			"parier": {Roles: []string{"moderator", "viewer"}},
+			"other":  {Roles: []string{"owner"}},
+		},
+	}
+	mappings := map[string]string{
+		"realm-admin":      "ADMIN",
+		"offline_access":   "",
+		"parier/moderator": "MANAGER",
+	}
+	got := keycloakRoleCandidates(claims, "parier", mappings, true)
+	want := []string{"VIEWER", "ADMIN", "MANAGER"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// only mapped roles are granted unless names are matched
+	got = keycloakRoleCandidates(claims, "parier", mappings, false)
+	want = []string{"ADMIN", "MANAGER"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got = keycloakRoleCandidates(claims, "other", nil, false); len(got) != 0 {
+		t.Errorf("unmapped roles must not be granted, got %v", got)
+	}
+
+	// without mappings role names are used as is
+	got = keycloakRoleCandidates(claims, "other", nil, true)
+	want = []string{"VIEWER", "REALM-ADMIN", "OFFLINE_ACCESS", "OWNER"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"parier-server/internal/config"
+	"parier-server/internal/models"
+	"parier-server/internal/util"
+
+	"github.com/google/uuid"
+)
+
+// sessionRefreshSkew - за сколько до истечения access токена сессия обновляется
+const sessionRefreshSkew = 30 * time.Second
+
+// ErrSessionExpired возвращается, когда Keycloak больше не принимает refresh токен сессии
+var ErrSessionExpired = errors.New("keycloak session expired")
+
+// keycloakTokenError - ответ token endpoint с кодом ошибки
+type keycloakTokenError struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *keycloakTokenError) Error() string {
+	return fmt.Sprintf("token endpoint returned %d: %s %s", e.StatusCode, e.Code, e.Description)
+}
+
+// copySession копирует сессию вместе с токенами. Сессию из кеша одновременно читают
+// другие запросы, поэтому меняется и сохраняется только копия
+func copySession(session *models.TSession) *models.TSession {
+	clone := *session
+	if session.Tokens != nil {
+		tokens := *session.Tokens
+		clone.Tokens = &tokens
+	}
+	return &clone
+}
+
+// RefreshSession обновляет токены сессии, если access токен истекает, и возвращает
+// обновлённую копию; переданная сессия не меняется.
+// Если Keycloak отклонил refresh токен, сессия удаляется и возвращается ErrSessionExpired.
+func (s *KeycloakService) RefreshSession(ctx context.Context, session *models.TSession) (*models.TSession, error) {
+	if !needsRefresh(session) {
+		return session, nil
+	}
+	lock := s.refreshLock(session.CkId)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Параллельный запрос или другой инстанс мог уже обновить сессию
+	if current, err := s.sessions.Get(session.CkId); err == nil {
+		session = current
+	}
+	if !needsRefresh(session) {
+		return session, nil
+	}
+
+	refreshed := copySession(session)
+	claims, tenant, err := s.refreshTokens(ctx, refreshed)
+	if err != nil {
+		if errors.Is(err, ErrSessionExpired) {
+			if err := s.deleteSession(session.CkId); err != nil {
+				log.Printf("Failed to delete expired session %s: %v", session.CkId, err)
+			}
+		}
+		return session, err
+	}
+	user, err := s.ConvertToLocalUser(claims, tenant)
+	if err != nil {
+		return session, fmt.Errorf("failed to convert user: %w", err)
+	}
+	user.SessionID = session.User.SessionID
+	user.Data["claims"] = claims
+	refreshed.User = *user
+	refreshed.CkUser = &user.ID
+	if err := s.UpdateSession(refreshed); err != nil {
+		return session, err
+	}
+	return refreshed, nil
+}
+
+func needsRefresh(session *models.TSession) bool {
+	return session.Tokens != nil && time.Until(session.Tokens.AccessExpire) < sessionRefreshSkew
+}
+
+// refreshTokens обменивает refresh токен сессии на новую пару токенов
+func (s *KeycloakService) refreshTokens(ctx context.Context, session *models.TSession) (*KeycloakJWTClaims, *config.TenantConfig, error) {
+	tenant, ok := s.TenantsIss[session.Tokens.Iss]
+	if !ok {
+		return nil, nil, fmt.Errorf("tenant not found: %s", session.Tokens.Iss)
+	}
+	if session.Tokens.RefreshExpire.Before(time.Now()) {
+		return nil, nil, ErrSessionExpired
+	}
+	refreshToken, err := util.DecryptString(session.Tokens.RefreshToken, s.cfg.Store.TokenSecret)
+	if err != nil {
+		// Секрет сменился - токен уже не восстановить
+		return nil, nil, fmt.Errorf("%w: failed to decrypt refresh token: %v", ErrSessionExpired, err)
+	}
+	resp, err := s.requestToken(ctx, tenant, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		var tokenErr *keycloakTokenError
+		if errors.As(err, &tokenErr) && (tokenErr.StatusCode == http.StatusBadRequest || tokenErr.StatusCode == http.StatusUnauthorized) {
+			return nil, nil, fmt.Errorf("%w: %v", ErrSessionExpired, err)
+		}
+		return nil, nil, fmt.Errorf("failed to refresh token: %w", err)
+	}
+	claims, err := s.storeTokens(ctx, tenant, session, resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	return claims, tenant, nil
+}
+
+// storeTokens проверяет access токен и сохраняет токены в сессию.
+// Срок жизни сессии совпадает со сроком жизни refresh токена.
+func (s *KeycloakService) storeTokens(ctx context.Context, tenant *config.TenantConfig, session *models.TSession, resp *KeycloakTokenResponse) (*KeycloakJWTClaims, error) {
+	claims, err := s.ValidateToken(ctx, resp.AccessToken, tenant.Iss)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate token: %w", err)
+	}
+	if resp.RefreshToken == "" {
+		session.Tokens = nil
+		return claims, nil
+	}
+	refreshToken, err := util.EncryptString(resp.RefreshToken, s.cfg.Store.TokenSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt refresh token: %w", err)
+	}
+	now := time.Now()
+	tokens := &models.SessionTokens{
+		Iss:           tenant.Iss,
+		RefreshToken:  refreshToken,
+		AccessExpire:  now.Add(time.Duration(resp.ExpiresIn) * time.Second),
+		RefreshExpire: now.Add(time.Duration(resp.RefreshExpiresIn) * time.Second),
+	}
+	// refresh_expires_in = 0 означает offline токен без срока
+	if resp.RefreshExpiresIn == 0 {
+		tokens.RefreshExpire = now.Add(s.cfg.Store.SessionDuration)
+	}
+	session.Tokens = tokens
+	session.CtExpire = tokens.RefreshExpire
+	return claims, nil
+}
+
+// requestToken выполняет запрос к token endpoint тенанта
+func (s *KeycloakService) requestToken(ctx context.Context, tenant *config.TenantConfig, form url.Values) (*KeycloakTokenResponse, error) {
+	form.Set("client_id", tenant.ClientID)
+	form.Set("client_secret", tenant.ClientSecret)
+	body, status, err := s.postForm(ctx, tenant.GetTokenEndpoint(), form)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		tokenErr := &keycloakTokenError{StatusCode: status}
+		_ = json.Unmarshal(body, tokenErr)
+		return nil, tokenErr
+	}
+	var tokenResponse KeycloakTokenResponse
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	return &tokenResponse, nil
+}
+
+// revokeToken отзывает refresh токен в Keycloak
+func (s *KeycloakService) revokeToken(ctx context.Context, tokens *models.SessionTokens) error {
+	tenant, ok := s.TenantsIss[tokens.Iss]
+	if !ok {
+		return fmt.Errorf("tenant not found: %s", tokens.Iss)
+	}
+	refreshToken, err := util.DecryptString(tokens.RefreshToken, s.cfg.Store.TokenSecret)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt refresh token: %w", err)
+	}
+	_, status, err := s.postForm(ctx, tenant.GetRevokeEndpoint(), url.Values{
+		"token":           {refreshToken},
+		"token_type_hint": {"refresh_token"},
+		"client_id":       {tenant.ClientID},
+		"client_secret":   {tenant.ClientSecret},
+	})
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("revoke endpoint returned %d", status)
+	}
+	return nil
+}
+
+func (s *KeycloakService) postForm(ctx context.Context, endpoint string, form url.Values) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return body, resp.StatusCode, nil
+}
+
+func (s *KeycloakService) refreshLock(id uuid.UUID) *sync.Mutex {
+	lock, _ := s.refreshLocks.LoadOrStore(id, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// deleteSession удаляет сессию после выхода или истечения
+func (s *KeycloakService) deleteSession(id uuid.UUID) error {
+	s.refreshLocks.Delete(id)
+	return s.sessions.Delete(id)
+}
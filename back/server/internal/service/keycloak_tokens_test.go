@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"parier-server/internal/config"
+	"parier-server/internal/models"
+	"parier-server/internal/util"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// fakeOIDC emulates the Keycloak token, revoke and certs endpoints of one realm
+type fakeOIDC struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu        sync.Mutex
+	refreshed []string // refresh tokens received by the token endpoint
+	revoked   []string
+	reject    bool // answer refresh requests with invalid_grant
+	issued    int
+}
+
+func newFakeOIDC(t *testing.T) *fakeOIDC {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeOIDC{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/realms/test/protocol/openid-connect/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if r.Form.Get("grant_type") == "refresh_token" {
+			f.refreshed = append(f.refreshed, r.Form.Get("refresh_token"))
+			if f.reject {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "Session not active"})
+				return
+			}
+		}
+		f.issued++
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, KeycloakJWTClaims{
+			PreferredUsername: "player",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "user-1",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+			},
+		})
+		token.Header["kid"] = "test"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Error(err)
+		}
+		json.NewEncoder(w).Encode(KeycloakTokenResponse{
+			AccessToken:      signed,
+			TokenType:        "Bearer",
+			ExpiresIn:        300,
+			RefreshToken:     fmt.Sprintf("refresh-%d", f.issued),
+			RefreshExpiresIn: 1800,
+		})
+	})
+	mux.HandleFunc("/realms/test/protocol/openid-connect/revoke", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		f.mu.Lock()
+		f.revoked = append(f.revoked, r.Form.Get("token"))
+		f.mu.Unlock()
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func newTestKeycloakService(f *fakeOIDC) (*KeycloakService, *config.TenantConfig) {
+	tenant := &config.TenantConfig{
+		// unique issuer, the JWKS cache is shared between tests
+		Iss:          f.URL + "/realms/test#" + uuid.NewString(),
+		Realm:        "test",
+		ClientID:     "parier",
+		ClientSecret: "secret",
+		JWTIssuer:    f.URL + "/realms/{realm}",
+		CertEndpoint: f.URL + "/certs",
+	}
+	return &KeycloakService{
+		cfg: &config.Config{
+			Store: config.StoreConfig{TokenSecret: "token-secret", SessionDuration: time.Hour},
+		},
+		httpClient: f.Client(),
+		TenantsIss: map[string]*config.TenantConfig{tenant.Iss: tenant},
+	}, tenant
+}
+
+func TestStoreTokensEncryptsRefreshToken(t *testing.T) {
+	f := newFakeOIDC(t)
+	s, tenant := newTestKeycloakService(f)
+	ctx := context.Background()
+
+	resp, err := s.requestToken(ctx, tenant, url.Values{"grant_type": {"authorization_code"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &models.TSession{CkId: uuid.New()}
+	claims, err := s.storeTokens(ctx, tenant, session, resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "user-1" {
+		t.Errorf("unexpected subject %q", claims.Subject)
+	}
+	if session.Tokens == nil || session.Tokens.RefreshToken == resp.RefreshToken {
+		t.Fatalf("refresh token is not encrypted: %+v", session.Tokens)
+	}
+	plain, err := util.DecryptString(session.Tokens.RefreshToken, "token-secret")
+	if err != nil || plain != resp.RefreshToken {
+		t.Errorf("expected %q, got %q (%v)", resp.RefreshToken, plain, err)
+	}
+	if d := time.Until(session.CtExpire); d < 29*time.Minute || d > 30*time.Minute {
+		t.Errorf("session expiry should follow refresh token lifetime, got %v", d)
+	}
+	if !session.CtExpire.Equal(session.Tokens.RefreshExpire) {
+		t.Error("session expiry differs from refresh token expiry")
+	}
+}
+
+func TestRefreshTokensRotatesRefreshToken(t *testing.T) {
+	f := newFakeOIDC(t)
+	s, tenant := newTestKeycloakService(f)
+	ctx := context.Background()
+
+	session := &models.TSession{CkId: uuid.New()}
+	resp, err := s.requestToken(ctx, tenant, url.Values{"grant_type": {"authorization_code"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.storeTokens(ctx, tenant, session, resp); err != nil {
+		t.Fatal(err)
+	}
+	session.Tokens.AccessExpire = time.Now()
+	if !needsRefresh(session) {
+		t.Fatal("expired access token should need refresh")
+	}
+
+	if _, _, err := s.refreshTokens(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.refreshed) != 1 || f.refreshed[0] != resp.RefreshToken {
+		t.Errorf("expected refresh with %q, got %v", resp.RefreshToken, f.refreshed)
+	}
+	plain, _ := util.DecryptString(session.Tokens.RefreshToken, "token-secret")
+	if plain == resp.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	if needsRefresh(session) {
+		t.Error("refreshed session should not need refresh")
+	}
+}
+
+func TestRefreshTokensRejected(t *testing.T) {
+	f := newFakeOIDC(t)
+	s, tenant := newTestKeycloakService(f)
+	ctx := context.Background()
+
+	session := &models.TSession{CkId: uuid.New()}
+	resp, _ := s.requestToken(ctx, tenant, url.Values{"grant_type": {"authorization_code"}})
+	if _, err := s.storeTokens(ctx, tenant, session, resp); err != nil {
+		t.Fatal(err)
+	}
+	f.reject = true
+	if _, _, err := s.refreshTokens(ctx, session); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("expected ErrSessionExpired, got %v", err)
+	}
+
+	f.reject = false
+	session.Tokens.RefreshExpire = time.Now().Add(-time.Second)
+	if _, _, err := s.refreshTokens(ctx, session); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("expected ErrSessionExpired for expired refresh token, got %v", err)
+	}
+	if len(f.refreshed) != 1 {
+		t.Errorf("expired refresh token should not reach Keycloak, got %d requests", len(f.refreshed))
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	f := newFakeOIDC(t)
+	s, tenant := newTestKeycloakService(f)
+	ctx := context.Background()
+
+	session := &models.TSession{CkId: uuid.New()}
+	resp, _ := s.requestToken(ctx, tenant, url.Values{"grant_type": {"authorization_code"}})
+	if _, err := s.storeTokens(ctx, tenant, session, resp); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.revokeToken(ctx, session.Tokens); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.revoked) != 1 || f.revoked[0] != resp.RefreshToken {
+		t.Errorf("expected %q to be revoked, got %v", resp.RefreshToken, f.revoked)
+	}
+}
+
+func TestRefreshCopyKeepsCachedSession(t *testing.T) {
+	f := newFakeOIDC(t)
+	s, tenant := newTestKeycloakService(f)
+	ctx := context.Background()
+
+	cached := &models.TSession{CkId: uuid.New()}
+	resp, err := s.requestToken(ctx, tenant, url.Values{"grant_type": {"authorization_code"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.storeTokens(ctx, tenant, cached, resp); err != nil {
+		t.Fatal(err)
+	}
+	cached.Tokens.AccessExpire = time.Now()
+	tokens, expire := *cached.Tokens, cached.CtExpire
+
+	refreshed := copySession(cached)
+	if _, _, err := s.refreshTokens(ctx, refreshed); err != nil {
+		t.Fatal(err)
+	}
+	if *cached.Tokens != tokens || !cached.CtExpire.Equal(expire) {
+		t.Error("refresh must not change the cached session")
+	}
+	if refreshed.Tokens == cached.Tokens || needsRefresh(refreshed) {
+		t.Error("refresh must store new tokens in the copy")
+	}
+}
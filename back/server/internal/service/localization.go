@@ -1,6 +1,7 @@
 package service
 
 import (
+	"math"
 	"parier-server/internal/models"
 	"parier-server/internal/repository"
 	"strings"
@@ -10,11 +11,12 @@ import (
 )
 
 type LocalizationService struct {
-	repo *repository.LocalizationRepository
+	repo  *repository.LocalizationRepository
+	audit *AuditService
 }
 
-func NewLocalizationService(repo *repository.LocalizationRepository) *LocalizationService {
-	return &LocalizationService{repo: repo}
+func NewLocalizationService(repo *repository.LocalizationRepository, audit *AuditService) *LocalizationService {
+	return &LocalizationService{repo: repo, audit: audit}
 }
 
 // === WORD MANAGEMENT ===
@@ -127,7 +129,8 @@ func (s *LocalizationService) DeleteWord(id uuid.UUID, userID string) error {
 
 // === LANGUAGE MANAGEMENT ===
 
-func (s *LocalizationService) CreateLanguage(req *CreateLanguageRequest, userID string, tx *gorm.DB) (*models.TDLang, error) {
+func (s *LocalizationService) CreateLanguage(req *CreateLanguageRequest, actor AuditActor, tx *gorm.DB) (*models.TDLang, error) {
+	userID := actor.User
 	if err := s.validateLanguageRequest(req); err != nil {
 		return nil, err
 	}
@@ -159,6 +162,10 @@ func (s *LocalizationService) CreateLanguage(req *CreateLanguageRequest, userID
 			Cause:   err,
 		}
 	}
+	err = s.audit.Record(tx, actor, AuditEntry{Action: AuditLanguageCreate, EntityType: "t_d_lang", EntityID: lang.CkId, After: lang})
+	if err != nil {
+		return nil, databaseError("Failed to record audit entry", err)
+	}
 
 	return lang, nil
 }
@@ -489,6 +496,243 @@ func (s *LocalizationService) DeleteLocalizationWord(localizationID string, lang
 	return nil
 }
 
+// === TRANSLATION MANAGEMENT ===
+
+// GetMissingTranslations lists keys of a namespace that have no translation in langID.
+// Each item carries the default language text as a reference for translators.
+func (s *LocalizationService) GetMissingTranslations(langID string, ns *string, offset, limit *int) ([]MissingTranslationResponse, int64, error) {
+	if _, err := s.repo.GetLanguageByID(langID); err != nil {
+		return nil, 0, &ServiceError{
+			Code:    "NOT_FOUND",
+			Message: "Language not found: " + langID,
+			Cause:   err,
+		}
+	}
+	localizations, total, err := s.repo.GetMissingTranslations(langID, ns, offset, limit)
+	if err != nil {
+		return nil, 0, &ServiceError{
+			Code:    "DATABASE_ERROR",
+			Message: "Failed to get missing translations",
+			Cause:   err,
+		}
+	}
+	defaultLang := s.repo.GetLanguageOrDefault(nil)
+	result := make([]MissingTranslationResponse, len(localizations))
+	for i, loc := range localizations {
+		item := MissingTranslationResponse{
+			Key:          loc.CkId,
+			Namespace:    string(loc.CrType),
+			Translations: make(map[string]string),
+		}
+		for _, locWord := range loc.LocalizationWords {
+			if locWord.TextWord == nil {
+				continue
+			}
+			item.Translations[locWord.CkLang] = locWord.TextWord.CvText
+			if locWord.CkLang == *defaultLang {
+				item.DefaultText = &locWord.TextWord.CvText
+			}
+		}
+		result[i] = item
+	}
+	return result, total, nil
+}
+
+// BulkUpdateTranslations applies all items in a single transaction.
+// An empty text removes the translation; unknown keys fail the whole batch
+// unless CreateMissingKeys is set.
+func (s *LocalizationService) BulkUpdateTranslations(req *BulkTranslationRequest, actor AuditActor) (*BulkTranslationResult, error) {
+	userID := actor.User
+	if len(req.Items) == 0 {
+		return nil, &ServiceError{
+			Code:    "VALIDATION_ERROR",
+			Message: "No translations to update",
+		}
+	}
+	ns := models.LocalizationTypeStatic
+	if req.Namespace != nil {
+		ns = models.LocalizationType(strings.ToUpper(*req.Namespace))
+		if !models.IsValidLocalizationType(ns) {
+			return nil, &ServiceError{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid namespace: " + *req.Namespace,
+			}
+		}
+	}
+
+	languages := make(map[string]bool)
+	for _, item := range req.Items {
+		lang := strings.ToUpper(item.Lang)
+		if languages[lang] {
+			continue
+		}
+		if _, err := s.repo.GetLanguageByID(lang); err != nil {
+			return nil, &ServiceError{
+				Code:    "NOT_FOUND",
+				Message: "Language not found: " + lang,
+				Cause:   err,
+			}
+		}
+		languages[lang] = true
+	}
+
+	result := &BulkTranslationResult{}
+	tx := s.repo.GetDB().Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, item := range req.Items {
+		lang := strings.ToUpper(item.Lang)
+		var loc models.TLocalization
+		err := tx.Where("ck_id = ? AND ct_delete IS NULL", item.Key).First(&loc).Error
+		if err == gorm.ErrRecordNotFound && req.CreateMissingKeys && strings.TrimSpace(item.Text) != "" {
+			loc = models.TLocalization{
+				CkId:   item.Key,
+				CrType: ns,
+				BaseModel: models.BaseModel{
+					CkCreate: userID,
+					CkModify: userID,
+				},
+			}
+			err = s.repo.CreateLocalization(&loc, tx)
+			result.Created++
+		}
+		if err != nil {
+			tx.Rollback()
+			if err == gorm.ErrRecordNotFound {
+				return nil, &ServiceError{
+					Code:    "NOT_FOUND",
+					Message: "Localization not found: " + item.Key,
+					Cause:   err,
+				}
+			}
+			return nil, &ServiceError{
+				Code:    "DATABASE_ERROR",
+				Message: "Failed to get localization: " + item.Key,
+				Cause:   err,
+			}
+		}
+
+		before, err := s.repo.GetTranslationText(loc.CkId, lang, tx)
+		if err != nil {
+			tx.Rollback()
+			return nil, &ServiceError{
+				Code:    "DATABASE_ERROR",
+				Message: "Failed to get translation: " + item.Key,
+				Cause:   err,
+			}
+		}
+		var after *string
+		if text := strings.TrimSpace(item.Text); text != "" {
+			after = &text
+		}
+		if (before == nil) != (after == nil) || (before != nil && *before != *after) {
+			err = s.audit.Record(tx, actor, AuditEntry{
+				Action:     AuditTranslationsUpdate,
+				EntityType: "t_localization_word",
+				EntityID:   loc.CkId + ":" + lang,
+				Before:     translationAudit(before),
+				After:      translationAudit(after),
+			})
+			if err != nil {
+				tx.Rollback()
+				return nil, databaseError("Failed to record audit entry", err)
+			}
+		}
+
+		if strings.TrimSpace(item.Text) == "" {
+			res := tx.Model(&models.TLocalizationWord{}).
+				Where("ck_localization = ? AND ck_lang = ? AND ct_delete IS NULL", loc.CkId, lang).
+				Updates(map[string]interface{}{"ct_delete": gorm.Expr("NOW()"), "ck_modify": userID})
+			if res.Error != nil {
+				tx.Rollback()
+				return nil, &ServiceError{
+					Code:    "DATABASE_ERROR",
+					Message: "Failed to remove translation: " + item.Key,
+					Cause:   res.Error,
+				}
+			}
+			result.Removed += int(res.RowsAffected)
+			continue
+		}
+
+		word, err := s.repo.GetOrCreateWord(strings.TrimSpace(item.Text), userID, tx)
+		if err != nil {
+			tx.Rollback()
+			return nil, &ServiceError{
+				Code:    "DATABASE_ERROR",
+				Message: "Failed to get or create word",
+				Cause:   err,
+			}
+		}
+		err = s.repo.UpsertLocalizationWord(&models.TLocalizationWord{
+			CkLocalization: loc.CkId,
+			CkLang:         lang,
+			CkText:         word.CkId,
+			BaseModel: models.BaseModel{
+				CkCreate: userID,
+				CkModify: userID,
+			},
+		}, tx)
+		if err != nil {
+			tx.Rollback()
+			return nil, &ServiceError{
+				Code:    "DATABASE_ERROR",
+				Message: "Failed to update translation: " + item.Key,
+				Cause:   err,
+			}
+		}
+		result.Updated++
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, &ServiceError{
+			Code:    "DATABASE_ERROR",
+			Message: "Failed to commit translations",
+			Cause:   err,
+		}
+	}
+	s.repo.Invalidate()
+	return result, nil
+}
+
+// translationAudit - состояние перевода для журнала аудита; nil - перевода нет
+func translationAudit(text *string) any {
+	if text == nil {
+		return nil
+	}
+	return map[string]string{"cv_text": *text}
+}
+
+// GetTranslationCoverage returns translated/total key counts with a percentage
+// for every active language, grouped by namespace
+func (s *LocalizationService) GetTranslationCoverage(ns *string) ([]TranslationCoverageResponse, error) {
+	coverage, err := s.repo.GetTranslationCoverage(ns)
+	if err != nil {
+		return nil, &ServiceError{
+			Code:    "DATABASE_ERROR",
+			Message: "Failed to get translation coverage",
+			Cause:   err,
+		}
+	}
+	result := make([]TranslationCoverageResponse, len(coverage))
+	for i, c := range coverage {
+		percent := 0.0
+		if c.Total > 0 {
+			percent = math.Round(float64(c.Translated)/float64(c.Total)*10000) / 100
+		}
+		result[i] = TranslationCoverageResponse{
+			TranslationCoverage: c,
+			Missing:             c.Total - c.Translated,
+			Percent:             percent,
+		}
+	}
+	return result, nil
+}
+
 // === HELPER METHODS ===
 
 func (s *LocalizationService) GetLocalizedText(localizationID string, langID string) (string, error) {
@@ -595,6 +839,37 @@ type UpdateLanguageRequest struct {
 	BrowserCode *string `json:"browser_code,omitempty"`
 }
 
+type MissingTranslationResponse struct {
+	Key          string            `json:"key"`
+	Namespace    string            `json:"namespace"`
+	DefaultText  *string           `json:"default_text,omitempty"`
+	Translations map[string]string `json:"translations"`
+}
+
+type TranslationUpdate struct {
+	Key  string `json:"key" binding:"required"`
+	Lang string `json:"lang" binding:"required"`
+	Text string `json:"text"`
+}
+
+type BulkTranslationRequest struct {
+	Namespace         *string             `json:"namespace,omitempty"`
+	CreateMissingKeys bool                `json:"create_missing_keys"`
+	Items             []TranslationUpdate `json:"items" binding:"required,min=1,dive"`
+}
+
+type BulkTranslationResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Removed int `json:"removed"`
+}
+
+type TranslationCoverageResponse struct {
+	repository.TranslationCoverage
+	Missing int64   `json:"missing"`
+	Percent float64 `json:"percent"`
+}
+
 type ServiceError struct {
 	Code    string
 	Message string
@@ -0,0 +1,598 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"parier-server/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LocaleFormat is a translation file format supported by import/export
+type LocaleFormat string
+
+const (
+	LocaleFormatJSON  LocaleFormat = "json"
+	LocaleFormatPO    LocaleFormat = "po"
+	LocaleFormatXLIFF LocaleFormat = "xliff"
+)
+
+func ParseLocaleFormat(format string) (LocaleFormat, error) {
+	switch LocaleFormat(strings.ToLower(format)) {
+	case "", LocaleFormatJSON:
+		return LocaleFormatJSON, nil
+	case LocaleFormatPO:
+		return LocaleFormatPO, nil
+	case LocaleFormatXLIFF:
+		return LocaleFormatXLIFF, nil
+	}
+	return "", &ServiceError{
+		Code:    "VALIDATION_ERROR",
+		Message: "Unsupported locale format: " + format,
+	}
+}
+
+func (f LocaleFormat) ContentType() string {
+	switch f {
+	case LocaleFormatPO:
+		return "text/x-gettext-translation; charset=utf-8"
+	case LocaleFormatXLIFF:
+		return "application/xliff+xml; charset=utf-8"
+	}
+	return "application/json; charset=utf-8"
+}
+
+func (f LocaleFormat) Extension() string {
+	if f == LocaleFormatXLIFF {
+		return "xlf"
+	}
+	return string(f)
+}
+
+// LocaleEntry is a single translation unit of a bundle.
+// Source is the default language text, used by PO and XLIFF only.
+type LocaleEntry struct {
+	Key    string
+	Source *string
+	Target string
+}
+
+// LocaleBundle is the format-independent content of a translation file
+type LocaleBundle struct {
+	SourceLang string
+	TargetLang string
+	Namespace  string
+	Entries    []LocaleEntry
+}
+
+type LocaleImportChange struct {
+	Key     string  `json:"key"`
+	OldText *string `json:"old_text,omitempty"`
+	NewText string  `json:"new_text"`
+}
+
+type LocaleImportConflict struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+}
+
+type LocaleImportResult struct {
+	DryRun    bool                   `json:"dry_run"`
+	Applied   bool                   `json:"applied"`
+	Added     []LocaleImportChange   `json:"added"`
+	Changed   []LocaleImportChange   `json:"changed"`
+	Conflicts []LocaleImportConflict `json:"conflicts"`
+	Unchanged int                    `json:"unchanged"`
+}
+
+const (
+	ConflictNamespaceMismatch = "NAMESPACE_MISMATCH"
+	ConflictSourceChanged     = "SOURCE_CHANGED"
+	ConflictDuplicateKey      = "DUPLICATE_KEY"
+	ConflictUnknownKey        = "UNKNOWN_KEY"
+)
+
+// ExportLocales renders every key of the namespace for langID. Keys without
+// a translation are exported with an empty target so translators can fill them in.
+func (s *LocalizationService) ExportLocales(langID string, ns string, format LocaleFormat) ([]byte, error) {
+	bundle, err := s.getLocaleBundle(langID, ns)
+	if err != nil {
+		return nil, err
+	}
+	var content []byte
+	switch format {
+	case LocaleFormatPO:
+		content = EncodePO(bundle)
+	case LocaleFormatXLIFF:
+		content, err = EncodeXLIFF(bundle)
+	default:
+		content, err = EncodeLocaleJSON(bundle)
+	}
+	if err != nil {
+		return nil, &ServiceError{
+			Code:    "INTERNAL_ERROR",
+			Message: "Failed to encode locale bundle",
+			Cause:   err,
+		}
+	}
+	return content, nil
+}
+
+// ImportLocales diffs the file against the stored translations and, unless
+// dryRun is set, applies added and changed keys in a single transaction.
+// Conflicting keys are reported and never applied. Keys missing from the
+// namespace are conflicts too unless createKeys is set, so a typo in the file
+// does not create a new key.
+func (s *LocalizationService) ImportLocales(langID string, ns string, format LocaleFormat, content []byte, dryRun bool, createKeys bool, actor AuditActor) (*LocaleImportResult, error) {
+	var bundle *LocaleBundle
+	var err error
+	switch format {
+	case LocaleFormatPO:
+		bundle, err = DecodePO(content)
+	case LocaleFormatXLIFF:
+		bundle, err = DecodeXLIFF(content)
+	default:
+		bundle, err = DecodeLocaleJSON(content)
+	}
+	if err != nil {
+		return nil, &ServiceError{
+			Code:    "INVALID_FORMAT",
+			Message: "Failed to parse locale file",
+			Cause:   err,
+		}
+	}
+
+	if bundle.TargetLang != "" && !strings.EqualFold(bundle.TargetLang, langID) {
+		return nil, &ServiceError{
+			Code:    "VALIDATION_ERROR",
+			Message: fmt.Sprintf("File is for language %s, not %s", bundle.TargetLang, langID),
+		}
+	}
+	if bundle.Namespace != "" && !strings.EqualFold(bundle.Namespace, ns) {
+		return nil, &ServiceError{
+			Code:    "VALIDATION_ERROR",
+			Message: fmt.Sprintf("File is for namespace %s, not %s", bundle.Namespace, ns),
+		}
+	}
+
+	current, err := s.getLocaleBundle(langID, ns)
+	if err != nil {
+		return nil, err
+	}
+	defaultLang := *s.repo.GetLanguageOrDefault(nil)
+	sources := make(map[string]string)
+	if defaultLang != langID {
+		defaults, _, err := s.repo.GetLocales(&defaultLang, &ns)
+		if err != nil {
+			return nil, &ServiceError{
+				Code:    "DATABASE_ERROR",
+				Message: "Failed to get default language translations",
+				Cause:   err,
+			}
+		}
+		sources = defaults
+	}
+	targets := make(map[string]string)
+	known := make(map[string]bool)
+	for _, entry := range current.Entries {
+		known[entry.Key] = true
+		if entry.Target != "" {
+			targets[entry.Key] = entry.Target
+		}
+	}
+	namespaces, err := s.repo.GetLocalizationNamespaces(entryKeys(bundle.Entries))
+	if err != nil {
+		return nil, &ServiceError{
+			Code:    "DATABASE_ERROR",
+			Message: "Failed to get localization keys",
+			Cause:   err,
+		}
+	}
+
+	result := &LocaleImportResult{
+		DryRun:    dryRun,
+		Added:     make([]LocaleImportChange, 0),
+		Changed:   make([]LocaleImportChange, 0),
+		Conflicts: make([]LocaleImportConflict, 0),
+	}
+	seen := make(map[string]string)
+	for _, entry := range bundle.Entries {
+		if entry.Key == "" || strings.TrimSpace(entry.Target) == "" {
+			continue
+		}
+		if previous, ok := seen[entry.Key]; ok {
+			if previous != entry.Target {
+				result.Conflicts = append(result.Conflicts, LocaleImportConflict{
+					Key:    entry.Key,
+					Reason: ConflictDuplicateKey,
+					Detail: "Key appears more than once with different translations",
+				})
+			}
+			continue
+		}
+		seen[entry.Key] = entry.Target
+		if keyNs, ok := namespaces[entry.Key]; ok && !known[entry.Key] {
+			result.Conflicts = append(result.Conflicts, LocaleImportConflict{
+				Key:    entry.Key,
+				Reason: ConflictNamespaceMismatch,
+				Detail: fmt.Sprintf("Key belongs to namespace %s", keyNs),
+			})
+			continue
+		}
+		if !known[entry.Key] && !createKeys {
+			result.Conflicts = append(result.Conflicts, LocaleImportConflict{
+				Key:    entry.Key,
+				Reason: ConflictUnknownKey,
+				Detail: "Key does not exist; import with create_keys to create it",
+			})
+			continue
+		}
+		if source, ok := sources[entry.Key]; ok && entry.Source != nil && *entry.Source != source {
+			result.Conflicts = append(result.Conflicts, LocaleImportConflict{
+				Key:    entry.Key,
+				Reason: ConflictSourceChanged,
+				Detail: fmt.Sprintf("Source text changed to %q since the file was exported", source),
+			})
+			continue
+		}
+		old, ok := targets[entry.Key]
+		switch {
+		case !ok:
+			result.Added = append(result.Added, LocaleImportChange{Key: entry.Key, NewText: entry.Target})
+		case old != entry.Target:
+			result.Changed = append(result.Changed, LocaleImportChange{Key: entry.Key, OldText: &old, NewText: entry.Target})
+		default:
+			result.Unchanged++
+		}
+	}
+	// a key that turned out to be duplicated must not be applied with either value
+	duplicates := make(map[string]bool)
+	for _, conflict := range result.Conflicts {
+		if conflict.Reason == ConflictDuplicateKey {
+			duplicates[conflict.Key] = true
+		}
+	}
+	result.Added = withoutKeys(result.Added, duplicates)
+	result.Changed = withoutKeys(result.Changed, duplicates)
+
+	if dryRun || len(result.Added)+len(result.Changed) == 0 {
+		return result, nil
+	}
+	req := &BulkTranslationRequest{
+		Namespace:         &ns,
+		CreateMissingKeys: createKeys,
+	}
+	for _, change := range append(result.Added, result.Changed...) {
+		req.Items = append(req.Items, TranslationUpdate{Key: change.Key, Lang: langID, Text: change.NewText})
+	}
+	if _, err := s.BulkUpdateTranslations(req, actor); err != nil {
+		return nil, err
+	}
+	result.Applied = true
+	return result, nil
+}
+
+func (s *LocalizationService) getLocaleBundle(langID string, ns string) (*LocaleBundle, error) {
+	if _, err := s.repo.GetLanguageByID(langID); err != nil {
+		return nil, &ServiceError{
+			Code:    "NOT_FOUND",
+			Message: "Language not found: " + langID,
+			Cause:   err,
+		}
+	}
+	if !models.IsValidLocalizationType(models.LocalizationType(ns)) {
+		return nil, &ServiceError{
+			Code:    "VALIDATION_ERROR",
+			Message: "Invalid namespace: " + ns,
+		}
+	}
+	defaultLang := *s.repo.GetLanguageOrDefault(nil)
+	keys, err := s.repo.GetLocalizationKeys(ns)
+	if err != nil {
+		return nil, &ServiceError{
+			Code:    "DATABASE_ERROR",
+			Message: "Failed to get localization keys",
+			Cause:   err,
+		}
+	}
+	targets, _, err := s.repo.GetLocales(&langID, &ns)
+	if err != nil {
+		return nil, &ServiceError{
+			Code:    "DATABASE_ERROR",
+			Message: "Failed to get translations",
+			Cause:   err,
+		}
+	}
+	sources, _, err := s.repo.GetLocales(&defaultLang, &ns)
+	if err != nil {
+		return nil, &ServiceError{
+			Code:    "DATABASE_ERROR",
+			Message: "Failed to get default language translations",
+			Cause:   err,
+		}
+	}
+	bundle := &LocaleBundle{
+		SourceLang: strings.ToLower(defaultLang),
+		TargetLang: strings.ToLower(langID),
+		Namespace:  ns,
+		Entries:    make([]LocaleEntry, 0, len(keys)),
+	}
+	for _, key := range keys {
+		entry := LocaleEntry{Key: key, Target: targets[key]}
+		if source, ok := sources[key]; ok {
+			entry.Source = &source
+		}
+		bundle.Entries = append(bundle.Entries, entry)
+	}
+	return bundle, nil
+}
+
+func entryKeys(entries []LocaleEntry) []string {
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		keys = append(keys, entry.Key)
+	}
+	return keys
+}
+
+func withoutKeys(changes []LocaleImportChange, keys map[string]bool) []LocaleImportChange {
+	result := make([]LocaleImportChange, 0, len(changes))
+	for _, change := range changes {
+		if !keys[change.Key] {
+			result = append(result, change)
+		}
+	}
+	return result
+}
+
+// === i18next JSON ===
+
+// EncodeLocaleJSON writes a flat i18next resource (keySeparator: false), the
+// same shape served by GET /core/locales/:lang/:ns. Untranslated keys are skipped.
+func EncodeLocaleJSON(bundle *LocaleBundle) ([]byte, error) {
+	resource := make(map[string]string)
+	for _, entry := range bundle.Entries {
+		if entry.Target != "" {
+			resource[entry.Key] = entry.Target
+		}
+	}
+	return json.MarshalIndent(resource, "", "  ")
+}
+
+// DecodeLocaleJSON accepts both flat and nested i18next resources;
+// nested objects are flattened with "." as the key separator
+func DecodeLocaleJSON(content []byte) (*LocaleBundle, error) {
+	var resource map[string]interface{}
+	if err := json.Unmarshal(content, &resource); err != nil {
+		return nil, err
+	}
+	bundle := &LocaleBundle{}
+	if err := flattenLocaleJSON("", resource, bundle); err != nil {
+		return nil, err
+	}
+	sort.Slice(bundle.Entries, func(i, j int) bool { return bundle.Entries[i].Key < bundle.Entries[j].Key })
+	return bundle, nil
+}
+
+func flattenLocaleJSON(prefix string, resource map[string]interface{}, bundle *LocaleBundle) error {
+	for key, value := range resource {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case string:
+			bundle.Entries = append(bundle.Entries, LocaleEntry{Key: key, Target: v})
+		case map[string]interface{}:
+			if err := flattenLocaleJSON(key, v, bundle); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported value for key %q", key)
+		}
+	}
+	return nil
+}
+
+// === gettext PO ===
+
+// EncodePO writes msgctxt as the key, msgid as the default language text
+// and msgstr as the translation
+func EncodePO(bundle *LocaleBundle) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("msgid \"\"\nmsgstr \"\"\n")
+	buf.WriteString(poQuote("Language: "+bundle.TargetLang+"\n") + "\n")
+	buf.WriteString(poQuote("Content-Type: text/plain; charset=UTF-8\n") + "\n")
+	buf.WriteString(poQuote("X-Source-Language: "+bundle.SourceLang+"\n") + "\n")
+	buf.WriteString(poQuote("X-Namespace: "+bundle.Namespace+"\n") + "\n")
+	for _, entry := range bundle.Entries {
+		source := ""
+		if entry.Source != nil {
+			source = *entry.Source
+		}
+		buf.WriteString("\nmsgctxt " + poQuote(entry.Key) + "\n")
+		buf.WriteString("msgid " + poQuote(source) + "\n")
+		buf.WriteString("msgstr " + poQuote(entry.Target) + "\n")
+	}
+	return buf.Bytes()
+}
+
+func DecodePO(content []byte) (*LocaleBundle, error) {
+	bundle := &LocaleBundle{}
+	var ctx, id, str *string
+	var current **string
+	flush := func() {
+		if id != nil && str != nil {
+			if *id == "" && ctx == nil {
+				parsePOHeader(*str, bundle)
+			} else {
+				key := *id
+				var source *string
+				if ctx != nil {
+					key = *ctx
+					source = id
+				}
+				bundle.Entries = append(bundle.Entries, LocaleEntry{Key: key, Source: source, Target: *str})
+			}
+		}
+		ctx, id, str, current = nil, nil, nil, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		var keyword string
+		switch {
+		case text == "" || strings.HasPrefix(text, "#"):
+			continue
+		case strings.HasPrefix(text, "msgctxt "):
+			keyword = "msgctxt"
+		case strings.HasPrefix(text, "msgid_plural "), strings.HasPrefix(text, "msgstr["):
+			return nil, fmt.Errorf("line %d: plural forms are not supported", line)
+		case strings.HasPrefix(text, "msgid "):
+			keyword = "msgid"
+		case strings.HasPrefix(text, "msgstr "):
+			keyword = "msgstr"
+		case strings.HasPrefix(text, "\""):
+			if current == nil || *current == nil {
+				return nil, fmt.Errorf("line %d: unexpected string continuation", line)
+			}
+			value, err := strconv.Unquote(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			**current += value
+			continue
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q", line, text)
+		}
+		value, err := strconv.Unquote(strings.TrimSpace(strings.TrimPrefix(text, keyword)))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		switch keyword {
+		case "msgctxt":
+			flush()
+			ctx = &value
+			current = &ctx
+		case "msgid":
+			if id != nil {
+				flush()
+			}
+			id = &value
+			current = &id
+		case "msgstr":
+			str = &value
+			current = &str
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return bundle, nil
+}
+
+func parsePOHeader(header string, bundle *LocaleBundle) {
+	for _, line := range strings.Split(header, "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(name) {
+		case "Language":
+			bundle.TargetLang = value
+		case "X-Source-Language":
+			bundle.SourceLang = value
+		case "X-Namespace":
+			bundle.Namespace = value
+		}
+	}
+}
+
+func poQuote(value string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n", "\t", "\\t", "\r", "\\r")
+	return "\"" + replacer.Replace(value) + "\""
+}
+
+// === XLIFF 1.2 ===
+
+type xliffDocument struct {
+	XMLName xml.Name  `xml:"urn:oasis:names:tc:xliff:document:1.2 xliff"`
+	Version string    `xml:"version,attr"`
+	File    xliffFile `xml:"file"`
+}
+
+type xliffFile struct {
+	SourceLanguage string           `xml:"source-language,attr"`
+	TargetLanguage string           `xml:"target-language,attr,omitempty"`
+	Datatype       string           `xml:"datatype,attr"`
+	Original       string           `xml:"original,attr"`
+	Units          []xliffTransUnit `xml:"body>trans-unit"`
+}
+
+type xliffTransUnit struct {
+	ID     string  `xml:"id,attr"`
+	Source *string `xml:"source"`
+	Target *string `xml:"target"`
+}
+
+// EncodeXLIFF writes an XLIFF 1.2 document; the namespace is stored in file@original
+func EncodeXLIFF(bundle *LocaleBundle) ([]byte, error) {
+	doc := xliffDocument{
+		Version: "1.2",
+		File: xliffFile{
+			SourceLanguage: bundle.SourceLang,
+			TargetLanguage: bundle.TargetLang,
+			Datatype:       "plaintext",
+			Original:       bundle.Namespace,
+			Units:          make([]xliffTransUnit, 0, len(bundle.Entries)),
+		},
+	}
+	for _, entry := range bundle.Entries {
+		unit := xliffTransUnit{ID: entry.Key}
+		// XLIFF requires <source>, so an entry without a source text gets an empty one
+		source := ""
+		if entry.Source != nil {
+			source = *entry.Source
+		}
+		unit.Source = &source
+		target := entry.Target
+		unit.Target = &target
+		doc.File.Units = append(doc.File.Units, unit)
+	}
+	content, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), content...), nil
+}
+
+func DecodeXLIFF(content []byte) (*LocaleBundle, error) {
+	var doc xliffDocument
+	if err := xml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	bundle := &LocaleBundle{
+		SourceLang: doc.File.SourceLanguage,
+		TargetLang: doc.File.TargetLanguage,
+		Namespace:  doc.File.Original,
+		Entries:    make([]LocaleEntry, 0, len(doc.File.Units)),
+	}
+	for _, unit := range doc.File.Units {
+		// a unit without <source> is not compared with the current source text
+		entry := LocaleEntry{Key: unit.ID, Source: unit.Source}
+		if unit.Target != nil {
+			entry.Target = *unit.Target
+		}
+		bundle.Entries = append(bundle.Entries, entry)
+	}
+	return bundle, nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testLocaleBundle() *LocaleBundle {
+	source := "Hello, \"world\"\nsecond line"
+	empty := ""
+	return &LocaleBundle{
+		SourceLang: "en",
+		TargetLang: "ru",
+		Namespace:  "STATIC",
+		Entries: []LocaleEntry{
+			{Key: "greeting", Source: &source, Target: "Привет, \"мир\"\nвторая строка"},
+			{Key: "menu.bets", Source: &empty, Target: "Ставки <b>&amp;</b>"},
+		},
+	}
+}
+
+func TestLocaleBundle_RoundTrip(t *testing.T) {
+	codecs := map[string]struct {
+		encode func(*LocaleBundle) ([]byte, error)
+		decode func([]byte) (*LocaleBundle, error)
+	}{
+		"po":    {func(b *LocaleBundle) ([]byte, error) { return EncodePO(b), nil }, DecodePO},
+		"xliff": {EncodeXLIFF, DecodeXLIFF},
+	}
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			bundle := testLocaleBundle()
+			content, err := codec.encode(bundle)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			decoded, err := codec.decode(content)
+			if err != nil {
+				t.Fatalf("decode: %v\n%s", err, content)
+			}
+			if !reflect.DeepEqual(bundle, decoded) {
+				t.Errorf("round trip mismatch:\nwant %+v\ngot  %+v\n%s", bundle, decoded, content)
+			}
+		})
+	}
+}
+
+func TestDecodeLocaleJSON_Nested(t *testing.T) {
+	bundle, err := DecodeLocaleJSON([]byte(`{"menu": {"bets": "Bets", "chat": {"title": "Chat"}}, "flat.key": "Flat"}`))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []LocaleEntry{
+		{Key: "flat.key", Target: "Flat"},
+		{Key: "menu.bets", Target: "Bets"},
+		{Key: "menu.chat.title", Target: "Chat"},
+	}
+	if !reflect.DeepEqual(bundle.Entries, want) {
+		t.Errorf("unexpected entries: %+v", bundle.Entries)
+	}
+	if _, err := DecodeLocaleJSON([]byte(`{"count": 1}`)); err == nil {
+		t.Error("expected error for non-string value")
+	}
+}
+
+func TestDecodeXLIFF_WithoutSource(t *testing.T) {
+	bundle, err := DecodeXLIFF([]byte(`<xliff xmlns="urn:oasis:names:tc:xliff:document:1.2" version="1.2"><file source-language="en" target-language="ru" original="STATIC"><body>
+<trans-unit id="greeting"><target>Привет</target></trans-unit>
+<trans-unit id="menu.bets"><source></source><target>Ставки</target></trans-unit>
+</body></file></xliff>`))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(bundle.Entries) != 2 {
+		t.Fatalf("unexpected entries: %+v", bundle.Entries)
+	}
+	if bundle.Entries[0].Source != nil {
+		t.Errorf("expected no source for a unit without <source>, got %q", *bundle.Entries[0].Source)
+	}
+	if bundle.Entries[1].Source == nil || *bundle.Entries[1].Source != "" {
+		t.Errorf("expected an empty source for an empty <source>, got %v", bundle.Entries[1].Source)
+	}
+}
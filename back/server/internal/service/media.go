@@ -2,35 +2,30 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
+	"os"
 	"parier-server/internal/config"
 	"parier-server/internal/models"
+	"parier-server/internal/module/storage"
 	"parier-server/internal/repository"
-	"os"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/google/uuid"
 )
 
 type MediaService struct {
-	repo       *repository.MediaRepository
-	LocRepo    *repository.LocalizationRepository
-	s3Client   *s3.Client
-	uploader   *manager.Uploader
-	downloader *manager.Downloader
-	bucket     string
-	config     *config.S3Config
-	cache      *CacheResponse
+	repo              *repository.MediaRepository
+	LocRepo           *repository.LocalizationRepository
+	Resolver          *repository.LocalizationResolver
+	store             storage.BlobStore
+	presignExpiration time.Duration
+	cache             *CacheResponse
 }
 
 type CacheDownloadResponse struct {
@@ -137,42 +132,7 @@ func (c *CacheResponse) Cleanup() {
 	}
 }
 
-func NewMediaService(repo *repository.MediaRepository, LocRepo *repository.LocalizationRepository, s3Config *config.S3Config, config *config.Config) (*MediaService, error) {
-	// Configure AWS credentials
-	var creds aws.CredentialsProvider
-	if s3Config.AccessKey != "" && s3Config.SecretKey != "" {
-		creds = credentials.NewStaticCredentialsProvider(s3Config.AccessKey, s3Config.SecretKey, "")
-	} else {
-		// Use default credential chain (environment variables, IAM role, etc.)
-		creds = nil
-	}
-
-	// Create AWS config
-	cfg := aws.Config{
-		Region:      s3Config.Region,
-		Credentials: creds,
-	}
-
-	// Configure custom endpoint for MinIO or other S3-compatible services
-	if s3Config.Endpoint != "" {
-		cfg.EndpointResolverWithOptions = aws.EndpointResolverWithOptionsFunc(
-			func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-				return aws.Endpoint{
-					URL:           s3Config.Endpoint,
-					SigningRegion: region,
-				}, nil
-			})
-	}
-
-	// Create S3 client
-	s3Client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.UsePathStyle = s3Config.ForcePathStyle
-	})
-
-	// Create uploader and downloader
-	uploader := manager.NewUploader(s3Client)
-	downloader := manager.NewDownloader(s3Client)
-
+func NewMediaService(repo *repository.MediaRepository, LocRepo *repository.LocalizationRepository, resolver *repository.LocalizationResolver, store storage.BlobStore, config *config.Config) (*MediaService, error) {
 	cache := NewCacheResponse(config)
 	go func() {
 		for {
@@ -182,14 +142,12 @@ func NewMediaService(repo *repository.MediaRepository, LocRepo *repository.Local
 	}()
 
 	return &MediaService{
-		repo:       repo,
-		LocRepo:    LocRepo,
-		s3Client:   s3Client,
-		uploader:   uploader,
-		downloader: downloader,
-		bucket:     s3Config.Bucket,
-		config:     s3Config,
-		cache:      cache,
+		repo:              repo,
+		LocRepo:           LocRepo,
+		Resolver:          resolver,
+		store:             store,
+		presignExpiration: config.Storage.PresignExpiration,
+		cache:             cache,
 	}, nil
 }
 
@@ -217,24 +175,12 @@ func (s *MediaService) UploadFile(ctx context.Context, req *models.UploadRequest
 	// Reset file cursor
 	req.File.Seek(0, 0)
 
-	// Upload to S3
-	uploadInput := &s3.PutObjectInput{
-		Bucket:      aws.String(s.bucket),
-		Key:         aws.String(s3Key),
-		Body:        req.File,
-		ContentType: aws.String(req.ContentType),
-		Metadata: map[string]string{
-			"original-filename": req.Filename,
-			"uploaded-by":       req.UserID,
-			"upload-time":       time.Now().Format(time.RFC3339),
-		},
-	}
-
-	_, err = s.uploader.Upload(ctx, uploadInput)
+	// Upload to storage
+	err = s.store.Put(ctx, s3Key, req.File, req.ContentType, uploadMetadata(req.Filename, req.UserID))
 	if err != nil {
 		return nil, &ServiceError{
 			Code:    "S3_UPLOAD_ERROR",
-			Message: "Failed to upload file to S3",
+			Message: "Failed to upload file to storage",
 			Cause:   err,
 		}
 	}
@@ -264,8 +210,8 @@ func (s *MediaService) UploadFile(ctx context.Context, req *models.UploadRequest
 
 	err = s.repo.CreateMedia(mediaRecord)
 	if err != nil {
-		// If database save fails, try to clean up S3 file
-		s.deleteFromS3(ctx, s3Key)
+		// If database save fails, try to clean up stored file
+		s.store.Delete(ctx, s3Key)
 		return nil, &ServiceError{
 			Code:    "DB_SAVE_ERROR",
 			Message: "Failed to save media metadata to database",
@@ -274,7 +220,7 @@ func (s *MediaService) UploadFile(ctx context.Context, req *models.UploadRequest
 	}
 
 	// Generate public URL
-	publicURL := s.getPublicURL(s3Key)
+	publicURL := s.store.PublicURL(s3Key)
 
 	return &models.UploadResponse{
 		MediaID: mediaID,
@@ -294,7 +240,7 @@ func (s *MediaService) UpdateFile(ctx context.Context, mediaID uuid.UUID, req *m
 		}
 	}
 	if media.CvUrl != "" {
-		s.deleteFromS3(ctx, media.CvUrl)
+		s.store.Delete(ctx, media.CvUrl)
 	}
 	// Generate S3 key (path)
 	s3Key := fmt.Sprintf("/media/%s/%s", mediaID.String(), req.Filename)
@@ -315,29 +261,17 @@ func (s *MediaService) UpdateFile(ctx context.Context, mediaID uuid.UUID, req *m
 	// Reset file cursor
 	req.File.Seek(0, 0)
 
-	// Upload to S3
-	uploadInput := &s3.PutObjectInput{
-		Bucket:      aws.String(s.bucket),
-		Key:         aws.String(s3Key),
-		Body:        req.File,
-		ContentType: aws.String(req.ContentType),
-		Metadata: map[string]string{
-			"original-filename": req.Filename,
-			"uploaded-by":       req.UserID,
-			"upload-time":       time.Now().Format(time.RFC3339),
-		},
-	}
-
-	_, err = s.uploader.Upload(ctx, uploadInput)
+	// Upload to storage
+	err = s.store.Put(ctx, s3Key, req.File, req.ContentType, uploadMetadata(req.Filename, req.UserID))
 	if err != nil {
 		return nil, &ServiceError{
 			Code:    "S3_UPLOAD_ERROR",
-			Message: "Failed to upload file to S3",
+			Message: "Failed to upload file to storage",
 			Cause:   err,
 		}
 	}
 
-	publicURL := s.getPublicURL(s3Key)
+	publicURL := s.store.PublicURL(s3Key)
 	mediaType := "DEFAULT" // Default type
 	if req.TypeID != nil {
 		mediaType = *req.TypeID
@@ -374,18 +308,19 @@ func (s *MediaService) DownloadFile(ctx context.Context, req *models.DownloadReq
 	if ok {
 		return response, nil
 	}
-	// Create buffer for download
-	buffer := manager.NewWriteAtBuffer([]byte{})
-
-	// Download from S3
-	_, err := s.downloader.Download(ctx, buffer, &s3.GetObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(media.CvUrl),
-	})
+	// Download from storage
+	object, err := s.store.Get(ctx, media.CvUrl)
 	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, &ServiceError{
+				Code:    "MEDIA_NOT_FOUND",
+				Message: "Media file not found in storage",
+				Cause:   err,
+			}
+		}
 		return nil, &ServiceError{
 			Code:    "S3_DOWNLOAD_ERROR",
-			Message: "Failed to download file from S3",
+			Message: "Failed to download file from storage",
 			Cause:   err,
 		}
 	}
@@ -396,7 +331,7 @@ func (s *MediaService) DownloadFile(ctx context.Context, req *models.DownloadReq
 		contentType = media.MediaType.CvMimeType
 	}
 	response = &models.DownloadResponse{
-		Content:     buffer.Bytes(),
+		Content:     object.Content,
 		ContentType: contentType,
 		Filename:    media.CvName,
 	}
@@ -435,12 +370,12 @@ func (s *MediaService) DeleteFile(ctx context.Context, mediaID uuid.UUID, userID
 		}
 	}
 
-	// Delete from S3
-	err = s.deleteFromS3(ctx, media.CvUrl)
+	// Delete from storage
+	err = s.store.Delete(ctx, media.CvUrl)
 	if err != nil {
 		return &ServiceError{
 			Code:    "S3_DELETE_ERROR",
-			Message: "Failed to delete file from S3",
+			Message: "Failed to delete file from storage",
 			Cause:   err,
 		}
 	}
@@ -500,28 +435,13 @@ func (s *MediaService) GetMediaStatistics() (map[string]interface{}, error) {
 	return s.repo.GetMediaStatistics()
 }
 
-// GetPresignedURL generates a presigned URL for direct upload to S3
+// GetPresignedURL generates a presigned URL for direct upload to storage
 func (s *MediaService) GetPresignedURL(ctx context.Context, filename string, contentType string, userID string) (string, error) {
 	mediaID := generateMediaID()
 	ext := filepath.Ext(filename)
 	s3Key := fmt.Sprintf("media/%s%s", mediaID, ext)
 
-	presignClient := s3.NewPresignClient(s.s3Client)
-
-	putObjectInput := &s3.PutObjectInput{
-		Bucket:      aws.String(s.bucket),
-		Key:         aws.String(s3Key),
-		ContentType: aws.String(contentType),
-		Metadata: map[string]string{
-			"original-filename": filename,
-			"uploaded-by":       userID,
-			"upload-time":       time.Now().Format(time.RFC3339),
-		},
-	}
-
-	presignedRequest, err := presignClient.PresignPutObject(ctx, putObjectInput, func(opts *s3.PresignOptions) {
-		opts.Expires = time.Duration(15 * time.Minute)
-	})
+	url, err := s.store.PresignPut(ctx, s3Key, contentType, uploadMetadata(filename, userID), s.presignExpiration)
 	if err != nil {
 		return "", &ServiceError{
 			Code:    "PRESIGN_ERROR",
@@ -530,7 +450,50 @@ func (s *MediaService) GetPresignedURL(ctx context.Context, filename string, con
 		}
 	}
 
-	return presignedRequest.URL, nil
+	return url, nil
+}
+
+// IsLocalStorage reports whether blobs are served by the API itself
+func (s *MediaService) IsLocalStorage() bool {
+	_, ok := s.store.(storage.LocalBlobStore)
+	return ok
+}
+
+// GetSignedBlob returns a blob addressed by a signed local URL
+func (s *MediaService) GetSignedBlob(ctx context.Context, key string, expires int64, signature string) (*storage.BlobObject, error) {
+	if err := s.verifySignedBlob(http.MethodGet, key, "", expires, signature); err != nil {
+		return nil, err
+	}
+	object, err := s.store.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, &ServiceError{Code: "MEDIA_NOT_FOUND", Message: "Media file not found in storage", Cause: err}
+		}
+		return nil, &ServiceError{Code: "S3_DOWNLOAD_ERROR", Message: "Failed to download file from storage", Cause: err}
+	}
+	return object, nil
+}
+
+// PutSignedBlob stores a blob uploaded to a presigned local URL
+func (s *MediaService) PutSignedBlob(ctx context.Context, key string, contentType string, expires int64, signature string, body io.Reader) error {
+	if err := s.verifySignedBlob(http.MethodPut, key, contentType, expires, signature); err != nil {
+		return err
+	}
+	if err := s.store.Put(ctx, key, body, contentType, nil); err != nil {
+		return &ServiceError{Code: "S3_UPLOAD_ERROR", Message: "Failed to upload file to storage", Cause: err}
+	}
+	return nil
+}
+
+func (s *MediaService) verifySignedBlob(method string, key string, contentType string, expires int64, signature string) error {
+	local, ok := s.store.(storage.LocalBlobStore)
+	if !ok {
+		return &ServiceError{Code: "STORAGE_NOT_SUPPORTED", Message: "Signed local URLs are not supported by this storage backend"}
+	}
+	if err := local.VerifyURL(method, key, contentType, expires, signature); err != nil {
+		return &ServiceError{Code: "INVALID_SIGNATURE", Message: "Invalid or expired signature", Cause: err}
+	}
+	return nil
 }
 
 // Helper functions
@@ -561,71 +524,37 @@ func getFileSize(file multipart.File) (int64, error) {
 	return size, nil
 }
 
-func (s *MediaService) deleteFromS3(ctx context.Context, s3Key string) error {
-	_, err := s.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(s3Key),
-	})
-	return err
-}
-
-func (s *MediaService) getPublicURL(s3Key string) string {
-	if s.config.Endpoint != "" {
-		// For MinIO or custom endpoints
-		protocol := "https"
-		if !s.config.UseSSL {
-			protocol = "http"
-		}
-		return fmt.Sprintf("%s://%s/%s/%s", protocol, strings.TrimPrefix(s.config.Endpoint, protocol+"://"), s.bucket, s3Key)
+func uploadMetadata(filename string, userID string) map[string]string {
+	return map[string]string{
+		"original-filename": filename,
+		"uploaded-by":       userID,
+		"upload-time":       time.Now().Format(time.RFC3339),
 	}
-
-	// For AWS S3
-	if s.config.ForcePathStyle {
-		return fmt.Sprintf("https://s3.%s.amazonaws.com/%s/%s", s.config.Region, s.bucket, s3Key)
-	}
-
-	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.config.Region, s3Key)
 }
 
-// CheckBucketExists checks if the S3 bucket exists
+// CheckBucketExists checks if the storage bucket exists
 func (s *MediaService) CheckBucketExists(ctx context.Context) error {
-	_, err := s.s3Client.HeadBucket(ctx, &s3.HeadBucketInput{
-		Bucket: aws.String(s.bucket),
-	})
-	if err != nil {
+	if err := s.store.CheckBucket(ctx); err != nil {
 		return &ServiceError{
 			Code:    "BUCKET_NOT_FOUND",
-			Message: "S3 bucket not found or not accessible",
+			Message: "Storage bucket not found or not accessible",
 			Cause:   err,
 		}
 	}
 	return nil
 }
 
-// CreateBucket creates the S3 bucket if it doesn't exist
+// CreateBucket creates the storage bucket if it doesn't exist
 func (s *MediaService) CreateBucket(ctx context.Context) error {
 	// Check if bucket already exists
 	if err := s.CheckBucketExists(ctx); err == nil {
 		return nil // Bucket already exists
 	}
 
-	// Create bucket
-	createInput := &s3.CreateBucketInput{
-		Bucket: aws.String(s.bucket),
-	}
-
-	// For regions other than us-east-1, we need to specify the location constraint
-	if s.config.Region != "us-east-1" {
-		createInput.CreateBucketConfiguration = &types.CreateBucketConfiguration{
-			LocationConstraint: types.BucketLocationConstraint(s.config.Region),
-		}
-	}
-
-	_, err := s.s3Client.CreateBucket(ctx, createInput)
-	if err != nil {
+	if err := s.store.CreateBucket(ctx); err != nil {
 		return &ServiceError{
 			Code:    "BUCKET_CREATE_ERROR",
-			Message: "Failed to create S3 bucket",
+			Message: "Failed to create storage bucket",
 			Cause:   err,
 		}
 	}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"parier-server/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// commentMentionLimit - больше пользователей в одном комментарии не упоминается, остальные @имена остаются текстом
+const commentMentionLimit = 10
+
+// mentionPattern - @имя в начале текста или после символа, который не может быть частью имени или адреса почты
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.])@([\p{L}\p{N}_][\p{L}\p{N}_.\-]*)`)
+
+// mentionToken - @имя в тексте; Offset и Length в символах, Length включает @
+type mentionToken struct {
+	Username string
+	Offset   int
+	Length   int
+}
+
+// parseMentions находит упоминания в тексте. Точка и дефис в конце имени считаются пунктуацией
+func parseMentions(content string) []mentionToken {
+	var tokens []mentionToken
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(content, -1) {
+		start, end := match[2], match[3]
+		name := strings.TrimRight(content[start:end], ".-")
+		tokens = append(tokens, mentionToken{
+			Username: name,
+			Offset:   utf8.RuneCountInString(content[:start-1]),
+			Length:   1 + utf8.RuneCountInString(name),
+		})
+	}
+	return tokens
+}
+
+// syncMentions сохраняет упоминания комментария по его текущему тексту. Имя разрешается
+// в пользователя без учёта регистра; имя, которое носят несколько пользователей, пропускается.
+// Упоминание самого себя и уже прочитанные упоминания не попадают в непрочитанные.
+// Возвращает пользователей, упомянутых в комментарии впервые, кроме автора
+func (s *ParierService) syncMentions(tx *gorm.DB, comment *models.TBetComment) ([]uuid.UUID, error) {
+	tokens := parseMentions(comment.CvContent)
+	existing, err := s.repo.GetCommentMentions([]uuid.UUID{comment.CkId}, tx)
+	if err != nil {
+		return nil, databaseError("Failed to get comment mentions", err)
+	}
+	if len(tokens) == 0 && len(existing) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		names = append(names, strings.ToLower(token.Username))
+	}
+	users, err := s.repo.GetUsersByUsernames(names, tx)
+	if err != nil {
+		return nil, databaseError("Failed to resolve mentioned users", err)
+	}
+	read := make(map[uuid.UUID]*time.Time, len(existing))
+	known := make(map[uuid.UUID]bool, len(existing))
+	for _, mention := range existing {
+		known[mention.CkUser] = true
+		if mention.CtRead != nil {
+			read[mention.CkUser] = mention.CtRead
+		}
+	}
+	now := time.Now()
+	var added []uuid.UUID
+	mentioned := make(map[uuid.UUID]bool)
+	mentions := make([]models.TBetCommentMention, 0, len(tokens))
+	for _, token := range tokens {
+		ids := users[strings.ToLower(token.Username)]
+		if len(ids) != 1 {
+			continue
+		}
+		userID := ids[0]
+		if !mentioned[userID] {
+			if len(mentioned) == commentMentionLimit {
+				continue
+			}
+			mentioned[userID] = true
+			if !known[userID] && userID != comment.CkAuthor {
+				added = append(added, userID)
+			}
+		}
+		mention := models.TBetCommentMention{
+			CkComment:  comment.CkId,
+			CkUser:     userID,
+			CvUsername: token.Username,
+			CnOffset:   token.Offset,
+			CnLength:   token.Length,
+			CtRead:     read[userID],
+			BaseModel: models.BaseModel{
+				CkCreate: comment.CkModify,
+				CkModify: comment.CkModify,
+			},
+		}
+		if userID == comment.CkAuthor {
+			mention.CtRead = &now
+		}
+		mentions = append(mentions, mention)
+	}
+	if err := s.repo.ReplaceCommentMentions(comment.CkId, mentions, tx); err != nil {
+		return nil, databaseError("Failed to save comment mentions", err)
+	}
+	return added, nil
+}
+
+// commentNotices - уведомления об ответе на комментарий и об упоминаниях в нём от автора author
+func commentNotices(comment *models.TBetComment, author string, parentAuthor *uuid.UUID, mentioned []uuid.UUID) []Notice {
+	payload := map[string]any{
+		"bet_id":     comment.CkBet,
+		"comment_id": comment.CkId,
+		"user_id":    comment.CkAuthor,
+		"user":       author,
+		"text":       excerpt(comment.CvContent),
+	}
+	var notices []Notice
+	if parentAuthor != nil && *parentAuthor != comment.CkAuthor {
+		notices = append(notices, Notice{User: *parentAuthor, Type: models.NotificationTypeCommentReply, Payload: payload})
+	}
+	for _, userID := range mentioned {
+		// ответ уже уведомляет автора родительского комментария
+		if parentAuthor != nil && userID == *parentAuthor {
+			continue
+		}
+		notices = append(notices, Notice{User: userID, Type: models.NotificationTypeCommentMention, Payload: payload})
+	}
+	return notices
+}
+
+// GetUnreadMentions возвращает непрочитанные упоминания пользователя, новые первыми
+func (s *ParierService) GetUnreadMentions(userID uuid.UUID, offset, limit *int) ([]models.MentionResponse, int64, error) {
+	o, l := 0, 20
+	if offset != nil {
+		o = *offset
+	}
+	if limit != nil {
+		l = min(*limit, commentPageMax)
+	}
+	mentions, total, err := s.repo.GetUnreadMentions(userID, o, l)
+	if err != nil {
+		return nil, 0, databaseError("Failed to get mentions", err)
+	}
+	now := time.Now()
+	result := make([]models.MentionResponse, 0, len(mentions))
+	for _, mention := range mentions {
+		comment := mention.Comment
+		res := models.MentionResponse{
+			ID:        mention.CkId,
+			BetID:     comment.CkBet,
+			CreatedAt: mention.CtCreate,
+			Comment: models.BetCommentResponse{
+				ID:        comment.CkId,
+				Content:   comment.CvContent,
+				CreatedAt: comment.CtCreate,
+				UpdatedAt: comment.CtModify,
+				Author:    s.authorResponse(comment.CkAuthor, comment.Author),
+			},
+		}
+		s.commentState(&res.Comment, comment, userID, now)
+		result = append(result, res)
+	}
+	return result, total, nil
+}
+
+// MarkMentionsRead отмечает упоминания пользователя прочитанными; без ids - все непрочитанные
+func (s *ParierService) MarkMentionsRead(userID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	count, err := s.repo.MarkMentionsRead(userID, ids)
+	if err != nil {
+		return 0, databaseError("Failed to mark mentions read", err)
+	}
+	return count, nil
+}
+
+// mentionResponses - подсказки для выделения упоминаний в тексте
+func mentionResponses(mentions []models.TBetCommentMention) []models.CommentMentionResponse {
+	if len(mentions) == 0 {
+		return nil
+	}
+	result := make([]models.CommentMentionResponse, 0, len(mentions))
+	for _, mention := range mentions {
+		result = append(result, models.CommentMentionResponse{
+			UserID:   mention.CkUser,
+			Username: mention.CvUsername,
+			Offset:   mention.CnOffset,
+			Length:   mention.CnLength,
+		})
+	}
+	return result
+}
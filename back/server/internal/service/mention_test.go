@@ -0,0 +1,28 @@
+package service
+
+import "testing"
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []mentionToken
+	}{
+		{"@alice привет", []mentionToken{{"alice", 0, 6}}},
+		{"Привет, @Боб.", []mentionToken{{"Боб", 8, 4}}},
+		{"(@bob-smith) и @carol_1", []mentionToken{{"bob-smith", 1, 10}, {"carol_1", 15, 8}}},
+		{"почта mail@example.com и @@twice", nil},
+		{"@", nil},
+	}
+	for _, tt := range tests {
+		got := parseMentions(tt.content)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.content, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: expected %v, got %v", tt.content, tt.want[i], got[i])
+			}
+		}
+	}
+}
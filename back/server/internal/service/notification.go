@@ -0,0 +1,388 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"parier-server/internal/config"
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// notificationDeliverBatch - сколько уведомлений забирает за раз задача доставки
+	notificationDeliverBatch = 100
+	// notificationExcerpt - длина отрывка комментария в данных уведомления, в символах
+	notificationExcerpt = 100
+	// notificationPageMax - больше уведомлений за один запрос не отдаётся
+	notificationPageMax = 100
+)
+
+// NotificationTypes - все типы уведомлений, которые создаёт сервер
+var NotificationTypes = []models.NotificationType{
+	models.NotificationTypeCommentReply,
+	models.NotificationTypeCommentMention,
+	models.NotificationTypeCommentLike,
+	models.NotificationTypeBetLike,
+	models.NotificationTypeWalletDeposit,
+	models.NotificationTypeWalletWithdrawal,
+	models.NotificationTypeAdminCredit,
+}
+
+// Notice - событие, о котором нужно уведомить пользователя User. Payload подставляется
+// в шаблоны заголовка и текста и отдаётся клиенту для ссылок
+type Notice struct {
+	User    uuid.UUID
+	Type    models.NotificationType
+	Payload map[string]any
+}
+
+// NotificationService создаёт уведомления в транзакциях событий и отправляет их
+// по внешним каналам фоновой задачей
+type NotificationService struct {
+	repo     *repository.NotificationRepository
+	resolver *repository.LocalizationResolver
+	events   *EventHub
+	channels []NotificationChannel
+	cfg      config.NotifyConfig
+	quit     chan struct{}
+}
+
+func NewNotificationService(repo *repository.NotificationRepository, resolver *repository.LocalizationResolver, events *EventHub, cfg config.NotifyConfig) *NotificationService {
+	return &NotificationService{
+		repo:     repo,
+		resolver: resolver,
+		events:   events,
+		channels: []NotificationChannel{inAppChannel{}, newEmailChannel(cfg), newWebhookChannel(cfg)},
+		cfg:      cfg,
+	}
+}
+
+// Notify сохраняет уведомления в транзакции tx. Уведомления без получателя и
+// отключённых получателем типов пропускаются
+func (s *NotificationService) Notify(tx *gorm.DB, actor string, notices ...Notice) error {
+	if s == nil || len(notices) == 0 {
+		return nil
+	}
+	users := make([]uuid.UUID, 0, len(notices))
+	for _, notice := range notices {
+		if notice.User != uuid.Nil && !slices.Contains(users, notice.User) {
+			users = append(users, notice.User)
+		}
+	}
+	props, err := s.repo.GetNotifyProperties(users, tx)
+	if err != nil {
+		return databaseError("Failed to get notification preferences", err)
+	}
+	if actor == "" {
+		actor = auditActorSystem
+	}
+	notifications := make([]models.TNotification, 0, len(notices))
+	for _, notice := range notices {
+		if notice.User == uuid.Nil || slices.Contains(notificationPreferences(props[notice.User]).Muted, notice.Type) {
+			continue
+		}
+		payload, err := json.Marshal(notice.Payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode notification payload: %w", err)
+		}
+		notifications = append(notifications, models.TNotification{
+			CkUser:    notice.User,
+			CkType:    notice.Type,
+			CjPayload: string(payload),
+			BaseModel: models.BaseModel{CkCreate: actor, CkModify: actor},
+		})
+	}
+	if err := s.repo.CreateNotifications(notifications, tx); err != nil {
+		return databaseError("Failed to create notifications", err)
+	}
+	// текст уведомления клиент запрашивает на своём языке через API
+	for _, notification := range notifications {
+		err := s.events.Publish(tx, userTopic(TopicNotifications, notification.CkUser), EventNotificationCreated, map[string]any{
+			"id":   notification.CkId,
+			"type": notification.CkType,
+		})
+		if err != nil {
+			return databaseError("Failed to publish notification event", err)
+		}
+	}
+	return nil
+}
+
+// List возвращает уведомления пользователя на языке lang, новые первыми
+func (s *NotificationService) List(userID uuid.UUID, unread bool, lang *string, offset, limit *int) ([]models.NotificationResponse, int64, error) {
+	o, l := 0, 20
+	if offset != nil {
+		o = *offset
+	}
+	if limit != nil {
+		l = min(*limit, notificationPageMax)
+	}
+	notifications, total, err := s.repo.GetNotifications(userID, unread, o, l)
+	if err != nil {
+		return nil, 0, databaseError("Failed to get notifications", err)
+	}
+	result := make([]models.NotificationResponse, 0, len(notifications))
+	for i := range notifications {
+		result = append(result, s.render(&notifications[i], lang))
+	}
+	return result, total, nil
+}
+
+// UnreadCount возвращает число непрочитанных уведомлений пользователя
+func (s *NotificationService) UnreadCount(userID uuid.UUID) (int64, error) {
+	count, err := s.repo.CountUnread(userID)
+	if err != nil {
+		return 0, databaseError("Failed to count notifications", err)
+	}
+	return count, nil
+}
+
+// MarkRead отмечает уведомления пользователя прочитанными; без ids - все непрочитанные
+func (s *NotificationService) MarkRead(userID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	count, err := s.repo.MarkRead(userID, ids)
+	if err != nil {
+		return 0, databaseError("Failed to mark notifications read", err)
+	}
+	return count, nil
+}
+
+// GetPreferences возвращает настройки уведомлений пользователя
+func (s *NotificationService) GetPreferences(userID uuid.UUID) (*models.NotificationPreferences, error) {
+	props, err := s.repo.GetNotifyProperties([]uuid.UUID{userID}, nil)
+	if err != nil {
+		return nil, databaseError("Failed to get notification preferences", err)
+	}
+	prefs := notificationPreferences(props[userID])
+	return &prefs, nil
+}
+
+// SetPreferences меняет переданные настройки уведомлений пользователя
+func (s *NotificationService) SetPreferences(userID uuid.UUID, request models.NotificationPreferencesRequest) (*models.NotificationPreferences, error) {
+	if request.Webhook != nil && *request.Webhook != "" {
+		if err := validateWebhookURL(*request.Webhook); err != nil {
+			return nil, err
+		}
+	}
+	if request.Muted != nil {
+		for _, kind := range *request.Muted {
+			if !slices.Contains(NotificationTypes, kind) {
+				return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Unknown notification type " + string(kind)}
+			}
+		}
+	}
+	err := s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if request.Email != nil {
+			if err := s.repo.SetNotifyProperty(tx, userID, repository.UserNotifyEmailProperty, nil, request.Email); err != nil {
+				return err
+			}
+		}
+		if request.Webhook != nil {
+			var webhook *string
+			if *request.Webhook != "" {
+				webhook = request.Webhook
+			}
+			if err := s.repo.SetNotifyProperty(tx, userID, repository.UserNotifyWebhookProperty, webhook, nil); err != nil {
+				return err
+			}
+		}
+		if request.Muted != nil {
+			var muted *string
+			if len(*request.Muted) > 0 {
+				data, err := json.Marshal(*request.Muted)
+				if err != nil {
+					return err
+				}
+				text := string(data)
+				muted = &text
+			}
+			if err := s.repo.SetNotifyProperty(tx, userID, repository.UserNotifyMutedProperty, muted, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, databaseError("Failed to save notification preferences", err)
+	}
+	return s.GetPreferences(userID)
+}
+
+// Start запускает периодическую отправку новых уведомлений по внешним каналам
+func (s *NotificationService) Start() {
+	if s.cfg.DeliverInterval <= 0 || s.quit != nil {
+		return
+	}
+	s.quit = make(chan struct{})
+	ticker := time.NewTicker(s.cfg.DeliverInterval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if sent, err := s.DeliverPending(); err != nil {
+					log.Printf("Notification delivery failed after %d notifications: %v", sent, err)
+				}
+			case <-s.quit:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (s *NotificationService) Stop() {
+	if s.quit != nil {
+		close(s.quit)
+		s.quit = nil
+	}
+}
+
+// DeliverPending отправляет новые уведомления по каналам, включённым получателем.
+// Уведомление отмечается отправленным до отправки, поэтому при сбое сервера оно
+// может не дойти, но не будет отправлено дважды; ошибки каналов сохраняются в уведомлении
+func (s *NotificationService) DeliverPending() (int, error) {
+	sent := 0
+	for {
+		notifications, err := s.repo.ClaimUndelivered(notificationDeliverBatch)
+		if err != nil {
+			return sent, databaseError("Failed to get undelivered notifications", err)
+		}
+		if len(notifications) == 0 {
+			return sent, nil
+		}
+		users := make([]uuid.UUID, 0, len(notifications))
+		for _, notification := range notifications {
+			users = append(users, notification.CkUser)
+		}
+		props, err := s.repo.GetNotifyProperties(users, nil)
+		if err != nil {
+			return sent, databaseError("Failed to get notification preferences", err)
+		}
+		for i := range notifications {
+			s.deliver(&notifications[i], notificationRecipient(notifications[i].CkUser, props[notifications[i].CkUser]))
+			sent++
+		}
+	}
+}
+
+func (s *NotificationService) deliver(notification *models.TNotification, to NotificationRecipient) {
+	message := s.render(notification, to.Lang)
+	var errs []string
+	for _, channel := range s.channels {
+		if !channel.Accepts(to) {
+			continue
+		}
+		if err := channel.Send(context.Background(), to, message); err != nil {
+			errs = append(errs, channel.Name()+": "+err.Error())
+		}
+	}
+	if len(errs) == 0 {
+		return
+	}
+	if err := s.repo.SetDeliverError(notification.CkId, strings.Join(errs, "; ")); err != nil {
+		log.Printf("Failed to save delivery error of notification %s: %v", notification.CkId, err)
+	}
+}
+
+// render подставляет данные уведомления в шаблоны его типа на языке lang
+func (s *NotificationService) render(notification *models.TNotification, lang *string) models.NotificationResponse {
+	payload := map[string]any{}
+	if err := json.Unmarshal([]byte(notification.CjPayload), &payload); err != nil {
+		log.Printf("Invalid payload of notification %s: %v", notification.CkId, err)
+	}
+	res := models.NotificationResponse{
+		ID:        notification.CkId,
+		Type:      notification.CkType,
+		Title:     string(notification.CkType),
+		Payload:   payload,
+		ReadAt:    notification.CtRead,
+		CreatedAt: notification.CtCreate,
+	}
+	if notification.Type == nil {
+		return res
+	}
+	texts := s.resolver.Resolve(lang, &notification.Type.CkName, &notification.Type.CkDescription)
+	if title := texts.Text(notification.Type.CkName); title != "" {
+		res.Title = fillTemplate(title, payload)
+	}
+	res.Body = fillTemplate(texts.Text(notification.Type.CkDescription), payload)
+	return res
+}
+
+// fillTemplate заменяет {ключ} значением из payload; неизвестные ключи остаются как есть
+func fillTemplate(template string, payload map[string]any) string {
+	pairs := make([]string, 0, len(payload)*2)
+	for key, value := range payload {
+		var text string
+		switch v := value.(type) {
+		case string:
+			text = v
+		case float64:
+			text = strconv.FormatFloat(v, 'f', -1, 64)
+		case nil:
+		default:
+			text = fmt.Sprint(v)
+		}
+		pairs = append(pairs, "{"+key+"}", text)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// excerpt обрезает текст комментария для данных уведомления
+func excerpt(text string) string {
+	if utf8.RuneCountInString(text) <= notificationExcerpt {
+		return text
+	}
+	return string([]rune(text)[:notificationExcerpt-1]) + "…"
+}
+
+// notificationPreferences собирает настройки уведомлений из свойств пользователя
+func notificationPreferences(props []models.TUserProperties) models.NotificationPreferences {
+	prefs := models.NotificationPreferences{Muted: []models.NotificationType{}}
+	for _, prop := range props {
+		switch prop.CkType {
+		case repository.UserNotifyEmailProperty:
+			prefs.Email = prop.ClBool != nil && *prop.ClBool
+		case repository.UserNotifyWebhookProperty:
+			if prop.CvText != nil && *prop.CvText != "" {
+				prefs.Webhook = prop.CvText
+			}
+		case repository.UserNotifyMutedProperty:
+			if prop.CvText != nil {
+				if err := json.Unmarshal([]byte(*prop.CvText), &prefs.Muted); err != nil {
+					log.Printf("Invalid muted notifications of user %s: %v", prop.CkUser, err)
+				}
+			}
+		}
+	}
+	return prefs
+}
+
+// validateWebhookURL отклоняет адреса, которые явно ведут во внутреннюю сеть.
+// Имена, которые разрешаются во внутренние адреса, отсекает webhookChannel при соединении
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return &ServiceError{Code: "VALIDATION_ERROR", Message: "Webhook must be an http or https URL"}
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return &ServiceError{Code: "VALIDATION_ERROR", Message: "Webhook must point to a public address"}
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicAddress(ip) {
+		return &ServiceError{Code: "VALIDATION_ERROR", Message: "Webhook must point to a public address"}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"parier-server/internal/config"
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// NotificationRecipient - получатель уведомления с его языком и настройками каналов
+type NotificationRecipient struct {
+	User        uuid.UUID
+	Lang        *string
+	Email       string
+	Preferences models.NotificationPreferences
+}
+
+func notificationRecipient(userID uuid.UUID, props []models.TUserProperties) NotificationRecipient {
+	to := NotificationRecipient{User: userID, Preferences: notificationPreferences(props)}
+	for _, prop := range props {
+		switch prop.CkType {
+		case repository.UserLangProperty:
+			to.Lang = prop.CvText
+		case repository.UserEmailProperty:
+			if prop.CvText != nil {
+				to.Email = *prop.CvText
+			}
+		}
+	}
+	return to
+}
+
+// NotificationChannel - способ доставки уведомления пользователю
+type NotificationChannel interface {
+	Name() string
+	// Accepts сообщает, включён ли канал для получателя
+	Accepts(to NotificationRecipient) bool
+	Send(ctx context.Context, to NotificationRecipient, message models.NotificationResponse) error
+}
+
+// inAppChannel - уведомления в приложении. Уведомление доступно получателю
+// через API сразу после создания, отправлять его не нужно
+type inAppChannel struct{}
+
+func (inAppChannel) Name() string { return "IN_APP" }
+
+func (inAppChannel) Accepts(NotificationRecipient) bool { return true }
+
+func (inAppChannel) Send(context.Context, NotificationRecipient, models.NotificationResponse) error {
+	return nil
+}
+
+// emailChannel отправляет уведомления на почту пользователя через SMTP
+type emailChannel struct {
+	cfg config.NotifyConfig
+}
+
+func newEmailChannel(cfg config.NotifyConfig) *emailChannel {
+	return &emailChannel{cfg: cfg}
+}
+
+func (c *emailChannel) Name() string { return "EMAIL" }
+
+func (c *emailChannel) Accepts(to NotificationRecipient) bool {
+	return c.cfg.SMTPHost != "" && to.Preferences.Email && to.Email != ""
+}
+
+func (c *emailChannel) Send(_ context.Context, to NotificationRecipient, message models.NotificationResponse) error {
+	var auth smtp.Auth
+	if c.cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", c.cfg.SMTPUsername, c.cfg.SMTPPassword, c.cfg.SMTPHost)
+	}
+	addr := net.JoinHostPort(c.cfg.SMTPHost, strconv.Itoa(c.cfg.SMTPPort))
+	return smtp.SendMail(addr, auth, c.cfg.SMTPFrom, []string{to.Email}, emailMessage(c.cfg.SMTPFrom, to.Email, message))
+}
+
+// emailMessage собирает письмо в UTF-8; заголовки без переводов строк
+func emailMessage(from, to string, message models.NotificationResponse) []byte {
+	header := strings.NewReplacer("\r", " ", "\n", " ")
+	var b strings.Builder
+	b.WriteString("From: " + header.Replace(from) + "\r\n")
+	b.WriteString("To: " + header.Replace(to) + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", header.Replace(message.Title)) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(message.Body + "\r\n")
+	return []byte(b.String())
+}
+
+// errWebhookAddress - адрес вебхука ведёт во внутреннюю сеть сервера
+var errWebhookAddress = errors.New("webhook address is not public")
+
+// webhookChannel отправляет уведомление POST запросом с JSON на адрес пользователя.
+// Если задан NOTIFY_WEBHOOK_SECRET, тело подписывается в заголовке X-Parier-Signature.
+// Адрес задаёт пользователь, поэтому соединения во внутреннюю сеть и редиректы запрещены
+type webhookChannel struct {
+	client *http.Client
+	secret string
+}
+
+func newWebhookChannel(cfg config.NotifyConfig) *webhookChannel {
+	// Проверяется адрес, к которому идёт соединение, а не имя: иначе DNS мог бы
+	// вернуть внутренний адрес уже после проверки. Прокси из окружения не используется,
+	// потому что тогда соединение шло бы к прокси
+	dialer := &net.Dialer{Timeout: cfg.WebhookTimeout, Control: webhookDialControl}
+	client := &http.Client{
+		Timeout:   cfg.WebhookTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: cfg.WebhookTimeout},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &webhookChannel{client: client, secret: cfg.WebhookSecret}
+}
+
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicAddress(ip) {
+		return errWebhookAddress
+	}
+	return nil
+}
+
+// webhookInternalNetworks - внутренние сети, которые не покрывают методы net.IP:
+// "эта сеть", CGNAT провайдера и сеть для тестов производительности
+var webhookInternalNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("198.18.0.0/15"),
+}
+
+// webhookNAT64 - префикс NAT64 (RFC 6052), в последних 4 байтах адреса лежит IPv4
+var webhookNAT64 = mustParseCIDR("64:ff9b::/96")
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// publicAddress сообщает, что ip не loopback, не частный, не link-local,
+// не multicast, не неуказанный адрес и не лежит во внутренних сетях.
+// Адрес NAT64 проверяется по вложенному IPv4
+func publicAddress(ip net.IP) bool {
+	if ip.To4() == nil && webhookNAT64.Contains(ip) {
+		return publicAddress(ip[net.IPv6len-net.IPv4len:])
+	}
+	for _, network := range webhookInternalNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+func (c *webhookChannel) Name() string { return "WEBHOOK" }
+
+func (c *webhookChannel) Accepts(to NotificationRecipient) bool {
+	return to.Preferences.Webhook != nil
+}
+
+func (c *webhookChannel) Send(ctx context.Context, to NotificationRecipient, message models.NotificationResponse) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *to.Preferences.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Parier-Event", string(message.Type))
+	if c.secret != "" {
+		req.Header.Set("X-Parier-Signature", "sha256="+webhookSignature(c.secret, body))
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
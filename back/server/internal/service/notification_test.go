@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"parier-server/internal/config"
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+func TestFillTemplate(t *testing.T) {
+	payload := map[string]any{"user": "alice", "amount": 12.5, "balance": float64(100), "missing": nil}
+	got := fillTemplate("{user}: {amount} -> {balance}{missing} {unknown}", payload)
+	if got != "alice: 12.5 -> 100 {unknown}" {
+		t.Errorf("unexpected text %q", got)
+	}
+	if text := excerpt(strings.Repeat("я", notificationExcerpt+1)); len([]rune(text)) != notificationExcerpt || !strings.HasSuffix(text, "…") {
+		t.Errorf("long text must be cut to %d characters, got %q", notificationExcerpt, text)
+	}
+}
+
+func TestCommentNotices(t *testing.T) {
+	author, parent, mentioned := uuid.New(), uuid.New(), uuid.New()
+	comment := &models.TBetComment{CkId: uuid.New(), CkAuthor: author, CvContent: "@bob @carol"}
+
+	notices := commentNotices(comment, "alice", &parent, []uuid.UUID{parent, mentioned})
+	if len(notices) != 2 || notices[0].User != parent || notices[0].Type != models.NotificationTypeCommentReply ||
+		notices[1].User != mentioned || notices[1].Type != models.NotificationTypeCommentMention {
+		t.Fatalf("parent author must get one reply notice and others mention notices: %+v", notices)
+	}
+	if notices := commentNotices(comment, "alice", &author, nil); len(notices) != 0 {
+		t.Errorf("reply to own comment must not notify: %+v", notices)
+	}
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	on, webhook, muted, email := true, "https://example.com/hook", `["BET_LIKE"]`, "user@example.com"
+	props := []models.TUserProperties{
+		{CkType: repository.UserNotifyEmailProperty, ClBool: &on},
+		{CkType: repository.UserNotifyWebhookProperty, CvText: &webhook},
+		{CkType: repository.UserNotifyMutedProperty, CvText: &muted},
+		{CkType: repository.UserEmailProperty, CvText: &email},
+	}
+	to := notificationRecipient(uuid.New(), props)
+	if !to.Preferences.Email || to.Email != email || to.Preferences.Webhook == nil || *to.Preferences.Webhook != webhook {
+		t.Errorf("channels are not read from properties: %+v", to)
+	}
+	if len(to.Preferences.Muted) != 1 || to.Preferences.Muted[0] != models.NotificationTypeBetLike {
+		t.Errorf("muted types are not read: %v", to.Preferences.Muted)
+	}
+	channel := newEmailChannel(config.NotifyConfig{SMTPHost: "smtp.example.com"})
+	if !channel.Accepts(to) || channel.Accepts(NotificationRecipient{Email: email}) {
+		t.Errorf("email channel must follow the preference")
+	}
+	if err := validateWebhookURL("ftp://example.com"); err == nil {
+		t.Errorf("only http and https webhooks are allowed")
+	}
+}
+
+func TestWebhookInternalAddresses(t *testing.T) {
+	for _, raw := range []string{
+		"http://localhost:8080/hook", "http://api.localhost/hook", "http://127.0.0.1/hook", "http://10.0.0.5/hook",
+		"http://192.168.1.1/hook", "http://169.254.169.254/latest/meta-data", "http://[::1]/hook", "http://0.0.0.0/hook",
+		"http://0.1.2.3/hook", "http://100.64.0.1/hook", "http://100.127.255.254/hook", "http://198.18.0.1/hook",
+		"http://198.19.255.254/hook", "http://[64:ff9b::a9fe:a9fe]/hook", "http://[64:ff9b::10.0.0.5]/hook",
+		"http://[64:ff9b::127.0.0.1]/hook", "http://[::ffff:10.0.0.5]/hook",
+	} {
+		if err := validateWebhookURL(raw); err == nil {
+			t.Errorf("%s must be rejected", raw)
+		}
+	}
+	for _, raw := range []string{"https://hooks.example.com/parier", "http://100.128.0.1/hook", "http://198.20.0.1/hook", "http://[64:ff9b::8.8.8.8]/hook"} {
+		if err := validateWebhookURL(raw); err != nil {
+			t.Errorf("public webhook %s must be accepted: %v", raw, err)
+		}
+	}
+
+	// имя проходит проверку, но соединение к внутреннему адресу обрывается
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+	channel := newWebhookChannel(config.NotifyConfig{WebhookTimeout: time.Second})
+	webhook := server.URL
+	to := NotificationRecipient{Preferences: models.NotificationPreferences{Webhook: &webhook}}
+	if err := channel.Send(context.Background(), to, models.NotificationResponse{}); !errors.Is(err, errWebhookAddress) {
+		t.Errorf("webhook to a loopback address must fail, got %v", err)
+	}
+	if called {
+		t.Errorf("loopback webhook must not be delivered")
+	}
+}
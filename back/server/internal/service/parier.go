@@ -2,18 +2,28 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
+	"parier-server/internal/config"
 	"parier-server/internal/models"
 	"parier-server/internal/repository"
 	"parier-server/internal/util"
 	"strconv"
+	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type ParierService struct {
 	repo             *repository.ParierRepository
 	repoLocalization *repository.LocalizationRepository
+	resolver         *repository.LocalizationResolver
 	repoUser         *repository.UserRepository
+	referrals        *ReferralService
+	audit            *AuditService
+	notifications    *NotificationService
+	events           *EventHub
+	comments         config.CommentsConfig
 }
 
 type TBetExtended struct {
@@ -26,8 +36,8 @@ type TBetExtended struct {
 	BetsCount   int  `json:"bets_count" gorm:"->"`
 }
 
-func NewParierService(repo *repository.ParierRepository, repoLocalization *repository.LocalizationRepository, repoUser *repository.UserRepository) *ParierService {
-	return &ParierService{repo: repo, repoLocalization: repoLocalization, repoUser: repoUser}
+func NewParierService(repo *repository.ParierRepository, repoLocalization *repository.LocalizationRepository, resolver *repository.LocalizationResolver, repoUser *repository.UserRepository, referrals *ReferralService, audit *AuditService, notifications *NotificationService, events *EventHub, comments config.CommentsConfig) *ParierService {
+	return &ParierService{repo: repo, repoLocalization: repoLocalization, resolver: resolver, repoUser: repoUser, referrals: referrals, audit: audit, notifications: notifications, events: events, comments: comments}
 }
 
 func (s *ParierService) GetCategories(request models.DictionaryRequest) ([]models.DictionaryItemString, error) {
@@ -45,12 +55,17 @@ func (s *ParierService) GetCategories(request models.DictionaryRequest) ([]model
 	if err != nil {
 		return nil, err
 	}
+	ids := make([]*string, 0, len(categories)*2)
+	for i := range categories {
+		ids = append(ids, &categories[i].CkName, categories[i].CkDescription)
+	}
+	texts := s.resolver.Resolve(request.Language, ids...)
 	var result []models.DictionaryItemString
 	for _, category := range categories {
 		result = append(result, models.DictionaryItemString{
 			Id:          category.CkId,
-			Name:        *s.repoLocalization.GetWordOrDefault(&category.CkName, request.Language),
-			Description: s.repoLocalization.GetWordOrDefault(category.CkDescription, request.Language),
+			Name:        texts.Text(category.CkName),
+			Description: texts.Get(category.CkDescription),
 		})
 	}
 	return result, err
@@ -71,12 +86,17 @@ func (s *ParierService) GetVerificationSources(request models.DictionaryRequest)
 	if err != nil {
 		return nil, err
 	}
+	ids := make([]*string, 0, len(verificationSources)*2)
+	for i := range verificationSources {
+		ids = append(ids, &verificationSources[i].CkName, verificationSources[i].CkDescription)
+	}
+	texts := s.resolver.Resolve(request.Language, ids...)
 	var result []models.DictionaryItemString
 	for _, verificationSource := range verificationSources {
 		result = append(result, models.DictionaryItemString{
 			Id:          verificationSource.CkId,
-			Name:        *s.repoLocalization.GetWordOrDefault(&verificationSource.CkName, request.Language),
-			Description: s.repoLocalization.GetWordOrDefault(verificationSource.CkDescription, request.Language),
+			Name:        texts.Text(verificationSource.CkName),
+			Description: texts.Get(verificationSource.CkDescription),
 		})
 	}
 	return result, err
@@ -97,12 +117,17 @@ func (s *ParierService) GetBetStatuses(request models.DictionaryRequest) ([]mode
 	if err != nil {
 		return nil, err
 	}
+	ids := make([]*string, 0, len(betStatuses)*2)
+	for i := range betStatuses {
+		ids = append(ids, &betStatuses[i].CkName, betStatuses[i].CkDescription)
+	}
+	texts := s.resolver.Resolve(request.Language, ids...)
 	var result []models.DictionaryItemString
 	for _, betStatus := range betStatuses {
 		result = append(result, models.DictionaryItemString{
 			Id:          betStatus.CkId,
-			Name:        *s.repoLocalization.GetWordOrDefault(&betStatus.CkName, request.Language),
-			Description: s.repoLocalization.GetWordOrDefault(betStatus.CkDescription, request.Language),
+			Name:        texts.Text(betStatus.CkName),
+			Description: texts.Get(betStatus.CkDescription),
 		})
 	}
 	return result, err
@@ -123,12 +148,17 @@ func (s *ParierService) GetBetTypes(request models.DictionaryRequest) ([]models.
 	if err != nil {
 		return nil, err
 	}
+	ids := make([]*string, 0, len(betTypes)*2)
+	for i := range betTypes {
+		ids = append(ids, &betTypes[i].CkName, betTypes[i].CkDescription)
+	}
+	texts := s.resolver.Resolve(request.Language, ids...)
 	var result []models.DictionaryItemString
 	for _, betType := range betTypes {
 		result = append(result, models.DictionaryItemString{
 			Id:          betType.CkId,
-			Name:        *s.repoLocalization.GetWordOrDefault(&betType.CkName, request.Language),
-			Description: s.repoLocalization.GetWordOrDefault(betType.CkDescription, request.Language),
+			Name:        texts.Text(betType.CkName),
+			Description: texts.Get(betType.CkDescription),
 		})
 	}
 	return result, err
@@ -149,18 +179,23 @@ func (s *ParierService) GetLikeTypes(request models.DictionaryRequest) ([]models
 	if err != nil {
 		return nil, err
 	}
+	ids := make([]*string, 0, len(likeTypes)*2)
+	for i := range likeTypes {
+		ids = append(ids, &likeTypes[i].CkName, likeTypes[i].CkDescription)
+	}
+	texts := s.resolver.Resolve(request.Language, ids...)
 	var result []models.DictionaryItemString
 	for _, likeType := range likeTypes {
 		result = append(result, models.DictionaryItemString{
 			Id:          likeType.CkId,
-			Name:        *s.repoLocalization.GetWordOrDefault(&likeType.CkName, request.Language),
-			Description: s.repoLocalization.GetWordOrDefault(likeType.CkDescription, request.Language),
+			Name:        texts.Text(likeType.CkName),
+			Description: texts.Get(likeType.CkDescription),
 		})
 	}
 	return result, err
 }
 
-func (s *ParierService) CreateBet(request models.BetCreateRequest) (*models.BetResponse, error) {
+func (s *ParierService) CreateBet(request models.BetCreateRequest, actor AuditActor) (*models.BetResponse, error) {
 	var err error
 	db := s.repo.GetDB()
 	tx := db.Begin()
@@ -243,6 +278,26 @@ func (s *ParierService) CreateBet(request models.BetCreateRequest) (*models.BetR
 	if err != nil {
 		return nil, err
 	}
+	// Пригласившему начисляется процент от ставки
+	err = s.referrals.AccrueCommission(tx, request.User.ID, models.ReferralEarningTypeStake, transaction.CkId, amount, actor)
+	if err != nil {
+		return nil, err
+	}
+	err = s.events.Publish(tx, TopicBets, EventBetCreated, map[string]any{
+		"bet_id":      bet.CkId,
+		"author_id":   bet.CkAuthor,
+		"category_id": bet.CkCategory,
+		"type_id":     bet.CkType,
+		"amount":      bet.CnAmount,
+		"coefficient": bet.CnCoefficient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = publishWalletEvent(s.events, tx, &userWallet, &transaction)
+	if err != nil {
+		return nil, err
+	}
 	bet.Category, err = s.repo.GetCategoryByID(bet.CkCategory)
 	if err != nil {
 		return nil, err
@@ -255,16 +310,17 @@ func (s *ParierService) CreateBet(request models.BetCreateRequest) (*models.BetR
 	if err != nil {
 		return nil, err
 	}
+	texts := s.resolver.Resolve(request.Language, &bet.Category.CkName, &bet.Status.CkName, &bet.Type.CkName, &bet.CkName, bet.CkDescription)
 	res := models.BetResponse{
 		ID:                  bet.CkId,
 		CategoryID:          bet.CkCategory,
-		CategoryName:        *s.repoLocalization.GetWordOrDefault(&bet.Category.CkName, request.Language),
+		CategoryName:        texts.Text(bet.Category.CkName),
 		StatusID:            bet.CkStatus,
-		StatusName:          *s.repoLocalization.GetWordOrDefault(&bet.Status.CkName, request.Language),
+		StatusName:          texts.Text(bet.Status.CkName),
 		TypeID:              bet.CkType,
-		TypeName:            *s.repoLocalization.GetWordOrDefault(&bet.Type.CkName, request.Language),
-		Title:               *s.repoLocalization.GetWordOrDefault(&bet.CkName, request.Language),
-		Description:         s.repoLocalization.GetWordOrDefault(bet.CkDescription, request.Language),
+		TypeName:            texts.Text(bet.Type.CkName),
+		Title:               texts.Text(bet.CkName),
+		Description:         texts.Get(bet.CkDescription),
 		Amount:              bet.CnAmount,
 		Coefficient:         bet.CnCoefficient,
 		Deadline:            bet.CtDeadline,
@@ -289,9 +345,29 @@ func (s *ParierService) CreateBet(request models.BetCreateRequest) (*models.BetR
 		}
 		res.VerificationSources = append(res.VerificationSources, models.VerificationSourceResponse{
 			ID:   verificationSource.CkId,
-			Name: *s.repoLocalization.GetWordOrDefault(&verificationSource.CkName, request.Language),
+			Name: s.resolver.Resolve(request.Language, &verificationSource.CkName).Text(verificationSource.CkName),
 		})
 	}
+	err = s.audit.Record(tx, actor, AuditEntry{
+		Action:     AuditBetCreate,
+		EntityType: "t_bet",
+		EntityID:   bet.CkId.String(),
+		After: map[string]any{
+			"ck_category":             bet.CkCategory,
+			"ck_type":                 bet.CkType,
+			"ck_status":               bet.CkStatus,
+			"ck_author":               bet.CkAuthor,
+			"cn_amount":               bet.CnAmount,
+			"cn_coefficient":          bet.CnCoefficient,
+			"ct_deadline":             bet.CtDeadline,
+			"ck_name":                 bet.CkName,
+			"ck_description":          bet.CkDescription,
+			"ck_verification_sources": request.VerificationSourceID,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return &res, nil
 }
@@ -377,13 +453,13 @@ func (s *ParierService) GetBets(request models.BetRequest) ([]*models.BetRespons
 		Preload("Author").
 		Preload("Author.UserProperties").
 		Preload("Author.UserProperties.PropertyType").
-		Preload("Author.BetHistory").
 		Preload("Author.RatingsReceived").
 		Preload("Author.LikesReceived").
+		Preload("Author.Reputation").
 		Select(`t_bet.*
 	, exists(select 1 from t_bet_like where ck_bet = t_bet.ck_id and ck_author = ? and ct_delete is null) as is_liked_by_me
 	, exists(select 1 from t_bet_rating where ck_bet = t_bet.ck_id and ck_user = ? and ct_delete is null) as is_rated_by_me
-	, (select avg(cn_rating) from t_bet_rating where ck_bet = t_bet.ck_id and ct_delete is null) as rating
+	, (select coalesce(round(avg(cn_rating)), 0)::int from t_bet_rating where ck_bet = t_bet.ck_id and ct_delete is null) as rating
 	, (select count(*) from t_bet_comment where ck_bet = t_bet.ck_id and ct_delete is null) as comments
 	, (select count(*) from t_bet_like where ck_bet = t_bet.ck_id and ct_delete is null) as likes
 	, (select count(*) from t_bet_amount where ck_bet = t_bet.ck_id and ct_delete is null) as bets_count`, request.User.ID.String(), request.User.ID.String()).
@@ -392,18 +468,28 @@ func (s *ParierService) GetBets(request models.BetRequest) ([]*models.BetRespons
 		return nil, 0, err
 	}
 
+	// resolve every localized field of the page in one query
+	ids := make([]*string, 0, len(bets)*6)
+	for i := range bets {
+		bet := &bets[i]
+		ids = append(ids, &bet.Category.CkName, &bet.Status.CkName, &bet.Type.CkName, &bet.CkName, bet.CkDescription)
+		for j := range bet.VerificationSources {
+			ids = append(ids, &bet.VerificationSources[j].VerificationSource.CkName)
+		}
+	}
+	texts := s.resolver.Resolve(request.Language, ids...)
 	var result []*models.BetResponse
 	for _, bet := range bets {
 		res := models.BetResponse{
 			ID:                  bet.CkId,
 			CategoryID:          bet.CkCategory,
-			CategoryName:        *s.repoLocalization.GetWordOrDefault(&bet.Category.CkName, request.Language),
+			CategoryName:        texts.Text(bet.Category.CkName),
 			StatusID:            bet.CkStatus,
-			StatusName:          *s.repoLocalization.GetWordOrDefault(&bet.Status.CkName, request.Language),
+			StatusName:          texts.Text(bet.Status.CkName),
 			TypeID:              bet.CkType,
-			TypeName:            *s.repoLocalization.GetWordOrDefault(&bet.Type.CkName, request.Language),
-			Title:               *s.repoLocalization.GetWordOrDefault(&bet.CkName, request.Language),
-			Description:         s.repoLocalization.GetWordOrDefault(bet.CkDescription, request.Language),
+			TypeName:            texts.Text(bet.Type.CkName),
+			Title:               texts.Text(bet.CkName),
+			Description:         texts.Get(bet.CkDescription),
 			Amount:              bet.CnAmount,
 			Coefficient:         bet.CnCoefficient,
 			Deadline:            bet.CtDeadline,
@@ -436,11 +522,12 @@ func (s *ParierService) GetBets(request models.BetRequest) ([]*models.BetRespons
 				DeletedAt: bet.Author.CtDelete,
 			},
 		}
+		setAuthorReputation(&res.Author, bet.Author.Reputation)
 
 		for i, verificationSource := range bet.VerificationSources {
 			res.VerificationSources[i] = models.VerificationSourceResponse{
 				ID:   verificationSource.VerificationSource.CkId,
-				Name: *s.repoLocalization.GetWordOrDefault(&verificationSource.VerificationSource.CkName, request.Language),
+				Name: texts.Text(verificationSource.VerificationSource.CkName),
 			}
 		}
 		result = append(result, &res)
@@ -451,7 +538,7 @@ func (s *ParierService) GetBets(request models.BetRequest) ([]*models.BetRespons
 func (s *ParierService) GetBetComments(betID uuid.UUID, request models.BetCommentRequest) ([]models.BetCommentResponse, int64, error) {
 	db := s.repo.GetDB()
 	query := db.Model(&models.TBetComment{})
-	query = query.Where("ck_bet = ?", betID)
+	query = query.Where("ck_bet = ? AND "+repository.CommentVisible("t_bet_comment"), betID)
 	if request.Search != nil {
 		subquery := db.Model(&models.TLocalizationWord{}).
 			Select("ck_localization").
@@ -473,52 +560,36 @@ func (s *ParierService) GetBetComments(betID uuid.UUID, request models.BetCommen
 		Preload("Author").
 		Preload("Author.UserProperties").
 		Preload("Author.UserProperties.PropertyType").
+		Preload("Author.Reputation").
 		Preload("Parent").
+		Preload("Parent.Author").
+		Preload("Parent.Author.UserProperties").
+		Preload("Parent.Author.UserProperties.PropertyType").
+		Preload("Parent.Author.Reputation").
 		Preload("Likes").
 		Preload("Media").
+		Preload("Mentions", "ct_delete IS NULL").
 		Find(&comments).Error
 	if err != nil {
 		return nil, 0, err
 	}
 	var result []models.BetCommentResponse
+	now := time.Now()
 	for _, comment := range comments {
 		result = append(result, models.BetCommentResponse{
 			ID:      comment.CkId,
 			Content: comment.CvContent,
-			Parent: util.IfThenElseFunc(comment.CkParent != nil, func() *models.BetCommentResponse {
-				parentComment, err := s.repo.GetBetCommentByID(*comment.CkParent)
-				if err != nil {
-					return nil
-				}
-				return &models.BetCommentResponse{
-					ID:        parentComment.CkId,
-					Content:   parentComment.CvContent,
-					CreatedAt: parentComment.CtCreate,
-					UpdatedAt: parentComment.CtModify,
-					DeletedAt: parentComment.CtDelete,
-					Author: models.AuthorResponse{
-						ID:       parentComment.Author.CkId,
-						Username: util.IfThenElseFunc(s.findUserProperty(parentComment.Author.UserProperties, "USER_USERNAME") != nil, func() *string { return s.findUserProperty(parentComment.Author.UserProperties, "USER_USERNAME").CvText }, func() *string { return nil }),
-						Avatar: util.IfThenElseFunc(s.findUserProperty(parentComment.Author.UserProperties, "USER_AVATAR") != nil, func() *uuid.UUID {
-							return s.findUserProperty(parentComment.Author.UserProperties, "USER_AVATAR").CkMedia
-						}, func() *uuid.UUID { return nil }),
-						Background: util.IfThenElseFunc(s.findUserProperty(parentComment.Author.UserProperties, "USER_BACKGROUND") != nil, func() *uuid.UUID {
-							return s.findUserProperty(parentComment.Author.UserProperties, "USER_BACKGROUND").CkMedia
-						}, func() *uuid.UUID { return nil }),
-						Verified: util.IfThenElseFunc(
-							s.findUserProperty(parentComment.Author.UserProperties, "USER_VERIFIED") != nil, func() bool {
-								return *s.findUserProperty(parentComment.Author.UserProperties, "USER_VERIFIED").ClBool
-							}, func() bool { return false }),
-						Likes:     len(parentComment.Author.LikesReceived),
-						Rating:    len(parentComment.Author.RatingsReceived),
-						WinRate:   s.calculateWinRate(parentComment.Author),
-						Interests: s.findUserInterests(parentComment.Author.UserProperties),
-						Location:  s.findUserLocation(parentComment.Author.UserProperties),
-						CreatedAt: parentComment.Author.CtCreate,
-						UpdatedAt: parentComment.Author.CtModify,
-						DeletedAt: parentComment.Author.CtDelete,
-					},
+			Parent: util.IfThenElseFunc(comment.Parent != nil, func() *models.BetCommentResponse {
+				parent := &models.BetCommentResponse{
+					ID:        comment.Parent.CkId,
+					Content:   comment.Parent.CvContent,
+					CreatedAt: comment.Parent.CtCreate,
+					UpdatedAt: comment.Parent.CtModify,
+					DeletedAt: comment.Parent.CtDelete,
+					Author:    s.authorResponse(comment.Parent.CkAuthor, comment.Parent.Author),
 				}
+				s.commentState(parent, comment.Parent, request.User.ID, now)
+				return parent
 			}, func() *models.BetCommentResponse { return nil }),
 			Likes:       len(comment.Likes),
 			IsLikedByMe: s.findBetCommentLike(comment.Likes, request.User.ID) != nil,
@@ -548,105 +619,195 @@ func (s *ParierService) GetBetComments(betID uuid.UUID, request models.BetCommen
 				DeletedAt: comment.Author.CtDelete,
 			},
 		})
+		setAuthorReputation(&result[len(result)-1].Author, comment.Author.Reputation)
+		s.commentState(&result[len(result)-1], &comment, request.User.ID, now)
 	}
 	return result, total, nil
 }
 
+// CreateBetComment создаёт комментарий, сохраняет упоминания пользователей из его текста
+// и уведомляет автора родительского комментария и упомянутых пользователей
 func (s *ParierService) CreateBetComment(betID uuid.UUID, request models.BetCommentCreateRequest) (bool, error) {
-	db := s.repo.GetDB()
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		} else {
-			tx.Commit()
+	var parentAuthor *uuid.UUID
+	if request.ParentID != nil {
+		parent, err := s.repo.GetBetCommentByID(*request.ParentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, &ServiceError{Code: "NOT_FOUND", Message: "Parent comment not found"}
 		}
-	}()
-	comment := models.TBetComment{
-		CkBet:     betID,
-		CkAuthor:  request.User.ID,
-		CvContent: request.Content,
-		CkParent:  request.ParentID,
-	}
-	err := s.repo.CreateBetComment(&comment, tx)
+		if err != nil {
+			return false, databaseError("Failed to get parent comment", err)
+		}
+		parentAuthor = &parent.CkAuthor
+	}
+	err := s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		comment := models.TBetComment{
+			CkBet:     betID,
+			CkAuthor:  request.User.ID,
+			CvContent: request.Content,
+			CkParent:  request.ParentID,
+			BaseModel: models.BaseModel{
+				CkCreate: request.User.ID.String(),
+				CkModify: request.User.ID.String(),
+			},
+		}
+		if err := s.repo.CreateBetComment(&comment, tx); err != nil {
+			return databaseError("Failed to create comment", err)
+		}
+		mentioned, err := s.syncMentions(tx, &comment)
+		if err != nil {
+			return err
+		}
+		if err := s.notifications.Notify(tx, request.User.ID.String(), commentNotices(&comment, request.User.Username, parentAuthor, mentioned)...); err != nil {
+			return err
+		}
+		return s.publishCommentCreated(tx, &comment, request.User, parentAuthor)
+	})
 	if err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
+// publishCommentCreated сообщает о комментарии подписчикам ставки, а автору
+// родительского комментария - об ответе ему
+func (s *ParierService) publishCommentCreated(tx *gorm.DB, comment *models.TBetComment, author *models.User, parentAuthor *uuid.UUID) error {
+	err := s.events.Publish(tx, BetTopic(comment.CkBet), EventCommentCreated, map[string]any{
+		"comment_id": comment.CkId,
+		"parent_id":  comment.CkParent,
+		"author_id":  comment.CkAuthor,
+	})
+	if err != nil || parentAuthor == nil || *parentAuthor == comment.CkAuthor {
+		return err
+	}
+	return s.events.Publish(tx, userTopic(TopicReplies, *parentAuthor), EventCommentReply, map[string]any{
+		"bet_id":     comment.CkBet,
+		"comment_id": comment.CkId,
+		"parent_id":  comment.CkParent,
+		"user_id":    author.ID,
+		"user":       author.Username,
+		"text":       excerpt(comment.CvContent),
+	})
+}
+
+// LikeBetComment ставит лайк комментарию и уведомляет его автора
 func (s *ParierService) LikeBetComment(commentID uuid.UUID, request models.DefaultRequest) (bool, error) {
-	db := s.repo.GetDB()
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		} else {
-			tx.Commit()
-		}
-	}()
-	like := models.TBetCommentLike{
-		CkComment: commentID,
-		CkAuthor:  request.User.ID,
-		CkType:    "LIKE",
+	comment, err := s.repo.GetBetCommentByID(commentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, &ServiceError{Code: "NOT_FOUND", Message: "Comment not found"}
 	}
-	err := s.repo.CreateBetCommentLike(&like, tx)
+	if err != nil {
+		return false, databaseError("Failed to get comment", err)
+	}
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		like := models.TBetCommentLike{
+			CkComment: commentID,
+			CkAuthor:  request.User.ID,
+			CkType:    "LIKE",
+		}
+		if err := s.repo.CreateBetCommentLike(&like, tx); err != nil {
+			return err
+		}
+		err := s.events.Publish(tx, BetTopic(comment.CkBet), EventCommentLiked, map[string]any{
+			"comment_id": comment.CkId,
+			"user_id":    request.User.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if comment.CkAuthor == request.User.ID {
+			return nil
+		}
+		return s.notifications.Notify(tx, request.User.ID.String(), Notice{
+			User: comment.CkAuthor,
+			Type: models.NotificationTypeCommentLike,
+			Payload: map[string]any{
+				"bet_id":     comment.CkBet,
+				"comment_id": comment.CkId,
+				"user_id":    request.User.ID,
+				"user":       request.User.Username,
+				"text":       excerpt(comment.CvContent),
+			},
+		})
+	})
 	if err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
+// UnlikeBetComment снимает лайк с комментария
 func (s *ParierService) UnlikeBetComment(commentID uuid.UUID, request models.DefaultRequest) (bool, error) {
-	db := s.repo.GetDB()
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		} else {
-			tx.Commit()
+	comment, err := s.repo.GetBetCommentByID(commentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, &ServiceError{Code: "NOT_FOUND", Message: "Comment not found"}
+	}
+	if err != nil {
+		return false, databaseError("Failed to get comment", err)
+	}
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.DeleteBetCommentLike(commentID, request.User.ID.String(), tx); err != nil {
+			return err
 		}
-	}()
-	err := s.repo.DeleteBetCommentLike(commentID, request.User.ID.String(), tx)
+		return s.events.Publish(tx, BetTopic(comment.CkBet), EventCommentUnliked, map[string]any{
+			"comment_id": comment.CkId,
+			"user_id":    request.User.ID,
+		})
+	})
 	if err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
+// LikeBet ставит лайк ставке и уведомляет её автора
 func (s *ParierService) LikeBet(betID uuid.UUID, request models.DefaultRequest) (bool, error) {
-	db := s.repo.GetDB()
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		} else {
-			tx.Commit()
-		}
-	}()
-	like := models.TBetLike{
-		CkBet:    betID,
-		CkAuthor: request.User.ID,
-		CkType:   "LIKE",
+	bet, err := s.repo.GetBetByID(betID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, &ServiceError{Code: "NOT_FOUND", Message: "Bet not found"}
 	}
-	err := s.repo.CreateBetLike(&like, tx)
+	if err != nil {
+		return false, databaseError("Failed to get bet", err)
+	}
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		like := models.TBetLike{
+			CkBet:    betID,
+			CkAuthor: request.User.ID,
+			CkType:   "LIKE",
+		}
+		if err := s.repo.CreateBetLike(&like, tx); err != nil {
+			return err
+		}
+		err := s.events.Publish(tx, BetTopic(bet.CkId), EventBetLiked, map[string]any{"user_id": request.User.ID})
+		if err != nil {
+			return err
+		}
+		if bet.CkAuthor == request.User.ID {
+			return nil
+		}
+		return s.notifications.Notify(tx, request.User.ID.String(), Notice{
+			User: bet.CkAuthor,
+			Type: models.NotificationTypeBetLike,
+			Payload: map[string]any{
+				"bet_id":  bet.CkId,
+				"user_id": request.User.ID,
+				"user":    request.User.Username,
+			},
+		})
+	})
 	if err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
+// UnlikeBet снимает лайк со ставки
 func (s *ParierService) UnlikeBet(betID uuid.UUID, request models.DefaultRequest) (bool, error) {
-	db := s.repo.GetDB()
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		} else {
-			tx.Commit()
+	err := s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.DeleteBetLike(betID, request.User.ID.String(), tx); err != nil {
+			return err
 		}
-	}()
-	err := s.repo.DeleteBetLike(betID, request.User.ID.String(), tx)
+		return s.events.Publish(tx, BetTopic(betID), EventBetUnliked, map[string]any{"user_id": request.User.ID})
+	})
 	if err != nil {
 		return false, err
 	}
@@ -661,7 +822,7 @@ func (s *ParierService) GetCurrentUser(currentUser *models.User) (*models.Author
 	query = query.Preload("UserProperties.PropertyType")
 	query = query.Preload("LikesReceived")
 	query = query.Preload("RatingsReceived")
-	query = query.Preload("BetHistory")
+	query = query.Preload("Reputation")
 	var user models.TUser
 	err := query.First(&user).Error
 	if err != nil {
@@ -688,10 +849,43 @@ func (s *ParierService) GetCurrentUser(currentUser *models.User) (*models.Author
 		UpdatedAt: user.CtModify,
 		DeletedAt: user.CtDelete,
 	}
+	setAuthorReputation(&res, user.Reputation)
 	return &res, nil
 }
 
 // HELPER FUNCTIONS
+
+// authorResponse собирает профиль автора; user может быть не загружен
+func (s *ParierService) authorResponse(id uuid.UUID, user *models.TUser) models.AuthorResponse {
+	if user == nil {
+		return models.AuthorResponse{ID: id}
+	}
+	res := models.AuthorResponse{
+		ID:        user.CkId,
+		Likes:     len(user.LikesReceived),
+		Rating:    len(user.RatingsReceived),
+		WinRate:   s.calculateWinRate(user),
+		Interests: s.findUserInterests(user.UserProperties),
+		Location:  s.findUserLocation(user.UserProperties),
+		CreatedAt: user.CtCreate,
+		UpdatedAt: user.CtModify,
+		DeletedAt: user.CtDelete,
+	}
+	if property := s.findUserProperty(user.UserProperties, "USER_USERNAME"); property != nil {
+		res.Username = property.CvText
+	}
+	if property := s.findUserProperty(user.UserProperties, "USER_AVATAR"); property != nil {
+		res.Avatar = property.CkMedia
+	}
+	if property := s.findUserProperty(user.UserProperties, "USER_BACKGROUND"); property != nil {
+		res.Background = property.CkMedia
+	}
+	if property := s.findUserProperty(user.UserProperties, "USER_VERIFIED"); property != nil && property.ClBool != nil {
+		res.Verified = *property.ClBool
+	}
+	setAuthorReputation(&res, user.Reputation)
+	return res
+}
 func (s *ParierService) findUserProperty(userProperties []models.TUserProperties, propertyType string) *models.TUserProperties {
 	for _, property := range userProperties {
 		if property.PropertyType.CkId == propertyType {
@@ -710,18 +904,13 @@ func (s *ParierService) findBetCommentLike(betCommentLikes []models.TBetCommentL
 	return nil
 }
 
+// calculateWinRate - процент выигранных ставок пользователя по счётчикам репутации,
+// которые пополняет расчёт ставок; без записи репутации рассчитанных ставок нет
 func (s *ParierService) calculateWinRate(user *models.TUser) int {
-	totalBets := len(user.BetHistory)
-	totalWin := 0
-	for _, bet := range user.BetHistory {
-		if bet.ClWin {
-			totalWin++
-		}
-	}
-	if totalBets == 0 {
+	if user.Reputation == nil {
 		return 0
 	}
-	return int(float64(totalWin) / float64(totalBets) * 100)
+	return winRate(user.Reputation.CnBets, user.Reputation.CnWins)
 }
 
 func (s *ParierService) findUserInterests(userProperties []models.TUserProperties) *[]string {
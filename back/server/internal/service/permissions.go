@@ -0,0 +1,194 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"parier-server/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// permissionStore - источник ролей и доступов, обычно repository.UserRepository
+type permissionStore interface {
+	GetUserRoleByID(id uuid.UUID) ([]string, error)
+	GetTableRolesByRoles(roles []string) ([]models.TDTableRole, error)
+	GetPropertiesRolesByRoles(roles []string) ([]models.TPropertiesRole, error)
+}
+
+// Permissions - роли и доступы одного набора ролей, загруженные разом
+type Permissions struct {
+	Roles      []string
+	tables     map[string]map[models.ActionType]bool
+	properties map[string]map[models.ActionType]bool
+}
+
+// HasRole проверяет наличие роли
+func (p *Permissions) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAnyRole проверяет наличие хотя бы одной из ролей
+func (p *Permissions) HasAnyRole(roles ...string) bool {
+	for _, role := range roles {
+		if p.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTable проверяет доступ action к таблице; доступ ALL разрешает любое действие
+func (p *Permissions) CanTable(table string, action models.ActionType) bool {
+	actions := p.tables[table]
+	return actions[action] || actions[models.ActionTypeAll]
+}
+
+// CanProperty проверяет доступ action к типу свойства; доступ ALL разрешает любое действие
+func (p *Permissions) CanProperty(propertyType string, action models.ActionType) bool {
+	actions := p.properties[propertyType]
+	return actions[action] || actions[models.ActionTypeAll]
+}
+
+// PermissionEvaluator загружает роли пользователя и доступы его ролей и кеширует их на ttl.
+// Запись ролей и доступов через UserRepository сбрасывает кеш; изменения на других инстансах
+// становятся видны не позже чем через ttl. При ttl = 0 кеш между запросами не используется.
+type PermissionEvaluator struct {
+	store permissionStore
+	ttl   time.Duration
+
+	mu         sync.RWMutex
+	users      map[uuid.UUID]cachedUserRoles
+	grants     map[string]cachedPermissions // отсортированный набор ролей -> доступы
+	generation uint64
+}
+
+type cachedUserRoles struct {
+	roles []string
+	at    time.Time
+}
+
+type cachedPermissions struct {
+	permissions *Permissions
+	at          time.Time
+}
+
+func NewPermissionEvaluator(store permissionStore, ttl time.Duration) *PermissionEvaluator {
+	return &PermissionEvaluator{
+		store:  store,
+		ttl:    ttl,
+		users:  make(map[uuid.UUID]cachedUserRoles),
+		grants: make(map[string]cachedPermissions),
+	}
+}
+
+// ForUser возвращает права пользователя по его действующим ролям
+func (e *PermissionEvaluator) ForUser(userID uuid.UUID) (*Permissions, error) {
+	e.mu.RLock()
+	cached, ok := e.users[userID]
+	generation := e.generation
+	e.mu.RUnlock()
+	if ok && e.fresh(cached.at) {
+		return e.ForRoles(cached.roles)
+	}
+
+	roles, err := e.store.GetUserRoleByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user roles: %w", err)
+	}
+	if e.ttl > 0 {
+		e.mu.Lock()
+		// пока роли загружались, кеш могли сбросить - тогда не сохраняем устаревшие данные
+		if e.generation == generation {
+			e.users[userID] = cachedUserRoles{roles: roles, at: time.Now()}
+		}
+		e.mu.Unlock()
+	}
+	return e.ForRoles(roles)
+}
+
+// ForRoles возвращает права набора ролей, например ролей анонимной сессии
+func (e *PermissionEvaluator) ForRoles(roles []string) (*Permissions, error) {
+	key := rolesKey(roles)
+	e.mu.RLock()
+	cached, ok := e.grants[key]
+	generation := e.generation
+	e.mu.RUnlock()
+	if ok && e.fresh(cached.at) {
+		return cached.permissions, nil
+	}
+
+	tableGrants, err := e.store.GetTableRolesByRoles(roles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table grants: %w", err)
+	}
+	propertyGrants, err := e.store.GetPropertiesRolesByRoles(roles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get property grants: %w", err)
+	}
+	permissions := buildPermissions(roles, tableGrants, propertyGrants)
+	if e.ttl > 0 {
+		e.mu.Lock()
+		if e.generation == generation {
+			e.grants[key] = cachedPermissions{permissions: permissions, at: time.Now()}
+		}
+		e.mu.Unlock()
+	}
+	return permissions, nil
+}
+
+// InvalidateUser сбрасывает закешированные роли пользователя
+func (e *PermissionEvaluator) InvalidateUser(userID uuid.UUID) {
+	e.mu.Lock()
+	delete(e.users, userID)
+	e.generation++
+	e.mu.Unlock()
+}
+
+// InvalidateAll сбрасывает весь кеш, например после изменения доступов роли
+func (e *PermissionEvaluator) InvalidateAll() {
+	e.mu.Lock()
+	e.users = make(map[uuid.UUID]cachedUserRoles)
+	e.grants = make(map[string]cachedPermissions)
+	e.generation++
+	e.mu.Unlock()
+}
+
+func (e *PermissionEvaluator) fresh(at time.Time) bool {
+	return e.ttl > 0 && time.Since(at) < e.ttl
+}
+
+func rolesKey(roles []string) string {
+	sorted := append([]string(nil), roles...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+func buildPermissions(roles []string, tableGrants []models.TDTableRole, propertyGrants []models.TPropertiesRole) *Permissions {
+	permissions := &Permissions{
+		Roles:      roles,
+		tables:     make(map[string]map[models.ActionType]bool),
+		properties: make(map[string]map[models.ActionType]bool),
+	}
+	for _, grant := range tableGrants {
+		if permissions.tables[grant.CkTable] == nil {
+			permissions.tables[grant.CkTable] = make(map[models.ActionType]bool)
+		}
+		permissions.tables[grant.CkTable][grant.CrAction] = true
+	}
+	for _, grant := range propertyGrants {
+		if permissions.properties[grant.CkType] == nil {
+			permissions.properties[grant.CkType] = make(map[models.ActionType]bool)
+		}
+		permissions.properties[grant.CkType][grant.CrAction] = true
+	}
+	return permissions
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"parier-server/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// countingPermissionStore считает обращения к БД
+type countingPermissionStore struct {
+	roles   map[uuid.UUID][]string
+	tables  []models.TDTableRole
+	queries int
+}
+
+func (s *countingPermissionStore) GetUserRoleByID(id uuid.UUID) ([]string, error) {
+	s.queries++
+	return s.roles[id], nil
+}
+
+func (s *countingPermissionStore) GetTableRolesByRoles(roles []string) ([]models.TDTableRole, error) {
+	s.queries++
+	var result []models.TDTableRole
+	for _, grant := range s.tables {
+		for _, role := range roles {
+			if grant.CkRole == role {
+				result = append(result, grant)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *countingPermissionStore) GetPropertiesRolesByRoles(roles []string) ([]models.TPropertiesRole, error) {
+	s.queries++
+	return nil, nil
+}
+
+func newCountingPermissionStore(userID uuid.UUID) *countingPermissionStore {
+	return &countingPermissionStore{
+		roles: map[uuid.UUID][]string{userID: {"VIEWER"}},
+		tables: []models.TDTableRole{
+			{CkRole: "VIEWER", CkTable: "t_bet", CrAction: models.ActionTypeView},
+			{CkRole: "ADMIN", CkTable: "t_bet", CrAction: models.ActionTypeAll},
+		},
+	}
+}
+
+func TestPermissionsCanTable(t *testing.T) {
+	store := newCountingPermissionStore(uuid.New())
+	evaluator := NewPermissionEvaluator(store, 0)
+
+	viewer, err := evaluator.ForRoles([]string{"VIEWER"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !viewer.CanTable("t_bet", models.ActionTypeView) || viewer.CanTable("t_bet", models.ActionTypeDelete) {
+		t.Errorf("VIEWER must only view t_bet")
+	}
+
+	admin, err := evaluator.ForRoles([]string{"ADMIN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !admin.CanTable("t_bet", models.ActionTypeDelete) {
+		t.Errorf("ALL grant must allow DELETE")
+	}
+	if admin.CanTable("t_session", models.ActionTypeView) {
+		t.Errorf("grant on t_bet must not allow t_session")
+	}
+}
+
+func TestPermissionEvaluatorCache(t *testing.T) {
+	userID := uuid.New()
+	store := newCountingPermissionStore(userID)
+	evaluator := NewPermissionEvaluator(store, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := evaluator.ForUser(userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if store.queries != 3 {
+		t.Fatalf("expected 3 queries for the first load only, got %d", store.queries)
+	}
+
+	store.roles[userID] = []string{"ADMIN"}
+	evaluator.InvalidateUser(userID)
+	permissions, err := evaluator.ForUser(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !permissions.HasRole("ADMIN") || !permissions.CanTable("t_bet", models.ActionTypeDelete) {
+		t.Errorf("roles must be reloaded after InvalidateUser, got %v", permissions.Roles)
+	}
+
+	store.tables = nil
+	evaluator.InvalidateAll()
+	permissions, err = evaluator.ForUser(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if permissions.CanTable("t_bet", models.ActionTypeView) {
+		t.Errorf("grants must be reloaded after InvalidateAll")
+	}
+}
+
+func TestPermissionEvaluatorWithoutTTL(t *testing.T) {
+	userID := uuid.New()
+	store := newCountingPermissionStore(userID)
+	evaluator := NewPermissionEvaluator(store, 0)
+
+	for i := 0; i < 2; i++ {
+		if _, err := evaluator.ForUser(userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if store.queries != 6 {
+		t.Errorf("expected every call to hit the store, got %d queries", store.queries)
+	}
+}
+
+// checksPerRequest - сколько раз типичный запрос проверяет права (middleware, роли, доступы в сервисе)
+const checksPerRequest = 4
+
+func benchmarkPermissionChecks(b *testing.B, ttl time.Duration, memoize bool) {
+	userID := uuid.New()
+	store := newCountingPermissionStore(userID)
+	evaluator := NewPermissionEvaluator(store, ttl)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var memo *Permissions
+		for j := 0; j < checksPerRequest; j++ {
+			permissions := memo
+			if permissions == nil {
+				var err error
+				permissions, err = evaluator.ForUser(userID)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if memoize {
+					memo = permissions
+				}
+			}
+			permissions.CanTable("t_bet", models.ActionTypeView)
+		}
+	}
+	b.ReportMetric(float64(store.queries)/float64(b.N), "queries/op")
+}
+
+func BenchmarkPermissionsUncached(b *testing.B) {
+	benchmarkPermissionChecks(b, 0, false)
+}
+
+func BenchmarkPermissionsPerRequest(b *testing.B) {
+	benchmarkPermissionChecks(b, 0, true)
+}
+
+func BenchmarkPermissionsTTLCache(b *testing.B) {
+	benchmarkPermissionChecks(b, time.Minute, true)
+}
@@ -0,0 +1,412 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"parier-server/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Шкала оценок пользователей и ставок
+const (
+	ratingMin = 1
+	ratingMax = 5
+)
+
+// Параметры репутации. Среднее оценок тянется к reputationPriorRating так, будто у каждого
+// уже есть reputationPriorWeight таких оценок: одна пятёрка не даёт максимальной репутации.
+// При reputationVolumeHalf оценках их количество даёт половину своего вклада
+const (
+	reputationPriorRating = 3
+	reputationPriorWeight = 5
+	reputationVolumeHalf  = 20
+)
+
+// reputationScore считает репутацию от 0 до 100: 50 - сглаженная средняя оценка,
+// 20 - количество оценок, 30 - доля выигранных ставок.
+// Формула повторена в заполнении t_user_reputation (changeset init_user_reputation)
+func reputationScore(ratingSum int64, ratings, bets, wins int) float64 {
+	average := (float64(ratingSum) + reputationPriorRating*reputationPriorWeight) / float64(ratings+reputationPriorWeight)
+	score := 50*(average-ratingMin)/(ratingMax-ratingMin) +
+		20*float64(ratings)/float64(ratings+reputationVolumeHalf) +
+		30*float64(winRate(bets, wins))/100
+	return math.Round(score*100) / 100
+}
+
+// winRate - процент выигранных ставок, 0 без рассчитанных ставок
+func winRate(bets, wins int) int {
+	if bets == 0 {
+		return 0
+	}
+	return wins * 100 / bets
+}
+
+func ratingAverage(ratingSum int64, ratings int) float64 {
+	if ratings == 0 {
+		return 0
+	}
+	return math.Round(float64(ratingSum)/float64(ratings)*100) / 100
+}
+
+// reputationDelta - изменение счётчиков репутации
+type reputationDelta struct {
+	ratingSum int64
+	ratings   int
+	bets      int
+	wins      int
+}
+
+// ratingDelta - изменение счётчиков при замене оценки previous на current; nil - оценки нет
+func ratingDelta(previous, current *int) reputationDelta {
+	var delta reputationDelta
+	if previous != nil {
+		delta.ratingSum -= int64(*previous)
+		delta.ratings--
+	}
+	if current != nil {
+		delta.ratingSum += int64(*current)
+		delta.ratings++
+	}
+	return delta
+}
+
+// applyReputation меняет счётчики и пересчитывает репутацию
+func applyReputation(reputation *models.TUserReputation, delta reputationDelta) {
+	reputation.CnRatingSum += delta.ratingSum
+	reputation.CnRatingCount += delta.ratings
+	reputation.CnBets += delta.bets
+	reputation.CnWins += delta.wins
+	reputation.CnScore = reputationScore(reputation.CnRatingSum, reputation.CnRatingCount, reputation.CnBets, reputation.CnWins)
+}
+
+// setAuthorReputation заполняет репутацию в профиле автора; без записи
+// репутации у пользователя ещё нет ни оценок, ни рассчитанных ставок
+func setAuthorReputation(res *models.AuthorResponse, reputation *models.TUserReputation) {
+	if reputation == nil {
+		res.Reputation = reputationScore(0, 0, 0, 0)
+		return
+	}
+	res.Reputation = reputation.CnScore
+	res.RatingAvg = ratingAverage(reputation.CnRatingSum, reputation.CnRatingCount)
+}
+
+func reputationResponse(userID uuid.UUID, reputation *models.TUserReputation, myRating *int) *models.ReputationResponse {
+	if reputation == nil {
+		reputation = &models.TUserReputation{CkUser: userID, CnScore: reputationScore(0, 0, 0, 0)}
+	}
+	return &models.ReputationResponse{
+		UserID:    userID,
+		Score:     reputation.CnScore,
+		RatingAvg: ratingAverage(reputation.CnRatingSum, reputation.CnRatingCount),
+		Ratings:   reputation.CnRatingCount,
+		Bets:      reputation.CnBets,
+		Wins:      reputation.CnWins,
+		WinRate:   winRate(reputation.CnBets, reputation.CnWins),
+		MyRating:  myRating,
+	}
+}
+
+func validateRating(rating int) error {
+	if rating < ratingMin || rating > ratingMax {
+		return &ServiceError{Code: "VALIDATION_ERROR", Message: fmt.Sprintf("Rating must be between %d and %d", ratingMin, ratingMax)}
+	}
+	return nil
+}
+
+// changeReputation применяет изменение к репутации, заблокированной LockUserReputation
+func (s *ParierService) changeReputation(reputation *models.TUserReputation, delta reputationDelta, actor string, tx *gorm.DB) error {
+	if delta == (reputationDelta{}) {
+		return nil
+	}
+	applyReputation(reputation, delta)
+	reputation.CkModify = actor
+	return s.repo.SaveUserReputation(reputation, tx)
+}
+
+// lockReputation блокирует репутацию пользователя до конца транзакции, см. LockUserReputation
+func (s *ParierService) lockReputation(userID uuid.UUID, actor string, tx *gorm.DB) (*models.TUserReputation, error) {
+	reputation := models.TUserReputation{
+		CkUser:    userID,
+		CnScore:   reputationScore(0, 0, 0, 0),
+		BaseModel: models.BaseModel{CkCreate: actor, CkModify: actor},
+	}
+	if err := s.repo.LockUserReputation(&reputation, tx); err != nil {
+		return nil, err
+	}
+	return &reputation, nil
+}
+
+func (s *ParierService) ratedUser(userID uuid.UUID) error {
+	if _, err := s.repoUser.GetUserByID(userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &ServiceError{Code: "NOT_FOUND", Message: "User not found"}
+		}
+		return databaseError("Failed to get user", err)
+	}
+	return nil
+}
+
+func (s *ParierService) ratedBet(betID uuid.UUID) (*models.TBet, error) {
+	bet, err := s.repo.GetBetByID(betID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &ServiceError{Code: "NOT_FOUND", Message: "Bet not found"}
+	}
+	if err != nil {
+		return nil, databaseError("Failed to get bet", err)
+	}
+	return bet, nil
+}
+
+// GetUserReputation возвращает репутацию пользователя и оценку, которую ему поставил текущий пользователь
+func (s *ParierService) GetUserReputation(userID uuid.UUID, request models.DefaultRequest) (*models.ReputationResponse, error) {
+	if err := s.ratedUser(userID); err != nil {
+		return nil, err
+	}
+	reputation, err := s.repo.GetUserReputation(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		reputation = nil
+	} else if err != nil {
+		return nil, databaseError("Failed to get reputation", err)
+	}
+	var myRating *int
+	if request.User != nil {
+		rating, err := s.repo.GetUserRating(userID, request.User.ID, nil)
+		if err != nil {
+			return nil, databaseError("Failed to get rating", err)
+		}
+		if rating != nil {
+			myRating = &rating.CnRating
+		}
+	}
+	return reputationResponse(userID, reputation, myRating), nil
+}
+
+// RateUser ставит пользователю оценку или меняет поставленную; репутация пересчитывается
+// в той же транзакции
+func (s *ParierService) RateUser(userID uuid.UUID, request models.RatingRequest) (*models.ReputationResponse, error) {
+	if err := validateRating(request.Rating); err != nil {
+		return nil, err
+	}
+	if request.User.ID == userID {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Cannot rate yourself"}
+	}
+	if err := s.ratedUser(userID); err != nil {
+		return nil, err
+	}
+	actor := request.User.ID.String()
+	var reputation *models.TUserReputation
+	err := s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		// блокировка репутации упорядочивает изменения оценок пользователя
+		if reputation, err = s.lockReputation(userID, actor, tx); err != nil {
+			return err
+		}
+		previous, err := s.repo.GetUserRating(userID, request.User.ID, tx)
+		if err != nil {
+			return err
+		}
+		rating := models.TUserRating{
+			CkUser:    userID,
+			CkAuthor:  request.User.ID,
+			CnRating:  request.Rating,
+			BaseModel: models.BaseModel{CkCreate: actor, CkModify: actor},
+		}
+		if err := s.repo.SetUserRating(&rating, tx); err != nil {
+			return err
+		}
+		var before *int
+		if previous != nil {
+			before = &previous.CnRating
+		}
+		return s.changeReputation(reputation, ratingDelta(before, &request.Rating), actor, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reputationResponse(userID, reputation, &request.Rating), nil
+}
+
+// UnrateUser снимает оценку пользователя; без оценки ничего не меняет
+func (s *ParierService) UnrateUser(userID uuid.UUID, request models.DefaultRequest) (*models.ReputationResponse, error) {
+	if err := s.ratedUser(userID); err != nil {
+		return nil, err
+	}
+	actor := request.User.ID.String()
+	var reputation *models.TUserReputation
+	err := s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		if reputation, err = s.lockReputation(userID, actor, tx); err != nil {
+			return err
+		}
+		previous, err := s.repo.GetUserRating(userID, request.User.ID, tx)
+		if err != nil || previous == nil {
+			return err
+		}
+		if err := s.repo.RemoveUserRating(userID, request.User.ID, actor, tx); err != nil {
+			return err
+		}
+		return s.changeReputation(reputation, ratingDelta(&previous.CnRating, nil), actor, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reputationResponse(userID, reputation, nil), nil
+}
+
+// RateBet ставит ставке оценку или меняет поставленную. Оценка ставки входит в репутацию её автора
+func (s *ParierService) RateBet(betID uuid.UUID, request models.RatingRequest) (*models.BetRatingResponse, error) {
+	if err := validateRating(request.Rating); err != nil {
+		return nil, err
+	}
+	bet, err := s.ratedBet(betID)
+	if err != nil {
+		return nil, err
+	}
+	if bet.CkAuthor == request.User.ID {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Cannot rate your own bet"}
+	}
+	return s.changeBetRating(bet, request.User.ID, &request.Rating)
+}
+
+// UnrateBet снимает оценку ставки; без оценки ничего не меняет
+func (s *ParierService) UnrateBet(betID uuid.UUID, request models.DefaultRequest) (*models.BetRatingResponse, error) {
+	bet, err := s.ratedBet(betID)
+	if err != nil {
+		return nil, err
+	}
+	return s.changeBetRating(bet, request.User.ID, nil)
+}
+
+// changeBetRating заменяет оценку ставки от userID на rating (nil - снять оценку)
+func (s *ParierService) changeBetRating(bet *models.TBet, userID uuid.UUID, rating *int) (*models.BetRatingResponse, error) {
+	actor := userID.String()
+	res := models.BetRatingResponse{BetID: bet.CkId, MyRating: rating}
+	err := s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		reputation, err := s.lockReputation(bet.CkAuthor, actor, tx)
+		if err != nil {
+			return err
+		}
+		previous, err := s.repo.GetBetRating(bet.CkId, userID, tx)
+		if err != nil {
+			return err
+		}
+		var before *int
+		if previous != nil {
+			before = &previous.CnRating
+		}
+		delta := ratingDelta(before, rating)
+		if rating != nil {
+			value := models.TBetRating{
+				CkBet:     bet.CkId,
+				CkUser:    userID,
+				CnRating:  *rating,
+				BaseModel: models.BaseModel{CkCreate: actor, CkModify: actor},
+			}
+			err = s.repo.SetBetRating(&value, tx)
+		} else if previous != nil {
+			err = s.repo.RemoveBetRating(bet.CkId, userID, actor, tx)
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.changeReputation(reputation, delta, actor, tx); err != nil {
+			return err
+		}
+		res.Reputation = reputation.CnScore
+		if res.Rating, res.Ratings, err = s.repo.GetBetRatingStats(bet.CkId, tx); err != nil {
+			return err
+		}
+		if before == nil && rating == nil {
+			return nil
+		}
+		return s.events.Publish(tx, BetTopic(bet.CkId), EventBetRated, map[string]any{
+			"user_id": userID,
+			"rating":  res.Rating,
+			"ratings": res.Ratings,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SettleBet рассчитывает открытую ставку: закрывает её и записывает результат автора в историю
+// ставок и репутацию. За выигрыш пригласившему автора начисляется процент от суммы выигрыша.
+// Возвращает репутацию автора. Выигрыш на кошелёк не начисляется
+func (s *ParierService) SettleBet(betID uuid.UUID, request models.BetSettleRequest, actor AuditActor) (*models.ReputationResponse, error) {
+	userID := request.User.ID.String()
+	var reputation *models.TUserReputation
+	var author uuid.UUID
+	err := s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		bet, err := s.repo.LockBet(betID, tx)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &ServiceError{Code: "NOT_FOUND", Message: "Bet not found"}
+		}
+		if err != nil {
+			return databaseError("Failed to get bet", err)
+		}
+		if bet.CkStatus != "OPEN" {
+			return &ServiceError{Code: "VALIDATION_ERROR", Message: "Only open bets can be settled"}
+		}
+		author = bet.CkAuthor
+		if err := s.repo.SetBetStatus(bet.CkId, "CLOSED", userID, tx); err != nil {
+			return databaseError("Failed to close bet", err)
+		}
+		if reputation, err = s.RecordBetResult(tx, bet.CkAuthor, bet.CkId, *request.Win, userID); err != nil {
+			return databaseError("Failed to record bet result", err)
+		}
+		if *request.Win {
+			winnings := roundMoney(bet.CnAmount * bet.CnCoefficient)
+			err = s.referrals.AccrueCommission(tx, bet.CkAuthor, models.ReferralEarningTypeWinnings, bet.CkId, winnings, actor)
+			if err != nil {
+				return err
+			}
+		}
+		err = s.events.Publish(tx, BetTopic(bet.CkId), EventBetSettled, map[string]any{
+			"author_id": bet.CkAuthor,
+			"win":       *request.Win,
+		})
+		if err != nil {
+			return err
+		}
+		return s.audit.Record(tx, actor, AuditEntry{
+			Action:     AuditBetSettle,
+			EntityType: "t_bet",
+			EntityID:   bet.CkId.String(),
+			Before:     map[string]any{"ck_status": bet.CkStatus},
+			After:      map[string]any{"ck_status": "CLOSED", "cl_win": *request.Win},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reputationResponse(author, reputation, nil), nil
+}
+
+// RecordBetResult записывает результат рассчитанной ставки пользователя в историю и учитывает
+// его в репутации. Вызывается при расчёте ставки в его транзакции
+func (s *ParierService) RecordBetResult(tx *gorm.DB, userID, betID uuid.UUID, win bool, actor string) (*models.TUserReputation, error) {
+	history := models.TUserBetHistory{
+		CkUser:    userID,
+		CkBet:     betID,
+		ClWin:     win,
+		BaseModel: models.BaseModel{CkCreate: actor, CkModify: actor},
+	}
+	if err := s.repo.CreateUserBetHistory(&history, tx); err != nil {
+		return nil, err
+	}
+	reputation, err := s.lockReputation(userID, actor, tx)
+	if err != nil {
+		return nil, err
+	}
+	delta := reputationDelta{bets: 1}
+	if win {
+		delta.wins = 1
+	}
+	return reputation, s.changeReputation(reputation, delta, actor, tx)
+}
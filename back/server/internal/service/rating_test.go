@@ -0,0 +1,160 @@
+package service
+
+import (
+	"database/sql/driver"
+	"slices"
+	"testing"
+
+	"parier-server/internal/config"
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+	"parier-server/internal/util/dbtest"
+
+	"github.com/google/uuid"
+)
+
+func TestReputationScore(t *testing.T) {
+	if score := reputationScore(0, 0, 0, 0); score != 25 {
+		t.Errorf("new user must have the neutral score 25, got %v", score)
+	}
+	if score := reputationScore(1000*ratingMax, 1000, 1000, 1000); score < 99 || score > 100 {
+		t.Errorf("only top ratings and wins must approach 100, got %v", score)
+	}
+	if score := reputationScore(1000*ratingMin, 1000, 1000, 0); score < 0 || score > 20 {
+		t.Errorf("only bottom ratings and losses must stay low, got %v", score)
+	}
+	one, many := reputationScore(5, 1, 0, 0), reputationScore(5*30, 30, 0, 0)
+	if one >= many {
+		t.Errorf("a single top rating must weigh less than many, got %v >= %v", one, many)
+	}
+	if low, high := reputationScore(0, 0, 10, 2), reputationScore(0, 0, 10, 8); low >= high {
+		t.Errorf("win rate must raise the score, got %v >= %v", low, high)
+	}
+	if rate := winRate(100, 29); rate != 29 {
+		t.Errorf("win rate must not lose a percent to rounding, got %d", rate)
+	}
+	s := &ParierService{}
+	if rate := s.calculateWinRate(&models.TUser{Reputation: &models.TUserReputation{CnBets: 4, CnWins: 3}}); rate != 75 {
+		t.Errorf("author win rate must come from the reputation counters, got %d", rate)
+	}
+	if rate := s.calculateWinRate(&models.TUser{}); rate != 0 {
+		t.Errorf("author without settled bets must have no win rate, got %d", rate)
+	}
+}
+
+func TestRatingDelta(t *testing.T) {
+	two, five := 2, 5
+	reputation := models.TUserReputation{}
+	applyReputation(&reputation, ratingDelta(nil, &two))
+	applyReputation(&reputation, ratingDelta(&two, &five))
+	if reputation.CnRatingSum != 5 || reputation.CnRatingCount != 1 {
+		t.Errorf("changed rating must replace the previous one, got sum %d count %d", reputation.CnRatingSum, reputation.CnRatingCount)
+	}
+	if reputation.CnScore != reputationScore(5, 1, 0, 0) {
+		t.Errorf("score must follow the counters, got %v", reputation.CnScore)
+	}
+	applyReputation(&reputation, ratingDelta(&five, nil))
+	if reputation.CnRatingSum != 0 || reputation.CnRatingCount != 0 || reputation.CnScore != reputationScore(0, 0, 0, 0) {
+		t.Errorf("removed rating must be subtracted, got %+v", reputation)
+	}
+	if delta := ratingDelta(nil, nil); delta != (reputationDelta{}) {
+		t.Errorf("no rating must change nothing, got %+v", delta)
+	}
+}
+
+// settleDB хранит в памяти ставку, историю и репутацию автора и отвечает на запросы SettleBet
+type settleDB struct {
+	author     uuid.UUID
+	status     string
+	history    []bool
+	reputation *models.TUserReputation
+}
+
+func (d *settleDB) handle(q dbtest.Query) dbtest.Result {
+	switch {
+	case q.Has(`FROM "t_bet"`, "FOR UPDATE"):
+		return dbtest.Result{
+			Columns: []string{"ck_id", "ck_author", "ck_status", "cn_amount", "cn_coefficient"},
+			Rows:    [][]driver.Value{{q.Args[0], d.author.String(), d.status, 100.0, 2.5}},
+		}
+	case q.Has(`UPDATE "t_bet"`):
+		status, _ := q.Value("ck_status")
+		d.status = status.(string)
+		return dbtest.Result{Affected: 1}
+	case q.Has(`INSERT INTO "t_user_bet_history"`):
+		win, _ := q.Value("cl_win")
+		d.history = append(d.history, win.(bool))
+	case q.Has(`INSERT INTO "t_user_reputation"`):
+		if d.reputation == nil {
+			d.reputation = &models.TUserReputation{CkUser: d.author}
+		}
+	case q.Has(`FROM "t_user_reputation"`, "FOR UPDATE"):
+		r := d.reputation
+		return dbtest.Result{
+			Columns: []string{"ck_user", "cn_rating_sum", "cn_rating_count", "cn_bets", "cn_wins", "cn_score"},
+			Rows:    [][]driver.Value{{r.CkUser.String(), r.CnRatingSum, int64(r.CnRatingCount), int64(r.CnBets), int64(r.CnWins), r.CnScore}},
+		}
+	case q.Has(`UPDATE "t_user_reputation"`):
+		bets, _ := q.Value("cn_bets")
+		wins, _ := q.Value("cn_wins")
+		d.reputation.CnBets, d.reputation.CnWins = int(bets.(int64)), int(wins.(int64))
+		return dbtest.Result{Affected: 1}
+	}
+	return dbtest.Result{}
+}
+
+func TestSettleBet(t *testing.T) {
+	win, loss := true, false
+	tests := []struct {
+		name       string
+		status     string
+		results    []*bool
+		settled    int // сколько расчётов должно пройти
+		history    []bool
+		bets, wins int
+	}{
+		{"win", "OPEN", []*bool{&win}, 1, []bool{true}, 1, 1},
+		{"loss", "OPEN", []*bool{&loss}, 1, []bool{false}, 1, 0},
+		{"closed bet", "CLOSED", []*bool{&win}, 0, nil, 0, 0},
+		{"settled twice", "OPEN", []*bool{&win, &loss}, 1, []bool{true}, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &settleDB{author: uuid.New(), status: tt.status}
+			db, fake := dbtest.Open(t, state.handle)
+			referrals := NewReferralService(repository.NewReferralRepository(db), nil, nil, config.ReferralConfig{WinningsPercent: 1})
+			s := NewParierService(repository.NewParierRepository(db), nil, nil, nil, referrals, NewAuditService(repository.NewAuditRepository(db)), nil, nil, config.CommentsConfig{})
+
+			settled := 0
+			var res *models.ReputationResponse
+			for _, result := range tt.results {
+				request := models.BetSettleRequest{Win: result, DefaultRequest: models.DefaultRequest{User: &models.User{ID: uuid.New()}}}
+				out, err := s.SettleBet(uuid.New(), request, AuditActor{User: "admin"})
+				if err != nil {
+					if serviceErr := GetServiceError(err); serviceErr == nil || serviceErr.Code != "VALIDATION_ERROR" {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					continue
+				}
+				settled++
+				res = out
+			}
+			if settled != tt.settled {
+				t.Errorf("expected %d settlements, got %d", tt.settled, settled)
+			}
+			if tt.settled > 0 && state.status != "CLOSED" {
+				t.Errorf("expected the bet to be closed, got %s", state.status)
+			}
+			if !slices.Equal(state.history, tt.history) {
+				t.Errorf("expected history %v, got %v", tt.history, state.history)
+			}
+			if res != nil && (res.Bets != tt.bets || res.Wins != tt.wins || res.WinRate != winRate(tt.bets, tt.wins)) {
+				t.Errorf("expected %d bets and %d wins, got %+v", tt.bets, tt.wins, res)
+			}
+			// процент с выигрыша ищет пригласившего только для выигранной ставки
+			if lookups, want := fake.Count(`FROM "t_referral"`), tt.wins; lookups != want {
+				t.Errorf("expected %d referral lookups, got %d", want, lookups)
+			}
+		})
+	}
+}
@@ -0,0 +1,453 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	roleIDPattern    = regexp.MustCompile(`^[A-Z][A-Z0-9_]{0,254}$`)
+	tableNamePattern = regexp.MustCompile(`^t_[a-z0-9_]+$`)
+)
+
+// concreteActions - действия, на которые раскрывается ALL
+var concreteActions = []models.ActionType{
+	models.ActionTypeView,
+	models.ActionTypeInsert,
+	models.ActionTypeUpdate,
+	models.ActionTypeDelete,
+}
+
+// RoleAssignment describes a time-bounded role of a user
+type RoleAssignment struct {
+	ID     uuid.UUID  `json:"id"`
+	Role   string     `json:"role"`
+	Start  time.Time  `json:"start"`
+	End    *time.Time `json:"end,omitempty"`
+	Active bool       `json:"active"`
+}
+
+// PermissionSource is a grant that gives a permission
+type PermissionSource struct {
+	Role    string            `json:"role"`
+	GrantID uuid.UUID         `json:"grant_id"`
+	Action  models.ActionType `json:"action"`
+}
+
+// EffectivePermission is an action on a table or property type and the grants that give it
+type EffectivePermission struct {
+	Object    string             `json:"object"`
+	Action    models.ActionType  `json:"action"`
+	GrantedBy []PermissionSource `json:"granted_by"`
+}
+
+// EffectivePermissions explains what a user may do and which role grants it
+type EffectivePermissions struct {
+	UserID     uuid.UUID             `json:"user_id"`
+	Roles      []RoleAssignment      `json:"roles"`
+	Tables     []EffectivePermission `json:"tables"`
+	Properties []EffectivePermission `json:"properties"`
+}
+
+// RBACService управляет ролями, их доступами к таблицам и свойствам и назначением ролей пользователям.
+// Изменения сбрасывают кеш прав (PermissionEvaluator) и записываются в журнал аудита.
+type RBACService struct {
+	repo    *repository.UserRepository
+	locRepo *repository.LocalizationRepository
+	audit   *AuditService
+}
+
+func NewRBACService(repo *repository.UserRepository, locRepo *repository.LocalizationRepository, audit *AuditService) *RBACService {
+	return &RBACService{repo: repo, locRepo: locRepo, audit: audit}
+}
+
+// ListRoles возвращает роли с их доступами
+func (s *RBACService) ListRoles() ([]models.TDRole, error) {
+	roles, err := s.repo.GetRolesWithGrants()
+	if err != nil {
+		return nil, databaseError("Failed to get roles", err)
+	}
+	return roles, nil
+}
+
+// CreateRole создаёт роль. Наименование и описание - идентификаторы локализации
+func (s *RBACService) CreateRole(id string, name string, description *string, place models.RolePlace, actor AuditActor) (*models.TDRole, error) {
+	id = strings.ToUpper(strings.TrimSpace(id))
+	if !roleIDPattern.MatchString(id) {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Role ID must consist of letters, digits and underscores"}
+	}
+	if place == "" {
+		place = models.RolePlaceGlobal
+	}
+	if !models.IsValidRolePlace(place) {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: fmt.Sprintf("Unknown role place %s", place)}
+	}
+	for _, loc := range []*string{&name, description} {
+		if loc == nil {
+			continue
+		}
+		if _, err := s.locRepo.GetLocalizationByID(*loc); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: fmt.Sprintf("Localization %s not found", *loc)}
+			}
+			return nil, databaseError("Failed to check localization", err)
+		}
+	}
+	if _, err := s.repo.GetRoleByID(id); err == nil {
+		return nil, &ServiceError{Code: "ALREADY_EXISTS", Message: fmt.Sprintf("Role %s already exists", id)}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, databaseError("Failed to get role", err)
+	}
+
+	role := &models.TDRole{
+		CkId:          id,
+		CkName:        name,
+		CkDescription: description,
+		CrPlace:       place,
+		BaseModel: models.BaseModel{
+			CkCreate: actor.User,
+			CkModify: actor.User,
+		},
+	}
+	if err := s.repo.CreateRole(role); err != nil {
+		return nil, databaseError("Failed to create role", err)
+	}
+	s.audit.RecordCommitted(actor, AuditEntry{Action: AuditRoleCreate, EntityType: "t_d_role", EntityID: role.CkId, After: role})
+	return role, nil
+}
+
+// DeleteRole удаляет роль вместе с её доступами и завершает её назначения
+func (s *RBACService) DeleteRole(id string, actor AuditActor) error {
+	role, err := s.getRole(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteRole(id, actor.User); err != nil {
+		return databaseError("Failed to delete role", err)
+	}
+	s.audit.RecordCommitted(actor, AuditEntry{Action: AuditRoleDelete, EntityType: "t_d_role", EntityID: role.CkId, Before: role})
+	return nil
+}
+
+// GrantTable выдаёт роли доступ к таблице
+func (s *RBACService) GrantTable(roleID string, table string, action models.ActionType, actor AuditActor) (*models.TDTableRole, error) {
+	if _, err := s.getRole(roleID); err != nil {
+		return nil, err
+	}
+	if !models.IsValidActionType(action) {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: fmt.Sprintf("Unknown action %s", action)}
+	}
+	if !tableNamePattern.MatchString(table) {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: fmt.Sprintf("Invalid table name %s", table)}
+	}
+	exists, err := s.repo.TableExists(table)
+	if err != nil {
+		return nil, databaseError("Failed to check table", err)
+	}
+	if !exists {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: fmt.Sprintf("Table %s not found", table)}
+	}
+	granted, err := s.repo.HasTableRole(roleID, table, action)
+	if err != nil {
+		return nil, databaseError("Failed to check grant", err)
+	}
+	if granted {
+		return nil, &ServiceError{Code: "ALREADY_EXISTS", Message: fmt.Sprintf("Role %s already has %s on %s", roleID, action, table)}
+	}
+
+	grant := &models.TDTableRole{
+		CkId:     uuid.New(),
+		CkRole:   roleID,
+		CkTable:  table,
+		CrAction: action,
+		BaseModel: models.BaseModel{
+			CkCreate: actor.User,
+			CkModify: actor.User,
+		},
+	}
+	if err := s.repo.CreateTableRole(grant); err != nil {
+		return nil, databaseError("Failed to create grant", err)
+	}
+	s.audit.RecordCommitted(actor, AuditEntry{Action: AuditRoleGrantTable, EntityType: "t_d_table_role", EntityID: grant.CkId.String(), After: grant})
+	return grant, nil
+}
+
+// RevokeTable отзывает доступ роли к таблице
+func (s *RBACService) RevokeTable(roleID string, grantID uuid.UUID, actor AuditActor) error {
+	grant, err := s.repo.DeleteTableRole(roleID, grantID, actor.User)
+	if err != nil {
+		return databaseError("Failed to revoke grant", err)
+	}
+	if grant == nil {
+		return &ServiceError{Code: "NOT_FOUND", Message: "Grant not found"}
+	}
+	s.audit.RecordCommitted(actor, AuditEntry{Action: AuditRoleRevokeTable, EntityType: "t_d_table_role", EntityID: grant.CkId.String(), Before: grant})
+	return nil
+}
+
+// GrantProperty выдаёт роли доступ к типу свойства
+func (s *RBACService) GrantProperty(roleID string, propertyType string, action models.ActionType, actor AuditActor) (*models.TPropertiesRole, error) {
+	if _, err := s.getRole(roleID); err != nil {
+		return nil, err
+	}
+	if !models.IsValidActionType(action) {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: fmt.Sprintf("Unknown action %s", action)}
+	}
+	exists, err := s.repo.PropertyTypeExists(propertyType)
+	if err != nil {
+		return nil, databaseError("Failed to check property type", err)
+	}
+	if !exists {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: fmt.Sprintf("Property type %s not found", propertyType)}
+	}
+	granted, err := s.repo.HasPropertiesRole(roleID, propertyType, action)
+	if err != nil {
+		return nil, databaseError("Failed to check grant", err)
+	}
+	if granted {
+		return nil, &ServiceError{Code: "ALREADY_EXISTS", Message: fmt.Sprintf("Role %s already has %s on %s", roleID, action, propertyType)}
+	}
+
+	grant := &models.TPropertiesRole{
+		CkId:     uuid.New(),
+		CkRole:   roleID,
+		CkType:   propertyType,
+		CrAction: action,
+		BaseModel: models.BaseModel{
+			CkCreate: actor.User,
+			CkModify: actor.User,
+		},
+	}
+	if err := s.repo.CreatePropertiesRole(grant); err != nil {
+		return nil, databaseError("Failed to create grant", err)
+	}
+	s.audit.RecordCommitted(actor, AuditEntry{Action: AuditRoleGrantProperty, EntityType: "t_properties_role", EntityID: grant.CkId.String(), After: grant})
+	return grant, nil
+}
+
+// RevokeProperty отзывает доступ роли к типу свойства
+func (s *RBACService) RevokeProperty(roleID string, grantID uuid.UUID, actor AuditActor) error {
+	grant, err := s.repo.DeletePropertiesRole(roleID, grantID, actor.User)
+	if err != nil {
+		return databaseError("Failed to revoke grant", err)
+	}
+	if grant == nil {
+		return &ServiceError{Code: "NOT_FOUND", Message: "Grant not found"}
+	}
+	s.audit.RecordCommitted(actor, AuditEntry{Action: AuditRoleRevokeProperty, EntityType: "t_properties_role", EntityID: grant.CkId.String(), Before: grant})
+	return nil
+}
+
+// GetUserRoles возвращает назначения ролей пользователя, включая будущие и завершённые
+func (s *RBACService) GetUserRoles(userID uuid.UUID) ([]RoleAssignment, error) {
+	if err := s.checkUser(userID); err != nil {
+		return nil, err
+	}
+	userRoles, err := s.repo.GetUserRoles(userID)
+	if err != nil {
+		return nil, databaseError("Failed to get user roles", err)
+	}
+	now := time.Now()
+	result := make([]RoleAssignment, len(userRoles))
+	for i := range userRoles {
+		result[i] = roleAssignment(&userRoles[i], now)
+	}
+	return result, nil
+}
+
+// AssignRole назначает роль пользователю на период [start, end); без start - с текущего момента
+func (s *RBACService) AssignRole(userID uuid.UUID, roleID string, start *time.Time, end *time.Time, actor AuditActor) (*RoleAssignment, error) {
+	if err := s.checkUser(userID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getRole(roleID); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	userRole := &models.TUserRole{
+		CkId:    uuid.New(),
+		CkUser:  userID,
+		CkRole:  roleID,
+		CtStart: now,
+		CtEnd:   end,
+		BaseModel: models.BaseModel{
+			CkCreate: actor.User,
+			CkModify: actor.User,
+		},
+	}
+	if start != nil {
+		userRole.CtStart = *start
+	}
+	if end != nil && (!end.After(userRole.CtStart) || !end.After(now)) {
+		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "End must be after start and in the future"}
+	}
+	if err := s.repo.CreateUserRole(userRole); err != nil {
+		return nil, databaseError("Failed to assign role", err)
+	}
+	s.audit.RecordCommitted(actor, AuditEntry{Action: AuditUserRoleAssign, EntityType: "t_user_role", EntityID: userRole.CkId.String(), After: userRole})
+	assignment := roleAssignment(userRole, now)
+	return &assignment, nil
+}
+
+// RevokeUserRole завершает назначение роли
+func (s *RBACService) RevokeUserRole(userID uuid.UUID, assignmentID uuid.UUID, actor AuditActor) error {
+	userRole, err := s.repo.EndUserRole(userID, assignmentID, actor.User)
+	if err != nil {
+		return databaseError("Failed to revoke role", err)
+	}
+	if userRole == nil {
+		return &ServiceError{Code: "NOT_FOUND", Message: "Role assignment not found"}
+	}
+	s.audit.RecordCommitted(actor, AuditEntry{
+		Action:     AuditUserRoleRevoke,
+		EntityType: "t_user_role",
+		EntityID:   userRole.CkId.String(),
+		Before:     map[string]any{"ck_user": userRole.CkUser, "ck_role": userRole.CkRole},
+		After:      map[string]any{"ck_user": userRole.CkUser, "ck_role": userRole.CkRole, "ct_end": userRole.CtEnd},
+	})
+	return nil
+}
+
+// ExplainPermissions показывает действующие права пользователя и роли, которые их дают
+func (s *RBACService) ExplainPermissions(userID uuid.UUID) (*EffectivePermissions, error) {
+	if err := s.checkUser(userID); err != nil {
+		return nil, err
+	}
+	userRoles, err := s.repo.GetUserRoles(userID)
+	if err != nil {
+		return nil, databaseError("Failed to get user roles", err)
+	}
+	now := time.Now()
+	var active []string
+	for i := range userRoles {
+		if roleAssignment(&userRoles[i], now).Active {
+			active = append(active, userRoles[i].CkRole)
+		}
+	}
+	tableGrants, err := s.repo.GetTableRolesByRoles(active)
+	if err != nil {
+		return nil, databaseError("Failed to get table grants", err)
+	}
+	propertyGrants, err := s.repo.GetPropertiesRolesByRoles(active)
+	if err != nil {
+		return nil, databaseError("Failed to get property grants", err)
+	}
+	return explainPermissions(userID, userRoles, tableGrants, propertyGrants, now), nil
+}
+
+func (s *RBACService) getRole(id string) (*models.TDRole, error) {
+	role, err := s.repo.GetRoleByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &ServiceError{Code: "NOT_FOUND", Message: fmt.Sprintf("Role %s not found", id)}
+	}
+	if err != nil {
+		return nil, databaseError("Failed to get role", err)
+	}
+	return role, nil
+}
+
+func (s *RBACService) checkUser(id uuid.UUID) error {
+	exists, err := s.repo.UserExists(id)
+	if err != nil {
+		return databaseError("Failed to get user", err)
+	}
+	if !exists {
+		return &ServiceError{Code: "NOT_FOUND", Message: "User not found"}
+	}
+	return nil
+}
+
+func roleAssignment(userRole *models.TUserRole, now time.Time) RoleAssignment {
+	return RoleAssignment{
+		ID:     userRole.CkId,
+		Role:   userRole.CkRole,
+		Start:  userRole.CtStart,
+		End:    userRole.CtEnd,
+		Active: userRole.CtDelete == nil && !userRole.CtStart.After(now) && (userRole.CtEnd == nil || userRole.CtEnd.After(now)),
+	}
+}
+
+// explainPermissions раскрывает ALL на конкретные действия и группирует доступы
+// активных ролей по объекту и действию
+func explainPermissions(userID uuid.UUID, userRoles []models.TUserRole, tableGrants []models.TDTableRole, propertyGrants []models.TPropertiesRole, now time.Time) *EffectivePermissions {
+	result := &EffectivePermissions{
+		UserID:     userID,
+		Roles:      make([]RoleAssignment, len(userRoles)),
+		Tables:     []EffectivePermission{},
+		Properties: []EffectivePermission{},
+	}
+	active := make(map[string]bool)
+	for i := range userRoles {
+		result.Roles[i] = roleAssignment(&userRoles[i], now)
+		if result.Roles[i].Active {
+			active[userRoles[i].CkRole] = true
+		}
+	}
+
+	tables := newPermissionSet()
+	for _, grant := range tableGrants {
+		if active[grant.CkRole] {
+			tables.add(grant.CkTable, PermissionSource{Role: grant.CkRole, GrantID: grant.CkId, Action: grant.CrAction})
+		}
+	}
+	properties := newPermissionSet()
+	for _, grant := range propertyGrants {
+		if active[grant.CkRole] {
+			properties.add(grant.CkType, PermissionSource{Role: grant.CkRole, GrantID: grant.CkId, Action: grant.CrAction})
+		}
+	}
+	result.Tables = tables.list()
+	result.Properties = properties.list()
+	return result
+}
+
+type permissionKey struct {
+	object string
+	action models.ActionType
+}
+
+type permissionSet map[permissionKey][]PermissionSource
+
+func newPermissionSet() permissionSet {
+	return make(permissionSet)
+}
+
+func (p permissionSet) add(object string, source PermissionSource) {
+	actions := []models.ActionType{source.Action}
+	if source.Action == models.ActionTypeAll {
+		actions = concreteActions
+	}
+	for _, action := range actions {
+		key := permissionKey{object: object, action: action}
+		p[key] = append(p[key], source)
+	}
+}
+
+func (p permissionSet) list() []EffectivePermission {
+	order := make(map[models.ActionType]int, len(concreteActions))
+	for i, action := range concreteActions {
+		order[action] = i
+	}
+	result := make([]EffectivePermission, 0, len(p))
+	for key, sources := range p {
+		sort.Slice(sources, func(i, j int) bool { return sources[i].Role < sources[j].Role })
+		result = append(result, EffectivePermission{Object: key.object, Action: key.action, GrantedBy: sources})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Object != result[j].Object {
+			return result[i].Object < result[j].Object
+		}
+		return order[result[i].Action] < order[result[j].Action]
+	})
+	return result
+}
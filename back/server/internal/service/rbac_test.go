@@ -0,0 +1,72 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"parier-server/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestExplainPermissions(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	userRoles := []models.TUserRole{
+		{CkId: uuid.New(), CkRole: "EDITOR", CtStart: past},
+		{CkId: uuid.New(), CkRole: "ADMIN", CtStart: past, CtEnd: &future},
+		{CkId: uuid.New(), CkRole: "EXPIRED", CtStart: past.Add(-time.Hour), CtEnd: &past},
+		{CkId: uuid.New(), CkRole: "UPCOMING", CtStart: future},
+	}
+	tableGrants := []models.TDTableRole{
+		{CkId: uuid.New(), CkRole: "EDITOR", CkTable: "t_localization_word", CrAction: models.ActionTypeView},
+		{CkId: uuid.New(), CkRole: "EDITOR", CkTable: "t_localization_word", CrAction: models.ActionTypeUpdate},
+		{CkId: uuid.New(), CkRole: "ADMIN", CkTable: "t_localization_word", CrAction: models.ActionTypeAll},
+		{CkId: uuid.New(), CkRole: "EXPIRED", CkTable: "t_session", CrAction: models.ActionTypeDelete},
+	}
+	propertyGrants := []models.TPropertiesRole{
+		{CkId: uuid.New(), CkRole: "UPCOMING", CkType: "USER_WALLET", CrAction: models.ActionTypeView},
+	}
+
+	result := explainPermissions(uuid.New(), userRoles, tableGrants, propertyGrants, now)
+
+	active := map[string]bool{}
+	for _, role := range result.Roles {
+		active[role.Role] = role.Active
+	}
+	if !active["EDITOR"] || !active["ADMIN"] || active["EXPIRED"] || active["UPCOMING"] {
+		t.Errorf("unexpected active roles %v", active)
+	}
+
+	if len(result.Tables) != 4 {
+		t.Fatalf("expected ALL to expand into 4 actions on one table, got %+v", result.Tables)
+	}
+	expected := []struct {
+		action models.ActionType
+		roles  []string
+	}{
+		{models.ActionTypeView, []string{"ADMIN", "EDITOR"}},
+		{models.ActionTypeInsert, []string{"ADMIN"}},
+		{models.ActionTypeUpdate, []string{"ADMIN", "EDITOR"}},
+		{models.ActionTypeDelete, []string{"ADMIN"}},
+	}
+	for i, want := range expected {
+		got := result.Tables[i]
+		if got.Object != "t_localization_word" || got.Action != want.action || len(got.GrantedBy) != len(want.roles) {
+			t.Errorf("permission %d: expected %s by %v, got %+v", i, want.action, want.roles, got)
+			continue
+		}
+		for j, role := range want.roles {
+			if got.GrantedBy[j].Role != role {
+				t.Errorf("permission %d: expected %s granted by %v, got %+v", i, want.action, want.roles, got.GrantedBy)
+			}
+		}
+	}
+	if got := result.Tables[1].GrantedBy[0].Action; got != models.ActionTypeAll {
+		t.Errorf("expected the source grant to keep its ALL action, got %s", got)
+	}
+
+	if len(result.Properties) != 0 {
+		t.Errorf("expected grants of inactive roles to be ignored, got %+v", result.Properties)
+	}
+}
@@ -3,19 +3,30 @@ package service
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
+	"math"
+	"parier-server/internal/config"
 	"parier-server/internal/models"
 	"parier-server/internal/repository"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// ReferralService выдаёт реферальные коды, связывает приглашённых пользователей с пригласившими
+// и начисляет пригласившим вознаграждения по правилам из конфигурации
 type ReferralService struct {
-	repo *repository.ReferralRepository
+	repo     *repository.ReferralRepository
+	userRepo *repository.UserRepository
+	audit    *AuditService
+	rules    config.ReferralConfig
+	quit     chan struct{}
 }
 
-func NewReferralService(repo *repository.ReferralRepository) *ReferralService {
-	return &ReferralService{repo: repo}
+func NewReferralService(repo *repository.ReferralRepository, userRepo *repository.UserRepository, audit *AuditService, rules config.ReferralConfig) *ReferralService {
+	return &ReferralService{repo: repo, userRepo: userRepo, audit: audit, rules: rules}
 }
 
 // generateCode creates a unique 8-char alphanumeric code
@@ -39,7 +50,7 @@ func (s *ReferralService) GetOrCreateReferralCode(userID uuid.UUID) (string, err
 		if err != nil {
 			return "", err
 		}
-		_, err = s.repo.GetReferralCodeByCode(code)
+		_, err = s.repo.GetReferralCodeByCode(code, nil)
 		if err != nil {
 			// Code doesn't exist, use it
 			rc := &models.TReferralCode{
@@ -60,10 +71,182 @@ func (s *ReferralService) GetOrCreateReferralCode(userID uuid.UUID) (string, err
 	return "", &ServiceError{Code: "INTERNAL_ERROR", Message: "Failed to generate unique referral code"}
 }
 
+// Attribute связывает зарегистрировавшегося пользователя с владельцем кода и начисляет
+// пригласившему бонус за регистрацию. Неизвестный код, собственный код, уже приглашённый
+// пользователь и встречное приглашение (пользователь сам пригласил владельца кода) пропускаются.
+// Проверку, что аккаунт зарегистрирован по этому приглашению, выполняет вызывающий.
+// В транзакции tx связь фиксируется вместе с ней; без tx используется отдельная транзакция.
+func (s *ReferralService) Attribute(tx *gorm.DB, userID uuid.UUID, code string, actor AuditActor) error {
+	if tx == nil {
+		return s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+			return s.Attribute(tx, userID, code, actor)
+		})
+	}
+	rc, err := s.repo.GetReferralCodeByCode(strings.ToUpper(strings.TrimSpace(code)), tx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return databaseError("Failed to get referral code", err)
+	}
+	if rc.CkUser == userID {
+		return nil
+	}
+	inviter, err := s.repo.GetReferralByReferred(rc.CkUser, tx)
+	if err != nil {
+		return databaseError("Failed to get referral", err)
+	}
+	if inviter != nil && inviter.CkReferrer == userID {
+		return nil
+	}
+	ref := &models.TReferral{
+		CkId:       uuid.New(),
+		CkReferrer: rc.CkUser,
+		CkReferred: userID,
+		CvCode:     rc.CvCode,
+		BaseModel: models.BaseModel{
+			CkCreate: userID.String(),
+			CkModify: userID.String(),
+		},
+	}
+	created, err := s.repo.CreateReferral(ref, tx)
+	if err != nil {
+		return databaseError("Failed to create referral", err)
+	}
+	if !created || s.rules.SignupBonus <= 0 {
+		return nil
+	}
+	return s.accrue(tx, ref, models.ReferralEarningTypeSignup, 1, ref.CkId, nil, roundMoney(s.rules.SignupBonus), actor)
+}
+
+// AccrueCommission начисляет пригласившему процент от ставки или выигрыша приглашённого.
+// source - транзакция или рассчитанная ставка приглашённого, amount - её сумма. Процент умножается на коэффициент
+// уровня программы пригласившего, а его собственному пригласившему начисляется
+// SecondLevelPercent от полученного вознаграждения. Вознаграждение начисляется только
+// в течение CommissionDays дней после регистрации (0 - без ограничения срока).
+// Вызывается в транзакции, в которой проводится сама операция.
+func (s *ReferralService) AccrueCommission(tx *gorm.DB, userID uuid.UUID, kind models.ReferralEarningType, source uuid.UUID, amount float64, actor AuditActor) error {
+	percent := s.commissionPercent(kind)
+	if percent <= 0 || amount <= 0 {
+		return nil
+	}
+	ref, err := s.repo.GetReferralByReferred(userID, tx)
+	if err != nil {
+		return databaseError("Failed to get referral", err)
+	}
+	if ref == nil || !s.inCommissionPeriod(ref, time.Now()) {
+		return nil
+	}
+	multiplier := 1.0
+	if len(s.rules.Tiers) > 0 {
+		active, err := s.repo.CountActiveReferees(tx, ref.CkReferrer, TxTypeBet, time.Now().AddDate(0, 0, -s.rules.ActiveDays))
+		if err != nil {
+			return databaseError("Failed to count active referees", err)
+		}
+		multiplier = s.tierMultiplier(active)
+	}
+	commission := roundMoney(amount * percent / 100 * multiplier)
+	if commission <= 0 {
+		return nil
+	}
+	if err := s.accrue(tx, ref, kind, 1, source, &amount, commission, actor); err != nil {
+		return err
+	}
+	return s.accrueSecondLevel(tx, ref, kind, source, commission, actor)
+}
+
+// accrueSecondLevel начисляет пригласившему пригласившего долю вознаграждения первого уровня
+func (s *ReferralService) accrueSecondLevel(tx *gorm.DB, ref *models.TReferral, kind models.ReferralEarningType, source uuid.UUID, commission float64, actor AuditActor) error {
+	share := roundMoney(commission * s.rules.SecondLevelPercent / 100)
+	if share <= 0 {
+		return nil
+	}
+	parent, err := s.repo.GetReferralByReferred(ref.CkReferrer, tx)
+	if err != nil {
+		return databaseError("Failed to get referral", err)
+	}
+	// при встречном приглашении приглашённый получил бы вознаграждение за собственную ставку
+	if parent == nil || parent.CkReferrer == ref.CkReferred {
+		return nil
+	}
+	return s.accrue(tx, parent, kind, 2, source, &commission, share, actor)
+}
+
+func (s *ReferralService) commissionPercent(kind models.ReferralEarningType) float64 {
+	switch kind {
+	case models.ReferralEarningTypeStake:
+		return s.rules.StakePercent
+	case models.ReferralEarningTypeWinnings:
+		return s.rules.WinningsPercent
+	default:
+		return 0
+	}
+}
+
+// tierMultiplier - коэффициент самого высокого уровня, которого достиг пригласивший
+func (s *ReferralService) tierMultiplier(activeReferees int64) float64 {
+	multiplier, reached := 1.0, 0
+	for _, tier := range s.rules.Tiers {
+		if int64(tier.ActiveReferees) <= activeReferees && tier.ActiveReferees >= reached && tier.Multiplier > 0 {
+			multiplier, reached = tier.Multiplier, tier.ActiveReferees
+		}
+	}
+	return multiplier
+}
+
+func (s *ReferralService) inCommissionPeriod(ref *models.TReferral, at time.Time) bool {
+	if s.rules.CommissionDays <= 0 {
+		return true
+	}
+	return at.Before(ref.CtCreate.AddDate(0, 0, s.rules.CommissionDays))
+}
+
+// accrue записывает вознаграждение по реферальной связи ref. Оно выплачивается через
+// HoldDays дней, если администратор его не заморозит; без срока удержания - сразу.
+// Пока пригласивший заморожен, вознаграждение начисляется замороженным
+func (s *ReferralService) accrue(tx *gorm.DB, ref *models.TReferral, kind models.ReferralEarningType, level int, source uuid.UUID, base *float64, amount float64, actor AuditActor) error {
+	freeze, err := s.repo.GetReferrerFreeze(tx, ref.CkReferrer)
+	if err != nil {
+		return databaseError("Failed to get referrer freeze", err)
+	}
+	earning := &models.TReferralEarning{
+		CkId:       uuid.New(),
+		CkReferral: ref.CkId,
+		CrType:     kind,
+		CrStatus:   models.ReferralEarningStatusPending,
+		CnLevel:    level,
+		CkSource:   &source,
+		CnBase:     base,
+		CnAmount:   amount,
+		BaseModel: models.BaseModel{
+			CkCreate: auditActorSystem,
+			CkModify: auditActorSystem,
+		},
+	}
+	if freeze != nil {
+		earning.CrStatus = models.ReferralEarningStatusFrozen
+		earning.CvFreezeReason = &freeze.CvReason
+	}
+	if err := s.repo.CreateReferralEarning(earning, tx); err != nil {
+		return databaseError("Failed to create referral earning", err)
+	}
+	if freeze != nil || s.rules.HoldDays > 0 {
+		return nil
+	}
+	earning.Referral = ref
+	return s.payEarning(tx, earning, actor)
+}
+
+// roundMoney округляет сумму до копеек
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
 // ReferralStatsResponse for API
 type ReferralStatsResponse struct {
 	TotalReferrals int                    `json:"total_referrals"`
 	TotalEarnings  float64                `json:"total_earnings"`
+	Pending        float64                `json:"pending_earnings"` // начислено и ждёт выплаты; замороженные не входят
 	Referrals      []ReferralItemResponse `json:"referrals"`
 }
 
@@ -90,7 +273,12 @@ func (s *ReferralService) GetReferralStats(userID uuid.UUID) (*ReferralStatsResp
 		earnings, _ := s.repo.GetReferralEarningsByReferralID(ref.CkId)
 		var total float64
 		for _, e := range earnings {
-			total += e.CnAmount
+			switch e.CrStatus {
+			case models.ReferralEarningStatusPaid:
+				total += e.CnAmount
+			case models.ReferralEarningStatusPending:
+				res.Pending += e.CnAmount
+			}
 		}
 		res.TotalEarnings += total
 		referredName := ""
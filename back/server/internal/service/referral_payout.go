@@ -0,0 +1,136 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"parier-server/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// referralPayoutBatch - сколько вознаграждений выбирается за один запрос задачи выплат
+const referralPayoutBatch = 100
+
+// Start запускает периодическую выплату вознаграждений, срок удержания которых истёк
+func (s *ReferralService) Start() {
+	if s.rules.HoldDays <= 0 || s.rules.PayoutInterval <= 0 || s.quit != nil {
+		return
+	}
+	s.quit = make(chan struct{})
+	ticker := time.NewTicker(s.rules.PayoutInterval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if paid, err := s.PayDueEarnings(); err != nil {
+					log.Printf("Referral payout failed after %d earnings: %v", paid, err)
+				}
+			case <-s.quit:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (s *ReferralService) Stop() {
+	if s.quit != nil {
+		close(s.quit)
+		s.quit = nil
+	}
+}
+
+// PayDueEarnings выплачивает ожидающие вознаграждения старше HoldDays дней.
+// Каждое вознаграждение выплачивается в своей транзакции; вознаграждения, которые
+// выплачивает другой экземпляр сервера, пропускаются
+func (s *ReferralService) PayDueEarnings() (int, error) {
+	before := time.Now().AddDate(0, 0, -s.rules.HoldDays)
+	paid := 0
+	for {
+		earnings, err := s.repo.GetDueEarnings(before, referralPayoutBatch)
+		if err != nil {
+			return paid, databaseError("Failed to get due referral earnings", err)
+		}
+		batchPaid := 0
+		for _, due := range earnings {
+			ok, err := s.payDueEarning(due.CkId)
+			if err != nil {
+				return paid, err
+			}
+			if ok {
+				batchPaid++
+			}
+		}
+		paid += batchPaid
+		if len(earnings) < referralPayoutBatch || batchPaid == 0 {
+			return paid, nil
+		}
+	}
+}
+
+func (s *ReferralService) payDueEarning(id uuid.UUID) (bool, error) {
+	ok := false
+	err := s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		earning, err := s.repo.LockPendingEarning(tx, id)
+		if err != nil {
+			return databaseError("Failed to lock referral earning", err)
+		}
+		if earning == nil {
+			return nil
+		}
+		// пригласившего заморозили после начисления: его вознаграждения не выплачиваются
+		freeze, err := s.repo.GetReferrerFreeze(tx, earning.Referral.CkReferrer)
+		if err != nil {
+			return databaseError("Failed to get referrer freeze", err)
+		}
+		if freeze != nil {
+			_, err := s.repo.SetReferrerEarningsStatus(tx, earning.Referral.CkReferrer, models.ReferralEarningStatusPending, models.ReferralEarningStatusFrozen, &freeze.CvReason, auditActorSystem)
+			if err != nil {
+				return databaseError("Failed to freeze referral earnings", err)
+			}
+			return nil
+		}
+		if err := s.payEarning(tx, earning, SystemActor); err != nil {
+			return err
+		}
+		ok = true
+		return nil
+	})
+	return ok && err == nil, err
+}
+
+// payEarning зачисляет вознаграждение в кошелёк пригласившего транзакцией REFERRAL
+// и отмечает его выплаченным. earning.Referral должен быть загружен
+func (s *ReferralService) payEarning(tx *gorm.DB, earning *models.TReferralEarning, actor AuditActor) error {
+	referrerID := earning.Referral.CkReferrer
+	wallet, err := s.userRepo.LockUserWallet(tx, referrerID, auditActorSystem)
+	if err != nil {
+		return databaseError("Failed to get referrer wallet", err)
+	}
+	balanceBefore := wallet.CnValue
+	wallet.CnValue += earning.CnAmount
+	wallet.CkModify = auditActorSystem
+	if err := tx.Save(wallet).Error; err != nil {
+		return databaseError("Failed to update referrer wallet", err)
+	}
+	tr := &models.TUserTransaction{
+		CkId:     uuid.New(),
+		CkUser:   referrerID,
+		CkType:   TxTypeReferral,
+		CkStatus: TxStatusCompleted,
+		CnAmount: earning.CnAmount,
+		BaseModel: models.BaseModel{
+			CkCreate: auditActorSystem,
+			CkModify: auditActorSystem,
+		},
+	}
+	if err := s.userRepo.CreateUserTransaction(tr, tx); err != nil {
+		return databaseError("Failed to create referral transaction", err)
+	}
+	if err := s.repo.MarkEarningPaid(tx, earning, tr.CkId, auditActorSystem); err != nil {
+		return databaseError("Failed to mark referral earning paid", err)
+	}
+	return s.audit.Record(tx, actor, walletAuditEntry(AuditReferralCommission, wallet, balanceBefore, tr))
+}
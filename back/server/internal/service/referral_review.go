@@ -0,0 +1,187 @@
+package service
+
+import (
+	"time"
+
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Признаки возможного мошенничества с реферальной программой
+const (
+	ReferralFlagSharedIP        = "SHARED_IP"         // сессии пригласившего и приглашённого с одного IP
+	ReferralFlagSharedUserAgent = "SHARED_USER_AGENT" // сессии пригласившего и приглашённого с одного браузера
+	ReferralFlagCircular        = "CIRCULAR"          // цепочка приглашений замыкается на пользователе
+	ReferralFlagNoStakes        = "NO_STAKES"         // приглашённый не сделал ни одной ставки
+)
+
+// referralCycleDepth - на сколько уровней вверх ищется замкнутая цепочка приглашений
+const referralCycleDepth = 10
+
+// RefereeReview - приглашённый пользователь и найденные у него признаки мошенничества
+type RefereeReview struct {
+	ReferralID uuid.UUID `json:"referral_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Stakes     int64     `json:"stakes"`
+	Flags      []string  `json:"flags"`
+}
+
+// ReferrerReview - пригласивший с невыплаченными вознаграждениями. Flags объединяет
+// признаки всех его приглашённых и его собственные
+type ReferrerReview struct {
+	UserID   uuid.UUID       `json:"user_id"`
+	Pending  float64         `json:"pending"`
+	Frozen   float64         `json:"frozen"`
+	Paid     float64         `json:"paid"`
+	Flags    []string        `json:"flags"`
+	Referees []RefereeReview `json:"referees"`
+}
+
+// Review возвращает пригласивших с ожидающими или замороженными вознаграждениями,
+// начиная с самых крупных, вместе с признаками мошенничества по их приглашённым
+func (s *ReferralService) Review(offset, limit *int) ([]ReferrerReview, int64, error) {
+	referrers, total, err := s.repo.GetUnpaidReferrers(offset, limit)
+	if err != nil {
+		return nil, 0, databaseError("Failed to get referrers", err)
+	}
+	if len(referrers) == 0 {
+		return []ReferrerReview{}, total, nil
+	}
+	referrerIDs := make([]uuid.UUID, len(referrers))
+	for i, referrer := range referrers {
+		referrerIDs[i] = referrer.CkReferrer
+	}
+	referees, err := s.repo.GetRefereeActivity(referrerIDs, TxTypeBet)
+	if err != nil {
+		return nil, 0, databaseError("Failed to get referees", err)
+	}
+	userIDs := append([]uuid.UUID{}, referrerIDs...)
+	for _, referee := range referees {
+		userIDs = append(userIDs, referee.CkReferred)
+	}
+	cycled, err := s.repo.GetReferralCycles(userIDs, referralCycleDepth)
+	if err != nil {
+		return nil, 0, databaseError("Failed to find referral cycles", err)
+	}
+	inCycle := make(map[uuid.UUID]bool, len(cycled))
+	for _, id := range cycled {
+		inCycle[id] = true
+	}
+
+	refereesByReferrer := make(map[uuid.UUID][]RefereeReview, len(referrers))
+	for _, referee := range referees {
+		refereesByReferrer[referee.CkReferrer] = append(refereesByReferrer[referee.CkReferrer], RefereeReview{
+			ReferralID: referee.CkReferral,
+			UserID:     referee.CkReferred,
+			CreatedAt:  referee.CtCreate,
+			Stakes:     referee.CnStakes,
+			Flags:      refereeFlags(referee, inCycle[referee.CkReferred]),
+		})
+	}
+	result := make([]ReferrerReview, len(referrers))
+	for i, referrer := range referrers {
+		review := ReferrerReview{
+			UserID:   referrer.CkReferrer,
+			Pending:  referrer.CnPending,
+			Frozen:   referrer.CnFrozen,
+			Paid:     referrer.CnPaid,
+			Referees: refereesByReferrer[referrer.CkReferrer],
+		}
+		if review.Referees == nil {
+			review.Referees = []RefereeReview{}
+		}
+		review.Flags = referrerFlags(review.Referees, inCycle[referrer.CkReferrer])
+		result[i] = review
+	}
+	return result, total, nil
+}
+
+// Freeze замораживает пригласившего: ожидающие выплаты вознаграждения замораживаются,
+// а новые до снятия заморозки начисляются замороженными. Возвращает число замороженных вознаграждений
+func (s *ReferralService) Freeze(referrerID uuid.UUID, reason string, actor AuditActor) (int64, error) {
+	freeze := func(tx *gorm.DB) error {
+		if err := s.repo.FreezeReferrer(tx, referrerID, reason, actor.User); err != nil {
+			return databaseError("Failed to freeze referrer", err)
+		}
+		return nil
+	}
+	return s.setEarningsStatus(referrerID, freeze, models.ReferralEarningStatusPending, models.ReferralEarningStatusFrozen, &reason, AuditReferralFreeze, actor)
+}
+
+// Unfreeze снимает заморозку пригласившего и возвращает его замороженные вознаграждения в очередь выплаты
+func (s *ReferralService) Unfreeze(referrerID uuid.UUID, actor AuditActor) (int64, error) {
+	unfreeze := func(tx *gorm.DB) error {
+		if err := s.repo.UnfreezeReferrer(tx, referrerID, actor.User); err != nil {
+			return databaseError("Failed to unfreeze referrer", err)
+		}
+		return nil
+	}
+	return s.setEarningsStatus(referrerID, unfreeze, models.ReferralEarningStatusFrozen, models.ReferralEarningStatusPending, nil, AuditReferralUnfreeze, actor)
+}
+
+// setEarningsStatus в одной транзакции меняет заморозку пригласившего (mark) и статус его вознаграждений
+func (s *ReferralService) setEarningsStatus(referrerID uuid.UUID, mark func(tx *gorm.DB) error, from, to models.ReferralEarningStatus, reason *string, action string, actor AuditActor) (int64, error) {
+	var changed int64
+	err := s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := mark(tx); err != nil {
+			return err
+		}
+		var err error
+		changed, err = s.repo.SetReferrerEarningsStatus(tx, referrerID, from, to, reason, actor.User)
+		if err != nil {
+			return databaseError("Failed to update referral earnings", err)
+		}
+		return s.audit.Record(tx, actor, AuditEntry{
+			Action:     action,
+			EntityType: "t_referral_earning",
+			EntityID:   referrerID.String(),
+			Before:     map[string]any{"cr_status": from},
+			After:      map[string]any{"cr_status": to, "cv_freeze_reason": reason, "count": changed},
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+func refereeFlags(referee repository.RefereeActivity, inCycle bool) []string {
+	flags := []string{}
+	if referee.ClSharedIp {
+		flags = append(flags, ReferralFlagSharedIP)
+	}
+	if referee.ClSharedUserAgent {
+		flags = append(flags, ReferralFlagSharedUserAgent)
+	}
+	if inCycle {
+		flags = append(flags, ReferralFlagCircular)
+	}
+	if referee.CnStakes == 0 {
+		flags = append(flags, ReferralFlagNoStakes)
+	}
+	return flags
+}
+
+// referrerFlags объединяет признаки приглашённых в порядке их важности
+func referrerFlags(referees []RefereeReview, inCycle bool) []string {
+	found := make(map[string]bool)
+	if inCycle {
+		found[ReferralFlagCircular] = true
+	}
+	for _, referee := range referees {
+		for _, flag := range referee.Flags {
+			found[flag] = true
+		}
+	}
+	flags := []string{}
+	for _, flag := range []string{ReferralFlagSharedIP, ReferralFlagSharedUserAgent, ReferralFlagCircular, ReferralFlagNoStakes} {
+		if found[flag] {
+			flags = append(flags, flag)
+		}
+	}
+	return flags
+}
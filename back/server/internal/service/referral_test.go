@@ -0,0 +1,164 @@
+package service
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"parier-server/internal/config"
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+	"parier-server/internal/util/dbtest"
+
+	"github.com/google/uuid"
+)
+
+func TestReferralCommissionRules(t *testing.T) {
+	s := &ReferralService{rules: config.ReferralConfig{StakePercent: 2.5, WinningsPercent: 1, CommissionDays: 30}}
+	tests := []struct {
+		kind   models.ReferralEarningType
+		amount float64
+		want   float64
+	}{
+		{models.ReferralEarningTypeStake, 100, 2.5},
+		{models.ReferralEarningTypeStake, 0.33, 0.01},
+		{models.ReferralEarningTypeWinnings, 1234.56, 12.35},
+		{models.ReferralEarningTypeSignup, 100, 0},
+	}
+	for _, tt := range tests {
+		if got := roundMoney(tt.amount * s.commissionPercent(tt.kind) / 100); got != tt.want {
+			t.Errorf("%s of %v: expected %v, got %v", tt.kind, tt.amount, tt.want, got)
+		}
+	}
+
+	registered := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ref := &models.TReferral{BaseModel: models.BaseModel{CtCreate: registered}}
+	if !s.inCommissionPeriod(ref, registered.AddDate(0, 0, 29)) {
+		t.Error("commission must be paid within the period")
+	}
+	if s.inCommissionPeriod(ref, registered.AddDate(0, 0, 30)) {
+		t.Error("commission must not be paid after the period")
+	}
+	s.rules.CommissionDays = 0
+	if !s.inCommissionPeriod(ref, registered.AddDate(10, 0, 0)) {
+		t.Error("zero days must not limit the period")
+	}
+}
+
+func TestReferralTierMultiplier(t *testing.T) {
+	s := &ReferralService{rules: config.ReferralConfig{Tiers: []config.ReferralTier{
+		{ActiveReferees: 20, Multiplier: 2},
+		{ActiveReferees: 5, Multiplier: 1.5},
+	}}}
+	for active, want := range map[int64]float64{0: 1, 4: 1, 5: 1.5, 19: 1.5, 20: 2, 100: 2} {
+		if got := s.tierMultiplier(active); got != want {
+			t.Errorf("%d active referees: expected %v, got %v", active, want, got)
+		}
+	}
+}
+
+func TestReferralFlags(t *testing.T) {
+	clean := repository.RefereeActivity{CnStakes: 3}
+	if flags := refereeFlags(clean, false); len(flags) != 0 {
+		t.Errorf("active referee without shared sessions must not be flagged, got %v", flags)
+	}
+	suspicious := repository.RefereeActivity{ClSharedIp: true, ClSharedUserAgent: true}
+	flags := refereeFlags(suspicious, true)
+	want := []string{ReferralFlagSharedIP, ReferralFlagSharedUserAgent, ReferralFlagCircular, ReferralFlagNoStakes}
+	if strings.Join(flags, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, flags)
+	}
+
+	referees := []RefereeReview{
+		{Flags: []string{ReferralFlagNoStakes}},
+		{Flags: []string{ReferralFlagSharedIP, ReferralFlagNoStakes}},
+	}
+	if got := strings.Join(referrerFlags(referees, true), ","); got != "SHARED_IP,CIRCULAR,NO_STAKES" {
+		t.Errorf("unexpected referrer flags %s", got)
+	}
+}
+
+// freezeDB отвечает на запросы начисления и выплаты вознаграждения пригласившему referrer
+// и запоминает статусы, с которыми вознаграждения записываются
+type freezeDB struct {
+	referrer uuid.UUID
+	frozen   bool
+	statuses []string
+}
+
+func (d *freezeDB) handle(q dbtest.Query) dbtest.Result {
+	switch {
+	case q.Has(`FROM "t_referral_freeze"`):
+		if !d.frozen {
+			return dbtest.Result{}
+		}
+		return dbtest.Result{
+			Columns: []string{"ck_id", "ck_user", "cv_reason"},
+			Rows:    [][]driver.Value{{uuid.NewString(), d.referrer.String(), "fraud"}},
+		}
+	case q.Has(`FROM "t_referral_earning"`, "FOR UPDATE"):
+		return dbtest.Result{
+			Columns: []string{"ck_id", "ck_referral", "cr_type", "cr_status", "cn_amount"},
+			Rows:    [][]driver.Value{{q.Args[0], uuid.NewString(), "STAKE", "PENDING", 5.0}},
+		}
+	case q.Has(`FROM "t_referral"`):
+		return dbtest.Result{
+			Columns: []string{"ck_id", "ck_referrer", "ck_referred"},
+			Rows:    [][]driver.Value{{q.Args[0], d.referrer.String(), uuid.NewString()}},
+		}
+	case q.Has(`INSERT INTO "t_referral_earning"`), q.Has(`UPDATE "t_referral_earning"`):
+		status, _ := q.Value("cr_status")
+		d.statuses = append(d.statuses, status.(string))
+		return dbtest.Result{Affected: 1}
+	}
+	return dbtest.Result{}
+}
+
+func TestReferralFreeze(t *testing.T) {
+	for _, frozen := range []bool{false, true} {
+		state := &freezeDB{referrer: uuid.New(), frozen: frozen}
+		db, fake := dbtest.Open(t, state.handle)
+		s := NewReferralService(repository.NewReferralRepository(db), repository.NewUserRepository(db), NewAuditService(repository.NewAuditRepository(db)), config.ReferralConfig{})
+		ref := &models.TReferral{CkId: uuid.New(), CkReferrer: state.referrer, CkReferred: uuid.New()}
+
+		if err := s.accrue(db, ref, models.ReferralEarningTypeStake, 1, uuid.New(), nil, 5, SystemActor); err != nil {
+			t.Fatalf("frozen %v: unexpected accrue error: %v", frozen, err)
+		}
+		paid, err := s.payDueEarning(uuid.New())
+		if err != nil {
+			t.Fatalf("frozen %v: unexpected payout error: %v", frozen, err)
+		}
+
+		want := []string{"PENDING", "PAID", "PAID"}
+		if frozen {
+			// новое вознаграждение начисляется замороженным, ожидающее замораживается вместо выплаты
+			want = []string{"FROZEN", "FROZEN"}
+		}
+		if strings.Join(state.statuses, ",") != strings.Join(want, ",") {
+			t.Errorf("frozen %v: expected statuses %v, got %v", frozen, want, state.statuses)
+		}
+		if paid == frozen {
+			t.Errorf("frozen %v: unexpected payout result %v", frozen, paid)
+		}
+		if wallets := fake.Count(`FROM "t_user_wallet"`); (wallets > 0) == frozen {
+			t.Errorf("frozen %v: unexpected %d wallet queries", frozen, wallets)
+		}
+	}
+
+	state := &freezeDB{referrer: uuid.New()}
+	db, fake := dbtest.Open(t, state.handle)
+	s := NewReferralService(repository.NewReferralRepository(db), nil, NewAuditService(repository.NewAuditRepository(db)), config.ReferralConfig{})
+	if _, err := s.Freeze(state.referrer, "fraud", AuditActor{User: "admin"}); err != nil {
+		t.Fatalf("unexpected freeze error: %v", err)
+	}
+	if count := fake.Count(`INSERT INTO "t_referral_freeze"`, "ON CONFLICT"); count != 1 {
+		t.Errorf("expected the referrer freeze to be stored, got %d inserts", count)
+	}
+	if _, err := s.Unfreeze(state.referrer, AuditActor{User: "admin"}); err != nil {
+		t.Fatalf("unexpected unfreeze error: %v", err)
+	}
+	if count := fake.Count(`UPDATE "t_referral_freeze"`, "ct_delete"); count != 1 {
+		t.Errorf("expected the referrer freeze to be lifted, got %d updates", count)
+	}
+}
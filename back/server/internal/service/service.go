@@ -2,6 +2,8 @@ package service
 
 import (
 	"parier-server/internal/config"
+	"parier-server/internal/module/ai"
+	"parier-server/internal/module/storage"
 	"parier-server/internal/repository"
 
 	"gorm.io/gorm"
@@ -17,6 +19,15 @@ type Services struct {
 	Admin        *AdminService
 	Wallet       *WalletService
 	Referral     *ReferralService
+	Translate    *TranslateService
+	Activity     *ActivityService
+	APIToken     *APITokenService
+	RBAC         *RBACService
+	Permissions  *PermissionEvaluator
+	Audit        *AuditService
+	Notification *NotificationService
+	Events       *EventHub
+	Chat         *ChatService
 }
 
 // NewServices creates a new Services instance with all dependencies
@@ -28,16 +39,57 @@ func NewServices(db *gorm.DB, cfg *config.Config) (*Services, error) {
 	coreRepo := repository.NewCoreRepository(db)
 	parierRepo := repository.NewParierRepository(db)
 	referralRepo := repository.NewReferralRepository(db)
+	activityRepo := repository.NewActivityRepository(db)
+	apiTokenRepo := repository.NewAPITokenRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	resolver := repository.NewLocalizationResolver(locRepo, cfg.Cache.LocalizationTTL)
 	// Initialize services
-	localizationService := NewLocalizationService(locRepo)
-	keycloakService := NewKeycloakService(cfg, &cfg.Keycloak, userRepo, locRepo)
-	coreService := NewCoreService(coreRepo, locRepo)
-	parierService := NewParierService(parierRepo, locRepo, userRepo)
-	adminService := NewAdminService(userRepo, db)
-	WalletService := NewWalletService(userRepo, db)
-	ReferralService := NewReferralService(referralRepo)
+	auditService := NewAuditService(auditRepo)
+	localizationService := NewLocalizationService(locRepo, auditService)
+	var eventHub *EventHub
+	if cfg.Events.Enabled {
+		eventHub = NewEventHub(repository.NewEventNotifier(db, cfg.Database.GetDSN()), cfg.Events)
+	}
+	notificationService := NewNotificationService(notificationRepo, resolver, eventHub, cfg.Notify)
+	var sessionNotifier *repository.SessionNotifier
+	if cfg.Store.SessionNotify {
+		sessionNotifier = repository.NewSessionNotifier(db, cfg.Database.GetDSN())
+	}
+	sessionBackend := NewCachedSessionBackend(userRepo, sessionNotifier)
+	keycloakService := NewKeycloakService(cfg, &cfg.Keycloak, sessionBackend, userRepo, locRepo)
+	coreService := NewCoreService(coreRepo, locRepo, resolver)
+	adminService := NewAdminService(userRepo, db, auditService, notificationService, eventHub)
+	WalletService := NewWalletService(userRepo, db, auditService, notificationService, eventHub)
+	ReferralService := NewReferralService(referralRepo, userRepo, auditService, cfg.Referral)
+	parierService := NewParierService(parierRepo, locRepo, resolver, userRepo, ReferralService, auditService, notificationService, eventHub, cfg.Comments)
+	chatService := NewChatService(parierRepo, userRepo, parierService, auditService, eventHub)
+	activityService := NewActivityService(activityRepo, userRepo, referralRepo, ReferralService, locRepo, cfg.Store.AnonymousDuration)
+	apiTokenService := NewAPITokenService(apiTokenRepo, userRepo, auditService)
+	rbacService := NewRBACService(userRepo, locRepo, auditService)
+	permissionEvaluator := NewPermissionEvaluator(userRepo, cfg.Cache.PermissionTTL)
+	userRepo.SetPermissionInvalidator(permissionEvaluator)
+	var aiModule ai.AIModuleInterface
+	if cfg.Translate.Enabled {
+		module, err := ai.NewAIModuleFromConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		aiModule = module
+	}
+	translateService := NewTranslateService(locRepo, aiModule, cfg.Translate, auditService)
+	translateService.Start()
+	ReferralService.Start()
+	notificationService.Start()
+	if eventHub != nil {
+		eventHub.Start()
+	}
 	// Initialize MediaService
-	mediaService, err := NewMediaService(mediaRepo, locRepo, &cfg.S3, cfg)
+	blobStore, err := storage.NewBlobStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mediaService, err := NewMediaService(mediaRepo, locRepo, resolver, blobStore, cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -47,9 +99,18 @@ func NewServices(db *gorm.DB, cfg *config.Config) (*Services, error) {
 		Keycloak:     keycloakService,
 		Core:         coreService,
 		Parier:       parierService,
+		Chat:         chatService,
 		Admin:        adminService,
 		Wallet:       WalletService,
 		Referral:     ReferralService,
+		Translate:    translateService,
+		Activity:     activityService,
+		APIToken:     apiTokenService,
+		RBAC:         rbacService,
+		Permissions:  permissionEvaluator,
+		Audit:        auditService,
+		Notification: notificationService,
+		Events:       eventHub,
 	}, nil
 }
 
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// SessionBackend хранит сессии пользователей.
+// Реализация должна сбрасывать закешированную сессию при изменении или удалении,
+// в том числе на других инстансах API.
+type SessionBackend interface {
+	Create(session *models.TSession) (*models.TSession, error)
+	Get(id uuid.UUID) (*models.TSession, error)
+	Update(session *models.TSession) error
+	Delete(id uuid.UUID) error
+	// ListByUser возвращает действующие сессии пользователя, минуя кеш
+	ListByUser(userID uuid.UUID) ([]models.TSession, error)
+	// Touch отмечает активность сессии; запись в БД не чаще sessionActivityInterval
+	Touch(session *models.TSession) error
+}
+
+// sessionActivityInterval - точность времени последней активности сессии
+const sessionActivityInterval = time.Minute
+
+type Session struct {
+	AccessTimestamp time.Time
+	Session         *models.TSession
+}
+
+type SessionStore struct {
+	sync.RWMutex
+	sessions map[uuid.UUID]*Session
+}
+
+func (s *SessionStore) Get(id uuid.UUID) (*models.TSession, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	session, ok := s.sessions[id]
+	if ok && session.Session.CtExpire.After(time.Now()) {
+		session.AccessTimestamp = time.Now()
+		return session.Session, true
+	}
+	return nil, false
+}
+
+func (s *SessionStore) Set(id uuid.UUID, session *models.TSession) {
+	s.Lock()
+	defer s.Unlock()
+	s.sessions[id] = &Session{
+		AccessTimestamp: time.Now(),
+		Session:         session,
+	}
+}
+
+func (s *SessionStore) Delete(id uuid.UUID) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.sessions, id)
+}
+
+// Clear удаляет все сессии из кеша
+func (s *SessionStore) Clear() {
+	s.Lock()
+	defer s.Unlock()
+	s.sessions = make(map[uuid.UUID]*Session)
+}
+
+func (s *SessionStore) Cleanup() {
+	s.RLock()
+	ids := make([]uuid.UUID, 0)
+	for id, session := range s.sessions {
+		if session.Session.CtExpire.Before(time.Now()) {
+			ids = append(ids, id)
+		}
+		if session.AccessTimestamp.Before(time.Now().Add(-30 * time.Minute)) {
+			ids = append(ids, id)
+		}
+	}
+	s.RUnlock()
+	for _, id := range ids {
+		s.Delete(id)
+	}
+}
+
+// CachedSessionBackend хранит сессии в Postgres и кеширует их в памяти процесса.
+// Если задан notifier, изменения рассылаются другим инстансам через LISTEN/NOTIFY.
+type CachedSessionBackend struct {
+	repo     *repository.UserRepository
+	notifier *repository.SessionNotifier
+	store    SessionStore
+	touched  sync.Map // ID сессии -> время последней записи активности
+	quit     context.CancelFunc
+}
+
+// NewCachedSessionBackend создаёт хранилище; notifier может быть nil, если API работает в одном экземпляре
+func NewCachedSessionBackend(repo *repository.UserRepository, notifier *repository.SessionNotifier) *CachedSessionBackend {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &CachedSessionBackend{
+		repo:     repo,
+		notifier: notifier,
+		store: SessionStore{
+			sessions: make(map[uuid.UUID]*Session),
+		},
+		quit: cancel,
+	}
+	ticker := time.NewTicker(30 * time.Minute)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				b.store.Cleanup()
+				b.forgetTouched()
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	if notifier != nil {
+		go notifier.Listen(ctx, b.invalidate)
+	}
+	return b
+}
+
+// Stop останавливает очистку кеша и прослушивание оповещений
+func (b *CachedSessionBackend) Stop() {
+	b.quit()
+}
+
+func (b *CachedSessionBackend) Create(session *models.TSession) (*models.TSession, error) {
+	if err := b.repo.CreateSession(session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return b.Get(session.CkId)
+}
+
+func (b *CachedSessionBackend) Get(id uuid.UUID) (*models.TSession, error) {
+	session, ok := b.store.Get(id)
+	if ok {
+		return session, nil
+	}
+	session, err := b.repo.GetSessionByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	// часы сервера и БД могут расходиться: истёкшую по часам сервера сессию не кешируем
+	if !session.CtExpire.After(time.Now()) {
+		return nil, fmt.Errorf("failed to get session: session %s expired", id)
+	}
+	b.store.Set(id, session)
+	return session, nil
+}
+
+func (b *CachedSessionBackend) Update(session *models.TSession) error {
+	if err := b.repo.UpdateSession(session); err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	b.store.Set(session.CkId, session)
+	b.publish(session.CkId)
+	return nil
+}
+
+func (b *CachedSessionBackend) Delete(id uuid.UUID) error {
+	if err := b.repo.DeleteSession(id); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	b.store.Delete(id)
+	b.touched.Delete(id)
+	b.publish(id)
+	return nil
+}
+
+func (b *CachedSessionBackend) ListByUser(userID uuid.UUID) ([]models.TSession, error) {
+	return b.repo.GetActiveSessionsByUserID(userID)
+}
+
+// Touch не меняет сессию: её из кеша одновременно читают другие запросы
+func (b *CachedSessionBackend) Touch(session *models.TSession) error {
+	now := time.Now()
+	last := session.CtActivity
+	if at, ok := b.touched.Load(session.CkId); ok {
+		touched := at.(time.Time)
+		last = &touched
+	}
+	if last != nil && now.Sub(*last) < sessionActivityInterval {
+		return nil
+	}
+	b.touched.Store(session.CkId, now)
+	if err := b.repo.TouchSession(session.CkId, now); err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+	return nil
+}
+
+// forgetTouched забывает отметки активности, после которых прошло больше sessionActivityInterval
+func (b *CachedSessionBackend) forgetTouched() {
+	b.touched.Range(func(id, at any) bool {
+		if time.Since(at.(time.Time)) >= sessionActivityInterval {
+			b.touched.Delete(id)
+		}
+		return true
+	})
+}
+
+func (b *CachedSessionBackend) publish(id uuid.UUID) {
+	if b.notifier == nil {
+		return
+	}
+	if err := b.notifier.Publish(id); err != nil {
+		log.Printf("Failed to notify about session %s: %v", id, err)
+	}
+}
+
+// invalidate сбрасывает сессию, изменённую другим инстансом; uuid.Nil сбрасывает весь кеш
+func (b *CachedSessionBackend) invalidate(id uuid.UUID) {
+	if id == uuid.Nil {
+		b.store.Clear()
+		return
+	}
+	b.store.Delete(id)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"database/sql/driver"
+	"parier-server/internal/models"
+	"parier-server/internal/repository"
+	"parier-server/internal/util/dbtest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCachedSessionBackendInvalidate(t *testing.T) {
+	b := NewCachedSessionBackend(nil, nil)
+	defer b.Stop()
+	first, second := uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{first, second} {
+		b.store.Set(id, &models.TSession{CkId: id, CtExpire: time.Now().Add(time.Hour)})
+	}
+
+	b.invalidate(first)
+	if _, ok := b.store.Get(first); ok {
+		t.Error("invalidated session is still cached")
+	}
+	if _, ok := b.store.Get(second); !ok {
+		t.Error("other session was evicted")
+	}
+
+	// uuid.Nil comes after the listener reconnects and drops everything
+	b.invalidate(uuid.Nil)
+	if _, ok := b.store.Get(second); ok {
+		t.Error("cache was not cleared")
+	}
+}
+
+func TestCachedSessionBackendGetExpired(t *testing.T) {
+	id := uuid.New()
+	expire := time.Now().Add(-time.Minute)
+	db, fake := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		if q.Has(`FROM "t_session"`) {
+			return dbtest.Result{
+				Columns: []string{"ck_id", "cv_data", "ct_expire"},
+				Rows:    [][]driver.Value{{id.String(), "{}", expire}},
+			}
+		}
+		return dbtest.Result{}
+	})
+	b := NewCachedSessionBackend(repository.NewUserRepository(db), nil)
+	defer b.Stop()
+
+	if _, err := b.Get(id); err == nil {
+		t.Fatal("expired session was returned")
+	}
+	if _, ok := b.store.Get(id); ok {
+		t.Error("expired session was cached")
+	}
+	if count := fake.Count(`FROM "t_session"`, "ct_expire > NOW()"); count != 1 {
+		t.Errorf("expected the query to skip expired sessions, got %d matching queries", count)
+	}
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"parier-server/internal/config"
+	"parier-server/internal/models"
+	"parier-server/internal/module/ai"
+	"parier-server/internal/repository"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MachineTranslationUser is recorded as creator of machine translations
+const MachineTranslationUser = "machine-translation"
+
+// TranslateService fills in missing translations through the AI module.
+// Results are stored with cl_machine set and stay pending until a person reviews them.
+type TranslateService struct {
+	repo   *repository.LocalizationRepository
+	ai     ai.AIModuleInterface
+	config config.TranslateConfig
+	audit  *AuditService
+
+	mu      sync.Mutex
+	running bool
+	failed  map[string]time.Time
+	quit    chan struct{}
+}
+
+type TranslateRunResult struct {
+	Translated int `json:"translated"`
+	Failed     int `json:"failed"`
+	Skipped    int `json:"skipped"` // translated by a person while the AI call was running
+}
+
+type PendingReviewResponse struct {
+	Key          string            `json:"key"`
+	Lang         string            `json:"lang"`
+	Text         string            `json:"text"`
+	Translations map[string]string `json:"translations"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+type ApproveTranslationsRequest struct {
+	Lang string   `json:"lang" binding:"required"`
+	Keys []string `json:"keys" binding:"required,min=1"`
+}
+
+// NewTranslateService creates the service; aiModule may be nil when machine translation is not configured
+func NewTranslateService(repo *repository.LocalizationRepository, aiModule ai.AIModuleInterface, cfg config.TranslateConfig, audit *AuditService) *TranslateService {
+	return &TranslateService{
+		repo:   repo,
+		ai:     aiModule,
+		config: cfg,
+		audit:  audit,
+		failed: make(map[string]time.Time),
+	}
+}
+
+// Start runs the job every configured interval until Stop is called
+func (s *TranslateService) Start() {
+	if s.ai == nil || s.quit != nil {
+		return
+	}
+	s.quit = make(chan struct{})
+	ticker := time.NewTicker(s.config.Interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.Run(); err != nil {
+					log.Printf("Machine translation failed: %v", err)
+				}
+			case <-s.quit:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (s *TranslateService) Stop() {
+	if s.quit != nil {
+		close(s.quit)
+		s.quit = nil
+	}
+}
+
+// Trigger starts a run in the background
+func (s *TranslateService) Trigger() error {
+	if s.ai == nil {
+		return &ServiceError{
+			Code:    "TRANSLATE_NOT_CONFIGURED",
+			Message: "Machine translation is not configured",
+		}
+	}
+	s.mu.Lock()
+	running := s.running
+	s.mu.Unlock()
+	if running {
+		return &ServiceError{
+			Code:    "TRANSLATE_IN_PROGRESS",
+			Message: "Machine translation is already running",
+		}
+	}
+	go func() {
+		result, err := s.Run()
+		if err != nil {
+			log.Printf("Machine translation failed: %v", err)
+			return
+		}
+		log.Printf("Machine translation finished: %d translated, %d failed", result.Translated, result.Failed)
+	}()
+	return nil
+}
+
+// Run translates up to BatchSize missing entries across all active languages.
+// Only one run is active at a time.
+func (s *TranslateService) Run() (*TranslateRunResult, error) {
+	if s.ai == nil {
+		return nil, &ServiceError{
+			Code:    "TRANSLATE_NOT_CONFIGURED",
+			Message: "Machine translation is not configured",
+		}
+	}
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return nil, &ServiceError{
+			Code:    "TRANSLATE_IN_PROGRESS",
+			Message: "Machine translation is already running",
+		}
+	}
+	s.running = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	languages, err := s.repo.GetAllLanguages()
+	if err != nil {
+		return nil, &ServiceError{
+			Code:    "DATABASE_ERROR",
+			Message: "Failed to get languages",
+			Cause:   err,
+		}
+	}
+	names := make(map[string]string)
+	defaultLang := ""
+	for _, lang := range languages {
+		names[lang.CkId] = languageName(&lang)
+		if lang.ClDefault {
+			defaultLang = lang.CkId
+		}
+	}
+
+	result := &TranslateRunResult{}
+	budget := s.config.BatchSize
+	for _, lang := range languages {
+		if budget <= 0 {
+			break
+		}
+		candidates, err := s.repo.GetMachineTranslationCandidates(lang.CkId, s.skippedKeys(lang.CkId), budget)
+		if err != nil {
+			return result, &ServiceError{
+				Code:    "DATABASE_ERROR",
+				Message: "Failed to get missing translations",
+				Cause:   err,
+			}
+		}
+		for _, loc := range candidates {
+			budget--
+			source := pickTranslationSource(loc.LocalizationWords, defaultLang)
+			if source == nil {
+				// a key without source text would be picked first on every run
+				s.markFailed(lang.CkId, loc.CkId)
+				continue
+			}
+			inserted, err := s.translate(loc.CkId, source, lang.CkId, names)
+			if err != nil {
+				log.Printf("Machine translation of %s to %s failed: %v", loc.CkId, lang.CkId, err)
+				s.markFailed(lang.CkId, loc.CkId)
+				result.Failed++
+				continue
+			}
+			if !inserted {
+				result.Skipped++
+				continue
+			}
+			result.Translated++
+		}
+	}
+	return result, nil
+}
+
+// translate stores a machine translation of key into langID. It reports false when
+// the key got a translation, or had one deleted, while the AI call was running
+func (s *TranslateService) translate(key string, source *models.TLocalizationWord, langID string, names map[string]string) (bool, error) {
+	response, err := s.ai.SendMessage(ai.AIMessageRequest{
+		SessionID: uuid.New(),
+		Message:   translatePrompt(source.TextWord.CvText, names[source.CkLang], names[langID]),
+	})
+	if err != nil {
+		return false, err
+	}
+	text := strings.TrimSpace(response.Message)
+	if text == "" {
+		return false, fmt.Errorf("empty translation")
+	}
+	word, err := s.repo.GetOrCreateWord(text, MachineTranslationUser, nil)
+	if err != nil {
+		return false, err
+	}
+	return s.repo.InsertMachineTranslation(&models.TLocalizationWord{
+		CkLocalization: key,
+		CkLang:         langID,
+		CkText:         word.CkId,
+		ClMachine:      true,
+		BaseModel: models.BaseModel{
+			CkCreate: MachineTranslationUser,
+			CkModify: MachineTranslationUser,
+		},
+	})
+}
+
+// GetPendingReview returns machine translations of langID awaiting review
+func (s *TranslateService) GetPendingReview(langID string, offset, limit *int) ([]PendingReviewResponse, int64, error) {
+	words, total, err := s.repo.GetPendingReview(langID, offset, limit)
+	if err != nil {
+		return nil, 0, &ServiceError{
+			Code:    "DATABASE_ERROR",
+			Message: "Failed to get translations pending review",
+			Cause:   err,
+		}
+	}
+	response := make([]PendingReviewResponse, len(words))
+	for i, word := range words {
+		item := PendingReviewResponse{
+			Key:          word.CkLocalization,
+			Lang:         word.CkLang,
+			Translations: make(map[string]string),
+			CreatedAt:    word.CtModify,
+		}
+		if word.TextWord != nil {
+			item.Text = word.TextWord.CvText
+		}
+		if word.Localization != nil {
+			for _, source := range word.Localization.LocalizationWords {
+				if source.TextWord != nil {
+					item.Translations[source.CkLang] = source.TextWord.CvText
+				}
+			}
+		}
+		response[i] = item
+	}
+	return response, total, nil
+}
+
+// ApproveTranslations marks machine translations as reviewed; corrections go
+// through BulkUpdateTranslations, which clears the machine flag
+func (s *TranslateService) ApproveTranslations(req *ApproveTranslationsRequest, actor AuditActor) (int64, error) {
+	lang := strings.ToUpper(req.Lang)
+	approved, err := s.repo.ApproveTranslations(lang, req.Keys, actor.User)
+	if err != nil {
+		return 0, &ServiceError{
+			Code:    "DATABASE_ERROR",
+			Message: "Failed to approve translations",
+			Cause:   err,
+		}
+	}
+	if approved > 0 {
+		s.audit.RecordCommitted(actor, AuditEntry{
+			Action:     AuditTranslationsApprove,
+			EntityType: "t_d_lang",
+			EntityID:   lang,
+			After:      map[string]any{"keys": req.Keys, "approved": approved},
+		})
+	}
+	return approved, nil
+}
+
+func (s *TranslateService) markFailed(langID string, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed[langID+":"+key] = time.Now()
+}
+
+// skippedKeys returns keys of langID that failed recently and forgets older failures
+func (s *TranslateService) skippedKeys(langID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for id, at := range s.failed {
+		if time.Since(at) > s.config.RetryAfter {
+			delete(s.failed, id)
+			continue
+		}
+		if key, ok := strings.CutPrefix(id, langID+":"); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// pickTranslationSource prefers a human translation in the default language,
+// then any human translation, then a machine one
+func pickTranslationSource(words []models.TLocalizationWord, defaultLang string) *models.TLocalizationWord {
+	var best *models.TLocalizationWord
+	bestScore := -1
+	for i := range words {
+		word := &words[i]
+		if word.TextWord == nil || strings.TrimSpace(word.TextWord.CvText) == "" {
+			continue
+		}
+		score := 0
+		if !word.ClMachine {
+			score += 2
+		}
+		if word.CkLang == defaultLang {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = word, score
+		}
+	}
+	return best
+}
+
+func translatePrompt(text string, from string, to string) string {
+	return fmt.Sprintf("Translate the following text from %s to %s. "+
+		"Keep placeholders like {{name}} and HTML tags unchanged. "+
+		"Reply with the translation only, without quotes or comments.\n\n%s", from, to, text)
+}
+
+func languageName(lang *models.TDLang) string {
+	if lang.NameWord != nil && lang.NameWord.CvText != "" {
+		return fmt.Sprintf("%s (%s)", lang.NameWord.CvText, lang.CvCodeBrowser)
+	}
+	return lang.CkId
+}
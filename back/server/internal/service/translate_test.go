@@ -0,0 +1,107 @@
+package service
+
+import (
+	"parier-server/internal/config"
+	"parier-server/internal/models"
+	"parier-server/internal/module/ai"
+	"parier-server/internal/repository"
+	"parier-server/internal/util/dbtest"
+	"testing"
+)
+
+func TestPickTranslationSource(t *testing.T) {
+	word := func(lang string, text string, machine bool) models.TLocalizationWord {
+		return models.TLocalizationWord{CkLang: lang, ClMachine: machine, TextWord: &models.TLWord{CvText: text}}
+	}
+	tests := []struct {
+		name  string
+		words []models.TLocalizationWord
+		want  string
+	}{
+		{"default language human", []models.TLocalizationWord{word("RU", "Привет", false), word("EN", "Hello", false)}, "EN"},
+		{"human over machine default", []models.TLocalizationWord{word("EN", "Hello", true), word("RU", "Привет", false)}, "RU"},
+		{"machine only", []models.TLocalizationWord{word("DE", "Hallo", true)}, "DE"},
+		{"skip empty text", []models.TLocalizationWord{word("EN", " ", false), word("RU", "Привет", true)}, "RU"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pickTranslationSource(tt.words, "EN")
+			if got == nil || got.CkLang != tt.want {
+				t.Errorf("expected %s, got %+v", tt.want, got)
+			}
+		})
+	}
+	if pickTranslationSource(nil, "EN") != nil {
+		t.Error("expected nil source for no translations")
+	}
+}
+
+// translatorStub отвечает переводом и перед ответом вызывает during, изображая
+// то, что успело произойти, пока шёл запрос к модели
+type translatorStub struct {
+	reply  string
+	during func()
+}
+
+func (a *translatorStub) Init() error  { return nil }
+func (a *translatorStub) Close() error { return nil }
+
+func (a *translatorStub) SendMessage(ai.AIMessageRequest) (ai.AIMessageResponse, error) {
+	if a.during != nil {
+		a.during()
+	}
+	return ai.AIMessageResponse{Message: a.reply}, nil
+}
+
+func TestMachineTranslationRace(t *testing.T) {
+	tests := []struct {
+		name     string
+		during   func(row *localizationRow)
+		inserted bool
+		want     localizationRow
+	}{
+		{"no translation", nil, true, localizationRow{exists: true, text: "Hallo", machine: true}},
+		{"saved by a person meanwhile", func(row *localizationRow) {
+			*row = localizationRow{exists: true, text: "Servus"}
+		}, false, localizationRow{exists: true, text: "Servus"}},
+		{"deleted by an admin meanwhile", func(row *localizationRow) {
+			*row = localizationRow{exists: true, text: "Servus", deleted: true}
+		}, false, localizationRow{exists: true, text: "Servus", deleted: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var row localizationRow
+			db, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+				if !q.Has(`INSERT INTO "t_localization_word"`) {
+					return dbtest.Result{}
+				}
+				// так ведёт себя Postgres: DO NOTHING не трогает существующую строку,
+				// DO UPDATE перезаписывает и живую, и удалённую
+				if row.exists && !q.Has("DO UPDATE") {
+					return dbtest.Result{}
+				}
+				row = localizationRow{exists: true, text: "Hallo", machine: true}
+				return dbtest.Changed(q)
+			})
+			stub := &translatorStub{reply: "Hallo"}
+			if tt.during != nil {
+				stub.during = func() { tt.during(&row) }
+			}
+			s := NewTranslateService(repository.NewLocalizationRepository(db), stub, config.TranslateConfig{}, nil)
+			source := &models.TLocalizationWord{CkLang: "EN", TextWord: &models.TLWord{CvText: "Hello"}}
+			inserted, err := s.translate("greeting", source, "DE", map[string]string{"EN": "English", "DE": "German"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if inserted != tt.inserted || row != tt.want {
+				t.Errorf("expected inserted=%v %+v, got inserted=%v %+v", tt.inserted, tt.want, inserted, row)
+			}
+		})
+	}
+}
+
+// localizationRow - строка t_localization_word одного ключа и языка
+type localizationRow struct {
+	exists, deleted, machine bool
+	text                     string
+}
@@ -14,16 +14,20 @@ const (
 	TxTypeBet         = "BET"
 	TxTypeWin         = "WIN"
 	TxTypeAdminCredit = "ADMIN_CREDIT"
+	TxTypeReferral    = "REFERRAL"
 	TxStatusCompleted = "COMPLETED"
 )
 
 type WalletService struct {
-	repo *repository.UserRepository
-	db   *gorm.DB
+	repo          *repository.UserRepository
+	db            *gorm.DB
+	audit         *AuditService
+	notifications *NotificationService
+	events        *EventHub
 }
 
-func NewWalletService(repo *repository.UserRepository, db *gorm.DB) *WalletService {
-	return &WalletService{repo: repo, db: db}
+func NewWalletService(repo *repository.UserRepository, db *gorm.DB, audit *AuditService, notifications *NotificationService, events *EventHub) *WalletService {
+	return &WalletService{repo: repo, db: db, audit: audit, notifications: notifications, events: events}
 }
 
 type BalanceResponse struct {
@@ -77,7 +81,7 @@ func (s *WalletService) GetBalance(userID uuid.UUID) (*BalanceResponse, error) {
 	}, nil
 }
 
-func (s *WalletService) Deposit(userID uuid.UUID, amount float64, description string) (*BalanceResponse, error) {
+func (s *WalletService) Deposit(userID uuid.UUID, amount float64, description string, actor AuditActor) (*BalanceResponse, error) {
 	if amount <= 0 {
 		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Amount must be positive"}
 	}
@@ -105,6 +109,7 @@ func (s *WalletService) Deposit(userID uuid.UUID, amount float64, description st
 		}
 	}()
 
+	balanceBefore := wallet.CnValue
 	wallet.CnValue += amount
 	wallet.CkModify = userID.String()
 	if err := tx.Save(wallet).Error; err != nil {
@@ -128,6 +133,21 @@ func (s *WalletService) Deposit(userID uuid.UUID, amount float64, description st
 		return nil, err
 	}
 
+	if err := s.audit.Record(tx, actor, walletAuditEntry(AuditWalletDeposit, wallet, balanceBefore, tr)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := s.notifications.Notify(tx, userID.String(), walletNotice(models.NotificationTypeWalletDeposit, wallet, tr, description)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := publishWalletEvent(s.events, tx, wallet, tr); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return nil, err
 	}
@@ -135,7 +155,7 @@ func (s *WalletService) Deposit(userID uuid.UUID, amount float64, description st
 	return s.GetBalance(userID)
 }
 
-func (s *WalletService) Withdraw(userID uuid.UUID, amount float64, description string) (*BalanceResponse, error) {
+func (s *WalletService) Withdraw(userID uuid.UUID, amount float64, description string, actor AuditActor) (*BalanceResponse, error) {
 	if amount <= 0 {
 		return nil, &ServiceError{Code: "VALIDATION_ERROR", Message: "Amount must be positive"}
 	}
@@ -156,6 +176,7 @@ func (s *WalletService) Withdraw(userID uuid.UUID, amount float64, description s
 		}
 	}()
 
+	balanceBefore := wallet.CnValue
 	wallet.CnValue -= amount
 	wallet.CkModify = userID.String()
 	if err := tx.Save(wallet).Error; err != nil {
@@ -179,6 +200,21 @@ func (s *WalletService) Withdraw(userID uuid.UUID, amount float64, description s
 		return nil, err
 	}
 
+	if err := s.audit.Record(tx, actor, walletAuditEntry(AuditWalletWithdraw, wallet, balanceBefore, tr)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := s.notifications.Notify(tx, userID.String(), walletNotice(models.NotificationTypeWalletWithdrawal, wallet, tr, description)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := publishWalletEvent(s.events, tx, wallet, tr); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return nil, err
 	}
@@ -186,6 +222,41 @@ func (s *WalletService) Withdraw(userID uuid.UUID, amount float64, description s
 	return s.GetBalance(userID)
 }
 
+// walletNotice - уведомление владельца кошелька об изменении баланса транзакцией
+func walletNotice(kind models.NotificationType, wallet *models.TUserWallet, tr *models.TUserTransaction, description string) Notice {
+	return Notice{
+		User: wallet.CkUser,
+		Type: kind,
+		Payload: map[string]any{
+			"transaction_id": tr.CkId,
+			"amount":         tr.CnAmount,
+			"balance":        wallet.CnValue,
+			"description":    description,
+		},
+	}
+}
+
+// publishWalletEvent сообщает владельцу кошелька новый баланс после фиксации транзакции
+func publishWalletEvent(events *EventHub, tx *gorm.DB, wallet *models.TUserWallet, tr *models.TUserTransaction) error {
+	return events.Publish(tx, userTopic(TopicWallet, wallet.CkUser), EventWalletBalance, map[string]any{
+		"transaction_id": tr.CkId,
+		"type":           tr.CkType,
+		"amount":         tr.CnAmount,
+		"balance":        wallet.CnValue,
+	})
+}
+
+// walletAuditEntry описывает изменение баланса кошелька транзакцией
+func walletAuditEntry(action string, wallet *models.TUserWallet, balanceBefore float64, tr *models.TUserTransaction) AuditEntry {
+	return AuditEntry{
+		Action:     action,
+		EntityType: "t_user_wallet",
+		EntityID:   wallet.CkId.String(),
+		Before:     map[string]any{"ck_user": wallet.CkUser, "cn_value": balanceBefore},
+		After:      map[string]any{"ck_user": wallet.CkUser, "cn_value": wallet.CnValue, "ck_transaction": tr.CkId},
+	}
+}
+
 type TransactionResponse struct {
 	Id            string  `json:"id"`
 	UserId        string  `json:"userId"`
@@ -220,6 +291,7 @@ func (s *WalletService) GetTransactions(userID uuid.UUID, offset, limit int) ([]
 		TxTypeBet:         "bet",
 		TxTypeWin:         "win",
 		TxTypeAdminCredit: "admin_credit",
+		TxTypeReferral:    "referral",
 	}
 	for i, t := range transactions {
 		ftype := typeMap[t.CkType]
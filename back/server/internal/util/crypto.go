@@ -0,0 +1,112 @@
+package util
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// APITokenPrefix отличает API токены от JWT Keycloak в заголовке Authorization
+const APITokenPrefix = "prt_"
+
+// EncryptString шифрует строку AES-256-GCM ключом, полученным из секрета
+//
+// Параметры:
+//   - plaintext: Строка для шифрования
+//   - secret: Секрет для получения ключа
+//
+// Возвращает:
+//   - string: Nonce и шифротекст в base64url
+//   - error: Ошибка шифрования
+func EncryptString(plaintext string, secret string) (string, error) {
+	gcm, err := newGCM(secret)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString расшифровывает строку, зашифрованную EncryptString
+//
+// Параметры:
+//   - ciphertext: Результат EncryptString
+//   - secret: Секрет, использованный при шифровании
+//
+// Возвращает:
+//   - string: Исходная строка
+//   - error: Ошибка, если данные повреждены или секрет не совпадает
+func DecryptString(ciphertext string, secret string) (string, error) {
+	gcm, err := newGCM(secret)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	nonce, data := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(secret string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// GenerateAPIToken создаёт случайный API токен с префиксом APITokenPrefix
+//
+// Возвращает:
+//   - string: Токен; показывается пользователю один раз, хранится только HashAPIToken
+//   - error: Ошибка генератора случайных чисел
+func GenerateAPIToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return APITokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// IsAPIToken проверяет, похожа ли строка на API токен
+//
+// Параметры:
+//   - token: Значение после "Bearer "
+//
+// Возвращает:
+//   - bool: true если строка начинается с APITokenPrefix
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// HashAPIToken возвращает SHA-256 токена в hex. Токен случайный и длинный,
+// поэтому медленный хеш не нужен, а по быстрому можно искать в БД.
+//
+// Параметры:
+//   - token: API токен
+//
+// Возвращает:
+//   - string: Хеш для хранения и поиска
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,240 @@
+// Package dbtest подменяет Postgres в тестах: GORM строит настоящий SQL диалекта
+// Postgres, а выполняет его обработчик теста. Обработчик видит запрос с аргументами
+// и возвращает строки или число изменённых строк, так что тест может держать
+// в памяти нужную ему часть таблицы и проверять, какие запросы пришли в базу
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Query - запрос к базе; транзакции приходят как BEGIN, COMMIT и ROLLBACK
+type Query struct {
+	SQL  string
+	Args []driver.Value
+}
+
+// Has сообщает, что запрос содержит все части parts
+func (q Query) Has(parts ...string) bool {
+	for _, part := range parts {
+		if !strings.Contains(q.SQL, part) {
+			return false
+		}
+	}
+	return true
+}
+
+// Value возвращает аргумент, который запрос пишет в столбец column: присваивание
+// "column"=$N в UPDATE или значение столбца в первой строке VALUES у INSERT
+func (q Query) Value(column string) (driver.Value, bool) {
+	quoted := `"` + column + `"`
+	if _, rest, ok := strings.Cut(q.SQL, quoted+"=$"); ok {
+		return q.arg(rest)
+	}
+	columns, rest, ok := strings.Cut(q.SQL, ") VALUES (")
+	if !ok {
+		return nil, false
+	}
+	_, columns, _ = strings.Cut(columns, " (")
+	values, _, _ := strings.Cut(rest, ")")
+	placeholders := strings.Split(values, ",")
+	for i, name := range strings.Split(columns, ",") {
+		if name == quoted && i < len(placeholders) {
+			return q.arg(strings.TrimPrefix(placeholders[i], "$"))
+		}
+	}
+	return nil, false
+}
+
+// arg возвращает аргумент по номеру плейсхолдера в начале s
+func (q Query) arg(s string) (driver.Value, bool) {
+	end := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if end < 0 {
+		end = len(s)
+	}
+	n, err := strconv.Atoi(s[:end])
+	if err != nil || n < 1 || n > len(q.Args) {
+		return nil, false
+	}
+	return q.Args[n-1], true
+}
+
+// Result - ответ на запрос: строки для SELECT и RETURNING, Affected для остальных
+type Result struct {
+	Columns  []string
+	Rows     [][]driver.Value
+	Affected int64
+	Err      error
+}
+
+// Handler отвечает на запрос; пустой Result - ни строк, ни изменений
+type Handler func(query Query) Result
+
+// Changed - ответ на INSERT или UPDATE, изменивший одну строку. Для запроса с RETURNING
+// возвращается одна строка с пустыми значениями: по ней GORM считает изменённые строки
+func Changed(query Query) Result {
+	_, returning, ok := strings.Cut(query.SQL, " RETURNING ")
+	if !ok {
+		return Result{Affected: 1}
+	}
+	var columns []string
+	for _, column := range strings.Split(returning, ",") {
+		columns = append(columns, strings.Trim(strings.TrimSpace(column), `"`))
+	}
+	return Result{Columns: columns, Rows: [][]driver.Value{make([]driver.Value, len(columns))}, Affected: 1}
+}
+
+// DB - база теста и журнал пришедших в неё запросов
+type DB struct {
+	mu      sync.Mutex
+	handler Handler
+	queries []Query
+}
+
+// Open открывает GORM поверх обработчика. Без обработчика на все запросы приходит пустой ответ
+func Open(t testing.TB, handler Handler) (*gorm.DB, *DB) {
+	t.Helper()
+	fake := &DB{handler: handler}
+	pool := sql.OpenDB(connector{fake})
+	t.Cleanup(func() { pool.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: pool}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	return db, fake
+}
+
+// Queries возвращает запросы в порядке поступления
+func (d *DB) Queries() []Query {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Query(nil), d.queries...)
+}
+
+// Count считает запросы, содержащие все части parts
+func (d *DB) Count(parts ...string) int {
+	count := 0
+	for _, query := range d.Queries() {
+		if query.Has(parts...) {
+			count++
+		}
+	}
+	return count
+}
+
+func (d *DB) run(query string, args []driver.NamedValue) Result {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	q := Query{SQL: query, Args: values}
+	d.mu.Lock()
+	d.queries = append(d.queries, q)
+	handler := d.handler
+	d.mu.Unlock()
+	if handler == nil {
+		return Result{}
+	}
+	return handler(q)
+}
+
+type connector struct{ db *DB }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return &conn{db: c.db}, nil }
+func (c connector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return nil, driver.ErrSkip }
+
+type conn struct{ db *DB }
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) { return &stmt{conn: c, query: query}, nil }
+func (c *conn) Close() error                              { return nil }
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	if res := c.db.run("BEGIN", nil); res.Err != nil {
+		return nil, res.Err
+	}
+	return tx{c}, nil
+}
+
+func (c *conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res := c.db.run(query, args)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return &rows{columns: res.Columns, values: res.Rows}, nil
+}
+
+func (c *conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res := c.db.run(query, args)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return driver.RowsAffected(res.Affected), nil
+}
+
+type tx struct{ conn *conn }
+
+func (t tx) Commit() error   { return t.conn.db.run("COMMIT", nil).Err }
+func (t tx) Rollback() error { return t.conn.db.run("ROLLBACK", nil).Err }
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	values := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		values[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return values
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
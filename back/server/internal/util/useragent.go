@@ -0,0 +1,75 @@
+package util
+
+import "strings"
+
+// UserAgentInfo описывает устройство, определённое по заголовку User-Agent
+type UserAgentInfo struct {
+	Device  string `json:"device"`  // desktop, mobile, tablet, bot или unknown
+	OS      string `json:"os"`      // название ОС без версии
+	Browser string `json:"browser"` // название браузера без версии
+}
+
+// Порядок важен: Edge и Opera содержат "Chrome", Chrome содержит "Safari"
+var uaBrowsers = []struct{ token, name string }{
+	{"edg/", "Edge"},
+	{"opr/", "Opera"},
+	{"yabrowser/", "Yandex Browser"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"safari/", "Safari"},
+}
+
+var uaSystems = []struct{ token, name string }{
+	{"windows", "Windows"},
+	{"iphone", "iOS"},
+	{"ipad", "iPadOS"},
+	{"android", "Android"},
+	{"cros", "ChromeOS"},
+	{"mac os x", "macOS"},
+	{"linux", "Linux"},
+}
+
+// ParseUserAgent определяет тип устройства, ОС и браузер по User-Agent.
+// Разбор упрощённый и рассчитан на отображение списка сессий пользователю.
+//
+// Параметры:
+//   - userAgent: Значение заголовка User-Agent
+//
+// Возвращает:
+//   - UserAgentInfo: Устройство, ОС и браузер; неизвестные значения - "unknown"
+func ParseUserAgent(userAgent string) UserAgentInfo {
+	ua := strings.ToLower(userAgent)
+	info := UserAgentInfo{Device: "unknown", OS: "unknown", Browser: "unknown"}
+	if ua == "" {
+		return info
+	}
+	if strings.Contains(ua, "bot") || strings.Contains(ua, "spider") || strings.Contains(ua, "crawl") {
+		info.Device = "bot"
+		return info
+	}
+	for _, os := range uaSystems {
+		if strings.Contains(ua, os.token) {
+			info.OS = os.name
+			break
+		}
+	}
+	for _, browser := range uaBrowsers {
+		if strings.Contains(ua, browser.token) {
+			info.Browser = browser.name
+			break
+		}
+	}
+	switch {
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") ||
+		(strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		info.Device = "tablet"
+	case strings.Contains(ua, "mobile") || strings.Contains(ua, "iphone"):
+		info.Device = "mobile"
+	case info.OS != "unknown":
+		info.Device = "desktop"
+	}
+	return info
+}